@@ -0,0 +1,403 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+//neoctl 运维命令行工具，基于WalletManager的接口操作，不需要编写Go代码
+//
+//	neoctl -conf conf/NEO.ini status
+//	neoctl -conf conf/NEO.ini rescan -height 100
+//	neoctl -conf conf/NEO.ini unscanned
+//	neoctl -conf conf/NEO.ini rebuild-utxo -wallet W1
+//	neoctl -conf conf/NEO.ini reindex -from 100
+//	neoctl decode -hex 8000...
+//	neoctl -conf conf/NEO.ini broadcast -hex 8000...
+//	neoctl -conf conf/NEO.ini fixtures -heights 100,200 -txids 0xabc... -out fixtures.json
+//	neoctl -conf conf/NEO.ini address-book -import counterparties.csv
+//	NEO_SERVER_API=http://127.0.0.1:10332 neoctl -conf conf/NEO.ini,conf/local.toml status
+//
+//配置了operationToken时，修改数据的命令（rescan、rebuild-utxo、reindex、broadcast）需要 -token
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+)
+
+var commands = map[string]func(conf string, args []string) error{
+	"status":       statusCmd,
+	"rescan":       rescanCmd,
+	"unscanned":    unscannedCmd,
+	"rebuild-utxo": rebuildUTXOCmd,
+	"reindex":      reindexCmd,
+	"decode":       decodeCmd,
+	"broadcast":    broadcastCmd,
+	"fixtures":     fixturesCmd,
+	"address-book": addressBookCmd,
+}
+
+func main() {
+
+	conf := flag.String("conf", "conf/NEO.ini", "config file paths separated by comma, toml, json or ini, later files override earlier ones")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd(*conf, flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: neoctl [-conf file] <command> [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  status        show local scanned block and node block height\n")
+	fmt.Fprintf(os.Stderr, "  rescan        reset scanner to rescan from -height\n")
+	fmt.Fprintf(os.Stderr, "  unscanned     list unscan records\n")
+	fmt.Fprintf(os.Stderr, "  rebuild-utxo  rebuild local unspent records of -wallet\n")
+	fmt.Fprintf(os.Stderr, "  reindex       rebuild local indexes -from height, rerun with the same height to resume\n")
+	fmt.Fprintf(os.Stderr, "  decode        decode raw transaction -hex\n")
+	fmt.Fprintf(os.Stderr, "  broadcast     broadcast raw transaction -hex\n")
+	fmt.Fprintf(os.Stderr, "  fixtures      capture blocks -heights and transactions -txids as json-rpc fixtures\n")
+	fmt.Fprintf(os.Stderr, "  address-book  list address book entries of -category, -import csv or ndjson file, -remove address\n\n")
+	flag.PrintDefaults()
+}
+
+//loadWalletManager 加载配置文件创建钱包管理者，多个文件用逗号分隔，后面的覆盖前面的
+func loadWalletManager(conf string) (*neocoin.WalletManager, error) {
+	wm := neocoin.NewWalletManager()
+	err := wm.LoadConfig(splitList(conf)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return wm, nil
+}
+
+func statusCmd(conf string, args []string) error {
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	localHeight, localHash := wm.GetLocalNewBlock()
+	fmt.Printf("local height: %d\n", localHeight)
+	fmt.Printf("local hash:   %s\n", localHash)
+
+	nodeHeight, err := wm.GetBlockHeight()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("node height:  %d\n", nodeHeight)
+	if nodeHeight > localHeight {
+		fmt.Printf("behind:       %d\n", nodeHeight-localHeight)
+	}
+
+	return nil
+}
+
+func rescanCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("rescan", flag.ExitOnError)
+	height := fs.Uint64("height", 0, "block height to rescan from")
+	token := fs.String("token", "", "operation token")
+	fs.Parse(args)
+
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	err = wm.GrantCapability(*token, neocoin.CapabilityRescan)
+	if err != nil {
+		return err
+	}
+
+	err = wm.Blockscanner.SetRescanBlockHeight(*height)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("scanner will rescan from height: %d\n", *height)
+	return nil
+}
+
+func unscannedCmd(conf string, args []string) error {
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	list, err := wm.GetUnscanRecords()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range list {
+		fmt.Printf("height: %d\ttxid: %s\treason: %s\n", r.BlockHeight, r.TxID, r.Reason)
+	}
+	fmt.Printf("total: %d\n", len(list))
+
+	return nil
+}
+
+func rebuildUTXOCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("rebuild-utxo", flag.ExitOnError)
+	walletID := fs.String("wallet", "", "wallet id")
+	token := fs.String("token", "", "operation token")
+	fs.Parse(args)
+
+	if len(*walletID) == 0 {
+		return fmt.Errorf("wallet id is empty")
+	}
+
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	err = wm.GrantCapability(*token, neocoin.CapabilityDeleteLocal)
+	if err != nil {
+		return err
+	}
+
+	err = wm.RebuildWalletUnspent(*walletID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wallet: %s unspent rebuilt\n", *walletID)
+	return nil
+}
+
+func reindexCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	from := fs.Uint64("from", 0, "block height to rebuild from")
+	status := fs.Bool("status", false, "show progress of the last reindex only")
+	token := fs.String("token", "", "operation token")
+	fs.Parse(args)
+
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	if !*status {
+		err = wm.GrantCapability(*token, neocoin.CapabilityRescan)
+		if err != nil {
+			return err
+		}
+
+		err = wm.Blockscanner.RebuildLocalData(*from)
+		if err != nil {
+			return err
+		}
+	}
+
+	checkpoint, err := wm.GetRebuildCheckpoint()
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		fmt.Printf("no reindex record\n")
+		return nil
+	}
+
+	fmt.Printf("from height: %d\n", checkpoint.FromHeight)
+	fmt.Printf("to height:   %d\n", checkpoint.ToHeight)
+	fmt.Printf("next height: %d\n", checkpoint.NextHeight)
+	fmt.Printf("txs:         %d\n", checkpoint.TxCount)
+	fmt.Printf("progress:    %.2f%%\n", checkpoint.Progress()*100)
+	return nil
+}
+
+func decodeCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	rawHex := fs.String("hex", "", "raw transaction hex")
+	fs.Parse(args)
+
+	txBytes, err := hex.DecodeString(*rawHex)
+	if err != nil {
+		return err
+	}
+
+	tx, err := neoTransaction.DecodeRawTransaction(txBytes)
+	if err != nil {
+		return err
+	}
+
+	txid, err := tx.GetHash()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("txid: %s\n", txid)
+	fmt.Println(tx.String())
+
+	return nil
+}
+
+func broadcastCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	rawHex := fs.String("hex", "", "signed raw transaction hex")
+	token := fs.String("token", "", "operation token")
+	fs.Parse(args)
+
+	if len(*rawHex) == 0 {
+		return fmt.Errorf("transaction hex is empty")
+	}
+
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	err = wm.GrantCapability(*token, neocoin.CapabilityBroadcast)
+	if err != nil {
+		return err
+	}
+
+	result, err := wm.SendRawTransaction(*rawHex)
+	if err != nil {
+		return err
+	}
+
+	txid, err := neocoin.GetTxId(*rawHex)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("result: %s\n", result)
+	fmt.Printf("txid:   %s\n", txid)
+	return nil
+}
+
+func fixturesCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("fixtures", flag.ExitOnError)
+	heightList := fs.String("heights", "", "block heights separated by comma")
+	txidList := fs.String("txids", "", "transaction ids separated by comma")
+	out := fs.String("out", "fixtures.json", "output fixtures file")
+	fs.Parse(args)
+
+	heights := make([]uint64, 0)
+	for _, s := range splitList(*heightList) {
+		height, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height: %s", s)
+		}
+		heights = append(heights, height)
+	}
+	txids := splitList(*txidList)
+
+	if len(heights) == 0 && len(txids) == 0 {
+		return fmt.Errorf("heights and txids are empty")
+	}
+
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	set, err := wm.CaptureFixtures(heights, txids)
+	if err != nil {
+		return err
+	}
+
+	err = set.SaveFile(*out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("captured %d calls to %s\n", len(set.Calls), *out)
+	return nil
+}
+
+func addressBookCmd(conf string, args []string) error {
+	fs := flag.NewFlagSet("address-book", flag.ExitOnError)
+	category := fs.String("category", "", "list entries of category, empty for all")
+	importFile := fs.String("import", "", "import csv (address,name[,category[,note]]) or ndjson file")
+	remove := fs.String("remove", "", "remove entry of address")
+	fs.Parse(args)
+
+	wm, err := loadWalletManager(conf)
+	if err != nil {
+		return err
+	}
+
+	if len(*importFile) > 0 {
+		f, err := os.Open(*importFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		count, err := wm.ImportAddressBook(f)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d entries from %s\n", count, *importFile)
+		return nil
+	}
+
+	if len(*remove) > 0 {
+		err = wm.RemoveAddressBookEntry(*remove)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("address: %s removed\n", *remove)
+		return nil
+	}
+
+	list, err := wm.ListAddressBook(*category)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range list {
+		fmt.Printf("address: %s\tname: %s\tcategory: %s\tnote: %s\n", entry.Address, entry.Name, entry.Category, entry.Note)
+	}
+	fmt.Printf("total: %d\n", len(list))
+
+	return nil
+}
+
+//splitList 拆分逗号分隔的参数
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -12,7 +12,9 @@ require (
 	github.com/btcsuite/btcd v0.0.0-20190315201642-aa6e0f35703c
 	github.com/btcsuite/btcutil v0.0.0-20190316010144-3ac1210f4b38
 	github.com/codeskyblue/go-sh v0.0.0-20190328095946-f4ce45e7999e
+	github.com/golang/protobuf v1.3.1
 	github.com/ethereum/go-ethereum v1.9.6
+	github.com/golang/protobuf v1.3.1
 	github.com/graarh/golang-socketio v0.0.0-20170510162725-2c44953b9b5f
 	github.com/imroc/req v0.2.3
 	github.com/ontio/ontology v1.8.2
@@ -20,4 +22,7 @@ require (
 	github.com/pkg/errors v0.8.1
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
 	github.com/tidwall/gjson v1.2.1
+	go.etcd.io/bbolt v1.3.2
+	golang.org/x/crypto v0.0.0-20191029031824-8986dd9e96cf
+	google.golang.org/grpc v1.18.0
 )
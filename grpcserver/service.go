@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+//grpcserver 把适配器的扫描流程以scan_service.proto定义的ScanService提供给非Go服务，
+//包括区块头和提取数据的服务端流，以及重扫和添加观测地址的控制接口。
+//Service基于neo.Client实现，NewServer把它注册到grpc.Server，scan_service.pb.go由scan_service.proto生成
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neo"
+	"github.com/blocktree/openwallet/openwallet"
+	"google.golang.org/grpc"
+)
+
+var (
+	//ErrStreamOverflow 订阅流的缓冲写满，客户端消费过慢，服务端断开该流
+	ErrStreamOverflow = errors.New("stream buffer overflow, consumer is too slow")
+	//ErrServiceClosed 服务已关闭
+	ErrServiceClosed = errors.New("scan service is closed")
+)
+
+//Options 服务选项
+type Options struct {
+	BufferSize int //每个订阅流缓冲的通知数量，写满时断开该流，默认1000
+}
+
+//Service ScanService的实现
+type Service struct {
+	client      *neo.Client
+	opts        Options
+	mu          sync.RWMutex
+	headerSubs  map[*subscription]struct{}
+	extractSubs map[*subscription]struct{}
+	closed      chan struct{}
+	unsubscribe func()
+}
+
+//subscription 一个订阅流的缓冲通道
+type subscription struct {
+	ch         chan interface{}
+	overflow   chan struct{}
+	once       sync.Once
+	sourceKeys map[string]bool
+}
+
+func newSubscription(size int, sourceKeys []string) *subscription {
+	sub := &subscription{
+		ch:       make(chan interface{}, size),
+		overflow: make(chan struct{}),
+	}
+	if len(sourceKeys) > 0 {
+		sub.sourceKeys = make(map[string]bool, len(sourceKeys))
+		for _, key := range sourceKeys {
+			sub.sourceKeys[key] = true
+		}
+	}
+	return sub
+}
+
+//push 写入通知，缓冲写满时标记溢出，不阻塞扫描
+func (sub *subscription) push(v interface{}) {
+	select {
+	case sub.ch <- v:
+	default:
+		sub.once.Do(func() {
+			close(sub.overflow)
+		})
+	}
+}
+
+//NewService 创建服务并订阅扫描器的通知
+func NewService(client *neo.Client, opts Options) (*Service, error) {
+
+	if client == nil {
+		return nil, errors.New("client is nil")
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1000
+	}
+
+	s := &Service{
+		client:      client,
+		opts:        opts,
+		headerSubs:  make(map[*subscription]struct{}),
+		extractSubs: make(map[*subscription]struct{}),
+		closed:      make(chan struct{}),
+	}
+
+	unsubscribe, err := client.Scanner().Subscribe(&serviceObserver{s: s}, neo.SubscribeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	s.unsubscribe = unsubscribe
+
+	return s, nil
+}
+
+//NewServer 创建grpc.Server并注册ScanService，调用方用Serve在监听上提供服务
+func NewServer(s *Service, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	RegisterScanServiceServer(server, s)
+	return server
+}
+
+//Close 取消订阅扫描器的通知，结束所有订阅流
+func (s *Service) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+	close(s.closed)
+	s.unsubscribe()
+}
+
+//StreamBlockHeaders 推送区块头，from_height大于0时先从节点回放到已扫描高度，再推送新区块
+func (s *Service) StreamBlockHeaders(req *StreamBlockHeadersRequest, stream ScanService_StreamBlockHeadersServer) error {
+
+	ctx := stream.Context()
+
+	//先订阅再回放，回放期间的新区块缓冲在订阅中
+	sub := newSubscription(s.opts.BufferSize, nil)
+	if err := s.addSubscription(s.headerSubs, sub); err != nil {
+		return err
+	}
+	defer s.removeSubscription(s.headerSubs, sub)
+
+	replayed := uint64(0)
+	if req.FromHeight > 0 {
+		scanned, err := s.client.Scanner().ScannedHeader(ctx)
+		if err != nil {
+			return err
+		}
+		for height := req.FromHeight; height <= scanned.Height; height++ {
+			block, err := s.client.BlockByHeight(ctx, height)
+			if err != nil {
+				return err
+			}
+			if err = stream.Send(toBlockHeader(block.BlockHeader(s.client.Manager().Symbol()))); err != nil {
+				return err
+			}
+			replayed = height
+		}
+	}
+
+	return s.serve(ctx, sub, func(v interface{}) error {
+		header := v.(*BlockHeader)
+		//已回放的区块不重复推送，分叉通知照常推送
+		if !header.Fork && header.Height <= replayed {
+			return nil
+		}
+		return stream.Send(header)
+	})
+}
+
+//StreamExtractData 推送提取数据，source_keys不为空时只推送这些账户的数据
+func (s *Service) StreamExtractData(req *StreamExtractDataRequest, stream ScanService_StreamExtractDataServer) error {
+
+	sub := newSubscription(s.opts.BufferSize, req.SourceKeys)
+	if err := s.addSubscription(s.extractSubs, sub); err != nil {
+		return err
+	}
+	defer s.removeSubscription(s.extractSubs, sub)
+
+	return s.serve(stream.Context(), sub, func(v interface{}) error {
+		return stream.Send(v.(*ExtractData))
+	})
+}
+
+//Rescan 重置扫描位置，从指定高度重新扫描
+func (s *Service) Rescan(ctx context.Context, req *RescanRequest) (*RescanReply, error) {
+	if err := s.client.Scanner().Rescan(ctx, req.Height); err != nil {
+		return nil, err
+	}
+	return &RescanReply{Height: req.Height}, nil
+}
+
+//AddWatchAddress 添加观测地址
+func (s *Service) AddWatchAddress(ctx context.Context, req *AddWatchAddressRequest) (*AddWatchAddressReply, error) {
+	opts := neo.WatchOptions{
+		Account: req.Account,
+		TTL:     time.Duration(req.TtlSeconds) * time.Second,
+	}
+	if err := s.client.Scanner().AddWatchAddress(ctx, req.Address, opts); err != nil {
+		return nil, err
+	}
+	return &AddWatchAddressReply{}, nil
+}
+
+//serve 把订阅的通知发送到流，直到客户端断开、缓冲溢出或服务关闭
+func (s *Service) serve(ctx context.Context, sub *subscription, send func(v interface{}) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.closed:
+			return ErrServiceClosed
+		case <-sub.overflow:
+			return ErrStreamOverflow
+		case v := <-sub.ch:
+			if err := send(v); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Service) addSubscription(subs map[*subscription]struct{}, sub *subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		return ErrServiceClosed
+	default:
+	}
+	subs[sub] = struct{}{}
+	return nil
+}
+
+func (s *Service) removeSubscription(subs map[*subscription]struct{}, sub *subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(subs, sub)
+}
+
+//toBlockHeader 转换为推送的区块头
+func toBlockHeader(header *openwallet.BlockHeader) *BlockHeader {
+	return &BlockHeader{
+		Hash:         header.Hash,
+		PreviousHash: header.Previousblockhash,
+		Height:       header.Height,
+		Time:         header.Time,
+		Fork:         header.Fork,
+		Symbol:       header.Symbol,
+	}
+}
+
+//serviceObserver 接收扫描器的通知并分发到订阅流
+type serviceObserver struct {
+	s *Service
+}
+
+func (o *serviceObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	msg := toBlockHeader(header)
+
+	o.s.mu.RLock()
+	defer o.s.mu.RUnlock()
+
+	for sub := range o.s.headerSubs {
+		sub.push(msg)
+	}
+	return nil
+}
+
+func (o *serviceObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	msg := &ExtractData{
+		SourceKey: sourceKey,
+		Json:      raw,
+	}
+	if data.Transaction != nil {
+		msg.Txid = data.Transaction.TxID
+		msg.BlockHeight = data.Transaction.BlockHeight
+	}
+
+	o.s.mu.RLock()
+	defer o.s.mu.RUnlock()
+
+	for sub := range o.s.extractSubs {
+		if sub.sourceKeys != nil && !sub.sourceKeys[sourceKey] {
+			continue
+		}
+		sub.push(msg)
+	}
+	return nil
+}
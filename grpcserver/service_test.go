@@ -0,0 +1,227 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neo"
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/openwallet"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func (s *Service) hasSubscribers() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.headerSubs)+len(s.extractSubs) > 0
+}
+
+//waitSubscribers 等待服务端的订阅建立或移除
+func waitSubscribers(t *testing.T, s *Service, want bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for s.hasSubscribers() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("hasSubscribers should be %v", want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+//newTestService 在bufconn监听上启动gRPC服务，返回服务和连接到它的客户端
+func newTestService(t *testing.T, bufferSize int) (*Service, ScanServiceClient, *neocoin.WalletManager, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		var result interface{}
+		switch body.Method {
+		case "getblockhash":
+			result = fmt.Sprintf("0x%064v", body.Params[0])
+		case "getblock":
+			height, _ := strconv.ParseUint(strings.TrimPrefix(body.Params[0].(string), "0x"), 10, 64)
+			result = map[string]interface{}{
+				"index":             height,
+				"hash":              body.Params[0],
+				"previousblockhash": fmt.Sprintf("0x%064d", height-1),
+				"time":              1000 + height*15,
+				"tx":                []interface{}{},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": "1", "result": result})
+	}))
+
+	wm := neocoin.NewWalletManager()
+	wm.WalletClient = neocoin.NewClient(server.URL, "", false)
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+
+	client, err := neo.NewClient(neo.Options{Manager: wm})
+	if err != nil {
+		t.Fatalf("NewClient failed unexpected error: %v", err)
+	}
+	s, err := NewService(client, Options{BufferSize: bufferSize})
+	if err != nil {
+		t.Fatalf("NewService failed unexpected error: %v", err)
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	grpcServer := NewServer(s)
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("Dial failed unexpected error: %v", err)
+	}
+
+	return s, NewScanServiceClient(conn), wm, func() {
+		conn.Close()
+		grpcServer.Stop()
+		s.Close()
+		server.Close()
+		os.RemoveAll(wm.Config.DBPath)
+	}
+}
+
+func TestService_StreamBlockHeaders(t *testing.T) {
+	s, client, wm, cleanup := newTestService(t, 10)
+	defer cleanup()
+
+	wm.SaveLocalNewBlock(3, fmt.Sprintf("0x%064d", 3))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.StreamBlockHeaders(ctx, &StreamBlockHeadersRequest{FromHeight: 2})
+	if err != nil {
+		t.Fatalf("StreamBlockHeaders failed unexpected error: %v", err)
+	}
+
+	//回放到已扫描高度
+	for _, height := range []uint64{2, 3} {
+		header, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed unexpected error: %v", err)
+		}
+		if header.Height != height || header.Time != 1000+height*15 || header.Symbol != neocoin.Symbol {
+			t.Errorf("unexpected replayed header: %+v", header)
+		}
+	}
+
+	//已回放的区块不重复推送，分叉通知和新区块照常推送
+	observer := &serviceObserver{s: s}
+	waitSubscribers(t, s, true)
+	observer.BlockScanNotify(&openwallet.BlockHeader{Height: 3, Hash: "a"})
+	observer.BlockScanNotify(&openwallet.BlockHeader{Height: 3, Hash: "b", Fork: true})
+	observer.BlockScanNotify(&openwallet.BlockHeader{Height: 4, Hash: "c"})
+	if header, err := stream.Recv(); err != nil || header.Hash != "b" || !header.Fork {
+		t.Errorf("unexpected fork header: %+v, %v", header, err)
+	}
+	if header, err := stream.Recv(); err != nil || header.Hash != "c" {
+		t.Errorf("unexpected new header: %+v, %v", header, err)
+	}
+
+	//客户端断开后结束流并取消订阅
+	cancel()
+	waitSubscribers(t, s, false)
+}
+
+func TestService_StreamExtractData(t *testing.T) {
+	s, client, _, cleanup := newTestService(t, 1)
+	defer cleanup()
+
+	stream, err := client.StreamExtractData(context.Background(), &StreamExtractDataRequest{SourceKeys: []string{"A"}})
+	if err != nil {
+		t.Fatalf("StreamExtractData failed unexpected error: %v", err)
+	}
+	waitSubscribers(t, s, true)
+
+	observer := &serviceObserver{s: s}
+	newData := func(txid string) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid, BlockHeight: 10}
+		return data
+	}
+
+	//只推送订阅的账户
+	observer.BlockExtractDataNotify("B", newData("tx1"))
+	observer.BlockExtractDataNotify("A", newData("tx2"))
+	data, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed unexpected error: %v", err)
+	}
+	if data.SourceKey != "A" || data.Txid != "tx2" || data.BlockHeight != 10 || !strings.Contains(string(data.Json), "tx2") {
+		t.Errorf("unexpected extract data: %+v", data)
+	}
+
+	//消费过慢时标记溢出，断开该流
+	sub := newSubscription(1, nil)
+	sub.push(&ExtractData{})
+	sub.push(&ExtractData{})
+	<-sub.ch
+	if err := s.serve(context.Background(), sub, nil); err != ErrStreamOverflow {
+		t.Errorf("stream should end with overflow, err: %v", err)
+	}
+
+	//关闭后结束已有的流，不接受新的订阅
+	s.Close()
+	if _, err := stream.Recv(); err == nil || !strings.Contains(err.Error(), ErrServiceClosed.Error()) {
+		t.Errorf("stream should end when service closed, err: %v", err)
+	}
+	stream, err = client.StreamExtractData(context.Background(), &StreamExtractDataRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err == nil || !strings.Contains(err.Error(), ErrServiceClosed.Error()) {
+		t.Errorf("closed service should reject stream, err: %v", err)
+	}
+}
+
+func TestService_ScanControl(t *testing.T) {
+	_, client, wm, cleanup := newTestService(t, 10)
+	defer cleanup()
+
+	address := "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+	_, err := client.AddWatchAddress(context.Background(), &AddWatchAddressRequest{Address: address, Account: "acc", TtlSeconds: 60})
+	if err != nil {
+		t.Errorf("AddWatchAddress failed unexpected error: %v", err)
+	}
+	if _, ok := wm.Blockscanner.WatchAddressExpireAt(address); !ok {
+		t.Errorf("watch address should be added with ttl")
+	}
+
+	if _, err := client.AddWatchAddress(context.Background(), &AddWatchAddressRequest{}); err == nil {
+		t.Errorf("empty address should fail")
+	}
+
+	reply, err := client.Rescan(context.Background(), &RescanRequest{Height: 5})
+	if err != nil || reply.Height != 5 {
+		t.Errorf("Rescan = %+v, %v", reply, err)
+	}
+}
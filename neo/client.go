@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+//neo 上下文感知的v2接口，基于neocoin.WalletManager实现，原有接口保持不变。
+//每个方法接收context.Context，上下文取消或超时时立即返回，错误统一包装为*Error
+//
+//	client, err := neo.NewClient(neo.Options{ConfigFiles: []string{"conf/NEO.ini"}})
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	height, err := client.BlockHeight(ctx)
+package neo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//Options 创建客户端的选项
+type Options struct {
+	ConfigFiles []string               //配置文件路径，后面的覆盖前面的
+	Manager     *neocoin.WalletManager //已创建的钱包管理者，非nil时不加载配置文件
+}
+
+//UnspentOptions 查询未花记录的选项
+type UnspentOptions struct {
+	Addresses        []string //查询的地址
+	MinConfirmations uint64   //最少确认数
+}
+
+//Client v2节点客户端
+type Client struct {
+	wm      *neocoin.WalletManager
+	scanner *Scanner
+}
+
+//NewClient 按选项创建客户端，与原有接口共用同一个钱包管理者
+func NewClient(opts Options) (*Client, error) {
+
+	wm := opts.Manager
+	if wm == nil {
+		if len(opts.ConfigFiles) == 0 {
+			return nil, wrapError("NewClient", errors.New("config files and manager are both empty"))
+		}
+		wm = neocoin.NewWalletManager()
+		if err := wm.LoadConfig(opts.ConfigFiles...); err != nil {
+			return nil, wrapError("NewClient", err)
+		}
+	}
+
+	c := &Client{wm: wm}
+	c.scanner = &Scanner{bs: wm.Blockscanner}
+	return c, nil
+}
+
+//Manager 返回底层的钱包管理者，用于调用v2接口未覆盖的功能
+func (c *Client) Manager() *neocoin.WalletManager {
+	return c.wm
+}
+
+//Scanner 返回区块扫描器
+func (c *Client) Scanner() *Scanner {
+	return c.scanner
+}
+
+//BlockHeight 获取节点的最新区块高度
+func (c *Client) BlockHeight(ctx context.Context) (uint64, error) {
+	var height uint64
+	err := call(ctx, "BlockHeight", func() (err error) {
+		height, err = c.wm.GetBlockHeight()
+		return
+	})
+	if err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+//BlockByHeight 获取指定高度的区块
+func (c *Client) BlockByHeight(ctx context.Context, height uint64) (*neocoin.Block, error) {
+	var block *neocoin.Block
+	err := call(ctx, "BlockByHeight", func() error {
+		hash, err := c.wm.GetBlockHash(height)
+		if err != nil {
+			return err
+		}
+		block, err = c.wm.GetBlock(hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+//Transaction 获取交易单
+func (c *Client) Transaction(ctx context.Context, txid string) (*neocoin.Transaction, error) {
+	var tx *neocoin.Transaction
+	err := call(ctx, "Transaction", func() (err error) {
+		tx, err = c.wm.GetTransaction(txid)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+//Unspents 查询地址的未花记录
+func (c *Client) Unspents(ctx context.Context, opts UnspentOptions) ([]*neocoin.UnspentBalance, error) {
+	if len(opts.Addresses) == 0 {
+		return nil, wrapError("Unspents", errors.New("addresses is empty"))
+	}
+	var unspents []*neocoin.UnspentBalance
+	err := call(ctx, "Unspents", func() (err error) {
+		unspents, err = c.wm.ListUnspent(opts.MinConfirmations, opts.Addresses...)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unspents, nil
+}
+
+//Balances 查询地址余额
+func (c *Client) Balances(ctx context.Context, addresses ...string) ([]*openwallet.Balance, error) {
+	var balances []*openwallet.Balance
+	err := call(ctx, "Balances", func() (err error) {
+		balances, err = c.wm.Blockscanner.GetBalanceByAddress(addresses...)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+//Broadcast 广播已签名的交易单，返回txid
+func (c *Client) Broadcast(ctx context.Context, rawHex string) (string, error) {
+	var txid string
+	err := call(ctx, "Broadcast", func() (err error) {
+		txid, err = c.wm.SendRawTransaction(rawHex)
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	return txid, nil
+}
+
+//EstimateFeeRate 估算手续费率
+func (c *Client) EstimateFeeRate(ctx context.Context) (decimal.Decimal, error) {
+	var rate decimal.Decimal
+	err := call(ctx, "EstimateFeeRate", func() (err error) {
+		rate, err = c.wm.EstimateFeeRate()
+		return
+	})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return rate, nil
+}
+
+//call 在独立的goroutine中执行底层调用，上下文取消或超时时立即返回，之后完成的底层调用结果被丢弃。
+//调用者只能在返回nil时读取fn写入的结果
+func call(ctx context.Context, op string, fn func() error) error {
+
+	if err := ctx.Err(); err != nil {
+		return wrapError(op, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return wrapError(op, err)
+	case <-ctx.Done():
+		return wrapError(op, ctx.Err())
+	}
+}
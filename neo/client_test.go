@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+)
+
+//newTestRPCServer 模拟节点的json-rpc服务
+func newTestRPCServer(handler func(method string, params []interface{}) interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      "1",
+			"result":  handler(body.Method, body.Params),
+		})
+	}))
+}
+
+func newTestClient(t *testing.T, server *httptest.Server) *Client {
+	wm := neocoin.NewWalletManager()
+	wm.WalletClient = neocoin.NewClient(server.URL, "", false)
+	client, err := NewClient(Options{Manager: wm})
+	if err != nil {
+		t.Fatalf("NewClient failed unexpected error: %v", err)
+	}
+	return client
+}
+
+func TestClient_BlockHeight(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 101
+		case "getblockhash":
+			return "0x01"
+		}
+		return nil
+	})
+	defer server.Close()
+
+	client := newTestClient(t, server)
+
+	//与原有接口返回相同
+	expected, _ := client.Manager().GetBlockHeight()
+	height, err := client.BlockHeight(context.Background())
+	if err != nil || height != expected || height == 0 {
+		t.Errorf("BlockHeight = %d, %v, expected: %d", height, err, expected)
+	}
+
+	//底层openwallet错误保留错误码
+	_, err = client.BlockByHeight(context.Background(), 1)
+	if ErrorCode(err) != neocoin.ErrRPCResponseInvalid || !strings.HasPrefix(err.Error(), "neo: BlockByHeight: ") {
+		t.Errorf("invalid block hash should be wrapped with code, err: %v", err)
+	}
+	if e, ok := err.(*Error); !ok || e.Unwrap() == nil || e.Op != "BlockByHeight" {
+		t.Errorf("unexpected error type: %#v", err)
+	}
+
+	if _, err := client.Unspents(context.Background(), UnspentOptions{}); err == nil {
+		t.Errorf("empty addresses should fail")
+	}
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		<-release
+		return 101
+	})
+	defer server.Close()
+	defer close(release)
+
+	client := newTestClient(t, server)
+
+	//超时立即返回，不等待节点响应
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.BlockHeight(ctx)
+	if !IsCanceled(err) || time.Since(start) > time.Second {
+		t.Errorf("BlockHeight should return on deadline, err: %v, elapsed: %v", err, time.Since(start))
+	}
+
+	//已取消的上下文不发起调用
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Broadcast(ctx, "80"); !IsCanceled(err) {
+		t.Errorf("Broadcast should fail with canceled context, err: %v", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	if _, err := NewClient(Options{}); err == nil {
+		t.Errorf("NewClient without config should fail")
+	}
+	if _, err := NewClient(Options{ConfigFiles: []string{"not-exist.ini"}}); err == nil {
+		t.Errorf("NewClient with missing config should fail")
+	}
+}
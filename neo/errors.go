@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//Error v2接口返回的错误，包装底层错误并保留操作名称和openwallet错误码
+type Error struct {
+	Op   string //出错的操作
+	Code uint64 //openwallet错误码，底层不是openwallet错误时为0
+	Err  error  //底层错误
+}
+
+func (e *Error) Error() string {
+	if e.Code > 0 {
+		return fmt.Sprintf("neo: %s: [%d] %v", e.Op, e.Code, e.Err)
+	}
+	return fmt.Sprintf("neo: %s: %v", e.Op, e.Err)
+}
+
+//Unwrap 返回底层错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//wrapError 包装底层错误，nil返回nil
+func wrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	wrapped := &Error{Op: op, Err: err}
+	if owErr, ok := err.(*openwallet.Error); ok {
+		wrapped.Code = owErr.Code()
+	}
+	return wrapped
+}
+
+//ErrorCode 获取错误的openwallet错误码，不是openwallet错误时返回0
+func ErrorCode(err error) uint64 {
+	switch e := err.(type) {
+	case *Error:
+		return e.Code
+	case *openwallet.Error:
+		return e.Code()
+	}
+	return 0
+}
+
+//IsCanceled 错误是否由上下文取消或超时引起
+func IsCanceled(err error) bool {
+	if e, ok := err.(*Error); ok {
+		err = e.Err
+	}
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
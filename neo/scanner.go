@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//ExtractOptions 提取交易单的选项
+type ExtractOptions struct {
+	Target openwallet.BlockScanTargetFunc //查找地址或公钥归属的账户
+}
+
+//SubscribeOptions 订阅扫描通知的选项
+type SubscribeOptions struct {
+	Filter *neocoin.ObserverFilter //按资产、地址或金额过滤通知，nil接收全部通知
+}
+
+//WatchOptions 添加观测地址的选项
+type WatchOptions struct {
+	Account string        //地址归属的账户，非空时提取不再调用ScanAddressFunc
+	TTL     time.Duration //有效期，不大于0表示不过期
+}
+
+//Scanner v2区块扫描器
+type Scanner struct {
+	bs *neocoin.NEOBlockScanner
+}
+
+//Run 启动扫描并阻塞，上下文取消后停止扫描并返回nil
+func (s *Scanner) Run(ctx context.Context) error {
+
+	if err := ctx.Err(); err != nil {
+		return wrapError("Run", err)
+	}
+
+	if err := s.bs.Run(); err != nil {
+		return wrapError("Run", err)
+	}
+
+	<-ctx.Done()
+
+	return wrapError("Run", s.bs.Stop())
+}
+
+//ScanBlock 扫描指定高度的区块
+func (s *Scanner) ScanBlock(ctx context.Context, height uint64) error {
+	return call(ctx, "ScanBlock", func() error {
+		return s.bs.ScanBlock(height)
+	})
+}
+
+//Rescan 重置扫描位置，从指定高度重新扫描
+func (s *Scanner) Rescan(ctx context.Context, height uint64) error {
+	return call(ctx, "Rescan", func() error {
+		return s.bs.SetRescanBlockHeight(height)
+	})
+}
+
+//AddWatchAddress 添加观测地址
+func (s *Scanner) AddWatchAddress(ctx context.Context, address string, opts WatchOptions) error {
+	if len(address) == 0 {
+		return wrapError("AddWatchAddress", errors.New("address is empty"))
+	}
+	return call(ctx, "AddWatchAddress", func() error {
+		s.bs.AddWatchAddressWithTTL(address, opts.Account, opts.TTL)
+		return nil
+	})
+}
+
+//ScannedHeader 获取已扫描的最新区块头
+func (s *Scanner) ScannedHeader(ctx context.Context) (*openwallet.BlockHeader, error) {
+	var header *openwallet.BlockHeader
+	err := call(ctx, "ScannedHeader", func() (err error) {
+		header, err = s.bs.GetScannedBlockHeader()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+//ExtractTransaction 提取交易单中与目标账户相关的数据，按账户分组返回
+func (s *Scanner) ExtractTransaction(ctx context.Context, txid string, opts ExtractOptions) (map[string][]*openwallet.TxExtractData, error) {
+	if opts.Target == nil {
+		return nil, wrapError("ExtractTransaction", errors.New("scan target func is empty"))
+	}
+	var result map[string][]*openwallet.TxExtractData
+	err := call(ctx, "ExtractTransaction", func() (err error) {
+		result, err = s.bs.ExtractTransactionData(txid, opts.Target)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//Subscribe 订阅扫描通知，返回取消订阅的函数
+func (s *Scanner) Subscribe(observer openwallet.BlockScanNotificationObject, opts SubscribeOptions) (func(), error) {
+	if observer == nil {
+		return nil, wrapError("Subscribe", errors.New("observer is nil"))
+	}
+	if err := s.bs.AddObserverWithFilter(observer, opts.Filter); err != nil {
+		return nil, wrapError("Subscribe", err)
+	}
+	return func() {
+		s.bs.RemoveObserver(observer)
+	}, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testObserver struct{}
+
+func (o *testObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+func (o *testObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	return nil
+}
+
+func TestScanner_Subscribe(t *testing.T) {
+	client, err := NewClient(Options{Manager: neocoin.NewWalletManager()})
+	if err != nil {
+		t.Fatalf("NewClient failed unexpected error: %v", err)
+	}
+	scanner := client.Scanner()
+	bs := client.Manager().Blockscanner
+
+	observer := &testObserver{}
+	filter := &neocoin.ObserverFilter{ContractOnly: true}
+	unsubscribe, err := scanner.Subscribe(observer, SubscribeOptions{Filter: filter})
+	if err != nil {
+		t.Fatalf("Subscribe failed unexpected error: %v", err)
+	}
+	if bs.ObserverFilter(observer) != filter {
+		t.Errorf("observer filter should be registered")
+	}
+
+	unsubscribe()
+	if bs.ObserverFilter(observer) != nil {
+		t.Errorf("observer filter should be removed after unsubscribe")
+	}
+
+	if _, err := scanner.Subscribe(nil, SubscribeOptions{}); err == nil {
+		t.Errorf("nil observer should fail")
+	}
+	if _, err := scanner.ExtractTransaction(context.Background(), "0x01", ExtractOptions{}); err == nil {
+		t.Errorf("empty scan target should fail")
+	}
+
+	//已取消的上下文不启动扫描
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := scanner.Run(ctx); !IsCanceled(err) {
+		t.Errorf("Run should fail with canceled context, err: %v", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"math/big"
 
-	"github.com/blocktree/go-owcrypt"
 )
 
 type SignaturePubkey struct {
@@ -36,18 +35,18 @@ func calcSignaturePubkey(txHash, prikey []byte) (*SignaturePubkey, error) {
 		return nil, errors.New("Transaction hash or private key data error!")
 	}
 
-	txHash = owcrypt.Hash(txHash, 0, owcrypt.HASH_ALG_SHA256)
-	sig, err := owcrypt.Signature(prikey, nil, 0, txHash, 32, owcrypt.ECC_CURVE_SECP256R1)
-	if err != owcrypt.SUCCESS {
-		return nil, errors.New("Signature failed!")
+	txHash = cryptoProvider.SHA256(txHash)
+	sig, err := cryptoProvider.Sign(prikey, txHash)
+	if err != nil {
+		return nil, err
 	}
 	sig = serilizeS(sig)
 
-	pub, err := owcrypt.GenPubkey(prikey, owcrypt.ECC_CURVE_SECP256R1)
-	if err != owcrypt.SUCCESS {
-		return nil, errors.New("Get Pubkey failed!")
+	pub, err := cryptoProvider.PublicKey(prikey)
+	if err != nil {
+		return nil, err
 	}
-	pub = owcrypt.PointCompress(pub, owcrypt.ECC_CURVE_SECP256R1)
+	pub = cryptoProvider.CompressPubkey(pub)
 
 	return &SignaturePubkey{sig, pub}, nil
 }
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/blocktree/go-owcrypt"
 )
 
 // Errors
@@ -155,7 +154,7 @@ func DecodeCheck(address string) ([]byte, []byte, error) {
 	if err != nil {
 		return nil, nil, errors.New("Invalid address!")
 	}
-	checksum := owcrypt.Hash(ret[:len(ret)-4], 0, owcrypt.HASh_ALG_DOUBLE_SHA256)[:4]
+	checksum := cryptoProvider.DoubleSHA256(ret[:len(ret)-4])[:4]
 	for i := 0; i < 4; i++ {
 		if checksum[i] != ret[len(ret)-4+i] {
 			return nil, nil, errors.New("Invalid address!")
@@ -168,7 +167,7 @@ func DecodeCheck(address string) ([]byte, []byte, error) {
 
 func EncodeCheck(prefix []byte, hash []byte) string {
 	data := append(prefix, hash...)
-	checksum := owcrypt.Hash(data, 0, owcrypt.HASh_ALG_DOUBLE_SHA256)[:4]
+	checksum := cryptoProvider.DoubleSHA256(data)[:4]
 	data = append(data, checksum...)
 	return Encode(data, NeocoinAlphabet)
 }
@@ -0,0 +1,80 @@
+// conformance 交易序列化兼容性用例，随包版本维护
+// 下游可在自己的测试中调用Verify，确认所用版本与NEO参考实现字节兼容
+package conformance
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+// 校验单个用例
+func Check(v Vector) error {
+	if len(v.UnsignedHex) > 0 {
+		unsigned, err := neoTransaction.CreateEmptyRawTransaction(v.Type, v.Vins, v.Vouts, nil)
+		if err != nil {
+			return fmt.Errorf("[%s] create transaction failed: %v", v.Name, err)
+		}
+		if unsigned != v.UnsignedHex {
+			return fmt.Errorf("[%s] unsigned hex mismatch, got: %s, expected: %s", v.Name, unsigned, v.UnsignedHex)
+		}
+		if err := checkDecode(v.Name, v.UnsignedHex, v.TxID); err != nil {
+			return err
+		}
+	}
+
+	if len(v.SignedHex) > 0 {
+		if err := checkDecode(v.Name, v.SignedHex, v.TxID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 校验反序列化后重新序列化结果一致，且txid正确
+func checkDecode(name, rawHex, txid string) error {
+	txBytes, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return fmt.Errorf("[%s] invalid hex: %v", name, err)
+	}
+
+	tx, err := neoTransaction.DecodeRawTransaction(txBytes)
+	if err != nil {
+		return fmt.Errorf("[%s] decode transaction failed: %v", name, err)
+	}
+
+	encoded, err := tx.EncodeToBytes()
+	if err != nil {
+		return fmt.Errorf("[%s] encode transaction failed: %v", name, err)
+	}
+	if hex.EncodeToString(encoded) != rawHex {
+		return fmt.Errorf("[%s] re-encoded hex mismatch, got: %x", name, encoded)
+	}
+
+	hash, err := tx.GetHash()
+	if err != nil {
+		return fmt.Errorf("[%s] calculate txid failed: %v", name, err)
+	}
+	if hash != txid {
+		return fmt.Errorf("[%s] txid mismatch, got: %s, expected: %s", name, hash, txid)
+	}
+
+	return nil
+}
+
+// 校验全部用例，返回所有不兼容的用例
+func Verify() error {
+	errs := make([]string, 0)
+	for _, v := range Vectors {
+		if err := Check(v); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("conformance suite v%d failed:\n%s", SuiteVersion, strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package conformance
+
+import (
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	if err := Verify(); err != nil {
+		t.Error(err)
+	}
+}
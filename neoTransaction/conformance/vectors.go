@@ -0,0 +1,56 @@
+package conformance
+
+import (
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+// 兼容性用例版本，增加或修改用例时递增
+const SuiteVersion = 1
+
+// 交易序列化兼容性用例
+// 期望值由独立的参考实现按NEO 2.x交易格式计算：
+// prevhash和assetid为小端字节序，金额为Fixed8小端int64，txid为未签名数据double SHA256的反序
+type Vector struct {
+	Name        string
+	Type        neoTransaction.TransactionType
+	Vins        []neoTransaction.Vin
+	Vouts       []neoTransaction.Vout
+	UnsignedHex string // 未签名交易序列化结果，为空表示只校验SignedHex
+	SignedHex   string // 已签名交易，用于校验反序列化后txid与重新序列化结果
+	TxID        string
+}
+
+// 兼容性用例
+var Vectors = []Vector{
+	{
+		Name: "contract_single_input_neo",
+		Type: neoTransaction.ContractTransaction,
+		Vins: []neoTransaction.Vin{
+			{TxID: "3e7146b4f1841a591d5989d6fc01d7ae3631136178d932de36ad0ebe63ba8113", Vout: 1},
+		},
+		Vouts: []neoTransaction.Vout{
+			{Asset: neoTransaction.NeoAssetId, Address: "ANYZ11AmUfwiZFLbAWHoExFyBuqgLmfz88", Value: 10000000000},
+		},
+		UnsignedHex: "800000011381ba63be0ead36de32d97861133136aed701fcd689591d591a84f1b446713e0100019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e40b54020000004a43e85f3e0137a23998cdc6dbacfac0268bf038",
+		TxID:        "0x6ef64f1bb1a4e3baff10492df3b231284ae242f7d06ab4489d65e49cc9d4d22b",
+	},
+	{
+		Name: "contract_multi_input_neo_gas",
+		Type: neoTransaction.ContractTransaction,
+		Vins: []neoTransaction.Vin{
+			{TxID: "3e7146b4f1841a591d5989d6fc01d7ae3631136178d932de36ad0ebe63ba8113", Vout: 1},
+			{TxID: "7b84a50bbc5d8480361a868efaddaef4411f6ceebf9d84e393644f074c289d0f", Vout: 0},
+		},
+		Vouts: []neoTransaction.Vout{
+			{Asset: neoTransaction.NeoAssetId, Address: "ANYZ11AmUfwiZFLbAWHoExFyBuqgLmfz88", Value: 6500000000},
+			{Asset: neoTransaction.NeoGasAssetId, Address: "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC", Value: 12345678},
+		},
+		UnsignedHex: "800000021381ba63be0ead36de32d97861133136aed701fcd689591d591a84f1b446713e01000f9d284c074f6493e3849dbfee6c1f41f4aeddfa8e861a3680845dbc0ba5847b0000029b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500216e83010000004a43e85f3e0137a23998cdc6dbacfac0268bf038e72d286979ee6cb1b7e65dfddfb2e384100b8d148e7758de42e4168b71792c604e61bc0000000000accc9eba9934271301effd425f88d4d0e1d1ac6e",
+		TxID:        "0xc541e35276a2f4d0c26e8dc5eee654529cdbf35d8260a518ed32cfca8e08b304",
+	},
+	{
+		Name:      "contract_signed_single_witness",
+		SignedHex: "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac",
+		TxID:      "0x4a52b4f9496629d66b382de94133285091fafc426cb5a6ec196883a8edd9bb94",
+	},
+}
@@ -0,0 +1,75 @@
+//go:build !purego
+// +build !purego
+
+package neoTransaction
+
+import (
+	"errors"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+//OwcryptProvider 基于owcrypt的算法实现
+type OwcryptProvider struct{}
+
+func defaultCryptoProvider() CryptoProvider {
+	return OwcryptProvider{}
+}
+
+//Name 实现名称
+func (OwcryptProvider) Name() string {
+	return "owcrypt"
+}
+
+//SHA256 单次SHA256
+func (OwcryptProvider) SHA256(data []byte) []byte {
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_SHA256)
+}
+
+//DoubleSHA256 两次SHA256
+func (OwcryptProvider) DoubleSHA256(data []byte) []byte {
+	return owcrypt.Hash(data, 0, owcrypt.HASh_ALG_DOUBLE_SHA256)
+}
+
+//RIPEMD160 单次RIPEMD160
+func (OwcryptProvider) RIPEMD160(data []byte) []byte {
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_RIPEMD160)
+}
+
+//Hash160 SHA256后再RIPEMD160
+func (OwcryptProvider) Hash160(data []byte) []byte {
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_HASH160)
+}
+
+//PublicKey 私钥对应的64字节非压缩公钥x||y
+func (OwcryptProvider) PublicKey(prikey []byte) ([]byte, error) {
+	pub, ret := owcrypt.GenPubkey(prikey, owcrypt.ECC_CURVE_SECP256R1)
+	if ret != owcrypt.SUCCESS {
+		return nil, errors.New("Get Pubkey failed!")
+	}
+	return pub, nil
+}
+
+//Sign 对32字节摘要签名，返回64字节r||s
+func (OwcryptProvider) Sign(prikey, digest []byte) ([]byte, error) {
+	sig, ret := owcrypt.Signature(prikey, nil, 0, digest, 32, owcrypt.ECC_CURVE_SECP256R1)
+	if ret != owcrypt.SUCCESS {
+		return nil, errors.New("Signature failed!")
+	}
+	return sig, nil
+}
+
+//Verify 使用64字节非压缩公钥x||y校验摘要的64字节r||s签名
+func (OwcryptProvider) Verify(pubkey, digest, signature []byte) bool {
+	return owcrypt.Verify(pubkey, nil, 0, digest, 32, signature, owcrypt.ECC_CURVE_SECP256R1) == owcrypt.SUCCESS
+}
+
+//CompressPubkey 64字节x||y或65字节非压缩公钥转为33字节压缩公钥
+func (OwcryptProvider) CompressPubkey(pubkey []byte) []byte {
+	return owcrypt.PointCompress(pubkey, owcrypt.ECC_CURVE_SECP256R1)
+}
+
+//DecompressPubkey 33字节压缩公钥转为65字节非压缩公钥
+func (OwcryptProvider) DecompressPubkey(pubkey []byte) []byte {
+	return owcrypt.PointDecompress(pubkey, owcrypt.ECC_CURVE_SECP256R1)
+}
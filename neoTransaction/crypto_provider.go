@@ -0,0 +1,54 @@
+package neoTransaction
+
+import "errors"
+
+//CryptoProvider 交易使用的哈希和secp256r1签名算法实现
+//默认使用owcrypt，使用purego编译标签时默认使用纯Go实现，便于在不方便编译owcrypt的平台构建
+type CryptoProvider interface {
+	//Name 实现名称
+	Name() string
+	//SHA256 单次SHA256
+	SHA256(data []byte) []byte
+	//DoubleSHA256 两次SHA256
+	DoubleSHA256(data []byte) []byte
+	//RIPEMD160 单次RIPEMD160
+	RIPEMD160(data []byte) []byte
+	//Hash160 SHA256后再RIPEMD160，即脚本hash
+	Hash160(data []byte) []byte
+	//PublicKey 私钥对应的64字节非压缩公钥x||y
+	PublicKey(prikey []byte) ([]byte, error)
+	//Sign 对32字节摘要签名，返回64字节r||s
+	Sign(prikey, digest []byte) ([]byte, error)
+	//Verify 使用64字节非压缩公钥x||y校验摘要的64字节r||s签名
+	Verify(pubkey, digest, signature []byte) bool
+	//CompressPubkey 64字节x||y或65字节非压缩公钥转为33字节压缩公钥，失败返回nil
+	CompressPubkey(pubkey []byte) []byte
+	//DecompressPubkey 33字节压缩公钥转为65字节非压缩公钥，失败返回nil
+	DecompressPubkey(pubkey []byte) []byte
+}
+
+//cryptoProvider 当前使用的算法实现
+var cryptoProvider = defaultCryptoProvider()
+
+//SetCryptoProvider 替换算法实现，应在创建和签名交易前设置
+func SetCryptoProvider(provider CryptoProvider) error {
+	if provider == nil {
+		return errors.New("Crypto provider is nil!")
+	}
+	cryptoProvider = provider
+	return nil
+}
+
+//GetCryptoProvider 当前使用的算法实现
+func GetCryptoProvider() CryptoProvider {
+	return cryptoProvider
+}
+
+//verifyCompressed 使用压缩公钥校验摘要签名
+func verifyCompressed(pubkey, digest, signature []byte) bool {
+	point := cryptoProvider.DecompressPubkey(pubkey)
+	if len(point) != 65 {
+		return false
+	}
+	return cryptoProvider.Verify(point[1:], digest, signature)
+}
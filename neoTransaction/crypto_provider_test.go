@@ -0,0 +1,106 @@
+package neoTransaction
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+//纯Go实现与owcrypt结果一致，签名可以互相校验
+func TestPureGoProvider(t *testing.T) {
+	p := PureGoProvider{}
+	data := []byte("neo-adapter")
+
+	if !bytes.Equal(p.SHA256(data), owcrypt.Hash(data, 0, owcrypt.HASH_ALG_SHA256)) {
+		t.Errorf("SHA256 mismatch")
+	}
+	if !bytes.Equal(p.DoubleSHA256(data), owcrypt.Hash(data, 0, owcrypt.HASh_ALG_DOUBLE_SHA256)) {
+		t.Errorf("DoubleSHA256 mismatch")
+	}
+	if !bytes.Equal(p.RIPEMD160(data), owcrypt.Hash(data, 0, owcrypt.HASH_ALG_RIPEMD160)) {
+		t.Errorf("RIPEMD160 mismatch")
+	}
+	if !bytes.Equal(p.Hash160(data), owcrypt.Hash(data, 0, owcrypt.HASH_ALG_HASH160)) {
+		t.Errorf("Hash160 mismatch")
+	}
+
+	prikey, _ := hex.DecodeString("55c87b7b8f435364250b271d979bfd3f83ebbc9950598a7b52b11ed7b117f89c")
+	pub, err := p.PublicKey(prikey)
+	if err != nil {
+		t.Errorf("PublicKey failed unexpected error: %v\n", err)
+		return
+	}
+	owPub, _ := owcrypt.GenPubkey(prikey, owcrypt.ECC_CURVE_SECP256R1)
+	if !bytes.Equal(pub, owPub) {
+		t.Errorf("PublicKey mismatch")
+	}
+
+	compressed := p.CompressPubkey(pub)
+	if !bytes.Equal(compressed, owcrypt.PointCompress(owPub, owcrypt.ECC_CURVE_SECP256R1)) {
+		t.Errorf("CompressPubkey mismatch")
+	}
+	if !bytes.Equal(p.DecompressPubkey(compressed), owcrypt.PointDecompress(compressed, owcrypt.ECC_CURVE_SECP256R1)) {
+		t.Errorf("DecompressPubkey mismatch")
+	}
+
+	digest := p.SHA256(data)
+	sig, err := p.Sign(prikey, digest)
+	if err != nil {
+		t.Errorf("Sign failed unexpected error: %v\n", err)
+		return
+	}
+	if owcrypt.Verify(pub, nil, 0, digest, 32, sig, owcrypt.ECC_CURVE_SECP256R1) != owcrypt.SUCCESS {
+		t.Errorf("owcrypt should verify pure go signature")
+	}
+
+	owSig, _ := owcrypt.Signature(prikey, nil, 0, digest, 32, owcrypt.ECC_CURVE_SECP256R1)
+	if !p.Verify(pub, digest, owSig) {
+		t.Errorf("pure go should verify owcrypt signature")
+	}
+	if p.Verify(pub, p.SHA256(digest), owSig) {
+		t.Errorf("signature of other digest should not verify")
+	}
+}
+
+func TestSetCryptoProvider(t *testing.T) {
+	old := GetCryptoProvider()
+	defer SetCryptoProvider(old)
+
+	if err := SetCryptoProvider(nil); err == nil {
+		t.Errorf("nil provider should be rejected")
+	}
+
+	if err := SetCryptoProvider(PureGoProvider{}); err != nil || GetCryptoProvider().Name() != "purego" {
+		t.Errorf("SetCryptoProvider failed unexpected error: %v\n", err)
+		return
+	}
+
+	prikey, _ := hex.DecodeString("55c87b7b8f435364250b271d979bfd3f83ebbc9950598a7b52b11ed7b117f89c")
+	rawTx := "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf405"
+
+	sp, err := SignRawTransaction(rawTx, prikey)
+	if err != nil {
+		t.Errorf("SignRawTransaction failed unexpected error: %v\n", err)
+		return
+	}
+
+	raw, _ := hex.DecodeString(rawTx)
+	digest := owcrypt.Hash(raw, 0, owcrypt.HASH_ALG_SHA256)
+	if !VerifySignature(digest, sp.Pubkey, sp.Signature) {
+		t.Errorf("pure go signature verify failed")
+	}
+
+	_, address, err := CreateSignatureRedeemScript(sp.Pubkey)
+	if err != nil {
+		t.Errorf("CreateSignatureRedeemScript failed unexpected error: %v\n", err)
+		return
+	}
+
+	SetCryptoProvider(old)
+	_, expected, _ := CreateSignatureRedeemScript(sp.Pubkey)
+	if address != expected {
+		t.Errorf("address mismatch: %s, expected: %s", address, expected)
+	}
+}
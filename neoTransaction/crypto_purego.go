@@ -0,0 +1,132 @@
+package neoTransaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+//PureGoProvider 基于Go标准库crypto/sha256、crypto/ecdsa的算法实现，不依赖cgo
+type PureGoProvider struct{}
+
+//Name 实现名称
+func (PureGoProvider) Name() string {
+	return "purego"
+}
+
+//SHA256 单次SHA256
+func (PureGoProvider) SHA256(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+//DoubleSHA256 两次SHA256
+func (PureGoProvider) DoubleSHA256(data []byte) []byte {
+	h := sha256.Sum256(data)
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
+
+//RIPEMD160 单次RIPEMD160
+func (PureGoProvider) RIPEMD160(data []byte) []byte {
+	h := ripemd160.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+//Hash160 SHA256后再RIPEMD160
+func (p PureGoProvider) Hash160(data []byte) []byte {
+	return p.RIPEMD160(p.SHA256(data))
+}
+
+//PublicKey 私钥对应的64字节非压缩公钥x||y
+func (PureGoProvider) PublicKey(prikey []byte) ([]byte, error) {
+	d := new(big.Int).SetBytes(prikey)
+	if len(prikey) != 32 || d.Sign() <= 0 || d.Cmp(p256Order) >= 0 {
+		return nil, errors.New("Get Pubkey failed!")
+	}
+	x, y := elliptic.P256().ScalarBaseMult(prikey)
+	return append(int2octets(x), int2octets(y)...), nil
+}
+
+//Sign 对32字节摘要签名，返回64字节r||s
+func (PureGoProvider) Sign(prikey, digest []byte) ([]byte, error) {
+	d := new(big.Int).SetBytes(prikey)
+	if len(prikey) != 32 || d.Sign() <= 0 || d.Cmp(p256Order) >= 0 || len(digest) != 32 {
+		return nil, errors.New("Signature failed!")
+	}
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(prikey)
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, errors.New("Signature failed!")
+	}
+	return append(int2octets(r), int2octets(s)...), nil
+}
+
+//Verify 使用64字节非压缩公钥x||y校验摘要的64字节r||s签名
+func (PureGoProvider) Verify(pubkey, digest, signature []byte) bool {
+	if len(pubkey) != 64 || len(signature) != 64 {
+		return false
+	}
+	curve := elliptic.P256()
+	x := new(big.Int).SetBytes(pubkey[:32])
+	y := new(big.Int).SetBytes(pubkey[32:])
+	if !curve.IsOnCurve(x, y) {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, digest, r, s)
+}
+
+//CompressPubkey 64字节x||y或65字节非压缩公钥转为33字节压缩公钥
+func (PureGoProvider) CompressPubkey(pubkey []byte) []byte {
+	if len(pubkey) == 65 && pubkey[0] == 0x04 {
+		pubkey = pubkey[1:]
+	}
+	if len(pubkey) != 64 {
+		return nil
+	}
+	prefix := byte(0x02)
+	if pubkey[63]&0x01 == 0x01 {
+		prefix = 0x03
+	}
+	return append([]byte{prefix}, pubkey[:32]...)
+}
+
+//DecompressPubkey 33字节压缩公钥转为65字节非压缩公钥
+func (PureGoProvider) DecompressPubkey(pubkey []byte) []byte {
+	if len(pubkey) != 33 || (pubkey[0] != 0x02 && pubkey[0] != 0x03) {
+		return nil
+	}
+
+	//y² = x³ - 3x + b (mod p)
+	params := elliptic.P256().Params()
+	x := new(big.Int).SetBytes(pubkey[1:])
+	if x.Cmp(params.P) >= 0 {
+		return nil
+	}
+	y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	y2.Sub(y2, new(big.Int).Mul(x, big.NewInt(3)))
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+
+	y := new(big.Int).ModSqrt(y2, params.P)
+	if y == nil {
+		return nil
+	}
+	if y.Bit(0) != uint(pubkey[0]&0x01) {
+		y.Sub(params.P, y)
+	}
+
+	return append(append([]byte{0x04}, int2octets(x)...), int2octets(y)...)
+}
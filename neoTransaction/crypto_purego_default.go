@@ -0,0 +1,8 @@
+//go:build purego
+// +build purego
+
+package neoTransaction
+
+func defaultCryptoProvider() CryptoProvider {
+	return PureGoProvider{}
+}
@@ -0,0 +1,56 @@
+package neoTransaction
+
+import (
+	"encoding/hex"
+	"errors"
+
+)
+
+// NEO 2.x 交易哈希方案：
+// 签名摘要 = SHA256(未签名交易序列化数据)，对摘要做secp256r1 ECDSA签名，即ECDSA-SHA256
+// 交易ID = 反序(SHA256(SHA256(未签名交易序列化数据)))，见证人脚本不参与计算
+
+// 获取交易签名摘要，已签名交易会先去掉见证人
+// rawTx : 交易hex
+func HashForSigning(rawTx string) ([]byte, error) {
+	unsigned, err := unsignedBytes(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	return cryptoProvider.SHA256(unsigned), nil
+}
+
+// 计算交易ID，已签名交易会先去掉见证人
+// rawTx : 交易hex
+func CalcTxID(rawTx string) (string, error) {
+	unsigned, err := unsignedBytes(rawTx)
+	if err != nil {
+		return "", err
+	}
+	hash := cryptoProvider.DoubleSHA256(unsigned)
+	return "0x" + reverseBytesToHex(hash), nil
+}
+
+// 去掉见证人的交易hex，多个签名者分别签名时，签名数据不受已加入的见证人影响
+// rawTx : 交易hex
+func UnsignedRawTransaction(rawTx string) (string, error) {
+	unsigned, err := unsignedBytes(rawTx)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(unsigned), nil
+}
+
+// 交易未签名部分的序列化数据
+func unsignedBytes(rawTx string) ([]byte, error) {
+	txBytes, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, errors.New("Invalid transaction hex data!")
+	}
+	tx, err := DecodeRawTransaction(txBytes)
+	if err != nil {
+		return nil, err
+	}
+	tx.Scripts = nil
+	return tx.encodeToBytes()
+}
@@ -0,0 +1,37 @@
+package neoTransaction
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+func TestHashForSigning(t *testing.T) {
+	signed := "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac"
+	unsigned := signed[:2*(3+1+34+1+60)]
+
+	txid, err := CalcTxID(signed)
+	if err != nil || txid != "0x4a52b4f9496629d66b382de94133285091fafc426cb5a6ec196883a8edd9bb94" {
+		t.Errorf("txid: %s, unexpected error: %v", txid, err)
+	}
+
+	//签名交易与未签名交易的签名摘要一致，且与交易内的签名匹配
+	digest, err := HashForSigning(signed)
+	if err != nil {
+		t.Errorf("HashForSigning failed unexpected error: %v\n", err)
+		return
+	}
+	unsignedDigest, _ := HashForSigning(unsigned)
+	if hex.EncodeToString(digest) != hex.EncodeToString(unsignedDigest) {
+		t.Errorf("signing digest should ignore witnesses")
+	}
+
+	raw, _ := hex.DecodeString(signed)
+	sig := raw[len(raw)-100 : len(raw)-36]
+	pub := raw[len(raw)-34 : len(raw)-1]
+	pubkey := owcrypt.PointDecompress(pub, owcrypt.ECC_CURVE_SECP256R1)[1:]
+	if owcrypt.Verify(pubkey, nil, 0, digest, 32, sig, owcrypt.ECC_CURVE_SECP256R1) != owcrypt.SUCCESS {
+		t.Errorf("witness signature should verify against signing digest")
+	}
+}
@@ -0,0 +1,132 @@
+package neoTransaction
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+)
+
+// NEO 2.x 区块默克尔树：
+// 叶子为交易哈希(内部字节序)，父节点 = SHA256(SHA256(左节点||右节点))，某层节点数为奇数时最后一个节点与自身配对
+// 对外的交易ID和默克尔根为反序hex，带0x前缀
+
+// 计算交易列表的默克尔根
+// txids : 区块内按顺序排列的交易ID
+func MerkleRoot(txids []string) (string, error) {
+	level, err := merkleLeaves(txids)
+	if err != nil {
+		return "", err
+	}
+	for len(level) > 1 {
+		level = merkleParents(level)
+	}
+	return merkleHashHex(level[0]), nil
+}
+
+// 获取交易的默克尔路径，即从叶子到根每一层的兄弟节点
+// txids : 区块内按顺序排列的交易ID
+// index : 交易在区块中的位置
+func MerklePath(txids []string, index int) ([]string, error) {
+	level, err := merkleLeaves(txids)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(level) {
+		return nil, errors.New("Invalid merkle leaf index!")
+	}
+
+	path := make([]string, 0)
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		path = append(path, merkleHashHex(level[sibling]))
+		level = merkleParents(level)
+		index = index / 2
+	}
+	return path, nil
+}
+
+// 校验默克尔路径是否能由交易ID推导出默克尔根
+// txid  : 交易ID
+// index : 交易在区块中的位置
+// path  : MerklePath返回的兄弟节点
+// root  : 区块头中的默克尔根
+func VerifyMerklePath(txid string, index int, path []string, root string) (bool, error) {
+	node, err := merkleHashBytes(txid)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range path {
+		sibling, err := merkleHashBytes(s)
+		if err != nil {
+			return false, err
+		}
+		if index%2 == 0 {
+			node = merkleParent(node, sibling)
+		} else {
+			node = merkleParent(sibling, node)
+		}
+		index = index / 2
+	}
+	expected, err := merkleHashBytes(root)
+	if err != nil {
+		return false, err
+	}
+	return byteArrayCompare(node, expected), nil
+}
+
+// 交易ID转为内部字节序的叶子节点
+func merkleLeaves(txids []string) ([][]byte, error) {
+	if len(txids) == 0 {
+		return nil, errors.New("Empty merkle leaves!")
+	}
+	leaves := make([][]byte, 0, len(txids))
+	for _, txid := range txids {
+		leaf, err := merkleHashBytes(txid)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, leaf)
+	}
+	return leaves, nil
+}
+
+// 反序hex转为内部字节序的32字节哈希
+func merkleHashBytes(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != 32 {
+		return nil, errors.New("Invalid merkle hash!")
+	}
+	return reverseBytes(b), nil
+}
+
+// 内部字节序的哈希转为反序hex，不修改原节点
+func merkleHashHex(b []byte) string {
+	display := make([]byte, len(b))
+	copy(display, b)
+	return "0x" + reverseBytesToHex(display)
+}
+
+// 计算上一层节点
+func merkleParents(level [][]byte) [][]byte {
+	parents := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		right := level[i]
+		if i+1 < len(level) {
+			right = level[i+1]
+		}
+		parents = append(parents, merkleParent(level[i], right))
+	}
+	return parents
+}
+
+// 父节点 = SHA256(SHA256(左节点||右节点))
+func merkleParent(left, right []byte) []byte {
+	data := make([]byte, 0, len(left)+len(right))
+	data = append(data, left...)
+	data = append(data, right...)
+	return cryptoProvider.DoubleSHA256(data)
+}
@@ -0,0 +1,82 @@
+package neoTransaction
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+func testMerkleTxIDs(n int) []string {
+	txids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		hash := owcrypt.Hash([]byte(fmt.Sprintf("tx%d", i)), 0, owcrypt.HASH_ALG_SHA256)
+		txids = append(txids, "0x"+hex.EncodeToString(hash))
+	}
+	return txids
+}
+
+func TestMerkleRoot(t *testing.T) {
+	//只有一笔交易时，默克尔根即交易ID
+	txids := testMerkleTxIDs(1)
+	root, err := MerkleRoot(txids)
+	if err != nil || root != txids[0] {
+		t.Errorf("single tx merkle root: %s, unexpected error: %v", root, err)
+	}
+
+	//两笔交易时，根 = 反序(DoubleSHA256(反序(tx0)||反序(tx1)))
+	txids = testMerkleTxIDs(2)
+	left, _ := hex.DecodeString(txids[0][2:])
+	right, _ := hex.DecodeString(txids[1][2:])
+	data := append(reverseBytes(left), reverseBytes(right)...)
+	expected := "0x" + reverseBytesToHex(owcrypt.Hash(data, 0, owcrypt.HASh_ALG_DOUBLE_SHA256))
+	root, err = MerkleRoot(txids)
+	if err != nil || root != expected {
+		t.Errorf("merkle root: %s, expected: %s, unexpected error: %v", root, expected, err)
+	}
+
+	if _, err := MerkleRoot(nil); err == nil {
+		t.Errorf("empty leaves should fail")
+	}
+	if _, err := MerkleRoot([]string{"0x01"}); err == nil {
+		t.Errorf("invalid hash should fail")
+	}
+}
+
+func TestMerklePath(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8} {
+		txids := testMerkleTxIDs(n)
+		root, _ := MerkleRoot(txids)
+
+		for i := range txids {
+			path, err := MerklePath(txids, i)
+			if err != nil {
+				t.Errorf("MerklePath failed unexpected error: %v\n", err)
+				return
+			}
+
+			ok, err := VerifyMerklePath(txids[i], i, path, root)
+			if err != nil || !ok {
+				t.Errorf("leaves: %d, index: %d merkle path should be valid, unexpected error: %v", n, i, err)
+			}
+
+			//位置错误时校验失败
+			if n > 1 {
+				if ok, _ := VerifyMerklePath(txids[i], i^1, path, root); ok && i^1 < n {
+					t.Errorf("leaves: %d, index: %d merkle path should be invalid with wrong index", n, i)
+				}
+			}
+		}
+
+		//计算路径不能修改交易ID
+		again, _ := MerkleRoot(txids)
+		if again != root {
+			t.Errorf("merkle root should be stable")
+		}
+	}
+
+	if _, err := MerklePath(testMerkleTxIDs(2), 2); err == nil {
+		t.Errorf("index out of range should fail")
+	}
+}
@@ -0,0 +1,96 @@
+package neoTransaction
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+)
+
+//PKCS11 对象类型和机制，取值与PKCS#11标准一致
+const (
+	CKO_PUBLIC_KEY  = 0x00000002
+	CKO_PRIVATE_KEY = 0x00000003
+	CKM_ECDSA       = 0x00001041
+)
+
+//PKCS11Session 已登录的PKCS#11会话，可由github.com/miekg/pkcs11等库适配，
+//适用于YubiHSM、SoftHSM等支持secp256r1的设备
+type PKCS11Session interface {
+	//FindObject 按对象类型和标签查找对象句柄
+	FindObject(class uint, label string) (uint, error)
+	//GetECPoint 读取公钥对象的CKA_EC_POINT属性
+	GetECPoint(handle uint) ([]byte, error)
+	//Sign 使用私钥对象按指定机制签名
+	Sign(mechanism uint, handle uint, data []byte) ([]byte, error)
+}
+
+//PKCS11Signer 私钥保存在PKCS#11设备中的签名者
+type PKCS11Signer struct {
+	session    PKCS11Session
+	privateKey uint
+	publicKey  []byte
+}
+
+//NewPKCS11Signer 按标签查找设备中的密钥对
+func NewPKCS11Signer(session PKCS11Session, label string) (*PKCS11Signer, error) {
+	privateKey, err := session.FindObject(CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, fmt.Errorf("find private key: %s failed, unexpected error: %v", label, err)
+	}
+
+	pubHandle, err := session.FindObject(CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, fmt.Errorf("find public key: %s failed, unexpected error: %v", label, err)
+	}
+
+	point, err := session.GetECPoint(pubHandle)
+	if err != nil {
+		return nil, fmt.Errorf("get public key: %s failed, unexpected error: %v", label, err)
+	}
+
+	publicKey, err := parseECPoint(point)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PKCS11Signer{
+		session:    session,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+	}, nil
+}
+
+//PublicKey 压缩格式公钥
+func (s *PKCS11Signer) PublicKey() ([]byte, error) {
+	return s.publicKey, nil
+}
+
+//Sign 在设备中对SHA256摘要做ECDSA签名，并规范为low-S
+func (s *PKCS11Signer) Sign(message []byte) ([]byte, error) {
+	digest := cryptoProvider.SHA256(message)
+
+	sig, err := s.session.Sign(CKM_ECDSA, s.privateKey, digest)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 sign failed, unexpected error: %v", err)
+	}
+
+	return NormalizeLowS(sig)
+}
+
+//parseECPoint 解析CKA_EC_POINT，支持DER OCTET STRING包装或裸点，返回压缩公钥
+func parseECPoint(point []byte) ([]byte, error) {
+	var raw []byte
+	if _, err := asn1.Unmarshal(point, &raw); err != nil {
+		raw = point
+	}
+
+	switch {
+	case len(raw) == 65 && raw[0] == 0x04:
+		return cryptoProvider.CompressPubkey(raw), nil
+	case len(raw) == 33 && (raw[0] == 0x02 || raw[0] == 0x03):
+		return raw, nil
+	}
+
+	return nil, errors.New("Invalid EC point of public key!")
+}
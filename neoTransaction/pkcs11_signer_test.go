@@ -0,0 +1,71 @@
+package neoTransaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+//testPKCS11Session 使用内存密钥模拟PKCS#11设备，签名时总是返回high-S
+type testPKCS11Session struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *testPKCS11Session) FindObject(class uint, label string) (uint, error) {
+	if label != "neo-hot" {
+		return 0, errors.New("object not found")
+	}
+	return class, nil
+}
+
+func (s *testPKCS11Session) GetECPoint(handle uint) ([]byte, error) {
+	return asn1.Marshal(elliptic.Marshal(elliptic.P256(), s.key.X, s.key.Y))
+}
+
+func (s *testPKCS11Session) Sign(mechanism uint, handle uint, data []byte) ([]byte, error) {
+	r, sv, err := ecdsa.Sign(rand.Reader, s.key, data)
+	if err != nil {
+		return nil, err
+	}
+	half := new(big.Int).Rsh(elliptic.P256().Params().N, 1)
+	if sv.Cmp(half) <= 0 {
+		sv.Sub(elliptic.P256().Params().N, sv)
+	}
+	sig := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), sv.Bytes()
+	copy(sig[32-len(rBytes):32], rBytes)
+	copy(sig[64-len(sBytes):], sBytes)
+	return sig, nil
+}
+
+func TestPKCS11Signer(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	session := &testPKCS11Session{key: key}
+
+	if _, err := NewPKCS11Signer(session, "unknown"); err == nil {
+		t.Errorf("unknown label should fail")
+	}
+
+	signer, err := NewPKCS11Signer(session, "neo-hot")
+	if err != nil {
+		t.Errorf("NewPKCS11Signer failed unexpected error: %v\n", err)
+		return
+	}
+
+	sigPub, err := SignRawTransactionWithSigner("80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf405", signer)
+	if err != nil {
+		t.Errorf("SignRawTransactionWithSigner failed unexpected error: %v\n", err)
+		return
+	}
+
+	if !IsLowS(sigPub.Signature) {
+		t.Errorf("signature should be low-S")
+	}
+	if len(sigPub.Pubkey) != 33 {
+		t.Errorf("public key should be compressed")
+	}
+}
@@ -0,0 +1,96 @@
+package neoTransaction
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+)
+
+// 地址版本号
+const AddressVersion = byte(0x17)
+
+// 多签公钥数量上限
+const MaxMultiSigPubkeys = 1024
+
+// 脚本hash转地址
+// scriptHash : 验证脚本的hash160
+func ScriptHashToAddress(scriptHash []byte) string {
+	return EncodeCheck([]byte{AddressVersion}, scriptHash)
+}
+
+// 规范公钥为33字节压缩格式
+func compressPubkey(pubkey []byte) ([]byte, error) {
+	switch {
+	case len(pubkey) == 33 && (pubkey[0] == 0x02 || pubkey[0] == 0x03):
+		return pubkey, nil
+	case len(pubkey) == 65 && pubkey[0] == 0x04:
+		return cryptoProvider.CompressPubkey(pubkey), nil
+	}
+	return nil, errors.New("Invalid pubkey data!")
+}
+
+// 创建单签验证脚本，返回验证脚本和地址，与neo-cli一致
+// pubkey : 压缩或非压缩公钥
+func CreateSignatureRedeemScript(pubkey []byte) ([]byte, string, error) {
+	pub, err := compressPubkey(pubkey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	script := append([]byte{OpPushBytes33}, pub...)
+	script = append(script, OpCheckSig)
+
+	return script, ScriptHashToAddress(cryptoProvider.Hash160(script)), nil
+}
+
+// 创建多签验证脚本，返回验证脚本和地址，与neo-cli一致
+// 公钥按椭圆曲线点排序（先比较X坐标，再比较Y坐标）
+// m : 最少签名数
+// pubkeys : 压缩或非压缩公钥
+func CreateMultiSigRedeemScript(m int, pubkeys [][]byte) ([]byte, string, error) {
+	n := len(pubkeys)
+	if m < 1 || m > n || n > MaxMultiSigPubkeys {
+		return nil, "", errors.New("Invalid required number or pubkeys for multisig!")
+	}
+
+	type point struct {
+		compressed []byte
+		x, y       *big.Int
+	}
+
+	points := make([]point, 0, n)
+	for _, pubkey := range pubkeys {
+		pub, err := compressPubkey(pubkey)
+		if err != nil {
+			return nil, "", err
+		}
+		full := cryptoProvider.DecompressPubkey(pub)
+		if len(full) != 65 {
+			return nil, "", errors.New("Invalid pubkey data!")
+		}
+		points = append(points, point{
+			compressed: pub,
+			x:          new(big.Int).SetBytes(full[1:33]),
+			y:          new(big.Int).SetBytes(full[33:]),
+		})
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		if c := points[i].x.Cmp(points[j].x); c != 0 {
+			return c < 0
+		}
+		return points[i].y.Cmp(points[j].y) < 0
+	})
+
+	sb := NewScriptBuilder().EmitPushInteger(big.NewInt(int64(m)))
+	for _, p := range points {
+		sb.EmitPushBytes(p.compressed)
+	}
+	script, err := sb.EmitPushInteger(big.NewInt(int64(n))).Emit(OpCheckMultiSig).ToArray()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return script, ScriptHashToAddress(cryptoProvider.Hash160(script)), nil
+}
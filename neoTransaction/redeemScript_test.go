@@ -0,0 +1,46 @@
+package neoTransaction
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestCreateSignatureRedeemScript(t *testing.T) {
+	pubkey, _ := hex.DecodeString("031a6c6fbbdf02ca351745fa86b9ba5a9452d785ac4f7fc2b7548ca2a46c4fcf4a")
+	script, address, err := CreateSignatureRedeemScript(pubkey)
+	if err != nil {
+		t.Errorf("CreateSignatureRedeemScript failed unexpected error: %v\n", err)
+		return
+	}
+	if hex.EncodeToString(script) != "21031a6c6fbbdf02ca351745fa86b9ba5a9452d785ac4f7fc2b7548ca2a46c4fcf4aac" {
+		t.Errorf("verification script: %x", script)
+	}
+	if address != "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y" {
+		t.Errorf("address: %s", address)
+	}
+}
+
+func TestCreateMultiSigRedeemScript(t *testing.T) {
+	pub1, _ := hex.DecodeString("031a6c6fbbdf02ca351745fa86b9ba5a9452d785ac4f7fc2b7548ca2a46c4fcf4a")
+	pub2, _ := hex.DecodeString("02df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5f")
+
+	script1, address1, err := CreateMultiSigRedeemScript(2, [][]byte{pub1, pub2})
+	if err != nil {
+		t.Errorf("CreateMultiSigRedeemScript failed unexpected error: %v\n", err)
+		return
+	}
+	//公钥顺序不影响结果
+	script2, address2, _ := CreateMultiSigRedeemScript(2, [][]byte{pub2, pub1})
+	if hex.EncodeToString(script1) != hex.EncodeToString(script2) || address1 != address2 {
+		t.Errorf("multisig script should be independent of pubkey order")
+	}
+
+	expect := "52" + "21" + hex.EncodeToString(pub1) + "21" + hex.EncodeToString(pub2) + "52ae"
+	if hex.EncodeToString(script1) != expect {
+		t.Errorf("multisig script: %x", script1)
+	}
+
+	if _, _, err := CreateMultiSigRedeemScript(3, [][]byte{pub1, pub2}); err == nil {
+		t.Errorf("required number greater than pubkeys should fail")
+	}
+}
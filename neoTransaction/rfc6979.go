@@ -0,0 +1,147 @@
+package neoTransaction
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+)
+
+//SignMode 签名随机数模式
+type SignMode int
+
+const (
+	SignModeRandom  SignMode = iota //使用随机数k
+	SignModeRFC6979                 //使用RFC 6979确定性k，不依赖签名时的随机数质量
+)
+
+//int2octets 把整数编码为32字节
+func int2octets(v *big.Int) []byte {
+	out := make([]byte, 32)
+	b := v.Bytes()
+	copy(out[32-len(b):], b)
+	return out
+}
+
+//rfc6979Nonce 按RFC 6979 3.2节生成确定性k，摘要和曲线阶都是256位，
+//skip为跳过的有效候选数，用于r或s为0时按标准继续生成下一个k
+func rfc6979Nonce(prikey, digest []byte, skip int) *big.Int {
+	n := p256Order
+
+	h1 := new(big.Int).SetBytes(digest)
+	h1.Mod(h1, n)
+
+	mac := func(key []byte, data ...[]byte) []byte {
+		m := hmac.New(sha256.New, key)
+		for _, d := range data {
+			m.Write(d)
+		}
+		return m.Sum(nil)
+	}
+
+	x := int2octets(new(big.Int).SetBytes(prikey))
+	h := int2octets(h1)
+
+	v := make([]byte, 32)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, 32)
+
+	k = mac(k, v, []byte{0x00}, x, h)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, x, h)
+	v = mac(k, v)
+
+	for {
+		v = mac(k, v)
+		nonce := new(big.Int).SetBytes(v)
+		if nonce.Sign() > 0 && nonce.Cmp(n) < 0 {
+			if skip == 0 {
+				return nonce
+			}
+			skip--
+		}
+		k = mac(k, v, []byte{0x00})
+		v = mac(k, v)
+	}
+}
+
+//signDeterministic 使用RFC 6979确定性k对摘要签名，返回low-S的64字节r||s
+func signDeterministic(prikey, digest []byte) ([]byte, error) {
+	curve := elliptic.P256()
+	n := p256Order
+
+	d := new(big.Int).SetBytes(prikey)
+	if d.Sign() <= 0 || d.Cmp(n) >= 0 {
+		return nil, errors.New("Invalid private key!")
+	}
+	e := new(big.Int).SetBytes(digest)
+
+	for skip := 0; ; skip++ {
+		k := rfc6979Nonce(prikey, digest, skip)
+
+		rx, _ := curve.ScalarBaseMult(int2octets(k))
+		r := new(big.Int).Mod(rx, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		return NormalizeLowS(append(int2octets(r), int2octets(s)...))
+	}
+}
+
+//IsCanonicalSignature 签名是否规范：r、s在[1, n-1]范围内且为low-S
+func IsCanonicalSignature(sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	if r.Sign() <= 0 || r.Cmp(p256Order) >= 0 {
+		return false
+	}
+	return IsLowS(sig)
+}
+
+//SignRawTransactionWithMode 按签名模式签名交易单，并校验签名规范
+func SignRawTransactionWithMode(rawTx string, prikey []byte, mode SignMode) (*SignaturePubkey, error) {
+	if mode != SignModeRFC6979 {
+		return SignRawTransaction(rawTx, prikey)
+	}
+
+	hash, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, errors.New("Invalid transaction hash!")
+	}
+	if len(prikey) != 32 {
+		return nil, errors.New("Transaction hash or private key data error!")
+	}
+
+	digest := cryptoProvider.SHA256(hash)
+	sig, err := signDeterministic(prikey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsCanonicalSignature(sig) {
+		return nil, errors.New("Signature is not canonical!")
+	}
+
+	pub, err := cryptoProvider.PublicKey(prikey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignaturePubkey{sig, cryptoProvider.CompressPubkey(pub)}, nil
+}
@@ -0,0 +1,60 @@
+package neoTransaction
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+//RFC 6979 A.2.5 P-256 SHA-256 "sample"
+func TestRFC6979Nonce(t *testing.T) {
+	prikey, _ := hex.DecodeString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	digest := sha256.Sum256([]byte("sample"))
+
+	k := rfc6979Nonce(prikey, digest[:], 0)
+	if hex.EncodeToString(int2octets(k)) != "a6e3c57dd01abe90086538398355dd4c3b17aa873382b0f24d6129493d8aad60" {
+		t.Errorf("nonce mismatch: %x", k)
+	}
+
+	sig, err := signDeterministic(prikey, digest[:])
+	if err != nil {
+		t.Errorf("signDeterministic failed unexpected error: %v\n", err)
+		return
+	}
+	if hex.EncodeToString(sig[:32]) != "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716" {
+		t.Errorf("r mismatch: %x", sig[:32])
+	}
+	//标准向量的s为high-S，规范后应为n-s
+	s, _ := new(big.Int).SetString("F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8", 16)
+	if new(big.Int).SetBytes(sig[32:]).Cmp(new(big.Int).Sub(p256Order, s)) != 0 {
+		t.Errorf("s is not low-S normalized: %x", sig[32:])
+	}
+	if !IsCanonicalSignature(sig) {
+		t.Errorf("signature should be canonical")
+	}
+}
+
+func TestSignRawTransactionWithMode(t *testing.T) {
+	prikey, _ := hex.DecodeString("55c87b7b8f435364250b271d979bfd3f83ebbc9950598a7b52b11ed7b117f89c")
+	rawTx := "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf405"
+
+	sig1, err := SignRawTransactionWithMode(rawTx, prikey, SignModeRFC6979)
+	if err != nil {
+		t.Errorf("SignRawTransactionWithMode failed unexpected error: %v\n", err)
+		return
+	}
+	sig2, _ := SignRawTransactionWithMode(rawTx, prikey, SignModeRFC6979)
+	if hex.EncodeToString(sig1.Signature) != hex.EncodeToString(sig2.Signature) {
+		t.Errorf("deterministic signatures should be equal")
+	}
+
+	raw, _ := hex.DecodeString(rawTx)
+	digest := owcrypt.Hash(raw, 0, owcrypt.HASH_ALG_SHA256)
+	pubkey := owcrypt.PointDecompress(sig1.Pubkey, owcrypt.ECC_CURVE_SECP256R1)[1:]
+	if owcrypt.Verify(pubkey, nil, 0, digest, 32, sig1.Signature, owcrypt.ECC_CURVE_SECP256R1) != owcrypt.SUCCESS {
+		t.Errorf("deterministic signature verify failed")
+	}
+}
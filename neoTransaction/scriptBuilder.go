@@ -0,0 +1,200 @@
+package neoTransaction
+
+import (
+	"errors"
+	"math/big"
+)
+
+// NEO VM 操作码
+const (
+	OpPush0      = byte(0x00)
+	OpPushData1  = byte(0x4C)
+	OpPushData2  = byte(0x4D)
+	OpPushData4  = byte(0x4E)
+	OpPushM1     = byte(0x4F)
+	OpPush1      = byte(0x51)
+	OpPush16     = byte(0x60)
+	OpAppCall    = byte(0x67)
+	OpSysCall    = byte(0x68)
+	OpTailCall   = byte(0x69)
+	OpPack       = byte(0xC1)
+	OpThrowIfNot = byte(0xF1)
+)
+
+// 脚本hash长度
+const ScriptHashLen = 20
+
+// 脚本构建器，用于构建NEP-5转账和合约调用的调用脚本
+// 构建过程中的错误会被记录，调用ToArray时返回
+type ScriptBuilder struct {
+	script []byte
+	err    error
+}
+
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{script: make([]byte, 0)}
+}
+
+// 写入操作码及参数
+func (sb *ScriptBuilder) Emit(op byte, arg ...byte) *ScriptBuilder {
+	sb.script = append(sb.script, op)
+	sb.script = append(sb.script, arg...)
+	return sb
+}
+
+// 压入字节数组
+func (sb *ScriptBuilder) EmitPushBytes(data []byte) *ScriptBuilder {
+	length := len(data)
+	switch {
+	case length < int(OpPushData1):
+		sb.script = append(sb.script, byte(length))
+	case length <= 0xFF:
+		sb.script = append(sb.script, OpPushData1, byte(length))
+	case length <= 0xFFFF:
+		sb.script = append(sb.script, OpPushData2)
+		sb.script = append(sb.script, uint16ToLittleEndianBytes(uint16(length))...)
+	default:
+		sb.script = append(sb.script, OpPushData4)
+		sb.script = append(sb.script, uint32ToLittleEndianBytes(uint32(length))...)
+	}
+	sb.script = append(sb.script, data...)
+	return sb
+}
+
+// 压入字符串
+func (sb *ScriptBuilder) EmitPushString(data string) *ScriptBuilder {
+	return sb.EmitPushBytes([]byte(data))
+}
+
+// 压入布尔值
+func (sb *ScriptBuilder) EmitPushBool(data bool) *ScriptBuilder {
+	if data {
+		return sb.Emit(OpPush1)
+	}
+	return sb.Emit(OpPush0)
+}
+
+// 压入整数，-1到16使用单字节操作码
+func (sb *ScriptBuilder) EmitPushInteger(number *big.Int) *ScriptBuilder {
+	if number.IsInt64() {
+		n := number.Int64()
+		switch {
+		case n == -1:
+			return sb.Emit(OpPushM1)
+		case n == 0:
+			return sb.Emit(OpPush0)
+		case n > 0 && n <= 16:
+			return sb.Emit(OpPush1 - 1 + byte(n))
+		}
+	}
+	return sb.EmitPushBytes(bigIntToNeoBytes(number))
+}
+
+// 压入参数，支持bool、整数、*big.Int、[]byte、string及其组成的[]interface{}数组
+func (sb *ScriptBuilder) EmitPush(v interface{}) *ScriptBuilder {
+	switch value := v.(type) {
+	case bool:
+		return sb.EmitPushBool(value)
+	case int:
+		return sb.EmitPushInteger(big.NewInt(int64(value)))
+	case int64:
+		return sb.EmitPushInteger(big.NewInt(value))
+	case uint64:
+		return sb.EmitPushInteger(new(big.Int).SetUint64(value))
+	case *big.Int:
+		return sb.EmitPushInteger(value)
+	case []byte:
+		return sb.EmitPushBytes(value)
+	case string:
+		return sb.EmitPushString(value)
+	case []interface{}:
+		//数组参数倒序压入，再压入长度并打包
+		for i := len(value) - 1; i >= 0; i-- {
+			sb.EmitPush(value[i])
+		}
+		return sb.EmitPushInteger(big.NewInt(int64(len(value)))).Emit(OpPack)
+	}
+	if sb.err == nil {
+		sb.err = errors.New("Unsupported script parameter type!")
+	}
+	return sb
+}
+
+// 调用合约
+// scriptHash : 合约脚本hash，小端字节序
+// useTailCall : 是否使用尾调用
+func (sb *ScriptBuilder) EmitAppCall(scriptHash []byte, useTailCall bool) *ScriptBuilder {
+	if len(scriptHash) != ScriptHashLen {
+		if sb.err == nil {
+			sb.err = errors.New("Invalid contract script hash!")
+		}
+		return sb
+	}
+	if useTailCall {
+		return sb.Emit(OpTailCall, scriptHash...)
+	}
+	return sb.Emit(OpAppCall, scriptHash...)
+}
+
+// 按合约方法和参数调用合约，参数打包为数组
+func (sb *ScriptBuilder) EmitAppCallWithOperation(scriptHash []byte, operation string, args ...interface{}) *ScriptBuilder {
+	if args == nil {
+		args = []interface{}{}
+	}
+	return sb.EmitPush(args).EmitPushString(operation).EmitAppCall(scriptHash, false)
+}
+
+// 调用系统接口
+func (sb *ScriptBuilder) EmitSysCall(api string) *ScriptBuilder {
+	if len(api) == 0 || len(api) > 252 {
+		if sb.err == nil {
+			sb.err = errors.New("Invalid syscall api!")
+		}
+		return sb
+	}
+	return sb.Emit(OpSysCall, append([]byte{byte(len(api))}, api...)...)
+}
+
+// 获取脚本
+func (sb *ScriptBuilder) ToArray() ([]byte, error) {
+	if sb.err != nil {
+		return nil, sb.err
+	}
+	return sb.script, nil
+}
+
+// 构建NEP-5转账调用脚本，转账失败时虚拟机中断
+// contractHash : 合约脚本hash，小端字节序
+// from, to : 转出和接收地址的脚本hash，小端字节序
+// amount : 转账数量，按合约精度换算后的整数
+func BuildNEP5TransferScript(contractHash, from, to []byte, amount *big.Int) ([]byte, error) {
+	return NewScriptBuilder().
+		EmitAppCallWithOperation(contractHash, "transfer", from, to, amount).
+		Emit(OpThrowIfNot).
+		ToArray()
+}
+
+// 整数转为NEO虚拟机的小端补码字节序
+func bigIntToNeoBytes(number *big.Int) []byte {
+	if number.Sign() == 0 {
+		return []byte{}
+	}
+
+	if number.Sign() > 0 {
+		ret := reverseBytes(number.Bytes())
+		if ret[len(ret)-1]&0x80 != 0 {
+			ret = append(ret, 0x00)
+		}
+		return ret
+	}
+
+	//负数取补码：2^(8*n) + number，n为能容纳该数的最小字节数
+	abs := new(big.Int).Neg(number)
+	n := (new(big.Int).Sub(abs, big.NewInt(1)).BitLen() + 8) / 8
+	twos := new(big.Int).Lsh(big.NewInt(1), uint(n*8))
+	twos.Add(twos, number)
+	padded := make([]byte, n)
+	b := twos.Bytes()
+	copy(padded[n-len(b):], b)
+	return reverseBytes(padded)
+}
@@ -0,0 +1,68 @@
+package neoTransaction
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestScriptBuilder_EmitPush(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		expect string
+	}{
+		{-1, "4f"},
+		{0, "00"},
+		{1, "51"},
+		{16, "60"},
+		{17, "0111"},
+		{128, "028000"},
+		{-128, "0180"},
+		{-129, "027fff"},
+		{true, "51"},
+		{"transfer", "087472616e73666572"},
+		{[]interface{}{1, 2}, "525152c1"},
+	}
+	for _, test := range tests {
+		script, err := NewScriptBuilder().EmitPush(test.value).ToArray()
+		if err != nil {
+			t.Errorf("EmitPush failed unexpected error: %v\n", err)
+			continue
+		}
+		if hex.EncodeToString(script) != test.expect {
+			t.Errorf("push %v got: %x, expected: %s", test.value, script, test.expect)
+		}
+	}
+
+	if _, err := NewScriptBuilder().EmitPush(1.5).ToArray(); err == nil {
+		t.Errorf("unsupported type should fail")
+	}
+}
+
+func TestScriptBuilder_EmitSysCall(t *testing.T) {
+	script, _ := NewScriptBuilder().EmitSysCall("Neo.Runtime.GetTrigger").ToArray()
+	if hex.EncodeToString(script) != "68164e656f2e52756e74696d652e47657454726967676572" {
+		t.Errorf("syscall script: %x", script)
+	}
+}
+
+func TestBuildNEP5TransferScript(t *testing.T) {
+	contract, _ := hex.DecodeString("9aff1e08aea2048a26a3d2ddbb3df495b932b1e7")
+	from, _ := hex.DecodeString("0101010101010101010101010101010101010101")
+	to, _ := hex.DecodeString("0202020202020202020202020202020202020202")
+
+	script, err := BuildNEP5TransferScript(contract, from, to, big.NewInt(100000000))
+	if err != nil {
+		t.Errorf("BuildNEP5TransferScript failed unexpected error: %v\n", err)
+		return
+	}
+
+	expect := "0400e1f505" +
+		"14" + "0202020202020202020202020202020202020202" +
+		"14" + "0101010101010101010101010101010101010101" +
+		"53c1" + "087472616e73666572" +
+		"67" + "9aff1e08aea2048a26a3d2ddbb3df495b932b1e7" + "f1"
+	if hex.EncodeToString(script) != expect {
+		t.Errorf("transfer script: %x", script)
+	}
+}
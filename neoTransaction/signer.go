@@ -0,0 +1,91 @@
+package neoTransaction
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+)
+
+//Signer 交易签名者，私钥可保存在内存、硬件设备或远程服务
+type Signer interface {
+	//PublicKey 压缩格式公钥
+	PublicKey() ([]byte, error)
+	//Sign 对消息的SHA256摘要做secp256r1签名，返回64字节r||s
+	Sign(message []byte) ([]byte, error)
+}
+
+//p256Order secp256r1曲线阶
+var p256Order = elliptic.P256().Params().N
+
+//NormalizeLowS 把签名的s规范为不大于曲线阶一半的值
+func NormalizeLowS(sig []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, errors.New("Invalid signature length!")
+	}
+
+	s := new(big.Int).SetBytes(sig[32:])
+	half := new(big.Int).Rsh(p256Order, 1)
+	if s.Cmp(half) <= 0 {
+		return sig, nil
+	}
+
+	s.Sub(p256Order, s)
+	normalized := make([]byte, 64)
+	copy(normalized, sig[:32])
+	sBytes := s.Bytes()
+	copy(normalized[64-len(sBytes):], sBytes)
+	return normalized, nil
+}
+
+//IsLowS 签名的s是否不大于曲线阶一半
+func IsLowS(sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	s := new(big.Int).SetBytes(sig[32:])
+	return s.Sign() > 0 && s.Cmp(new(big.Int).Rsh(p256Order, 1)) <= 0
+}
+
+//SignRawTransactionWithSigner 使用签名者签名交易单
+func SignRawTransactionWithSigner(rawTx string, signer Signer) (*SignaturePubkey, error) {
+	hash, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, errors.New("Invalid transaction hash!")
+	}
+
+	sig, err := signer.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err = NormalizeLowS(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := signer.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	//签名者可能是外部设备，返回前校验签名
+	digest := cryptoProvider.SHA256(hash)
+	if !verifyCompressed(pub, digest, sig) {
+		return nil, errors.New("Signer produced invalid signature!")
+	}
+
+	return &SignaturePubkey{sig, pub}, nil
+}
+
+//VerifySignature 校验摘要的签名，用于接收外部签名服务回传的签名
+//digest : 交易签名摘要，即HashForSigning的结果
+//pubkey : 压缩格式公钥
+//signature : 64字节r||s
+func VerifySignature(digest, pubkey, signature []byte) bool {
+	if len(digest) != 32 || len(pubkey) != 33 || len(signature) != 64 {
+		return false
+	}
+	return verifyCompressed(pubkey, digest, signature)
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"github.com/blocktree/go-owcrypt"
 )
 
 type Vin struct {
@@ -78,6 +77,35 @@ func CreateRawTransactionHashForSig(txHex string) ([]TxHash, error) {
 	return emptyTrans.getHashesForSig()
 }
 
+// 按签名者地址获取待签名的交易哈希，交易输入属于不同地址时每个地址一个，按见证人顺序排列
+// NEO交易的所有见证人签名同一个摘要，各地址的哈希相同，Normal.Address用于把哈希路由到对应的私钥
+// txHex : 交易hex，已签名交易会先去掉见证人
+// addresses : 交易输入所属的地址，重复的地址只保留一个
+func CreateRawTransactionHashForSigByAddresses(txHex string, addresses []string) ([]TxHash, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("No signer address found!")
+	}
+
+	sorted, err := SortAddressesByScriptHash(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := HashForSigning(txHex)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]TxHash, 0, len(sorted))
+	for _, address := range sorted {
+		hashes = append(hashes, TxHash{
+			Hash:   hex.EncodeToString(digest),
+			Normal: &NormalTx{Address: address},
+		})
+	}
+	return hashes, nil
+}
+
 // 签名原始交易
 // rawTx : 组装获得的原始交易
 // priKey : 签名的私钥
@@ -121,12 +149,12 @@ func InsertSignatureIntoEmptyTransaction(rawTx string, txHashes []TxHash) (strin
 	}
 
 	for _, txHash := range txHashes {
-		script, err := createTxScript(txHash.Normal.SigPub.Pubkey, txHash.Normal.SigPub.Signature)
+		script, err := NewSignatureWitness(txHash.Normal.SigPub.Pubkey, txHash.Normal.SigPub.Signature)
 		if err != nil {
 			return "", err
 		}
 
-		emptyTrans.Scripts = append(emptyTrans.Scripts, *script)
+		emptyTrans.AddWitness(*script)
 	}
 
 	ret, err := emptyTrans.encodeToBytes()
@@ -189,16 +217,14 @@ func VerifyRawTransaction(signedRawTx string) bool {
 	for _, t := range txHash {
 		th, _ := hex.DecodeString(t.Hash)
 		if t.NRequired == 0 {
-			pubkey := owcrypt.PointDecompress(t.Normal.SigPub.Pubkey, owcrypt.ECC_CURVE_SECP256R1)[1:]
-			if owcrypt.Verify(pubkey, nil, 0, th, 32, t.Normal.SigPub.Signature, owcrypt.ECC_CURVE_SECP256R1) != owcrypt.SUCCESS {
+			if !verifyCompressed(t.Normal.SigPub.Pubkey, th, t.Normal.SigPub.Signature) {
 				return false
 			}
 		} else {
 			count := 0
 			for i := 0; i < int(t.NRequired); i++ {
 				for j := count; j < len(t.Multi); j++ {
-					pubkey := owcrypt.PointDecompress(t.Multi[j].SigPub.Pubkey, owcrypt.ECC_CURVE_SECP256R1)[1:]
-					if owcrypt.Verify(pubkey, nil, 0, th, 32, t.Multi[i].SigPub.Signature, owcrypt.ECC_CURVE_SECP256R1) == owcrypt.SUCCESS {
+					if verifyCompressed(t.Multi[j].SigPub.Pubkey, th, t.Multi[i].SigPub.Signature) {
 						count++
 						break
 					}
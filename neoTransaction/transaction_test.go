@@ -1668,3 +1668,63 @@ func TestVerifyRawTransaction(t *testing.T) {
 	}
 	t.Log("Verify raw transaction success!")
 }
+
+func TestCreateEmptyRawTransactionWithAttributes(t *testing.T) {
+	in := Vin{"3e7146b4f1841a591d5989d6fc01d7ae3631136178d932de36ad0ebe63ba8113", uint16(1)}
+	out := Vout{NeoAssetId, "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC", uint64(100000000)}
+	remark := []byte("proof of existence")
+	script := "0102030405060708090a0b0c0d0e0f1011121314"
+	attrs := []Attribute{
+		{AttrRemark, hex.EncodeToString(remark)},
+		{AttrScript, script},
+	}
+
+	emptyTrans, err := CreateEmptyRawTransaction(ContractTransaction, []Vin{in}, []Vout{out}, attrs)
+	if err != nil {
+		t.Errorf("CreateEmptyRawTransaction failed unexpected error: %v", err)
+		return
+	}
+
+	// 变长数据按变长整数写入长度，定长数据不写入长度
+	expected := "f0" + fmt.Sprintf("%02x", len(remark)) + hex.EncodeToString(remark) + "20" + script
+	if emptyTrans[4:6] != "02" || emptyTrans[6:6+len(expected)] != expected {
+		t.Errorf("unexpected attributes encoding: %s", emptyTrans)
+	}
+
+	txBytes, _ := hex.DecodeString(emptyTrans)
+	tx, err := DecodeRawTransaction(txBytes)
+	if err != nil || len(tx.Attributes) != 2 {
+		t.Errorf("DecodeRawTransaction failed unexpected error: %v", err)
+		return
+	}
+	if tx.Attributes[0].Usage() != AttrRemark.value || string(tx.Attributes[0].Data()) != string(remark) {
+		t.Errorf("unexpected remark attribute: %s", tx.Attributes[0].String())
+	}
+	if hex.EncodeToString(tx.Attributes[1].Data()) != script || len(tx.Vins) != 1 || len(tx.Vouts) != 1 {
+		t.Errorf("unexpected decoded transaction: %s", tx.String())
+	}
+
+	// 定长数据长度不符
+	if _, err := CreateEmptyRawTransaction(ContractTransaction, []Vin{in}, []Vout{out}, []Attribute{{AttrScript, "01"}}); err == nil {
+		t.Errorf("invalid fixed length attribute should fail")
+	}
+}
+
+func TestDecodeTxOut(t *testing.T) {
+	out := Vout{NeoGasAssetId, "ANYZ11AmUfwiZFLbAWHoExFyBuqgLmfz88", uint64(150000000)}
+	emptyTrans, err := CreateEmptyRawTransaction(ContractTransaction, []Vin{{"3e7146b4f1841a591d5989d6fc01d7ae3631136178d932de36ad0ebe63ba8113", 1}}, []Vout{out}, nil)
+	if err != nil {
+		t.Fatalf("CreateEmptyRawTransaction failed, unexpected error: %v", err)
+	}
+	txBytes, _ := hex.DecodeString(emptyTrans)
+	tx, err := DecodeRawTransaction(txBytes)
+	if err != nil || len(tx.Vouts) != 1 {
+		t.Fatalf("DecodeRawTransaction failed, unexpected error: %v", err)
+	}
+	//多次读取结果不变
+	for i := 0; i < 2; i++ {
+		if tx.Vouts[0].GetAssetID() != NeoGasAssetId || tx.Vouts[0].GetValue() != out.Value || tx.Vouts[0].GetAddress() != out.Address {
+			t.Errorf("unexpected output: %s %d %s", tx.Vouts[0].GetAssetID(), tx.Vouts[0].GetValue(), tx.Vouts[0].GetAddress())
+		}
+	}
+}
@@ -41,8 +41,16 @@ func newTxAttributeForEmptyTrans(attrs []Attribute) ([]TxAttribute, error) {
 		}
 		txAttr := TxAttribute{usage: attr.Attr.value}
 		if attr.Attr.fixedDataLength != 0 {
-			data = fixedDataLen(data, int(attr.Attr.fixedDataLength))
-			txAttr.length = uint16ToLittleEndianBytes(uint16(len(data)))
+			// 定长数据不写入长度
+			if len(data) != int(attr.Attr.fixedDataLength) {
+				return nil, errors.New(fmt.Sprintf("Invalid attribute %s data length : %d, expected : %d", attr.Attr.jsonString, len(data), attr.Attr.fixedDataLength))
+			}
+		} else {
+			// 变长数据以变长整数写入长度
+			if attr.Attr.maxDataLength != 0 && len(data) > int(attr.Attr.maxDataLength) {
+				return nil, errors.New(fmt.Sprintf("Invalid attribute %s data length : %d, max : %d", attr.Attr.jsonString, len(data), attr.Attr.maxDataLength))
+			}
+			txAttr.length = writeVarInt(uint64(len(data)))
 		}
 		txAttr.data = data
 		ret = append(ret, txAttr)
@@ -55,6 +63,9 @@ func newTxAttributeForEmptyTrans(attrs []Attribute) ([]TxAttribute, error) {
 // index : 对应在序列化数组中的索引
 func decodeTxAttributeFromRawTrans(txByte []byte, index int) ([]TxAttribute, int, error) {
 	var txAttrs = make([]TxAttribute, 0)
+	if index >= len(txByte) {
+		return nil, index, errors.New("Invalid transaction attribute length")
+	}
 	var attrCount = txByte[index]
 	index++
 	if attrCount == 0 {
@@ -63,51 +74,56 @@ func decodeTxAttributeFromRawTrans(txByte []byte, index int) ([]TxAttribute, int
 
 	for i := byte(0); i < attrCount; i++ {
 		var txAttr = TxAttribute{}
-		if index > len(txByte) {
-			return nil, index, errors.New("Invalid transaction vout attribute length")
+		if index >= len(txByte) {
+			return nil, index, errors.New("Invalid transaction attribute length")
 		}
 		txAttr.usage = txByte[index]
 		index++
 		attrType := getAttributeTypeByUsage(txAttr.usage)
+		if attrType == nil {
+			return nil, index, errors.New(fmt.Sprintf("Invalid transaction attribute usage : %d", txAttr.usage))
+		}
 		if attrType.fixedDataLength == 0 {
-			if index+2 > len(txByte) {
-				return nil, index, errors.New("Invalid transaction vout attribute length")
-			}
-			txAttr.length = txByte[index : index+2]
-			index += 2
-			dataLen := int(littleEndianBytesToUint16(txAttr.length))
-			if index+dataLen > len(txByte) {
-				return nil, index, errors.New("Invalid transaction vout attribute length")
+			start := index
+			data, newIndex, err := readVarBytes(txByte, index)
+			if err != nil {
+				return nil, index, errors.New("Invalid transaction attribute length")
 			}
-			txAttr.data = txByte[index : index+dataLen]
-			index += dataLen
+			txAttr.length = txByte[start : newIndex-len(data)]
+			txAttr.data = data
+			index = newIndex
 			txAttrs = append(txAttrs, txAttr)
 			continue
 		}
 		if index+int(attrType.fixedDataLength) > len(txByte) {
-			return nil, index, errors.New("Invalid transaction vout attribute length")
+			return nil, index, errors.New("Invalid transaction attribute length")
 		}
 		txAttr.data = txByte[index : index+int(attrType.fixedDataLength)]
+		index += int(attrType.fixedDataLength)
 		txAttrs = append(txAttrs, txAttr)
 	}
 	return txAttrs, index, nil
 }
 
-// 转换为字节数组
+// 转换为字节数组，变长数据的长度已按变长整数编码
 func (ta TxAttribute) toBytes() ([]byte, error) {
-	if ta.usage < 0 || ta.usage > 0xff {
-		return nil, errors.New(fmt.Sprintf("Invalid usage value : %d", ta.usage))
-	}
 	ret := []byte{}
 	ret = append(ret, ta.usage)
-	lengthData := littleEndianBytesToUint16(ta.length)
-	if lengthData > 0 {
-		ret = append(ret, ta.length...)
-	}
+	ret = append(ret, ta.length...)
 	ret = append(ret, ta.data...)
 	return ret, nil
 }
 
+// 使用类型
+func (ta TxAttribute) Usage() byte {
+	return ta.usage
+}
+
+// 附加数据
+func (ta TxAttribute) Data() []byte {
+	return ta.data
+}
+
 func (tx *TxAttribute) String() string {
 	return fmt.Sprintf("{ usage : %x, length : %x, data : %x }", tx.usage, tx.length, tx.data)
 }
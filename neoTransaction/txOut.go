@@ -13,6 +13,21 @@ type TxOut struct {
 	address []byte
 }
 
+// 获取接收地址
+func (out TxOut) GetAddress() string {
+	return ScriptHashToAddress(out.address)
+}
+
+// 获取资产ID
+func (out TxOut) GetAssetID() string {
+	return reverseBytesToHex(append([]byte{}, out.asset...))
+}
+
+// 获取金额，单位为资产的最小精度
+func (out TxOut) GetValue() uint64 {
+	return littleEndianBytesToUint64(out.value)
+}
+
 // 创建并序列化交易输出
 // vouts : 交易输出源数据
 func newTxOutForEmptyTrans(vouts []Vout) ([]TxOut, error) {
@@ -80,21 +80,21 @@ func createTxScript(pubKeyBytes, signBytes []byte) (*TxScript, error) {
 // index : 对应序列化数组的索引
 func decodeTxScriptVerificationFromRawTrans(txByte []byte, index int) ([]TxScript, int, error) {
 	var ret = make([]TxScript, 0)
-	scriptsCount := txByte[index]
-	index++
-	for i := byte(0); i < scriptsCount; i++ {
-		index++
-		if index+65 > len(txByte) {
+	scriptsCount, index, err := readVarInt(txByte, index)
+	if err != nil {
+		return ret, index, err
+	}
+	for i := uint64(0); i < scriptsCount; i++ {
+		invocationScript, newIndex, err := readVarBytes(txByte, index)
+		if err != nil {
 			return ret, index, errors.New("Invalid transaction tx script invocationScript")
 		}
-		invocationScript := txByte[index : index+65]
-		index += 65
-		index++
-		if index+35 > len(txByte) {
+		index = newIndex
+		verificationScript, newIndex, err := readVarBytes(txByte, index)
+		if err != nil {
 			return ret, index, errors.New("Invalid transaction tx script verificationScript")
 		}
-		verificationScript := txByte[index : index+35]
-		index += 35
+		index = newIndex
 		ret = append(ret, TxScript{invocationScript: invocationScript, verificationScript: verificationScript})
 	}
 	return ret, index, nil
@@ -103,9 +103,9 @@ func decodeTxScriptVerificationFromRawTrans(txByte []byte, index int) ([]TxScrip
 // 转换为 byte 数组
 func (ts TxScript) toBytes() ([]byte, error) {
 	var ret = make([]byte, 0)
-	ret = append(ret, byte(len(ts.invocationScript)))
+	ret = append(ret, writeVarInt(uint64(len(ts.invocationScript)))...)
 	ret = append(ret, ts.invocationScript...)
-	ret = append(ret, byte(len(ts.verificationScript)))
+	ret = append(ret, writeVarInt(uint64(len(ts.verificationScript)))...)
 	ret = append(ret, ts.verificationScript...)
 	return ret, nil
 }
@@ -83,6 +83,11 @@ func (t *Transaction) GetHash() (string,error){
 
 }
 
+// 交易序列化，Scripts为nil时只序列化未签名部分
+func (t Transaction) EncodeToBytes() ([]byte, error) {
+	return t.encodeToBytes()
+}
+
 // 交易序列化组装
 func (t Transaction) encodeToBytes() (ret []byte, err error) {
 	ret = append(ret, t.Type)
@@ -118,7 +123,7 @@ func (t Transaction) encodeToBytes() (ret []byte, err error) {
 		return ret, nil
 	}
 
-	ret = append(ret, byte(len(t.Scripts)))
+	ret = append(ret, writeVarInt(uint64(len(t.Scripts)))...)
 	for _, script := range t.Scripts {
 		scriptBytes, err := script.toBytes()
 		if err != nil {
@@ -0,0 +1,318 @@
+package neoTransaction
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+)
+
+// 见证人即交易脚本，由调用脚本和验证脚本组成，每个签名者一个
+// 交易中的见证人必须按验证脚本hash（UInt160）升序排列
+
+// 调用脚本
+func (ts TxScript) InvocationScript() []byte {
+	return ts.invocationScript
+}
+
+// 验证脚本
+func (ts TxScript) VerificationScript() []byte {
+	return ts.verificationScript
+}
+
+// 验证脚本hash，即签名者地址对应的脚本hash
+func (ts TxScript) ScriptHash() []byte {
+	return cryptoProvider.Hash160(ts.verificationScript)
+}
+
+// 创建单签见证人
+// pubKey : 签名对应的压缩公钥
+// signBytes : 签名
+func NewSignatureWitness(pubKey, signBytes []byte) (*TxScript, error) {
+	if len(pubKey) != 33 || len(signBytes) != 64 {
+		return nil, errors.New("Invalid pubkey or signature data!")
+	}
+	return createTxScript(pubKey, signBytes)
+}
+
+// 由签名和公钥构建的单签见证人脚本，供离线签名的外部系统组装交易
+type WitnessScripts struct {
+	Invocation      []byte // 调用脚本
+	Verification    []byte // 验证脚本
+	InvocationHex   string
+	VerificationHex string
+	Address         string // 签名者地址
+}
+
+// 由签名和公钥构建单签见证人的调用脚本和验证脚本
+// signature : 对HashForSigning摘要的64字节r||s签名，或DER编码的签名
+// pubkey : 签名者的压缩或非压缩公钥
+func BuildWitness(signature, pubkey []byte) (*WitnessScripts, error) {
+	sig, err := normalizeSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := compressPubkey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	script, err := createTxScript(pub, sig)
+	if err != nil {
+		return nil, err
+	}
+	return &WitnessScripts{
+		Invocation:      script.invocationScript,
+		Verification:    script.verificationScript,
+		InvocationHex:   hex.EncodeToString(script.invocationScript),
+		VerificationHex: hex.EncodeToString(script.verificationScript),
+		Address:         ScriptHashToAddress(script.ScriptHash()),
+	}, nil
+}
+
+// 转为交易脚本
+func (w *WitnessScripts) TxScript() TxScript {
+	return TxScript{invocationScript: w.Invocation, verificationScript: w.Verification}
+}
+
+// 把外部签名的单签见证人加入交易，返回可广播的交易hex
+// 见证人按脚本hash顺序插入，同一签名者已有的见证人会被替换，签名与交易摘要不匹配时返回错误
+// rawTx : 未签名或部分签名的交易hex
+// witnesses : BuildWitness构建的见证人
+func AttachWitnesses(rawTx string, witnesses ...*WitnessScripts) (string, error) {
+	if len(witnesses) == 0 {
+		return "", errors.New("No witness to attach!")
+	}
+	txBytes, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return "", errors.New("Invalid transaction hex data!")
+	}
+	tx, err := DecodeRawTransaction(txBytes)
+	if err != nil {
+		return "", err
+	}
+	digest, err := HashForSigning(rawTx)
+	if err != nil {
+		return "", err
+	}
+	for i, w := range witnesses {
+		if w == nil {
+			return "", fmt.Errorf("witness %d is nil", i)
+		}
+		script := w.TxScript()
+		pub, err := script.GetPubKeyByVerificationScript()
+		if err != nil {
+			return "", fmt.Errorf("witness %d, %v", i, err)
+		}
+		sig, err := script.GetSignatureByInvocationScript()
+		if err != nil {
+			return "", fmt.Errorf("witness %d, %v", i, err)
+		}
+		if !verifyCompressed(pub, digest, sig) {
+			return "", fmt.Errorf("witness %d signer: %s signature does not match the transaction", i, ScriptHashToAddress(script.ScriptHash()))
+		}
+		tx.AddWitness(script)
+	}
+	ret, err := tx.encodeToBytes()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(ret), nil
+}
+
+// 签名规范为64字节r||s，支持DER编码的签名
+func normalizeSignature(signature []byte) ([]byte, error) {
+	if len(signature) == 64 {
+		return signature, nil
+	}
+	var der struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(signature, &der)
+	if err != nil || len(rest) != 0 || der.R == nil || der.S == nil || der.R.Sign() <= 0 || der.S.Sign() <= 0 {
+		return nil, errors.New("Invalid signature data!")
+	}
+	r, s := der.R.Bytes(), der.S.Bytes()
+	if len(r) > 32 || len(s) > 32 {
+		return nil, errors.New("Invalid signature data!")
+	}
+	sig := make([]byte, 64)
+	copy(sig[32-len(r):32], r)
+	copy(sig[64-len(s):], s)
+	return sig, nil
+}
+
+// 创建多签见证人，签名需按验证脚本中公钥的顺序排列
+// signatures : 签名列表
+// verification : 多签验证脚本
+func NewMultiSigWitness(signatures [][]byte, verification []byte) (*TxScript, error) {
+	if len(verification) == 0 {
+		return nil, errors.New("Invalid verification script!")
+	}
+	invocation := make([]byte, 0, len(signatures)*65)
+	for _, sig := range signatures {
+		if len(sig) != 64 {
+			return nil, errors.New("Invalid signature data!")
+		}
+		invocation = append(invocation, BuildInvocation(sig)...)
+	}
+	return NewEmptyTxScript(invocation, verification), nil
+}
+
+// 比较脚本hash，与UInt160一致从高位字节开始比较
+func compareScriptHash(a, b []byte) int {
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// 地址转脚本hash
+func AddressToScriptHash(address string) ([]byte, error) {
+	data, err := Decode(address, NeocoinAlphabet)
+	if err != nil || len(data) != 1+ScriptHashLen+4 {
+		return nil, errors.New("Invalid address!")
+	}
+	prefix, hash, err := DecodeCheck(address)
+	if err != nil {
+		return nil, err
+	}
+	if prefix[0] != AddressVersion {
+		return nil, errors.New("Invalid address version!")
+	}
+	return hash, nil
+}
+
+// 签名者地址按脚本hash升序排列，即交易中见证人要求的顺序
+// addresses : 签名者地址，重复的地址只保留一个
+func SortAddressesByScriptHash(addresses []string) ([]string, error) {
+	type signer struct {
+		address string
+		hash    []byte
+	}
+	signers := make([]signer, 0, len(addresses))
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		hash, err := AddressToScriptHash(address)
+		if err != nil {
+			return nil, fmt.Errorf("address: %s, %v", address, err)
+		}
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		signers = append(signers, signer{address: address, hash: hash})
+	}
+	sort.SliceStable(signers, func(i, j int) bool {
+		return compareScriptHash(signers[i].hash, signers[j].hash) < 0
+	})
+	sorted := make([]string, 0, len(signers))
+	for _, s := range signers {
+		sorted = append(sorted, s.address)
+	}
+	return sorted, nil
+}
+
+// 检查见证人是否按脚本hash严格升序排列，顺序错误或签名者重复时节点会返回验证失败
+func CheckWitnessOrder(scripts []TxScript) error {
+	for i := 1; i < len(scripts); i++ {
+		switch compareScriptHash(scripts[i-1].ScriptHash(), scripts[i].ScriptHash()) {
+		case 0:
+			return fmt.Errorf("witness %d and %d have the same signer: %s", i-1, i, ScriptHashToAddress(scripts[i].ScriptHash()))
+		case 1:
+			return fmt.Errorf("witness %d signer: %s must be placed before witness %d signer: %s",
+				i, ScriptHashToAddress(scripts[i].ScriptHash()), i-1, ScriptHashToAddress(scripts[i-1].ScriptHash()))
+		}
+	}
+	return nil
+}
+
+// 检查交易的见证人顺序
+func (t *Transaction) CheckWitnessOrder() error {
+	return CheckWitnessOrder(t.Scripts)
+}
+
+// 添加见证人，按脚本hash顺序插入，相同脚本hash的见证人会被替换
+func (t *Transaction) AddWitness(witness TxScript) {
+	hash := witness.ScriptHash()
+	for i, script := range t.Scripts {
+		switch compareScriptHash(hash, script.ScriptHash()) {
+		case 0:
+			t.Scripts[i] = witness
+			return
+		case -1:
+			t.Scripts = append(t.Scripts, TxScript{})
+			copy(t.Scripts[i+1:], t.Scripts[i:])
+			t.Scripts[i] = witness
+			return
+		}
+	}
+	t.Scripts = append(t.Scripts, witness)
+}
+
+// 写入变长整数
+func writeVarInt(v uint64) []byte {
+	switch {
+	case v < 0xFD:
+		return []byte{byte(v)}
+	case v <= 0xFFFF:
+		ret := []byte{0xFD, 0, 0}
+		binary.LittleEndian.PutUint16(ret[1:], uint16(v))
+		return ret
+	case v <= 0xFFFFFFFF:
+		ret := []byte{0xFE, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(ret[1:], uint32(v))
+		return ret
+	}
+	ret := []byte{0xFF, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(ret[1:], v)
+	return ret
+}
+
+// 读取变长整数
+func readVarInt(data []byte, index int) (uint64, int, error) {
+	if index >= len(data) {
+		return 0, index, errors.New("Invalid var int data!")
+	}
+	size := 0
+	switch data[index] {
+	case 0xFD:
+		size = 2
+	case 0xFE:
+		size = 4
+	case 0xFF:
+		size = 8
+	default:
+		return uint64(data[index]), index + 1, nil
+	}
+	index++
+	if index+size > len(data) {
+		return 0, index, errors.New("Invalid var int data!")
+	}
+	var v uint64
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint64(data[index+i])
+	}
+	return v, index + size, nil
+}
+
+// 读取变长字节数组
+func readVarBytes(data []byte, index int) ([]byte, int, error) {
+	length, index, err := readVarInt(data, index)
+	if err != nil {
+		return nil, index, err
+	}
+	if uint64(len(data)-index) < length {
+		return nil, index, errors.New("Invalid var bytes data!")
+	}
+	end := index + int(length)
+	return data[index:end], end, nil
+}
@@ -0,0 +1,156 @@
+package neoTransaction
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestTransaction_AddWitness(t *testing.T) {
+	var tx Transaction
+
+	witnesses := make([]TxScript, 0)
+	for i := byte(1); i <= 3; i++ {
+		pub := append([]byte{0x02}, bytes.Repeat([]byte{i}, 32)...)
+		w, err := NewSignatureWitness(pub, bytes.Repeat([]byte{i}, 64))
+		if err != nil {
+			t.Errorf("NewSignatureWitness failed unexpected error: %v\n", err)
+			return
+		}
+		witnesses = append(witnesses, *w)
+	}
+	for i := len(witnesses) - 1; i >= 0; i-- {
+		tx.AddWitness(witnesses[i])
+	}
+	//重复添加相同签名者的见证人会替换
+	tx.AddWitness(witnesses[0])
+
+	if len(tx.Scripts) != 3 {
+		t.Errorf("witness count: %d, expected: 3", len(tx.Scripts))
+		return
+	}
+	for i := 1; i < len(tx.Scripts); i++ {
+		if compareScriptHash(tx.Scripts[i-1].ScriptHash(), tx.Scripts[i].ScriptHash()) >= 0 {
+			t.Errorf("witnesses are not sorted by script hash")
+		}
+	}
+}
+
+func TestDecodeMultiSigWitness(t *testing.T) {
+	//多签验证脚本超过单签长度，序列化后可以还原
+	verification := append([]byte{0x52}, bytes.Repeat([]byte{0x21}, 70)...)
+	verification = append(verification, 0x53, OpCheckMultiSig)
+	w, err := NewMultiSigWitness([][]byte{bytes.Repeat([]byte{1}, 64), bytes.Repeat([]byte{2}, 64)}, verification)
+	if err != nil {
+		t.Errorf("NewMultiSigWitness failed unexpected error: %v\n", err)
+		return
+	}
+
+	data := []byte{0x01}
+	wBytes, _ := w.toBytes()
+	data = append(data, wBytes...)
+
+	scripts, index, err := decodeTxScriptVerificationFromRawTrans(data, 0)
+	if err != nil || index != len(data) || len(scripts) != 1 {
+		t.Errorf("decode witness failed, index: %d, unexpected error: %v\n", index, err)
+		return
+	}
+	if !bytes.Equal(scripts[0].InvocationScript(), w.InvocationScript()) || !bytes.Equal(scripts[0].VerificationScript(), verification) {
+		t.Errorf("decoded witness mismatch")
+	}
+}
+
+func TestSortAddressesByScriptHash(t *testing.T) {
+	addresses := make([]string, 0)
+	witnesses := make([]TxScript, 0)
+	for i := byte(1); i <= 3; i++ {
+		pub := append([]byte{0x02}, bytes.Repeat([]byte{i}, 32)...)
+		w, _ := NewSignatureWitness(pub, bytes.Repeat([]byte{i}, 64))
+		witnesses = append(witnesses, *w)
+		addresses = append(addresses, ScriptHashToAddress(w.ScriptHash()))
+	}
+
+	sorted, err := SortAddressesByScriptHash(append(addresses, addresses[0]))
+	if err != nil || len(sorted) != 3 {
+		t.Errorf("SortAddressesByScriptHash: %v, unexpected error: %v", sorted, err)
+		return
+	}
+
+	//按排序后的地址排列见证人，顺序检查通过
+	byAddress := make(map[string]TxScript)
+	for i, a := range addresses {
+		byAddress[a] = witnesses[i]
+	}
+	ordered := make([]TxScript, 0)
+	for _, a := range sorted {
+		ordered = append(ordered, byAddress[a])
+	}
+	if err := CheckWitnessOrder(ordered); err != nil {
+		t.Errorf("CheckWitnessOrder failed unexpected error: %v\n", err)
+	}
+
+	ordered[0], ordered[1] = ordered[1], ordered[0]
+	if err := CheckWitnessOrder(ordered); err == nil {
+		t.Errorf("out of order witnesses should be rejected")
+	}
+	if err := CheckWitnessOrder([]TxScript{ordered[0], ordered[0]}); err == nil {
+		t.Errorf("duplicate signer witnesses should be rejected")
+	}
+
+	if _, err := SortAddressesByScriptHash([]string{"AVoKVQbfvvZ6ioTQbNrAWjvcmwmrYSjLNq"}); err == nil {
+		t.Errorf("invalid address should be rejected")
+	}
+}
+
+func TestBuildWitness(t *testing.T) {
+	signed := "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac"
+	unsigned := signed[:2*(3+1+34+1+60)] + "00"
+	raw, _ := hex.DecodeString(signed)
+	sig := raw[len(raw)-100 : len(raw)-36]
+	pub := raw[len(raw)-34 : len(raw)-1]
+
+	//外部签名的签名和公钥加上未签名交易即可组装出与原交易一致的已签名交易
+	w, err := BuildWitness(sig, pub)
+	if err != nil {
+		t.Errorf("BuildWitness failed unexpected error: %v\n", err)
+		return
+	}
+	if w.InvocationHex != "40"+hex.EncodeToString(sig) || w.VerificationHex != "21"+hex.EncodeToString(pub)+"ac" {
+		t.Errorf("unexpected witness scripts: %s, %s", w.InvocationHex, w.VerificationHex)
+	}
+	if _, address, _ := CreateSignatureRedeemScript(pub); w.Address != address {
+		t.Errorf("unexpected witness address: %s", w.Address)
+	}
+	assembled, err := AttachWitnesses(unsigned, w)
+	if err != nil || assembled != signed {
+		t.Errorf("AttachWitnesses = %s, unexpected error: %v", assembled, err)
+	}
+	if !VerifyRawTransaction(assembled) {
+		t.Errorf("assembled transaction should verify")
+	}
+
+	//DER编码的签名与r||s签名构建相同的见证人
+	der, _ := asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])})
+	if dw, err := BuildWitness(der, pub); err != nil || dw.InvocationHex != w.InvocationHex {
+		t.Errorf("DER signature should build the same witness, unexpected error: %v", err)
+	}
+
+	//签名与交易不匹配或数据错误时返回错误
+	wrong := append([]byte{}, sig...)
+	wrong[0] ^= 0xff
+	ww, _ := BuildWitness(wrong, pub)
+	if _, err := AttachWitnesses(unsigned, ww); err == nil {
+		t.Errorf("mismatched signature should be rejected")
+	}
+	if _, err := BuildWitness(sig[:63], pub); err == nil {
+		t.Errorf("invalid signature should be rejected")
+	}
+	if _, err := BuildWitness(sig, pub[:32]); err == nil {
+		t.Errorf("invalid pubkey should be rejected")
+	}
+	if _, err := AttachWitnesses(unsigned); err == nil {
+		t.Errorf("attach without witness should be rejected")
+	}
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/imroc/req"
+	"github.com/shopspring/decimal"
+)
+
+//AccountActivity 观测账户在一段区块范围内的出入账汇总
+type AccountActivity struct {
+	SourceKey      string
+	Coin           string
+	DepositCount   int
+	DepositAmount  string
+	WithdrawCount  int
+	WithdrawAmount string
+}
+
+//ActivityHeartbeat 每N个区块发送一次的账户活动汇总，用于轻量对账
+type ActivityHeartbeat struct {
+	Symbol     string
+	FromHeight uint64
+	ToHeight   uint64
+	Accounts   []*AccountActivity
+	CreateAt   int64
+}
+
+//NEOActivityNotificationObject 账户活动汇总被通知对象
+type NEOActivityNotificationObject interface {
+
+	//NEOActivityHeartbeatNotify 账户活动汇总通知
+	//@required
+	NEOActivityHeartbeatNotify(heartbeat *ActivityHeartbeat) error
+}
+
+type activityAmount struct {
+	depositCount   int
+	depositAmount  decimal.Decimal
+	withdrawCount  int
+	withdrawAmount decimal.Decimal
+}
+
+//activityWindow 当前统计窗口的累计数据
+type activityWindow struct {
+	mu         sync.Mutex
+	started    bool
+	fromHeight uint64
+	accounts   map[string]map[string]*activityAmount //sourceKey -> coin -> 汇总
+	seen       map[string]bool                       //已统计的交易，重扫时不重复统计
+}
+
+func newActivityWindow() *activityWindow {
+	return &activityWindow{
+		accounts: make(map[string]map[string]*activityAmount),
+		seen:     make(map[string]bool),
+	}
+}
+
+//AddActivityObserver 添加账户活动汇总观测者
+func (bs *NEOBlockScanner) AddActivityObserver(obj NEOActivityNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if obj == nil {
+		return nil
+	}
+
+	bs.ActivityObservers[obj] = true
+
+	return nil
+}
+
+//RemoveActivityObserver 移除账户活动汇总观测者
+func (bs *NEOBlockScanner) RemoveActivityObserver(obj NEOActivityNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	delete(bs.ActivityObservers, obj)
+
+	return nil
+}
+
+//activityCoin 汇总时区分的币种标识
+func activityCoin(data *openwallet.TxExtractData) string {
+	if data.Transaction == nil {
+		return ""
+	}
+	coin := data.Transaction.Coin
+	if coin.IsContract {
+		return coin.Symbol + ":" + coin.ContractID
+	}
+	return coin.Symbol
+}
+
+//recordActivity 累计已确认交易的账户出入账
+func (bs *NEOBlockScanner) recordActivity(height uint64, extractData map[string]*openwallet.TxExtractData) {
+
+	if bs.wm.config().ActivityHeartbeatBlocks == 0 || height == 0 {
+		return
+	}
+
+	w := bs.activity
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, data := range extractData {
+
+		if data == nil || data.Transaction == nil {
+			continue
+		}
+
+		seenKey := fmt.Sprintf("%s_%s", data.Transaction.TxID, key)
+		if w.seen[seenKey] {
+			continue
+		}
+		w.seen[seenKey] = true
+
+		coins := w.accounts[key]
+		if coins == nil {
+			coins = make(map[string]*activityAmount)
+			w.accounts[key] = coins
+		}
+
+		coin := activityCoin(data)
+		a := coins[coin]
+		if a == nil {
+			a = &activityAmount{depositAmount: decimal.Zero, withdrawAmount: decimal.Zero}
+			coins[coin] = a
+		}
+
+		if len(data.TxOutputs) > 0 {
+			a.depositCount++
+			for _, output := range data.TxOutputs {
+				amount, _ := decimal.NewFromString(output.Amount)
+				a.depositAmount = a.depositAmount.Add(amount)
+			}
+		}
+
+		if len(data.TxInputs) > 0 {
+			a.withdrawCount++
+			for _, input := range data.TxInputs {
+				amount, _ := decimal.NewFromString(input.Amount)
+				a.withdrawAmount = a.withdrawAmount.Add(amount)
+			}
+		}
+	}
+}
+
+//heartbeatActivity 区块高度保存后调用，满N个区块时发送汇总并开启新窗口
+func (bs *NEOBlockScanner) heartbeatActivity(height uint64) {
+
+	blocks := bs.wm.config().ActivityHeartbeatBlocks
+	if blocks == 0 {
+		return
+	}
+
+	w := bs.activity
+	w.mu.Lock()
+
+	if !w.started {
+		w.started = true
+		w.fromHeight = height
+	}
+
+	if height+1 < w.fromHeight+blocks {
+		w.mu.Unlock()
+		return
+	}
+
+	heartbeat := &ActivityHeartbeat{
+		Symbol:     bs.wm.Symbol(),
+		FromHeight: w.fromHeight,
+		ToHeight:   height,
+		Accounts:   make([]*AccountActivity, 0),
+		CreateAt:   time.Now().Unix(),
+	}
+
+	for key, coins := range w.accounts {
+		for coin, a := range coins {
+			heartbeat.Accounts = append(heartbeat.Accounts, &AccountActivity{
+				SourceKey:      key,
+				Coin:           coin,
+				DepositCount:   a.depositCount,
+				DepositAmount:  a.depositAmount.String(),
+				WithdrawCount:  a.withdrawCount,
+				WithdrawAmount: a.withdrawAmount.String(),
+			})
+		}
+	}
+
+	sort.Slice(heartbeat.Accounts, func(i, j int) bool {
+		if heartbeat.Accounts[i].SourceKey != heartbeat.Accounts[j].SourceKey {
+			return heartbeat.Accounts[i].SourceKey < heartbeat.Accounts[j].SourceKey
+		}
+		return heartbeat.Accounts[i].Coin < heartbeat.Accounts[j].Coin
+	})
+
+	//开启新窗口
+	w.fromHeight = height + 1
+	w.accounts = make(map[string]map[string]*activityAmount)
+	w.seen = make(map[string]bool)
+	w.mu.Unlock()
+
+	bs.newActivityNotify(heartbeat)
+}
+
+//newActivityNotify 发送账户活动汇总给观测者及webhook
+func (bs *NEOBlockScanner) newActivityNotify(heartbeat *ActivityHeartbeat) {
+
+	bs.wm.Log.Std.Info("[%s] activity heartbeat from height: %d to height: %d, accounts: %d", heartbeat.Symbol, heartbeat.FromHeight, heartbeat.ToHeight, len(heartbeat.Accounts))
+
+	bs.Mu.RLock()
+	for o := range bs.ActivityObservers {
+		err := o.NEOActivityHeartbeatNotify(heartbeat)
+		if err != nil {
+			bs.wm.Log.Error("NEOActivityHeartbeatNotify unexpected error:", err)
+		}
+	}
+	bs.Mu.RUnlock()
+
+	if len(bs.wm.config().ActivityHeartbeatURL) > 0 {
+		go bs.postActivityHeartbeat(heartbeat)
+	}
+}
+
+//postActivityHeartbeat 以JSON格式推送账户活动汇总到webhook
+func (bs *NEOBlockScanner) postActivityHeartbeat(heartbeat *ActivityHeartbeat) {
+
+	r, err := req.New().Post(bs.wm.config().ActivityHeartbeatURL, req.BodyJSON(heartbeat))
+	if err != nil {
+		bs.wm.Log.Std.Error("post activity heartbeat failed. unexpected error: %v", err)
+		return
+	}
+
+	if code := r.Response().StatusCode; code < 200 || code >= 300 {
+		bs.wm.Log.Std.Error("post activity heartbeat failed. status code: %d", code)
+	}
+}
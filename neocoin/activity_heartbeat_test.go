@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testActivityObserver struct {
+	heartbeats []*ActivityHeartbeat
+}
+
+func (o *testActivityObserver) NEOActivityHeartbeatNotify(heartbeat *ActivityHeartbeat) error {
+	o.heartbeats = append(o.heartbeats, heartbeat)
+	return nil
+}
+
+func TestNEOBlockScanner_HeartbeatActivity(t *testing.T) {
+	posted := make(chan *ActivityHeartbeat, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var heartbeat ActivityHeartbeat
+		json.NewDecoder(r.Body).Decode(&heartbeat)
+		posted <- &heartbeat
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.ActivityHeartbeatBlocks = 2
+	wm.Config.ActivityHeartbeatURL = server.URL
+	bs := wm.Blockscanner
+	observer := &testActivityObserver{}
+	bs.AddActivityObserver(observer)
+
+	newData := func(txid string, deposit, withdraw string) *openwallet.TxExtractData {
+		data := &openwallet.TxExtractData{
+			Transaction: &openwallet.Transaction{TxID: txid, Coin: openwallet.Coin{Symbol: "NEO"}},
+		}
+		if len(deposit) > 0 {
+			output := &openwallet.TxOutPut{}
+			output.Amount = deposit
+			data.TxOutputs = append(data.TxOutputs, output)
+		}
+		if len(withdraw) > 0 {
+			input := &openwallet.TxInput{}
+			input.Amount = withdraw
+			data.TxInputs = append(data.TxInputs, input)
+		}
+		return data
+	}
+
+	bs.recordActivity(10, map[string]*openwallet.TxExtractData{"acc": newData("0x01", "5", "")})
+	bs.heartbeatActivity(10)
+	if len(observer.heartbeats) != 0 {
+		t.Errorf("heartbeat should wait for %d blocks", wm.Config.ActivityHeartbeatBlocks)
+	}
+
+	bs.recordActivity(11, map[string]*openwallet.TxExtractData{"acc": newData("0x02", "3", "2")})
+	//重扫同一交易不重复统计
+	bs.recordActivity(11, map[string]*openwallet.TxExtractData{"acc": newData("0x02", "3", "2")})
+	bs.heartbeatActivity(11)
+
+	if len(observer.heartbeats) != 1 {
+		t.Errorf("heartbeats: %d, expected: 1", len(observer.heartbeats))
+		return
+	}
+
+	heartbeat := observer.heartbeats[0]
+	if heartbeat.FromHeight != 10 || heartbeat.ToHeight != 11 || len(heartbeat.Accounts) != 1 {
+		t.Errorf("unexpected heartbeat: %+v", heartbeat)
+		return
+	}
+
+	a := heartbeat.Accounts[0]
+	if a.SourceKey != "acc" || a.DepositCount != 2 || a.DepositAmount != "8" || a.WithdrawCount != 1 || a.WithdrawAmount != "2" {
+		t.Errorf("unexpected account activity: %+v", a)
+	}
+
+	select {
+	case p := <-posted:
+		if p.ToHeight != 11 || len(p.Accounts) != 1 {
+			t.Errorf("unexpected posted heartbeat: %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("heartbeat should be posted to webhook")
+	}
+
+	//新窗口从下一高度开始
+	bs.heartbeatActivity(12)
+	bs.heartbeatActivity(13)
+	if len(observer.heartbeats) != 2 || observer.heartbeats[1].FromHeight != 12 || len(observer.heartbeats[1].Accounts) != 0 {
+		t.Errorf("unexpected second heartbeat: %+v", observer.heartbeats)
+	}
+}
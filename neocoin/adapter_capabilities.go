@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//AdapterCapabilities 当前配置和节点下生效的可选功能，调用方据此调整流程，不需要试探调用
+type AdapterCapabilities struct {
+	NEP5Extraction     bool     `json:"nep5Extraction"`     //提取NEP-5代币转账
+	TokenContracts     []string `json:"tokenContracts"`     //跟踪的代币合约
+	WebsocketSubscribe bool     `json:"websocketSubscribe"` //通过浏览器socketIO订阅内存池交易
+	MempoolScan        bool     `json:"mempoolScan"`        //扫描内存池交易
+	ExplorerHistory    bool     `json:"explorerHistory"`    //可通过浏览器API查询地址交易历史
+	ClaimableGAS       bool     `json:"claimableGAS"`       //可通过节点钱包提取GAS
+	N3Mode             bool     `json:"n3Mode"`             //NEO N3节点，当前只支持NEO 2.x
+	NodeFlavor         string   `json:"nodeFlavor"`         //节点实现
+}
+
+//Capabilities 获取当前配置和节点下生效的可选功能
+func (wm *WalletManager) Capabilities() *AdapterCapabilities {
+
+	cfg := wm.config()
+
+	caps := &AdapterCapabilities{
+		TokenContracts: make([]string, 0),
+		NodeFlavor:     cfg.NodeFlavor,
+	}
+
+	//停用或未到开始高度的合约不提取
+	if wm.ContractDecoder != nil {
+		height, _ := wm.GetLocalNewBlock()
+		if contracts, err := wm.ActiveTokenContracts(height); err == nil {
+			caps.TokenContracts = contracts
+		}
+	}
+	caps.NEP5Extraction = len(caps.TokenContracts) > 0
+
+	caps.WebsocketSubscribe = cfg.RPCServerType == RPCServerExplorer && wm.FeatureEnabled(FeatureWebsocketSubscriber)
+	caps.MempoolScan = wm.Blockscanner != nil && wm.Blockscanner.IsScanMemPool
+	caps.ExplorerHistory = wm.explorerClient() != nil
+	//claimgas是节点钱包的接口，浏览器API不支持
+	caps.ClaimableGAS = cfg.RPCServerType == RPCServerCore && wm.nodeClient() != nil
+
+	return caps
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalletManager_Capabilities(t *testing.T) {
+	const rpx = "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	caps := wm.Capabilities()
+	if caps.NEP5Extraction || caps.ClaimableGAS || caps.ExplorerHistory || caps.WebsocketSubscribe || caps.N3Mode {
+		t.Errorf("unexpected capabilities without node: %+v", caps)
+	}
+	if caps.NodeFlavor != NodeFlavorNeoCli || !caps.MempoolScan {
+		t.Errorf("unexpected default capabilities: %+v", caps)
+	}
+
+	wm.Config.TokenContracts = []string{rpx}
+	wm.WalletClient = NewClient("http://127.0.0.1:10332", "", false)
+	wm.ExplorerClient = NewExplorer("http://127.0.0.1:3001", false)
+	caps = wm.Capabilities()
+	if !caps.NEP5Extraction || len(caps.TokenContracts) != 1 || !caps.ClaimableGAS || !caps.ExplorerHistory {
+		t.Errorf("unexpected core node capabilities: %+v", caps)
+	}
+
+	//停用的合约不提取
+	wm.SetTokenContractEnabled(rpx, false)
+	wm.Config.RPCServerType = RPCServerExplorer
+	caps = wm.Capabilities()
+	if caps.NEP5Extraction || caps.ClaimableGAS || !caps.WebsocketSubscribe {
+		t.Errorf("unexpected explorer capabilities: %+v", caps)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+//ReorgRecord 扫描时观测到的区块重组
+type ReorgRecord struct {
+	ID       int    `storm:"id,increment"`
+	Height   uint64 //最高的孤块高度
+	Depth    uint64 //回退的区块数量
+	CreateAt int64  `storm:"index"`
+}
+
+//SaveReorgRecord 记录区块重组，并清理超过统计期限的记录
+func (wm *WalletManager) SaveReorgRecord(height, depth uint64) error {
+
+	cfg := wm.config()
+
+	db, err := wm.openLocalDB(cfg.BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now()
+	err = db.Save(&ReorgRecord{Height: height, Depth: depth, CreateAt: now.Unix()})
+	if err != nil {
+		return err
+	}
+
+	err = db.Select(q.Lt("CreateAt", now.Add(-cfg.ReorgHistoryPeriod).Unix())).Delete(&ReorgRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+//GetReorgRecords 获取统计期限内的区块重组记录
+func (wm *WalletManager) GetReorgRecords() ([]*ReorgRecord, error) {
+
+	cfg := wm.config()
+
+	db, err := wm.openLocalDB(cfg.BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var records []*ReorgRecord
+	err = db.Select(q.Gte("CreateAt", time.Now().Add(-cfg.ReorgHistoryPeriod).Unix())).Find(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+//rescanBlockCount 每轮扫描结束后重扫的区块数量，启用自适应时按统计期限内最深的重组多重扫1个区块，
+//并限制在最小和最大数量之间，网络稳定时减少重复扫描
+func (bs *NEOBlockScanner) rescanBlockCount() uint64 {
+
+	cfg := bs.wm.config()
+	if !cfg.AdaptiveRescan {
+		return bs.RescanLastBlockCount
+	}
+
+	count := uint64(0)
+	records, err := bs.wm.GetReorgRecords()
+	if err != nil {
+		bs.wm.Log.Std.Error("get reorg records failed; unexpected error: %v", err)
+		return cfg.RescanMaxBlockCount
+	}
+	for _, r := range records {
+		if r.Depth+1 > count {
+			count = r.Depth + 1
+		}
+	}
+
+	if count < cfg.RescanMinBlockCount {
+		count = cfg.RescanMinBlockCount
+	}
+	if count > cfg.RescanMaxBlockCount {
+		count = cfg.RescanMaxBlockCount
+	}
+	return count
+}
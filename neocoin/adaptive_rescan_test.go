@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNEOBlockScanner_RescanBlockCount(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := NewNEOBlockScanner(wm)
+	bs.RescanLastBlockCount = 3
+
+	//未启用时使用固定数量
+	if count := bs.rescanBlockCount(); count != 3 {
+		t.Errorf("fixed rescan count: %d", count)
+	}
+
+	wm.Config.AdaptiveRescan = true
+	wm.Config.RescanMinBlockCount = 2
+	wm.Config.RescanMaxBlockCount = 6
+
+	//没有重组时使用最小数量
+	if count := bs.rescanBlockCount(); count != 2 {
+		t.Errorf("rescan count without reorg: %d", count)
+	}
+
+	wm.SaveReorgRecord(100, 3)
+	wm.SaveReorgRecord(200, 1)
+	if count := bs.rescanBlockCount(); count != 4 {
+		t.Errorf("rescan count after reorg depth 3: %d", count)
+	}
+
+	//不超过最大数量
+	wm.SaveReorgRecord(300, 10)
+	if count := bs.rescanBlockCount(); count != 6 {
+		t.Errorf("rescan count after reorg depth 10: %d", count)
+	}
+
+	//超过统计期限的重组不计入，并在记录新重组时清理
+	wm.Config.ReorgHistoryPeriod = time.Hour
+	db, _ := wm.openLocalDB(wm.Config.BlockchainFile)
+	db.Drop(&ReorgRecord{})
+	db.Save(&ReorgRecord{Height: 100, Depth: 5, CreateAt: time.Now().Add(-2 * time.Hour).Unix()})
+	db.Close()
+	if count := bs.rescanBlockCount(); count != 2 {
+		t.Errorf("rescan count after history expired: %d", count)
+	}
+	wm.SaveReorgRecord(400, 1)
+	wm.Config.ReorgHistoryPeriod = 24 * time.Hour
+	records, _ := wm.GetReorgRecords()
+	if len(records) != 1 || records[0].Height != 400 {
+		t.Errorf("expired reorg records should be pruned: %d", len(records))
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	AddressAuditOff    = 0 //不校验
+	AddressAuditFull   = 1 //逐个请求validateaddress校验每个地址
+	AddressAuditBatch  = 2 //批量请求validateaddress校验每个地址，节点客户端不支持批量请求时逐个请求
+	AddressAuditSample = 3 //按AddressAuditSampleRate抽样校验，每批至少校验第一个地址
+)
+
+//AddressAuditResult 节点对派生地址的校验结果
+type AddressAuditResult struct {
+	Address string
+	Valid   bool
+	Error   string //请求失败时的错误
+}
+
+//AuditAddresses 按配置的校验模式请求节点validateaddress校验新派生的地址，未开启时返回nil，
+//有地址未通过校验或无法校验时返回ErrAddressAuditFailed错误，用于发现曲线或地址版本配置错误导致派生出节点不认可的充值地址
+func (wm *WalletManager) AuditAddresses(addresses []string) ([]*AddressAuditResult, error) {
+
+	mode := wm.config().AddressAuditMode
+	if mode == AddressAuditOff || len(addresses) == 0 {
+		return nil, nil
+	}
+
+	client := wm.nodeClient()
+	if client == nil || wm.config().RPCServerType == RPCServerExplorer {
+		return nil, wm.errorf(ErrAddressAuditFailed, "address audit requires json-rpc node")
+	}
+
+	checked := addresses
+	if mode == AddressAuditSample {
+		checked = sampleAddresses(addresses, wm.config().AddressAuditSampleRate)
+	}
+
+	var results []*AddressAuditResult
+	if batch, ok := client.(BatchClientInterface); ok && mode == AddressAuditBatch {
+		results = wm.validateAddressesBatch(batch, checked)
+	} else {
+		results = make([]*AddressAuditResult, 0, len(checked))
+		for _, address := range checked {
+			result, err := client.Call("validateaddress", []interface{}{address})
+			results = append(results, newAddressAuditResult(address, result, err))
+		}
+	}
+
+	failed := make([]string, 0)
+	for _, r := range results {
+		if !r.Valid {
+			failed = append(failed, r.Address)
+		}
+	}
+	if len(failed) > 0 {
+		return results, wm.errorf(ErrAddressAuditFailed, "%d of %d audited addresses failed node validation: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+
+	return results, nil
+}
+
+//validateAddressesBatch 按AddressAuditBatchSize分批请求校验
+func (wm *WalletManager) validateAddressesBatch(client BatchClientInterface, addresses []string) []*AddressAuditResult {
+
+	size := wm.config().AddressAuditBatchSize
+	if size <= 0 {
+		size = len(addresses)
+	}
+
+	results := make([]*AddressAuditResult, 0, len(addresses))
+	for start := 0; start < len(addresses); start += size {
+		end := start + size
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		requests := make([][]interface{}, 0, end-start)
+		for _, address := range addresses[start:end] {
+			requests = append(requests, []interface{}{address})
+		}
+
+		batch, err := client.BatchCall("validateaddress", requests)
+		for i, address := range addresses[start:end] {
+			var result *gjson.Result
+			if err == nil && i < len(batch) {
+				result = batch[i]
+			}
+			results = append(results, newAddressAuditResult(address, result, err))
+		}
+	}
+
+	return results
+}
+
+//newAddressAuditResult 解析validateaddress的返回，节点返回的地址与请求不一致也视为未通过
+func newAddressAuditResult(address string, result *gjson.Result, err error) *AddressAuditResult {
+	r := &AddressAuditResult{Address: address}
+	switch {
+	case err != nil:
+		r.Error = err.Error()
+	case result == nil:
+		r.Error = "no result"
+	default:
+		r.Valid = result.Get("isvalid").Bool() && result.Get("address").String() == address
+	}
+	return r
+}
+
+//sampleAddresses 每rate个地址抽取第一个
+func sampleAddresses(addresses []string, rate int) []string {
+	if rate <= 1 {
+		return addresses
+	}
+	sampled := make([]string, 0, len(addresses)/rate+1)
+	for i := 0; i < len(addresses); i += rate {
+		sampled = append(sampled, addresses[i])
+	}
+	return sampled
+}
+
+//auditDerivedAddresses 校验派生的地址，未通过时整批地址不导入也不返回
+func (wm *WalletManager) auditDerivedAddresses(addrs []*openwallet.Address) error {
+
+	addresses := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		addresses = append(addresses, a.Address)
+	}
+
+	results, err := wm.AuditAddresses(addresses)
+	if err != nil {
+		return err
+	}
+	if results != nil {
+		wm.Log.Std.Info("address audit passed, %d of %d derived addresses validated by node", len(results), len(addresses))
+	}
+
+	return nil
+}
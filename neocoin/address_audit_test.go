@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_AuditAddresses(t *testing.T) {
+	var (
+		bad      = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+		requests = 0
+		batches  = 0
+	)
+	validate := func(params []interface{}) map[string]interface{} {
+		return map[string]interface{}{"address": params[0], "isvalid": params[0] != bad}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		var batch []map[string]interface{}
+		if json.Unmarshal(raw, &batch) == nil {
+			batches++
+			resp := make([]map[string]interface{}, 0)
+			for _, b := range batch {
+				resp = append(resp, map[string]interface{}{"id": b["id"], "result": validate(b["params"].([]interface{}))})
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		requests++
+		var body struct {
+			Params []interface{} `json:"params"`
+		}
+		json.Unmarshal(raw, &body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "1", "result": validate(body.Params)})
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	good := []string{"AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT", "AXb8cY7dWgc4NfyLbHzxMhJsEdhWXkMrv3", "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i"}
+	if results, err := wm.AuditAddresses(good); results != nil || err != nil {
+		t.Fatalf("audit disabled should skip, got: %v, %v", results, err)
+	}
+
+	//逐个校验
+	wm.Config.AddressAuditMode = AddressAuditFull
+	results, err := wm.AuditAddresses(good)
+	if err != nil || len(results) != 3 || requests != 3 {
+		t.Fatalf("full audit = %d, %v, requests: %d", len(results), err, requests)
+	}
+	results, err = wm.AuditAddresses(append(good, bad))
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrAddressAuditFailed || results[3].Valid {
+		t.Fatalf("invalid address should fail audit, err: %v", err)
+	}
+
+	//批量校验
+	wm.Config.AddressAuditMode = AddressAuditBatch
+	wm.Config.AddressAuditBatchSize = 2
+	requests = 0
+	if _, err = wm.AuditAddresses(append(good, bad)); err == nil || batches != 2 || requests != 0 {
+		t.Errorf("batch audit should fail in 2 batches, err: %v, batches: %d, requests: %d", err, batches, requests)
+	}
+
+	//抽样校验，未抽到的地址不校验
+	wm.Config.AddressAuditMode = AddressAuditSample
+	wm.Config.AddressAuditSampleRate = 2
+	if results, err = wm.AuditAddresses([]string{good[0], bad, good[1]}); err != nil || len(results) != 2 {
+		t.Errorf("sampled audit = %d, %v", len(results), err)
+	}
+	if _, err = wm.AuditAddresses([]string{bad, good[0]}); err == nil {
+		t.Errorf("sampled invalid address should fail audit")
+	}
+
+	//派生的地址未通过时整批拒绝
+	derived := []*openwallet.Address{{Address: good[0]}, {Address: bad}}
+	wm.Config.AddressAuditMode = AddressAuditFull
+	if err = wm.auditDerivedAddresses(derived); err == nil {
+		t.Errorf("derived addresses should be refused")
+	}
+
+	//节点不可用时无法校验，同样拒绝
+	server.Close()
+	if _, err = wm.AuditAddresses(good[:1]); err == nil {
+		t.Errorf("unreachable node should fail audit")
+	}
+}
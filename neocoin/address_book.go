@@ -0,0 +1,314 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	AddressBookExchange     = "exchange"     //交易所地址
+	AddressBookColdWallet   = "coldWallet"   //冷钱包地址
+	AddressBookCounterparty = "counterparty" //已知交易对手
+
+	//CounterpartiesParam 交易单ExtParam中的交易对手字段
+	CounterpartiesParam = "counterparties"
+)
+
+//AddressBookEntry 地址簿记录，提取交易时按地址标记交易对手
+type AddressBookEntry struct {
+	Address  string `storm:"id" json:"address"`
+	Name     string `json:"name"`                   //交易对手名称
+	Category string `storm:"index" json:"category"` //地址类别
+	Note     string `json:"note,omitempty"`         //备注
+	UpdateAt int64  `json:"updateAt,omitempty"`     //更新时间
+}
+
+//SaveAddressBookEntry 添加或更新地址簿记录
+func (wm *WalletManager) SaveAddressBookEntry(entry *AddressBookEntry) error {
+	return wm.saveAddressBookEntries([]*AddressBookEntry{entry})
+}
+
+//GetAddressBookEntry 获取地址的地址簿记录
+func (wm *WalletManager) GetAddressBookEntry(address string) (*AddressBookEntry, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var entry AddressBookEntry
+	err = db.One("Address", address, &entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+//RemoveAddressBookEntry 删除地址簿记录
+func (wm *WalletManager) RemoveAddressBookEntry(address string) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&AddressBookEntry{Address: address})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	wm.addressBookMu.Lock()
+	wm.addressBook = nil
+	wm.addressBookMu.Unlock()
+
+	return nil
+}
+
+//ListAddressBook 列出地址簿记录，category为空时列出全部，按地址排序
+func (wm *WalletManager) ListAddressBook(category string) ([]*AddressBookEntry, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AddressBookEntry
+	if len(category) == 0 {
+		err = db.All(&list)
+	} else {
+		err = db.Find("Category", category, &list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+
+	return list, nil
+}
+
+//ImportAddressBook 批量导入地址簿，支持CSV（address,name[,category[,note]]，可带表头）
+//和NDJSON（{"address":"","name":"","category":"","note":""}）两种格式，已有的地址覆盖更新，返回导入的记录数量
+func (wm *WalletManager) ImportAddressBook(reader io.Reader) (int, error) {
+
+	r := bufio.NewReader(reader)
+
+	//根据第一个非空白字符判断格式
+	isJSON := false
+	for {
+		b, err := r.Peek(1)
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(string(b)) == "" {
+			r.ReadByte()
+			continue
+		}
+		isJSON = b[0] == '{'
+		break
+	}
+
+	var (
+		entries []*AddressBookEntry
+		err     error
+	)
+	if isJSON {
+		entries, err = readAddressBookNDJSON(r)
+	} else {
+		entries, err = readAddressBookCSV(r)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = wm.saveAddressBookEntries(entries); err != nil {
+		return 0, err
+	}
+
+	wm.Log.Std.Info("address book imported %d entries", len(entries))
+
+	return len(entries), nil
+}
+
+//readAddressBookNDJSON 读取每行一个json对象的地址簿记录
+func readAddressBookNDJSON(r io.Reader) ([]*AddressBookEntry, error) {
+	entries := make([]*AddressBookEntry, 0)
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var entry AddressBookEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode address book record: %d failed, unexpected error: %v", line, err)
+		}
+		if len(entry.Address) == 0 {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+}
+
+//readAddressBookCSV 读取address,name[,category[,note]]格式的地址簿记录
+func readAddressBookCSV(r io.Reader) ([]*AddressBookEntry, error) {
+	entries := make([]*AddressBookEntry, 0)
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read address book line: %d failed, unexpected error: %v", line, err)
+		}
+		address := strings.TrimSpace(record[0])
+		//跳过表头和空行
+		if len(address) == 0 || (line == 1 && strings.EqualFold(address, "address")) {
+			continue
+		}
+		entry := &AddressBookEntry{Address: address}
+		fields := []*string{&entry.Name, &entry.Category, &entry.Note}
+		for i, field := range fields {
+			if len(record) > i+1 {
+				*field = strings.TrimSpace(record[i+1])
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+//saveAddressBookEntries 在同一个数据库事务中保存地址簿记录，并清空内存缓存
+func (wm *WalletManager) saveAddressBookEntries(entries []*AddressBookEntry) error {
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	for _, entry := range entries {
+		if len(entry.Address) == 0 {
+			return fmt.Errorf("address book entry address is empty")
+		}
+		if len(entry.Category) == 0 {
+			entry.Category = AddressBookCounterparty
+		}
+		entry.UpdateAt = time.Now().Unix()
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		if err = tx.Save(entry); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	wm.addressBookMu.Lock()
+	wm.addressBook = nil
+	wm.addressBookMu.Unlock()
+
+	return nil
+}
+
+//loadAddressBook 读取地址簿的内存缓存，缓存为空时从本地数据库加载
+func (wm *WalletManager) loadAddressBook() map[string]*AddressBookEntry {
+
+	wm.addressBookMu.RLock()
+	book := wm.addressBook
+	wm.addressBookMu.RUnlock()
+	if book != nil {
+		return book
+	}
+
+	list, err := wm.ListAddressBook("")
+	if err != nil {
+		wm.Log.Std.Error("load address book failed, unexpected error: %v", err)
+		return nil
+	}
+
+	book = make(map[string]*AddressBookEntry, len(list))
+	for _, entry := range list {
+		book[entry.Address] = entry
+	}
+
+	wm.addressBookMu.Lock()
+	wm.addressBook = book
+	wm.addressBookMu.Unlock()
+
+	return book
+}
+
+//attachCounterparties 按地址簿把交易相关地址的交易对手记录到交易单扩展参数，地址可带":金额"后缀
+func (bs *NEOBlockScanner) attachCounterparties(tx *openwallet.Transaction) {
+
+	book := bs.wm.loadAddressBook()
+	if len(book) == 0 {
+		return
+	}
+
+	counterparties := make(map[string]map[string]string)
+	for _, a := range append(append([]string{}, tx.From...), tx.To...) {
+		address := strings.Split(a, ":")[0]
+		entry, ok := book[address]
+		if !ok {
+			continue
+		}
+		counterparties[address] = map[string]string{
+			"name":     entry.Name,
+			"category": entry.Category,
+		}
+	}
+
+	if len(counterparties) > 0 {
+		tx.SetExtParam(CounterpartiesParam, counterparties)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestWalletManager_AddressBook(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//CSV带表头，缺省类别为交易对手
+	count, err := wm.ImportAddressBook(strings.NewReader(`address,name,category,note
+` + testColdAddress1 + `,Binance,exchange,cold wallet
+` + testColdAddress2 + `,OTC desk
+`))
+	if err != nil || count != 2 {
+		t.Fatalf("import csv address book: %d, unexpected error: %v", count, err)
+	}
+
+	//NDJSON覆盖已有的地址
+	count, err = wm.ImportAddressBook(strings.NewReader(`
+{"address":"` + testColdAddress2 + `","name":"OTC desk B"}
+{"address":"` + testHotAddress + `","name":"Treasury","category":"coldWallet"}
+`))
+	if err != nil || count != 2 {
+		t.Fatalf("import ndjson address book: %d, unexpected error: %v", count, err)
+	}
+
+	entry, err := wm.GetAddressBookEntry(testColdAddress2)
+	if err != nil || entry.Name != "OTC desk B" || entry.Category != AddressBookCounterparty {
+		t.Errorf("unexpected entry: %+v, err: %v", entry, err)
+	}
+
+	list, err := wm.ListAddressBook(AddressBookExchange)
+	if err != nil || len(list) != 1 || list[0].Note != "cold wallet" {
+		t.Errorf("unexpected exchange entries: %v, err: %v", list, err)
+	}
+	list, _ = wm.ListAddressBook("")
+	if len(list) != 3 {
+		t.Errorf("address book entries: %d, expected: 3", len(list))
+	}
+
+	//提取的交易单标记交易对手
+	bs := wm.Blockscanner
+	tx := &openwallet.Transaction{
+		From: []string{testColdAddress1 + ":10"},
+		To:   []string{testHotAddress + ":9", testClaimReceiver + ":1"},
+	}
+	bs.attachCounterparties(tx)
+	if gjson.Get(tx.ExtParam, CounterpartiesParam+"."+testColdAddress1+".name").String() != "Binance" ||
+		gjson.Get(tx.ExtParam, CounterpartiesParam+"."+testHotAddress+".category").String() != AddressBookColdWallet ||
+		gjson.Get(tx.ExtParam, CounterpartiesParam+"."+testClaimReceiver).Exists() {
+		t.Errorf("unexpected counterparties: %s", tx.ExtParam)
+	}
+
+	//删除后不再标记
+	if err := wm.RemoveAddressBookEntry(testColdAddress1); err != nil {
+		t.Errorf("RemoveAddressBookEntry failed unexpected error: %v", err)
+	}
+	tx = &openwallet.Transaction{From: []string{testColdAddress1 + ":10"}}
+	bs.attachCounterparties(tx)
+	if len(tx.ExtParam) > 0 {
+		t.Errorf("removed entry should not be tagged: %s", tx.ExtParam)
+	}
+
+	if err := wm.SaveAddressBookEntry(&AddressBookEntry{Name: "empty"}); err == nil {
+		t.Errorf("entry without address should fail")
+	}
+}
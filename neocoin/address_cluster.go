@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sort"
+)
+
+//AddressCluster 被同一交易单共同花费的观测地址集合
+type AddressCluster struct {
+	ID         int      `json:"id"`
+	Addresses  []string `json:"addresses"`
+	SourceKeys []string `json:"sourceKeys"` //地址所属的账户标记
+	TxIDs      []string `json:"txids"`      //共同花费的交易单
+	Commingled bool     `json:"commingled"` //跨账户共同花费，充值地址可能混入热钱包资金
+}
+
+//AddressClusterReport 地址聚类结果
+type AddressClusterReport struct {
+	FromHeight uint64            `json:"fromHeight"`
+	ToHeight   uint64            `json:"toHeight"`
+	Clusters   []*AddressCluster `json:"clusters"`
+	Membership map[string]int    `json:"membership"` //地址对应的聚类ID
+}
+
+//ClusterOf 地址所属的聚类，不属于任何聚类返回nil
+func (r *AddressClusterReport) ClusterOf(address string) *AddressCluster {
+	id, ok := r.Membership[address]
+	if !ok {
+		return nil
+	}
+	return r.Clusters[id]
+}
+
+//addressUnion 地址并查集
+type addressUnion struct {
+	parent map[string]string
+}
+
+func (u *addressUnion) find(a string) string {
+	if _, ok := u.parent[a]; !ok {
+		u.parent[a] = a
+	}
+	for u.parent[a] != a {
+		u.parent[a] = u.parent[u.parent[a]]
+		a = u.parent[a]
+	}
+	return a
+}
+
+func (u *addressUnion) union(a, b string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u.parent[rb] = ra
+	}
+}
+
+//ClusterAddresses 按共同输入启发式聚类区块高度范围内已保存提取结果中的观测地址，
+//同一交易单的多个输入地址视为同一控制者，只报告包含两个以上地址的聚类
+func (wm *WalletManager) ClusterAddresses(fromHeight, toHeight uint64) (*AddressClusterReport, error) {
+
+	if fromHeight > toHeight {
+		return nil, wm.errorf(ErrBlockHeightInvalid, "from height: %d is greater than to height: %d", fromHeight, toHeight)
+	}
+
+	list, err := wm.GetExtractData(fromHeight, toHeight)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get extract data failed, unexpected error: %v", err)
+	}
+
+	//同一交易单可能按账户拆分成多条提取结果，先按txid合并输入地址
+	txInputs := make(map[string][]string)
+	addressKeys := make(map[string]string)
+	for _, r := range list {
+		if r.Data == nil {
+			continue
+		}
+		for _, input := range r.Data.TxInputs {
+			if len(input.Address) == 0 {
+				continue
+			}
+			txInputs[r.TxID] = append(txInputs[r.TxID], input.Address)
+			addressKeys[input.Address] = r.SourceKey
+		}
+	}
+
+	u := &addressUnion{parent: make(map[string]string)}
+	for _, addrs := range txInputs {
+		for _, a := range addrs[1:] {
+			u.union(addrs[0], a)
+		}
+	}
+
+	//按根节点归集地址和交易单
+	members := make(map[string]map[string]bool)
+	txs := make(map[string]map[string]bool)
+	for txid, addrs := range txInputs {
+		root := u.find(addrs[0])
+		if members[root] == nil {
+			members[root] = make(map[string]bool)
+			txs[root] = make(map[string]bool)
+		}
+		for _, a := range addrs {
+			members[root][a] = true
+		}
+		if len(uniqueStrings(addrs)) > 1 {
+			txs[root][txid] = true
+		}
+	}
+
+	clusters := make([]*AddressCluster, 0)
+	for root, set := range members {
+		if len(set) < 2 {
+			continue
+		}
+		cluster := &AddressCluster{
+			Addresses: sortedKeys(set),
+			TxIDs:     sortedKeys(txs[root]),
+		}
+		keys := make(map[string]bool)
+		for _, a := range cluster.Addresses {
+			keys[addressKeys[a]] = true
+		}
+		cluster.SourceKeys = sortedKeys(keys)
+		cluster.Commingled = len(cluster.SourceKeys) > 1
+		clusters = append(clusters, cluster)
+	}
+
+	//聚类按首个地址排序，保证结果稳定
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Addresses[0] < clusters[j].Addresses[0]
+	})
+
+	report := &AddressClusterReport{
+		FromHeight: fromHeight,
+		ToHeight:   toHeight,
+		Clusters:   clusters,
+		Membership: make(map[string]int),
+	}
+	for i, cluster := range clusters {
+		cluster.ID = i
+		for _, a := range cluster.Addresses {
+			report.Membership[a] = i
+		}
+		if cluster.Commingled {
+			wm.Log.Std.Warning("address cluster: %d co-spends addresses of accounts: %v in txs: %v", i, cluster.SourceKeys, cluster.TxIDs)
+		}
+	}
+
+	return report, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func uniqueStrings(list []string) []string {
+	set := make(map[string]bool)
+	for _, s := range list {
+		set[s] = true
+	}
+	return sortedKeys(set)
+}
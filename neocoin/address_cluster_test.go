@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func testSpendExtractData(txid string, addresses ...string) *openwallet.TxExtractData {
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: txid}
+	for _, a := range addresses {
+		data.TxInputs = append(data.TxInputs, &openwallet.TxInput{Recharge: openwallet.Recharge{TxID: txid, Address: a, Amount: "1"}})
+	}
+	return data
+}
+
+func TestWalletManager_ClusterAddresses(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//A、B在tx1共同花费，B、C在tx2共同花费，聚为一类
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"deposit": testSpendExtractData("tx1", "A", "B")})
+	wm.SaveExtractData(11, map[string]*openwallet.TxExtractData{
+		"deposit": testSpendExtractData("tx2", "B"),
+		"hot":     testSpendExtractData("tx2", "C"),
+	})
+	//D单独花费不聚类，E、F同账户共同花费
+	wm.SaveExtractData(12, map[string]*openwallet.TxExtractData{
+		"deposit": testSpendExtractData("tx3", "D"),
+		"hot":     testSpendExtractData("tx4", "E", "F"),
+	})
+
+	report, err := wm.ClusterAddresses(0, 100)
+	if err != nil {
+		t.Errorf("ClusterAddresses failed unexpected error: %v\n", err)
+		return
+	}
+
+	if len(report.Clusters) != 2 {
+		t.Errorf("unexpected clusters: %+v", report.Clusters)
+		return
+	}
+
+	abc := report.ClusterOf("C")
+	if abc == nil || len(abc.Addresses) != 3 || len(abc.TxIDs) != 2 || !abc.Commingled {
+		t.Errorf("unexpected cluster of C: %+v", abc)
+	}
+	if report.ClusterOf("A") != abc {
+		t.Errorf("A and C should be in the same cluster")
+	}
+
+	ef := report.ClusterOf("E")
+	if ef == nil || ef.Commingled || len(ef.SourceKeys) != 1 || ef.SourceKeys[0] != "hot" {
+		t.Errorf("unexpected cluster of E: %+v", ef)
+	}
+
+	if report.ClusterOf("D") != nil {
+		t.Errorf("D should not be clustered")
+	}
+
+	//只统计高度范围内的提取结果
+	report, _ = wm.ClusterAddresses(11, 11)
+	if len(report.Clusters) != 1 || len(report.Clusters[0].Addresses) != 2 {
+		t.Errorf("unexpected clusters in height 11: %+v", report.Clusters)
+	}
+}
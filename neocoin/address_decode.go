@@ -17,10 +17,10 @@ package neocoin
 
 import (
 	"fmt"
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
 	"github.com/blocktree/openwallet/openwallet"
 
 	"github.com/blocktree/go-owcdrivers/addressEncoder"
-	"github.com/blocktree/go-owcrypt"
 )
 
 func init() {
@@ -56,7 +56,7 @@ func NewAddressDecoder(wm *WalletManager) *addressDecoder {
 func (decoder *addressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (string, error) {
 
 	cfg := NEO_mainnetPrivateWIFCompressed
-	if decoder.wm.Config.IsTestNet {
+	if decoder.wm.config().IsTestNet {
 		cfg = NEO_testnetPrivateWIFCompressed
 	}
 
@@ -75,15 +75,14 @@ func (decoder *addressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (str
 //PublicKeyToAddress 公钥转地址
 func (decoder *addressDecoder) PublicKeyToAddress(pub []byte, isTestnet bool) (string, error) {
 	cfg := NEO_mainnetAddressP2PKH
-	if decoder.wm.Config.IsTestNet {
+	if decoder.wm.config().IsTestNet {
 		cfg = NEO_testnetAddressP2PKH
 	}
 
 	pub = append([]byte{0x21}, pub...)
 	pub = append(pub, 0xac)
 
-	sha256result := owcrypt.Hash(pub, 0, owcrypt.HASH_ALG_SHA256)
-	pkHash := owcrypt.Hash(sha256result, 0, owcrypt.HASH_ALG_RIPEMD160)
+	pkHash := neoTransaction.GetCryptoProvider().Hash160(pub)
 
 	address := addressEncoder.AddressEncode(pkHash, cfg)
 
@@ -94,21 +93,11 @@ func (decoder *addressDecoder) PublicKeyToAddress(pub []byte, isTestnet bool) (s
 //RedeemScriptToAddress 多重签名赎回脚本转地址
 func (decoder *addressDecoder) RedeemScriptToAddress(pubs [][]byte, required uint64, isTestnet bool) (string, error) {
 
-	cfg := addressEncoder.BTC_mainnetAddressP2SH
-	if decoder.wm.Config.IsTestNet {
-		cfg = addressEncoder.BTC_testnetAddressP2SH
-	}
-
-	redeemScript := make([]byte, 0)
-
-	for _, pub := range pubs {
-		redeemScript = append(redeemScript, pub...)
+	_, address, err := neoTransaction.CreateMultiSigRedeemScript(int(required), pubs)
+	if err != nil {
+		return "", err
 	}
 
-	pkHash := owcrypt.Hash(redeemScript, 0, owcrypt.HASH_ALG_HASH160)
-
-	address := addressEncoder.AddressEncode(pkHash, cfg)
-
 	return address, nil
 
 }
@@ -117,7 +106,7 @@ func (decoder *addressDecoder) RedeemScriptToAddress(pubs [][]byte, required uin
 func (decoder *addressDecoder) WIFToPrivateKey(wif string, isTestnet bool) ([]byte, error) {
 
 	cfg := addressEncoder.BTC_mainnetPrivateWIFCompressed
-	if decoder.wm.Config.IsTestNet {
+	if decoder.wm.config().IsTestNet {
 		cfg = addressEncoder.BTC_testnetPrivateWIFCompressed
 	}
 
@@ -132,7 +121,7 @@ func (decoder *addressDecoder) WIFToPrivateKey(wif string, isTestnet bool) ([]by
 
 //ScriptPubKeyToBech32Address scriptPubKey转Bech32地址
 func (decoder *addressDecoder) ScriptPubKeyToBech32Address(scriptPubKey []byte) (string, error) {
-	return scriptPubKeyToBech32Address(scriptPubKey, decoder.wm.Config.IsTestNet)
+	return scriptPubKeyToBech32Address(scriptPubKey, decoder.wm.config().IsTestNet)
 
 }
 
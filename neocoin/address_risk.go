@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//AddressRisk 地址风险筛查结果
+type AddressRisk struct {
+	Address  string
+	Score    float64 //风险分数，越大风险越高
+	Category string  //风险类别
+}
+
+//AddressRiskProvider 地址风险筛查接口，用于接入合规服务
+type AddressRiskProvider interface {
+	CheckAddressRisk(address string) (*AddressRisk, error)
+}
+
+//SetAddressRiskProvider 设置地址风险筛查服务，传入nil关闭筛查
+func (wm *WalletManager) SetAddressRiskProvider(provider AddressRiskProvider) {
+	wm.RiskProvider = provider
+}
+
+//getMaxAddressRisk 查询地址列表中风险最高的地址，地址可带":金额"后缀，查询失败的地址跳过
+func (wm *WalletManager) getMaxAddressRisk(addresses []string) *AddressRisk {
+
+	if wm.RiskProvider == nil {
+		return nil
+	}
+
+	var maxRisk *AddressRisk
+	checked := make(map[string]bool)
+	for _, a := range addresses {
+		address := strings.Split(a, ":")[0]
+		if len(address) == 0 || checked[address] {
+			continue
+		}
+		checked[address] = true
+
+		risk, err := wm.RiskProvider.CheckAddressRisk(address)
+		if err != nil {
+			wm.Log.Std.Warning("address: %s risk check failed; unexpected error: %v", address, err)
+			continue
+		}
+		if risk == nil {
+			continue
+		}
+		if maxRisk == nil || risk.Score > maxRisk.Score {
+			maxRisk = risk
+		}
+	}
+
+	return maxRisk
+}
+
+//attachAddressRisk 把交易相关地址的最高风险记录到交易单扩展参数
+func (bs *NEOBlockScanner) attachAddressRisk(tx *openwallet.Transaction) {
+
+	risk := bs.wm.getMaxAddressRisk(append(append([]string{}, tx.From...), tx.To...))
+	if risk == nil {
+		return
+	}
+
+	tx.SetExtParam("riskAddress", risk.Address)
+	tx.SetExtParam("riskScore", risk.Score)
+	tx.SetExtParam("riskCategory", risk.Category)
+}
+
+//checkWithdrawRisk 创建交易单前筛查接收地址，风险分数达到阈值时拒绝创建
+func (decoder *TransactionDecoder) checkWithdrawRisk(rawTx *openwallet.RawTransaction) error {
+
+	to := make([]string, 0, len(rawTx.To))
+	for addr := range rawTx.To {
+		to = append(to, addr)
+	}
+
+	risk := decoder.wm.getMaxAddressRisk(to)
+	if risk == nil {
+		return nil
+	}
+
+	rawTx.SetExtParam("riskAddress", risk.Address)
+	rawTx.SetExtParam("riskScore", risk.Score)
+	rawTx.SetExtParam("riskCategory", risk.Category)
+
+	threshold := decoder.wm.config().RiskBlockScore
+	if threshold > 0 && risk.Score >= threshold {
+		return decoder.wm.errorf(ErrAddressRiskBlocked, "address: %s risk score: %v category: %s is blocked", risk.Address, risk.Score, risk.Category)
+	}
+
+	return nil
+}
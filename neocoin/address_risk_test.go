@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+type testRiskProvider map[string]*AddressRisk
+
+func (p testRiskProvider) CheckAddressRisk(address string) (*AddressRisk, error) {
+	return p[address], nil
+}
+
+func TestAddressRiskProvider(t *testing.T) {
+	wm := NewWalletManager()
+	wm.SetAddressRiskProvider(testRiskProvider{
+		"mixer":    {Address: "mixer", Score: 90, Category: "mixer"},
+		"exchange": {Address: "exchange", Score: 10, Category: "exchange"},
+	})
+
+	//提取时附加风险
+	tx := &openwallet.Transaction{
+		From: []string{"exchange:1"},
+		To:   []string{"mixer:1", "normal:0.5"},
+	}
+	wm.Blockscanner.attachAddressRisk(tx)
+	if gjson.Get(tx.ExtParam, "riskCategory").String() != "mixer" || gjson.Get(tx.ExtParam, "riskScore").Float() != 90 {
+		t.Errorf("unexpected ext param: %s", tx.ExtParam)
+	}
+
+	//创建交易单时拒绝高风险地址
+	decoder := NewTransactionDecoder(wm)
+	rawTx := &openwallet.RawTransaction{To: map[string]string{"mixer": "1"}}
+	if err := decoder.checkWithdrawRisk(rawTx); err != nil {
+		t.Errorf("risk should not block without threshold")
+	}
+
+	wm.Config.RiskBlockScore = 50
+	err := decoder.checkWithdrawRisk(rawTx)
+	if err == nil || openwallet.ConvertError(err).Code() != ErrAddressRiskBlocked {
+		t.Errorf("high risk address should be blocked, err: %v", err)
+	}
+
+	rawTx = &openwallet.RawTransaction{To: map[string]string{"exchange": "1"}}
+	if err := decoder.checkWithdrawRisk(rawTx); err != nil {
+		t.Errorf("low risk address should not be blocked, err: %v", err)
+	}
+	if gjson.Get(rawTx.ExtParam, "riskCategory").String() != "exchange" {
+		t.Errorf("unexpected ext param: %s", rawTx.ExtParam)
+	}
+}
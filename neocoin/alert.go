@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+)
+
+const (
+	AlertTypeNodeDivergence       = "node_divergence"       //多节点区块hash不一致
+	AlertTypeSlowObserver         = "slow_observer"         //观察者处理通知持续超过耗时预算
+	AlertTypeExplorerDisagreement = "explorer_disagreement" //多个浏览器返回的数据不一致
+)
+
+//Alert 适配器运行中的告警事件
+type Alert struct {
+	Symbol   string
+	Type     string
+	Height   uint64
+	Message  string
+	Details  map[string]string
+	CreateAt int64
+}
+
+func NewAlert(symbol, alertType string, height uint64, message string) *Alert {
+	return &Alert{
+		Symbol:   symbol,
+		Type:     alertType,
+		Height:   height,
+		Message:  message,
+		Details:  make(map[string]string),
+		CreateAt: time.Now().Unix(),
+	}
+}
+
+//NEOAlertNotificationObject 告警事件被通知对象
+type NEOAlertNotificationObject interface {
+
+	//NEOAlertNotify 告警事件通知
+	//@required
+	NEOAlertNotify(alert *Alert) error
+}
+
+//AddAlertObserver 添加告警观测者
+func (bs *NEOBlockScanner) AddAlertObserver(obj NEOAlertNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if obj == nil {
+		return nil
+	}
+
+	bs.AlertObservers[obj] = true
+
+	return nil
+}
+
+//RemoveAlertObserver 移除告警观测者
+func (bs *NEOBlockScanner) RemoveAlertObserver(obj NEOAlertNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	delete(bs.AlertObservers, obj)
+
+	return nil
+}
+
+//newAlertNotify 发送告警事件给观测者
+func (bs *NEOBlockScanner) newAlertNotify(alert *Alert) {
+
+	bs.wm.Log.Std.Warning("[%s] alert: %s, height: %d, %s", alert.Symbol, alert.Type, alert.Height, alert.Message)
+
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+
+	for o := range bs.AlertObservers {
+		err := o.NEOAlertNotify(alert)
+		if err != nil {
+			bs.wm.Log.Error("NEOAlertNotify unexpected error:", err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	AuditActionCreate    = "create"    //创建交易单
+	AuditActionSign      = "sign"      //签名交易单
+	AuditActionBroadcast = "broadcast" //广播交易单
+	AuditActionCancel    = "cancel"    //取消交易单
+	AuditActionSchedule  = "schedule"  //保存定时广播的交易单
+)
+
+//AuditLogEntry 交易审计日志，每条记录包含上一条记录的hash，形成不可篡改的链
+type AuditLogEntry struct {
+	Seq       uint64 `storm:"id" json:"seq"`
+	Action    string `json:"action"`
+	AccountID string `json:"accountID"`
+	TxID      string `json:"txid"`
+	RawHex    string `json:"rawHex"`
+	Detail    string `json:"detail"`
+	CreateAt  int64  `json:"createAt"`
+	PrevHash  string `json:"prevHash"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"` //配置了审计密钥时，hash的HMAC签名
+}
+
+//calcHash 计算记录的hash
+func (e *AuditLogEntry) calcHash() string {
+	plain := fmt.Sprintf("%d|%s|%s|%s|%s|%s|%d|%s", e.Seq, e.Action, e.AccountID, e.TxID, e.RawHex, e.Detail, e.CreateAt, e.PrevHash)
+	hash := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(hash[:])
+}
+
+//calcSignature 使用审计密钥计算hash的HMAC签名
+func (e *AuditLogEntry) calcSignature(key string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(e.Hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//openAuditDB 打开审计日志数据库，与区块数据分开保存
+func (wm *WalletManager) openAuditDB() (*storm.DB, error) {
+	return wm.openLocalDB(wm.config().AuditLogFile)
+}
+
+//AppendAuditLog 追加一条交易审计日志
+func (wm *WalletManager) AppendAuditLog(action, accountID, txid, rawHex, detail string) (*AuditLogEntry, error) {
+
+	wm.auditMu.Lock()
+	defer wm.auditMu.Unlock()
+
+	db, err := wm.openAuditDB()
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "open audit log db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "begin audit log failed, unexpected error: %v", err)
+	}
+	defer tx.Rollback()
+
+	entry := &AuditLogEntry{
+		Seq:       1,
+		Action:    action,
+		AccountID: accountID,
+		TxID:      txid,
+		RawHex:    rawHex,
+		Detail:    detail,
+		CreateAt:  time.Now().Unix(),
+	}
+
+	var last []*AuditLogEntry
+	err = tx.Select().OrderBy("Seq").Reverse().Limit(1).Find(&last)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get last audit log failed, unexpected error: %v", err)
+	}
+	if len(last) > 0 {
+		entry.Seq = last[0].Seq + 1
+		entry.PrevHash = last[0].Hash
+	}
+
+	entry.Hash = entry.calcHash()
+	entry.Signature = entry.calcSignature(wm.config().AuditLogKey)
+
+	err = tx.Save(entry)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "save audit log failed, unexpected error: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "commit audit log failed, unexpected error: %v", err)
+	}
+
+	return entry, nil
+}
+
+//auditTransaction 开启审计日志时记录交易操作，失败只记录错误日志
+func (wm *WalletManager) auditTransaction(action, accountID, txid, rawHex, detail string) {
+
+	if !wm.config().AuditLog {
+		return
+	}
+
+	_, err := wm.AppendAuditLog(action, accountID, txid, rawHex, detail)
+	if err != nil {
+		wm.Log.Std.Error("append audit log action: %s, txid: %s failed; unexpected error: %v", action, txid, err)
+	}
+}
+
+//GetAuditLogs 按顺序获取全部审计日志
+func (wm *WalletManager) GetAuditLogs() ([]*AuditLogEntry, error) {
+
+	db, err := wm.openAuditDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AuditLogEntry
+	err = db.Select().OrderBy("Seq").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//ExportAuditLog 按顺序导出审计日志，每行一条json记录
+func (wm *WalletManager) ExportAuditLog(w io.Writer) error {
+
+	list, err := wm.GetAuditLogs()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, e := range list {
+		err = encoder.Encode(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//VerifyAuditLog 校验审计日志的hash链和签名，返回校验通过的记录数
+func (wm *WalletManager) VerifyAuditLog() (int, error) {
+
+	list, err := wm.GetAuditLogs()
+	if err != nil {
+		return 0, err
+	}
+
+	prevHash := ""
+	for i, e := range list {
+		if e.Seq != uint64(i+1) {
+			return i, fmt.Errorf("audit log seq: %d is not continuous, expected: %d", e.Seq, i+1)
+		}
+		if e.PrevHash != prevHash {
+			return i, fmt.Errorf("audit log seq: %d previous hash mismatch", e.Seq)
+		}
+		if e.calcHash() != e.Hash {
+			return i, fmt.Errorf("audit log seq: %d hash mismatch", e.Seq)
+		}
+		if len(wm.config().AuditLogKey) > 0 && !hmac.Equal([]byte(e.calcSignature(wm.config().AuditLogKey)), []byte(e.Signature)) {
+			return i, fmt.Errorf("audit log seq: %d signature mismatch", e.Seq)
+		}
+		prevHash = e.Hash
+	}
+
+	return len(list), nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/asdine/storm"
+)
+
+func TestWalletManager_AuditLog(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.AuditLogKey = "audit-key"
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	for _, action := range []string{AuditActionCreate, AuditActionSign, AuditActionBroadcast} {
+		_, err := wm.AppendAuditLog(action, "account", "txid", "00ff", "")
+		if err != nil {
+			t.Errorf("AppendAuditLog failed unexpected error: %v\n", err)
+			return
+		}
+	}
+
+	n, err := wm.VerifyAuditLog()
+	if err != nil || n != 3 {
+		t.Errorf("VerifyAuditLog failed, count: %d, unexpected error: %v\n", n, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = wm.ExportAuditLog(&buf)
+	if err != nil {
+		t.Errorf("ExportAuditLog failed unexpected error: %v\n", err)
+		return
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("export lines: %d, expected: 3", lines)
+	}
+
+	//篡改第二条记录
+	db, err := storm.Open(wm.Config.DBPath + "/" + wm.Config.AuditLogFile)
+	if err != nil {
+		t.Errorf("open audit db failed unexpected error: %v\n", err)
+		return
+	}
+	var entry AuditLogEntry
+	db.One("Seq", uint64(2), &entry)
+	entry.RawHex = "11ff"
+	db.Save(&entry)
+	db.Close()
+
+	n, err = wm.VerifyAuditLog()
+	if err == nil || n != 1 {
+		t.Errorf("tampered audit log should fail on seq 2, count: %d, err: %v", n, err)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//BlockDetail 获取区块时返回的数据完整程度，扫描器按各阶段的需要选择最小的程度，减少远程节点的传输
+type BlockDetail int
+
+const (
+	BlockDetailFull   BlockDetail = iota //区块头和完整交易详情，默认
+	BlockDetailTxIDs                     //区块头和交易ID列表，不解析交易详情
+	BlockDetailHeader                    //只有区块头，核心节点使用getblockheader，不传输交易数据
+)
+
+//String 数据完整程度名称
+func (d BlockDetail) String() string {
+	switch d {
+	case BlockDetailFull:
+		return "full"
+	case BlockDetailTxIDs:
+		return "txids"
+	case BlockDetailHeader:
+		return "header"
+	}
+	return "unknown"
+}
+
+//blockDetail 可变参数指定的数据完整程度，未指定时为BlockDetailFull
+func blockDetail(detail []BlockDetail) BlockDetail {
+	if len(detail) == 0 {
+		return BlockDetailFull
+	}
+	return detail[0]
+}
+
+//trimBlock 按数据完整程度裁剪已获取的区块，用于不支持按程度获取的数据源
+func trimBlock(block *Block, detail BlockDetail) *Block {
+	switch detail {
+	case BlockDetailTxIDs:
+		block.TxDetails = make([]*Transaction, 0)
+		block.isVerbose = false
+	case BlockDetailHeader:
+		block.Tx = make([]string, 0)
+		block.TxDetails = make([]*Transaction, 0)
+		block.isVerbose = false
+	}
+	return block
+}
+
+//getBlockByCoreWithDetail 按数据完整程度从核心节点获取区块
+//节点getblock verbose结果已包含交易详情，BlockDetailTxIDs只省去解析，BlockDetailHeader改用getblockheader
+func (wm *WalletManager) getBlockByCoreWithDetail(hash string, detail BlockDetail) (*Block, error) {
+
+	if detail == BlockDetailHeader {
+		result, err := wm.callWithBreaker(wm.readClient(), "getblockheader", []interface{}{hash, 1})
+		if err == nil {
+			err = validateBlockHeaderResult(result)
+		}
+		if err == nil {
+			return trimBlock(NewBlock(result), BlockDetailHeader), nil
+		}
+		//节点不支持或方法已熔断时，获取区块后丢弃交易
+		wm.Log.Std.Debug("get block header: %s failed, fallback to getblock; unexpected error: %v", hash, err)
+	}
+
+	result, err := wm.readClient().Call("getblock", []interface{}{hash, "1"})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateBlockResult(result); err != nil {
+		return nil, err
+	}
+
+	return trimBlock(newBlock(result, detail == BlockDetailFull), detail), nil
+}
+
+//scanBlockDetail 扫描提取交易需要的区块数据，rawBlockParse开启时直接使用交易详情，否则只需要交易ID
+func (bs *NEOBlockScanner) scanBlockDetail() BlockDetail {
+	if bs.wm.config().RPCServerType == RPCServerCore && bs.wm.FeatureEnabled(FeatureRawBlockParse) {
+		return BlockDetailFull
+	}
+	return BlockDetailTxIDs
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestWalletManager_GetBlockDetail(t *testing.T) {
+	var (
+		txid         = testHash("tx")
+		headerCalls  int
+		blockCalls   int
+		headerMethod = true
+	)
+	header := map[string]interface{}{"index": 10, "hash": testHash("block10"), "previousblockhash": testHash("block9"), "time": 1000, "nextconsensus": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockhash":
+			return testHash("block10")
+		case "getblockheader":
+			headerCalls++
+			if !headerMethod {
+				return nil
+			}
+			return header
+		case "getblock":
+			blockCalls++
+			block := map[string]interface{}{"tx": []interface{}{map[string]interface{}{"txid": txid, "vin": []interface{}{}, "vout": []interface{}{}}}}
+			for k, v := range header {
+				block[k] = v
+			}
+			return block
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	full, err := wm.GetBlockByHeight(10)
+	if err != nil || len(full.Tx) != 1 || len(full.TxDetails) != 1 || !full.isVerbose {
+		t.Fatalf("full block = %+v, %v", full, err)
+	}
+
+	ids, err := wm.GetBlock(testHash("block10"), BlockDetailTxIDs)
+	if err != nil || len(ids.Tx) != 1 || ids.Tx[0] != txid || len(ids.TxDetails) != 0 || ids.isVerbose {
+		t.Fatalf("txids block = %+v, %v", ids, err)
+	}
+
+	//只获取区块头时使用getblockheader
+	blockCalls = 0
+	head, err := wm.GetBlock(testHash("block10"), BlockDetailHeader)
+	if err != nil || headerCalls != 1 || blockCalls != 0 || head.Height != 10 || head.NextConsensus == "" || len(head.Tx) != 0 {
+		t.Fatalf("header block = %+v, %v, header calls: %d, block calls: %d", head, err, headerCalls, blockCalls)
+	}
+
+	//节点不支持getblockheader时获取区块后丢弃交易
+	headerMethod = false
+	head, err = wm.GetBlock(testHash("block10"), BlockDetailHeader)
+	if err != nil || blockCalls != 1 || head.Height != 10 || len(head.Tx) != 0 || len(head.TxDetails) != 0 {
+		t.Fatalf("fallback header block = %+v, %v, block calls: %d", head, err, blockCalls)
+	}
+
+	//扫描时按rawBlockParse选择
+	bs := wm.Blockscanner
+	if bs.scanBlockDetail() != BlockDetailTxIDs {
+		t.Errorf("scanner should fetch txids only, got: %s", bs.scanBlockDetail())
+	}
+	wm.SetFeatureFlag(FeatureRawBlockParse, true)
+	if bs.scanBlockDetail() != BlockDetailFull {
+		t.Errorf("scanner should fetch full block with raw block parse, got: %s", bs.scanBlockDetail())
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//maxPendingBlockStats 提取后等待通知的区块统计最大数量，分叉等未通知的统计超过后清空
+const maxPendingBlockStats = 100
+
+//BlockStats 区块全部交易的资产汇总，用于监控链上活跃度
+type BlockStats struct {
+	Symbol         string
+	Height         uint64
+	Hash           string
+	TxCount        int            //交易数量
+	NEOMoved       string         //NEO输出总额
+	GASMoved       string         //GAS输出总额
+	TokenTransfers map[string]int //代币合约ID -> 转账次数
+}
+
+//NEOBlockStatsNotificationObject 区块资产汇总被通知对象
+type NEOBlockStatsNotificationObject interface {
+
+	//NEOBlockStatsNotify 新区块资产汇总通知
+	//@required
+	NEOBlockStatsNotify(header *openwallet.BlockHeader, stats *BlockStats) error
+}
+
+//AddBlockStatsObserver 添加区块资产汇总观测者
+func (bs *NEOBlockScanner) AddBlockStatsObserver(obj NEOBlockStatsNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if obj == nil {
+		return nil
+	}
+
+	bs.BlockStatsObservers[obj] = true
+
+	return nil
+}
+
+//RemoveBlockStatsObserver 移除区块资产汇总观测者
+func (bs *NEOBlockScanner) RemoveBlockStatsObserver(obj NEOBlockStatsNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	delete(bs.BlockStatsObservers, obj)
+
+	return nil
+}
+
+//hasBlockStatsObservers 是否有区块资产汇总观测者，没有时不统计
+func (bs *NEOBlockScanner) hasBlockStatsObservers() bool {
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+	return len(bs.BlockStatsObservers) > 0
+}
+
+//blockStatsBuilder 累计区块交易的资产汇总，提取线程并发添加
+type blockStatsBuilder struct {
+	mu       sync.Mutex
+	stats    *BlockStats
+	neoAsset string
+	gasAsset string
+	neo      decimal.Decimal
+	gas      decimal.Decimal
+}
+
+func (bs *NEOBlockScanner) newBlockStatsBuilder(height uint64, hash string, txCount int) *blockStatsBuilder {
+	cfg := bs.wm.config()
+	return &blockStatsBuilder{
+		stats: &BlockStats{
+			Symbol:         bs.wm.Symbol(),
+			Height:         height,
+			Hash:           hash,
+			TxCount:        txCount,
+			TokenTransfers: make(map[string]int),
+		},
+		neoAsset: strings.ToLower(strings.TrimPrefix(cfg.NEOAssetID, "0x")),
+		gasAsset: strings.ToLower(strings.TrimPrefix(cfg.GASAssetID, "0x")),
+		neo:      decimal.Zero,
+		gas:      decimal.Zero,
+	}
+}
+
+//addTransaction 累计交易输出的NEO和GAS
+func (b *blockStatsBuilder) addTransaction(trx *Transaction) {
+	if trx == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, output := range trx.Vouts {
+		amount, err := decimal.NewFromString(output.Value)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimPrefix(output.Asset, "0x")) {
+		case b.neoAsset:
+			b.neo = b.neo.Add(amount)
+		case b.gasAsset:
+			b.gas = b.gas.Add(amount)
+		}
+	}
+}
+
+//addTokenTransfer 累计有效的代币转账次数
+func (b *blockStatsBuilder) addTokenTransfer(trx *OmniTransaction) {
+	if trx == nil || !trx.Valid {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contractID := openwallet.GenContractID(b.stats.Symbol, common.NewString(trx.PropertyId).String())
+	b.stats.TokenTransfers[contractID]++
+}
+
+func (b *blockStatsBuilder) build() *BlockStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.NEOMoved = b.neo.String()
+	b.stats.GASMoved = b.gas.String()
+	return b.stats
+}
+
+//blockStatsCache 提取时统计的区块资产汇总，通知新区块时取出
+type blockStatsCache struct {
+	mu    sync.Mutex
+	items map[string]*BlockStats //区块hash -> 统计
+}
+
+func newBlockStatsCache() *blockStatsCache {
+	return &blockStatsCache{items: make(map[string]*BlockStats)}
+}
+
+func (c *blockStatsCache) put(stats *BlockStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.items) >= maxPendingBlockStats {
+		c.items = make(map[string]*BlockStats)
+	}
+	c.items[stats.Hash] = stats
+}
+
+func (c *blockStatsCache) take(hash string) *BlockStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.items[hash]
+	delete(c.items, hash)
+	return stats
+}
+
+//collectBlockStats 提取时未统计的区块，如回填时已优先提取，重新获取交易单统计
+func (bs *NEOBlockScanner) collectBlockStats(block *Block) (*BlockStats, error) {
+
+	builder := bs.newBlockStatsBuilder(block.Height, block.Hash, len(block.Tx))
+	for _, txid := range block.Tx {
+		trx, err := bs.wm.GetTransaction(txid)
+		if err != nil {
+			return nil, err
+		}
+		builder.addTransaction(trx)
+
+		if bs.wm.omniEnabled() {
+			omniTrx, _ := bs.wm.GetOmniTransaction(txid)
+			builder.addTokenTransfer(omniTrx)
+		}
+	}
+
+	return builder.build(), nil
+}
+
+//blockStatsNotify 通知新区块的资产汇总给观测者
+func (bs *NEOBlockScanner) blockStatsNotify(block *Block, header *openwallet.BlockHeader) {
+
+	if !bs.hasBlockStatsObservers() {
+		return
+	}
+
+	stats := bs.blockStats.take(block.Hash)
+	if stats == nil {
+		var err error
+		stats, err = bs.collectBlockStats(block)
+		if err != nil {
+			bs.wm.Log.Std.Error("block height: %d collect block stats failed; unexpected error: %v", block.Height, err)
+			return
+		}
+	}
+
+	bs.Mu.RLock()
+	for o := range bs.BlockStatsObservers {
+		err := o.NEOBlockStatsNotify(header, stats)
+		if err != nil {
+			bs.wm.Log.Error("NEOBlockStatsNotify unexpected error:", err)
+		}
+	}
+	bs.Mu.RUnlock()
+}
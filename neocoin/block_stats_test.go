@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testBlockStatsObserver struct {
+	stats []*BlockStats
+}
+
+func (o *testBlockStatsObserver) NEOBlockStatsNotify(header *openwallet.BlockHeader, stats *BlockStats) error {
+	o.stats = append(o.stats, stats)
+	return nil
+}
+
+func TestNEOBlockScanner_BlockStatsNotify(t *testing.T) {
+	const (
+		neo = "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+		gas = "0x602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7"
+	)
+	tx1, tx2 := testHash("tx1"), testHash("tx2")
+	output := func(n int, asset, value string) map[string]interface{} {
+		return map[string]interface{}{"n": n, "asset": asset, "value": value, "address": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}
+	}
+	txs := map[string]interface{}{
+		tx1: map[string]interface{}{"txid": tx1, "vout": []interface{}{output(0, neo, "10"), output(1, gas, "0.5")}},
+		tx2: map[string]interface{}{"txid": tx2, "vout": []interface{}{output(0, neo, "5"), output(1, gas, "1.25")}},
+	}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getrawtransaction":
+			return txs[params[0].(string)]
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	bs := NewNEOBlockScanner(wm)
+
+	observer := &testBlockStatsObserver{}
+	bs.AddBlockStatsObserver(observer)
+
+	//提取时未统计的区块重新获取交易单
+	block := &Block{Height: 100, Hash: testHash("block100"), Tx: []string{tx1, tx2}}
+	bs.newBlockNotify(block, false)
+	if len(observer.stats) != 1 {
+		t.Fatalf("block stats notified: %d", len(observer.stats))
+	}
+	stats := observer.stats[0]
+	if stats.Height != 100 || stats.TxCount != 2 || stats.NEOMoved != "15" || stats.GASMoved != "1.75" {
+		t.Errorf("block stats: %+v", stats)
+	}
+
+	//提取时已统计的区块直接使用
+	builder := bs.newBlockStatsBuilder(101, testHash("block101"), 1)
+	builder.addTokenTransfer(&OmniTransaction{PropertyId: 2, Valid: true})
+	builder.addTokenTransfer(&OmniTransaction{PropertyId: 2, Valid: false})
+	bs.blockStats.put(builder.build())
+	bs.newBlockNotify(&Block{Height: 101, Hash: testHash("block101"), Tx: []string{testHash("unknown")}}, false)
+	if len(observer.stats) != 2 {
+		t.Fatalf("block stats notified: %d", len(observer.stats))
+	}
+	stats = observer.stats[1]
+	if stats.NEOMoved != "0" || stats.TokenTransfers[openwallet.GenContractID(wm.Symbol(), "2")] != 1 {
+		t.Errorf("cached block stats: %+v", stats)
+	}
+
+	//孤块不统计
+	bs.newBlockNotify(block, true)
+	if len(observer.stats) != 2 {
+		t.Errorf("fork block should not notify stats")
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//GetBlockByTime 二分查找区块时间不晚于timestamp的最高区块，
+//可用于按时间截止对账，或按日期初始化起始扫描高度
+func (wm *WalletManager) GetBlockByTime(timestamp uint64) (*Block, error) {
+
+	maxHeight, err := wm.GetBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	getBlock := func(height uint64) (*Block, error) {
+		hash, err := wm.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+		return wm.GetBlock(hash, BlockDetailHeader)
+	}
+
+	first, err := getBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if first.Time > timestamp {
+		return nil, wm.errorf(ErrBlockHeightInvalid, "timestamp: %d is earlier than genesis block time: %d", timestamp, first.Time)
+	}
+
+	//区间[low, high]内查找，low处的区块时间始终不晚于timestamp
+	found := first
+	low, high := uint64(0), maxHeight
+	for low < high {
+		mid := low + (high-low+1)/2
+		block, err := getBlock(mid)
+		if err != nil {
+			return nil, err
+		}
+		if block.Time <= timestamp {
+			low = mid
+			found = block
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return found, nil
+}
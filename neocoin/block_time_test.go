@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWalletManager_GetBlockByTime(t *testing.T) {
+	//区块时间 = 1000 + height * 15
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 1001
+		case "getblockhash":
+			return fmt.Sprintf("0x%064v", params[0])
+		case "getblock":
+			height, _ := strconv.ParseUint(strings.TrimPrefix(params[0].(string), "0x"), 10, 64)
+			return map[string]interface{}{
+				"index":             height,
+				"hash":              params[0],
+				"previousblockhash": fmt.Sprintf("0x%064d", height-1),
+				"time":              1000 + height*15,
+				"tx":                []interface{}{},
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	tests := map[uint64]uint64{
+		1000:  0,
+		1014:  0,
+		1015:  1,
+		8500:  500,
+		8514:  500,
+		16000: 1000,
+		99999: 1000,
+	}
+	for timestamp, height := range tests {
+		block, err := wm.GetBlockByTime(timestamp)
+		if err != nil {
+			t.Errorf("GetBlockByTime failed unexpected error: %v\n", err)
+			return
+		}
+		if block.Height != height {
+			t.Errorf("timestamp: %d, block height: %d, expected: %d", timestamp, block.Height, height)
+		}
+	}
+
+	if _, err := wm.GetBlockByTime(999); err == nil {
+		t.Errorf("timestamp before genesis should fail")
+	}
+}
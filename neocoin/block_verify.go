@@ -0,0 +1,250 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+const (
+	opPushBytes1    = 0x01
+	opPushBytes33   = 0x21
+	opPushBytes64   = 0x40
+	opPush1         = 0x51
+	opPush16        = 0x60
+	opCheckSig      = 0xac
+	opCheckMultiSig = 0xae
+)
+
+//unsignedHeaderData 序列化区块头的未签名部分，与NEO Legacy的BlockBase.SerializeUnsigned一致
+func (b *Block) unsignedHeaderData() ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+
+	prevHash, err := hex.DecodeString(strings.TrimPrefix(b.Previousblockhash, "0x"))
+	if err != nil || len(prevHash) != 32 {
+		return nil, fmt.Errorf("invalid previous block hash: %s", b.Previousblockhash)
+	}
+
+	merkleRoot, err := hex.DecodeString(strings.TrimPrefix(b.Merkleroot, "0x"))
+	if err != nil || len(merkleRoot) != 32 {
+		return nil, fmt.Errorf("invalid merkle root: %s", b.Merkleroot)
+	}
+
+	consensusData, err := strconv.ParseUint(b.Nonce, 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consensus data: %s", b.Nonce)
+	}
+
+	nextConsensus, err := addressToScriptHash(b.NextConsensus)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.Write(buf, binary.LittleEndian, uint32(b.Version))
+	buf.Write(reverseBytes(prevHash))
+	buf.Write(reverseBytes(merkleRoot))
+	binary.Write(buf, binary.LittleEndian, uint32(b.Time))
+	binary.Write(buf, binary.LittleEndian, uint32(b.Height))
+	binary.Write(buf, binary.LittleEndian, consensusData)
+	buf.Write(nextConsensus)
+
+	return buf.Bytes(), nil
+}
+
+//VerifyBlockWitness 验证区块头的hash和共识节点多签见证，
+//prevNextConsensus是上一区块记录的下一轮共识节点地址
+func VerifyBlockWitness(block *Block, prevNextConsensus string) error {
+
+	if block == nil {
+		return fmt.Errorf("block is nil")
+	}
+
+	data, err := block.unsignedHeaderData()
+	if err != nil {
+		return err
+	}
+
+	//区块hash必须由区块头计算得出
+	hash := "0x" + hex.EncodeToString(reverseBytes(neoTransaction.GetCryptoProvider().DoubleSHA256(data)))
+	if !strings.EqualFold(hash, block.Hash) {
+		return fmt.Errorf("block hash: %s mismatch header hash: %s", block.Hash, hash)
+	}
+
+	verification, err := hex.DecodeString(block.Verification)
+	if err != nil {
+		return fmt.Errorf("invalid verification script: %v", err)
+	}
+
+	invocation, err := hex.DecodeString(block.Invocation)
+	if err != nil {
+		return fmt.Errorf("invalid invocation script: %v", err)
+	}
+
+	//验证脚本必须是上一区块指定的共识节点
+	expected, err := addressToScriptHash(prevNextConsensus)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(neoTransaction.GetCryptoProvider().Hash160(verification), expected) {
+		return fmt.Errorf("block verification script is not signed by consensus: %s", prevNextConsensus)
+	}
+
+	m, pubkeys, err := parseVerificationScript(verification)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := parseInvocationScript(invocation)
+	if err != nil {
+		return err
+	}
+
+	if len(signatures) < m {
+		return fmt.Errorf("block signatures: %d less than required: %d", len(signatures), m)
+	}
+
+	message := neoTransaction.GetCryptoProvider().SHA256(data)
+	if !verifyMultiSigSignatures(message, m, pubkeys, signatures) {
+		return fmt.Errorf("block witness signatures verify failed")
+	}
+
+	return nil
+}
+
+//verifyMultiSigSignatures 签名与公钥按顺序匹配，与CHECKMULTISIG一致
+func verifyMultiSigSignatures(message []byte, m int, pubkeys, signatures [][]byte) bool {
+	i, j := 0, 0
+	for i < m && i < len(signatures) && j < len(pubkeys) {
+		if neoTransaction.VerifySignature(message, pubkeys[j], signatures[i]) {
+			i++
+		}
+		j++
+		if m-i > len(pubkeys)-j {
+			break
+		}
+	}
+	return i >= m
+}
+
+//parseVerificationScript 解析单签或多签验证脚本，返回需要的签名数和公钥
+func parseVerificationScript(script []byte) (int, [][]byte, error) {
+
+	//单签: PUSHBYTES33 <pubkey> CHECKSIG
+	if len(script) == 35 && script[0] == opPushBytes33 && script[34] == opCheckSig {
+		return 1, [][]byte{script[1:34]}, nil
+	}
+
+	if len(script) < 2 || script[len(script)-1] != opCheckMultiSig {
+		return 0, nil, fmt.Errorf("unsupported verification script")
+	}
+
+	pos := 0
+	m, pos, err := readPushInt(script, pos)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pubkeys := make([][]byte, 0)
+	for pos < len(script) && script[pos] == opPushBytes33 {
+		if pos+34 > len(script) {
+			return 0, nil, fmt.Errorf("invalid verification script")
+		}
+		pubkeys = append(pubkeys, script[pos+1:pos+34])
+		pos += 34
+	}
+
+	n, pos, err := readPushInt(script, pos)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if pos != len(script)-1 || n != len(pubkeys) || m < 1 || m > n {
+		return 0, nil, fmt.Errorf("invalid multisig verification script")
+	}
+
+	return m, pubkeys, nil
+}
+
+//readPushInt 读取脚本中压入的小整数
+func readPushInt(script []byte, pos int) (int, int, error) {
+	if pos >= len(script) {
+		return 0, pos, fmt.Errorf("invalid verification script")
+	}
+	op := script[pos]
+	if op >= opPush1 && op <= opPush16 {
+		return int(op-opPush1) + 1, pos + 1, nil
+	}
+	if op == opPushBytes1 && pos+1 < len(script) {
+		return int(script[pos+1]), pos + 2, nil
+	}
+	return 0, pos, fmt.Errorf("invalid verification script")
+}
+
+//parseInvocationScript 解析调用脚本中的签名
+func parseInvocationScript(script []byte) ([][]byte, error) {
+	signatures := make([][]byte, 0)
+	for pos := 0; pos < len(script); pos += 65 {
+		if script[pos] != opPushBytes64 || pos+65 > len(script) {
+			return nil, fmt.Errorf("invalid invocation script")
+		}
+		signatures = append(signatures, script[pos+1:pos+65])
+	}
+	return signatures, nil
+}
+
+//addressToScriptHash 地址解码为脚本hash
+func addressToScriptHash(address string) ([]byte, error) {
+	_, hash, err := neoTransaction.DecodeCheck(address)
+	if err != nil || len(hash) != 20 {
+		return nil, fmt.Errorf("invalid consensus address: %s", address)
+	}
+	return hash, nil
+}
+
+func reverseBytes(s []byte) []byte {
+	r := make([]byte, len(s))
+	for i := range s {
+		r[i] = s[len(s)-1-i]
+	}
+	return r
+}
+
+//verifyBlock 开启区块签名验证时，检查区块是否由上一区块指定的共识节点签名
+func (bs *NEOBlockScanner) verifyBlock(block *Block) error {
+
+	if !bs.wm.config().VerifyBlockSignature || block.Height == 0 {
+		return nil
+	}
+
+	//优先使用本地已验证的上一区块
+	prevBlock, err := bs.wm.GetLocalBlock(block.Height - 1)
+	if err != nil || prevBlock.Hash != block.Previousblockhash || len(prevBlock.NextConsensus) == 0 {
+		prevBlock, err = bs.wm.GetBlock(block.Previousblockhash, BlockDetailHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return VerifyBlockWitness(block, prevBlock.NextConsensus)
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/go-owcrypt"
+)
+
+//testSignedBlock 构造由m-of-n共识节点签名的区块
+func testSignedBlock(t *testing.T, m, n int) (*Block, string) {
+
+	prikeys := make([][]byte, 0)
+	verification := []byte{byte(opPush1 + m - 1)}
+	for i := 0; i < n; i++ {
+		prikey := owcrypt.Hash([]byte{byte(i + 1)}, 0, owcrypt.HASH_ALG_SHA256)
+		pubkey, _ := owcrypt.GenPubkey(prikey, owcrypt.ECC_CURVE_SECP256R1)
+		prikeys = append(prikeys, prikey)
+		verification = append(verification, opPushBytes33)
+		verification = append(verification, owcrypt.PointCompress(pubkey, owcrypt.ECC_CURVE_SECP256R1)...)
+	}
+	verification = append(verification, byte(opPush1+n-1), opCheckMultiSig)
+
+	consensus := neoTransaction.EncodeCheck([]byte{0x17}, owcrypt.Hash(verification, 0, owcrypt.HASH_ALG_HASH160))
+
+	block := &Block{
+		Height:            100,
+		Version:           0,
+		Time:              1540000000,
+		Previousblockhash: "0x" + strings.Repeat("11", 32),
+		Merkleroot:        "0x" + strings.Repeat("22", 32),
+		Nonce:             "6c5b2f1e3d4a0918",
+		NextConsensus:     consensus,
+		Verification:      hex.EncodeToString(verification),
+	}
+
+	data, err := block.unsignedHeaderData()
+	if err != nil {
+		t.Fatalf("unsignedHeaderData failed unexpected error: %v\n", err)
+	}
+	block.Hash = "0x" + hex.EncodeToString(reverseBytes(owcrypt.Hash(data, 0, owcrypt.HASh_ALG_DOUBLE_SHA256)))
+
+	message := owcrypt.Hash(data, 0, owcrypt.HASH_ALG_SHA256)
+	invocation := make([]byte, 0)
+	for i := 0; i < m; i++ {
+		sig, _ := owcrypt.Signature(prikeys[i], nil, 0, message, 32, owcrypt.ECC_CURVE_SECP256R1)
+		invocation = append(invocation, opPushBytes64)
+		invocation = append(invocation, sig...)
+	}
+	block.Invocation = hex.EncodeToString(invocation)
+
+	return block, consensus
+}
+
+func TestVerifyBlockWitness(t *testing.T) {
+	block, consensus := testSignedBlock(t, 3, 4)
+
+	err := VerifyBlockWitness(block, consensus)
+	if err != nil {
+		t.Errorf("VerifyBlockWitness failed unexpected error: %v\n", err)
+		return
+	}
+
+	//伪造的共识节点
+	other, _ := testSignedBlock(t, 1, 1)
+	err = VerifyBlockWitness(block, other.NextConsensus)
+	if err == nil {
+		t.Errorf("block signed by other consensus should be rejected")
+	}
+
+	//篡改区块头
+	block.Merkleroot = "0x" + strings.Repeat("33", 32)
+	err = VerifyBlockWitness(block, consensus)
+	if err == nil {
+		t.Errorf("tampered block header should be rejected")
+	}
+
+	//签名不足
+	block, consensus = testSignedBlock(t, 3, 4)
+	block.Invocation = block.Invocation[:130*2]
+	err = VerifyBlockWitness(block, consensus)
+	if err == nil {
+		t.Errorf("block without enough signatures should be rejected")
+	}
+}
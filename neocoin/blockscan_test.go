@@ -21,8 +21,13 @@ import (
 	"github.com/blocktree/openwallet/log"
 	"github.com/blocktree/openwallet/openwallet"
 	"github.com/pborman/uuid"
+	bolt "go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetNEOBlockHeight(t *testing.T) {
@@ -324,3 +329,544 @@ func TestGetCurrentBlockHashAndHeight(t *testing.T) {
 
 	t.Logf(" block height : %d ", block.Height)
 }
+
+func TestWalletManager_DeleteLocalDataAboveHeight(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	for i := uint64(1); i <= 5; i++ {
+		wm.SaveLocalBlock(&Block{Height: i, Hash: fmt.Sprintf("hash%d", i)})
+		wm.Blockscanner.SaveUnscanRecord(NewUnscanRecord(i, "", "test"))
+	}
+
+	err := wm.DeleteLocalDataAboveHeight(3)
+	if err != nil {
+		t.Errorf("DeleteLocalDataAboveHeight failed unexpected error: %v\n", err)
+		return
+	}
+
+	if _, err := wm.GetLocalBlock(3); err != nil {
+		t.Errorf("block 3 should be kept")
+	}
+
+	if _, err := wm.GetLocalBlock(4); err == nil {
+		t.Errorf("block 4 should be deleted")
+	}
+
+	records, _ := wm.GetUnscanRecords()
+	if len(records) != 3 {
+		t.Errorf("unscan records count: %d, expected: 3", len(records))
+	}
+}
+
+type testReplayObserver struct {
+	notified []string
+	data     []*openwallet.TxExtractData
+}
+
+func (o *testReplayObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+func (o *testReplayObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	o.notified = append(o.notified, sourceKey+":"+data.Transaction.TxID)
+	o.data = append(o.data, data)
+	return nil
+}
+
+func TestNEOBlockScanner_ReplayExtractData(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	for i := uint64(1); i <= 5; i++ {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: fmt.Sprintf("tx%d", i)}
+		wm.SaveExtractData(i, map[string]*openwallet.TxExtractData{"account": data})
+	}
+
+	observer := &testReplayObserver{}
+	err := wm.Blockscanner.ReplayExtractData(2, 4, observer)
+	if err != nil {
+		t.Errorf("ReplayExtractData failed unexpected error: %v\n", err)
+		return
+	}
+
+	if len(observer.notified) != 3 || observer.notified[0] != "account:tx2" {
+		t.Errorf("ReplayExtractData notified: %v", observer.notified)
+	}
+}
+
+func TestNEOBlockScanner_ExtractDataSequence(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	for i := uint64(1); i <= 5; i++ {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: fmt.Sprintf("tx%d", i)}
+		other := openwallet.NewBlockExtractData()
+		other.Transaction = &openwallet.Transaction{TxID: fmt.Sprintf("tx%d", i)}
+		extractData := map[string]*openwallet.TxExtractData{"account": data}
+		if i%2 == 0 {
+			extractData["other"] = other
+		}
+		wm.SaveExtractData(i, extractData)
+
+		//每个sourceKey的序号单调递增
+		if ExtractDataSequence(data) != i {
+			t.Errorf("tx%d sequence: %d", i, ExtractDataSequence(data))
+		}
+		if i%2 == 0 && ExtractDataSequence(other) != i/2 {
+			t.Errorf("tx%d other sequence: %d", i, ExtractDataSequence(other))
+		}
+	}
+
+	//重扫同一交易沿用已分配的序号
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "tx3"}
+	wm.SaveExtractData(3, map[string]*openwallet.TxExtractData{"account": data})
+	if ExtractDataSequence(data) != 3 {
+		t.Errorf("rescanned tx sequence: %d", ExtractDataSequence(data))
+	}
+	if last, _ := wm.GetLastExtractDataSequence("account"); last != 5 {
+		t.Errorf("last sequence: %d", last)
+	}
+
+	observer := &testReplayObserver{}
+	err := wm.Blockscanner.ReplayExtractDataBySequence("account", 2, 4, observer)
+	if err != nil {
+		t.Errorf("ReplayExtractDataBySequence failed unexpected error: %v\n", err)
+		return
+	}
+	if len(observer.notified) != 3 || observer.notified[0] != "account:tx2" || observer.notified[2] != "account:tx4" {
+		t.Errorf("ReplayExtractDataBySequence notified: %v", observer.notified)
+	}
+	if ExtractDataSequence(observer.data[1]) != 3 {
+		t.Errorf("replayed data should carry sequence")
+	}
+}
+
+func TestWalletManager_PruneExtractData(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	for i := uint64(1); i <= 10; i++ {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: fmt.Sprintf("tx%d", i)}
+		wm.SaveExtractData(i, map[string]*openwallet.TxExtractData{"account": data})
+	}
+	wm.SaveLocalNewBlock(10, "0x0a")
+
+	//保留本地高度往前3个区块
+	count, err := wm.PruneExtractData(3)
+	if err != nil || count != 6 {
+		t.Errorf("PruneExtractData count: %d, unexpected error: %v", count, err)
+	}
+	list, _ := wm.GetExtractData(0, 10)
+	if len(list) != 4 || list[0].BlockHeight != 7 {
+		t.Errorf("remaining extract data: %d", len(list))
+	}
+
+	//序号不受清理影响
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "tx11"}
+	wm.SaveExtractData(11, map[string]*openwallet.TxExtractData{"account": data})
+	if ExtractDataSequence(data) != 11 {
+		t.Errorf("sequence after prune: %d", ExtractDataSequence(data))
+	}
+
+	if count, _ := wm.PruneExtractData(0); count != 0 {
+		t.Errorf("zero retention should keep all records, pruned: %d", count)
+	}
+}
+
+func TestNEOBlockScanner_NewBlockExtractDataNotify(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	observer := &testReplayObserver{}
+	wm.Blockscanner.AddObserver(observer)
+
+	//区块内的交易一次保存，按顺序分配序号后再通知
+	batch := make([]map[string]*openwallet.TxExtractData, 0)
+	for i := 1; i <= 3; i++ {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: fmt.Sprintf("tx%d", i)}
+		batch = append(batch, map[string]*openwallet.TxExtractData{"account": data}, map[string]*openwallet.TxExtractData{})
+	}
+	if failed := wm.Blockscanner.newBlockExtractDataNotify(10, batch); failed != 0 {
+		t.Errorf("newBlockExtractDataNotify failed: %d", failed)
+	}
+
+	if len(observer.data) != 3 || observer.notified[0] != "account:tx1" || observer.notified[2] != "account:tx3" {
+		t.Errorf("block extract data notified: %v", observer.notified)
+		return
+	}
+	for i, data := range observer.data {
+		if ExtractDataSequence(data) != uint64(i+1) {
+			t.Errorf("tx%d sequence: %d", i+1, ExtractDataSequence(data))
+		}
+	}
+
+	list, _ := wm.GetExtractData(10, 10)
+	if len(list) != 3 {
+		t.Errorf("saved extract data: %d", len(list))
+	}
+}
+
+func TestNEOBlockScanner_RollbackExtractData(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "tx1", Amount: "1.5", WxID: "wx1", BlockHash: "0x0a"}
+	output := &openwallet.TxOutPut{}
+	output.Amount = "1.5"
+	output.Sid = "sid1"
+	data.TxOutputs = append(data.TxOutputs, output)
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": data})
+
+	observer := &testReplayObserver{}
+	wm.Blockscanner.AddObserver(observer)
+	wm.Blockscanner.rollbackExtractData(10)
+
+	if len(observer.data) != 1 {
+		t.Errorf("rollback notified: %v", observer.notified)
+		return
+	}
+	rollback := observer.data[0]
+	if rollback.Transaction.Amount != "-1.5" || rollback.Transaction.TxAction != TxActionRollback {
+		t.Errorf("unexpected rollback transaction: %+v", rollback.Transaction)
+	}
+	if !rollback.TxOutputs[0].Delete || rollback.TxOutputs[0].Amount != "-1.5" {
+		t.Errorf("unexpected rollback output: %+v", rollback.TxOutputs[0])
+	}
+	//回滚数据的ID与原数据不同，消费者按ID去重时不会丢弃
+	if rollback.Transaction.WxID == "wx1" || rollback.TxOutputs[0].Sid == "sid1" {
+		t.Errorf("rollback should have its own ids, wxid: %s, sid: %s", rollback.Transaction.WxID, rollback.TxOutputs[0].Sid)
+	}
+
+	list, _ := wm.GetExtractData(10, 10)
+	if len(list) != 0 {
+		t.Errorf("orphaned extract data should be deleted")
+	}
+}
+
+func TestNEOBlockScanner_RollbackExtractDataOutbox(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.NotifyOutbox = true
+
+	observer := &testNamedObserver{name: "offline", fail: true}
+	wm.Blockscanner.AddObserver(observer)
+
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "tx1", Amount: "1.5", WxID: "wx1", BlockHash: "0x0a"}
+	wm.Blockscanner.newExtractDataNotify(10, map[string]*openwallet.TxExtractData{"account": data})
+	wm.Blockscanner.rollbackExtractData(10)
+
+	//回滚通知单独记录投递状态，不覆盖原通知
+	pending, _ := wm.GetNotifyDeliveries("offline", NotifyDeliveryPending)
+	if len(pending) != 2 {
+		t.Errorf("pending deliveries: %d, want 2", len(pending))
+		return
+	}
+	actions := make(map[string]bool)
+	for _, d := range pending {
+		actions[d.Data.Transaction.TxAction] = true
+	}
+	if !actions[TxActionRollback] || len(actions) != 2 {
+		t.Errorf("rollback should be recorded beside the original delivery: %v", actions)
+	}
+
+	//观察者恢复后补发回滚通知
+	observer.fail = false
+	delivered, err := wm.Blockscanner.RedeliverNotifications(0)
+	if err != nil || delivered != 2 {
+		t.Errorf("RedeliverNotifications delivered: %d, unexpected error: %v", delivered, err)
+	}
+}
+
+func TestNEOBlockScanner_WatchAddressFilter(t *testing.T) {
+	bs := NewNEOBlockScanner(tw)
+	called := 0
+	scanAddressFunc := func(address string) (string, bool) {
+		called++
+		return "account", address == "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	}
+
+	bs.SetWatchAddressFilter("AGofsxAUDwt52KjaB664GYsqVAkULYvKNt")
+	filterFunc := bs.filterScanAddressFunc(scanAddressFunc)
+
+	if _, ok := filterFunc("AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"); !ok {
+		t.Errorf("watched address should be matched")
+	}
+
+	if _, ok := filterFunc(""); ok {
+		t.Errorf("empty address should not be matched")
+	}
+
+	bs.AddWatchAddressToFilter("AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC")
+	filterFunc("AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC")
+	if called != 2 {
+		t.Errorf("scanAddressFunc called: %d, expected: 2", called)
+	}
+}
+
+func TestNEOBlockScanner_ImportWatchAddresses(t *testing.T) {
+	bs := NewWalletManager().Blockscanner
+	bs.SetWatchAddressFilter("AGofsxAUDwt52KjaB664GYsqVAkULYvKNt")
+
+	csvData := "address,account\nAXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC,acc1\n\nAGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT\n"
+	count, err := bs.ImportWatchAddresses(strings.NewReader(csvData))
+	if err != nil || count != 2 {
+		t.Errorf("ImportWatchAddresses csv count: %d, err: %v", count, err)
+		return
+	}
+
+	jsonData := `
+{"address":"AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y","account":"acc2"}
+{"address":"AVoKVQbfvvZ6ioTQbNrAWjvcmwmrYSjLNq"}
+`
+	count, err = bs.ImportWatchAddresses(strings.NewReader(jsonData))
+	if err != nil || count != 2 {
+		t.Errorf("ImportWatchAddresses ndjson count: %d, err: %v", count, err)
+		return
+	}
+
+	called := 0
+	filterFunc := bs.filterScanAddressFunc(func(address string) (string, bool) {
+		called++
+		return "scanned", true
+	})
+
+	if account, ok := filterFunc("AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC"); !ok || account != "acc1" {
+		t.Errorf("imported address should belong to acc1, got: %s", account)
+	}
+	if account, ok := filterFunc("AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"); !ok || account != "acc2" {
+		t.Errorf("imported address should belong to acc2, got: %s", account)
+	}
+	if called != 0 {
+		t.Errorf("tagged address should not call scanAddressFunc")
+	}
+
+	//无账户标记和之前设置的地址仍由ScanAddressFunc判断
+	filterFunc("AVoKVQbfvvZ6ioTQbNrAWjvcmwmrYSjLNq")
+	filterFunc("AGofsxAUDwt52KjaB664GYsqVAkULYvKNt")
+	if called != 2 {
+		t.Errorf("scanAddressFunc called: %d, expected: 2", called)
+	}
+
+	if _, err = bs.ImportWatchAddresses(strings.NewReader("{bad json")); err == nil {
+		t.Errorf("invalid ndjson should fail")
+	}
+}
+
+func TestNEOBlockScanner_ExtractNonstandardOutput(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.NonstandardOutputPolicy = NonstandardOutputRecord
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	trx := &Transaction{
+		TxID:        "0x28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d",
+		BlockHeight: 100,
+		Vins:        make([]*Vin, 0),
+		Vouts: []*Vout{
+			{N: 0, Addr: "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT", Value: "100"},
+			{N: 1, Value: "0", ScriptPubKey: "6a0568656c6c6f"},
+		},
+	}
+
+	result := ExtractResult{
+		TxID:        trx.TxID,
+		extractData: make(map[string]*openwallet.TxExtractData),
+	}
+
+	to, total := wm.Blockscanner.extractTxOutput(trx, &result, func(address string) (string, bool) {
+		return "", false
+	})
+
+	if len(to) != 1 || total.String() != "100" {
+		t.Errorf("extractTxOutput to: %v, total: %s", to, total.String())
+	}
+
+	outputs, err := wm.GetNonstandardOutputs(trx.TxID)
+	if err != nil {
+		t.Errorf("GetNonstandardOutputs failed unexpected error: %v\n", err)
+		return
+	}
+
+	if len(outputs) != 1 || outputs[0].ScriptPubKey != "6a0568656c6c6f" {
+		t.Errorf("nonstandard outputs: %v", outputs)
+	}
+}
+
+func TestNEOBlockScanner_ScanBlockTaskSaveFailed(t *testing.T) {
+	var holder *storm.DB
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.DBLockTimeout = 50 * time.Millisecond
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 4
+		case "getblockhash":
+			return fmt.Sprintf("0x%064v", params[0])
+		case "getblock":
+			//读取区块后数据库被其他进程占用，保存高度失败
+			if holder == nil {
+				holder, _ = storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+			}
+			return map[string]interface{}{
+				"index":             2,
+				"hash":              params[0],
+				"previousblockhash": fmt.Sprintf("0x%064d", 1),
+				"time":              1000,
+				"tx":                []interface{}{},
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.SaveLocalNewBlock(1, fmt.Sprintf("0x%064d", 1))
+
+	bs := wm.Blockscanner
+	bs.Scanning = true
+	bs.ScanBlockTask()
+
+	if holder == nil {
+		t.Errorf("block should be fetched")
+		return
+	}
+	holder.Close()
+
+	height, _ := wm.GetLocalNewBlock()
+	if height != 1 {
+		t.Errorf("local height should not advance when save failed, height: %d", height)
+	}
+}
+
+func TestNEOBlockScanner_ScanBlockTaskSaveBlockFailed(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 3
+		case "getblockhash":
+			return fmt.Sprintf("0x%064v", params[0])
+		case "getblock":
+			return map[string]interface{}{
+				"index":             2,
+				"hash":              params[0],
+				"previousblockhash": fmt.Sprintf("0x%064d", 1),
+				"time":              1000,
+				"tx":                []interface{}{},
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.SaveLocalNewBlock(1, fmt.Sprintf("0x%064d", 1))
+
+	//高度2的区块键被子bucket占用，只有保存区块失败
+	db, err := bolt.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile), 0600, nil)
+	if err != nil {
+		t.Fatalf("open local db failed, unexpected error: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("Block"))
+		if err != nil {
+			return err
+		}
+		_, err = b.CreateBucket([]byte{0, 0, 0, 0, 0, 0, 0, 2})
+		return err
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("prepare local db failed, unexpected error: %v", err)
+	}
+
+	bs := wm.Blockscanner
+	bs.Scanning = true
+	bs.ScanBlockTask()
+
+	height, hash := wm.GetLocalNewBlock()
+	if height != 1 || hash != fmt.Sprintf("0x%064d", 1) {
+		t.Errorf("local head should not advance when save block failed, height: %d, hash: %s", height, hash)
+	}
+}
+
+func TestNEOBlockScanner_SetLocalBlockHead(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "getblockhash" {
+			return fmt.Sprintf("0x%064v", params[0])
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+
+	//从创世块后重新扫描
+	if err := bs.SetRescanBlockHeight(1); err != nil {
+		t.Fatalf("SetRescanBlockHeight(1) failed, unexpected error: %v", err)
+	}
+	if height, hash := wm.GetLocalNewBlock(); height != 0 || hash != fmt.Sprintf("0x%064v", 0) {
+		t.Errorf("local head should be genesis block, got: %d %s", height, hash)
+	}
+	if err := bs.SetRescanBlockHeight(0); err == nil {
+		t.Errorf("rescan height 0 should be rejected")
+	}
+
+	//hash与节点不一致
+	err := bs.SetLocalBlockHead(5, "0xwrong", false)
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrBlockHashMismatch {
+		t.Errorf("hash mismatch should return ErrBlockHashMismatch, err: %v", err)
+	}
+
+	//清除新区块头以上的本地区块
+	for i := uint64(1); i <= 8; i++ {
+		wm.SaveLocalBlock(&Block{Height: i, Hash: fmt.Sprintf("0x%064v", i)})
+	}
+	if err := bs.SetLocalBlockHead(5, fmt.Sprintf("0x%064v", 5), true); err != nil {
+		t.Fatalf("SetLocalBlockHead failed, unexpected error: %v", err)
+	}
+	if height, _ := wm.GetLocalNewBlock(); height != 5 {
+		t.Errorf("local head should be 5, got: %d", height)
+	}
+	if _, err := wm.GetLocalBlock(5); err != nil {
+		t.Errorf("block 5 should be kept")
+	}
+	if _, err := wm.GetLocalBlock(6); err == nil {
+		t.Errorf("block 6 should be purged")
+	}
+
+	//不清除时保留本地区块
+	if err := bs.SetLocalBlockHead(2, "", false); err != nil {
+		t.Fatalf("SetLocalBlockHead failed, unexpected error: %v", err)
+	}
+	if _, err := wm.GetLocalBlock(5); err != nil {
+		t.Errorf("block 5 should be kept without purge")
+	}
+}
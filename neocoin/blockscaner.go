@@ -20,15 +20,17 @@ import (
 	"fmt"
 	"github.com/tidwall/gjson"
 	"net/url"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
 	"github.com/blocktree/openwallet/common"
 	"github.com/blocktree/openwallet/openwallet"
+	"github.com/blocktree/openwallet/timer"
 	"github.com/graarh/golang-socketio"
+	"github.com/btcsuite/btcutil/bloom"
 	"github.com/graarh/golang-socketio/transport"
 	"github.com/shopspring/decimal"
 )
@@ -54,10 +56,48 @@ type NEOBlockScanner struct {
 	socketIO             *gosocketio.Client //socketIO客户端
 	setupSocketIOOnce    sync.Once
 	stopSocketIO         chan struct{}
+	addressFilter        *bloom.Filter      //观测地址布隆过滤器
+	watchAddresses       map[string]string  //观测地址集合，地址对应账户标记
+	watchTTL             map[string]*watchAddressTTL //有有效期的观测地址
+	multiSigWatch        map[string]string  //多重签名账户地址，地址对应账户
+	lastDivergenceCheck  time.Time          //最近一次多节点分歧检查时间
+	profile              *scanProfile       //按区块范围采集的性能分析
+	profileMu            sync.Mutex
+
+	AlertObservers    map[NEOAlertNotificationObject]bool    //告警观察者
+	ActivityObservers map[NEOActivityNotificationObject]bool //账户活动汇总观察者
+	activity          *activityWindow                        //账户活动汇总窗口
+	heightGuard       *heightGuard                           //区块高度扫描锁和通知记录
+	explorer          *ExplorerServer                        //内嵌浏览器HTTP接口
+	tokenRefresh      *timer.TaskTimer                       //代币合约元数据定时刷新
+	assetsReload      *timer.TaskTimer                       //跟踪资产文件定时重新加载
+	notifyRedeliver   *timer.TaskTimer                       //未投递通知定时补发
+	outboxMu          sync.Mutex
+	headLagDegraded   bool                                   //节点高度落后，暂停通知提取结果
+	headLagMu         sync.RWMutex
+	backfill          *backfillQueue                         //回填时待优先扫描的区块
+	forkVerifyUntil   uint64                                 //分叉前的扫描高度，重扫到该高度前验证交易见证签名
+
+	CheckpointObservers map[NEOCheckpointNotificationObject]bool //检查点观察者
+	BlockStatsObservers map[NEOBlockStatsNotificationObject]bool //区块资产汇总观察者
+	blockStats          *blockStatsCache                         //提取时统计的区块资产汇总
+	mempoolSynced       bool                                     //启动后是否已同步内存池
+	txPageSnapshots     map[string]*TxPageSnapshot               //地址交易记录查询的分页快照
+	txPageMu            sync.Mutex
+	observerLatency     map[string]*ObserverLatency              //观察者通知耗时统计
+	latencyMu           sync.Mutex
+	shedStatus          LoadShedStatus                           //追赶区块期间推迟低优先级工作的状态
+	shedMu              sync.Mutex
 
 	//用于实现浏览器
 	IsSkipFailedBlock bool                                    //是否跳过失败区块
 	NEOBlockObservers map[NEOBlockScanNotificationObject]bool //观察者
+
+	observerFilters map[openwallet.BlockScanNotificationObject]*ObserverFilter //观察者订阅过滤条件
+
+	notifyClassifier NotifyErrorClassifier //判断观察者返回的错误是否永久失败
+	classifierMu     sync.RWMutex
+	health           *scannerHealth //最近的错误和降级状态
 }
 
 //ExtractResult 扫描完成的提取结果
@@ -68,6 +108,9 @@ type ExtractResult struct {
 	BlockHeight     uint64
 	Success         bool
 	IsOmniTransfer  bool
+	index           int              //交易在批次中的序号
+	trx             *Transaction     //浏览器模式下索引的交易单
+	omniTrx         *OmniTransaction //代币交易单，用于区块资产汇总
 }
 
 //SaveResult 保存结果
@@ -89,6 +132,15 @@ func NewNEOBlockScanner(wm *WalletManager) *NEOBlockScanner {
 	bs.RescanLastBlockCount = 0
 	bs.stopSocketIO = make(chan struct{})
 	bs.NEOBlockObservers = make(map[NEOBlockScanNotificationObject]bool)
+	bs.observerFilters = make(map[openwallet.BlockScanNotificationObject]*ObserverFilter)
+	bs.AlertObservers = make(map[NEOAlertNotificationObject]bool)
+	bs.ActivityObservers = make(map[NEOActivityNotificationObject]bool)
+	bs.activity = newActivityWindow()
+	bs.CheckpointObservers = make(map[NEOCheckpointNotificationObject]bool)
+	bs.BlockStatsObservers = make(map[NEOBlockStatsNotificationObject]bool)
+	bs.blockStats = newBlockStatsCache()
+	bs.heightGuard = newHeightGuard()
+	bs.health = newScannerHealth()
 	//bs.RPCServer = RPCServerCore
 
 	//设置扫描任务
@@ -97,53 +149,137 @@ func NewNEOBlockScanner(wm *WalletManager) *NEOBlockScanner {
 	return &bs
 }
 
-//SetRescanBlockHeight 重置区块链扫描高度
+//SetRescanBlockHeight 重置区块链扫描高度，下一次扫描从height开始
 func (bs *NEOBlockScanner) SetRescanBlockHeight(height uint64) error {
-	height = height - 1
-	if height < 0 {
-		return errors.New("block height to rescan must greater than 0.")
+	if err := bs.wm.requireCapability(CapabilityRescan); err != nil {
+		return err
 	}
 
-	hash, err := bs.wm.GetBlockHash(height)
-	if err != nil {
+	return bs.setRescanBlockHeight(height)
+}
+
+func (bs *NEOBlockScanner) setRescanBlockHeight(height uint64) error {
+	if height == 0 {
+		return bs.wm.errorf(ErrBlockHeightInvalid, "block height to rescan must greater than 0")
+	}
+
+	return bs.setLocalBlockHead(height-1, "", false)
+}
+
+//SetLocalBlockHead 设置本地已扫区块头
+//hash为空时使用节点上该高度的hash，不为空时需要与节点一致
+//purge为true时，清除本地高于height的区块和未扫记录，需要CapabilityDeleteLocal
+func (bs *NEOBlockScanner) SetLocalBlockHead(height uint64, hash string, purge bool) error {
+	if err := bs.wm.requireCapability(localBlockHeadCapability(purge)); err != nil {
 		return err
 	}
 
-	bs.wm.SaveLocalNewBlock(height, hash)
+	return bs.setLocalBlockHead(height, hash, purge)
+}
+
+//setLocalBlockHead height为0时区块头为创世块，从高度1开始扫描
+func (bs *NEOBlockScanner) setLocalBlockHead(height uint64, hash string, purge bool) error {
 
-	return nil
+	nodeHash, err := bs.wm.GetBlockHash(height)
+	if err != nil {
+		return bs.wm.errorf(openwallet.ErrCallFullNodeAPIFailed, "can not get block hash on height: %d, unexpected error: %v", height, err)
+	}
+
+	if len(hash) == 0 {
+		hash = nodeHash
+	} else if hash != nodeHash {
+		return bs.wm.errorf(ErrBlockHashMismatch, "block hash: %s is not equal to node hash: %s on height: %d", hash, nodeHash, height)
+	}
+
+	if purge {
+		err = bs.wm.deleteLocalDataAboveHeight(height)
+		if err != nil {
+			return bs.wm.errorf(ErrLocalDBOperateFailed, "purge local data above height: %d failed, unexpected error: %v", height, err)
+		}
+	}
+
+	return bs.wm.SaveLocalNewBlock(height, hash)
 }
 
 //ScanBlockTask 扫描任务
 func (bs *NEOBlockScanner) ScanBlockTask() {
 
+	//扫描区块期间配置保持不变，区块之间让出给等待中的配置替换
+	bs.wm.scanCycleMu.RLock()
+	defer bs.wm.scanCycleMu.RUnlock()
+
+	defer bs.scanTaskDone()
+
+	//同一本地数据库只允许一个实例扫描写入
+	if err := bs.wm.keepScanLease(); err != nil {
+		bs.wm.Log.Std.Warning("block scanner can not hold scan lease; unexpected error: %v", err)
+		bs.recordScanError(ScanErrorSourceLease, 0, err)
+		return
+	}
+
+	//节点高度落后于其他节点或浏览器时，暂停通知提取结果
+	bs.checkHeadLag()
+
 	//获取本地区块高度
 	blockHeader, err := bs.GetScannedBlockHeader()
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not get new block height; unexpected error: %v", err)
+		bs.recordScanError(bs.chainErrorSource(), 0, err)
 		return
 	}
 
 	currentHeight := blockHeader.Height
 	currentHash := blockHeader.Hash
 
+	//落后较多时先追赶区块，推迟低优先级工作
+	if maxHeight, err := bs.wm.GetBlockHeight(); err == nil {
+		bs.updateLoadShedding(currentHeight, maxHeight)
+	}
+
+	//启动后先提取停机期间进入内存池的交易
+	mempoolSynced := bs.syncMempoolOnStartup()
+
+	//回填时先扫描观测地址相关的区块
+	bs.priorityBackfill(currentHeight)
+
+	//固定读取的链快照，以及重组重扫前已提取的区块
+	var pin, extracted *ScanPin
+	//连续回退的区块数量，回退结束后记录重组深度
+	var reorgHeight, reorgDepth uint64
+	//每次迭代固定一个节点读取区块数据，见readRouting
+	unpin := func() {}
+	defer func() { unpin() }()
+
 	for {
 
+		unpin()
+		unpin = bs.wm.pinReadNode()
+
+		bs.yieldScanCycle()
+
 		if !bs.Scanning {
 			//区块扫描器已暂停，马上结束本次任务
 			return
 		}
 
-		//获取最大高度
+		//租约被其他实例接管时，马上结束本次任务
+		if err := bs.wm.keepScanLease(); err != nil {
+			bs.wm.Log.Std.Warning("block scanner lost scan lease; unexpected error: %v", err)
+			bs.recordScanError(ScanErrorSourceLease, 0, err)
+			return
+		}
+
+		//获取最大高度，已按节点实现扣除创世区块偏移，见ScannableTipHeight
 		maxHeight, err := bs.wm.GetBlockHeight()
-		// getblockcount 接口拿到的区块数
-		// 包含了创世区块，所以要减1，
-		// 否则后面执行 getblockhash 会报错 ： [-100]Invalid Height
 		if err != nil {
 			//下一个高度找不到会报异常
 			bs.wm.Log.Std.Info("block scanner can not get rpc-server block height; unexpected error: %v", err)
+			bs.chainUnavailable(err)
 			break
 		}
+		bs.chainAvailable()
+
+		bs.updateLoadShedding(currentHeight, maxHeight)
 
 		//是否已到最新高度
 		if currentHeight >= maxHeight {
@@ -151,19 +287,30 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 			break
 		}
 
+		//固定本次迭代读取的链快照
+		pin, err = bs.pinChainTip(pin, maxHeight)
+		if err != nil {
+			bs.wm.Log.Std.Info("block scanner can not pin chain tip; unexpected error: %v", err)
+			break
+		}
+
 		//继续扫描下一个区块
 		currentHeight = currentHeight + 1
 
 		bs.wm.Log.Std.Info("block scanner scanning height: %d ...", currentHeight)
 
+		//到达性能分析范围时开始采集
+		bs.profileBeforeBlock(currentHeight)
+
 		hash, err := bs.wm.GetBlockHash(currentHeight)
 		if err != nil {
 			//下一个高度找不到会报异常
 			bs.wm.Log.Std.Info("block scanner can not get new block hash; unexpected error: %v", err)
+			bs.recordScanError(bs.chainErrorSource(), currentHeight, err)
 			break
 		}
 
-		if bs.wm.Config.OmniSupport {
+		if bs.wm.omniEnabled() {
 			//判断omni的区块高度是否一致
 			omniBlockHash, err := bs.wm.GetOmniBlockHash(currentHeight)
 			if err != nil {
@@ -178,9 +325,13 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 			}
 		}
 
-		block, err := bs.wm.GetBlock(hash)
+		var block *Block
+		bs.withPhaseLabel(ProfilePhaseFetchBlock, currentHeight, func() {
+			block, err = bs.wm.GetBlock(hash, bs.scanBlockDetail())
+		})
 		if err != nil {
 			bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
+			bs.recordScanError(bs.chainErrorSource(), currentHeight, err)
 
 			//记录未扫区块
 			unscanRecord := NewUnscanRecord(currentHeight, "", err.Error())
@@ -189,6 +340,19 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 			continue
 		}
 
+		//节点返回的区块高度须与请求高度一致
+		if block.Height != currentHeight {
+			bs.wm.Log.Std.Error("block scanner got block height: %d, expected: %d", block.Height, currentHeight)
+			break
+		}
+
+		//验证区块的共识签名，防止节点返回伪造区块
+		err = bs.verifyBlock(block)
+		if err != nil {
+			bs.wm.Log.Std.Error("block height: %d verify witness failed; unexpected error: %v", currentHeight, err)
+			break
+		}
+
 		isFork := false
 
 		//判断hash是否上一区块的hash
@@ -203,15 +367,38 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 			//查询本地分叉的区块
 			forkBlock, _ := bs.wm.GetLocalBlock(currentHeight - 1)
 
+			if reorgDepth == 0 {
+				reorgHeight = currentHeight - 1
+			}
+			reorgDepth++
+
+			if currentHeight > bs.forkVerifyUntil {
+				bs.forkVerifyUntil = currentHeight
+			}
+
 			//删除上一区块链的所有充值记录
 			//bs.DeleteRechargesByHeight(currentHeight - 1)
 			//删除上一区块链的未扫记录
-			bs.wm.DeleteUnscanRecord(currentHeight - 1)
+			bs.wm.deleteUnscanRecord(currentHeight - 1)
 			currentHeight = currentHeight - 2 //倒退2个区块重新扫描
 			if currentHeight <= 0 {
 				currentHeight = 1
 			}
 
+			//删除孤块上的交易索引
+			if bs.wm.config().ExplorerMode {
+				if err := bs.wm.TxIndex().DeleteAboveHeight(currentHeight); err != nil {
+					bs.wm.Log.Std.Error("delete indexed transactions above height: %d failed; unexpected error: %v", currentHeight, err)
+				}
+			}
+
+			//回滚孤块上的未花输出历史
+			if bs.wm.config().UTXOHistory {
+				if err := bs.wm.DeleteUTXOHistoryAboveHeight(currentHeight); err != nil {
+					bs.wm.Log.Std.Error("delete utxo history above height: %d failed; unexpected error: %v", currentHeight, err)
+				}
+			}
+
 			localBlock, err := bs.wm.GetLocalBlock(currentHeight)
 			if err != nil {
 				bs.wm.Log.Std.Error("block scanner can not get local block; unexpected error: %v", err)
@@ -225,7 +412,7 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 					break
 				}
 
-				localBlock, err = bs.wm.GetBlock(prevHash)
+				localBlock, err = bs.wm.GetBlock(prevHash, BlockDetailHeader)
 				if err != nil {
 					bs.wm.Log.Std.Error("block scanner can not get prev block; unexpected error: %v", err)
 					break
@@ -239,7 +426,11 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 			bs.wm.Log.Std.Info("rescan block on height: %d, hash: %s .", currentHeight, currentHash)
 
 			//重新记录一个新扫描起点
-			bs.wm.SaveLocalNewBlock(localBlock.Height, localBlock.Hash)
+			err = bs.wm.SaveLocalNewBlock(localBlock.Height, localBlock.Hash)
+			if err != nil {
+				bs.wm.Log.Std.Error("block height: %d save local new block failed; unexpected error: %v", localBlock.Height, err)
+				break
+			}
 
 			isFork = true
 
@@ -247,48 +438,130 @@ func (bs *NEOBlockScanner) ScanBlockTask() {
 
 				//通知分叉区块给观测者，异步处理
 				bs.newBlockNotify(forkBlock, isFork)
+
+				//回滚孤块上已通知的提取结果
+				bs.rollbackExtractData(forkBlock.Height)
 			}
 
 		} else {
 
-			err = bs.BatchExtractTransaction(block.Height, block.Hash, block.tx)
+			//分叉后重扫的区块，交易见证签名验证失败时不提取也不保存高度，下次扫描重新验证
+			if bs.wm.config().ForkRescanVerifyWitness && currentHeight <= bs.forkVerifyUntil {
+				if err := bs.checkForkRescanWitnesses(block); err != nil {
+					bs.wm.Log.Std.Error("block height: %d verify transaction witness failed; unexpected error: %v", currentHeight, err)
+					break
+				}
+			}
+
+			//回填时已优先提取的区块，或重组前已提取的相同区块，不再重复提取
+			if bs.takePriorityScanned(currentHeight, hash) || !bs.needExtractAfterRestart(extracted, currentHeight, hash) {
+				err = nil
+			} else {
+				bs.withPhaseLabel(ProfilePhaseExtract, currentHeight, func() {
+					err = bs.batchExtractTransaction(block.Height, block.Hash, block.Tx, bs.blockTxDetails(block))
+				})
+			}
 			if err != nil {
 				bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
 			}
 
+			//提取期间节点重组，不保存高度，重新固定快照后扫描，已提取的区块记录下来避免重复通知
+			extracted = nil
+			if !bs.isPinConsistent(pin, currentHeight, hash) {
+				bs.wm.Log.Std.Warning("node reorganized after extracting height: %d, restart iteration", currentHeight)
+				extracted = &ScanPin{Height: currentHeight, Hash: hash}
+				pin = nil
+				currentHeight = currentHeight - 1
+				continue
+			}
+
 			//重置当前区块的hash
 			currentHash = hash
 
 			//保存本地新高度
-			bs.wm.SaveLocalNewBlock(currentHeight, currentHash)
-			bs.wm.SaveLocalBlock(block)
+			bs.withPhaseLabel(ProfilePhaseSave, currentHeight, func() {
+				err = bs.wm.saveLocalBlockAndHead(block, currentHeight, currentHash)
+			})
+			if err != nil {
+				//高度未保存，下次扫描从该区块重新开始
+				bs.wm.Log.Std.Error("block height: %d save local block failed; unexpected error: %v", currentHeight, err)
+				break
+			}
 
 			isFork = false
 
+			//重组回退结束，记录重组深度用于调整重扫区块数量
+			if reorgDepth > 0 {
+				if err := bs.wm.SaveReorgRecord(reorgHeight, reorgDepth); err != nil {
+					bs.wm.Log.Std.Error("save reorg record failed; unexpected error: %v", err)
+				}
+				reorgDepth = 0
+			}
+
 			//通知新区块给观测者，异步处理
 			bs.newBlockNotify(block, isFork)
+
+			//满N个区块发送账户活动汇总
+			bs.heartbeatActivity(currentHeight)
+
+			//满N个区块发布检查点
+			bs.publishCheckpoint(currentHeight, currentHash)
+
+			//满N个区块保存扫描状态快照
+			bs.takeScanSnapshot(currentHeight, currentHash)
+
+			//扫描完性能分析范围时停止采集
+			bs.profileAfterBlock(currentHeight)
 		}
 
 	}
 
 	//重扫前N个块，为保证记录找到
-	for i := currentHeight - bs.RescanLastBlockCount; i < currentHeight; i++ {
+	rescanCount := bs.rescanBlockCount()
+	if rescanCount > currentHeight {
+		rescanCount = currentHeight
+	}
+	for i := currentHeight - rescanCount; i < currentHeight; i++ {
+		bs.yieldScanCycle()
 		bs.scanBlock(i)
 	}
 
-	if bs.IsScanMemPool {
+	bs.yieldScanCycle()
+
+	//本次任务启动时已同步过内存池，不再重复通知
+	if bs.IsScanMemPool && !mempoolSynced && !bs.shedLoad(ShedWorkMempool) {
 		//扫描交易内存池
 		bs.ScanTxMemPool()
 	}
 
+	bs.yieldScanCycle()
+
 	//重扫失败区块
 	bs.RescanFailedRecord()
 
+	//通知达到确认数要求的交易
+	bs.checkConfirmations()
+
+	//检查多节点分歧
+	if !bs.shedLoad(ShedWorkReconcile) {
+		bs.checkNodeDivergence()
+	}
+
+}
+
+//yieldScanCycle 短暂释放扫描周期读锁，等待中的SwapConfig在区块之间生效，
+//RWMutex有写锁等待时新的读锁会阻塞，保证替换不会被连续扫描饿死
+func (bs *NEOBlockScanner) yieldScanCycle() {
+	bs.wm.scanCycleMu.RUnlock()
+	bs.wm.scanCycleMu.RLock()
 }
 
 //ScanBlock 扫描指定高度区块
 func (bs *NEOBlockScanner) ScanBlock(height uint64) error {
 
+	bs.wm.scanCycleMu.RLock()
+	defer bs.wm.scanCycleMu.RUnlock()
+
 	block, err := bs.scanBlock(height)
 	if err != nil {
 		return err
@@ -302,6 +575,9 @@ func (bs *NEOBlockScanner) ScanBlock(height uint64) error {
 
 func (bs *NEOBlockScanner) scanBlock(height uint64) (*Block, error) {
 
+	//区块hash和区块数据从同一节点读取
+	defer bs.wm.pinReadNode()()
+
 	hash, err := bs.wm.GetBlockHash(height)
 	if err != nil {
 		//下一个高度找不到会报异常
@@ -309,7 +585,7 @@ func (bs *NEOBlockScanner) scanBlock(height uint64) (*Block, error) {
 		return nil, err
 	}
 
-	block, err := bs.wm.GetBlock(hash)
+	block, err := bs.wm.GetBlock(hash, bs.scanBlockDetail())
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
 
@@ -322,7 +598,7 @@ func (bs *NEOBlockScanner) scanBlock(height uint64) (*Block, error) {
 
 	bs.wm.Log.Std.Info("block scanner scanning height: %d ...", block.Height)
 
-	err = bs.BatchExtractTransaction(block.Height, block.Hash, block.tx)
+	err = bs.batchExtractTransaction(block.Height, block.Hash, block.Tx, bs.blockTxDetails(block))
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
 	}
@@ -338,27 +614,42 @@ func (bs *NEOBlockScanner) ScanTxMemPool() {
 
 	bs.wm.Log.Std.Info("block scanner scanning mempool ...")
 
+	err := bs.scanTxMemPool()
+	if err != nil {
+		bs.wm.Log.Std.Info("block scanner can not scan mempool; unexpected error: %v", err)
+	}
+}
+
+//scanTxMemPool 提取内存池中的交易并跟踪已通知交易是否被驱逐
+func (bs *NEOBlockScanner) scanTxMemPool() error {
+
 	//提取未确认的交易单
 	txIDsInMemPool, err := bs.wm.GetTxIDsInMemPool()
 	if err != nil {
-		bs.wm.Log.Std.Info("block scanner can not get mempool data; unexpected error: %v", err)
-		return
+		return err
 	}
 
-	if txIDsInMemPool == nil || len(txIDsInMemPool) == 0 {
-		return
-	}
+	//检查已跟踪的交易是否被驱逐
+	bs.checkDroppedMempoolTxs(txIDsInMemPool)
 
-	err = bs.BatchExtractTransaction(0, "", txIDsInMemPool)
-	if err != nil {
-		bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
+	//离开内存池的交易不再计入网络费统计
+	bs.wm.feeStats.retainMempool(txIDsInMemPool)
+
+	if txIDsInMemPool == nil || len(txIDsInMemPool) == 0 {
+		return nil
 	}
 
+	return bs.BatchExtractTransaction(0, "", txIDsInMemPool)
 }
 
 //rescanFailedRecord 重扫失败记录
 func (bs *NEOBlockScanner) RescanFailedRecord() {
 
+	//节点落后时重扫的结果同样不会通知，等待恢复
+	if bs.isHeadLagDegraded() {
+		return
+	}
+
 	var (
 		blockMap = make(map[uint64][]string)
 	)
@@ -383,7 +674,19 @@ func (bs *NEOBlockScanner) RescanFailedRecord() {
 		}
 	}
 
-	for height, txs := range blockMap {
+	heights := make([]uint64, 0, len(blockMap))
+	if bs.wm.config().StrictNotifyOrder {
+		//严格顺序通知时，按高度升序重扫
+		heights = sortedHeights(blockMap)
+	} else {
+		for height := range blockMap {
+			heights = append(heights, height)
+		}
+	}
+
+	for _, height := range heights {
+
+		txs := blockMap[height]
 
 		if height == 0 {
 			continue
@@ -402,13 +705,13 @@ func (bs *NEOBlockScanner) RescanFailedRecord() {
 				continue
 			}
 
-			block, err := bs.wm.GetBlock(hash)
+			block, err := bs.wm.GetBlock(hash, BlockDetailTxIDs)
 			if err != nil {
 				bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
 				continue
 			}
 
-			txs = block.tx
+			txs = block.Tx
 		}
 
 		err = bs.BatchExtractTransaction(height, hash, txs)
@@ -418,23 +721,42 @@ func (bs *NEOBlockScanner) RescanFailedRecord() {
 		}
 
 		//删除未扫记录
-		bs.wm.DeleteUnscanRecord(height)
+		bs.wm.deleteUnscanRecord(height)
 	}
 
 	//删除未没有找到交易记录的重扫记录
-	bs.wm.DeleteUnscanRecordNotFindTX()
+	bs.wm.deleteUnscanRecordNotFindTX()
 }
 
 //newBlockNotify 获得新区块后，通知给观测者
 func (bs *NEOBlockScanner) newBlockNotify(block *Block, isFork bool) {
+
+	//实时扫描与手动重扫可能先后通知同一区块，分叉通知不去重
+	if isFork {
+		bs.heightGuard.forget(block.Height)
+	} else if !bs.heightGuard.markNotified(block.Height, block.Hash) {
+		bs.wm.Log.Std.Info("block height: %d hash: %s has been notified, skip duplicate notification", block.Height, block.Hash)
+		return
+	}
+
 	header := block.BlockHeader(bs.wm.Symbol())
 	header.Fork = isFork
 	bs.NewBlockNotify(header)
+
+	//通知新区块的资产汇总，孤块不统计，追赶区块期间不统计
+	if !isFork && !bs.shedLoad(ShedWorkStats) {
+		bs.blockStatsNotify(block, header)
+	}
 }
 
 //BatchExtractTransaction 批量提取交易单
 //bitcoin 1M的区块链可以容纳3000笔交易，批量多线程处理，速度更快
 func (bs *NEOBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash string, txs []string) error {
+	return bs.batchExtractTransaction(blockHeight, blockHash, txs, nil)
+}
+
+//batchExtractTransaction 批量提取交易单，details中有的交易直接使用，不再查询节点
+func (bs *NEOBlockScanner) batchExtractTransaction(blockHeight uint64, blockHash string, txs []string, details map[string]*Transaction) error {
 
 	var (
 		quit       = make(chan struct{})
@@ -447,6 +769,12 @@ func (bs *NEOBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash
 		return errors.New("BatchExtractTransaction block is nil.")
 	}
 
+	//同一区块高度串行提取，交易池的未确认交易不加锁
+	if blockHeight > 0 {
+		bs.heightGuard.lock(blockHeight)
+		defer bs.heightGuard.unlock(blockHeight)
+	}
+
 	//生产通道
 	producer := make(chan ExtractResult)
 	defer close(producer)
@@ -455,34 +783,75 @@ func (bs *NEOBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash
 	worker := make(chan ExtractResult)
 	defer close(worker)
 
+	//浏览器模式和未花输出历史按交易序号收集交易单
+	indexed := make([]*Transaction, len(txs))
+	collect := bs.wm.config().ExplorerMode || bs.wm.utxoHistoryEnabled()
+
+	//区块交易的提取结果全部完成后一次保存再通知
+	blockExtractData := make([]map[string]*openwallet.TxExtractData, 0)
+
+	//有观测者时统计区块资产汇总
+	var stats *blockStatsBuilder
+	if blockHeight > 0 && bs.hasBlockStatsObservers() {
+		stats = bs.newBlockStatsBuilder(blockHeight, blockHash, len(txs))
+	}
+
+	//通知工作
+	notifyWork := func(height uint64, gets ExtractResult) {
+
+		if gets.Success && height > 0 {
+
+			blockExtractData = append(blockExtractData, gets.extractData, gets.extractOmniData)
+
+		} else if gets.Success {
+
+			notifyErr := bs.newExtractDataNotify(height, gets.extractData)
+			//saveErr := bs.SaveRechargeToWalletDB(height, gets.Recharges)
+			if notifyErr != nil {
+				failed++ //标记保存失败数
+				bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
+			}
+
+			notifyErr = nil
+			notifyErr = bs.newExtractDataNotify(height, gets.extractOmniData)
+			if notifyErr != nil {
+				failed++ //标记保存失败数
+				bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
+			}
+
+		} else {
+			//记录未扫区块
+			unscanRecord := NewUnscanRecord(height, "", "")
+			bs.SaveUnscanRecord(unscanRecord)
+			bs.wm.Log.Std.Info("block height: %d extract failed.", height)
+			failed++ //标记保存失败数
+		}
+	}
+
 	//保存工作
 	saveWork := func(height uint64, result chan ExtractResult) {
+		//严格顺序通知时，缓存先完成的结果，按交易序号依次通知
+		sequencer := newExtractSequencer()
 		//回收创建的地址
 		for gets := range result {
 
-			if gets.Success {
+			if collect && gets.index < len(indexed) {
+				indexed[gets.index] = gets.trx
+			}
 
-				notifyErr := bs.newExtractDataNotify(height, gets.extractData)
-				//saveErr := bs.SaveRechargeToWalletDB(height, gets.Recharges)
-				if notifyErr != nil {
-					failed++ //标记保存失败数
-					bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
-				}
+			if stats != nil {
+				stats.addTransaction(gets.trx)
+				stats.addTokenTransfer(gets.omniTrx)
+			}
 
-				notifyErr = nil
-				notifyErr = bs.newExtractDataNotify(height, gets.extractOmniData)
-				if notifyErr != nil {
-					failed++ //标记保存失败数
-					bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
+			if bs.wm.config().StrictNotifyOrder {
+				for _, ready := range sequencer.push(gets) {
+					notifyWork(height, ready)
 				}
-
 			} else {
-				//记录未扫区块
-				unscanRecord := NewUnscanRecord(height, "", "")
-				bs.SaveUnscanRecord(unscanRecord)
-				bs.wm.Log.Std.Info("block height: %d extract failed.", height)
-				failed++ //标记保存失败数
+				notifyWork(height, gets)
 			}
+
 			//累计完成的线程数
 			done++
 			if done == shouldDone {
@@ -492,19 +861,29 @@ func (bs *NEOBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash
 		}
 	}
 
+	//观测地址预过滤
+	scanAddressFunc := bs.filterScanAddressFunc(bs.ScanAddressFunc)
+
+	//公共节点模式下批量预取交易单，已有区块交易详情时不需要
+	if details == nil {
+		bs.wm.prefetchTransactions(txs)
+	}
+
 	//提取工作
 	extractWork := func(eblockHeight uint64, eBlockHash string, mTxs []string, eProducer chan ExtractResult) {
-		for _, txid := range mTxs {
+		for i, txid := range mTxs {
 			bs.extractingCH <- struct{}{}
 			//shouldDone++
-			go func(mBlockHeight uint64, mTxid string, end chan struct{}, mProducer chan<- ExtractResult) {
+			go func(mBlockHeight uint64, mTxid string, mIndex int, end chan struct{}, mProducer chan<- ExtractResult) {
 
 				//导出提出的交易
-				mProducer <- bs.ExtractTransaction(mBlockHeight, eBlockHash, mTxid, bs.ScanAddressFunc)
+				result := bs.extractTransactionWith(mBlockHeight, eBlockHash, mTxid, details[mTxid], scanAddressFunc)
+				result.index = mIndex
+				mProducer <- result
 				//释放
 				<-end
 
-			}(eblockHeight, txid, bs.extractingCH, eProducer)
+			}(eblockHeight, txid, i, bs.extractingCH, eProducer)
 		}
 	}
 
@@ -519,6 +898,44 @@ func (bs *NEOBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash
 	//以下使用生产消费模式
 	bs.extractRuntime(producer, worker, quit)
 
+	//实时扫描与手动重扫可能先后提取同一区块，提取数据已全部通知过时不重复通知，
+	//按txid补扫失败记录时没有区块hash，只提取部分交易，不去重
+	wholeBlock := blockHeight > 0 && blockHash != ""
+	duplicate := wholeBlock && bs.heightGuard.extractNotified(blockHeight, blockHash)
+	if duplicate {
+		bs.wm.Log.Std.Info("block height: %d hash: %s extract data has been notified, skip duplicate notification", blockHeight, blockHash)
+	} else if blockHeight > 0 && len(blockExtractData) > 0 {
+		failed += bs.newBlockExtractDataNotify(blockHeight, blockExtractData)
+	}
+
+	if wholeBlock && !duplicate && failed == 0 {
+		bs.heightGuard.markExtractNotified(blockHeight, blockHash)
+	}
+
+	if stats != nil && failed == 0 {
+		bs.blockStats.put(stats.build())
+	}
+
+	//浏览器模式保存区块全部交易的索引
+	if bs.wm.config().ExplorerMode && blockHeight > 0 {
+		indexErr := bs.indexBlockTransactions(blockHeight, blockHash, indexed, failed == 0)
+		if indexErr != nil {
+			bs.SaveUnscanRecord(NewUnscanRecord(blockHeight, "", indexErr.Error()))
+			bs.wm.Log.Std.Info("block height: %d index transactions failed, unexpected error: %v", blockHeight, indexErr)
+			failed++
+		}
+	}
+
+	//记录未花输出的创建和花费高度
+	if bs.wm.utxoHistoryEnabled() && blockHeight > 0 {
+		historyErr := bs.saveUTXOHistory(indexed, scanAddressFunc)
+		if historyErr != nil {
+			bs.SaveUnscanRecord(NewUnscanRecord(blockHeight, "", historyErr.Error()))
+			bs.wm.Log.Std.Info("block height: %d save utxo history failed, unexpected error: %v", blockHeight, historyErr)
+			failed++
+		}
+	}
+
 	if failed > 0 {
 		return fmt.Errorf("block scanner saveWork failed")
 	} else {
@@ -566,6 +983,11 @@ func (bs *NEOBlockScanner) extractRuntime(producer chan ExtractResult, worker ch
 
 //ExtractTransaction 提取交易单
 func (bs *NEOBlockScanner) ExtractTransaction(blockHeight uint64, blockHash string, txid string, scanAddressFunc openwallet.BlockScanAddressFunc) ExtractResult {
+	return bs.extractTransactionWith(blockHeight, blockHash, txid, nil, scanAddressFunc)
+}
+
+//extractTransactionWith 提取交易单，parsed不为nil时使用已解析的交易详情，不再查询节点
+func (bs *NEOBlockScanner) extractTransactionWith(blockHeight uint64, blockHash string, txid string, parsed *Transaction, scanAddressFunc openwallet.BlockScanAddressFunc) ExtractResult {
 
 	var (
 		result = ExtractResult{
@@ -580,7 +1002,13 @@ func (bs *NEOBlockScanner) ExtractTransaction(blockHeight uint64, blockHash stri
 
 	//bs.wm.Log.Std.Debug("block scanner scanning tx: %s ...", txid)
 	//获取bitcoin的交易单
-	trx, err := bs.wm.GetTransaction(txid)
+	var (
+		trx = parsed
+		err error
+	)
+	if trx == nil {
+		trx, err = bs.wm.GetTransaction(txid)
+	}
 
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not extract transaction data; unexpected error: %v", err)
@@ -594,7 +1022,12 @@ func (bs *NEOBlockScanner) ExtractTransaction(blockHeight uint64, blockHash stri
 		trx.BlockHash = blockHash
 	}
 
-	if bs.wm.Config.OmniSupport {
+	//记录网络费样本，追赶区块期间的历史区块不反映当前网络费
+	if !bs.IsSheddingLoad() {
+		bs.wm.feeStats.observe(trx, bs.wm.config().FeeStatsBlocks)
+	}
+
+	if bs.wm.omniEnabled() {
 		//获取omni的交易单
 		omniTrx, _ = bs.wm.GetOmniTransaction(txid)
 	}
@@ -604,16 +1037,29 @@ func (bs *NEOBlockScanner) ExtractTransaction(blockHeight uint64, blockHash stri
 	}
 
 	bs.extractTransaction(trx, &result, scanAddressFunc)
+	if result.Success {
+		result.trx = trx
+	}
 
 	if omniTrx != nil {
+		result.omniTrx = omniTrx
 		bs.extractOmniTransaction(omniTrx, &result, scanAddressFunc)
 	}
 
+	//保存观测地址相关交易的原始数据，保存失败时区块重新扫描
+	if bs.wm.config().RawTxArchive && bs.wm.config().RPCServerType != RPCServerExplorer &&
+		blockHeight > 0 && result.Success && (len(result.extractData) > 0 || len(result.extractOmniData) > 0) {
+		if err := bs.wm.archiveRawTx(blockHeight, txid); err != nil {
+			bs.wm.Log.Std.Info("block scanner can not archive raw transaction: %s; unexpected error: %v", txid, err)
+			result.Success = false
+		}
+	}
+
 	/*//bs.wm.Log.Debug("start extractTransaction")
 	bs.extractTransaction(trx, &result, scanAddressFunc)
 	//bs.wm.Log.Debug("end extractTransaction")
 
-	if bs.wm.Config.OmniSupport {
+	if bs.wm.omniEnabled() {
 		//获取omni的交易单
 		omniTrx, err := bs.wm.GetOmniTransaction(txid)
 		if err != nil {
@@ -815,11 +1261,11 @@ func (bs *NEOBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 			to, totalReceived := bs.extractTxOutput(trx, result, scanAddressFunc)
 			//bs.wm.Log.Debug("to:", to, "totalReceived:", totalReceived)
 
-			for _, extractData := range result.extractData {
+			for sourceKey, extractData := range result.extractData {
 				tx := &openwallet.Transaction{
 					From: from,
 					To:   to,
-					Fees: totalSpent.Sub(totalReceived).StringFixed(bs.wm.Decimal()),
+					Fees: bs.wm.FormatGAS(totalSpent.Sub(totalReceived)),
 					Coin: openwallet.Coin{
 						Symbol:     bs.wm.Symbol(),
 						IsContract: false,
@@ -834,7 +1280,11 @@ func (bs *NEOBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 				}
 				wxID := openwallet.GenTransactionWxID(tx)
 				tx.WxID = wxID
+				bs.attachAddressRisk(tx)
+				bs.attachCounterparties(tx)
+				bs.attachContractDestinations(tx, trx)
 				extractData.Transaction = tx
+				bs.attachInvoiceMatches(sourceKey, extractData, trx)
 
 				bs.wm.Log.Debug("Transaction:", extractData.Transaction)
 			}
@@ -853,7 +1303,7 @@ func (bs *NEOBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 	//vin := trx.Get("vin")
 
 	var (
-		from        = make([]string, 0)
+		from        = make([]string, 0, len(trx.Vins))
 		totalAmount = decimal.Zero
 		txType      = uint64(0)
 	)
@@ -862,6 +1312,11 @@ func (bs *NEOBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 		txType = 1
 	}
 
+	//先匹配地址，按匹配数量一次分配输入记录，归集交易的大量输入不逐个分配
+	matches, counts, matched := matchScanAddresses(len(trx.Vins), func(i int) string { return trx.Vins[i].Addr }, scanAddressFunc)
+	inputs := make([]openwallet.TxInput, matched)
+	reserveExtractData(result, counts, true)
+
 	createAt := time.Now().Unix()
 	for i, output := range trx.Vins {
 
@@ -877,9 +1332,10 @@ func (bs *NEOBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 
 		amount := output.Value
 		addr := output.Addr
-		sourceKey, ok := scanAddressFunc(addr)
+		sourceKey, ok := matches[i].sourceKey, matches[i].ok
 		if ok {
-			input := openwallet.TxInput{}
+			input := &inputs[0]
+			inputs = inputs[1:]
 			input.SourceTxID = txid
 			input.SourceIndex = vout
 			input.TxID = result.TxID
@@ -902,16 +1358,14 @@ func (bs *NEOBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 			//transactions = append(transactions, &transaction)
 
 			ed := result.extractData[sourceKey]
-			if ed == nil {
-				ed = openwallet.NewBlockExtractData()
-				result.extractData[sourceKey] = ed
-			}
-
-			ed.TxInputs = append(ed.TxInputs, &input)
+			ed.TxInputs = append(ed.TxInputs, input)
 
 		}
 
-		from = append(from, addr+":"+amount)
+		//没有地址的输入不加入from列表，金额仍计入手续费计算
+		if len(addr) > 0 {
+			from = append(from, addr+":"+amount)
+		}
 		dAmount, _ := decimal.NewFromString(amount)
 		totalAmount = totalAmount.Add(dAmount)
 
@@ -923,7 +1377,7 @@ func (bs *NEOBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 func (bs *NEOBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResult, scanAddressFunc openwallet.BlockScanAddressFunc) ([]string, decimal.Decimal) {
 
 	var (
-		to          = make([]string, 0)
+		to          = make([]string, 0, len(trx.Vouts))
 		totalAmount = decimal.Zero
 		txType      = uint64(0)
 	)
@@ -936,13 +1390,28 @@ func (bs *NEOBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 	vout := trx.Vouts
 	txid := trx.TxID
 	//bs.wm.Log.Debug("vout:", vout.Array())
+
+	//先匹配地址，按匹配数量一次分配输出记录
+	matches, counts, matched := matchScanAddresses(len(vout), func(i int) string { return vout[i].Addr }, scanAddressFunc)
+	outputs := make([]openwallet.TxOutPut, matched)
+	reserveExtractData(result, counts, false)
+
 	createAt := time.Now().Unix()
-	for _, output := range vout {
+	for i, output := range vout {
 
 		amount := output.Value
 		n := output.N
 		addr := output.Addr
-		sourceKey, ok := scanAddressFunc(addr)
+
+		//没有地址的输出，按策略单独处理，不加入to列表
+		if len(addr) == 0 {
+			bs.handleNonstandardOutput(trx, output)
+			dAmount, _ := decimal.NewFromString(amount)
+			totalAmount = totalAmount.Add(dAmount)
+			continue
+		}
+
+		sourceKey, ok := matches[i].sourceKey, matches[i].ok
 		if ok {
 
 			//a := wallet.GetAddress(addr)
@@ -950,7 +1419,8 @@ func (bs *NEOBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 			//	continue
 			//}
 
-			outPut := openwallet.TxOutPut{}
+			outPut := &outputs[0]
+			outputs = outputs[1:]
 			outPut.TxID = txid
 			outPut.Address = addr
 			//transaction.AccountID = a.AccountID
@@ -965,6 +1435,9 @@ func (bs *NEOBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 
 			//保存utxo到扩展字段
 			outPut.SetExtParam("scriptPubKey", output.ScriptPubKey)
+			if output.IsContract {
+				outPut.SetExtParam("contractDestination", true)
+			}
 			outPut.CreateAt = createAt
 			outPut.BlockHeight = trx.BlockHeight
 			outPut.BlockHash = trx.BlockHash
@@ -974,12 +1447,7 @@ func (bs *NEOBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 			//transactions = append(transactions, &transaction)
 
 			ed := result.extractData[sourceKey]
-			if ed == nil {
-				ed = openwallet.NewBlockExtractData()
-				result.extractData[sourceKey] = ed
-			}
-
-			ed.TxOutputs = append(ed.TxOutputs, &outPut)
+			ed.TxOutputs = append(ed.TxOutputs, outPut)
 
 		}
 
@@ -995,11 +1463,96 @@ func (bs *NEOBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 //newExtractDataNotify 发送通知
 func (bs *NEOBlockScanner) newExtractDataNotify(height uint64, extractData map[string]*openwallet.TxExtractData) error {
 
+	//内存池交易按零确认充值策略通知
+	if height == 0 {
+		extractData = bs.applyZeroConfPolicy(extractData)
+		if len(extractData) == 0 {
+			return nil
+		}
+	}
+
+	//节点高度落后时不通知，避免按过时的链状态入账
+	if err := bs.withholdExtractData(height); err != nil {
+		return err
+	}
+
+	//保存提取结果，用于观察者离线后补发
+	err := bs.wm.SaveExtractData(height, extractData)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, save extract data failed. unexpected error: %v", height, err)
+	}
+
+	return bs.notifyExtractData(height, extractData)
+}
+
+//newBlockExtractDataNotify 区块全部交易提取完成后，一次保存提取结果再依次通知，返回通知失败的数量
+func (bs *NEOBlockScanner) newBlockExtractDataNotify(height uint64, batch []map[string]*openwallet.TxExtractData) int {
+
+	//节点高度落后时不通知，避免按过时的链状态入账
+	if err := bs.withholdExtractData(height); err != nil {
+		bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", err)
+		return 1
+	}
+
+	//保存提取结果，用于观察者离线后补发
+	err := bs.wm.saveBlockExtractData(height, batch)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, save extract data failed. unexpected error: %v", height, err)
+	}
+
+	failed := 0
+	for _, extractData := range batch {
+		if err = bs.notifyExtractData(height, extractData); err != nil {
+			failed++
+			bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", err)
+		}
+	}
+	return failed
+}
+
+//notifyExtractData 更新跟踪记录并通知已保存的提取结果
+func (bs *NEOBlockScanner) notifyExtractData(height uint64, extractData map[string]*openwallet.TxExtractData) error {
+
+	var err error
+
+	//累计账户活动汇总
+	bs.recordActivity(height, extractData)
+
+	//未确认的交易加入内存池跟踪
+	if height == 0 {
+		err = bs.wm.SaveMempoolTxs(extractData)
+		if err != nil {
+			bs.wm.Log.Std.Error("save mempool txs failed. unexpected error: %v", err)
+		}
+	}
+
+	//区块交易加入确认数跟踪，达到要求的确认数后发送确认通知
+	if height > 0 && bs.wm.config().ConfirmNotify {
+		err = bs.wm.SaveConfirmPending(extractData)
+		if err != nil {
+			bs.wm.Log.Std.Error("block height: %d, save confirm pending txs failed. unexpected error: %v", height, err)
+		}
+	}
+
+	//持久化投递状态，失败的通知由补发任务重新投递
+	if bs.wm.config().NotifyOutbox {
+		bs.deliverExtractData(height, extractData)
+		return nil
+	}
+
 	for o, _ := range bs.Observers {
 		for key, data := range extractData {
-			err := o.BlockExtractDataNotify(key, data)
+			if !bs.acceptNotify(o, key, data) {
+				continue
+			}
+			err := bs.notifyObserver(o, key, data)
 			if err != nil {
 				bs.wm.Log.Error("BlockExtractDataNotify unexpected error:", err)
+				//永久失败的通知隔离，不记录未扫区块，避免反复重扫整个区块
+				if bs.isPermanentNotifyError(notifyObserverName(o), err) {
+					bs.quarantineNotify(notifyObserverName(o), height, key, data, err)
+					continue
+				}
 				//记录未扫区块
 				unscanRecord := NewUnscanRecord(height, "", "ExtractData Notify failed.")
 				err = bs.SaveUnscanRecord(unscanRecord)
@@ -1016,12 +1569,20 @@ func (bs *NEOBlockScanner) newExtractDataNotify(height uint64, extractData map[s
 
 //DeleteUnscanRecordNotFindTX 删除未没有找到交易记录的重扫记录
 func (wm *WalletManager) DeleteUnscanRecordNotFindTX() error {
+	if err := wm.requireCapability(CapabilityDeleteUnscan); err != nil {
+		return err
+	}
+
+	return wm.deleteUnscanRecordNotFindTX()
+}
+
+func (wm *WalletManager) deleteUnscanRecordNotFindTX() error {
 
 	//删除找不到交易单
 	reason := "[-5]No information available about transaction"
 
 	//获取本地区块高度
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
 		return err
 	}
@@ -1033,14 +1594,22 @@ func (wm *WalletManager) DeleteUnscanRecordNotFindTX() error {
 		return err
 	}
 
+	notFound := make([]*UnscanRecord, 0)
+	for _, r := range list {
+		if strings.HasPrefix(r.Reason, reason) {
+			notFound = append(notFound, r)
+		}
+	}
+
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
 	}
-	for _, r := range list {
-		if strings.HasPrefix(r.Reason, reason) {
-			tx.DeleteStruct(r)
-		}
+	defer tx.Rollback()
+
+	err = softDeleteUnscanRecords(tx, notFound, UnscanDeleteTxNotFound)
+	if err != nil {
+		return err
 	}
 	return tx.Commit()
 }
@@ -1095,6 +1664,15 @@ func (bs *NEOBlockScanner) GetScannedBlockHeader() (*openwallet.BlockHeader, err
 
 	blockHeight, hash = bs.wm.GetLocalNewBlock()
 
+	//首次部署时，优先以浏览器的最新高度作为扫描起点
+	if blockHeight == 0 && bs.wm.config().WarmStartFromExplorer {
+		header, warmErr := bs.getWarmStartBlockHeader()
+		if warmErr == nil {
+			return header, nil
+		}
+		bs.wm.Log.Std.Warning("block scanner warm start from explorer failed; unexpected error: %v", warmErr)
+	}
+
 	//如果本地没有记录，查询接口的高度
 	if blockHeight == 0 {
 		blockHeight, err = bs.wm.GetBlockHeight()
@@ -1115,6 +1693,38 @@ func (bs *NEOBlockScanner) GetScannedBlockHeader() (*openwallet.BlockHeader, err
 	return &openwallet.BlockHeader{Height: blockHeight, Hash: hash}, nil
 }
 
+//getWarmStartBlockHeader 以浏览器最新高度减去确认数作为扫描起点，避免核心节点落后时起点不一致
+func (bs *NEOBlockScanner) getWarmStartBlockHeader() (*openwallet.BlockHeader, error) {
+
+	if bs.wm.explorerClient() == nil {
+		return nil, errors.New("explorer API is not setup")
+	}
+
+	tip, err := bs.wm.getBlockHeightByExplorer()
+	if err != nil {
+		return nil, err
+	}
+
+	if tip <= bs.wm.config().ConfirmBlocks {
+		return nil, fmt.Errorf("explorer tip height: %d is not greater than confirm blocks: %d", tip, bs.wm.config().ConfirmBlocks)
+	}
+
+	blockHeight := tip - bs.wm.config().ConfirmBlocks
+
+	hash, err := bs.wm.getBlockHashByExplorer(blockHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hash) == 0 {
+		return nil, fmt.Errorf("explorer can not find block hash on height: %d", blockHeight)
+	}
+
+	bs.wm.Log.Std.Info("block scanner warm start from explorer tip: %d, start height: %d", tip, blockHeight)
+
+	return &openwallet.BlockHeader{Height: blockHeight, Hash: hash}, nil
+}
+
 //GetCurrentBlockHeader 获取当前区块高度
 func (bs *NEOBlockScanner) GetCurrentBlockHeader() (*openwallet.BlockHeader, error) {
 
@@ -1155,6 +1765,9 @@ func (bs *NEOBlockScanner) GetScannedBlockHeight() uint64 {
 
 func (bs *NEOBlockScanner) ExtractTransactionData(txid string, scanTargetFunc openwallet.BlockScanTargetFunc) (map[string][]*openwallet.TxExtractData, error) {
 
+	bs.wm.scanCycleMu.RLock()
+	defer bs.wm.scanCycleMu.RUnlock()
+
 	scanAddressFunc := func(address string) (string, bool) {
 		target := openwallet.ScanTarget{
 			Address:          address,
@@ -1243,7 +1856,7 @@ func (bs *NEOBlockScanner) SaveUnscanRecord(record *UnscanRecord) error {
 	}
 
 	//获取本地区块高度
-	db, err := storm.Open(filepath.Join(bs.wm.Config.DBPath, bs.wm.Config.BlockchainFile))
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
 	if err != nil {
 		return err
 	}
@@ -1267,25 +1880,31 @@ func (bs *NEOBlockScanner) SaveUnscanRecord(record *UnscanRecord) error {
 //	}
 //}
 
-//GetBlockHeight 获取区块链高度
+//GetBlockHeight 获取区块链高度，即可扫描的最高区块索引
 func (wm *WalletManager) GetBlockHeight() (uint64, error) {
-
-	if wm.Config.RPCServerType == RPCServerExplorer {
-		return wm.getBlockHeightByExplorer()
-	} else {
-		return wm.getBlockHeightByCore()
-	}
+	return wm.ScannableTipHeight()
 }
 
-//getBlockHeightByCore 获取区块链高度
+//getBlockHeightByCore 获取节点的最高区块索引，neo-cli为区块数量减1
 func (wm *WalletManager) getBlockHeightByCore() (uint64, error) {
 
-	result, err := wm.WalletClient.Call("getblockcount", []interface{}{})
+	count, err := wm.getBlockCountByCore()
 	if err != nil {
 		return 0, err
 	}
 
-	return result.Uint()-1, nil
+	offset := wm.HeightOffset()
+	if count < offset {
+		return 0, nil
+	}
+
+	return count - offset, nil
+}
+
+//getBlockCountByCore 获取节点getblockcount的原始返回值
+func (wm *WalletManager) getBlockCountByCore() (uint64, error) {
+
+	return wm.rpc().GetBlockCount()
 }
 
 //GetLocalNewBlock 获取本地记录的区块高度和hash
@@ -1297,8 +1916,9 @@ func (wm *WalletManager) GetLocalNewBlock() (uint64, string) {
 	)
 
 	//获取本地区块高度
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
+		wm.Log.Std.Error("get local new block failed, unexpected error: %v", err)
 		return 0, ""
 	}
 	defer db.Close()
@@ -1310,35 +1930,95 @@ func (wm *WalletManager) GetLocalNewBlock() (uint64, string) {
 }
 
 //SaveLocalNewBlock 记录区块高度和hash到本地
-func (wm *WalletManager) SaveLocalNewBlock(blockHeight uint64, blockHash string) {
+func (wm *WalletManager) SaveLocalNewBlock(blockHeight uint64, blockHash string) error {
 
 	//获取本地区块高度
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
-		return
+		return err
 	}
 	defer db.Close()
 
-	db.Set(blockchainBucket, "blockHeight", &blockHeight)
-	db.Set(blockchainBucket, "blockHash", &blockHash)
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = putLocalNewBlock(tx, blockHeight, blockHash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 //SaveLocalBlock 记录本地新区块
-func (wm *WalletManager) SaveLocalBlock(block *Block) {
+func (wm *WalletManager) SaveLocalBlock(block *Block) error {
 
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
-		return
+		return err
+	}
+	defer db.Close()
+
+	return putLocalBlock(db, block)
+}
+
+//saveLocalBlockAndHead 在一个事务中保存区块和本地区块头，区块保存失败时高度不推进
+func (wm *WalletManager) saveLocalBlockAndHead(block *Block, blockHeight uint64, blockHash string) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
 	}
 	defer db.Close()
 
-	db.Save(block)
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = putLocalBlock(tx, block)
+	if err != nil {
+		return err
+	}
+
+	err = putLocalNewBlock(tx, blockHeight, blockHash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//putLocalNewBlock 写入区块高度和hash，高度和hash同时写入，不会出现不匹配
+func putLocalNewBlock(tx storm.Node, blockHeight uint64, blockHash string) error {
+
+	err := tx.Set(blockchainBucket, "blockHeight", &blockHeight)
+	if err != nil {
+		return err
+	}
+
+	return tx.Set(blockchainBucket, "blockHash", &blockHash)
+}
+
+//putLocalBlock 写入区块，本地只记录交易id，不保存交易详情
+func putLocalBlock(tx storm.Node, block *Block) error {
+
+	block.SchemaVersion = SchemaVersion
+
+	local := *block
+	local.TxDetails = nil
+	local.isVerbose = false
+	return tx.Save(&local)
 }
 
 //GetBlockHash 根据区块高度获得区块hash
 func (wm *WalletManager) GetBlockHash(height uint64) (string, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
+	if wm.config().RPCServerType == RPCServerExplorer {
 		return wm.getBlockHashByExplorer(height)
 	} else {
 		return wm.getBlockHashByCore(height)
@@ -1348,17 +2028,13 @@ func (wm *WalletManager) GetBlockHash(height uint64) (string, error) {
 //getBlockHashByCore 根据区块高度获得区块hash
 func (wm *WalletManager) getBlockHashByCore(height uint64) (string, error) {
 
-	request := []interface{}{
-		height,
-	}
-
-	result, err := wm.WalletClient.Call("getblockhash", request)
+	hash, err := wm.rpc().GetBlockHash(height)
 	if err != nil {
 		fmt.Println(fmt.Sprintf("current height : %d, error : %s", height, err.Error()))
 		return "", err
 	}
 
-	return result.String(), nil
+	return hash, nil
 }
 
 //GetLocalBlock 获取本地区块数据
@@ -1368,7 +2044,7 @@ func (wm *WalletManager) GetLocalBlock(height uint64) (*Block, error) {
 		block Block
 	)
 
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
 		return nil, err
 	}
@@ -1379,16 +2055,24 @@ func (wm *WalletManager) GetLocalBlock(height uint64) (*Block, error) {
 		return nil, err
 	}
 
+	if block.SchemaVersion > SchemaVersion {
+		return nil, wm.errorf(ErrLocalDBSchemaUnsupported, "local block schema version: %d is newer than adapter: %d", block.SchemaVersion, SchemaVersion)
+	}
+
 	return &block, nil
 }
 
-//GetBlock 获取区块数据
-func (wm *WalletManager) GetBlock(hash string) (*Block, error) {
+//GetBlock 获取区块数据，detail指定数据完整程度，默认包含完整交易详情
+func (wm *WalletManager) GetBlock(hash string, detail ...BlockDetail) (*Block, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
-		return wm.getBlockByExplorer(hash)
+	if wm.config().RPCServerType == RPCServerExplorer {
+		block, err := wm.getBlockByExplorer(hash)
+		if err != nil {
+			return nil, err
+		}
+		return trimBlock(block, blockDetail(detail)), nil
 	} else {
-		return wm.getBlockByCore(hash)
+		return wm.getBlockByCoreWithDetail(hash, blockDetail(detail))
 	}
 }
 
@@ -1404,18 +2088,22 @@ func (wm *WalletManager) getBlockByCore(hash string, format ...uint64) (*Block,
 		request = append(request, format[0])
 	}
 
-	result, err := wm.WalletClient.Call("getblock", request)
+	result, err := wm.readClient().Call("getblock", request)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = validateBlockResult(result); err != nil {
+		return nil, err
+	}
+
 	return wm.NewBlock(result), nil
 }
 
 //GetTxIDsInMemPool 获取待处理的交易池中的交易单IDs
 func (wm *WalletManager) GetTxIDsInMemPool() ([]string, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
+	if wm.config().RPCServerType == RPCServerExplorer {
 		return wm.getTxIDsInMemPoolByExplorer()
 	} else {
 		return wm.getTxIDsInMemPoolByCore()
@@ -1425,32 +2113,13 @@ func (wm *WalletManager) GetTxIDsInMemPool() ([]string, error) {
 //getTxIDsInMemPoolByCore 获取待处理的交易池中的交易单IDs
 func (wm *WalletManager) getTxIDsInMemPoolByCore() ([]string, error) {
 
-	var (
-		txids = make([]string, 0)
-	)
-
-	request := []interface{}{}
-
-	result, err := wm.WalletClient.Call("getrawmempool", request)
-	if err != nil {
-		return nil, err
-	}
-
-	if !result.IsArray() {
-		return nil, errors.New("no query record")
-	}
-
-	for _, txid := range result.Array() {
-		txids = append(txids, txid.String())
-	}
-
-	return txids, nil
+	return wm.rpc().GetRawMempool()
 }
 
 //GetTransaction 获取交易单
 func (wm *WalletManager) GetTransaction(txid string) (*Transaction, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
+	if wm.config().RPCServerType == RPCServerExplorer {
 		return wm.getTransactionByExplorer(txid)
 	} else {
 		return wm.getTransactionByCore(txid)
@@ -1470,7 +2139,7 @@ func (wm *WalletManager) getTransactionByCore(txid string) (*Transaction, error)
 		true,
 	}
 
-	result, err = wm.WalletClient.Call("getrawtransaction", request)
+	result, err = wm.readClient().Call("getrawtransaction", request)
 	if err != nil {
 
 		request = []interface{}{
@@ -1478,19 +2147,23 @@ func (wm *WalletManager) getTransactionByCore(txid string) (*Transaction, error)
 			1,
 		}
 
-		result, err = wm.WalletClient.Call("getrawtransaction", request)
+		result, err = wm.readClient().Call("getrawtransaction", request)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return wm.newTxByCore(result), nil
+	if err = validateTransactionResult(result); err != nil {
+		return nil, err
+	}
+
+	return NewTransaction(result), nil
 }
 
 //GetTxOut 获取交易单输出信息，用于追溯交易单输入源头
 func (wm *WalletManager) GetTxOut(txid string, vout uint64) (*Vout, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
+	if wm.config().RPCServerType == RPCServerExplorer {
 		return wm.getTxOutByExplorer(txid, vout)
 	} else {
 		return wm.getTxOutByCore(txid, vout)
@@ -1500,18 +2173,11 @@ func (wm *WalletManager) GetTxOut(txid string, vout uint64) (*Vout, error) {
 //getTxOutByCore 获取交易单输出信息，用于追溯交易单输入源头
 func (wm *WalletManager) getTxOutByCore(txid string, vout uint64) (*Vout, error) {
 
-	request := []interface{}{
-		txid,
-		vout,
-	}
-
-	result, err := wm.WalletClient.Call("gettxout", request)
+	output, err := wm.rpc().GetTxOut(txid, vout)
 	if err != nil {
 		return nil, err
 	}
 
-	output := newTxVoutByCore(result)
-
 	/*
 		{
 			"bestblock": "0000000000012164c0fb1f7ac13462211aaaa83856073bf94faf2ea9c6ea193a",
@@ -1533,27 +2199,35 @@ func (wm *WalletManager) getTxOutByCore(txid string, vout uint64) (*Vout, error)
 
 }
 
-//获取未扫记录
+//获取未扫记录，不包括已软删除的记录
 func (wm *WalletManager) GetUnscanRecords() ([]*UnscanRecord, error) {
 	//获取本地区块高度
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
 	var list []*UnscanRecord
-	err = db.All(&list)
-	if err != nil {
+	err = db.Select(q.Eq("Deleted", false)).Find(&list)
+	if err != nil && err != storm.ErrNotFound {
 		return nil, err
 	}
 	return list, nil
 }
 
-//DeleteUnscanRecord 删除指定高度的未扫记录
+//DeleteUnscanRecord 软删除指定高度的未扫记录，清理前可用RestoreUnscanRecord恢复
 func (wm *WalletManager) DeleteUnscanRecord(height uint64) error {
+	if err := wm.requireCapability(CapabilityDeleteUnscan); err != nil {
+		return err
+	}
+
+	return wm.deleteUnscanRecord(height)
+}
+
+func (wm *WalletManager) deleteUnscanRecord(height uint64) error {
 	//获取本地区块高度
-	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
 	if err != nil {
 		return err
 	}
@@ -1565,11 +2239,57 @@ func (wm *WalletManager) DeleteUnscanRecord(height uint64) error {
 		return err
 	}
 
-	for _, r := range list {
-		db.DeleteStruct(r)
+	return softDeleteUnscanRecords(db, list, UnscanDeleteRescanned)
+}
+
+//DeleteLocalDataAboveHeight 删除本地高于指定高度的区块和未扫记录
+func (wm *WalletManager) DeleteLocalDataAboveHeight(height uint64) error {
+	if err := wm.requireCapability(CapabilityDeleteLocal); err != nil {
+		return err
 	}
 
-	return nil
+	return wm.deleteLocalDataAboveHeight(height)
+}
+
+func (wm *WalletManager) deleteLocalDataAboveHeight(height uint64) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var blocks []*Block
+	err = db.Select(q.Gt("Height", height)).Find(&blocks)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	var records []*UnscanRecord
+	err = db.Select(q.Gt("BlockHeight", height)).Find(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, b := range blocks {
+		err = tx.DeleteStruct(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = softDeleteUnscanRecords(tx, records, UnscanDeleteRollback)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 //GetAssetsAccountBalanceByAddress 查询账户相关地址的交易记录
@@ -1654,14 +2374,42 @@ func (wm *WalletManager) calculateUnspent(utxos []*UnspentBalance) map[string]*o
 
 }
 
-//GetAssetsAccountTransactionsByAddress 查询账户相关地址的交易记录
+//GetAssetsAccountTransactionsByAddress 查询账户相关地址的交易记录，
+//启用分页快照时offset为0的查询记录当前区块，同一查询的后续页锚定到该区块
 func (bs *NEOBlockScanner) GetTransactionsByAddress(offset, limit int, coin openwallet.Coin, address ...string) ([]*openwallet.TxExtractData, error) {
 
+	snapshot, err := bs.txPageSnapshot(offset, coin, address)
+	if err != nil {
+		return nil, err
+	}
+
+	array, err := bs.GetTransactionsByAddressAt(snapshot, offset, limit, coin, address...)
+	if err != nil {
+		if openErr, ok := err.(*openwallet.Error); ok && openErr.Code() == ErrTxPageSnapshotStale {
+			bs.dropTxPageSnapshot(coin, address)
+		}
+		return nil, err
+	}
+
+	return array, nil
+}
+
+//GetTransactionsByAddressAt 查询账户相关地址在快照区块及之前的交易记录，snapshot为nil时包含最新和未确认的交易单
+func (bs *NEOBlockScanner) GetTransactionsByAddressAt(snapshot *TxPageSnapshot, offset, limit int, coin openwallet.Coin, address ...string) ([]*openwallet.TxExtractData, error) {
+
 	var (
-		array = make([]*openwallet.TxExtractData, 0)
+		array     = make([]*openwallet.TxExtractData, 0)
+		maxHeight uint64
 	)
 
-	trxs, err := bs.wm.getMultiAddrTransactionsByExplorer(offset, limit, address...)
+	if snapshot != nil {
+		if err := bs.wm.verifyTxPageSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		maxHeight = snapshot.Height
+	}
+
+	trxs, err := bs.wm.getMultiAddrTransactionsByExplorer(offset, limit, maxHeight, address...)
 	if err != nil {
 		return nil, err
 	}
@@ -1692,23 +2440,72 @@ func (bs *NEOBlockScanner) GetTransactionsByAddress(offset, limit int, coin open
 		data := result.extractData
 		txExtract := data[key]
 		if txExtract != nil {
+			bs.wm.attachTxLabel(txExtract.Transaction)
 			array = append(array, txExtract)
 		}
 	}
 
+	//按当前高度计算确认数
+	if err := bs.wm.enrichConfirmations(array, bs.wm.config().HydrateMempoolRecords); err != nil {
+		return nil, err
+	}
+
 	return array, nil
 }
 
 //Run 运行
 func (bs *NEOBlockScanner) Run() error {
 
+	//恢复已创建的多重签名账户的观测
+	bs.loadMultiSigAccounts()
+
+	//自检通过后才开始扫描
+	if bs.wm.config().SelfTestBeforeRun {
+		bs.waitSelfTest()
+	}
+
+	//节点重新同步到其他链时，回滚本地已扫描区块到共同祖先
+	if bs.wm.config().StartupHeadCheck {
+		if _, err := bs.checkLocalHeadConsistency(); err != nil {
+			bs.wm.Log.Std.Error("check local head consistency failed, unexpected error: %v", err)
+		}
+	}
+
+	//每次启动后重新同步内存池
+	bs.mempoolSynced = false
+
 	//使用浏览器，开启socketIO监听内存池交易
-	if bs.wm.Config.RPCServerType == RPCServerExplorer {
-		if bs.socketIO == nil {
-			go bs.setupSocketIO()
+	bs.startSocketIO()
+
+	//启动内嵌浏览器HTTP接口
+	if len(bs.wm.config().ExplorerListen) > 0 {
+		if bs.explorer == nil {
+			bs.explorer = NewExplorerServer(bs)
+		}
+		if err := bs.explorer.Start(bs.wm.config().ExplorerListen); err != nil {
+			bs.wm.Log.Std.Error("explorer api listen on %s failed, unexpected error: %v", bs.wm.config().ExplorerListen, err)
 		}
 	}
 
+	//定时刷新跟踪的代币合约元数据，配置了跟踪资产文件时运行期间可能上架新代币
+	if (len(bs.wm.config().TokenContracts) > 0 || len(bs.wm.config().TrackedAssetsFile) > 0) && bs.tokenRefresh == nil {
+		bs.tokenRefresh = bs.wm.startTrackedTokenRefresh(bs.wm.config().TokenMetadataRefreshInterval)
+	}
+
+	//定时重新加载跟踪资产文件
+	if len(bs.wm.config().TrackedAssetsFile) > 0 && bs.assetsReload == nil {
+		bs.assetsReload = bs.wm.startTrackedAssetsReload(bs.wm.config().TrackedAssetsReloadInterval)
+	}
+
+	//定时补发未投递的通知，包括重启前未完成的
+	if bs.wm.config().NotifyOutbox && bs.notifyRedeliver == nil {
+		bs.notifyRedeliver = bs.startNotifyRedeliver(bs.wm.config().NotifyRedeliverInterval)
+	}
+
+	//调度维护任务
+	bs.wm.addConfiguredJobs()
+	bs.wm.Scheduler.Start()
+
 	bs.BlockScannerBase.Run()
 
 	return nil
@@ -1717,21 +2514,43 @@ func (bs *NEOBlockScanner) Run() error {
 ////Stop 停止扫描
 func (bs *NEOBlockScanner) Stop() error {
 
-	if bs.socketIO != nil {
-		bs.socketIO.Close()
-		bs.socketIO = nil
+	bs.stopSocketIOListen()
+
+	bs.BlockScannerBase.Stop()
+
+	if bs.explorer != nil {
+		bs.explorer.Stop()
 	}
 
-	//通知停止线程
-	bs.stopSocketIO <- struct{}{}
+	if bs.tokenRefresh != nil {
+		bs.tokenRefresh.Stop()
+		bs.tokenRefresh = nil
+	}
 
-	bs.BlockScannerBase.Stop()
+	if bs.assetsReload != nil {
+		bs.assetsReload.Stop()
+		bs.assetsReload = nil
+	}
+
+	if bs.notifyRedeliver != nil {
+		bs.notifyRedeliver.Stop()
+		bs.notifyRedeliver = nil
+	}
+
+	bs.wm.Scheduler.Stop()
+
+	//释放扫描租约，其他实例可立即接管
+	if bs.wm.config().ScanLeaseTTL > 0 {
+		if err := bs.wm.ReleaseScanLease(); err != nil {
+			bs.wm.Log.Std.Warning("block scanner release scan lease failed; unexpected error: %v", err)
+		}
+	}
 	return nil
 }
 
 //Pause 暂停扫描
 func (bs *NEOBlockScanner) Pause() error {
-	if bs.wm.Config.RPCServerType == RPCServerExplorer {
+	if bs.wm.config().RPCServerType == RPCServerExplorer {
 		return nil
 	} else {
 		bs.BlockScannerBase.Pause()
@@ -1741,7 +2560,7 @@ func (bs *NEOBlockScanner) Pause() error {
 
 //Restart 继续扫描
 func (bs *NEOBlockScanner) Restart() error {
-	if bs.wm.Config.RPCServerType == RPCServerExplorer {
+	if bs.wm.config().RPCServerType == RPCServerExplorer {
 		return nil
 	} else {
 		bs.BlockScannerBase.Restart()
@@ -1751,13 +2570,47 @@ func (bs *NEOBlockScanner) Restart() error {
 
 /******************* 使用insight socket.io 监听区块 *******************/
 
+//startSocketIO 浏览器模式且websocketSubscriber开启时，启动socketIO监听内存池交易
+func (bs *NEOBlockScanner) startSocketIO() {
+	if bs.wm.config().RPCServerType != RPCServerExplorer || !bs.wm.FeatureEnabled(FeatureWebsocketSubscriber) {
+		return
+	}
+	if bs.socketIO == nil {
+		go bs.setupSocketIO()
+	}
+}
+
+//stopSocketIOListen 关闭socketIO连接并停止重连线程
+func (bs *NEOBlockScanner) stopSocketIOListen() {
+
+	if bs.socketIO != nil {
+		bs.socketIO.Close()
+		bs.socketIO = nil
+	}
+
+	//通知停止线程，未开启socketIO监听时没有接收者
+	select {
+	case bs.stopSocketIO <- struct{}{}:
+	default:
+	}
+}
+
+//syncSocketIO 运行时切换websocketSubscriber后，扫描器运行中时立即启动或停止socketIO监听
+func (bs *NEOBlockScanner) syncSocketIO() {
+	if !bs.wm.FeatureEnabled(FeatureWebsocketSubscriber) {
+		bs.stopSocketIOListen()
+	} else if bs.Scanning {
+		bs.startSocketIO()
+	}
+}
+
 func (bs *NEOBlockScanner) connectSocketIO(disconnected chan struct{}) (*gosocketio.Client, error) {
 
 	var (
 		room = "inv"
 	)
 
-	apiUrl, err := url.Parse(bs.wm.Config.ServerAPI)
+	apiUrl, err := url.Parse(bs.wm.config().ServerAPI)
 	if err != nil {
 		return nil, err
 	}
@@ -1887,26 +2740,15 @@ func (bs *NEOBlockScanner) setupSocketIO() error {
 	return nil
 }
 
-// GetBlockByHeight 获取指定区块高度的区块信息
-func (wm *WalletManager) GetBlockByHeight(height uint64, format ...interface{}) (*Block, error) {
-	return wm.getBlockByHeightOnCore(height, format)
-}
-
-func (wm *WalletManager) getBlockByHeightOnCore(height uint64, format ...interface{}) (*Block, error) {
-	request := []interface{}{
-		height,
-	}
-
-	if len(format) > 0 {
-		request = append(request, format...)
-	}
+// GetBlockByHeight 获取指定区块高度的区块信息，detail指定数据完整程度，默认包含完整交易详情
+func (wm *WalletManager) GetBlockByHeight(height uint64, detail ...BlockDetail) (*Block, error) {
 
-	result, err := wm.WalletClient.Call("getblock", request)
+	hash, err := wm.GetBlockHash(height)
 	if err != nil {
 		return nil, err
 	}
 
-	return wm.NewBlock(result), nil
+	return wm.GetBlock(hash, detail...)
 }
 
 // GetBestBlockHash 获取主链中高度最大的区块的hash
@@ -1915,11 +2757,7 @@ func (wm *WalletManager) GetBestBlockHash() (string, error) {
 }
 
 func (wm *WalletManager) getBestBlockHash() (string, error) {
-	result, err := wm.WalletClient.Call("getbestblockhash", []interface{}{})
-	if err != nil {
-		return "", err
-	}
-	return result.String(), nil
+	return wm.rpc().GetBestBlockHash()
 }
 
 //GetLocalNewBlock 获取本地记录的区块高度和hash
@@ -2005,6 +2843,10 @@ func (bs *NEOBlockScanner) GetUnscanRecords() ([]*openwallet.UnscanRecord, error
 
 //DeleteUnscanRecord 删除指定高度的未扫记录
 func (bs *NEOBlockScanner) DeleteUnscanRecord(height uint64) error {
+	if err := bs.wm.requireCapability(CapabilityDeleteUnscan); err != nil {
+		return err
+	}
+
 	if bs.BlockchainDAI == nil {
 		return fmt.Errorf("Blockchain DAI is not setup ")
 	}
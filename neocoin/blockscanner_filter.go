@@ -0,0 +1,250 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/bloom"
+)
+
+const (
+	addressFilterFPRate = 0.0001 //观测地址过滤器误判率
+)
+
+//SetWatchAddressFilter 用观测地址重建布隆过滤器，提取时先过滤不可能匹配的地址，减少ScanAddressFunc的调用
+//addresses为空时关闭预过滤
+func (bs *NEOBlockScanner) SetWatchAddressFilter(addresses ...string) {
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if len(addresses) == 0 {
+		bs.addressFilter = nil
+		bs.watchAddresses = make(map[string]string)
+		bs.watchTTL = nil
+		return
+	}
+
+	bs.watchAddresses = make(map[string]string, len(addresses))
+	for _, a := range addresses {
+		bs.watchAddresses[a] = ""
+	}
+
+	//不再观测的地址去掉有效期
+	for a := range bs.watchTTL {
+		if _, ok := bs.watchAddresses[a]; !ok {
+			delete(bs.watchTTL, a)
+		}
+	}
+
+	bs.rebuildAddressFilter()
+}
+
+//rebuildAddressFilter 按观测地址集合重建布隆过滤器，调用者需持有写锁
+func (bs *NEOBlockScanner) rebuildAddressFilter() {
+
+	if len(bs.watchAddresses) == 0 {
+		bs.addressFilter = nil
+		return
+	}
+
+	filter := bloom.NewFilter(uint32(len(bs.watchAddresses)), 0, addressFilterFPRate, wire.BloomUpdateNone)
+	for a := range bs.watchAddresses {
+		filter.Add([]byte(a))
+	}
+
+	bs.addressFilter = filter
+}
+
+//AddWatchAddressToFilter 添加观测地址到布隆过滤器，过滤器未开启时不处理
+func (bs *NEOBlockScanner) AddWatchAddressToFilter(addresses ...string) {
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if bs.addressFilter == nil {
+		return
+	}
+
+	for _, a := range addresses {
+		if _, ok := bs.watchAddresses[a]; !ok {
+			bs.watchAddresses[a] = ""
+		}
+		bs.addressFilter.Add([]byte(a))
+	}
+}
+
+//watchAddressRecord 批量导入的观测地址记录
+type watchAddressRecord struct {
+	Address string `json:"address"`
+	Account string `json:"account"`
+}
+
+//ImportWatchAddresses 批量导入观测地址到观测地址集合和布隆过滤器，
+//支持CSV（address[,account]，可带表头）和NDJSON（{"address":"","account":""}）两种格式。
+//带有账户标记的地址提取时直接归属该账户，不再调用ScanAddressFunc，返回导入的地址数量
+func (bs *NEOBlockScanner) ImportWatchAddresses(reader io.Reader) (int, error) {
+
+	r := bufio.NewReader(reader)
+
+	//根据第一个非空白字符判断格式
+	isJSON := false
+	for {
+		b, err := r.Peek(1)
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(string(b)) == "" {
+			r.ReadByte()
+			continue
+		}
+		isJSON = b[0] == '{'
+		break
+	}
+
+	imported := make(map[string]string)
+	var err error
+	if isJSON {
+		err = readWatchAddressNDJSON(r, imported)
+	} else {
+		err = readWatchAddressCSV(r, imported)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if bs.watchAddresses == nil {
+		bs.watchAddresses = make(map[string]string, len(imported))
+	}
+	for address, account := range imported {
+		bs.watchAddresses[address] = account
+	}
+
+	bs.rebuildAddressFilter()
+
+	bs.wm.Log.Std.Info("block scanner imported %d watch addresses, total: %d", len(imported), len(bs.watchAddresses))
+
+	return len(imported), nil
+}
+
+//readWatchAddressNDJSON 读取每行一个json对象的观测地址
+func readWatchAddressNDJSON(r io.Reader, imported map[string]string) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var record watchAddressRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decode watch address record: %d failed, unexpected error: %v", line, err)
+		}
+		if len(record.Address) == 0 {
+			continue
+		}
+		imported[record.Address] = record.Account
+	}
+}
+
+//readWatchAddressCSV 读取address[,account]格式的观测地址
+func readWatchAddressCSV(r io.Reader, imported map[string]string) error {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	csvReader.ReuseRecord = true
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read watch address line: %d failed, unexpected error: %v", line, err)
+		}
+		address := strings.TrimSpace(record[0])
+		//跳过表头和空行
+		if len(address) == 0 || (line == 1 && strings.EqualFold(address, "address")) {
+			continue
+		}
+		account := ""
+		if len(record) > 1 {
+			account = strings.TrimSpace(record[1])
+		}
+		imported[address] = account
+	}
+}
+
+//filterScanAddressFunc 包装ScanAddressFunc，不在过滤器中的地址直接跳过
+func (bs *NEOBlockScanner) filterScanAddressFunc(scanAddressFunc openwallet.BlockScanAddressFunc) openwallet.BlockScanAddressFunc {
+
+	bs.Mu.RLock()
+	filter := bs.addressFilter
+	multiSig := len(bs.multiSigWatch) > 0
+	bs.Mu.RUnlock()
+
+	if filter == nil && !multiSig {
+		return scanAddressFunc
+	}
+
+	return func(address string) (string, bool) {
+		//创建的多重签名账户地址直接归属
+		if account, ok := bs.multiSigAccount(address); ok {
+			return account, true
+		}
+
+		if filter == nil {
+			if scanAddressFunc == nil {
+				return "", false
+			}
+			return scanAddressFunc(address)
+		}
+
+		if len(address) == 0 || !filter.Matches([]byte(address)) {
+			return "", false
+		}
+
+		//过期的观测地址不再匹配
+		if !bs.matchWatchAddressTTL(address) {
+			return "", false
+		}
+
+		//导入时带账户标记的地址直接归属
+		bs.Mu.RLock()
+		account := bs.watchAddresses[address]
+		bs.Mu.RUnlock()
+		if len(account) > 0 {
+			return account, true
+		}
+
+		if scanAddressFunc == nil {
+			return "", false
+		}
+		return scanAddressFunc(address)
+	}
+}
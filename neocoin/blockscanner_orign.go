@@ -123,7 +123,11 @@ func (bs *NEOBlockScanner) ScanBlockTaskOrigin() {
 			bs.wm.Log.Std.Info("rescan block on height: %d, hash: %s .", currentHeight, currentHash)
 
 			//重新记录一个新扫描起点
-			bs.wm.SaveLocalNewBlock(localBlock.Height, localBlock.Hash)
+			err = bs.wm.SaveLocalNewBlock(localBlock.Height, localBlock.Hash)
+			if err != nil {
+				bs.wm.Log.Std.Error("block height: %d save local new block failed; unexpected error: %v", localBlock.Height, err)
+				break
+			}
 
 			isFork = true
 
@@ -145,9 +149,12 @@ func (bs *NEOBlockScanner) ScanBlockTaskOrigin() {
 			//重置当前区块的hash
 			currentHash = hash
 
-			//保存本地新高度
-			bs.wm.SaveLocalNewBlock(currentHeight, currentHash)
-			bs.wm.SaveLocalBlock(block)
+			//保存本地新高度，保存失败时不推进高度
+			err = bs.wm.saveLocalBlockAndHead(block, currentHeight, currentHash)
+			if err != nil {
+				bs.wm.Log.Std.Error("block height: %d save local block failed; unexpected error: %v", currentHeight, err)
+				break
+			}
 
 			isFork = false
 
@@ -183,7 +190,7 @@ func (bs *NEOBlockScanner) ScanTxMemPoolOrigin() {
 		Height:    0,
 		Hash:      "",
 		isVerbose: false,
-		tx:        txIDsInMemPool,
+		Tx:        txIDsInMemPool,
 	}
 
 	err = bs.BatchExtractTransactionOrigin(block)
@@ -201,10 +208,10 @@ func (bs *NEOBlockScanner) BatchExtractTransactionOrigin(block *Block) error {
 
 	var (
 		done       = 0 //完成标记
-		shouldDone = len(block.tx) //需要完成的总数
+		shouldDone = len(block.Tx) //需要完成的总数
 	)
 
-	if len(block.tx) == 0 {
+	if len(block.Tx) == 0 {
 		return fmt.Errorf("BatchExtractTransaction block is nil.")
 	}
 
@@ -229,8 +236,8 @@ func (bs *NEOBlockScanner) BatchExtractTransactionOrigin(block *Block) error {
 				if ok {
 
 					if txResult.Success {
-						block.txDetails = append(block.txDetails, txResult.Tx)
-						//bs.wm.Log.Debugf("txDetails Length = %d", len(block.txDetails))
+						block.TxDetails = append(block.TxDetails, txResult.Tx)
+						//bs.wm.Log.Debugf("txDetails Length = %d", len(block.TxDetails))
 					}
 
 				}
@@ -282,7 +289,7 @@ func (bs *NEOBlockScanner) BatchExtractTransactionOrigin(block *Block) error {
 	go saveWork(worker)
 
 	//独立线程运行生产
-	go extractWork(block.tx, producer)
+	go extractWork(block.Tx, producer)
 
 	//以下使用生产消费模式
 	concurrent.ProducerToConsumerRuntime(producer, worker)
@@ -323,7 +330,7 @@ func (bs *NEOBlockScanner) RemoveBTCBlockObserver(obj NEOBlockScanNotificationOb
 func (bs *NEOBlockScanner) NewBTCBlockNotify(block *Block, isFork bool) {
 	block.Fork = isFork
 	for o, _ := range bs.NEOBlockObservers {
-		o.NEOBlockScanNotify(block, block.txDetails)
+		o.NEOBlockScanNotify(block, block.TxDetails)
 	}
 }
 
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestWalletManager_CallRaw(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "getnep5balances" {
+			return map[string]interface{}{"address": params[0], "balance": []interface{}{}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	if _, err := wm.CallRaw("getnep5balances", nil); err == nil {
+		t.Errorf("CallRaw without node client should fail")
+	}
+
+	wm.WalletClient = NewClient(server.URL, "", false)
+	result, err := wm.CallRaw("getnep5balances", []interface{}{"AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"})
+	if err != nil {
+		t.Errorf("CallRaw failed unexpected error: %v\n", err)
+		return
+	}
+	if result.Get("address").String() != "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y" {
+		t.Errorf("unexpected result: %s", result.Raw)
+	}
+
+	if _, err := wm.CallRaw("", nil); err == nil {
+		t.Errorf("empty method should fail")
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sort"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ChangeDustToFees          = 0 //找零低于粉尘阈值时并入手续费
+	ChangeDustToLargestOutput = 1 //找零低于粉尘阈值时并入金额最大的输出
+)
+
+//handleChangeDust 找零低于粉尘阈值时按策略处理，避免产生无法花费的粉尘UTXO，
+//返回处理后的找零和手续费，处理结果记录到交易单的ExtParam
+func (decoder *TransactionDecoder) handleChangeDust(rawTx *openwallet.RawTransaction, outputAddrs map[string]decimal.Decimal, changeAmount, fees decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+
+	threshold := decoder.wm.config().ChangeDustThreshold
+	if !changeAmount.GreaterThan(decimal.Zero) || !changeAmount.LessThan(threshold) {
+		return changeAmount, fees
+	}
+
+	dust := decoder.wm.FormatGAS(changeAmount)
+
+	switch decoder.wm.config().ChangeDustPolicy {
+	case ChangeDustToLargestOutput:
+		//金额相同时按地址排序，保证结果稳定
+		addrs := make([]string, 0, len(outputAddrs))
+		for addr := range outputAddrs {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+
+		largest := ""
+		for _, addr := range addrs {
+			if largest == "" || outputAddrs[addr].GreaterThan(outputAddrs[largest]) {
+				largest = addr
+			}
+		}
+
+		if largest != "" {
+			outputAddrs[largest] = outputAddrs[largest].Add(changeAmount)
+			rawTx.SetExtParam("changeDust", map[string]string{
+				"amount":   dust,
+				"decision": "largestOutput",
+				"address":  largest,
+			})
+			decoder.wm.Log.Std.Notice("Change dust: %s folded into output: %s", dust, largest)
+			return decimal.Zero, fees
+		}
+	}
+
+	fees = fees.Add(changeAmount)
+	rawTx.Fees = decoder.wm.FormatGAS(fees)
+	decoder.setFeesFiat(rawTx, fees)
+	rawTx.SetExtParam("changeDust", map[string]string{
+		"amount":   dust,
+		"decision": "fees",
+	})
+	decoder.wm.Log.Std.Notice("Change dust: %s folded into fees", dust)
+
+	return decimal.Zero, fees
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/shopspring/decimal"
+)
+
+//CheckpointAccount 截至检查点高度观测账户的累计入账
+type CheckpointAccount struct {
+	SourceKey     string
+	Coin          string
+	DepositCount  int
+	DepositAmount string
+}
+
+//ChainCheckpoint 每N个区块发布一次的检查点，外部消费者据此核对自上个检查点以来是否完整处理了事件流
+type ChainCheckpoint struct {
+	Height               uint64 `storm:"id"`
+	Symbol               string
+	BlockHash            string
+	PrevCheckpointHeight uint64
+	Accounts             []*CheckpointAccount
+	LastSequences        map[string]uint64 //sourceKey -> 截至检查点高度最大的提取结果序号
+	EventCount           int               //自上个检查点以来的提取结果数量
+	LedgerHash           string            //提取结果账本的状态哈希，与上个检查点的哈希链接
+	CreateAt             int64
+}
+
+//NEOCheckpointNotificationObject 检查点被通知对象
+type NEOCheckpointNotificationObject interface {
+
+	//NEOCheckpointNotify 检查点通知
+	//@required
+	NEOCheckpointNotify(checkpoint *ChainCheckpoint) error
+}
+
+//AddCheckpointObserver 添加检查点观测者
+func (bs *NEOBlockScanner) AddCheckpointObserver(obj NEOCheckpointNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if obj == nil {
+		return nil
+	}
+
+	bs.CheckpointObservers[obj] = true
+
+	return nil
+}
+
+//RemoveCheckpointObserver 移除检查点观测者
+func (bs *NEOBlockScanner) RemoveCheckpointObserver(obj NEOCheckpointNotificationObject) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	delete(bs.CheckpointObservers, obj)
+
+	return nil
+}
+
+//ledgerEntryHash 把一条提取结果链接到账本状态哈希
+func ledgerEntryHash(prev string, record *ExtractDataRecord) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%d|%s", prev, record.BlockHeight, record.SourceKey, record.Sequence, record.TxID)))
+	return hex.EncodeToString(h[:])
+}
+
+//buildChainCheckpoint 在上个检查点的基础上累计(prev.Height, height]范围内的提取结果
+func buildChainCheckpoint(prev *ChainCheckpoint, height uint64, records []*ExtractDataRecord) *ChainCheckpoint {
+
+	checkpoint := &ChainCheckpoint{
+		Height:        height,
+		Accounts:      make([]*CheckpointAccount, 0),
+		LastSequences: make(map[string]uint64),
+		CreateAt:      time.Now().Unix(),
+	}
+
+	type amount struct {
+		count int
+		total decimal.Decimal
+	}
+	accounts := make(map[string]map[string]*amount)
+	getAmount := func(key, coin string) *amount {
+		coins := accounts[key]
+		if coins == nil {
+			coins = make(map[string]*amount)
+			accounts[key] = coins
+		}
+		a := coins[coin]
+		if a == nil {
+			a = &amount{total: decimal.Zero}
+			coins[coin] = a
+		}
+		return a
+	}
+
+	if prev != nil {
+		checkpoint.PrevCheckpointHeight = prev.Height
+		checkpoint.LedgerHash = prev.LedgerHash
+		for key, seq := range prev.LastSequences {
+			checkpoint.LastSequences[key] = seq
+		}
+		for _, acc := range prev.Accounts {
+			a := getAmount(acc.SourceKey, acc.Coin)
+			a.count = acc.DepositCount
+			a.total, _ = decimal.NewFromString(acc.DepositAmount)
+		}
+	}
+
+	//按高度、sourceKey、序号排序，保证同样的事件流得到同样的哈希
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].BlockHeight != records[j].BlockHeight {
+			return records[i].BlockHeight < records[j].BlockHeight
+		}
+		if records[i].SourceKey != records[j].SourceKey {
+			return records[i].SourceKey < records[j].SourceKey
+		}
+		return records[i].Sequence < records[j].Sequence
+	})
+
+	for _, record := range records {
+		checkpoint.EventCount++
+		checkpoint.LedgerHash = ledgerEntryHash(checkpoint.LedgerHash, record)
+		if record.Sequence > checkpoint.LastSequences[record.SourceKey] {
+			checkpoint.LastSequences[record.SourceKey] = record.Sequence
+		}
+
+		data := record.Data
+		if data == nil || data.Transaction == nil || len(data.TxOutputs) == 0 {
+			continue
+		}
+
+		a := getAmount(record.SourceKey, activityCoin(data))
+		a.count++
+		for _, output := range data.TxOutputs {
+			v, _ := decimal.NewFromString(output.Amount)
+			a.total = a.total.Add(v)
+		}
+	}
+
+	for key, coins := range accounts {
+		for coin, a := range coins {
+			checkpoint.Accounts = append(checkpoint.Accounts, &CheckpointAccount{
+				SourceKey:     key,
+				Coin:          coin,
+				DepositCount:  a.count,
+				DepositAmount: a.total.String(),
+			})
+		}
+	}
+
+	sort.Slice(checkpoint.Accounts, func(i, j int) bool {
+		if checkpoint.Accounts[i].SourceKey != checkpoint.Accounts[j].SourceKey {
+			return checkpoint.Accounts[i].SourceKey < checkpoint.Accounts[j].SourceKey
+		}
+		return checkpoint.Accounts[i].Coin < checkpoint.Accounts[j].Coin
+	})
+
+	return checkpoint
+}
+
+//publishCheckpoint 区块高度保存后调用，高度为N的整数倍时生成、保存并发布检查点
+func (bs *NEOBlockScanner) publishCheckpoint(height uint64, hash string) {
+
+	blocks := bs.wm.config().CheckpointBlocks
+	if blocks == 0 || height == 0 || height%blocks != 0 {
+		return
+	}
+
+	checkpoint, err := bs.wm.createChainCheckpoint(height, hash)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d create checkpoint failed; unexpected error: %v", height, err)
+		return
+	}
+	checkpoint.Symbol = bs.wm.Symbol()
+
+	bs.wm.Log.Std.Info("[%s] checkpoint at height: %d, events: %d, ledger hash: %s", checkpoint.Symbol, checkpoint.Height, checkpoint.EventCount, checkpoint.LedgerHash)
+
+	bs.Mu.RLock()
+	for o := range bs.CheckpointObservers {
+		err := o.NEOCheckpointNotify(checkpoint)
+		if err != nil {
+			bs.wm.Log.Error("NEOCheckpointNotify unexpected error:", err)
+		}
+	}
+	bs.Mu.RUnlock()
+}
+
+//createChainCheckpoint 计算并保存高度height的检查点
+func (wm *WalletManager) createChainCheckpoint(height uint64, hash string) (*ChainCheckpoint, error) {
+
+	prev, err := wm.getPrevChainCheckpoint(height)
+	if err != nil {
+		return nil, err
+	}
+
+	fromHeight := uint64(1)
+	if prev != nil {
+		fromHeight = prev.Height + 1
+	}
+
+	records, err := wm.GetExtractData(fromHeight, height)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := buildChainCheckpoint(prev, height, records)
+	checkpoint.BlockHash = hash
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.Save(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+//getPrevChainCheckpoint 获取高度低于height的最近一个检查点，没有时返回nil
+func (wm *WalletManager) getPrevChainCheckpoint(height uint64) (*ChainCheckpoint, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var checkpoint ChainCheckpoint
+	err = db.Select(q.Lt("Height", height)).OrderBy("Height").Reverse().First(&checkpoint)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+//GetChainCheckpoint 获取指定高度已发布的检查点
+func (wm *WalletManager) GetChainCheckpoint(height uint64) (*ChainCheckpoint, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var checkpoint ChainCheckpoint
+	err = db.One("Height", height, &checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+//GetLatestChainCheckpoint 获取最近发布的检查点，没有时返回nil
+func (wm *WalletManager) GetLatestChainCheckpoint() (*ChainCheckpoint, error) {
+	return wm.getPrevChainCheckpoint(^uint64(0))
+}
+
+//deleteChainCheckpointsFromHeight 删除高度不小于fromHeight的检查点，回滚后重新生成
+func (wm *WalletManager) deleteChainCheckpointsFromHeight(fromHeight uint64) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Select(q.Gte("Height", fromHeight)).Delete(&ChainCheckpoint{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
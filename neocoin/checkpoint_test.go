@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testCheckpointObserver struct {
+	checkpoints []*ChainCheckpoint
+}
+
+func (o *testCheckpointObserver) NEOCheckpointNotify(checkpoint *ChainCheckpoint) error {
+	o.checkpoints = append(o.checkpoints, checkpoint)
+	return nil
+}
+
+func TestNEOBlockScanner_PublishCheckpoint(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.CheckpointBlocks = 2
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	observer := &testCheckpointObserver{}
+	bs.AddCheckpointObserver(observer)
+
+	newDeposit := func(txid, amount string) *openwallet.TxExtractData {
+		output := &openwallet.TxOutPut{}
+		output.Amount = amount
+		return &openwallet.TxExtractData{
+			Transaction: &openwallet.Transaction{TxID: txid, Coin: openwallet.Coin{Symbol: "NEO"}},
+			TxOutputs:   []*openwallet.TxOutPut{output},
+		}
+	}
+
+	wm.SaveExtractData(1, map[string]*openwallet.TxExtractData{"account1": newDeposit("tx1", "1.5")})
+	wm.SaveExtractData(2, map[string]*openwallet.TxExtractData{"account2": newDeposit("tx2", "3")})
+	for h := uint64(1); h <= 2; h++ {
+		bs.publishCheckpoint(h, testHash("block"))
+	}
+
+	wm.SaveExtractData(4, map[string]*openwallet.TxExtractData{"account1": newDeposit("tx4", "2")})
+	for h := uint64(3); h <= 4; h++ {
+		bs.publishCheckpoint(h, testHash("block"))
+	}
+
+	if len(observer.checkpoints) != 2 {
+		t.Errorf("checkpoints should be published at height 2 and 4, got: %d", len(observer.checkpoints))
+		return
+	}
+
+	first, second := observer.checkpoints[0], observer.checkpoints[1]
+	if first.Height != 2 || first.EventCount != 2 || first.LastSequences["account1"] != 1 {
+		t.Errorf("unexpected first checkpoint: %+v", first)
+	}
+	if second.Height != 4 || second.PrevCheckpointHeight != 2 || second.EventCount != 1 || second.LastSequences["account1"] != 2 {
+		t.Errorf("unexpected second checkpoint: %+v", second)
+	}
+	if len(second.Accounts) != 2 || second.Accounts[0].SourceKey != "account1" || second.Accounts[0].DepositAmount != "3.5" || second.Accounts[0].DepositCount != 2 {
+		t.Errorf("deposit totals should be cumulative, got: %+v", second.Accounts[0])
+	}
+
+	//同样的事件流得到同样的账本哈希
+	records, _ := wm.GetExtractData(3, 4)
+	expected := buildChainCheckpoint(first, 4, records)
+	if second.LedgerHash != expected.LedgerHash || second.LedgerHash == first.LedgerHash {
+		t.Errorf("ledger hash should chain previous checkpoint, got: %s, expected: %s", second.LedgerHash, expected.LedgerHash)
+	}
+
+	//回滚后检查点重新生成
+	err := wm.DeleteExtractData(4)
+	if err != nil {
+		t.Errorf("DeleteExtractData failed unexpected error: %v\n", err)
+		return
+	}
+	latest, err := wm.GetLatestChainCheckpoint()
+	if err != nil || latest == nil || latest.Height != 2 {
+		t.Errorf("checkpoint above rollback height should be deleted, latest: %+v, err: %v", latest, err)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	AlertTypeFeatureDegraded  = "feature_degraded"  //可选功能依赖的RPC方法连续不可用，功能暂停
+	AlertTypeFeatureRecovered = "feature_recovered" //RPC方法恢复可用，功能恢复
+)
+
+var (
+	//rpcFeatures 可选功能依赖的RPC方法，方法不可用时只停用该功能，不影响区块扫描
+	rpcFeatures = map[string]string{
+		"invokefunction":      "token metadata refresh",
+		"getblockheader":      "header-only block fetch",
+		"omni_gettransaction": "omni transaction extraction",
+	}
+	rpcFeaturesMu sync.RWMutex
+)
+
+//RegisterRPCFeature 登记可选功能依赖的RPC方法，通过callWithBreaker调用时按方法熔断
+func RegisterRPCFeature(method, feature string) {
+	rpcFeaturesMu.Lock()
+	defer rpcFeaturesMu.Unlock()
+	rpcFeatures[method] = feature
+}
+
+//rpcFeature RPC方法对应的功能名称
+func rpcFeature(method string) string {
+	rpcFeaturesMu.RLock()
+	defer rpcFeaturesMu.RUnlock()
+	if feature, ok := rpcFeatures[method]; ok {
+		return feature
+	}
+	return method
+}
+
+//RPCBreakerStatus RPC方法的熔断状态
+type RPCBreakerStatus struct {
+	Method    string
+	Feature   string
+	Open      bool      //是否熔断
+	Failures  int       //连续不可用次数
+	OpenUntil time.Time //熔断到该时间后放行一次试探请求
+	LastError string
+}
+
+//methodBreaker 单个RPC方法的熔断器
+type methodBreaker struct {
+	failures  int
+	open      bool
+	probing   bool //冷却后放行的试探请求进行中
+	openUntil time.Time
+	lastErr   string
+}
+
+//rpcBreakers 按RPC方法统计连续不可用次数，达到阈值后熔断，冷却后放行一次试探请求，成功则恢复
+type rpcBreakers struct {
+	mu      sync.Mutex
+	methods map[string]*methodBreaker
+}
+
+func newRPCBreakers() *rpcBreakers {
+	return &rpcBreakers{methods: make(map[string]*methodBreaker)}
+}
+
+//isMethodUnavailable 是否表示方法不可用，节点不支持该方法或连接失败；
+//节点正常返回的业务错误说明方法可用，不计入熔断
+func isMethodUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, "[-32601]") || strings.Contains(strings.ToLower(msg), "method not found")
+}
+
+//callWithBreaker 调用可选功能依赖的RPC方法，方法熔断期间直接返回错误，熔断和恢复时发送告警
+func (wm *WalletManager) callWithBreaker(client ClientInterface, method string, request []interface{}) (*gjson.Result, error) {
+
+	threshold := wm.config().RPCBreakerThreshold
+	if threshold <= 0 {
+		return client.Call(method, request)
+	}
+
+	if until, open := wm.breakers.allow(method); open {
+		return nil, wm.errorf(ErrRPCMethodUnavailable, "rpc method: %s is unavailable, %s is disabled until %s", method, rpcFeature(method), until.Format(time.RFC3339))
+	}
+
+	result, err := client.Call(method, request)
+
+	changed, open := wm.breakers.record(method, err, threshold, wm.config().RPCBreakerCooldown)
+	if changed {
+		var alert *Alert
+		if open {
+			alert = NewAlert(wm.Symbol(), AlertTypeFeatureDegraded, 0, rpcFeature(method)+" is disabled, rpc method: "+method+" is unavailable")
+			alert.Details["error"] = err.Error()
+		} else {
+			alert = NewAlert(wm.Symbol(), AlertTypeFeatureRecovered, 0, rpcFeature(method)+" is enabled, rpc method: "+method+" is available again")
+		}
+		alert.Details["method"] = method
+		alert.Details["feature"] = rpcFeature(method)
+		wm.Blockscanner.newAlertNotify(alert)
+	}
+
+	return result, err
+}
+
+//allow 是否放行请求，熔断中返回试探时间
+func (b *rpcBreakers) allow(method string) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	mb, ok := b.methods[method]
+	if !ok || !mb.open {
+		return time.Time{}, false
+	}
+
+	if mb.probing || time.Now().Before(mb.openUntil) {
+		return mb.openUntil, true
+	}
+
+	mb.probing = true
+	return time.Time{}, false
+}
+
+//record 记录请求结果，返回熔断状态是否变化及当前是否熔断
+func (b *rpcBreakers) record(method string, err error, threshold int, cooldown time.Duration) (bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	mb, ok := b.methods[method]
+	if !ok {
+		mb = &methodBreaker{}
+		b.methods[method] = mb
+	}
+
+	if !isMethodUnavailable(err) {
+		wasOpen := mb.open
+		mb.failures = 0
+		mb.open = false
+		mb.probing = false
+		return wasOpen, false
+	}
+
+	mb.failures++
+	mb.lastErr = err.Error()
+
+	if mb.open {
+		//试探失败，继续熔断
+		mb.probing = false
+		mb.openUntil = time.Now().Add(cooldown)
+		return false, true
+	}
+
+	if mb.failures >= threshold {
+		mb.open = true
+		mb.openUntil = time.Now().Add(cooldown)
+		return true, true
+	}
+
+	return false, false
+}
+
+//RPCBreakerStatus 已调用过的可选功能RPC方法的熔断状态，按方法名排序
+func (wm *WalletManager) RPCBreakerStatus() []*RPCBreakerStatus {
+	wm.breakers.mu.Lock()
+	defer wm.breakers.mu.Unlock()
+
+	list := make([]*RPCBreakerStatus, 0, len(wm.breakers.methods))
+	for method, mb := range wm.breakers.methods {
+		list = append(list, &RPCBreakerStatus{
+			Method:    method,
+			Feature:   rpcFeature(method),
+			Open:      mb.open,
+			Failures:  mb.failures,
+			OpenUntil: mb.openUntil,
+			LastError: mb.lastErr,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Method < list[j].Method
+	})
+	return list
+}
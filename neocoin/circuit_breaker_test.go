@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestWalletManager_RPCCircuitBreaker(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.RPCBreakerThreshold = 3
+	wm.Config.RPCBreakerCooldown = 50 * time.Millisecond
+	alerts := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(alerts)
+
+	calls := 0
+	pluginMissing := true
+	wm.SetNodeClient(mockFuncClient(func(path string, request []interface{}) (*gjson.Result, error) {
+		calls++
+		if pluginMissing {
+			return nil, errors.New("[-32601]Method not found")
+		}
+		result := gjson.Parse(`{"state":"HALT","stack":[{"type":"String","value":"RPX"}]}`)
+		return &result, nil
+	}))
+
+	//业务错误说明方法可用，不计入熔断
+	for i := 0; i < 5; i++ {
+		wm.breakers.record("invokefunction", errors.New("[-100]Unknown contract"), 3, time.Minute)
+	}
+	if status := wm.RPCBreakerStatus(); len(status) != 1 || status[0].Open {
+		t.Errorf("business errors should not open breaker: %+v", status[0])
+	}
+
+	for i := 0; i < 5; i++ {
+		wm.callWithBreaker(wm.nodeClient(), "invokefunction", nil)
+	}
+	if calls != 3 || len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeFeatureDegraded || alerts.alerts[0].Details["feature"] != "token metadata refresh" {
+		t.Errorf("breaker should open after 3 failures, calls: %d, alerts: %d", calls, len(alerts.alerts))
+		return
+	}
+
+	_, err := wm.callWithBreaker(wm.nodeClient(), "invokefunction", nil)
+	if err == nil || openwallet.ConvertError(err).Code() != ErrRPCMethodUnavailable {
+		t.Errorf("open breaker should fail fast, err: %v", err)
+	}
+
+	//冷却后试探失败，继续熔断
+	time.Sleep(60 * time.Millisecond)
+	wm.callWithBreaker(wm.nodeClient(), "invokefunction", nil)
+	wm.callWithBreaker(wm.nodeClient(), "invokefunction", nil)
+	if calls != 4 || len(alerts.alerts) != 1 {
+		t.Errorf("only one probe should be sent after cooldown, calls: %d", calls)
+	}
+
+	//插件安装后试探成功，恢复
+	pluginMissing = false
+	time.Sleep(60 * time.Millisecond)
+	if _, err := wm.callWithBreaker(wm.nodeClient(), "invokefunction", nil); err != nil {
+		t.Errorf("probe should succeed, err: %v", err)
+	}
+	if len(alerts.alerts) != 2 || alerts.alerts[1].Type != AlertTypeFeatureRecovered || wm.RPCBreakerStatus()[0].Open {
+		t.Errorf("breaker should close after successful probe")
+	}
+}
@@ -0,0 +1,518 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+//Package compliance 区块扫描器和交易单解析器的行为兼容性检查，随适配器版本维护。
+//下游集成方可在自己的测试中使用实际配置调用Verify，确认适配器满足openwallet接口的行为约定：
+//重设扫描高度、分叉通知、提取结果的结构。检查在临时目录的沙盒中进行，不影响正式的本地数据
+package compliance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//SuiteVersion 检查用例版本，增加或修改检查项时递增
+const SuiteVersion = 1
+
+const (
+	StatusPass = "pass" //检查通过
+	StatusFail = "fail" //检查失败
+	StatusSkip = "skip" //链上数据不满足条件，跳过检查
+)
+
+//complianceSourceKey 检查期间所有地址都归属的账户
+const complianceSourceKey = "compliance"
+
+//编译期检查接口实现
+var (
+	_ openwallet.BlockScanner       = (*neocoin.NEOBlockScanner)(nil)
+	_ openwallet.TransactionDecoder = (*neocoin.TransactionDecoder)(nil)
+)
+
+//Options 检查参数
+type Options struct {
+	Height  uint64        //用于检查的区块高度，须大于1，为0时使用节点最新高度
+	TxID    string        //用于检查提取结果的交易，为空时使用区块中第一笔有提取结果的交易
+	Timeout time.Duration //等待异步通知的时间，为0时使用10秒
+}
+
+//Check 单项检查结果
+type Check struct {
+	Name    string
+	Status  string
+	Message string
+}
+
+//Report 检查报告
+type Report struct {
+	SuiteVersion int
+	Symbol       string
+	Height       uint64
+	Passed       bool
+	Checks       []*Check
+}
+
+//Failed 未通过的检查项
+func (r *Report) Failed() []*Check {
+	failed := make([]*Check, 0)
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+//errSkip 链上数据不满足条件，跳过
+type errSkip string
+
+func (e errSkip) Error() string {
+	return string(e)
+}
+
+//Verify 执行全部检查，有未通过的检查项时返回错误
+func Verify(wm *neocoin.WalletManager, opts Options) error {
+	report := Run(wm, opts)
+	failed := report.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(failed))
+	for _, c := range failed {
+		messages = append(messages, fmt.Sprintf("[%s] %s", c.Name, c.Message))
+	}
+	return fmt.Errorf("compliance suite v%d failed: %s", SuiteVersion, strings.Join(messages, "; "))
+}
+
+//Run 在沙盒中执行全部检查。重设扫描高度需要CapabilityRescan，配置了操作令牌时须先授予wm
+func Run(wm *neocoin.WalletManager, opts Options) *Report {
+
+	report := &Report{
+		SuiteVersion: SuiteVersion,
+		Symbol:       wm.Symbol(),
+		Passed:       true,
+		Checks:       make([]*Check, 0),
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	run := func(name string, check func() (string, error)) {
+		message, err := check()
+		c := &Check{Name: name, Status: StatusPass, Message: message}
+		if err != nil {
+			if _, skip := err.(errSkip); skip {
+				c.Status = StatusSkip
+			} else {
+				c.Status = StatusFail
+				report.Passed = false
+			}
+			c.Message = err.Error()
+		}
+		report.Checks = append(report.Checks, c)
+	}
+
+	dbPath, err := ioutil.TempDir("", "neo-compliance")
+	if err != nil {
+		run("sandbox", func() (string, error) {
+			return "", fmt.Errorf("create sandbox directory failed: %v", err)
+		})
+		return report
+	}
+	defer os.RemoveAll(dbPath)
+
+	s := &suite{
+		wm:       wm.Sandbox(dbPath),
+		opts:     opts,
+		observer: &observer{},
+	}
+	s.bs = s.wm.Blockscanner
+	defer s.bs.CloseBlockScanner()
+	s.bs.AddObserver(s.observer)
+	s.bs.SetBlockScanTargetFunc(func(target openwallet.ScanTarget) (string, bool) {
+		return complianceSourceKey, true
+	})
+
+	run("interfaces", s.checkInterfaces)
+	run("currentHeader", s.checkCurrentHeader)
+	if s.height == 0 {
+		return report
+	}
+	report.Height = s.height
+	run("rescan", s.checkRescan)
+	run("scanBlock", s.checkScanBlock)
+	run("fork", s.checkFork)
+	run("extractData", s.checkExtractData)
+	run("extractTransactionData", s.checkExtractTransactionData)
+
+	return report
+}
+
+//suite 一次检查使用的沙盒和收到的通知
+type suite struct {
+	wm       *neocoin.WalletManager
+	bs       *neocoin.NEOBlockScanner
+	opts     Options
+	observer *observer
+	height   uint64 //检查使用的区块高度
+	hash     string //检查使用的区块hash
+}
+
+//checkInterfaces 管理者返回的扫描器和解析器可用
+func (s *suite) checkInterfaces() (string, error) {
+	if s.wm.GetBlockScanner() == nil {
+		return "", fmt.Errorf("block scanner is nil")
+	}
+	if s.wm.GetTransactionDecoder() == nil {
+		return "", fmt.Errorf("transaction decoder is nil")
+	}
+	return fmt.Sprintf("%T, %T", s.wm.GetBlockScanner(), s.wm.GetTransactionDecoder()), nil
+}
+
+//checkCurrentHeader 节点最新区块头与按高度查询的hash一致，并确定检查使用的区块高度
+func (s *suite) checkCurrentHeader() (string, error) {
+	header, err := s.bs.GetCurrentBlockHeader()
+	if err != nil {
+		return "", fmt.Errorf("get current block header failed: %v", err)
+	}
+	if header.Height == 0 || len(header.Hash) == 0 {
+		return "", fmt.Errorf("current block header height: %d, hash: %s is empty", header.Height, header.Hash)
+	}
+	hash, err := s.wm.GetBlockHash(header.Height)
+	if err != nil {
+		return "", fmt.Errorf("get block hash on height: %d failed: %v", header.Height, err)
+	}
+	if hash != header.Hash {
+		return "", fmt.Errorf("current block header hash: %s is not equal to node hash: %s on height: %d", header.Hash, hash, header.Height)
+	}
+
+	height := s.opts.Height
+	if height == 0 {
+		height = header.Height
+	}
+	if height < 2 || height > header.Height {
+		return "", fmt.Errorf("check height: %d must be in range [2, %d]", height, header.Height)
+	}
+	if height != header.Height {
+		if hash, err = s.wm.GetBlockHash(height); err != nil {
+			return "", fmt.Errorf("get block hash on height: %d failed: %v", height, err)
+		}
+	}
+	s.height, s.hash = height, hash
+
+	return fmt.Sprintf("height: %d, hash: %s", header.Height, header.Hash), nil
+}
+
+//checkRescan 重设扫描高度后从该高度开始扫描，扫描后通知该高度的区块
+func (s *suite) checkRescan() (string, error) {
+	if err := s.bs.SetRescanBlockHeight(0); err == nil {
+		return "", fmt.Errorf("rescan height 0 should be rejected")
+	}
+	if err := s.bs.SetRescanBlockHeight(s.height); err != nil {
+		return "", fmt.Errorf("set rescan height: %d failed: %v", s.height, err)
+	}
+	if scanned := s.bs.GetScannedBlockHeight(); scanned != s.height-1 {
+		return "", fmt.Errorf("scanned height: %d after rescan should be: %d", scanned, s.height-1)
+	}
+
+	s.scan()
+
+	if scanned := s.bs.GetScannedBlockHeight(); scanned < s.height {
+		return "", fmt.Errorf("scanned height: %d should reach rescan height: %d", scanned, s.height)
+	}
+	header := s.observer.waitHeader(s.opts.Timeout, func(h *openwallet.BlockHeader) bool {
+		return h.Height == s.height
+	})
+	if header == nil {
+		return "", fmt.Errorf("block height: %d is not notified after rescan", s.height)
+	}
+	if header.Fork || header.Hash != s.hash {
+		return "", fmt.Errorf("block height: %d notified with hash: %s, fork: %v, expected hash: %s", s.height, header.Hash, header.Fork, s.hash)
+	}
+	if header.Symbol != s.wm.Symbol() {
+		return "", fmt.Errorf("block header symbol: %s is not equal to: %s", header.Symbol, s.wm.Symbol())
+	}
+	return fmt.Sprintf("rescan from height: %d", s.height), nil
+}
+
+//checkScanBlock 扫描指定区块只通知该区块，不改变已扫描高度
+func (s *suite) checkScanBlock() (string, error) {
+	height := s.height - 1
+	scanned := s.bs.GetScannedBlockHeight()
+	hash, err := s.wm.GetBlockHash(height)
+	if err != nil {
+		return "", fmt.Errorf("get block hash on height: %d failed: %v", height, err)
+	}
+	if err := s.bs.ScanBlock(height); err != nil {
+		return "", fmt.Errorf("scan block height: %d failed: %v", height, err)
+	}
+	header := s.observer.waitHeader(s.opts.Timeout, func(h *openwallet.BlockHeader) bool {
+		return h.Height == height
+	})
+	if header == nil {
+		return "", fmt.Errorf("block height: %d is not notified after scan block", height)
+	}
+	if header.Fork || header.Hash != hash {
+		return "", fmt.Errorf("block height: %d notified with hash: %s, fork: %v, expected hash: %s", height, header.Hash, header.Fork, hash)
+	}
+	if got := s.bs.GetScannedBlockHeight(); got != scanned {
+		return "", fmt.Errorf("scan block should not change scanned height: %d, got: %d", scanned, got)
+	}
+	return fmt.Sprintf("scan block height: %d", height), nil
+}
+
+//checkFork 本地已扫区块被替换时，以Fork为true通知被替换的区块，并重新扫描到原高度
+func (s *suite) checkFork() (string, error) {
+	var (
+		height = s.height - 1
+		orphan = "0x" + strings.Repeat("0", 63) + "1"
+	)
+	hash, err := s.wm.GetBlockHash(height)
+	if err != nil {
+		return "", fmt.Errorf("get block hash on height: %d failed: %v", height, err)
+	}
+	block, err := s.wm.GetBlock(hash)
+	if err != nil {
+		return "", fmt.Errorf("get block: %s failed: %v", hash, err)
+	}
+
+	//模拟本地已扫描的区块为孤块
+	block.Hash = orphan
+	if err := s.wm.SaveLocalBlock(block); err != nil {
+		return "", fmt.Errorf("save local block failed: %v", err)
+	}
+	if err := s.wm.SaveLocalNewBlock(height, orphan); err != nil {
+		return "", fmt.Errorf("save local new block failed: %v", err)
+	}
+
+	s.scan()
+
+	fork := s.observer.waitHeader(s.opts.Timeout, func(h *openwallet.BlockHeader) bool {
+		return h.Fork
+	})
+	if fork == nil {
+		return "", fmt.Errorf("orphan block height: %d is not notified as fork", height)
+	}
+	if fork.Height != height || fork.Hash != orphan {
+		return "", fmt.Errorf("fork notified height: %d, hash: %s, expected height: %d, hash: %s", fork.Height, fork.Hash, height, orphan)
+	}
+	first := s.observer.first(height)
+	replaced := s.observer.waitHeader(s.opts.Timeout, func(h *openwallet.BlockHeader) bool {
+		return !h.Fork && h.Height == height && h.Hash == hash && h != first
+	})
+	if replaced == nil {
+		return "", fmt.Errorf("block height: %d is not notified again after fork", height)
+	}
+	if scanned, scannedHash := s.wm.GetLocalNewBlock(); scanned < s.height || (scanned == s.height && scannedHash != s.hash) {
+		return "", fmt.Errorf("scanned height: %d, hash: %s should reach height: %d, hash: %s after fork", scanned, scannedHash, s.height, s.hash)
+	}
+	return fmt.Sprintf("fork on height: %d", height), nil
+}
+
+//checkExtractData 扫描时通知的提取结果结构完整，与所在区块一致
+func (s *suite) checkExtractData() (string, error) {
+	notified := s.observer.extractData()
+	count := 0
+	for _, n := range notified {
+		if n.data.Transaction == nil || n.data.Transaction.BlockHeight != s.height {
+			continue
+		}
+		if err := checkExtractDataShape(s.wm, n.sourceKey, n.data, s.hash); err != nil {
+			return "", err
+		}
+		if len(s.opts.TxID) == 0 {
+			s.opts.TxID = n.data.Transaction.TxID
+		}
+		count++
+	}
+	if count == 0 {
+		return "", errSkip(fmt.Sprintf("block height: %d has no extract data", s.height))
+	}
+	return fmt.Sprintf("%d extract data on height: %d", count, s.height), nil
+}
+
+//checkExtractTransactionData 按txid提取的结果结构完整，不匹配任何地址时返回空结果
+func (s *suite) checkExtractTransactionData() (string, error) {
+	txid := s.opts.TxID
+	if len(txid) == 0 {
+		return "", errSkip("no transaction to extract, set Options.TxID")
+	}
+
+	result, err := s.bs.ExtractTransactionData(txid, func(target openwallet.ScanTarget) (string, bool) {
+		return complianceSourceKey, true
+	})
+	if err != nil {
+		return "", fmt.Errorf("extract transaction: %s failed: %v", txid, err)
+	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("extract transaction: %s matched all addresses but got nothing", txid)
+	}
+	for sourceKey, list := range result {
+		for _, data := range list {
+			if err := checkExtractDataShape(s.wm, sourceKey, data, ""); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	none, err := s.bs.ExtractTransactionData(txid, func(target openwallet.ScanTarget) (string, bool) {
+		return "", false
+	})
+	if err != nil {
+		return "", fmt.Errorf("extract transaction: %s without target failed: %v", txid, err)
+	}
+	if len(none) != 0 {
+		return "", fmt.Errorf("extract transaction: %s matched no address but got %d source keys", txid, len(none))
+	}
+
+	return txid, nil
+}
+
+//scan 执行一次扫描任务
+func (s *suite) scan() {
+	s.bs.Scanning = true
+	s.bs.ScanBlockTask()
+	s.bs.Scanning = false
+}
+
+//checkExtractDataShape 提取结果的交易单和输入输出完整且相互一致，blockHash不为空时检查所在区块
+func checkExtractDataShape(wm *neocoin.WalletManager, sourceKey string, data *openwallet.TxExtractData, blockHash string) error {
+
+	if sourceKey != complianceSourceKey {
+		return fmt.Errorf("extract data source key: %s is not returned by scan target func", sourceKey)
+	}
+	if data == nil || data.Transaction == nil {
+		return fmt.Errorf("extract data transaction is nil")
+	}
+
+	tx := data.Transaction
+	if len(tx.TxID) == 0 {
+		return fmt.Errorf("extract data txid is empty")
+	}
+	if tx.Coin.Symbol != wm.Symbol() {
+		return fmt.Errorf("transaction: %s coin symbol: %s is not equal to: %s", tx.TxID, tx.Coin.Symbol, wm.Symbol())
+	}
+	if tx.WxID != openwallet.GenTransactionWxID(tx) {
+		return fmt.Errorf("transaction: %s wxid: %s is not generated from txid and coin", tx.TxID, tx.WxID)
+	}
+	if len(blockHash) > 0 && tx.BlockHash != blockHash {
+		return fmt.Errorf("transaction: %s block hash: %s is not equal to: %s", tx.TxID, tx.BlockHash, blockHash)
+	}
+	if len(data.TxInputs) == 0 && len(data.TxOutputs) == 0 {
+		return fmt.Errorf("transaction: %s has neither inputs nor outputs", tx.TxID)
+	}
+
+	check := func(kind, txid, address string, coin openwallet.Coin, amount string) error {
+		if txid != tx.TxID {
+			return fmt.Errorf("transaction: %s %s txid: %s is not equal to transaction", tx.TxID, kind, txid)
+		}
+		if len(address) == 0 {
+			return fmt.Errorf("transaction: %s %s address is empty", tx.TxID, kind)
+		}
+		if coin.Symbol != tx.Coin.Symbol {
+			return fmt.Errorf("transaction: %s %s coin symbol: %s is not equal to transaction", tx.TxID, kind, coin.Symbol)
+		}
+		if _, err := decimal.NewFromString(amount); err != nil {
+			return fmt.Errorf("transaction: %s %s amount: %s is invalid", tx.TxID, kind, amount)
+		}
+		return nil
+	}
+	for _, in := range data.TxInputs {
+		if err := check("input", in.TxID, in.Address, in.Coin, in.Amount); err != nil {
+			return err
+		}
+	}
+	for _, out := range data.TxOutputs {
+		if err := check("output", out.TxID, out.Address, out.Coin, out.Amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//notifiedExtractData 收到的提取结果通知
+type notifiedExtractData struct {
+	sourceKey string
+	data      *openwallet.TxExtractData
+}
+
+//observer 记录扫描器的区块和提取结果通知
+type observer struct {
+	mu      sync.Mutex
+	headers []*openwallet.BlockHeader
+	data    []notifiedExtractData
+}
+
+func (o *observer) BlockScanNotify(header *openwallet.BlockHeader) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.headers = append(o.headers, header)
+	return nil
+}
+
+func (o *observer) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.data = append(o.data, notifiedExtractData{sourceKey: sourceKey, data: data})
+	return nil
+}
+
+//waitHeader 区块通知是异步的，等待收到满足条件的区块头，超时返回nil
+func (o *observer) waitHeader(timeout time.Duration, match func(h *openwallet.BlockHeader) bool) *openwallet.BlockHeader {
+	deadline := time.Now().Add(timeout)
+	for {
+		o.mu.Lock()
+		for _, h := range o.headers {
+			if match(h) {
+				o.mu.Unlock()
+				return h
+			}
+		}
+		o.mu.Unlock()
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+//first 最先收到的指定高度的区块头
+func (o *observer) first(height uint64) *openwallet.BlockHeader {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, h := range o.headers {
+		if h.Height == height {
+			return h
+		}
+	}
+	return nil
+}
+
+//extractData 已收到的提取结果
+func (o *observer) extractData() []notifiedExtractData {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]notifiedExtractData{}, o.data...)
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package compliance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/crypto"
+)
+
+//testHash 由标记生成格式合法的区块hash或交易ID
+func testHash(label string) string {
+	return "0x" + hex.EncodeToString(crypto.SHA256([]byte(label)))
+}
+
+//newTestNode 模拟高度为tip的节点，每个区块有一笔转账交易
+func newTestNode(tip uint64) *httptest.Server {
+	heightOf := make(map[string]uint64)
+	for h := uint64(0); h <= tip; h++ {
+		heightOf[testHash(fmt.Sprintf("block%d", h))] = h
+		heightOf[testHash(fmt.Sprintf("tx%d", h))] = h
+	}
+	handle := func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return tip + 1
+		case "getblockhash":
+			return testHash(fmt.Sprintf("block%v", params[0]))
+		case "getblock":
+			h := heightOf[params[0].(string)]
+			return map[string]interface{}{"index": h, "hash": params[0], "previousblockhash": testHash(fmt.Sprintf("block%d", h-1)),
+				"time": 1000 + h, "tx": []interface{}{testHash(fmt.Sprintf("tx%d", h))}}
+		case "getrawtransaction":
+			h := heightOf[params[0].(string)]
+			return map[string]interface{}{"txid": params[0], "blockhash": testHash(fmt.Sprintf("block%d", h)), "vin": []interface{}{},
+				"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"}}}
+		}
+		return nil
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": "1", "result": handle(body.Method, body.Params)})
+	}))
+}
+
+func TestVerify(t *testing.T) {
+	server := newTestNode(5)
+	defer server.Close()
+
+	wm := neocoin.NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = neocoin.NewClient(server.URL, "", false)
+
+	report := Run(wm, Options{Timeout: time.Second})
+	if !report.Passed || report.Height != 5 || report.SuiteVersion != SuiteVersion {
+		t.Fatalf("compliance report: %+v, failed: %+v", report, report.Failed())
+	}
+	for _, c := range report.Checks {
+		if c.Status != StatusPass {
+			t.Errorf("check [%s] status: %s, message: %s", c.Name, c.Status, c.Message)
+		}
+	}
+
+	//正式的本地数据不受影响
+	if height, _ := wm.GetLocalNewBlock(); height != 0 {
+		t.Errorf("compliance check should run in sandbox, local height: %d", height)
+	}
+
+	//指定检查高度
+	if err := Verify(wm, Options{Height: 3, TxID: testHash("tx2"), Timeout: time.Second}); err != nil {
+		t.Errorf("Verify failed, unexpected error: %v", err)
+	}
+	if err := Verify(wm, Options{Height: 1, Timeout: time.Second}); err == nil {
+		t.Errorf("height below 2 should fail")
+	}
+
+	//配置了操作令牌但未授予重设扫描高度的权限
+	wm.Config.OperationToken = "token"
+	report = Run(wm, Options{Timeout: 100 * time.Millisecond})
+	if failed := report.Failed(); report.Passed || len(failed) == 0 || failed[0].Name != "rescan" {
+		t.Errorf("rescan without capability should fail, got: %+v", failed)
+	}
+	wm.GrantCapability("token", neocoin.CapabilityRescan)
+	if err := Verify(wm, Options{Timeout: time.Second}); err != nil {
+		t.Errorf("Verify with granted capability failed, unexpected error: %v", err)
+	}
+}
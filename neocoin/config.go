@@ -90,6 +90,8 @@ type WalletConfig struct {
 	BlockchainFile string
 	//是否测试网络
 	IsTestNet bool
+	//节点网络标识，本地数据按币种和网络标识划分目录，0按是否测试网取NEO主网或测试网的值
+	NetworkMagic uint32
 	// 核心钱包是否只做监听
 	CoreWalletWatchOnly bool
 	//最大的输入数量
@@ -130,6 +132,8 @@ type WalletConfig struct {
 	OmniRPCPassword string
 	//是否支持omni
 	OmniSupport bool
+	//兼容已废弃的Omni代币功能，关闭时OmniSupport不生效，代币查询使用NEP-5代币合约
+	OmniDeprecatedCompat bool
 	//主网地址前缀
 	MainNetAddressPrefix neoTransaction.AddressPrefix
 	//测试网地址前缀
@@ -140,6 +144,261 @@ type WalletConfig struct {
 	MinFees decimal.Decimal
 	//数据目录
 	DataDir string
+	//区块确认数
+	ConfirmBlocks uint64
+	//浏览器API，用于辅助核心节点查询
+	ExplorerAPI string
+	//备用浏览器API，多个用逗号分隔，用于余额和交易记录的一致性读取
+	BackupExplorerAPI []string
+	//余额和交易记录须有多少个浏览器返回一致的结果，包括主浏览器，小于等于1表示不检查
+	ExplorerQuorum int
+	//结果涉及的数额达到该值时才检查一致性，0表示全部检查
+	ExplorerQuorumThreshold decimal.Decimal
+	//首次扫描是否以浏览器最新高度减确认数作为起点
+	WarmStartFromExplorer bool
+	//无地址输出的处理策略
+	NonstandardOutputPolicy int
+	//从内存池提取的零确认充值的通知策略
+	ZeroConfDepositPolicy int
+	//备用节点API，多个用逗号分隔
+	BackupServerAPI []string
+	//多节点分歧检查间隔
+	NodeDivergenceCheckInterval time.Duration
+	//公共节点模式，低并发、限速、缓存
+	PublicNodeMode bool
+	//扫描迭代内固定节点快照读取，检测重组后重新扫描
+	PinnedScan bool
+	//多节点读请求路由，按权重和延迟把只读请求分配到主节点和备用节点
+	ReadRouting bool
+	//读请求路由的节点权重，按serverAPI、backupServerAPI的顺序，未配置的为1，0表示不分配读请求
+	NodeWeights []int
+	//危险操作令牌，配置后重设扫描高度、删除未扫记录、广播需先授权
+	OperationToken string
+	//签名随机数模式，0：随机k；1：RFC 6979确定性k
+	SignMode int
+	//并行签名的协程数，0为CPU核数，1为依次签名
+	SignWorkers int
+	//本地数据库加密密钥，hex编码的16/24/32字节AES密钥，为空不加密；已有的明文数据库首次打开时迁移，记录ID等索引键仍为明文
+	DBEncryptKey string
+	//是否记录交易审计日志
+	AuditLog bool
+	//审计日志数据库文件
+	AuditLogFile string
+	//审计日志签名密钥
+	AuditLogKey string
+	//是否为提取阶段打上pprof标签
+	ProfileLabels bool
+	//地址风险分数达到该值时拒绝创建交易单，0表示不拒绝
+	RiskBlockScore float64
+	//每个账户单笔提币最大金额，0表示不限制
+	WithdrawMaxPerTx decimal.Decimal
+	//每个账户每小时提币最大金额，0表示不限制
+	WithdrawMaxPerHour decimal.Decimal
+	//每个账户每天提币最大金额，0表示不限制
+	WithdrawMaxPerDay decimal.Decimal
+	//按资产设置的提币限额，key为币种符号或代币合约地址，优先于默认限额
+	WithdrawAssetLimits map[string]WithdrawLimit
+	//找零粉尘阈值，找零低于该值时不产生找零输出
+	ChangeDustThreshold decimal.Decimal
+	//找零粉尘处理策略
+	ChangeDustPolicy int
+	//发送NEO时对持有GAS领取周期的处理策略，交易单ExtParam可单独指定
+	NEOClaimPolicy int
+	//查询未花记录时每批地址数量
+	UnspentQueryChunkSize int
+	//查询未花记录的并发数
+	UnspentQueryConcurrency int
+	//扫描时是否验证区块头的共识节点签名
+	VerifyBlockSignature bool
+	//分叉后重扫区块时是否验证交易的见证签名
+	ForkRescanVerifyWitness bool
+	//按区块重组历史自动调整每轮扫描结束后重扫的区块数量
+	AdaptiveRescan bool
+	//自适应重扫的最小区块数量
+	RescanMinBlockCount uint64
+	//自适应重扫的最大区块数量
+	RescanMaxBlockCount uint64
+	//统计区块重组历史的期限
+	ReorgHistoryPeriod time.Duration
+	//分叉时是否自动通知孤块交易的回滚数据
+	ForkRollbackNotify bool
+	//NEO资产ID，私有链可自定义
+	NEOAssetID string
+	//GAS资产ID，私有链可自定义
+	GASAssetID string
+	//广播后是否向节点核对交易ID，用于尽早发现哈希方案回归
+	TxIDCheck bool
+	//是否严格按区块高度及交易顺序通知提取结果，关闭可提高吞吐
+	StrictNotifyOrder bool
+	//内存池交易连续多少次扫描消失且未上链，视为被丢弃
+	MempoolDropAfterScans int
+	//每N个区块发送一次账户活动汇总，0表示不发送
+	ActivityHeartbeatBlocks uint64
+	//账户活动汇总推送的webhook地址，为空不推送
+	ActivityHeartbeatURL string
+	//创世区块hash，配置后自检时核对节点网络
+	GenesisBlockHash string
+	//启动扫描前是否阻塞直到自检通过
+	SelfTestBeforeRun bool
+	//自检未通过的重试间隔
+	SelfTestRetryInterval time.Duration
+	//错误消息语言，en：英文；zh：简体中文
+	Locale string
+	//本地数据库文件锁等待时间，超时返回存储繁忙
+	DBLockTimeout time.Duration
+	//节点实现，决定区块数量与最高区块索引的偏移，见nodeFlavorHeightOffsets
+	NodeFlavor string
+	//区块数量与最高区块索引的偏移，小于0时按节点实现取值
+	BlockCountOffset int64
+	//扫描租约有效期，超过有效期未续约时其他实例可接管扫描，0表示不启用
+	ScanLeaseTTL time.Duration
+	//地址交易记录分页快照有效期，同一查询的后续页锚定到第一页的区块，0表示不启用
+	TxPageSnapshotTTL time.Duration
+	//内嵌浏览器只读HTTP接口监听地址，为空不启动
+	ExplorerListen string
+	//跟踪的代币合约脚本hash，定时刷新元数据
+	TokenContracts []string
+	//代币合约开始跟踪的区块高度，新上架的合约不回溯历史区块
+	TokenContractActivation map[string]uint64
+	//停用的代币合约，不再刷新元数据和告警，可在运行期间用SetTokenContractEnabled修改
+	DisabledTokenContracts []string
+	//代币合约元数据刷新间隔
+	TokenMetadataRefreshInterval time.Duration
+	//跟踪资产文件，yaml或json格式，配置后替代tokenContracts和disabledTokenContracts，相对路径以配置文件目录为准
+	TrackedAssetsFile string
+	//跟踪资产文件变化检查间隔
+	TrackedAssetsReloadInterval time.Duration
+	//从跟踪资产文件加载的代币资产，未配置文件时为nil
+	TrackedAssets []*TrackedAsset
+	//查询历史交易时，为提取时未打包但已确认的交易单补全区块hash和高度
+	HydrateMempoolRecords bool
+	//持久化每个观察者的提取结果通知投递状态，失败的通知定时补发，重启后继续投递
+	NotifyOutbox bool
+	//未投递通知的补发间隔
+	NotifyRedeliverInterval time.Duration
+	//观察者处理一次提取结果通知的耗时预算，0表示不检查
+	ObserverNotifyBudget time.Duration
+	//观察者连续超过耗时预算多少次后告警
+	ObserverSlowStreak uint64
+	//一次通知的输入输出总数超过该数量时分段通知，0表示不分段
+	NotifyChunkSize int
+	//一次通知的json大小估计超过该字节数时分段通知，0表示不检查
+	NotifyChunkBytes int
+	//落后最新区块超过该数量时推迟内存池扫描、多节点分歧检查、统计和定时维护任务，0表示不推迟
+	LoadShedBehind uint64
+	//推迟后回到落后该数量以内时恢复
+	LoadShedResume uint64
+	//浏览器接口类型，决定请求路径和返回结果的映射
+	ExplorerSchema string
+	//节点高度落后于备用节点和浏览器高度中位数超过该区块数时，暂停通知提取结果，0表示不检查
+	HeadLagThreshold uint64
+	//维护任务每次执行前的最大随机等待时间
+	SchedulerJitter time.Duration
+	//启用定时认领GAS任务
+	ClaimGASJob bool
+	//认领GAS的地址
+	ClaimGASAddresses []string
+	//认领GAS任务执行间隔
+	ClaimGASInterval time.Duration
+	//启用定时压缩本地数据库任务
+	CompactDBJob bool
+	//压缩本地数据库任务执行间隔
+	CompactDBInterval time.Duration
+	//启用定时广播任务，交易单可指定最早广播时间
+	ScheduledBroadcastJob bool
+	//定时广播任务检查到期交易单的间隔
+	ScheduledBroadcastInterval time.Duration
+	//交易达到要求的确认数后发送确认通知
+	ConfirmNotify bool
+	//地址或账户的确认数要求，未设置的使用ConfirmBlocks
+	MinConfirmations map[string]uint64
+	//高风险子系统的功能开关，按部署逐步开启，未配置的使用默认状态，见Feature
+	FeatureFlags map[string]bool
+	//发送确认通知前通过浏览器或备用节点核对观测地址的入账
+	DepositCrossVerify bool
+	//入账金额达到该值时核对，0表示全部核对
+	DepositCrossVerifyThreshold decimal.Decimal
+	//派生地址的节点校验模式，0：不校验；1：逐个校验；2：批量校验；3：抽样校验，见AddressAuditMode
+	AddressAuditMode int
+	//批量校验时每批请求的地址数量
+	AddressAuditBatchSize int
+	//抽样校验时每多少个地址校验一个
+	AddressAuditSampleRate int
+	//网络费统计保留的最近区块数量
+	FeeStatsBlocks uint64
+	//迁移区块链数据接口时的双写验证期限
+	DAIDualWritePeriod time.Duration
+	//可选功能RPC方法连续不可用达到该次数时熔断，0表示不熔断
+	RPCBreakerThreshold int
+	//熔断后放行试探请求的冷却时间
+	RPCBreakerCooldown time.Duration
+	//保留最近多少次节点RPC调用的记录，用于漏入账复盘，0表示不记录
+	RPCJournalSize int
+	//节点RPC调用记录文件，固定容量的环形文件
+	RPCJournalFile string
+	//允许测试工具注入模拟的分叉，只用于测试环境
+	ForkSimulation bool
+	//扫描器状态保留的最近错误数量，0表示不保留
+	StatusErrorHistory int
+	//浏览器模式，索引全部交易单，不限于观测地址
+	ExplorerMode bool
+	//浏览器模式交易索引的本地数据库文件
+	TxIndexFile string
+	//记录扫描地址的未花输出创建和花费高度，用于查询历史余额
+	UTXOHistory bool
+	//保存观测地址相关交易的原始数据，用于争议时提供交易的原始字节
+	RawTxArchive bool
+	//启用定时归档过期观测地址任务
+	ArchiveWatchAddressJob bool
+	//归档过期观测地址任务执行间隔
+	ArchiveWatchAddressInterval time.Duration
+	//启用定时清理软删除未扫记录任务
+	PurgeUnscanRecordJob bool
+	//清理软删除未扫记录任务执行间隔
+	PurgeUnscanRecordInterval time.Duration
+	//软删除的未扫记录保留时长，超过后被清理
+	UnscanRecordRetention time.Duration
+	//启用定时清理已保存提取结果任务
+	PruneExtractDataJob bool
+	//清理提取结果任务执行间隔
+	PruneExtractDataInterval time.Duration
+	//提取结果保留的区块数，早于本地高度减去该值的记录被清理，不能再补发
+	ExtractDataRetentionBlocks uint64
+	//启用定时统计本地数据库存储任务
+	StorageMetricsJob bool
+	//统计本地数据库存储任务执行间隔
+	StorageMetricsInterval time.Duration
+	//本地数据库文件超过该字节数时告警，0表示不检查
+	StorageWarnSize int64
+	//本地数据库文件每小时增长超过该字节数时告警，0表示不检查
+	StorageWarnGrowth int64
+	//手续费预估和报表的金额舍入方式
+	FeeRoundingMode RoundingMode
+	//报表显示的法币名称
+	FiatCurrency string
+	//报表显示的法币精度
+	FiatDecimals int32
+	//1 GAS对应的法币价格，0表示不换算
+	GASFiatRate decimal.Decimal
+	//启动时检查本地已扫描区块是否仍在节点链上，不在则回滚到共同祖先
+	StartupHeadCheck bool
+	//按节点最新区块缓存未花查询结果，新区块或本地广播相关交易后失效，
+	//每次查询需额外请求节点最新区块hash，适合构建交易时频繁查询相同地址的场景
+	UnspentCache bool
+	//回填时按浏览器的观测地址交易历史优先扫描相关区块，开启严格顺序通知时不生效
+	PriorityBackfill bool
+	//本地高度落后节点达到该区块数时才优先扫描
+	PriorityBackfillMinLag uint64
+	//签名后交易单的大小上限，按见证人大小估算，超出时拆分成多笔交易单
+	MaxTxSize int
+	//每N个区块发布一次检查点（累计入账和提取结果账本哈希），0表示不发布
+	CheckpointBlocks uint64
+	//每N个区块保存一次扫描状态快照（区块头和提取结果序号），用于RestoreToHeight恢复，0表示不保存
+	ScanSnapshotBlocks uint64
+	//保留最近的扫描状态快照数量，0表示不限制
+	ScanSnapshotRetention int
+	//启动后第一次区块迭代前同步节点内存池，提取停机期间广播的相关交易
+	StartupMempoolSync bool
 }
 
 func NewConfig(symbol string, curveType uint32, decimals int32) *WalletConfig {
@@ -147,25 +406,17 @@ func NewConfig(symbol string, curveType uint32, decimals int32) *WalletConfig {
 	c := WalletConfig{}
 
 	//币种
-	c.Symbol = symbol
+	c.setSymbol(symbol)
 	c.CurveType = curveType
 
 	//RPC认证账户名
 	c.RpcUser = ""
 	//RPC认证账户密码
 	c.RpcPassword = ""
-	//证书目录
-	c.CertsDir = filepath.Join("data", strings.ToLower(c.Symbol), "certs")
-	//钥匙备份路径
-	c.keyDir = filepath.Join("data", strings.ToLower(c.Symbol), "key")
-	//地址导出路径
-	c.addressDir = filepath.Join("data", strings.ToLower(c.Symbol), "address")
 	//区块链数据
 	//blockchainDir = filepath.Join("data", strings.ToLower(Symbol), "blockchain")
 	//配置文件路径
 	c.configFilePath = filepath.Join("conf")
-	//配置文件名
-	c.configFileName = c.Symbol + ".ini"
 	//rpc证书
 	c.CertFileName = "rpc.cert"
 	//区块链数据文件
@@ -176,10 +427,6 @@ func NewConfig(symbol string, curveType uint32, decimals int32) *WalletConfig {
 	c.CoreWalletWatchOnly = true
 	//最大的输入数量
 	c.MaxTxInputs = 1
-	//本地数据库文件路径
-	c.DBPath = filepath.Join("data", strings.ToLower(c.Symbol), "db")
-	//备份路径
-	c.backupDir = filepath.Join("data", strings.ToLower(c.Symbol), "backup")
 	//钱包服务API
 	c.ServerAPI = "http://127.0.0.1:10000"
 	//钱包安装的路径e
@@ -200,12 +447,161 @@ func NewConfig(symbol string, curveType uint32, decimals int32) *WalletConfig {
 	c.SupportSegWit = true
 	//是否支持omni
 	c.OmniSupport = false
+	c.OmniDeprecatedCompat = false
 	//小数位精度
 	c.Decimals = decimals
 	//最低手续费
 	c.MinFees = decimal.Zero
+	//区块确认数
+	c.ConfirmBlocks = 1
+	//首次扫描是否以浏览器最新高度减确认数作为起点
+	c.WarmStartFromExplorer = false
+	//无地址输出的处理策略
+	c.NonstandardOutputPolicy = NonstandardOutputSkip
+	c.ZeroConfDepositPolicy = ZeroConfNotify
+	//多节点分歧检查间隔
+	c.NodeDivergenceCheckInterval = time.Minute
 	c.MainNetAddressPrefix = MainNetAddressPrefix
 	c.TestNetAddressPrefix = TestNetAddressPrefix
+	//审计日志数据库文件
+	c.AuditLogFile = "audit.db"
+	//提币限额，默认不限制
+	c.WithdrawMaxPerTx = decimal.Zero
+	c.WithdrawMaxPerHour = decimal.Zero
+	c.WithdrawMaxPerDay = decimal.Zero
+	c.WithdrawAssetLimits = make(map[string]WithdrawLimit)
+	//找零粉尘阈值，默认不处理
+	c.ChangeDustThreshold = decimal.Zero
+	c.ChangeDustPolicy = ChangeDustToFees
+	//默认找零到第一个输入地址
+	c.NEOClaimPolicy = NEOClaimDefault
+	//未花记录分批查询
+	c.UnspentQueryChunkSize = 50
+	c.UnspentQueryConcurrency = 4
+	//分叉时自动通知回滚数据
+	c.ForkRollbackNotify = true
+	//资产ID
+	c.NEOAssetID = neoTransaction.NeoAssetId
+	c.GASAssetID = neoTransaction.NeoGasAssetId
+	//广播后核对交易ID
+	c.TxIDCheck = true
+	//严格顺序通知
+	c.StrictNotifyOrder = true
+	//内存池交易丢弃判定次数
+	c.MempoolDropAfterScans = 3
+	//自检重试间隔
+	c.SelfTestRetryInterval = 10 * time.Second
+	//错误消息语言
+	c.Locale = LocaleEN
+	//本地数据库文件锁等待时间
+	c.DBLockTimeout = 3 * time.Second
+	//节点实现
+	c.NodeFlavor = NodeFlavorNeoCli
+	//按节点实现取偏移
+	c.BlockCountOffset = -1
+	//扫描租约有效期
+	c.ScanLeaseTTL = 30 * time.Second
+	//地址交易记录分页快照有效期
+	c.TxPageSnapshotTTL = 10 * time.Minute
+	//代币合约元数据刷新间隔
+	c.TokenMetadataRefreshInterval = time.Hour
+	//跟踪资产文件变化检查间隔
+	c.TrackedAssetsReloadInterval = 30 * time.Second
+	//未投递通知的补发间隔
+	c.NotifyRedeliverInterval = 30 * time.Second
+	//观察者通知耗时预算和告警的连续次数
+	c.ObserverNotifyBudget = 500 * time.Millisecond
+	c.ObserverSlowStreak = 5
+	//大量输入输出的交易单分段通知
+	c.NotifyChunkSize = 1000
+	c.NotifyChunkBytes = 1 << 20
+	//追赶区块期间推迟低优先级工作
+	c.LoadShedBehind = 0
+	c.LoadShedResume = 10
+	//浏览器接口类型
+	c.ExplorerSchema = ExplorerSchemaInsight
+	//维护任务随机等待时间
+	c.SchedulerJitter = 5 * time.Minute
+	//认领GAS任务执行间隔
+	c.ClaimGASInterval = 24 * time.Hour
+	//压缩本地数据库任务执行间隔
+	c.CompactDBInterval = 7 * 24 * time.Hour
+	//定时广播任务
+	c.ScheduledBroadcastJob = true
+	c.ScheduledBroadcastInterval = 30 * time.Second
+	//自适应重扫的区块数量范围和重组历史统计期限
+	c.AdaptiveRescan = false
+	c.RescanMinBlockCount = 1
+	c.RescanMaxBlockCount = 12
+	c.ReorgHistoryPeriod = 7 * 24 * time.Hour
+	//地址或账户的确认数要求
+	c.MinConfirmations = make(map[string]uint64)
+	//功能开关
+	c.FeatureFlags = make(map[string]bool)
+	//入账核对
+	c.DepositCrossVerify = false
+	c.DepositCrossVerifyThreshold = decimal.Zero
+	//派生地址的节点校验，默认不校验
+	c.AddressAuditMode = AddressAuditOff
+	c.AddressAuditBatchSize = 100
+	c.AddressAuditSampleRate = 10
+	//网络费统计的区块数量
+	c.FeeStatsBlocks = 100
+	//双写验证期限
+	c.DAIDualWritePeriod = 7 * 24 * time.Hour
+	//RPC方法熔断阈值和冷却时间
+	c.RPCBreakerThreshold = 5
+	c.RPCBreakerCooldown = 5 * time.Minute
+	//节点RPC调用记录，默认不记录
+	c.RPCJournalSize = 0
+	c.RPCJournalFile = "rpcjournal.log"
+	//模拟分叉，默认不允许
+	c.ForkSimulation = false
+	//扫描器状态保留的最近错误数量
+	c.StatusErrorHistory = 20
+	//多节点读请求路由，默认只使用主节点
+	c.ReadRouting = false
+	//浏览器模式交易索引
+	c.ExplorerMode = false
+	c.TxIndexFile = "txindex.db"
+	//未花输出历史
+	c.UTXOHistory = false
+	c.RawTxArchive = false
+	//归档过期观测地址任务
+	c.ArchiveWatchAddressJob = true
+	c.ArchiveWatchAddressInterval = time.Hour
+	//清理软删除未扫记录任务
+	c.PurgeUnscanRecordJob = true
+	c.PurgeUnscanRecordInterval = 24 * time.Hour
+	c.UnscanRecordRetention = 30 * 24 * time.Hour
+	//清理提取结果任务，默认保留约90天的区块
+	c.PruneExtractDataJob = true
+	c.PruneExtractDataInterval = 24 * time.Hour
+	c.ExtractDataRetentionBlocks = 518400
+	//统计本地数据库存储任务，文件超过8GB或每小时增长超过256MB时告警
+	c.StorageMetricsJob = true
+	c.StorageMetricsInterval = time.Hour
+	c.StorageWarnSize = 8 << 30
+	c.StorageWarnGrowth = 256 << 20
+	//金额舍入和法币显示
+	c.FeeRoundingMode = RoundHalfUp
+	c.FiatCurrency = "USD"
+	c.FiatDecimals = 2
+	c.GASFiatRate = decimal.Zero
+	//启动时检查本地区块头
+	c.StartupHeadCheck = true
+	//未花查询缓存
+	c.UnspentCache = false
+	//优先回填
+	c.PriorityBackfill = false
+	c.PriorityBackfillMinLag = 1000
+	//交易单大小上限
+	c.MaxTxSize = MaxTransactionSize
+	//扫描状态快照
+	c.ScanSnapshotBlocks = 100
+	c.ScanSnapshotRetention = 48
+	//启动时同步内存池
+	c.StartupMempoolSync = true
 
 	//创建目录
 	//file.MkdirAll(c.dbPath)
@@ -215,6 +611,23 @@ func NewConfig(symbol string, curveType uint32, decimals int32) *WalletConfig {
 	return &c
 }
 
+//setSymbol 设置币种标识，与币种相关的目录和配置文件名一并更新，不同币种的数据相互隔离
+func (c *WalletConfig) setSymbol(symbol string) {
+	c.Symbol = symbol
+	//证书目录
+	c.CertsDir = filepath.Join("data", strings.ToLower(c.Symbol), "certs")
+	//钥匙备份路径
+	c.keyDir = filepath.Join("data", strings.ToLower(c.Symbol), "key")
+	//地址导出路径
+	c.addressDir = filepath.Join("data", strings.ToLower(c.Symbol), "address")
+	//配置文件名
+	c.configFileName = c.Symbol + ".ini"
+	//本地数据库文件路径
+	c.DBPath = filepath.Join("data", strings.ToLower(c.Symbol), "db")
+	//备份路径
+	c.backupDir = filepath.Join("data", strings.ToLower(c.Symbol), "backup")
+}
+
 //printConfig Print config information
 func (wc *WalletConfig) PrintConfig() error {
 
@@ -268,9 +681,104 @@ func (wc *WalletConfig) makeDataDir() {
 		wc.DataDir = "data"
 	}
 
-	//本地数据库文件路径
-	wc.DBPath = filepath.Join(wc.DataDir, strings.ToLower(wc.Symbol), "db")
+	//本地数据库文件路径，按币种和网络标识划分
+	wc.DBPath = wc.networkDBPath()
 
 	//创建目录
 	file.MkdirAll(wc.DBPath)
 }
+
+//clone 复制配置，切片字段独立分配，避免副本之间相互影响
+func (c *WalletConfig) clone() *WalletConfig {
+	cfg := *c
+	if c.BackupServerAPI != nil {
+		cfg.BackupServerAPI = make([]string, len(c.BackupServerAPI))
+		copy(cfg.BackupServerAPI, c.BackupServerAPI)
+	}
+	if c.NodeWeights != nil {
+		cfg.NodeWeights = make([]int, len(c.NodeWeights))
+		copy(cfg.NodeWeights, c.NodeWeights)
+	}
+	if c.BackupExplorerAPI != nil {
+		cfg.BackupExplorerAPI = make([]string, len(c.BackupExplorerAPI))
+		copy(cfg.BackupExplorerAPI, c.BackupExplorerAPI)
+	}
+	if c.TokenContracts != nil {
+		cfg.TokenContracts = make([]string, len(c.TokenContracts))
+		copy(cfg.TokenContracts, c.TokenContracts)
+	}
+	if c.TokenContractActivation != nil {
+		cfg.TokenContractActivation = make(map[string]uint64, len(c.TokenContractActivation))
+		for k, v := range c.TokenContractActivation {
+			cfg.TokenContractActivation[k] = v
+		}
+	}
+	if c.DisabledTokenContracts != nil {
+		cfg.DisabledTokenContracts = make([]string, len(c.DisabledTokenContracts))
+		copy(cfg.DisabledTokenContracts, c.DisabledTokenContracts)
+	}
+	if c.TrackedAssets != nil {
+		cfg.TrackedAssets = copyTrackedAssets(c.TrackedAssets)
+	}
+	if c.ClaimGASAddresses != nil {
+		cfg.ClaimGASAddresses = make([]string, len(c.ClaimGASAddresses))
+		copy(cfg.ClaimGASAddresses, c.ClaimGASAddresses)
+	}
+	if c.WithdrawAssetLimits != nil {
+		cfg.WithdrawAssetLimits = make(map[string]WithdrawLimit, len(c.WithdrawAssetLimits))
+		for k, v := range c.WithdrawAssetLimits {
+			cfg.WithdrawAssetLimits[k] = v
+		}
+	}
+	if c.MinConfirmations != nil {
+		cfg.MinConfirmations = make(map[string]uint64, len(c.MinConfirmations))
+		for k, v := range c.MinConfirmations {
+			cfg.MinConfirmations[k] = v
+		}
+	}
+	if c.FeatureFlags != nil {
+		cfg.FeatureFlags = make(map[string]bool, len(c.FeatureFlags))
+		for k, v := range c.FeatureFlags {
+			cfg.FeatureFlags[k] = v
+		}
+	}
+	return &cfg
+}
+
+//ConfigSnapshot 获取当前配置的副本，修改副本不影响正在使用的配置
+func (wm *WalletManager) ConfigSnapshot() *WalletConfig {
+	return wm.config().clone()
+}
+
+//config 当前生效的配置，运行期间配置只整体替换不原地修改，读取到的配置内容不会变化
+func (wm *WalletManager) config() *WalletConfig {
+	wm.configMu.RLock()
+	defer wm.configMu.RUnlock()
+	return wm.Config
+}
+
+//updateConfig 复制当前配置修改后整体替换，不影响已读取配置的调用方
+func (wm *WalletManager) updateConfig(update func(cfg *WalletConfig)) {
+	wm.configMu.Lock()
+	defer wm.configMu.Unlock()
+	cfg := wm.Config.clone()
+	update(cfg)
+	wm.Config = cfg
+}
+
+//SwapConfig 替换钱包配置，等待正在扫描的区块结束后生效，
+//同一区块扫描期间读取到的配置始终一致。不能在扫描通知的回调中调用
+func (wm *WalletManager) SwapConfig(cfg *WalletConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("config is nil")
+	}
+
+	wm.scanCycleMu.Lock()
+	defer wm.scanCycleMu.Unlock()
+
+	wm.configMu.Lock()
+	defer wm.configMu.Unlock()
+
+	wm.Config = cfg.clone()
+	return nil
+}
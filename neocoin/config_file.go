@@ -0,0 +1,540 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/astaxie/beego/config"
+)
+
+//LayeredConfig 多个配置文件叠加的配置，后面的文件覆盖前面的，环境变量优先于所有配置文件，
+//支持toml、json和ini格式，只支持顶层键值，数组按逗号连接为列表
+type LayeredConfig struct {
+	envPrefix string
+	mu        sync.Mutex
+	values    map[string]string //小写键 -> 值
+	keys      map[string]string //小写键 -> 文件中的原始键
+	sources   map[string]string //小写键 -> 来源文件
+	used      map[string]bool   //已读取的键
+	problems  []string          //值格式错误
+}
+
+//NewLayeredConfig 按顺序读取并叠加配置文件，envPrefix为环境变量前缀，如NEO时NEO_SERVER_API覆盖serverAPI
+func NewLayeredConfig(envPrefix string, paths ...string) (*LayeredConfig, error) {
+	c := &LayeredConfig{
+		envPrefix: envPrefix,
+		values:    make(map[string]string),
+		keys:      make(map[string]string),
+		sources:   make(map[string]string),
+		used:      make(map[string]bool),
+	}
+
+	for _, path := range paths {
+		values, err := parseConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range values {
+			lower := strings.ToLower(key)
+			c.values[lower] = val
+			c.keys[lower] = key
+			c.sources[lower] = path
+		}
+	}
+
+	return c, nil
+}
+
+//parseConfigFile 按扩展名解析配置文件
+func parseConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file failed, unexpected error: %v", err)
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		values, err = parseTOMLConfig(data)
+	case ".json":
+		values, err = parseJSONConfig(data)
+	default:
+		values, err = parseINIConfig(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file: %s, %v", path, err)
+	}
+	return values, nil
+}
+
+//parseINIConfig 解析ini格式，只读取默认分区
+func parseINIConfig(data []byte) (map[string]string, error) {
+	c, err := config.NewConfigData("ini", data)
+	if err != nil {
+		return nil, err
+	}
+	section, err := c.GetSection("default")
+	if err != nil {
+		return make(map[string]string), nil
+	}
+	return section, nil
+}
+
+//parseJSONConfig 解析json格式，值为字符串、数字、布尔或它们的数组
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var obj map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+
+	values := make(map[string]string)
+	for key, v := range obj {
+		val, err := jsonConfigValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("key: %s, %v", key, err)
+		}
+		if val != nil {
+			values[key] = *val
+		}
+	}
+	return values, nil
+}
+
+func jsonConfigValue(v interface{}) (*string, error) {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = val
+	case json.Number:
+		s = val.String()
+	case bool:
+		s = strconv.FormatBool(val)
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			if _, nested := item.([]interface{}); nested {
+				return nil, fmt.Errorf("nested arrays are not supported")
+			}
+			str, err := jsonConfigValue(item)
+			if err != nil {
+				return nil, err
+			}
+			if str != nil {
+				items = append(items, *str)
+			}
+		}
+		s = strings.Join(items, ",")
+	default:
+		return nil, fmt.Errorf("nested objects are not supported, use top level keys")
+	}
+	return &s, nil
+}
+
+//parseTOMLConfig 解析toml格式的顶层键值，不支持表和多行字符串
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if len(text) == 0 {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, use top level keys", line)
+		}
+
+		kv := strings.SplitN(text, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		if unquoted, err := strconv.Unquote(key); err == nil {
+			key = unquoted
+		} else if strings.Contains(key, ".") {
+			return nil, fmt.Errorf("line %d: dotted keys are not supported, use top level keys", line)
+		}
+
+		val, err := tomlConfigValue(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: key: %s, %v", line, key, err)
+		}
+		values[key] = val
+	}
+	return values, scanner.Err()
+}
+
+//stripTOMLComment 去掉引号外的注释
+func stripTOMLComment(s string) string {
+	var quote rune
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func tomlConfigValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return "", fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated literal string")
+		}
+		return s[1 : len(s)-1], nil
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return "", fmt.Errorf("arrays should be written in one line")
+		}
+		items := make([]string, 0)
+		for _, item := range splitTOMLArray(s[1 : len(s)-1]) {
+			if item = strings.TrimSpace(item); len(item) == 0 {
+				continue
+			}
+			val, err := tomlConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, val)
+		}
+		return strings.Join(items, ","), nil
+	case strings.HasPrefix(s, "{"):
+		return "", fmt.Errorf("inline tables are not supported")
+	case s == "true" || s == "false":
+		return s, nil
+	}
+
+	//数字允许下划线分隔
+	num := strings.Replace(s, "_", "", -1)
+	if _, err := strconv.ParseFloat(num, 64); err != nil {
+		return "", fmt.Errorf("invalid value: %s, strings should be quoted", s)
+	}
+	return num, nil
+}
+
+//splitTOMLArray 按引号外的逗号分割数组元素
+func splitTOMLArray(s string) []string {
+	items := make([]string, 0)
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[':
+			//嵌套数组交给元素解析报错
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+//EnvName 配置键对应的环境变量名，驼峰转为大写下划线，如serverAPI对应NEO_SERVER_API
+func (c *LayeredConfig) EnvName(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	if len(c.envPrefix) == 0 {
+		return b.String()
+	}
+	return c.envPrefix + "_" + b.String()
+}
+
+//get 读取配置值，环境变量优先
+func (c *LayeredConfig) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lower := strings.ToLower(key)
+	c.used[lower] = true
+	if val, ok := os.LookupEnv(c.EnvName(key)); ok {
+		return val, true
+	}
+	val, ok := c.values[lower]
+	return val, ok
+}
+
+//problem 记录值格式错误
+func (c *LayeredConfig) problem(key, val, expected string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	source := c.sources[strings.ToLower(key)]
+	if _, ok := os.LookupEnv(c.EnvName(key)); ok {
+		source = "env " + c.EnvName(key)
+	}
+	c.problems = append(c.problems, fmt.Sprintf("%s: %q is not %s (from %s)", key, val, expected, source))
+}
+
+//Problems 读取过程中发现的值格式错误
+func (c *LayeredConfig) Problems() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.problems...)
+}
+
+//UnusedKeys 配置文件中未被读取的键，通常是拼写错误
+func (c *LayeredConfig) UnusedKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0)
+	for lower, key := range c.keys {
+		if !c.used[lower] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *LayeredConfig) Set(key, val string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.ToLower(key)] = val
+	return nil
+}
+
+func (c *LayeredConfig) String(key string) string {
+	val, _ := c.get(key)
+	return val
+}
+
+func (c *LayeredConfig) Strings(key string) []string {
+	val := c.String(key)
+	if len(val) == 0 {
+		return nil
+	}
+	return strings.Split(val, ";")
+}
+
+func (c *LayeredConfig) Int(key string) (int, error) {
+	val, _ := c.get(key)
+	n, err := strconv.Atoi(val)
+	if err != nil && len(val) > 0 {
+		c.problem(key, val, "an integer")
+	}
+	return n, err
+}
+
+func (c *LayeredConfig) Int64(key string) (int64, error) {
+	val, _ := c.get(key)
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil && len(val) > 0 {
+		c.problem(key, val, "an integer")
+	}
+	return n, err
+}
+
+func (c *LayeredConfig) Bool(key string) (bool, error) {
+	val, _ := c.get(key)
+	b, err := config.ParseBool(val)
+	if err != nil && len(val) > 0 {
+		c.problem(key, val, "a boolean")
+	}
+	return b, err
+}
+
+func (c *LayeredConfig) Float(key string) (float64, error) {
+	val, _ := c.get(key)
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil && len(val) > 0 {
+		c.problem(key, val, "a number")
+	}
+	return f, err
+}
+
+func (c *LayeredConfig) DefaultString(key string, defaultVal string) string {
+	if val := c.String(key); len(val) > 0 {
+		return val
+	}
+	return defaultVal
+}
+
+func (c *LayeredConfig) DefaultStrings(key string, defaultVal []string) []string {
+	if val := c.Strings(key); val != nil {
+		return val
+	}
+	return defaultVal
+}
+
+func (c *LayeredConfig) DefaultInt(key string, defaultVal int) int {
+	if n, err := c.Int(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *LayeredConfig) DefaultInt64(key string, defaultVal int64) int64 {
+	if n, err := c.Int64(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *LayeredConfig) DefaultBool(key string, defaultVal bool) bool {
+	if b, err := c.Bool(key); err == nil {
+		return b
+	}
+	return defaultVal
+}
+
+func (c *LayeredConfig) DefaultFloat(key string, defaultVal float64) float64 {
+	if f, err := c.Float(key); err == nil {
+		return f
+	}
+	return defaultVal
+}
+
+func (c *LayeredConfig) DIY(key string) (interface{}, error) {
+	if val, ok := c.get(key); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("key: %s not found", key)
+}
+
+func (c *LayeredConfig) GetSection(section string) (map[string]string, error) {
+	if section != "default" {
+		return nil, fmt.Errorf("nonexist section %s", section)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := make(map[string]string, len(c.values))
+	for key, val := range c.values {
+		values[key] = val
+	}
+	return values, nil
+}
+
+func (c *LayeredConfig) SaveConfigFile(filename string) error {
+	return fmt.Errorf("layered config can not be saved, edit the config files instead")
+}
+
+var _ config.Configer = (*LayeredConfig)(nil)
+
+//LoadConfig 读取配置文件初始化钱包管理者，多个文件按顺序叠加，后面的覆盖前面的，
+//环境变量<SYMBOL>_<KEY>优先于配置文件，未指定文件时读取默认配置文件。
+//读取后检查必填项和值格式，所有问题一次返回
+func (wm *WalletManager) LoadConfig(paths ...string) error {
+
+	if len(paths) == 0 {
+		absFile := filepath.Join(wm.config().configFilePath, wm.config().configFileName)
+		if _, err := os.Stat(absFile); err != nil {
+			return fmt.Errorf("Config is not setup. Please run 'wmd Config -s <symbol>' ")
+		}
+		paths = []string{absFile}
+	}
+
+	c, err := NewLayeredConfig(strings.ToUpper(wm.config().Symbol), paths...)
+	if err != nil {
+		return err
+	}
+
+	cfg, assetsStamp := wm.parseAssetsConfig(c)
+
+	for _, key := range c.UnusedKeys() {
+		wm.Log.Std.Warning("config key: %s is not used, check the spelling", key)
+	}
+
+	problems := append(c.Problems(), cfg.validate(c)...)
+	if len(problems) > 0 {
+		return wm.errorf(ErrConfigInvalid, "invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return wm.applyAssetsConfig(cfg, assetsStamp)
+}
+
+//validate 检查必填项和取值范围，返回发现的问题
+func (wc *WalletConfig) validate(c *LayeredConfig) []string {
+
+	problems := make([]string, 0)
+	require := func(ok bool, key, msg string) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s, set it in config file or env %s", key, msg, c.EnvName(key)))
+		}
+	}
+
+	require(len(wc.ServerAPI) > 0, "serverAPI", "is required")
+	require(wc.RPCServerType == RPCServerCore || wc.RPCServerType == RPCServerExplorer, "rpcServerType", "should be 0 or 1")
+	explorerMappersMu.RLock()
+	_, schemaOK := explorerMappers[wc.ExplorerSchema]
+	explorerMappersMu.RUnlock()
+	require(schemaOK, "explorerSchema", "is not a registered explorer schema")
+	require(wc.NonstandardOutputPolicy == 0 || wc.NonstandardOutputPolicy == 1, "nonstandardOutputPolicy", "should be 0 or 1")
+	require(wc.ChangeDustPolicy == 0 || wc.ChangeDustPolicy == 1, "changeDustPolicy", "should be 0 or 1")
+	require(wc.SignMode == 0 || wc.SignMode == 1, "signMode", "should be 0 or 1")
+	if len(wc.DBEncryptKey) > 0 {
+		key, err := hex.DecodeString(strings.TrimPrefix(wc.DBEncryptKey, "0x"))
+		require(err == nil && (len(key) == 16 || len(key) == 24 || len(key) == 32), "dbEncryptKey", "should be hex encoded 16/24/32 bytes")
+	}
+	if wc.ClaimGASJob {
+		require(len(wc.ClaimGASAddresses) > 0, "claimGASAddresses", "is required when claimGASJob is enabled")
+	}
+
+	problems = append(problems, wc.Validate()...)
+
+	return problems
+}
@@ -16,9 +16,17 @@
 package neocoin
 
 import (
-	"github.com/astaxie/beego/config"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
 )
 
 func TestWalletManager_InitAssetsConfig(t *testing.T) {
@@ -51,4 +59,273 @@ func TestWalletManager_LoadAssetsConfig(t *testing.T) {
 		return
 	}
 	t.Logf("ServerAPI: %s", tw.Config.ServerAPI)
-}
\ No newline at end of file
+}
+
+func TestWalletManager_SwapConfig(t *testing.T) {
+	wm := NewWalletManager()
+
+	cfg := wm.ConfigSnapshot()
+	cfg.ConfirmBlocks = 12
+	cfg.BackupServerAPI = []string{"http://127.0.0.1:30334"}
+	if wm.Config.ConfirmBlocks == 12 {
+		t.Errorf("modify snapshot should not affect current config")
+	}
+
+	//模拟进行中的扫描周期
+	wm.scanCycleMu.RLock()
+	done := make(chan struct{})
+	go func() {
+		wm.SwapConfig(cfg)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Errorf("swap config should wait for scan cycle")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if wm.Config.ConfirmBlocks == 12 {
+		t.Errorf("config changed during scan cycle")
+	}
+	wm.scanCycleMu.RUnlock()
+	<-done
+
+	if wm.Config.ConfirmBlocks != 12 {
+		t.Errorf("config should be swapped")
+	}
+
+	cfg.BackupServerAPI[0] = "changed"
+	if wm.Config.BackupServerAPI[0] != "http://127.0.0.1:30334" {
+		t.Errorf("swapped config should not share slice with caller")
+	}
+
+	if err := wm.SwapConfig(nil); err == nil {
+		t.Errorf("swap nil config should fail")
+	}
+}
+
+func TestWalletConfig_CloneDeepCopy(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.MinConfirmations = map[string]uint64{"acc": 3}
+	wm.Config.ClaimGASAddresses = []string{"AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}
+
+	cfg := wm.ConfigSnapshot()
+	cfg.MinConfirmations["acc"] = 10
+	cfg.ClaimGASAddresses[0] = "changed"
+	if wm.Config.MinConfirmations["acc"] != 3 || wm.Config.ClaimGASAddresses[0] == "changed" {
+		t.Errorf("snapshot should not share map or slice with current config")
+	}
+
+	wm.SwapConfig(cfg)
+	cfg.MinConfirmations["acc"] = 20
+	if wm.Config.MinConfirmations["acc"] != 10 {
+		t.Errorf("swapped config should not share map with caller")
+	}
+}
+
+func TestWalletManager_SwapConfigBetweenBlocks(t *testing.T) {
+	wm := NewWalletManager()
+	bs := wm.Blockscanner
+
+	cfg := wm.ConfigSnapshot()
+	cfg.ConfirmBlocks = 12
+
+	//模拟扫描任务持有读锁
+	wm.scanCycleMu.RLock()
+	done := make(chan struct{})
+	go func() {
+		wm.SwapConfig(cfg)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	//扫描下一个区块前让出，等待中的替换生效
+	bs.yieldScanCycle()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("swap config should take effect between blocks")
+	}
+	if wm.config().ConfirmBlocks != 12 {
+		t.Errorf("config should be swapped")
+	}
+	wm.scanCycleMu.RUnlock()
+}
+
+func TestWalletManager_LoadAssetsConfig_PrivateChain(t *testing.T) {
+	conf := `
+serverAPI = "http://127.0.0.1:30333"
+dataDir = "%s"
+symbol = "%s"
+gasAssetID = "0xabcd"
+`
+	tempDir, _ := ioutil.TempDir("", "neo-data")
+	defer os.RemoveAll(tempDir)
+	dataDir := filepath.Join(tempDir, "data")
+
+	symbols := []string{"NEO-PRIV", "NEO-TEST"}
+	dbPaths := make(map[string]bool)
+	for _, symbol := range symbols {
+		c, err := config.NewConfigData("ini", []byte(fmt.Sprintf(conf, dataDir, symbol)))
+		if err != nil {
+			t.Errorf("NewConfigData failed unexpected error: %v\n", err)
+			return
+		}
+
+		wm := NewWalletManager()
+		wm.LoadAssetsConfig(c)
+
+		if wm.Symbol() != symbol {
+			t.Errorf("symbol should be %s, got %s", symbol, wm.Symbol())
+		}
+		if wm.Config.GASAssetID != "abcd" {
+			t.Errorf("gas asset id should be abcd, got %s", wm.Config.GASAssetID)
+		}
+		dbPaths[wm.Config.DBPath] = true
+	}
+
+	if len(dbPaths) != len(symbols) {
+		t.Errorf("each symbol should use isolated db path: %v", dbPaths)
+	}
+
+	result := gjson.Parse(`{"address":"AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y","balance":[{"asset_hash":"0xabcd","asset_symbol":"PGAS","amount":"1"},{"asset_hash":"0x1234","asset_symbol":"PNEO","amount":"2"}]}`)
+	balance, err := NewUnspentBalance(&result, "abcd")
+	if err != nil {
+		t.Errorf("NewUnspentBalance failed unexpected error: %v\n", err)
+		return
+	}
+	if balance.GASUnspent == nil || balance.GASUnspent.Amount != "1" {
+		t.Errorf("custom gas asset should be recognized")
+	}
+}
+
+func TestWalletManager_LoadConfig(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "neo-conf")
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"base.ini": fmt.Sprintf(`
+serverAPI = "http://127.0.0.1:30333"
+dataDir = "%s"
+confirmBlocks = 1
+`, filepath.Join(tempDir, "data")),
+		"override.toml": `
+# 生产环境覆盖
+confirmBlocks = 6
+backupServerAPI = ["http://127.0.0.1:30334", "http://127.0.0.1:30335"]
+rpcUser = 'ops' # 行尾注释
+`,
+		"override.json": `{"minConfirmations": ["AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT:12"], "confirmNotify": true}`,
+	}
+	paths := make([]string, 0)
+	for _, name := range []string{"base.ini", "override.toml", "override.json"} {
+		path := filepath.Join(tempDir, name)
+		ioutil.WriteFile(path, []byte(files[name]), 0600)
+		paths = append(paths, path)
+	}
+
+	os.Setenv("NEO_RPC_PASSWORD", "from-env")
+	defer os.Unsetenv("NEO_RPC_PASSWORD")
+
+	wm := NewWalletManager()
+	if err := wm.LoadConfig(paths...); err != nil {
+		t.Errorf("LoadConfig failed unexpected error: %v\n", err)
+		return
+	}
+
+	if wm.Config.ConfirmBlocks != 6 || wm.Config.RpcUser != "ops" || wm.Config.RpcPassword != "from-env" || len(wm.Config.BackupServerAPI) != 2 || !wm.Config.ConfirmNotify || wm.Config.MinConfirmations["AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"] != 12 {
+		t.Errorf("unexpected layered config: %+v", wm.Config)
+	}
+
+	//缺少必填项和值格式错误一次返回
+	bad := filepath.Join(tempDir, "bad.toml")
+	ioutil.WriteFile(bad, []byte(fmt.Sprintf("dataDir = %q\nrpcServerType = 3\nconfirmBlocks = \"six\"\n", filepath.Join(tempDir, "data"))), 0600)
+	err := NewWalletManager().LoadConfig(bad)
+	if err == nil || !strings.Contains(err.Error(), "serverAPI is required") || !strings.Contains(err.Error(), "NEO_SERVER_API") ||
+		!strings.Contains(err.Error(), "rpcServerType should be 0 or 1") || !strings.Contains(err.Error(), `confirmBlocks: "six" is not an integer`) {
+		t.Errorf("LoadConfig should report all problems, err: %v", err)
+	}
+
+	table := filepath.Join(tempDir, "table.toml")
+	ioutil.WriteFile(table, []byte("[node]\nserverAPI = \"http://127.0.0.1:30333\"\n"), 0600)
+	if err := NewWalletManager().LoadConfig(table); err == nil || !strings.Contains(err.Error(), "line 1: tables are not supported") {
+		t.Errorf("LoadConfig should reject toml tables, err: %v", err)
+	}
+}
+
+func TestLayeredConfig_EnvName(t *testing.T) {
+	c := &LayeredConfig{envPrefix: "NEO"}
+	cases := map[string]string{
+		"serverAPI":                   "NEO_SERVER_API",
+		"rpcUser":                     "NEO_RPC_USER",
+		"claimGASJob":                 "NEO_CLAIM_GAS_JOB",
+		"tokenMetadataRefreshSeconds": "NEO_TOKEN_METADATA_REFRESH_SECONDS",
+		"isTestNet":                   "NEO_IS_TEST_NET",
+	}
+	for key, expected := range cases {
+		if name := c.EnvName(key); name != expected {
+			t.Errorf("env name of %s: %s, expected: %s", key, name, expected)
+		}
+	}
+}
+
+func TestWalletConfig_Validate(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "neo-data")
+	defer os.RemoveAll(tempDir)
+
+	wm := NewWalletManager()
+	wm.Config.DataDir = tempDir
+	wm.Config.DBPath = filepath.Join(tempDir, "neo", "db")
+	if problems := wm.Config.Validate(); len(problems) != 0 {
+		t.Errorf("default config should be valid, problems: %v", problems)
+	}
+
+	//文件不能作为数据目录
+	notDir := filepath.Join(tempDir, "file")
+	ioutil.WriteFile(notDir, []byte{}, 0600)
+
+	c, _ := config.NewConfigData("ini", []byte(fmt.Sprintf(`
+serverAPI = "127.0.0.1:30333"
+rpcServerType = 1
+dataDir = "%s"
+unspentCache = true
+explorerListen = "10080"
+priorityBackfill = true
+strictNotifyOrder = true
+tokenContracts = "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9, 0xECC6B20D3CCAC1EE9EF109AF5A7CDB85706B1DF9, 0x1234"
+disabledTokenContracts = "0xceab719b8baa2310f232ee0d277c061704541cfb"
+`, filepath.Join(notDir, "data"))))
+	invalid := NewWalletManager()
+	before := invalid.config()
+	err := invalid.LoadAssetsConfig(c)
+	openErr, ok := err.(*openwallet.Error)
+	if !ok || openErr.Code() != ErrConfigInvalid {
+		t.Fatalf("LoadAssetsConfig should fail with invalid config, err: %v", err)
+	}
+	//检查失败时不替换当前配置
+	if invalid.config() != before || invalid.Config.ServerAPI == "127.0.0.1:30333" || invalid.Config.UnspentCache {
+		t.Errorf("invalid config should not be applied")
+	}
+	for _, expected := range []string{
+		`serverAPI: "127.0.0.1:30333"`,
+		"unspentCache requires rpcServerType = 0",
+		`explorerListen: "10080" should be host:port`,
+		"priorityBackfill has no effect when strictNotifyOrder is enabled",
+		"dataDir: ",
+		`"0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9" is duplicated`,
+		`tokenContracts: "0x1234" should be 20 bytes hex script hash`,
+		`disabledTokenContracts: "0xceab719b8baa2310f232ee0d277c061704541cfb" is not in tokenContracts`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("problems should contain: %s, err: %v", expected, err)
+		}
+	}
+
+	//核心节点模式下依赖浏览器的功能
+	wm.Config.WarmStartFromExplorer = true
+	wm.Config.DepositCrossVerify = true
+	problems := strings.Join(wm.Config.Validate(), "; ")
+	if !strings.Contains(problems, "warmStartFromExplorer requires explorerAPI") || !strings.Contains(problems, "depositCrossVerify requires explorerAPI or backupServerAPI") {
+		t.Errorf("unexpected core mode problems: %s", problems)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Validate 检查互斥的配置、数据目录是否可写、URL格式和跟踪的代币合约，返回发现的所有问题，
+//启动时一次报告，避免扫描中途才失败
+func (wc *WalletConfig) Validate() []string {
+
+	problems := make([]string, 0)
+	report := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	//URL格式
+	checkURL := func(key, value string) {
+		if len(value) == 0 {
+			return
+		}
+		if err := validateHTTPURL(value); err != nil {
+			report("%s: %q %v", key, value, err)
+		}
+	}
+	checkURL("serverAPI", wc.ServerAPI)
+	checkURL("explorerAPI", wc.ExplorerAPI)
+	for _, api := range wc.BackupServerAPI {
+		checkURL("backupServerAPI", api)
+	}
+	for _, api := range wc.BackupExplorerAPI {
+		checkURL("backupExplorerAPI", api)
+	}
+	checkURL("activityHeartbeatURL", wc.ActivityHeartbeatURL)
+	if wc.OmniSupport && wc.OmniDeprecatedCompat {
+		checkURL("omniCoreAPI", wc.OmniCoreAPI)
+	}
+	if len(wc.ExplorerListen) > 0 {
+		if _, _, err := net.SplitHostPort(wc.ExplorerListen); err != nil {
+			report("explorerListen: %q should be host:port", wc.ExplorerListen)
+		}
+	}
+
+	//浏览器数据源不支持只能通过核心节点实现的功能
+	if wc.RPCServerType == RPCServerExplorer {
+		coreOnly := []struct {
+			key     string
+			enabled bool
+		}{
+			{"depositCrossVerify", wc.DepositCrossVerify},
+			{"unspentCache", wc.UnspentCache},
+			{"publicNodeMode", wc.PublicNodeMode},
+			{"forkRescanVerifyWitness", wc.ForkRescanVerifyWitness},
+			{"claimGASJob", wc.ClaimGASJob},
+			{"addressAuditMode", wc.AddressAuditMode != AddressAuditOff},
+			{"forkSimulation", wc.ForkSimulation},
+			{"readRouting", wc.ReadRouting},
+		}
+		for _, f := range coreOnly {
+			if f.enabled {
+				report("%s requires rpcServerType = 0, it has no effect with explorer", f.key)
+			}
+		}
+	} else {
+		//核心节点模式下依赖浏览器的功能需要配置浏览器API
+		if wc.WarmStartFromExplorer && len(wc.ExplorerAPI) == 0 {
+			report("warmStartFromExplorer requires explorerAPI")
+		}
+		if wc.PriorityBackfill && len(wc.ExplorerAPI) == 0 {
+			report("priorityBackfill requires explorerAPI")
+		}
+		if wc.DepositCrossVerify && len(wc.ExplorerAPI) == 0 && len(wc.BackupServerAPI) == 0 {
+			report("depositCrossVerify requires explorerAPI or backupServerAPI")
+		}
+	}
+	if wc.ReadRouting && len(wc.BackupServerAPI) == 0 {
+		report("readRouting requires backupServerAPI")
+	}
+	if len(wc.NodeWeights) > 1+len(wc.BackupServerAPI) {
+		report("nodeWeights: %d weights are more than %d nodes of serverAPI and backupServerAPI", len(wc.NodeWeights), 1+len(wc.BackupServerAPI))
+	}
+	for _, w := range wc.NodeWeights {
+		if w < 0 {
+			report("nodeWeights: %v should be non-negative integers", wc.NodeWeights)
+			break
+		}
+	}
+	if wc.ExplorerQuorum > 1+len(wc.BackupExplorerAPI) {
+		report("explorerQuorum: %d can not be reached with %d backup explorers", wc.ExplorerQuorum, len(wc.BackupExplorerAPI))
+	}
+	if wc.PriorityBackfill && wc.StrictNotifyOrder {
+		report("priorityBackfill has no effect when strictNotifyOrder is enabled, disable one of them")
+	}
+	if wc.LoadShedBehind > 0 && wc.LoadShedResume >= wc.LoadShedBehind {
+		report("loadShedResume: %d should be less than loadShedBehind: %d", wc.LoadShedResume, wc.LoadShedBehind)
+	}
+	if wc.ForkSimulation && wc.VerifyBlockSignature {
+		report("forkSimulation can not be used with verifyBlockSignature, simulated blocks have no valid witness")
+	}
+	if wc.AddressAuditMode < AddressAuditOff || wc.AddressAuditMode > AddressAuditSample {
+		report("addressAuditMode: %d is unknown, should be 0 to 3", wc.AddressAuditMode)
+	}
+	for name, enabled := range wc.FeatureFlags {
+		if !isKnownFeature(name) {
+			report("featureFlags: %q is unknown", name)
+		} else if Feature(name) == FeatureRawBlockParse && enabled && wc.RPCServerType == RPCServerExplorer {
+			report("featureFlags: %s requires rpcServerType = 0, it has no effect with explorer", name)
+		}
+	}
+
+	//数据目录可写
+	checkDir := func(key, dir string) {
+		if len(dir) == 0 {
+			return
+		}
+		if err := checkWritableDir(dir); err != nil {
+			report("%s: %q is not writable, %v", key, dir, err)
+		}
+	}
+	checkDir("dataDir", wc.DataDir)
+	checkDir("dbPath", wc.DBPath)
+
+	//跟踪资产文件
+	if len(wc.TrackedAssetsFile) > 0 {
+		if _, _, err := wc.readTrackedAssetsFile(); err != nil {
+			report("trackedAssetsFile: %v", err)
+		}
+	}
+
+	//跟踪的代币合约
+	tracked := make(map[string]bool, len(wc.TokenContracts))
+	for _, contract := range wc.TokenContracts {
+		if !isContractHash(contract) {
+			report("tokenContracts: %q should be 20 bytes hex script hash", contract)
+			continue
+		}
+		if tracked[contract] {
+			report("tokenContracts: %q is duplicated", contract)
+		}
+		tracked[contract] = true
+	}
+	for contract := range wc.TokenContractActivation {
+		if !tracked[contract] {
+			report("tokenContracts: activation height of %q is set but the contract is not tracked", contract)
+		}
+	}
+	for _, contract := range wc.DisabledTokenContracts {
+		if !isContractHash(contract) {
+			report("disabledTokenContracts: %q should be 20 bytes hex script hash", contract)
+		} else if !tracked[contract] {
+			report("disabledTokenContracts: %q is not in tokenContracts", contract)
+		}
+	}
+
+	return problems
+}
+
+//validateHTTPURL 检查是否为http或https地址
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("is not a valid url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("should start with http:// or https://")
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("has no host")
+	}
+	return nil
+}
+
+//checkWritableDir 检查目录可写，目录不存在时检查最近的已存在上级目录
+func checkWritableDir(dir string) error {
+
+	path := filepath.Clean(dir)
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", path)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return err
+		}
+		path = parent
+	}
+
+	f, err := ioutil.TempFile(path, ".write-check")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return nil
+}
+
+//isContractHash 是否为0x开头的20字节hex合约脚本hash
+func isContractHash(contract string) bool {
+	if !strings.HasPrefix(contract, "0x") {
+		return false
+	}
+	raw, err := hex.DecodeString(contract[2:])
+	return err == nil && len(raw) == 20
+}
@@ -0,0 +1,351 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//TxActionConfirmed 交易达到要求的确认数后发送的确认通知标识
+	TxActionConfirmed = "confirmed"
+
+	//confirmNotifiedRetention 已发送确认通知的记录保留时间，期间重扫同一交易不再重复跟踪
+	confirmNotifiedRetention = 7 * 24 * time.Hour
+)
+
+//ConfirmPendingRecord 已通知的区块交易，达到要求的确认数后发送确认通知
+type ConfirmPendingRecord struct {
+	ID          string `storm:"id"`
+	TxID        string `storm:"index"`
+	SourceKey   string
+	BlockHash   string
+	BlockHeight uint64
+	Required    uint64 //要求的确认数
+	Data        *openwallet.TxExtractData
+	Notified    bool `storm:"index"` //是否已发送确认通知
+	Mismatch    bool //入账核对不一致，已发出告警
+	CreateAt    int64
+	UpdateAt    int64
+}
+
+func NewConfirmPendingRecord(sourceKey string, required uint64, data *openwallet.TxExtractData) *ConfirmPendingRecord {
+	obj := ConfirmPendingRecord{}
+	obj.SourceKey = sourceKey
+	obj.Required = required
+	obj.Data = data
+	if data != nil && data.Transaction != nil {
+		obj.TxID = data.Transaction.TxID
+		obj.BlockHash = data.Transaction.BlockHash
+		obj.BlockHeight = data.Transaction.BlockHeight
+	}
+	obj.CreateAt = time.Now().Unix()
+	obj.UpdateAt = obj.CreateAt
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("confirm_%s_%s", obj.TxID, sourceKey))))
+	return &obj
+}
+
+//SetMinConfirmations 设置地址或账户的确认数要求，覆盖配置文件，为0时恢复配置文件或默认的确认数
+func (wm *WalletManager) SetMinConfirmations(key string, confirmations uint64) {
+	wm.confirmMu.Lock()
+	defer wm.confirmMu.Unlock()
+	if wm.minConfirms == nil {
+		wm.minConfirms = make(map[string]uint64)
+	}
+	if confirmations == 0 {
+		delete(wm.minConfirms, key)
+		return
+	}
+	wm.minConfirms[key] = confirmations
+}
+
+//minConfirmationsOf 地址或账户设置的确认数要求，未设置返回0
+func (wm *WalletManager) minConfirmationsOf(key string) uint64 {
+	wm.confirmMu.Lock()
+	n, ok := wm.minConfirms[key]
+	wm.confirmMu.Unlock()
+	if ok {
+		return n
+	}
+	return wm.config().MinConfirmations[key]
+}
+
+//RequiredConfirmations 交易要求的确认数，交易涉及的地址有设置时取其中最大值，
+//否则使用账户的设置，都未设置时使用配置的确认数
+func (wm *WalletManager) RequiredConfirmations(sourceKey string, data *openwallet.TxExtractData) uint64 {
+
+	var required uint64
+	if data != nil {
+		for _, input := range data.TxInputs {
+			if n := wm.minConfirmationsOf(input.Address); n > required {
+				required = n
+			}
+		}
+		for _, output := range data.TxOutputs {
+			if n := wm.minConfirmationsOf(output.Address); n > required {
+				required = n
+			}
+		}
+	}
+	if required > 0 {
+		return required
+	}
+
+	if data != nil && data.Transaction != nil && len(data.Transaction.AccountID) > 0 {
+		if n := wm.minConfirmationsOf(data.Transaction.AccountID); n > 0 {
+			return n
+		}
+	}
+	if n := wm.minConfirmationsOf(sourceKey); n > 0 {
+		return n
+	}
+
+	if wm.config().ConfirmBlocks > 0 {
+		return wm.config().ConfirmBlocks
+	}
+	return 1
+}
+
+//SaveConfirmPending 跟踪已通知的区块交易，已发送过确认通知的不再跟踪
+func (wm *WalletManager) SaveConfirmPending(extractData map[string]*openwallet.TxExtractData) error {
+
+	if len(extractData) == 0 {
+		return nil
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for key, data := range extractData {
+		record := NewConfirmPendingRecord(key, wm.RequiredConfirmations(key, data), data)
+		if record.BlockHeight == 0 {
+			continue
+		}
+		var exist ConfirmPendingRecord
+		if err = tx.One("ID", record.ID, &exist); err == nil && exist.Notified && exist.BlockHash == record.BlockHash {
+			continue
+		}
+		err = tx.Save(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetConfirmPending 获取等待确认的交易
+func (wm *WalletManager) GetConfirmPending() ([]*ConfirmPendingRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ConfirmPendingRecord
+	err = db.Select(q.Eq("Notified", false)).OrderBy("BlockHeight").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//NewConfirmedExtractData 生成交易达到确认数的通知数据
+func NewConfirmedExtractData(data *openwallet.TxExtractData, confirm int64) *openwallet.TxExtractData {
+	if data == nil || data.Transaction == nil {
+		return data
+	}
+	data.Transaction.TxAction = TxActionConfirmed
+	data.Transaction.Confirm = confirm
+	for _, input := range data.TxInputs {
+		input.Confirm = confirm
+	}
+	for _, output := range data.TxOutputs {
+		output.Confirm = confirm
+	}
+	return data
+}
+
+//checkConfirmations 按已扫描高度检查等待确认的交易，达到要求的确认数时通知观察者，
+//所在区块已被分叉替换的不再跟踪，由分叉回滚通知处理
+func (bs *NEOBlockScanner) checkConfirmations() {
+
+	if !bs.wm.config().ConfirmNotify || bs.isHeadLagDegraded() {
+		return
+	}
+
+	list, err := bs.wm.GetConfirmPending()
+	if err != nil {
+		bs.wm.Log.Std.Error("get confirm pending txs failed. unexpected error: %v", err)
+		return
+	}
+
+	if len(list) == 0 {
+		return
+	}
+
+	tip := bs.GetScannedBlockHeight()
+
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
+	if err != nil {
+		bs.wm.Log.Std.Error("open local db failed. unexpected error: %v", err)
+		return
+	}
+	defer db.Close()
+
+	now := time.Now().Unix()
+	for _, r := range list {
+
+		confirm := confirmationsAt(r.BlockHeight, tip)
+		if confirm < int64(r.Required) {
+			continue
+		}
+
+		var block Block
+		if err := db.One("Height", r.BlockHeight, &block); err == nil && block.Hash != r.BlockHash {
+			bs.wm.Log.Std.Warning("txid: %s block: %s on height: %d is orphaned, stop tracking confirmations", r.TxID, r.BlockHash, r.BlockHeight)
+			db.DeleteStruct(r)
+			continue
+		}
+
+		//入账核对未通过的不发送确认通知
+		if !bs.crossVerifyConfirmPending(r) {
+			db.Save(r)
+			continue
+		}
+
+		data := NewConfirmedExtractData(r.Data, confirm)
+		failed := false
+		for o := range bs.Observers {
+			if !bs.acceptNotify(o, r.SourceKey, data) {
+				continue
+			}
+			if err := bs.notifyObserver(o, r.SourceKey, data); err != nil {
+				bs.wm.Log.Std.Error("txid: %s confirmed notify failed. unexpected error: %v", r.TxID, err)
+				failed = true
+			}
+		}
+		//通知失败的下次扫描重试
+		if failed {
+			continue
+		}
+
+		r.Notified = true
+		r.UpdateAt = now
+		db.Save(r)
+	}
+
+	err = db.Select(q.Eq("Notified", true), q.Lt("UpdateAt", time.Now().Add(-confirmNotifiedRetention).Unix())).Delete(&ConfirmPendingRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		bs.wm.Log.Std.Error("prune confirm notified records failed. unexpected error: %v", err)
+	}
+}
+
+//confirmationsAt 按区块链当前高度计算确认数，未打包或高度超过当前高度时为0
+func confirmationsAt(height, tip uint64) int64 {
+	if height == 0 || height > tip {
+		return 0
+	}
+	return int64(tip - height + 1)
+}
+
+//getTxBlock 查询交易单所在区块，未打包返回空hash
+func (wm *WalletManager) getTxBlock(txid string) (string, uint64, error) {
+
+	trx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(trx.BlockHash) == 0 || trx.BlockHeight > 0 {
+		return trx.BlockHash, trx.BlockHeight, nil
+	}
+
+	//节点返回的交易单只有区块hash时，查区块补全高度
+	block, err := wm.GetBlock(trx.BlockHash, BlockDetailHeader)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return trx.BlockHash, block.Height, nil
+}
+
+//enrichConfirmations 按查询时的区块链高度重新计算确认数，不使用提取时的确认数，
+//hydrate为true时，为提取时未打包但已确认的交易单补全区块hash和高度
+func (wm *WalletManager) enrichConfirmations(list []*openwallet.TxExtractData, hydrate bool) error {
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	tip, err := wm.GetBlockHeight()
+	if err != nil {
+		return err
+	}
+
+	for _, data := range list {
+		tx := data.Transaction
+		if tx == nil {
+			continue
+		}
+
+		if hydrate && tx.BlockHeight == 0 {
+			hash, height, err := wm.getTxBlock(tx.TxID)
+			if err != nil {
+				wm.Log.Std.Warning("hydrate txid: %s block failed, unexpected error: %v", tx.TxID, err)
+			} else if len(hash) > 0 {
+				tx.BlockHash = hash
+				tx.BlockHeight = height
+				for _, input := range data.TxInputs {
+					input.BlockHash = hash
+					input.BlockHeight = height
+				}
+				for _, output := range data.TxOutputs {
+					output.BlockHash = hash
+					output.BlockHeight = height
+				}
+			}
+		}
+
+		tx.Confirm = confirmationsAt(tx.BlockHeight, tip)
+		for _, input := range data.TxInputs {
+			input.Confirm = confirmationsAt(input.BlockHeight, tip)
+		}
+		for _, output := range data.TxOutputs {
+			output.Confirm = confirmationsAt(output.BlockHeight, tip)
+		}
+	}
+
+	return nil
+}
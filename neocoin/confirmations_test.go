@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_EnrichConfirmations(t *testing.T) {
+	var (
+		minedTxID   = testHash("mined")
+		mempoolTxID = testHash("mempool")
+		blockHash   = testHash("block95")
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 101
+		case "getrawtransaction":
+			return map[string]interface{}{"txid": params[0], "blockhash": blockHash}
+		case "getblock":
+			return map[string]interface{}{"index": 95, "hash": blockHash, "previousblockhash": testHash("block94"), "time": 1000, "tx": []interface{}{mempoolTxID}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	newData := func(txid string, height uint64, confirm int64) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid, BlockHeight: height, Confirm: confirm}
+		data.TxOutputs = []*openwallet.TxOutPut{{Recharge: openwallet.Recharge{TxID: txid, BlockHeight: height, Confirm: confirm}}}
+		return data
+	}
+
+	//提取时缓存的确认数不再使用
+	mined := newData(minedTxID, 90, 1)
+	mempool := newData(mempoolTxID, 0, 0)
+	if err := wm.enrichConfirmations([]*openwallet.TxExtractData{mined, mempool}, false); err != nil {
+		t.Errorf("enrichConfirmations failed unexpected error: %v\n", err)
+		return
+	}
+	if mined.Transaction.Confirm != 11 || mined.TxOutputs[0].Confirm != 11 {
+		t.Errorf("unexpected confirmations: %d", mined.Transaction.Confirm)
+	}
+	if mempool.Transaction.Confirm != 0 || mempool.Transaction.BlockHeight != 0 {
+		t.Errorf("mempool record should not be hydrated")
+	}
+
+	wm.enrichConfirmations([]*openwallet.TxExtractData{mempool}, true)
+	if mempool.Transaction.BlockHeight != 95 || mempool.Transaction.BlockHash != blockHash || mempool.Transaction.Confirm != 6 || mempool.TxOutputs[0].BlockHeight != 95 {
+		t.Errorf("unexpected hydrated record: %+v", mempool.Transaction)
+	}
+}
+
+func TestNEOBlockScanner_CheckConfirmations(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.ConfirmNotify = true
+	wm.Config.MinConfirmations["small"] = 3
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//大客户地址要求6个确认
+	wm.SetMinConfirmations("AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT", 6)
+
+	newData := func(txid, address string) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid, BlockHash: testHash("block10"), BlockHeight: 10}
+		output := &openwallet.TxOutPut{}
+		output.Address = address
+		data.TxOutputs = append(data.TxOutputs, output)
+		return data
+	}
+	large := newData("large", "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT")
+	small := newData("small", "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt")
+	if n := wm.RequiredConfirmations("small", large); n != 6 {
+		t.Errorf("address confirmations should override account, got: %d", n)
+	}
+
+	wm.SaveLocalBlock(&Block{Hash: testHash("block10"), Height: 10})
+	wm.SaveConfirmPending(map[string]*openwallet.TxExtractData{"large": large, "small": small})
+
+	observer := &testReplayObserver{}
+	wm.Blockscanner.AddObserver(observer)
+
+	wm.SaveLocalNewBlock(12, testHash("block12"))
+	wm.Blockscanner.checkConfirmations()
+	if len(observer.notified) != 1 || observer.notified[0] != "small:small" || observer.data[0].Transaction.TxAction != TxActionConfirmed || observer.data[0].Transaction.Confirm != 3 {
+		t.Errorf("unexpected confirmed notifications: %v", observer.notified)
+		return
+	}
+
+	wm.SaveLocalNewBlock(15, testHash("block15"))
+	wm.Blockscanner.checkConfirmations()
+	if len(observer.notified) != 2 || observer.notified[1] != "large:large" {
+		t.Errorf("unexpected confirmed notifications: %v", observer.notified)
+	}
+
+	//重扫同一区块不重复发送确认通知
+	wm.SaveConfirmPending(map[string]*openwallet.TxExtractData{"small": newData("small", "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt")})
+	wm.Blockscanner.checkConfirmations()
+	if len(observer.notified) != 2 {
+		t.Errorf("confirmed notification should not repeat: %v", observer.notified)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//scriptHashToAddress 脚本hash转地址，scriptHash为UInt160字符串（大端显示，可带0x前缀）
+func scriptHashToAddress(scriptHash string) string {
+	hash, err := hex.DecodeString(strings.TrimPrefix(scriptHash, "0x"))
+	if err != nil || len(hash) != 20 {
+		return ""
+	}
+	//UInt160显示顺序与字节顺序相反
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return neoTransaction.ScriptHashToAddress(hash)
+}
+
+//resolveContractDestination 输出没有地址但带有脚本hash时，生成脚本hash形式的地址并标记为合约地址
+func resolveContractDestination(output *Vout, json *gjson.Result) {
+
+	if len(output.Addr) > 0 {
+		return
+	}
+
+	for _, key := range []string{"scripthash", "script_hash"} {
+		scriptHash := gjson.Get(json.Raw, key).String()
+		if len(scriptHash) == 0 {
+			continue
+		}
+		if addr := scriptHashToAddress(scriptHash); len(addr) > 0 {
+			output.Addr = addr
+			output.IsContract = true
+			return
+		}
+	}
+}
+
+//attachContractDestinations 把交易输出中的合约地址记录到交易单扩展参数
+func (bs *NEOBlockScanner) attachContractDestinations(tx *openwallet.Transaction, trx *Transaction) {
+
+	contractTo := make([]string, 0)
+	for _, output := range trx.Vouts {
+		if output.IsContract {
+			contractTo = append(contractTo, output.Addr)
+		}
+	}
+
+	if len(contractTo) == 0 {
+		return
+	}
+
+	tx.SetExtParam("contractTo", contractTo)
+}
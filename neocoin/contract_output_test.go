@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestNEOBlockScanner_ExtractContractDestination(t *testing.T) {
+	wm := NewWalletManager()
+
+	json := gjson.Parse(`{"n":1,"asset":"0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b","value":"5","scripthash":"0xe9eed8dc39332032dc22e5d6e86332c50327ba23"}`)
+	contractOut := NewVout(&json)
+	if contractOut.Addr != "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y" || !contractOut.IsContract {
+		t.Errorf("contract vout: %+v", contractOut)
+	}
+
+	json = gjson.Parse(`{"n":0,"value":"100","address":"AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}`)
+	normalOut := NewVout(&json)
+	if normalOut.IsContract {
+		t.Errorf("normal vout should not be marked as contract")
+	}
+
+	trx := &Transaction{
+		TxID:  "0x28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d",
+		Vins:  make([]*Vin, 0),
+		Vouts: []*Vout{normalOut, contractOut},
+	}
+
+	result := ExtractResult{
+		TxID:        trx.TxID,
+		extractData: make(map[string]*openwallet.TxExtractData),
+	}
+
+	to, total := wm.Blockscanner.extractTxOutput(trx, &result, func(address string) (string, bool) {
+		return "contract", address == contractOut.Addr
+	})
+
+	if len(to) != 2 || to[1] != "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y:5" || total.String() != "105" {
+		t.Errorf("extractTxOutput to: %v, total: %s", to, total.String())
+	}
+
+	outputs := result.extractData["contract"].TxOutputs
+	if len(outputs) != 1 || gjson.Get(outputs[0].ExtParam, "contractDestination").Bool() != true {
+		t.Errorf("contract output should be flagged: %+v", outputs)
+	}
+
+	tx := &openwallet.Transaction{To: to}
+	wm.Blockscanner.attachContractDestinations(tx, trx)
+	if gjson.Get(tx.ExtParam, "contractTo.0").String() != contractOut.Addr {
+		t.Errorf("unexpected ext param: %s", tx.ExtParam)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//SigningHash 一个签名地址需要签名的交易哈希
+type SigningHash struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"publicKey,omitempty"` //地址的压缩公钥，未知时为空
+	HDPath    string `json:"hdPath,omitempty"`
+	Hash      string `json:"hash"` //hex编码的签名摘要，对摘要直接做secp256r1签名
+}
+
+//SigningRequest 交给外部签名服务的待签名数据，哈希按见证人顺序排列
+type SigningRequest struct {
+	Symbol    string         `json:"symbol"`
+	AccountID string         `json:"accountID"`
+	TxID      string         `json:"txid"`
+	Hashes    []*SigningHash `json:"hashes"`
+}
+
+//SignedHash 外部签名服务回传的签名
+type SignedHash struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"publicKey"` //压缩公钥
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"` //hex编码的64字节r||s
+}
+
+//GetSigningRequest 获取已创建交易单需要签名的地址和哈希，用于不使用openwallet秘钥存储的外部签名服务
+func (decoder *TransactionDecoder) GetSigningRequest(rawTx *openwallet.RawTransaction) (*SigningRequest, error) {
+
+	if rawTx == nil || !rawTx.IsBuilt || len(rawTx.RawHex) == 0 {
+		return nil, fmt.Errorf("transaction is not built")
+	}
+
+	if rawTx.Account == nil {
+		return nil, fmt.Errorf("transaction account is empty")
+	}
+
+	keySignatures := rawTx.Signatures[rawTx.Account.AccountID]
+	if len(keySignatures) == 0 {
+		return nil, fmt.Errorf("transaction signature is empty")
+	}
+
+	addresses := make([]string, 0, len(keySignatures))
+	byAddress := make(map[string]*openwallet.KeySignature)
+	for _, keySignature := range keySignatures {
+		if keySignature.Address == nil {
+			return nil, fmt.Errorf("transaction signature address is empty")
+		}
+		addresses = append(addresses, keySignature.Address.Address)
+		byAddress[keySignature.Address.Address] = keySignature
+	}
+
+	//重新计算哈希，不信任交易单中保存的哈希
+	txHashes, err := neoTransaction.CreateRawTransactionHashForSigByAddresses(rawTx.RawHex, addresses)
+	if err != nil {
+		return nil, fmt.Errorf("create transaction hash for sig failed, unexpected error: %v", err)
+	}
+
+	txid, err := neoTransaction.CalcTxID(rawTx.RawHex)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &SigningRequest{
+		Symbol:    decoder.wm.Symbol(),
+		AccountID: rawTx.Account.AccountID,
+		TxID:      txid,
+		Hashes:    make([]*SigningHash, 0, len(txHashes)),
+	}
+	for _, txHash := range txHashes {
+		address := byAddress[txHash.GetNormalTxAddress()].Address
+		req.Hashes = append(req.Hashes, &SigningHash{
+			Address:   address.Address,
+			PublicKey: address.PublicKey,
+			HDPath:    address.HDPath,
+			Hash:      txHash.GetTxHashHex(),
+		})
+	}
+
+	return req, nil
+}
+
+//ExportSigningRequest 导出JSON格式的待签名数据
+func (decoder *TransactionDecoder) ExportSigningRequest(rawTx *openwallet.RawTransaction) ([]byte, error) {
+	req, err := decoder.GetSigningRequest(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(req)
+}
+
+//ImportSignatures 导入外部签名服务回传的签名，校验哈希、公钥和签名后填入交易单，
+//之后调用VerifyRawTransaction合并签名
+func (decoder *TransactionDecoder) ImportSignatures(rawTx *openwallet.RawTransaction, signed []*SignedHash) error {
+
+	req, err := decoder.GetSigningRequest(rawTx)
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[string]string)
+	for _, h := range req.Hashes {
+		hashes[h.Address] = h.Hash
+	}
+
+	imported := make(map[string]*SignedHash)
+	for _, s := range signed {
+		hash, ok := hashes[s.Address]
+		if !ok {
+			return fmt.Errorf("address: %s is not a signer of the transaction", s.Address)
+		}
+		if s.Hash != hash {
+			return fmt.Errorf("hash: %s of address: %s is not equal to transaction hash: %s", s.Hash, s.Address, hash)
+		}
+
+		digest, _ := hex.DecodeString(hash)
+		pubkey, err := hex.DecodeString(s.PublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key of address: %s", s.Address)
+		}
+		signature, err := hex.DecodeString(s.Signature)
+		if err != nil {
+			return fmt.Errorf("invalid signature of address: %s", s.Address)
+		}
+		signature, err = neoTransaction.NormalizeLowS(signature)
+		if err != nil {
+			return fmt.Errorf("invalid signature of address: %s", s.Address)
+		}
+
+		if _, address, err := neoTransaction.CreateSignatureRedeemScript(pubkey); err != nil || address != s.Address {
+			return fmt.Errorf("public key: %s does not belong to address: %s", s.PublicKey, s.Address)
+		}
+
+		if !neoTransaction.VerifySignature(digest, pubkey, signature) {
+			return fmt.Errorf("signature of address: %s verify failed", s.Address)
+		}
+
+		imported[s.Address] = &SignedHash{
+			Address:   s.Address,
+			PublicKey: hex.EncodeToString(pubkey),
+			Hash:      hash,
+			Signature: hex.EncodeToString(signature),
+		}
+	}
+
+	//全部校验通过后再填入，部分签名允许分批导入
+	for _, keySignature := range rawTx.Signatures[rawTx.Account.AccountID] {
+		s, ok := imported[keySignature.Address.Address]
+		if !ok {
+			continue
+		}
+		keySignature.Message = s.Hash
+		keySignature.Signature = s.Signature
+		keySignature.Address.PublicKey = s.PublicKey
+	}
+
+	return nil
+}
+
+//ImportSignaturesJSON 导入JSON格式的回传签名，格式为SignedHash数组
+func (decoder *TransactionDecoder) ImportSignaturesJSON(rawTx *openwallet.RawTransaction, data []byte) error {
+	var signed []*SignedHash
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("invalid signatures json, unexpected error: %v", err)
+	}
+	return decoder.ImportSignatures(rawTx, signed)
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/hdkeystore"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestTransactionDecoder_ExternalCoSigning(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.MaxTxInputs = 10
+	decoder := NewTransactionDecoder(wm)
+
+	//外部签名服务持有私钥，钱包只有地址
+	key, _ := hdkeystore.NewHDKey(bytes.Repeat([]byte{9}, 32), "external", "m/44'/888'")
+	wallet := &testSignWallet{addresses: make(map[string]*openwallet.Address)}
+	prikeys := make(map[string][]byte)
+	usedUtxos := make([]*UnspentBalance, 0)
+	for i := 0; i < 2; i++ {
+		child, _ := key.DerivedKeyWithPath(fmt.Sprintf("m/44'/888'/0'/0/%d", i), wm.Config.CurveType)
+		_, address, _ := neoTransaction.CreateSignatureRedeemScript(child.GetPublicKeyBytes())
+		prikeys[address], _ = child.GetPrivateKeyBytes()
+		wallet.addresses[address] = &openwallet.Address{AccountID: "account", Address: address}
+		usedUtxos = append(usedUtxos, &UnspentBalance{
+			Address:    address,
+			NEOUnspent: &Unspent{UnspentTxs: &[]UnspentTx{{TxID: testHash(address)[2:], N: 0, Value: "1"}}, Amount: "1"},
+		})
+	}
+
+	rawTx := &openwallet.RawTransaction{Account: &openwallet.AssetsAccount{AccountID: "account"}, Fees: "0"}
+	to := map[string]decimal.Decimal{"AGofsxAUDwt52KjaB664GYsqVAkULYvKNt": decimal.New(2, 0)}
+	if err := decoder.createNEORawTransaction(wallet, rawTx, usedUtxos, to); err != nil {
+		t.Errorf("createNEORawTransaction failed unexpected error: %v\n", err)
+		return
+	}
+
+	data, err := decoder.ExportSigningRequest(rawTx)
+	if err != nil {
+		t.Errorf("ExportSigningRequest failed unexpected error: %v\n", err)
+		return
+	}
+	var req SigningRequest
+	json.Unmarshal(data, &req)
+	if len(req.Hashes) != 2 || req.AccountID != "account" || len(req.TxID) == 0 {
+		t.Errorf("unexpected signing request: %s", data)
+		return
+	}
+
+	sign := func(h *SigningHash) *SignedHash {
+		digest, _ := hex.DecodeString(h.Hash)
+		sig, _ := owcrypt.Signature(prikeys[h.Address], nil, 0, digest, 32, owcrypt.ECC_CURVE_SECP256R1)
+		pub, _ := owcrypt.GenPubkey(prikeys[h.Address], owcrypt.ECC_CURVE_SECP256R1)
+		return &SignedHash{
+			Address:   h.Address,
+			PublicKey: hex.EncodeToString(owcrypt.PointCompress(pub, owcrypt.ECC_CURVE_SECP256R1)),
+			Hash:      h.Hash,
+			Signature: hex.EncodeToString(sig),
+		}
+	}
+
+	//签名与地址不对应时拒绝导入
+	wrong := sign(req.Hashes[0])
+	wrong.Address = req.Hashes[1].Address
+	if err := decoder.ImportSignatures(rawTx, []*SignedHash{wrong}); err == nil {
+		t.Errorf("signature of another address should be rejected")
+	}
+
+	//分批导入签名
+	for _, h := range req.Hashes {
+		signed, _ := json.Marshal([]*SignedHash{sign(h)})
+		if err := decoder.ImportSignaturesJSON(rawTx, signed); err != nil {
+			t.Errorf("ImportSignaturesJSON failed unexpected error: %v\n", err)
+			return
+		}
+	}
+
+	if err := decoder.VerifyNEORawTransaction(wallet, rawTx); err != nil || !rawTx.IsCompleted {
+		t.Errorf("VerifyNEORawTransaction failed, completed: %v, unexpected error: %v", rawTx.IsCompleted, err)
+	}
+}
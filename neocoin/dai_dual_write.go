@@ -0,0 +1,333 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	AlertTypeDAIMismatch = "dai_mismatch" //双写期间新旧区块链数据接口读取结果不一致
+	AlertTypeDAICutover  = "dai_cutover"  //双写期满，验证通过后切换到新区块链数据接口
+
+	//daiMismatchKeep 保留的最近不一致记录数量
+	daiMismatchKeep = 100
+)
+
+//DAIMismatch 新旧接口读取结果不一致的记录
+type DAIMismatch struct {
+	Method    string
+	Args      string
+	Primary   string
+	Secondary string
+	CreateAt  int64
+}
+
+//DualWriteReport 双写验证统计
+type DualWriteReport struct {
+	StartAt         int64
+	Until           int64
+	Writes          int           //写入次数
+	SecondaryFailed int           //新接口写入失败次数
+	Reads           int           //比较读取次数
+	Mismatches      int           //读取结果不一致次数，包括新接口读取失败
+	Recent          []DAIMismatch //最近的不一致记录
+	CutoverAt       int64         //切换到新接口的时间，0表示未切换
+}
+
+//DualWriteDAI 迁移区块链数据接口时使用，期间写入同时写到旧接口和新接口，读取以旧接口为准并与新接口比较，
+//期满后如果新接口没有写入失败和读取不一致，则切换为只使用新接口，否则继续双写并告警，由运维决定是否切换
+type DualWriteDAI struct {
+	Primary   openwallet.BlockchainDAI //旧接口
+	Secondary openwallet.BlockchainDAI //新接口
+
+	bs         *NEOBlockScanner
+	mu         sync.Mutex
+	report     DualWriteReport
+	alerted    bool //已发送不一致告警，期满前不重复发送
+	expireDone bool //已处理期满
+}
+
+//SetDualWriteBlockchainDAI 使用当前的区块链数据接口作为旧接口，开启与新接口的双写验证，period为0时使用配置的双写期限
+func (bs *NEOBlockScanner) SetDualWriteBlockchainDAI(secondary openwallet.BlockchainDAI, period time.Duration) (*DualWriteDAI, error) {
+
+	if bs.BlockchainDAI == nil {
+		return nil, fmt.Errorf("Blockchain DAI is not setup ")
+	}
+
+	if secondary == nil {
+		return nil, fmt.Errorf("secondary Blockchain DAI is nil")
+	}
+
+	if period <= 0 {
+		period = bs.wm.config().DAIDualWritePeriod
+	}
+
+	now := time.Now()
+	dai := &DualWriteDAI{
+		Primary:   bs.BlockchainDAI,
+		Secondary: secondary,
+		bs:        bs,
+	}
+	dai.report.StartAt = now.Unix()
+	dai.report.Until = now.Add(period).Unix()
+
+	bs.SetBlockchainDAI(dai)
+
+	bs.wm.Log.Std.Notice("block chain dai dual write started, validate until: %s", now.Add(period).Format(time.RFC3339))
+
+	return dai, nil
+}
+
+//Report 双写验证统计
+func (dai *DualWriteDAI) Report() DualWriteReport {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+
+	report := dai.report
+	report.Recent = append([]DAIMismatch(nil), dai.report.Recent...)
+	return report
+}
+
+//active 返回当前使用的接口，期满时按验证结果决定是否切换，dual为true表示仍在双写
+func (dai *DualWriteDAI) active() (primary openwallet.BlockchainDAI, dual bool) {
+	dai.mu.Lock()
+
+	if dai.report.CutoverAt > 0 {
+		dai.mu.Unlock()
+		return dai.Secondary, false
+	}
+
+	if dai.expireDone || time.Now().Unix() < dai.report.Until {
+		dai.mu.Unlock()
+		return dai.Primary, true
+	}
+
+	dai.expireDone = true
+	clean := dai.report.SecondaryFailed == 0 && dai.report.Mismatches == 0
+	if clean {
+		dai.report.CutoverAt = time.Now().Unix()
+	}
+	report := dai.report
+	dai.mu.Unlock()
+
+	var alert *Alert
+	if clean {
+		alert = NewAlert(dai.bs.wm.Symbol(), AlertTypeDAICutover, 0,
+			fmt.Sprintf("dual write validated %d writes and %d reads without mismatch, cut over to new block chain dai", report.Writes, report.Reads))
+	} else {
+		alert = NewAlert(dai.bs.wm.Symbol(), AlertTypeDAIMismatch, 0,
+			fmt.Sprintf("dual write period expired with %d secondary write failures and %d read mismatches, keep dual write until operator cuts over", report.SecondaryFailed, report.Mismatches))
+	}
+	alert.Details["writes"] = fmt.Sprintf("%d", report.Writes)
+	alert.Details["reads"] = fmt.Sprintf("%d", report.Reads)
+	dai.bs.newAlertNotify(alert)
+
+	if clean {
+		return dai.Secondary, false
+	}
+	return dai.Primary, true
+}
+
+//Cutover 立即切换到新接口，不论验证结果
+func (dai *DualWriteDAI) Cutover() {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	if dai.report.CutoverAt == 0 {
+		dai.report.CutoverAt = time.Now().Unix()
+	}
+}
+
+//write 写入旧接口成功后写入新接口，新接口失败只记录，不影响扫描
+func (dai *DualWriteDAI) write(method string, fn func(openwallet.BlockchainDAI) error) error {
+
+	primary, dual := dai.active()
+	err := fn(primary)
+	if err != nil || !dual {
+		return err
+	}
+
+	secondaryErr := fn(dai.Secondary)
+
+	dai.mu.Lock()
+	dai.report.Writes++
+	if secondaryErr != nil {
+		dai.report.SecondaryFailed++
+	}
+	dai.mu.Unlock()
+
+	if secondaryErr != nil {
+		dai.bs.wm.Log.Std.Warning("dual write %s to secondary block chain dai failed, unexpected error: %v", method, secondaryErr)
+	}
+
+	return nil
+}
+
+//compare 记录一次读取比较的结果
+func (dai *DualWriteDAI) compare(method, args, primary, secondary string) {
+
+	dai.mu.Lock()
+	dai.report.Reads++
+	if primary == secondary {
+		dai.mu.Unlock()
+		return
+	}
+
+	dai.report.Mismatches++
+	dai.report.Recent = append(dai.report.Recent, DAIMismatch{
+		Method:    method,
+		Args:      args,
+		Primary:   primary,
+		Secondary: secondary,
+		CreateAt:  time.Now().Unix(),
+	})
+	if len(dai.report.Recent) > daiMismatchKeep {
+		dai.report.Recent = dai.report.Recent[len(dai.report.Recent)-daiMismatchKeep:]
+	}
+	alerted := dai.alerted
+	dai.alerted = true
+	dai.mu.Unlock()
+
+	dai.bs.wm.Log.Std.Warning("dual write %s(%s) mismatch, primary: %s, secondary: %s", method, args, primary, secondary)
+
+	if !alerted {
+		alert := NewAlert(dai.bs.wm.Symbol(), AlertTypeDAIMismatch, 0, fmt.Sprintf("secondary block chain dai %s(%s) returns different result", method, args))
+		alert.Details["primary"] = primary
+		alert.Details["secondary"] = secondary
+		dai.bs.newAlertNotify(alert)
+	}
+}
+
+//daiHeaderString 比较用的区块头摘要
+func daiHeaderString(header *openwallet.BlockHeader, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if header == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%d:%s", header.Height, header.Hash)
+}
+
+//daiUnscanString 比较用的未扫记录摘要，与顺序无关
+func daiUnscanString(list []*openwallet.UnscanRecord, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	keys := make([]string, 0, len(list))
+	for _, r := range list {
+		keys = append(keys, fmt.Sprintf("%d:%s", r.BlockHeight, r.TxID))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+//daiTxString 比较用的交易记录摘要，与顺序无关
+func daiTxString(list []*openwallet.Transaction, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	keys := make([]string, 0, len(list))
+	for _, tx := range list {
+		keys = append(keys, fmt.Sprintf("%s:%s:%s", tx.TxID, tx.AccountID, tx.Amount))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func (dai *DualWriteDAI) SaveCurrentBlockHead(header *openwallet.BlockHeader) error {
+	return dai.write("SaveCurrentBlockHead", func(d openwallet.BlockchainDAI) error {
+		return d.SaveCurrentBlockHead(header)
+	})
+}
+
+func (dai *DualWriteDAI) GetCurrentBlockHead(symbol string) (*openwallet.BlockHeader, error) {
+	primary, dual := dai.active()
+	header, err := primary.GetCurrentBlockHead(symbol)
+	if dual {
+		secondary, secondaryErr := dai.Secondary.GetCurrentBlockHead(symbol)
+		dai.compare("GetCurrentBlockHead", symbol, daiHeaderString(header, err), daiHeaderString(secondary, secondaryErr))
+	}
+	return header, err
+}
+
+func (dai *DualWriteDAI) SaveLocalBlockHead(header *openwallet.BlockHeader) error {
+	return dai.write("SaveLocalBlockHead", func(d openwallet.BlockchainDAI) error {
+		return d.SaveLocalBlockHead(header)
+	})
+}
+
+func (dai *DualWriteDAI) GetLocalBlockHeadByHeight(height uint64, symbol string) (*openwallet.BlockHeader, error) {
+	primary, dual := dai.active()
+	header, err := primary.GetLocalBlockHeadByHeight(height, symbol)
+	if dual {
+		secondary, secondaryErr := dai.Secondary.GetLocalBlockHeadByHeight(height, symbol)
+		dai.compare("GetLocalBlockHeadByHeight", fmt.Sprintf("%d, %s", height, symbol), daiHeaderString(header, err), daiHeaderString(secondary, secondaryErr))
+	}
+	return header, err
+}
+
+func (dai *DualWriteDAI) SaveUnscanRecord(record *openwallet.UnscanRecord) error {
+	return dai.write("SaveUnscanRecord", func(d openwallet.BlockchainDAI) error {
+		return d.SaveUnscanRecord(record)
+	})
+}
+
+func (dai *DualWriteDAI) DeleteUnscanRecordByHeight(height uint64, symbol string) error {
+	return dai.write("DeleteUnscanRecordByHeight", func(d openwallet.BlockchainDAI) error {
+		return d.DeleteUnscanRecordByHeight(height, symbol)
+	})
+}
+
+func (dai *DualWriteDAI) DeleteUnscanRecordByID(id string, symbol string) error {
+	return dai.write("DeleteUnscanRecordByID", func(d openwallet.BlockchainDAI) error {
+		return d.DeleteUnscanRecordByID(id, symbol)
+	})
+}
+
+func (dai *DualWriteDAI) GetTransactionsByTxID(txid, symbol string) ([]*openwallet.Transaction, error) {
+	primary, dual := dai.active()
+	list, err := primary.GetTransactionsByTxID(txid, symbol)
+	if dual {
+		secondary, secondaryErr := dai.Secondary.GetTransactionsByTxID(txid, symbol)
+		dai.compare("GetTransactionsByTxID", fmt.Sprintf("%s, %s", txid, symbol), daiTxString(list, err), daiTxString(secondary, secondaryErr))
+	}
+	return list, err
+}
+
+func (dai *DualWriteDAI) GetUnscanRecords(symbol string) ([]*openwallet.UnscanRecord, error) {
+	primary, dual := dai.active()
+	list, err := primary.GetUnscanRecords(symbol)
+	if dual {
+		secondary, secondaryErr := dai.Secondary.GetUnscanRecords(symbol)
+		dai.compare("GetUnscanRecords", symbol, daiUnscanString(list, err), daiUnscanString(secondary, secondaryErr))
+	}
+	return list, err
+}
+
+func (dai *DualWriteDAI) SetMaxBlockCache(max uint64, symbol string) error {
+	return dai.write("SetMaxBlockCache", func(d openwallet.BlockchainDAI) error {
+		return d.SetMaxBlockCache(max, symbol)
+	})
+}
+
+var _ openwallet.BlockchainDAI = (*DualWriteDAI)(nil)
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_DualWriteDAI(t *testing.T) {
+	wm := NewWalletManager()
+	bs := wm.Blockscanner
+	alerts := &testAlertObserver{}
+	bs.AddAlertObserver(alerts)
+
+	primary, secondary := newTestBlockchainDAI(), newTestBlockchainDAI()
+	bs.SetBlockchainDAI(primary)
+	dai, err := bs.SetDualWriteBlockchainDAI(secondary, time.Hour)
+	if err != nil {
+		t.Errorf("SetDualWriteBlockchainDAI failed unexpected error: %v\n", err)
+		return
+	}
+
+	bs.SaveLocalNewBlock(10, "0x10")
+	if secondary.current == nil || secondary.current.Hash != "0x10" {
+		t.Errorf("secondary dai should be written")
+	}
+	if height, _, _ := bs.GetLocalNewBlock(); height != 10 {
+		t.Errorf("GetLocalNewBlock height: %d, expected: 10", height)
+	}
+
+	//新接口数据不一致，只告警一次，读取仍以旧接口为准
+	secondary.current = &openwallet.BlockHeader{Height: 9, Hash: "0x09"}
+	bs.GetLocalNewBlock()
+	height, _, _ := bs.GetLocalNewBlock()
+	report := dai.Report()
+	if height != 10 || report.Writes != 1 || report.Reads != 3 || report.Mismatches != 2 || len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeDAIMismatch {
+		t.Errorf("unexpected report: %+v, alerts: %d", report, len(alerts.alerts))
+	}
+
+	//期满验证未通过，继续双写
+	dai.report.Until = time.Now().Add(-time.Second).Unix()
+	bs.SaveLocalNewBlock(11, "0x11")
+	if dai.Report().CutoverAt != 0 || secondary.current.Height != 11 || len(alerts.alerts) != 2 {
+		t.Errorf("dual write should continue after failed validation")
+	}
+}
+
+func TestNEOBlockScanner_DualWriteDAICutover(t *testing.T) {
+	wm := NewWalletManager()
+	bs := wm.Blockscanner
+	alerts := &testAlertObserver{}
+	bs.AddAlertObserver(alerts)
+
+	primary, secondary := newTestBlockchainDAI(), newTestBlockchainDAI()
+	bs.SetBlockchainDAI(primary)
+	dai, _ := bs.SetDualWriteBlockchainDAI(secondary, time.Hour)
+
+	bs.SaveLocalNewBlock(10, "0x10")
+	bs.GetLocalNewBlock()
+
+	//期满验证通过，切换到新接口
+	dai.report.Until = time.Now().Add(-time.Second).Unix()
+	bs.SaveLocalNewBlock(11, "0x11")
+	if dai.Report().CutoverAt == 0 || len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeDAICutover {
+		t.Errorf("dual write should cut over after validation, report: %+v", dai.Report())
+		return
+	}
+	if primary.current.Height != 10 || secondary.current.Height != 11 {
+		t.Errorf("only secondary dai should be written after cut over")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+
+	"github.com/asdine/storm"
+)
+
+const (
+	//SchemaVersion 当前本地数据结构版本，数据结构变化时递增并添加迁移
+	SchemaVersion = 1
+
+	schemaBucket     = "schema" //数据结构版本集合
+	schemaVersionKey = "version"
+)
+
+//dbMigration 本地数据迁移，把数据从Version-1升级到Version
+type dbMigration struct {
+	Version int
+	Desc    string
+	Migrate func(tx storm.Node) error
+}
+
+//dbMigrations 按版本顺序执行的迁移列表
+var dbMigrations = []dbMigration{
+	{
+		Version: 1,
+		Desc:    "set schema version of blocks and extract data records",
+		Migrate: migrateSchemaVersionV1,
+	},
+}
+
+//migrateSchemaVersionV1 为已有的区块和提取结果记录写入版本号
+func migrateSchemaVersionV1(tx storm.Node) error {
+
+	var blocks []*Block
+	err := tx.All(&blocks)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	for _, b := range blocks {
+		b.SchemaVersion = 1
+		if err = tx.Save(b); err != nil {
+			return err
+		}
+	}
+
+	var records []*ExtractDataRecord
+	err = tx.All(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	for _, r := range records {
+		r.SchemaVersion = 1
+		if err = tx.Save(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//GetLocalSchemaVersion 获取本地数据结构版本，未记录时为0
+func (wm *WalletManager) GetLocalSchemaVersion() (int, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	version := 0
+	err = db.Get(schemaBucket, schemaVersionKey, &version)
+	if err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+//MigrateLocalDB 把本地数据升级到当前数据结构版本，每个迁移在独立事务中执行，
+//本地数据版本比适配器更新时返回错误，避免旧版本读取新数据结构
+func (wm *WalletManager) MigrateLocalDB() error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "open local db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	version := 0
+	err = db.Get(schemaBucket, schemaVersionKey, &version)
+	if err != nil && err != storm.ErrNotFound {
+		return wm.errorf(ErrLocalDBOperateFailed, "get schema version failed, unexpected error: %v", err)
+	}
+
+	if version > SchemaVersion {
+		return wm.errorf(ErrLocalDBSchemaUnsupported, "local db schema version: %d is newer than adapter: %d", version, SchemaVersion)
+	}
+
+	for _, m := range dbMigrations {
+		if m.Version <= version {
+			continue
+		}
+
+		tx, err := db.Begin(true)
+		if err != nil {
+			return wm.errorf(ErrLocalDBOperateFailed, "begin migration failed, unexpected error: %v", err)
+		}
+
+		err = m.Migrate(tx)
+		if err == nil {
+			err = tx.Set(schemaBucket, schemaVersionKey, m.Version)
+		}
+		if err != nil {
+			tx.Rollback()
+			return wm.errorf(ErrLocalDBOperateFailed, "migrate local db to version: %d failed, unexpected error: %v", m.Version, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return wm.errorf(ErrLocalDBOperateFailed, "commit migration failed, unexpected error: %v", err)
+		}
+
+		wm.Log.Std.Info("local db migrated to schema version: %d, %s", m.Version, m.Desc)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm"
+)
+
+func TestWalletManager_MigrateLocalDB(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//旧版本保存的区块没有版本号
+	db, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	if err != nil {
+		t.Errorf("open db failed unexpected error: %v\n", err)
+		return
+	}
+	db.Save(&Block{Height: 10, Hash: "0x10"})
+	db.Close()
+
+	version, _ := wm.GetLocalSchemaVersion()
+	if version != 0 {
+		t.Errorf("schema version should be 0, got %d", version)
+	}
+
+	err = wm.MigrateLocalDB()
+	if err != nil {
+		t.Errorf("MigrateLocalDB failed unexpected error: %v\n", err)
+		return
+	}
+
+	version, _ = wm.GetLocalSchemaVersion()
+	if version != SchemaVersion {
+		t.Errorf("schema version should be %d, got %d", SchemaVersion, version)
+	}
+
+	block, err := wm.GetLocalBlock(10)
+	if err != nil || block.SchemaVersion != 1 {
+		t.Errorf("block should be migrated, block: %+v, err: %v", block, err)
+	}
+
+	//本地数据比适配器新
+	db, _ = storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	db.Set(schemaBucket, schemaVersionKey, SchemaVersion+1)
+	db.Save(&Block{Height: 11, Hash: "0x11", SchemaVersion: SchemaVersion + 1})
+	db.Close()
+
+	if err = wm.MigrateLocalDB(); err == nil {
+		t.Errorf("newer schema version should be rejected")
+	}
+	if _, err = wm.GetLocalBlock(11); err == nil {
+		t.Errorf("newer block schema should be rejected")
+	}
+}
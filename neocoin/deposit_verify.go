@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"errors"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AlertTypeDepositMismatch = "deposit_mismatch" //入账与浏览器或备用节点的数据不一致
+)
+
+//getTransactionByClient 通过指定节点获取交易单
+func getTransactionByClient(client ClientInterface, txid string) (*Transaction, error) {
+
+	result, err := client.Call("getrawtransaction", []interface{}{txid, 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateTransactionResult(result); err != nil {
+		return nil, err
+	}
+
+	return NewTransaction(result), nil
+}
+
+//crossVerifyTransaction 从核对来源获取交易单，优先使用浏览器，其次第一个备用节点
+func (wm *WalletManager) crossVerifyTransaction(txid string) (string, *Transaction, error) {
+
+	if wm.explorerClient() != nil {
+		trx, err := wm.getTransactionByExplorer(txid)
+		return "explorer", trx, err
+	}
+
+	if backups := wm.backupClients(); len(backups) > 0 {
+		trx, err := getTransactionByClient(backups[0], txid)
+		return clientName(backups[0], 1), trx, err
+	}
+
+	return "", nil, errors.New("no explorer or backup node to cross verify deposit")
+}
+
+//needCrossVerify 核心节点模式下，观测地址入账金额达到阈值时需要核对
+func (wm *WalletManager) needCrossVerify(data *openwallet.TxExtractData) bool {
+
+	cfg := wm.config()
+	if !cfg.DepositCrossVerify || cfg.RPCServerType != RPCServerCore || data == nil || data.Transaction == nil {
+		return false
+	}
+
+	for _, output := range data.TxOutputs {
+		amount, err := decimal.NewFromString(output.Amount)
+		if err != nil || amount.GreaterThanOrEqual(cfg.DepositCrossVerifyThreshold) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//CrossVerifyDeposit 通过浏览器或备用节点核对入账的区块和输出，不一致时返回ErrDepositMismatch错误
+func (wm *WalletManager) CrossVerifyDeposit(data *openwallet.TxExtractData) error {
+
+	if data == nil || data.Transaction == nil {
+		return nil
+	}
+
+	txid := data.Transaction.TxID
+	source, trx, err := wm.crossVerifyTransaction(txid)
+	if err != nil {
+		return err
+	}
+	if trx == nil || len(trx.TxID) == 0 {
+		return wm.errorf(ErrDepositMismatch, "%s can not find txid: %s", source, txid)
+	}
+
+	//核对来源返回了区块hash时须一致
+	if len(trx.BlockHash) > 0 && trx.BlockHash != data.Transaction.BlockHash {
+		return wm.errorf(ErrDepositMismatch, "%s txid: %s block hash: %s, expected: %s", source, txid, trx.BlockHash, data.Transaction.BlockHash)
+	}
+
+	vouts := make(map[uint64]*Vout)
+	for _, output := range trx.Vouts {
+		vouts[output.N] = output
+	}
+	for _, output := range data.TxOutputs {
+		vout, ok := vouts[output.Index]
+		if !ok {
+			return wm.errorf(ErrDepositMismatch, "%s txid: %s has no output: %d", source, txid, output.Index)
+		}
+		expected, _ := decimal.NewFromString(output.Amount)
+		amount, _ := decimal.NewFromString(vout.Value)
+		if vout.Addr != output.Address || !amount.Equal(expected) {
+			return wm.errorf(ErrDepositMismatch, "%s txid: %s output: %d is %s %s, expected: %s %s", source, txid, output.Index, vout.Addr, vout.Value, output.Address, output.Amount)
+		}
+	}
+
+	return nil
+}
+
+//crossVerifyConfirmPending 确认通知前核对入账，核对来源不可用时下次重试，
+//数据不一致时首次发出告警，不发送确认通知，下次扫描继续核对
+func (bs *NEOBlockScanner) crossVerifyConfirmPending(r *ConfirmPendingRecord) bool {
+
+	if !bs.wm.needCrossVerify(r.Data) {
+		return true
+	}
+
+	err := bs.wm.CrossVerifyDeposit(r.Data)
+	if err == nil {
+		return true
+	}
+
+	if openwallet.ConvertError(err).Code() != ErrDepositMismatch {
+		bs.wm.Log.Std.Warning("txid: %s cross verify deposit failed, retry later; unexpected error: %v", r.TxID, err)
+		return false
+	}
+
+	if !r.Mismatch {
+		r.Mismatch = true
+		alert := NewAlert(bs.wm.Symbol(), AlertTypeDepositMismatch, r.BlockHeight, err.Error())
+		alert.Details["txid"] = r.TxID
+		alert.Details["sourceKey"] = r.SourceKey
+		bs.newAlertNotify(alert)
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestNEOBlockScanner_CrossVerifyDeposit(t *testing.T) {
+	const address = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+	blockHash := testHash("block10")
+	matched, large := testHash("matched"), testHash("large")
+
+	//备用节点上large交易的金额与主节点不一致
+	backup := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getrawtransaction" {
+			return nil
+		}
+		value := "100"
+		if params[0] == large {
+			value = "99"
+		}
+		return map[string]interface{}{"txid": params[0], "blockhash": blockHash,
+			"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0xc56f", "value": value, "address": address}}}
+	})
+	defer backup.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.ConfirmNotify = true
+	wm.Config.ConfirmBlocks = 1
+	wm.Config.DepositCrossVerify = true
+	wm.Config.DepositCrossVerifyThreshold = decimal.New(50, 0)
+	wm.BackupClients = []*Client{NewClient(backup.URL, "", false)}
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	newData := func(txid, amount string) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid, BlockHash: blockHash, BlockHeight: 10}
+		output := &openwallet.TxOutPut{}
+		output.TxID = txid
+		output.Address = address
+		output.Amount = amount
+		data.TxOutputs = append(data.TxOutputs, output)
+		return data
+	}
+
+	if err := wm.CrossVerifyDeposit(newData(matched, "100")); err != nil {
+		t.Errorf("CrossVerifyDeposit failed unexpected error: %v\n", err)
+	}
+	if err := wm.CrossVerifyDeposit(newData(large, "100")); openwallet.ConvertError(err).Code() != ErrDepositMismatch {
+		t.Errorf("mismatched deposit should be rejected, err: %v", err)
+	}
+	//低于阈值不核对
+	if wm.needCrossVerify(newData(testHash("small"), "10")) {
+		t.Errorf("deposit below threshold should not be verified")
+	}
+
+	wm.SaveLocalBlock(&Block{Hash: blockHash, Height: 10})
+	wm.SaveConfirmPending(map[string]*openwallet.TxExtractData{"matched": newData(matched, "100"), "large": newData(large, "100")})
+
+	observer := &testReplayObserver{}
+	wm.Blockscanner.AddObserver(observer)
+	alerts := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(alerts)
+
+	wm.SaveLocalNewBlock(12, testHash("block12"))
+	wm.Blockscanner.checkConfirmations()
+	if len(observer.notified) != 1 || observer.notified[0] != "matched:"+matched {
+		t.Errorf("unexpected confirmed notifications: %v", observer.notified)
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeDepositMismatch {
+		t.Errorf("mismatched deposit should alert: %v", alerts.alerts)
+	}
+
+	//不一致的入账继续核对，不重复告警
+	wm.Blockscanner.checkConfirmations()
+	if len(observer.notified) != 1 || len(alerts.alerts) != 1 {
+		t.Errorf("mismatched deposit should not notify or alert again: %v, %d", observer.notified, len(alerts.alerts))
+	}
+}
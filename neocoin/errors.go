@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//适配器扩展的错误码，配合openwallet.Errorf使用
+const (
+	/* 区块扫描类别 */
+	ErrBlockHeightInvalid     = 5001 //区块高度不正确
+	ErrBlockHashMismatch      = 5002 //区块hash与节点不一致
+	ErrLocalDBOperateFailed   = 5003 //本地数据库操作失败
+	ErrStorageBusy            = 5004 //本地数据库被其他进程占用
+	ErrScanLeaseHeld          = 5005 //扫描租约被其他实例持有
+	ErrTxPageSnapshotStale    = 5006 //分页快照锚定的区块已被分叉替换
+	ErrNotifyDeliveryNotFound = 5007 //通知投递记录不存在
+
+	/* 风险筛查类别 */
+	ErrAddressRiskBlocked    = 5201 //地址风险过高，拒绝交易
+	ErrWithdrawLimitExceeded = 5202 //超出提币限额
+	ErrDepositMismatch       = 5203 //入账与浏览器或备用节点的数据不一致
+	ErrAddressAuditFailed    = 5204 //派生的地址未通过节点校验
+
+	/* 权限类别 */
+	ErrOperationNotPermitted = 5301 //未授权执行危险操作
+
+	/* 交易审批类别 */
+	ErrTransactionPendingApproval = 5401 //交易单待审批
+	ErrTransactionRejected        = 5402 //交易单审批拒绝
+
+	/* 交易广播类别 */
+	ErrTxIDMismatch                = 5501 //交易ID与节点不一致
+	ErrTransactionCancelled        = 5502 //交易单已取消
+	ErrTransactionAlreadySubmitted = 5503 //交易单已广播，不能取消
+	ErrTransactionScheduled        = 5504 //交易单未到最早广播时间，已保存由定时任务广播
+
+	/* 节点响应类别 */
+	ErrRPCResponseInvalid       = 5601 //节点返回数据格式不正确
+	ErrRPCMethodUnavailable     = 5602 //RPC方法不可用，依赖的功能已熔断
+	ErrExplorerQuorumNotReached = 5603 //浏览器返回一致数据的数量未达到要求
+
+	/* 跨链证明类别 */
+	ErrProofUnavailable = 5701 //无法生成交易证明
+
+	/* 配置类别 */
+	ErrConfigInvalid  = 5801 //配置不正确
+	ErrFeatureUnknown = 5802 //功能开关不存在
+
+	/* 交易构建类别 */
+	ErrTransactionTooLarge = 5901 //交易单超出大小上限，需要拆分
+
+	/* 本地数据类别 */
+	ErrLocalDBSchemaUnsupported = 5101 //本地数据结构版本不支持
+	ErrLocalDBNetworkMismatch   = 5102 //本地数据属于其他网络
+	ErrLocalDBBackupInvalid     = 5103 //备份格式不正确、不完整或校验失败
+)
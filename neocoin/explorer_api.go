@@ -16,7 +16,6 @@
 package neocoin
 
 import (
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
@@ -106,40 +105,40 @@ func (b *Explorer) isError(resp *req.Resp) error {
 //getBlockByExplorer 获取区块数据
 func (wm *WalletManager) getBlockByExplorer(hash string) (*Block, error) {
 
-	path := fmt.Sprintf("block/%s", hash)
+	mapper := wm.explorerMapper()
 
-	result, err := wm.ExplorerClient.Call(path, nil, "GET")
+	result, err := wm.explorerClient().Call(mapper.BlockPath(hash), nil, "GET")
 	if err != nil {
 		return nil, err
 	}
 
-	return newBlockByExplorer(result), nil
+	return mapper.MapBlock(result), nil
 }
 
 //getBlockHashByExplorer 获取区块hash
 func (wm *WalletManager) getBlockHashByExplorer(height uint64) (string, error) {
 
-	path := fmt.Sprintf("block-index/%d", height)
+	mapper := wm.explorerMapper()
 
-	result, err := wm.ExplorerClient.Call(path, nil, "GET")
+	result, err := wm.explorerClient().Call(mapper.BlockHashPath(height), nil, "GET")
 	if err != nil {
 		return "", err
 	}
 
-	return result.Get("blockHash").String(), nil
+	return mapper.MapBlockHash(result), nil
 }
 
 //getBlockHeightByExplorer 获取区块链高度
 func (wm *WalletManager) getBlockHeightByExplorer() (uint64, error) {
 
-	path := "status?q=getInfo"
+	mapper := wm.explorerMapper()
 
-	result, err := wm.ExplorerClient.Call(path, nil, "GET")
+	result, err := wm.explorerClient().Call(mapper.BlockHeightPath(), nil, "GET")
 	if err != nil {
 		return 0, err
 	}
 
-	height := result.Get("info.blocks").Uint()
+	height := mapper.MapBlockHeight(result)
 
 	return height, nil
 }
@@ -153,14 +152,12 @@ func (wm *WalletManager) getTxIDsInMemPoolByExplorer() ([]string, error) {
 //GetTransaction 获取交易单
 func (wm *WalletManager) getTransactionByExplorer(txid string) (*Transaction, error) {
 
-	path := fmt.Sprintf("tx/%s", txid)
-
-	result, err := wm.ExplorerClient.Call(path, nil, "GET")
+	result, err := wm.explorerClient().Call(wm.explorerMapper().TransactionPath(txid), nil, "GET")
 	if err != nil {
 		return nil, err
 	}
 
-	tx := wm.newTxByExplorer(result)
+	tx := wm.mapExplorerTx(result)
 
 	return tx, nil
 
@@ -181,12 +178,12 @@ func (wm *WalletManager) listUnspentByExplorer(min uint64, address ...string) ([
 
 	path := "addrs/utxo"
 
-	result, err := wm.ExplorerClient.Call(path, request, "POST")
+	result, err := wm.quorumExplorerCall(path, request, "POST", digestUnspentResult)
 	if err != nil {
 		return nil, err
 	}
 
-	balance, err := NewUnspentBalance(result)
+	balance, err := NewUnspentBalance(result, wm.config().GASAssetID)
 	if err != nil {
 		return nil, err
 	}
@@ -197,255 +194,61 @@ func (wm *WalletManager) listUnspentByExplorer(min uint64, address ...string) ([
 
 }
 
-func newBlockByExplorer(json *gjson.Result) *Block {
-
-	/*
-		{
-			"hash": "0000000000002bd2475d1baea1de4067ebb528523a8046d5f9d8ef1cb60460d3",
-			"size": 549,
-			"height": 1434016,
-			"version": 536870912,
-			"merkleroot": "ae4310c991ec16cfc7404aaad9fe5fbd533d0b6617c03eb1ac644c89d58b3e18",
-			"tx": ["6767a8acc1a63c7978186c582fdea26c47da5e04b0b2b34740a1728bfd959a05", "226dee96373aedd8a3dd00021684b190b7f23f5e16bb186cee11d0560406c19d"],
-			"time": 1539066282,
-			"nonce": 4089837546,
-			"bits": "1a3fffc0",
-			"difficulty": 262144,
-			"chainwork": "0000000000000000000000000000000000000000000000c6fce84fddeb57e5fb",
-			"confirmations": 279,
-			"previousblockhash": "0000000000001fdabb5efc93d15ccaf6980642918cd898df6b3ff5fbf26c19c4",
-			"nextblockhash": "00000000000024f2bd323157e595613291f83485ddfbbf311323ed0c0dc46545",
-			"reward": 0.78125,
-			"isMainChain": true,
-			"poolInfo": {}
-		}
-	*/
-	obj := &Block{}
-	//解析json
-	obj.Hash = gjson.Get(json.Raw, "hash").String()
-	obj.Confirmations = gjson.Get(json.Raw, "confirmations").Uint()
-	obj.Merkleroot = gjson.Get(json.Raw, "merkleroot").String()
-
-	txs := make([]string, 0)
-	for _, tx := range gjson.Get(json.Raw, "tx").Array() {
-		txs = append(txs, tx.String())
-	}
-
-	obj.tx = txs
-	obj.Previousblockhash = gjson.Get(json.Raw, "previousblockhash").String()
-	obj.Height = gjson.Get(json.Raw, "height").Uint()
-	//obj.Version = gjson.Get(json.Raw, "version").String()
-	obj.Time = gjson.Get(json.Raw, "time").Uint()
-
-	return obj
-}
-
-func (wm *WalletManager) newTxByExplorer(json *gjson.Result) *Transaction {
-
-	/*
-			{
-			"txid": "9f5eae5b95016825a437ceb9c9224d3e30d3b351f1100e4df5cc0cacac4e668c",
-			"version": 1,
-			"locktime": 1433760,
-			"vin": [],
-			"vout": [],
-			"blockhash": "0000000000003ac968ee1ae321f35f76d4dcb685045968d60fc39edb20b0eed0",
-			"blockheight": 1433761,
-			"confirmations": 5,
-			"time": 1539050096,
-			"blocktime": 1539050096,
-			"valueOut": 0.14652549,
-			"size": 814,
-			"valueIn": 0.14668889,
-			"fees": 0.0001634
-		}
-	*/
-	obj := Transaction{}
-	//解析json
-	obj.TxID = gjson.Get(json.Raw, "txid").String()
-	obj.Version = gjson.Get(json.Raw, "version").Uint()
-	obj.BlockHash = gjson.Get(json.Raw, "blockhash").String()
-	blockHeight := gjson.Get(json.Raw, "blockheight").Int()
-	if blockHeight < 0 {
-		obj.BlockHeight = 0
-	} else {
-		obj.BlockHeight = uint64(blockHeight)
-	}
-
-	obj.Confirmations = gjson.Get(json.Raw, "confirmations").Uint()
-	obj.Blocktime = gjson.Get(json.Raw, "blocktime").Int()
-	obj.Size = gjson.Get(json.Raw, "size").Uint()
-
-	obj.Vins = make([]*Vin, 0)
-	if vins := gjson.Get(json.Raw, "vin"); vins.IsArray() {
-		for _, vin := range vins.Array() {
-			input := newTxVinByExplorer(&vin)
-			obj.Vins = append(obj.Vins, input)
-		}
-	}
-
-	obj.Vouts = make([]*Vout, 0)
-	if vouts := gjson.Get(json.Raw, "vout"); vouts.IsArray() {
-		for _, vout := range vouts.Array() {
-			output := wm.newTxVoutByExplorer(&vout)
-			obj.Vouts = append(obj.Vouts, output)
-		}
-	}
-
-	return &obj
-}
-
-func newTxVinByExplorer(json *gjson.Result) *Vin {
-
-	/*
-		{
-			"txid": "b8c00fff9208cb02f694666084fe0d65c471e92e45cdc3fb2e43af3a772e702d",
-			"vout": 0,
-			"sequence": 4294967294,
-			"n": 0,
-			"scriptSig": {
-				"hex": "47304402201f77d18435931a6cb51b6dd183decf067f933e92647562f71a33e80988fbc8f6022012abe6824ffa70e5ccb7326e0dbb66144ba71133c1d4a1215da0b17358d7ca660121024d7be1242bd44619779a976cd1cd2d9351fcf58df59929b30a0c69d852302fb5",
-				"asm": "304402201f77d18435931a6cb51b6dd183decf067f933e92647562f71a33e80988fbc8f6022012abe6824ffa70e5ccb7326e0dbb66144ba71133c1d4a1215da0b17358d7ca66[ALL] 024d7be1242bd44619779a976cd1cd2d9351fcf58df59929b30a0c69d852302fb5"
-			},
-			"addr": "msYiUQquCtGucnk3ZaWeJenYmY8WxRoeuv",
-			"valueSat": 990000,
-			"value": 0.0099,
-			"doubleSpentTxID": null
-		}
-	*/
-	obj := Vin{}
-	//解析json
-	obj.TxID = gjson.Get(json.Raw, "txid").String()
-	obj.Vout = gjson.Get(json.Raw, "vout").Uint()
-	obj.N = gjson.Get(json.Raw, "n").Uint()
-	obj.Addr = gjson.Get(json.Raw, "addr").String()
-	obj.Value = gjson.Get(json.Raw, "value").String()
-	obj.Coinbase = gjson.Get(json.Raw, "coinbase").String()
-
-	return &obj
-}
-
-func (wm *WalletManager) newTxVoutByExplorer(json *gjson.Result) *Vout {
-
-	/*
-		{
-			"value": "0.01652549",
-			"n": 0,
-			"scriptPubKey": {
-				"hex": "76a9142760a760e8d22b5facb380444920e1197f272ea888ac",
-				"asm": "OP_DUP OP_HASH160 2760a760e8d22b5facb380444920e1197f272ea8 OP_EQUALVERIFY OP_CHECKSIG",
-				"addresses": ["mj7ASAGw8ia2o7Hqvo2XS1d7jGWr5UgEU9"],
-				"type": "pubkeyhash"
-			},
-			"spentTxId": null,
-			"spentIndex": null,
-			"spentHeight": null
-		}
-	*/
-	obj := Vout{}
-	//解析json
-	obj.Value = gjson.Get(json.Raw, "value").String()
-	obj.N = gjson.Get(json.Raw, "n").Uint()
-	obj.ScriptPubKey = gjson.Get(json.Raw, "scriptPubKey.hex").String()
-	asm := gjson.Get(json.Raw, "scriptPubKey.asm").String()
-
-	if len(obj.ScriptPubKey) == 0 {
-		scriptPubKey, err := DecodeScript(asm)
-		if err == nil {
-			obj.ScriptPubKey = hex.EncodeToString(scriptPubKey)
-		}
-	}
-
-	//提取地址
-	if addresses := gjson.Get(json.Raw, "scriptPubKey.addresses"); addresses.IsArray() {
-		obj.Addr = addresses.Array()[0].String()
-	}
-
-	obj.Type = gjson.Get(json.Raw, "scriptPubKey.type").String()
-
-	if len(obj.Addr) == 0 {
-
-		scriptBytes, _ := hex.DecodeString(obj.ScriptPubKey)
-		obj.Addr, _ = wm.Decoder.ScriptPubKeyToBech32Address(scriptBytes)
-	}
-
-	return &obj
-}
-
 //getBalanceByExplorer 获取地址余额
 func (wm *WalletManager) getBalanceByExplorer(address string) (*openwallet.Balance, error) {
 
-	path := fmt.Sprintf("addr/%s?noTxList=1", address)
+	mapper := wm.explorerMapper()
 
-	result, err := wm.ExplorerClient.Call(path, nil, "GET")
+	result, err := wm.quorumExplorerCall(mapper.BalancePath(address), nil, "GET", wm.digestBalanceResult)
 	if err != nil {
 		return nil, err
 	}
 
-	return newBalanceByExplorer(result), nil
-}
-
-func newBalanceByExplorer(json *gjson.Result) *openwallet.Balance {
-
-	/*
-
-		{
-			"addrStr": "mnMSQs3HZ5zhJrCEKbqGvcDLjAAxvDJDCd",
-			"balance": 3136.82244887,
-			"balanceSat": 313682244887,
-			"totalReceived": 3136.82244887,
-			"totalReceivedSat": 313682244887,
-			"totalSent": 0,
-			"totalSentSat": 0,
-			"unconfirmedBalance": 0,
-			"unconfirmedBalanceSat": 0,
-			"unconfirmedTxApperances": 0,
-			"txApperances": 3909
-		}
-
-	*/
-	//log.Debug(json.Raw)
-	obj := openwallet.Balance{}
-	//解析json
-	obj.Address = gjson.Get(json.Raw, "addrStr").String()
-	obj.ConfirmBalance = gjson.Get(json.Raw, "balance").String()
-	obj.UnconfirmBalance = gjson.Get(json.Raw, "unconfirmedBalance").String()
-	u, _ := decimal.NewFromString(obj.ConfirmBalance)
-	b, _ := decimal.NewFromString(obj.UnconfirmBalance)
-	obj.Balance = u.Add(b).String()
-
-	return &obj
+	return mapper.MapBalance(result, wm.config().NEOAssetID), nil
 }
 
-//getMultiAddrTransactionsByExplorer 获取多个地址的交易单数组
-func (wm *WalletManager) getMultiAddrTransactionsByExplorer(offset, limit int, address ...string) ([]*Transaction, error) {
+//getMultiAddrTransactionsByExplorer 获取多个地址的交易单数组，按txid去重，
+//按最新在前排序：未确认的交易单在前，已确认的按区块高度和区块内序号倒序。
+//maxHeight大于0时只返回该高度及之前已确认的交易单，分页期间新进入的交易单不影响偏移
+func (wm *WalletManager) getMultiAddrTransactionsByExplorer(offset, limit int, maxHeight uint64, address ...string) ([]*Transaction, error) {
 
 	var (
 		trxs = make([]*Transaction, 0)
 	)
 
-	addrs := strings.Join(address, ",")
-
-	request := req.Param{
-		"addrs": addrs,
-		"from":  offset,
-		"to":    offset + limit,
+	inSnapshot := func(item *explorerTxItem) bool {
+		return maxHeight == 0 || (item.tx.BlockHeight > 0 && item.tx.BlockHeight <= maxHeight)
 	}
 
-	path := fmt.Sprintf("addrs/txs")
-
-	result, err := wm.ExplorerClient.Call(path, request, "POST")
+	all, _, err := wm.collectAddrTxsByExplorer(address, func(items []*explorerTxItem) bool {
+		count := 0
+		for _, item := range items {
+			if inSnapshot(item) {
+				count++
+			}
+		}
+		return count >= offset+limit
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if items := result.Get("items"); items.IsArray() {
-		for _, obj := range items.Array() {
-			tx := wm.newTxByExplorer(&obj)
-			trxs = append(trxs, tx)
+	items := make([]*explorerTxItem, 0, len(all))
+	for _, item := range all {
+		if inSnapshot(item) {
+			items = append(items, item)
 		}
 	}
 
+	if err := wm.fillExplorerTxIndex(items, make(map[string]map[string]int)); err != nil {
+		return nil, err
+	}
+	sortExplorerTxItems(items)
+
+	for i := len(items) - 1 - offset; i >= 0 && len(trxs) < limit; i-- {
+		trxs = append(trxs, items[i].tx)
+	}
+
 	return trxs, nil
 }
 
@@ -456,7 +259,7 @@ func (wm *WalletManager) estimateFeeRateByExplorer() (decimal.Decimal, error) {
 
 	path := fmt.Sprintf("utils/estimatefee?nbBlocks=%d", 2)
 
-	result, err := wm.ExplorerClient.Call(path, nil, "GET")
+	result, err := wm.explorerClient().Call(path, nil, "GET")
 	if err != nil {
 		return decimal.New(0, 0), err
 	}
@@ -497,7 +300,7 @@ func (wm *WalletManager) sendRawTransactionByExplorer(txHex string) (string, err
 
 	path := fmt.Sprintf("tx/send")
 
-	result, err := wm.ExplorerClient.Call(path, request, "POST")
+	result, err := wm.explorerClient().Call(path, request, "POST")
 	if err != nil {
 		return "", err
 	}
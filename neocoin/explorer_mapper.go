@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	ExplorerSchemaInsight = "insight" //bitpay insight-API及其克隆
+	ExplorerSchemaNeoscan = "neoscan" //neoscan v1 API
+)
+
+// ExplorerMapper 浏览器接口映射，把不同浏览器的请求路径和json结构映射为适配器内部结构，
+// 新增浏览器（如Dora、NeoTube）只需在单独文件中实现映射并注册，不需要修改查询逻辑
+type ExplorerMapper interface {
+	//请求路径，相对于浏览器API地址
+	BlockPath(hash string) string
+	BlockHashPath(height uint64) string
+	BlockHeightPath() string
+	TransactionPath(txid string) string
+	BalancePath(address string) string
+
+	//返回结果映射
+	MapBlock(json *gjson.Result) *Block
+	MapBlockHash(json *gjson.Result) string
+	MapBlockHeight(json *gjson.Result) uint64
+	MapTransaction(json *gjson.Result) *Transaction
+	MapBalance(json *gjson.Result, assetID string) *openwallet.Balance
+}
+
+var (
+	explorerMappers = map[string]ExplorerMapper{
+		ExplorerSchemaInsight: insightMapper{},
+		ExplorerSchemaNeoscan: neoscanMapper{},
+	}
+	explorerMappersMu sync.RWMutex
+)
+
+// RegisterExplorerMapper 注册浏览器接口映射，相同名称会被替换，应在加载配置前注册
+func RegisterExplorerMapper(schema string, mapper ExplorerMapper) {
+	explorerMappersMu.Lock()
+	defer explorerMappersMu.Unlock()
+	explorerMappers[schema] = mapper
+}
+
+// explorerMapper 按配置的浏览器接口类型获取映射，未注册的类型使用insight
+func (wm *WalletManager) explorerMapper() ExplorerMapper {
+	explorerMappersMu.RLock()
+	mapper, ok := explorerMappers[wm.config().ExplorerSchema]
+	explorerMappersMu.RUnlock()
+
+	if !ok {
+		if len(wm.config().ExplorerSchema) > 0 {
+			wm.Log.Std.Warning("explorer schema: %s is not registered, use %s", wm.config().ExplorerSchema, ExplorerSchemaInsight)
+		}
+		return insightMapper{}
+	}
+	return mapper
+}
+
+// mapExplorerTx 映射浏览器返回的交易单，补全映射无法得到的输出地址
+func (wm *WalletManager) mapExplorerTx(json *gjson.Result) *Transaction {
+
+	tx := wm.explorerMapper().MapTransaction(json)
+
+	for _, output := range tx.Vouts {
+		if len(output.Addr) > 0 || len(output.ScriptPubKey) == 0 {
+			continue
+		}
+		scriptBytes, _ := hex.DecodeString(output.ScriptPubKey)
+		output.Addr, _ = wm.Decoder.ScriptPubKeyToBech32Address(scriptBytes)
+	}
+
+	return tx
+}
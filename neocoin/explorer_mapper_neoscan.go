@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+// neoscanMapper neoscan的v1接口，浏览器API地址配置到版本路径，例如：https://api.neoscan.io/api/main_net/v1/
+// 数值以json数字返回，hash可能不带0x前缀，映射时统一为节点的格式
+type neoscanMapper struct{}
+
+func (neoscanMapper) BlockPath(hash string) string {
+	return fmt.Sprintf("get_block/%s", strings.TrimPrefix(hash, "0x"))
+}
+
+func (neoscanMapper) BlockHashPath(height uint64) string {
+	return fmt.Sprintf("get_block/%d", height)
+}
+
+func (neoscanMapper) BlockHeightPath() string {
+	return "get_height"
+}
+
+func (neoscanMapper) TransactionPath(txid string) string {
+	return fmt.Sprintf("get_transaction/%s", strings.TrimPrefix(txid, "0x"))
+}
+
+func (neoscanMapper) BalancePath(address string) string {
+	return fmt.Sprintf("get_balance/%s", address)
+}
+
+func (neoscanMapper) MapBlockHash(json *gjson.Result) string {
+	return neoscanHex(json.Get("hash").String())
+}
+
+func (neoscanMapper) MapBlockHeight(json *gjson.Result) uint64 {
+	return json.Get("height").Uint()
+}
+
+func (neoscanMapper) MapBlock(json *gjson.Result) *Block {
+
+	/*
+		{
+			"confirmations": 12,
+			"hash": "0xd87f1b76d89a158ed54a0cb88701e5d5ad86ce6f86399ecb50c589a65d709881",
+			"index": 4123456,
+			"merkleroot": "0x...",
+			"nextblockhash": "0x...",
+			"previousblockhash": "0x...",
+			"size": 686,
+			"time": 1573037731,
+			"transactions": ["0x28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d"],
+			"tx_count": 1,
+			"version": 0
+		}
+	*/
+	obj := &Block{}
+	obj.Hash = neoscanHex(json.Get("hash").String())
+	obj.Confirmations = json.Get("confirmations").Uint()
+	obj.Merkleroot = neoscanHex(json.Get("merkleroot").String())
+	obj.Previousblockhash = neoscanHex(json.Get("previousblockhash").String())
+	obj.Height = json.Get("index").Uint()
+	obj.Version = json.Get("version").Uint()
+	obj.Time = json.Get("time").Uint()
+
+	txs := make([]string, 0)
+	for _, tx := range json.Get("transactions").Array() {
+		txs = append(txs, neoscanHex(tx.String()))
+	}
+	obj.Tx = txs
+
+	return obj
+}
+
+func (neoscanMapper) MapTransaction(json *gjson.Result) *Transaction {
+
+	/*
+		{
+			"txid": "28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d",
+			"type": "ContractTransaction",
+			"version": 0,
+			"size": 262,
+			"sys_fee": "0",
+			"net_fee": "0",
+			"time": 1573037731,
+			"block_hash": "d87f1b76d89a158ed54a0cb88701e5d5ad86ce6f86399ecb50c589a65d709881",
+			"block_height": 4123456,
+			"vin": [
+				{"txid": "9e6b6822...", "n": 1, "asset": "c56f33fc...", "value": 100, "address_hash": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}
+			],
+			"vouts": [
+				{"n": 0, "asset": "c56f33fc...", "value": 100, "address_hash": "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC"}
+			]
+		}
+	*/
+	obj := Transaction{}
+	obj.TxID = neoscanHex(json.Get("txid").String())
+	obj.Type = json.Get("type").String()
+	obj.Version = json.Get("version").Uint()
+	obj.Size = json.Get("size").Uint()
+	obj.SysFee = neoscanAmount(json.Get("sys_fee"))
+	obj.NetFee = neoscanAmount(json.Get("net_fee"))
+	obj.BlockHash = neoscanHex(json.Get("block_hash").String())
+	obj.BlockHeight = json.Get("block_height").Uint()
+	obj.Blocktime = json.Get("time").Int()
+
+	//输入带有引用输出的地址和金额，不需要再查询引用的交易单
+	obj.Vins = make([]*Vin, 0)
+	for i, vin := range json.Get("vin").Array() {
+		obj.Vins = append(obj.Vins, &Vin{
+			TxID:  neoscanHex(vin.Get("txid").String()),
+			Vout:  vin.Get("n").Uint(),
+			N:     uint64(i),
+			Addr:  vin.Get("address_hash").String(),
+			Value: neoscanAmount(vin.Get("value")),
+		})
+	}
+
+	obj.Vouts = make([]*Vout, 0)
+	for _, vout := range json.Get("vouts").Array() {
+		output := &Vout{
+			N:     vout.Get("n").Uint(),
+			Asset: neoscanHex(vout.Get("asset").String()),
+			Value: neoscanAmount(vout.Get("value")),
+			Addr:  vout.Get("address_hash").String(),
+		}
+		resolveContractDestination(output, &vout)
+		obj.Vouts = append(obj.Vouts, output)
+	}
+
+	return &obj
+}
+
+func (neoscanMapper) MapBalance(json *gjson.Result, assetID string) *openwallet.Balance {
+
+	/*
+		{
+			"address": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT",
+			"balance": [
+				{"asset_hash": "c56f33fc...", "asset_symbol": "NEO", "amount": 100, "unspent": []}
+			]
+		}
+	*/
+	obj := openwallet.Balance{}
+	obj.Address = json.Get("address").String()
+	obj.ConfirmBalance = "0"
+	obj.UnconfirmBalance = "0"
+
+	assetID = strings.TrimPrefix(strings.ToLower(assetID), "0x")
+	for _, b := range json.Get("balance").Array() {
+		if strings.TrimPrefix(strings.ToLower(b.Get("asset_hash").String()), "0x") == assetID {
+			obj.ConfirmBalance = neoscanAmount(b.Get("amount"))
+			break
+		}
+	}
+	obj.Balance = obj.ConfirmBalance
+
+	return &obj
+}
+
+// neoscanHex hash统一为0x前缀的小写格式
+func neoscanHex(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return "0x" + strings.TrimPrefix(strings.ToLower(s), "0x")
+}
+
+// neoscanAmount json数字转为十进制字符串，避免科学计数法
+func neoscanAmount(value gjson.Result) string {
+	if !value.Exists() {
+		return "0"
+	}
+	amount, err := decimal.NewFromString(value.String())
+	if err != nil {
+		return value.String()
+	}
+	return amount.String()
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// testPathExplorer 按请求路径返回固定json的浏览器
+type testPathExplorer map[string]string
+
+func (e testPathExplorer) Call(path string, request interface{}, method string) (*gjson.Result, error) {
+	raw, ok := e[path]
+	if !ok {
+		return nil, fmt.Errorf("path: %s not found", path)
+	}
+	result := gjson.Parse(raw)
+	return &result, nil
+}
+
+func TestWalletManager_ExplorerMapperNeoscan(t *testing.T) {
+	const (
+		txid  = "28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d"
+		block = "D87F1B76D89A158ED54A0CB88701E5D5AD86CE6F86399ECB50C589A65D709881"
+		neo   = "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+	)
+
+	wm := NewWalletManager()
+	wm.Config.RPCServerType = RPCServerExplorer
+	wm.Config.ExplorerSchema = ExplorerSchemaNeoscan
+	wm.SetExplorerClient(testPathExplorer{
+		"get_height":                          `{"height": 4123456}`,
+		"get_block/4000":                      `{"hash": "` + block + `", "index": 4000}`,
+		"get_block/" + strings.ToLower(block): `{"hash": "` + block + `", "index": 4000, "time": 1573037731, "transactions": ["` + txid + `"]}`,
+		"get_transaction/" + txid: `{"txid": "` + txid + `", "type": "ContractTransaction", "block_hash": "` + block + `", "block_height": 4000, "time": 1573037731, "sys_fee": 0, "net_fee": 0.001,
+			"vin": [{"txid": "9e6b682209f778a1246202524be785633e03129b6877040ad05134cc96336fcb", "n": 1, "asset": "` + neo + `", "value": 100, "address_hash": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}],
+			"vouts": [{"n": 0, "asset": "` + neo + `", "value": 1e2, "address_hash": "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC"}]}`,
+		"get_balance/AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC": `{"address": "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC", "balance": [
+			{"asset_hash": "602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7", "amount": 0.5},
+			{"asset_hash": "` + neo + `", "amount": 100}]}`,
+	})
+
+	height, err := wm.getBlockHeightByExplorer()
+	if err != nil || height != 4123456 {
+		t.Errorf("getBlockHeightByExplorer: %d, unexpected error: %v", height, err)
+	}
+
+	hash, err := wm.GetBlockHash(4000)
+	if err != nil || hash != "0x"+"d87f1b76d89a158ed54a0cb88701e5d5ad86ce6f86399ecb50c589a65d709881" {
+		t.Errorf("GetBlockHash: %s, unexpected error: %v", hash, err)
+		return
+	}
+
+	b, err := wm.GetBlock(hash)
+	if err != nil || b.Height != 4000 || len(b.Tx) != 1 || b.Tx[0] != "0x"+txid {
+		t.Errorf("GetBlock: %+v, unexpected error: %v", b, err)
+	}
+
+	tx, err := wm.GetTransaction("0x" + txid)
+	if err != nil {
+		t.Errorf("GetTransaction failed unexpected error: %v\n", err)
+		return
+	}
+	if tx.BlockHeight != 4000 || tx.BlockHash != hash || tx.NetFee != "0.001" || len(tx.Vins) != 1 || len(tx.Vouts) != 1 {
+		t.Errorf("unexpected mapped transaction: %+v", tx)
+		return
+	}
+	if vin := tx.Vins[0]; vin.Vout != 1 || vin.Addr != "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT" || vin.Value != "100" {
+		t.Errorf("unexpected mapped input: %+v", vin)
+	}
+	if vout := tx.Vouts[0]; vout.Asset != "0x"+neo || vout.Value != "100" || vout.Addr != "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC" {
+		t.Errorf("unexpected mapped output: %+v", vout)
+	}
+
+	balance, err := wm.getBalanceByExplorer("AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC")
+	if err != nil || balance.ConfirmBalance != "100" || balance.Balance != "100" {
+		t.Errorf("getBalanceByExplorer: %+v, unexpected error: %v", balance, err)
+	}
+}
+
+// testTubeMapper 注册的自定义浏览器映射，路径不同，其余沿用insight
+type testTubeMapper struct {
+	insightMapper
+}
+
+func (testTubeMapper) BlockHeightPath() string {
+	return "v1/height"
+}
+
+func (testTubeMapper) MapBlockHeight(json *gjson.Result) uint64 {
+	return json.Get("data.height").Uint()
+}
+
+func TestRegisterExplorerMapper(t *testing.T) {
+	RegisterExplorerMapper("test-tube", testTubeMapper{})
+	defer func() {
+		explorerMappersMu.Lock()
+		delete(explorerMappers, "test-tube")
+		explorerMappersMu.Unlock()
+	}()
+
+	wm := NewWalletManager()
+	wm.Config.ExplorerSchema = "test-tube"
+	wm.SetExplorerClient(testPathExplorer{
+		"v1/height":        `{"data": {"height": 77}}`,
+		"status?q=getInfo": `{"info": {"blocks": 66}}`,
+	})
+
+	height, err := wm.getBlockHeightByExplorer()
+	if err != nil || height != 77 {
+		t.Errorf("registered mapper height: %d, unexpected error: %v", height, err)
+	}
+
+	//未注册的类型使用insight
+	wm.Config.ExplorerSchema = "unknown"
+	height, err = wm.getBlockHeightByExplorer()
+	if err != nil || height != 66 {
+		t.Errorf("fallback mapper height: %d, unexpected error: %v", height, err)
+	}
+}
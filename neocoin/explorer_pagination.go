@@ -0,0 +1,288 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/imroc/req"
+)
+
+const (
+	explorerTxPageSize    = 50 //浏览器单次请求的交易单数量上限
+	explorerTxPageOverlap = 5  //相邻页重叠的数量，拉取期间有交易单被移除时避免遗漏
+)
+
+//ExplorerTxPage 按游标分页的地址交易单
+type ExplorerTxPage struct {
+	Txs        []*Transaction
+	NextCursor string //下一页游标，传入ListAddrTransactionsByExplorer继续拉取
+	HasMore    bool
+}
+
+//explorerTxCursor 游标记录上一页最后一笔交易单的位置，
+//Seen为游标及之前的已确认交易单数量，新交易单只追加在最新一端，从最早一端计数的位置保持稳定
+type explorerTxCursor struct {
+	Height uint64 `json:"h"`
+	Index  int    `json:"i"`
+	Seen   int    `json:"n"`
+}
+
+func encodeExplorerTxCursor(c *explorerTxCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeExplorerTxCursor(cursor string) (*explorerTxCursor, error) {
+	c := &explorerTxCursor{}
+	if len(cursor) == 0 {
+		return c, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return c, nil
+}
+
+//explorerTxItem 交易单及其在区块中的序号
+type explorerTxItem struct {
+	tx    *Transaction
+	index int
+}
+
+//after 是否排在游标之后，未确认的交易单排在最后
+func (item *explorerTxItem) after(c *explorerTxCursor) bool {
+	if item.tx.BlockHeight == 0 {
+		return true
+	}
+	if item.tx.BlockHeight != c.Height {
+		return item.tx.BlockHeight > c.Height
+	}
+	return item.index > c.Index
+}
+
+//sortExplorerTxItems 按区块高度、区块内序号升序，未确认的交易单排在最后
+func sortExplorerTxItems(items []*explorerTxItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		hi, hj := items[i].tx.BlockHeight, items[j].tx.BlockHeight
+		if (hi == 0) != (hj == 0) {
+			return hj == 0
+		}
+		if hi != hj {
+			return hi < hj
+		}
+		if items[i].index != items[j].index {
+			return items[i].index < items[j].index
+		}
+		return items[i].tx.TxID < items[j].tx.TxID
+	})
+}
+
+//getAddrTxPageByExplorer 拉取一页地址交易单，返回交易单和总数
+func (wm *WalletManager) getAddrTxPageByExplorer(addrs string, from int) ([]*Transaction, int, error) {
+
+	request := req.Param{
+		"addrs": addrs,
+		"from":  from,
+		"to":    from + explorerTxPageSize,
+	}
+
+	result, err := wm.quorumExplorerCall("addrs/txs", request, "POST", wm.digestTxPageResult)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	trxs := make([]*Transaction, 0)
+	if items := result.Get("items"); items.IsArray() {
+		for _, obj := range items.Array() {
+			trxs = append(trxs, wm.mapExplorerTx(&obj))
+		}
+	}
+
+	return trxs, int(result.Get("totalItems").Int()), nil
+}
+
+//collectAddrTxsByExplorer 从最新的交易单开始逐页拉取并按txid去重，
+//stop返回true时停止拉取，返回是否已拉取全部交易单
+func (wm *WalletManager) collectAddrTxsByExplorer(address []string, stop func(items []*explorerTxItem) bool) ([]*explorerTxItem, bool, error) {
+
+	var (
+		addrs = strings.Join(address, ",")
+		items = make([]*explorerTxItem, 0)
+		seen  = make(map[string]bool)
+		from  = 0
+	)
+
+	for {
+		trxs, total, err := wm.getAddrTxPageByExplorer(addrs, from)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, tx := range trxs {
+			key := normalizeTxID(tx.TxID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			items = append(items, &explorerTxItem{tx: tx})
+		}
+
+		if len(trxs) == 0 || from+len(trxs) >= total {
+			return items, true, nil
+		}
+
+		if stop(items) {
+			return items, false, nil
+		}
+
+		//相邻页重叠，重复的交易单已去重
+		if len(trxs) > explorerTxPageOverlap {
+			from += len(trxs) - explorerTxPageOverlap
+		} else {
+			from += len(trxs)
+		}
+	}
+}
+
+//fillExplorerTxIndex 查询区块补全交易单在区块中的序号，blocks缓存区块内交易单序号，同一区块只查询一次
+func (wm *WalletManager) fillExplorerTxIndex(items []*explorerTxItem, blocks map[string]map[string]int) error {
+
+	for _, item := range items {
+		if item.tx.BlockHeight == 0 || len(item.tx.BlockHash) == 0 {
+			continue
+		}
+
+		index, ok := blocks[item.tx.BlockHash]
+		if !ok {
+			block, err := wm.getBlockByExplorer(item.tx.BlockHash)
+			if err != nil {
+				return err
+			}
+			index = make(map[string]int, len(block.Tx))
+			for i, txid := range block.Tx {
+				index[normalizeTxID(txid)] = i
+			}
+			blocks[item.tx.BlockHash] = index
+		}
+
+		item.index = index[normalizeTxID(item.tx.TxID)]
+	}
+
+	return nil
+}
+
+//ListAddrTransactionsByExplorer 按区块高度和区块内序号升序分页获取多个地址的已确认交易单，
+//cursor为空从第一笔开始，之后传入上一页返回的NextCursor继续，新交易单只会出现在后续页
+func (wm *WalletManager) ListAddrTransactionsByExplorer(cursor string, limit int, address ...string) (*ExplorerTxPage, error) {
+
+	c, err := decodeExplorerTxCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = explorerTxPageSize
+	}
+
+	addrs := strings.Join(address, ",")
+	_, total, err := wm.getAddrTxPageByExplorer(addrs, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		items  = make([]*explorerTxItem, 0)
+		seen   = make(map[string]bool)
+		blocks = make(map[string]map[string]int)
+		//浏览器按时间倒序返回，游标之后的交易单位于倒序下标total-Seen之前，从该位置向最新一端逐页拉取
+		end = total - c.Seen + explorerTxPageOverlap
+	)
+	if end > total {
+		end = total
+	}
+
+	for end > 0 {
+		from := end - explorerTxPageSize
+		if from < 0 {
+			from = 0
+		}
+
+		trxs, _, err := wm.getAddrTxPageByExplorer(addrs, from)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range trxs {
+			key := normalizeTxID(tx.TxID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			items = append(items, &explorerTxItem{tx: tx})
+		}
+
+		if err := wm.fillExplorerTxIndex(items, blocks); err != nil {
+			return nil, err
+		}
+
+		//已拉取到多于一页的后续交易单
+		count := 0
+		for _, item := range items {
+			if item.tx.BlockHeight > 0 && item.after(c) {
+				count++
+			}
+		}
+		if count > limit || from == 0 {
+			break
+		}
+		end = from + explorerTxPageOverlap
+	}
+
+	sortExplorerTxItems(items)
+
+	page := &ExplorerTxPage{
+		Txs:        make([]*Transaction, 0, limit),
+		NextCursor: cursor,
+	}
+	next := *c
+	for _, item := range items {
+		if item.tx.BlockHeight == 0 || !item.after(c) {
+			continue
+		}
+		if len(page.Txs) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Txs = append(page.Txs, item.tx)
+		next.Height = item.tx.BlockHeight
+		next.Index = item.index
+		next.Seen++
+	}
+	if len(page.Txs) > 0 {
+		page.NextCursor = encodeExplorerTxCursor(&next)
+	}
+
+	return page, nil
+}
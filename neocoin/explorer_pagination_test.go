@@ -0,0 +1,172 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+//testExplorer 按最新在前返回地址交易单的浏览器
+type testExplorer struct {
+	txs    []map[string]interface{}
+	blocks map[string][]string
+	onPage func(from int)
+}
+
+func (e *testExplorer) addTx(height int, index int) {
+	txid := fmt.Sprintf("tx%d_%d", height, index)
+	hash := ""
+	if height > 0 {
+		hash = fmt.Sprintf("block%d", height)
+		e.blocks[hash] = append(e.blocks[hash], txid)
+	}
+	//同一区块内按序号正序插入到最前，浏览器返回的区块内顺序与区块序号相反
+	tx := map[string]interface{}{"txid": txid, "blockheight": height, "blockhash": hash, "vin": []interface{}{}, "vout": []interface{}{}}
+	e.txs = append([]map[string]interface{}{tx}, e.txs...)
+}
+
+func (e *testExplorer) Call(path string, request interface{}, method string) (*gjson.Result, error) {
+	var body interface{}
+	if path == "addrs/txs" {
+		param := request.(req.Param)
+		from, to := param["from"].(int), param["to"].(int)
+		if e.onPage != nil {
+			e.onPage(from)
+		}
+		if to > len(e.txs) {
+			to = len(e.txs)
+		}
+		items := []map[string]interface{}{}
+		if from < to {
+			items = e.txs[from:to]
+		}
+		body = map[string]interface{}{"totalItems": len(e.txs), "from": from, "to": to, "items": items}
+	} else if strings.HasPrefix(path, "block/") {
+		hash := strings.TrimPrefix(path, "block/")
+		body = map[string]interface{}{"hash": hash, "tx": e.blocks[hash]}
+	}
+	raw, _ := json.Marshal(body)
+	result := gjson.ParseBytes(raw)
+	return &result, nil
+}
+
+func newTestExplorer(blocks int) *testExplorer {
+	e := &testExplorer{blocks: make(map[string][]string)}
+	for h := 1; h <= blocks; h++ {
+		e.addTx(h, 0)
+		e.addTx(h, 1)
+	}
+	e.addTx(0, 0)
+	return e
+}
+
+func TestWalletManager_GetMultiAddrTransactionsByExplorer(t *testing.T) {
+	explorer := newTestExplorer(60)
+	wm := NewWalletManager()
+	wm.SetExplorerClient(explorer)
+
+	trxs, err := wm.getMultiAddrTransactionsByExplorer(0, 5, 0, "A")
+	if err != nil {
+		t.Errorf("getMultiAddrTransactionsByExplorer failed unexpected error: %v\n", err)
+		return
+	}
+	expected := []string{"tx0_0", "tx60_1", "tx60_0", "tx59_1", "tx59_0"}
+	for i, tx := range trxs {
+		if tx.TxID != expected[i] {
+			t.Errorf("trxs[%d] = %s, expected: %s", i, tx.TxID, expected[i])
+		}
+	}
+
+	//拉取过程中有新交易单进入，不重复不遗漏
+	inserted := false
+	explorer.onPage = func(from int) {
+		if from > 0 && !inserted {
+			inserted = true
+			explorer.addTx(0, 1)
+			explorer.addTx(0, 2)
+		}
+	}
+	trxs, _ = wm.getMultiAddrTransactionsByExplorer(100, 21, 0, "A")
+	seen := make(map[string]bool)
+	for _, tx := range trxs {
+		if seen[tx.TxID] {
+			t.Errorf("duplicated tx: %s", tx.TxID)
+		}
+		seen[tx.TxID] = true
+	}
+	if len(trxs) != 21 || trxs[len(trxs)-1].TxID != "tx1_0" {
+		t.Errorf("unexpected last page, count: %d", len(trxs))
+	}
+}
+
+func TestWalletManager_ListAddrTransactionsByExplorer(t *testing.T) {
+	explorer := newTestExplorer(60)
+	wm := NewWalletManager()
+	wm.SetExplorerClient(explorer)
+
+	var (
+		cursor string
+		list   = make([]*Transaction, 0)
+		pages  = 0
+	)
+	for {
+		page, err := wm.ListAddrTransactionsByExplorer(cursor, 25, "A")
+		if err != nil {
+			t.Errorf("ListAddrTransactionsByExplorer failed unexpected error: %v\n", err)
+			return
+		}
+		list = append(list, page.Txs...)
+		cursor = page.NextCursor
+		pages++
+
+		//翻页期间出块，新交易单出现在后续页
+		if pages == 2 {
+			explorer.addTx(61, 0)
+			explorer.addTx(61, 1)
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if len(list) != 122 {
+		t.Errorf("unexpected tx count: %d", len(list))
+		return
+	}
+	for i, tx := range list {
+		expected := fmt.Sprintf("tx%d_%d", i/2+1, i%2)
+		if tx.TxID != expected {
+			t.Errorf("list[%d] = %s, expected: %s", i, tx.TxID, expected)
+			return
+		}
+	}
+
+	//没有新交易单时游标不变
+	page, _ := wm.ListAddrTransactionsByExplorer(cursor, 25, "A")
+	if len(page.Txs) != 0 || page.NextCursor != cursor || page.HasMore {
+		t.Errorf("unexpected page after last: %+v", page)
+	}
+
+	if _, err := wm.ListAddrTransactionsByExplorer("!invalid", 25, "A"); err == nil {
+		t.Errorf("invalid cursor should fail")
+	}
+}
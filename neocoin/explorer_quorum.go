@@ -0,0 +1,168 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+//explorerDigestFunc 提取浏览器返回结果的摘要和涉及的最大资产数额，摘要相同视为结果一致
+type explorerDigestFunc func(result *gjson.Result) (digest string, value decimal.Decimal)
+
+//SetBackupExplorerClients 注入备用浏览器API客户端实现，设置后替代BackupExplorerClients，nil恢复使用BackupExplorerClients
+func (wm *WalletManager) SetBackupExplorerClients(explorers []ExplorerInterface) {
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+	wm.injected.backupExplorers = explorers
+}
+
+//backupExplorerClients 当前使用的备用浏览器API客户端
+func (wm *WalletManager) backupExplorerClients() []ExplorerInterface {
+	wm.injectMu.RLock()
+	defer wm.injectMu.RUnlock()
+	if wm.injected.backupExplorers != nil {
+		return wm.injected.backupExplorers
+	}
+	explorers := make([]ExplorerInterface, 0, len(wm.BackupExplorerClients))
+	for _, e := range wm.BackupExplorerClients {
+		explorers = append(explorers, e)
+	}
+	return explorers
+}
+
+//quorumExplorerCall 请求主浏览器，结果涉及的数额达到阈值时向备用浏览器请求相同数据，
+//包括主浏览器在内有explorerQuorum个结果一致才返回，否则告警并返回ErrExplorerQuorumNotReached错误
+func (wm *WalletManager) quorumExplorerCall(path string, request interface{}, method string, digest explorerDigestFunc) (*gjson.Result, error) {
+
+	result, err := wm.explorerClient().Call(path, request, method)
+	if err != nil {
+		return nil, err
+	}
+
+	quorum := wm.config().ExplorerQuorum
+	if quorum <= 1 {
+		return result, nil
+	}
+
+	expected, value := digest(result)
+	if value.LessThan(wm.config().ExplorerQuorumThreshold) {
+		return result, nil
+	}
+
+	var (
+		agreed    = 1
+		responded = 1
+		conflicts = make([]string, 0)
+	)
+	for i, explorer := range wm.backupExplorerClients() {
+		if agreed >= quorum {
+			break
+		}
+		backup, err := explorer.Call(path, request, method)
+		if err != nil {
+			wm.Log.Std.Info("backup explorer: %d request: %s failed, unexpected error: %v", i, path, err)
+			continue
+		}
+		responded++
+		if d, _ := digest(backup); d == expected {
+			agreed++
+		} else {
+			conflicts = append(conflicts, fmt.Sprintf("backup explorer: %d", i))
+		}
+	}
+
+	if agreed < quorum {
+		//有不一致的结果时告警，只是备用浏览器不可用时不告警
+		if len(conflicts) > 0 {
+			alert := NewAlert(wm.Symbol(), AlertTypeExplorerDisagreement, 0,
+				fmt.Sprintf("explorer request: %s disagreed by %s", path, strings.Join(conflicts, ", ")))
+			alert.Details["path"] = path
+			alert.Details["value"] = value.String()
+			alert.Details["agreed"] = fmt.Sprintf("%d", agreed)
+			alert.Details["responded"] = fmt.Sprintf("%d", responded)
+			wm.Blockscanner.newAlertNotify(alert)
+		}
+		return nil, wm.errorf(ErrExplorerQuorumNotReached, "explorer request: %s value: %s agreed by %d of %d responses, quorum: %d", path, value.String(), agreed, responded, quorum)
+	}
+
+	return result, nil
+}
+
+//digestUnspentResult 未花查询结果的摘要，按资产排序的余额和未花输出，数额取各资产余额的最大值
+func digestUnspentResult(result *gjson.Result) (string, decimal.Decimal) {
+
+	var (
+		assets = make([]string, 0)
+		value  = decimal.Zero
+	)
+	for _, a := range result.Get("balance").Array() {
+		unspent := NewUnspent(&a)
+		outputs := make([]string, 0)
+		if unspent.UnspentTxs != nil {
+			for _, u := range *unspent.UnspentTxs {
+				v, _ := decimal.NewFromString(u.Value)
+				outputs = append(outputs, fmt.Sprintf("%s:%d:%s", normalizeTxID(u.TxID), u.N, v.String()))
+			}
+		}
+		sort.Strings(outputs)
+		amount, _ := decimal.NewFromString(unspent.Amount)
+		if amount.GreaterThan(value) {
+			value = amount
+		}
+		assets = append(assets, strings.TrimPrefix(unspent.AssetHash, "0x")+"="+amount.String()+"["+strings.Join(outputs, ",")+"]")
+	}
+	sort.Strings(assets)
+
+	return result.Get("address").String() + "|" + strings.Join(assets, ";"), value
+}
+
+//digestTxPageResult 地址交易记录页的摘要，按txid排序的交易单和所在区块，数额取交易单各资产输出合计的最大值
+func (wm *WalletManager) digestTxPageResult(result *gjson.Result) (string, decimal.Decimal) {
+
+	var (
+		txs   = make([]string, 0)
+		value = decimal.Zero
+	)
+	for _, obj := range result.Get("items").Array() {
+		tx := wm.mapExplorerTx(&obj)
+		amounts := make(map[string]decimal.Decimal)
+		for _, out := range tx.Vouts {
+			v, _ := decimal.NewFromString(out.Value)
+			amounts[out.Asset] = amounts[out.Asset].Add(v)
+		}
+		for _, amount := range amounts {
+			if amount.GreaterThan(value) {
+				value = amount
+			}
+		}
+		txs = append(txs, fmt.Sprintf("%s@%d", normalizeTxID(tx.TxID), tx.BlockHeight))
+	}
+	sort.Strings(txs)
+
+	return fmt.Sprintf("%d|%s", result.Get("totalItems").Int(), strings.Join(txs, ",")), value
+}
+
+//digestBalanceResult 地址余额查询结果的摘要，数额取总余额
+func (wm *WalletManager) digestBalanceResult(result *gjson.Result) (string, decimal.Decimal) {
+	balance := wm.explorerMapper().MapBalance(result, wm.config().NEOAssetID)
+	value, _ := decimal.NewFromString(balance.Balance)
+	return balance.Address + "|" + balance.ConfirmBalance + "|" + balance.UnconfirmBalance + "|" + balance.Balance, value
+}
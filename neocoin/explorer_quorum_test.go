@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+//testUnspentExplorer 返回固定未花结果的浏览器
+type testUnspentExplorer struct {
+	amount string
+	down   bool
+	calls  int
+}
+
+func (e *testUnspentExplorer) Call(path string, request interface{}, method string) (*gjson.Result, error) {
+	e.calls++
+	if e.down {
+		return nil, fmt.Errorf("explorer is down")
+	}
+	raw := fmt.Sprintf(`{"address":"A","balance":[{"asset_hash":"0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b","asset_symbol":"NEO","amount":%s,"unspent":[{"txid":"0xtx1","n":0,"value":%s}]}]}`, e.amount, e.amount)
+	result := gjson.Parse(raw)
+	return &result, nil
+}
+
+func TestWalletManager_QuorumExplorerCall(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.ExplorerQuorum = 2
+	alerts := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(alerts)
+
+	primary := &testUnspentExplorer{amount: "100"}
+	agree := &testUnspentExplorer{amount: "100"}
+	disagree := &testUnspentExplorer{amount: "900"}
+	down := &testUnspentExplorer{down: true}
+	wm.SetExplorerClient(primary)
+
+	//第一个一致的备用浏览器即满足要求，不再请求后续的浏览器
+	wm.SetBackupExplorerClients([]ExplorerInterface{agree, disagree})
+	utxos, err := wm.listUnspentByExplorer(0, "A")
+	if err != nil || len(utxos) != 1 || utxos[0].NEOUnspent.Amount != "100" {
+		t.Fatalf("listUnspentByExplorer = %+v, %v", utxos, err)
+	}
+	if disagree.calls != 0 {
+		t.Errorf("quorum reached, later backups should not be requested")
+	}
+
+	//不一致或不可用时未达到要求，不一致时告警
+	wm.SetBackupExplorerClients([]ExplorerInterface{down, disagree})
+	_, err = wm.listUnspentByExplorer(0, "A")
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrExplorerQuorumNotReached {
+		t.Fatalf("quorum should not be reached, err: %v", err)
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeExplorerDisagreement || alerts.alerts[0].Details["agreed"] != "1" {
+		t.Errorf("disagreement should raise an alert, got: %+v", alerts.alerts)
+	}
+
+	//备用浏览器只是不可用时不告警
+	wm.SetBackupExplorerClients([]ExplorerInterface{down})
+	if _, err = wm.listUnspentByExplorer(0, "A"); err == nil {
+		t.Errorf("quorum should not be reached without backups")
+	}
+	if len(alerts.alerts) != 1 {
+		t.Errorf("unavailable backups should not raise an alert")
+	}
+
+	//数额未达到阈值时不检查
+	wm.Config.ExplorerQuorumThreshold = decimal.New(1000, 0)
+	calls := disagree.calls
+	wm.SetBackupExplorerClients([]ExplorerInterface{disagree})
+	if _, err = wm.listUnspentByExplorer(0, "A"); err != nil || disagree.calls != calls {
+		t.Errorf("amount under threshold should skip quorum, err: %v", err)
+	}
+
+	//交易记录按页比较
+	wm.Config.ExplorerQuorumThreshold = decimal.Zero
+	history := newTestExplorer(3)
+	same := newTestExplorer(3)
+	wm.SetExplorerClient(history)
+	wm.SetBackupExplorerClients([]ExplorerInterface{same})
+	if trxs, err := wm.getMultiAddrTransactionsByExplorer(0, 5, 0, "A"); err != nil || len(trxs) != 5 {
+		t.Fatalf("getMultiAddrTransactionsByExplorer = %d, %v", len(trxs), err)
+	}
+	missing := newTestExplorer(2)
+	wm.SetBackupExplorerClients([]ExplorerInterface{missing})
+	if _, err := wm.getMultiAddrTransactionsByExplorer(0, 5, 0, "A"); err == nil {
+		t.Errorf("history disagreement should fail")
+	}
+
+	//未启用时只请求主浏览器
+	wm.Config.ExplorerQuorum = 0
+	if _, err := wm.getMultiAddrTransactionsByExplorer(0, 5, 0, "A"); err != nil {
+		t.Errorf("quorum disabled should use primary explorer, err: %v", err)
+	}
+}
@@ -0,0 +1,508 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	explorerDefaultPageSize = 50  //地址交易记录默认分页大小
+	explorerMaxPageSize     = 500 //地址交易记录最大分页大小
+)
+
+// ExplorerBlock 浏览器接口返回的区块
+type ExplorerBlock struct {
+	Height            uint64   `json:"height"`
+	Hash              string   `json:"hash"`
+	Previousblockhash string   `json:"previousblockhash"`
+	Merkleroot        string   `json:"merkleroot"`
+	Time              uint64   `json:"time"`
+	Fork              bool     `json:"fork"`
+	TxIDs             []string `json:"txids"` //已提取的交易单
+}
+
+// ExplorerTransaction 浏览器接口返回的交易单提取结果
+type ExplorerTransaction struct {
+	TxID        string                      `json:"txid"`
+	BlockHeight uint64                      `json:"blockHeight"`
+	BlockHash   string                      `json:"blockHash"`
+	Extracts    []*openwallet.TxExtractData `json:"extracts"`
+}
+
+// AddressTxRecord 地址相关的一笔交易单
+type AddressTxRecord struct {
+	TxID        string `json:"txid"`
+	BlockHeight uint64 `json:"blockHeight"`
+	BlockHash   string `json:"blockHash"`
+	Received    string `json:"received"`
+	Sent        string `json:"sent"`
+}
+
+// AddressBalance 按本地提取结果统计的地址余额
+type AddressBalance struct {
+	Address       string `json:"address"`
+	Symbol        string `json:"symbol"`
+	Received      string `json:"received"`
+	Sent          string `json:"sent"`
+	Balance       string `json:"balance"`
+	TxCount       int    `json:"txCount"`
+	ScannedHeight uint64 `json:"scannedHeight"`
+}
+
+// GetAddressHistory 从已保存的提取结果汇总地址相关的交易单，按区块高度倒序
+func (wm *WalletManager) GetAddressHistory(address string) ([]*AddressTxRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ExtractDataRecord
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	records := make(map[string]*AddressTxRecord)
+	for _, r := range list {
+		if r.Data == nil {
+			continue
+		}
+
+		received := decimal.Zero
+		sent := decimal.Zero
+		found := false
+		for _, input := range r.Data.TxInputs {
+			if input.Address == address {
+				amount, _ := decimal.NewFromString(input.Amount)
+				sent = sent.Add(amount)
+				found = true
+			}
+		}
+		for _, output := range r.Data.TxOutputs {
+			if output.Address == address {
+				amount, _ := decimal.NewFromString(output.Amount)
+				received = received.Add(amount)
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		//同一交易单的多个来源只记录一次
+		if _, exist := records[r.TxID]; exist {
+			continue
+		}
+
+		record := &AddressTxRecord{
+			TxID:        r.TxID,
+			BlockHeight: r.BlockHeight,
+			Received:    received.String(),
+			Sent:        sent.String(),
+		}
+		if r.Data.Transaction != nil {
+			record.BlockHash = r.Data.Transaction.BlockHash
+		}
+		records[r.TxID] = record
+	}
+
+	history := make([]*AddressTxRecord, 0, len(records))
+	for _, record := range records {
+		history = append(history, record)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].BlockHeight != history[j].BlockHeight {
+			return history[i].BlockHeight > history[j].BlockHeight
+		}
+		return history[i].TxID < history[j].TxID
+	})
+
+	return history, nil
+}
+
+// ExplorerServer 内嵌的只读浏览器HTTP接口，查询扫描器保存的本地数据
+type ExplorerServer struct {
+	bs       *NEOBlockScanner
+	mux      *http.ServeMux
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewExplorerServer 创建浏览器HTTP接口
+func NewExplorerServer(bs *NEOBlockScanner) *ExplorerServer {
+	s := &ExplorerServer{
+		bs:  bs,
+		mux: http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/block/", s.handleBlock)
+	s.mux.HandleFunc("/tx/", s.handleTransaction)
+	s.mux.HandleFunc("/address/", s.handleAddress)
+	s.mux.HandleFunc("/index/", s.handleIndex)
+	s.mux.HandleFunc("/metrics/observers", s.handleObserverMetrics)
+	return s
+}
+
+// ServeHTTP 只接受GET请求
+func (s *ExplorerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeExplorerError(w, http.StatusMethodNotAllowed, "explorer api is read-only")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start 在指定地址启动监听
+func (s *ExplorerServer) Start(listen string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.server != nil {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	s.listener = l
+	s.server = &http.Server{Handler: s}
+	go func(server *http.Server) {
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			s.bs.wm.Log.Std.Error("explorer api serve failed, unexpected error: %v", err)
+		}
+	}(s.server)
+
+	s.bs.wm.Log.Std.Info("explorer api listening on %s", l.Addr().String())
+
+	return nil
+}
+
+// Addr 监听地址，未启动返回空
+func (s *ExplorerServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop 停止监听
+func (s *ExplorerServer) Stop() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.server == nil {
+		return nil
+	}
+
+	err := s.server.Close()
+	s.server = nil
+	s.listener = nil
+	return err
+}
+
+// isWatchAddress 未设置观测地址时不限制，提取结果本身只包含扫描命中的地址
+func (s *ExplorerServer) isWatchAddress(address string) bool {
+	s.bs.Mu.RLock()
+	defer s.bs.Mu.RUnlock()
+	if len(s.bs.watchAddresses) == 0 {
+		return true
+	}
+	_, ok := s.bs.watchAddresses[address]
+	return ok
+}
+
+func (s *ExplorerServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	height, hash := s.bs.wm.GetLocalNewBlock()
+	writeExplorerJSON(w, map[string]interface{}{
+		"symbol":        s.bs.wm.Symbol(),
+		"scannedHeight": height,
+		"scannedHash":   hash,
+	})
+}
+
+// handleBlock GET /block/{height}
+func (s *ExplorerServer) handleBlock(w http.ResponseWriter, r *http.Request) {
+
+	height, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/block/"), 10, 64)
+	if err != nil {
+		writeExplorerError(w, http.StatusBadRequest, "invalid block height")
+		return
+	}
+
+	block, err := s.bs.wm.GetLocalBlock(height)
+	if err == storm.ErrNotFound {
+		writeExplorerError(w, http.StatusNotFound, "block not found")
+		return
+	} else if err != nil {
+		writeExplorerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	list, err := s.bs.wm.GetExtractData(height, height)
+	if err != nil {
+		writeExplorerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txids := make([]string, 0, len(list))
+	seen := make(map[string]bool)
+	for _, record := range list {
+		if !seen[record.TxID] {
+			seen[record.TxID] = true
+			txids = append(txids, record.TxID)
+		}
+	}
+
+	writeExplorerJSON(w, &ExplorerBlock{
+		Height:            block.Height,
+		Hash:              block.Hash,
+		Previousblockhash: block.Previousblockhash,
+		Merkleroot:        block.Merkleroot,
+		Time:              block.Time,
+		Fork:              block.Fork,
+		TxIDs:             txids,
+	})
+}
+
+// handleTransaction GET /tx/{txid}
+func (s *ExplorerServer) handleTransaction(w http.ResponseWriter, r *http.Request) {
+
+	txid := strings.TrimPrefix(r.URL.Path, "/tx/")
+	list, err := s.bs.wm.GetExtractDataByTxID(txid)
+	if err != nil {
+		writeExplorerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(list) == 0 {
+		writeExplorerError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+
+	tx := &ExplorerTransaction{
+		TxID:        txid,
+		BlockHeight: list[0].BlockHeight,
+		Extracts:    make([]*openwallet.TxExtractData, 0, len(list)),
+	}
+	for _, record := range list {
+		if record.Data == nil {
+			continue
+		}
+		if record.Data.Transaction != nil {
+			tx.BlockHash = record.Data.Transaction.BlockHash
+		}
+		tx.Extracts = append(tx.Extracts, record.Data)
+	}
+
+	writeExplorerJSON(w, tx)
+}
+
+// handleAddress GET /address/{address}/balance 和 /address/{address}/history?offset=0&limit=50
+func (s *ExplorerServer) handleAddress(w http.ResponseWriter, r *http.Request) {
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/address/"), "/")
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		writeExplorerError(w, http.StatusNotFound, "unknown api path")
+		return
+	}
+	address := parts[0]
+
+	if !s.isWatchAddress(address) {
+		writeExplorerError(w, http.StatusNotFound, "address is not watched")
+		return
+	}
+
+	history, err := s.bs.wm.GetAddressHistory(address)
+	if err != nil {
+		writeExplorerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch parts[1] {
+	case "balance":
+		received := decimal.Zero
+		sent := decimal.Zero
+		for _, record := range history {
+			in, _ := decimal.NewFromString(record.Received)
+			out, _ := decimal.NewFromString(record.Sent)
+			received = received.Add(in)
+			sent = sent.Add(out)
+		}
+		height, _ := s.bs.wm.GetLocalNewBlock()
+		writeExplorerJSON(w, &AddressBalance{
+			Address:       address,
+			Symbol:        s.bs.wm.Symbol(),
+			Received:      received.String(),
+			Sent:          sent.String(),
+			Balance:       received.Sub(sent).String(),
+			TxCount:       len(history),
+			ScannedHeight: height,
+		})
+	case "history":
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if offset < 0 {
+			offset = 0
+		}
+		if limit <= 0 {
+			limit = explorerDefaultPageSize
+		} else if limit > explorerMaxPageSize {
+			limit = explorerMaxPageSize
+		}
+		page := make([]*AddressTxRecord, 0)
+		if offset < len(history) {
+			end := offset + limit
+			if end > len(history) {
+				end = len(history)
+			}
+			page = history[offset:end]
+		}
+		writeExplorerJSON(w, map[string]interface{}{
+			"address": address,
+			"total":   len(history),
+			"offset":  offset,
+			"txs":     page,
+		})
+	default:
+		writeExplorerError(w, http.StatusNotFound, "unknown api path")
+	}
+}
+
+// handleObserverMetrics GET /metrics/observers 各观察者的通知耗时，单位毫秒，按平均耗时从慢到快排序
+func (s *ExplorerServer) handleObserverMetrics(w http.ResponseWriter, r *http.Request) {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	observers := make([]map[string]interface{}, 0)
+	for _, l := range s.bs.GetObserverMetrics() {
+		observers = append(observers, map[string]interface{}{
+			"observer":   l.Observer,
+			"count":      l.Count,
+			"failed":     l.Failed,
+			"avgMs":      ms(l.Avg()),
+			"maxMs":      ms(l.Max),
+			"lastMs":     ms(l.Last),
+			"slowCount":  l.SlowCount,
+			"slowStreak": l.SlowStreak,
+			"updateAt":   l.UpdateAt,
+		})
+	}
+	writeExplorerJSON(w, map[string]interface{}{
+		"budgetMs":  ms(s.bs.wm.config().ObserverNotifyBudget),
+		"observers": observers,
+	})
+}
+
+// handleIndex 浏览器模式的全量交易索引
+// GET /index/tx/{txid}、/index/block/{height} 和 /index/address/{address}?offset=0&limit=50
+func (s *ExplorerServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+
+	if !s.bs.wm.config().ExplorerMode {
+		writeExplorerError(w, http.StatusNotFound, "explorer mode is disabled")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/index/"), "/")
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		writeExplorerError(w, http.StatusNotFound, "unknown api path")
+		return
+	}
+
+	switch parts[0] {
+	case "tx":
+		tx, err := s.bs.wm.GetIndexedTx(parts[1])
+		if err != nil {
+			writeExplorerError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if tx == nil {
+			writeExplorerError(w, http.StatusNotFound, "transaction not found")
+			return
+		}
+		writeExplorerJSON(w, tx)
+	case "block":
+		height, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			writeExplorerError(w, http.StatusBadRequest, "invalid block height")
+			return
+		}
+		txs, err := s.bs.wm.GetIndexedTxsByHeight(height)
+		if err != nil {
+			writeExplorerError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeExplorerJSON(w, map[string]interface{}{
+			"height": height,
+			"txs":    txs,
+		})
+	case "address":
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if offset < 0 {
+			offset = 0
+		}
+		if limit <= 0 {
+			limit = explorerDefaultPageSize
+		} else if limit > explorerMaxPageSize {
+			limit = explorerMaxPageSize
+		}
+		txs, total, err := s.bs.wm.GetIndexedTxsByAddress(parts[1], offset, limit)
+		if err != nil {
+			writeExplorerError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeExplorerJSON(w, map[string]interface{}{
+			"address": parts[1],
+			"total":   total,
+			"offset":  offset,
+			"txs":     txs,
+		})
+	default:
+		writeExplorerError(w, http.StatusNotFound, "unknown api path")
+	}
+}
+
+func writeExplorerJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeExplorerError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
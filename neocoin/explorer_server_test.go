@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestExplorerServer(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	address := "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	wm.SaveLocalBlock(&Block{Hash: "0xblock10", Height: 10})
+	wm.SaveLocalBlock(&Block{Hash: "0xblock11", Height: 11})
+	wm.SaveLocalNewBlock(11, "0xblock11")
+
+	deposit := openwallet.NewBlockExtractData()
+	deposit.Transaction = &openwallet.Transaction{TxID: "0xtx1", BlockHeight: 10, BlockHash: "0xblock10"}
+	deposit.TxOutputs = []*openwallet.TxOutPut{{Recharge: openwallet.Recharge{TxID: "0xtx1", Address: address, Amount: "10"}}}
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": deposit})
+
+	withdraw := openwallet.NewBlockExtractData()
+	withdraw.Transaction = &openwallet.Transaction{TxID: "0xtx2", BlockHeight: 11, BlockHash: "0xblock11"}
+	withdraw.TxInputs = []*openwallet.TxInput{{Recharge: openwallet.Recharge{TxID: "0xtx2", Address: address, Amount: "10"}}}
+	withdraw.TxOutputs = []*openwallet.TxOutPut{{Recharge: openwallet.Recharge{TxID: "0xtx2", Address: address, Amount: "6"}}}
+	wm.SaveExtractData(11, map[string]*openwallet.TxExtractData{"account": withdraw})
+
+	server := httptest.NewServer(NewExplorerServer(NewNEOBlockScanner(wm)))
+	defer server.Close()
+
+	get := func(path string, v interface{}) int {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("get %s failed, unexpected error: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+
+	var block ExplorerBlock
+	if code := get("/block/10", &block); code != http.StatusOK || block.Hash != "0xblock10" || len(block.TxIDs) != 1 || block.TxIDs[0] != "0xtx1" {
+		t.Errorf("unexpected block: %d, %+v", code, block)
+	}
+	if code := get("/block/12", nil); code != http.StatusNotFound {
+		t.Errorf("missing block should be not found, code: %d", code)
+	}
+
+	var tx ExplorerTransaction
+	if code := get("/tx/0xtx2", &tx); code != http.StatusOK || tx.BlockHeight != 11 || tx.BlockHash != "0xblock11" || len(tx.Extracts) != 1 {
+		t.Errorf("unexpected transaction: %d, %+v", code, tx)
+	}
+	if code := get("/tx/0xtx3", nil); code != http.StatusNotFound {
+		t.Errorf("missing transaction should be not found, code: %d", code)
+	}
+
+	var balance AddressBalance
+	if code := get("/address/"+address+"/balance", &balance); code != http.StatusOK || balance.Balance != "6" || balance.TxCount != 2 || balance.ScannedHeight != 11 {
+		t.Errorf("unexpected balance: %d, %+v", code, balance)
+	}
+
+	var history struct {
+		Total int                `json:"total"`
+		Txs   []*AddressTxRecord `json:"txs"`
+	}
+	if code := get("/address/"+address+"/history?limit=1", &history); code != http.StatusOK || history.Total != 2 || len(history.Txs) != 1 || history.Txs[0].TxID != "0xtx2" || history.Txs[0].Sent != "10" {
+		t.Errorf("unexpected history: %d, %+v", code, history)
+	}
+
+	resp, _ := http.Post(server.URL+"/block/10", "application/json", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("explorer api should be read-only, code: %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestExplorerServer_WatchAddress(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := NewNEOBlockScanner(wm)
+	bs.SetWatchAddressFilter("AGofsxAUDwt52KjaB664GYsqVAkULYvKNt")
+
+	s := NewExplorerServer(bs)
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Errorf("start explorer api failed, unexpected error: %v", err)
+		return
+	}
+	defer s.Stop()
+
+	resp, err := http.Get("http://" + s.Addr() + "/address/AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y/balance")
+	if err != nil {
+		t.Errorf("get balance failed, unexpected error: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unwatched address should be not found, code: %d", resp.StatusCode)
+	}
+}
@@ -85,7 +85,7 @@ func TestGetBalanceByExplorer(t *testing.T) {
 }
 
 func TestGetMultiAddrTransactionsByExplorer(t *testing.T) {
-	list, err := tw.getMultiAddrTransactionsByExplorer(0, 15, "2N7Mh6PLX39japSF76r2MAf7wT7WKU5TdpK")
+	list, err := tw.getMultiAddrTransactionsByExplorer(0, 15, 0, "2N7Mh6PLX39japSF76r2MAf7wT7WKU5TdpK")
 	if err != nil {
 		t.Errorf("getMultiAddrTransactionsByExplorer failed unexpected error: %v\n", err)
 		return
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//ExtractDataChunkParam 分段通知时交易单ExtParam中的段序号字段，从1开始
+	ExtractDataChunkParam = "chunk"
+	//ExtractDataChunksParam 分段通知时交易单ExtParam中的总段数字段
+	ExtractDataChunksParam = "chunks"
+	//ExtractDataChunkMoreParam 分段通知时交易单ExtParam中的后续段标记，最后一段为false
+	ExtractDataChunkMoreParam = "chunkMore"
+
+	//chunkParamsBytes 分段字段在交易单json中占用大小的估计
+	chunkParamsBytes = 64
+)
+
+//scanMatch 交易输入输出的地址匹配结果
+type scanMatch struct {
+	sourceKey string
+	ok        bool
+}
+
+//matchScanAddresses 匹配n个输入或输出的地址，返回每项的匹配结果、各sourceKey的匹配数量和匹配总数，没有地址的不匹配
+func matchScanAddresses(n int, addr func(i int) string, scanAddressFunc openwallet.BlockScanAddressFunc) ([]scanMatch, map[string]int, int) {
+
+	var (
+		matches = make([]scanMatch, n)
+		counts  = make(map[string]int)
+		matched = 0
+	)
+	for i := 0; i < n; i++ {
+		a := addr(i)
+		if len(a) == 0 {
+			continue
+		}
+		if sourceKey, ok := scanAddressFunc(a); ok {
+			matches[i] = scanMatch{sourceKey: sourceKey, ok: true}
+			counts[sourceKey]++
+			matched++
+		}
+	}
+	return matches, counts, matched
+}
+
+//reserveExtractData 按各sourceKey的匹配数量创建提取结果并预留输入或输出列表的容量
+func reserveExtractData(result *ExtractResult, counts map[string]int, inputs bool) {
+	for sourceKey, n := range counts {
+		ed := result.extractData[sourceKey]
+		if ed == nil {
+			ed = openwallet.NewBlockExtractData()
+			result.extractData[sourceKey] = ed
+		}
+		if inputs && cap(ed.TxInputs)-len(ed.TxInputs) < n {
+			list := make([]*openwallet.TxInput, len(ed.TxInputs), len(ed.TxInputs)+n)
+			copy(list, ed.TxInputs)
+			ed.TxInputs = list
+		}
+		if !inputs && cap(ed.TxOutputs)-len(ed.TxOutputs) < n {
+			list := make([]*openwallet.TxOutPut, len(ed.TxOutputs), len(ed.TxOutputs)+n)
+			copy(list, ed.TxOutputs)
+			ed.TxOutputs = list
+		}
+	}
+}
+
+//ExtractDataChunk 读取分段通知的段序号和总段数，未分段时返回0, 0，
+//同一交易单的各段按序号依次通知，消费者按输入输出的Sid合并，或收齐后用MergeExtractDataChunks合并
+func ExtractDataChunk(data *openwallet.TxExtractData) (int, int) {
+	if data == nil || data.Transaction == nil || len(data.Transaction.ExtParam) == 0 {
+		return 0, 0
+	}
+	ext := data.Transaction.GetExtParam()
+	return int(ext.Get(ExtractDataChunkParam).Int()), int(ext.Get(ExtractDataChunksParam).Int())
+}
+
+//ExtractDataHasMoreChunks 分段通知后面是否还有同一交易单的段，未分段或最后一段时返回false
+func ExtractDataHasMoreChunks(data *openwallet.TxExtractData) bool {
+	if data == nil || data.Transaction == nil || len(data.Transaction.ExtParam) == 0 {
+		return false
+	}
+	return data.Transaction.GetExtParam().Get(ExtractDataChunkMoreParam).Bool()
+}
+
+//MergeExtractDataChunks 合并同一交易单收齐的全部分段，段的顺序不限，返回去掉分段字段的完整提取结果
+func MergeExtractDataChunks(chunks []*openwallet.TxExtractData) (*openwallet.TxExtractData, error) {
+
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no chunks to merge")
+	}
+
+	ordered := make([]*openwallet.TxExtractData, len(chunks))
+	for _, chunk := range chunks {
+		index, total := ExtractDataChunk(chunk)
+		if index == 0 {
+			return nil, fmt.Errorf("extract data is not a chunk")
+		}
+		if total != len(chunks) || index > total {
+			return nil, fmt.Errorf("chunk: %d of %d does not match %d received chunks", index, total, len(chunks))
+		}
+		if ordered[index-1] != nil {
+			return nil, fmt.Errorf("duplicate chunk: %d", index)
+		}
+		if chunk.Transaction.TxID != chunks[0].Transaction.TxID {
+			return nil, fmt.Errorf("chunk: %d belongs to transaction: %s, expected: %s", index, chunk.Transaction.TxID, chunks[0].Transaction.TxID)
+		}
+		ordered[index-1] = chunk
+	}
+
+	tx := *ordered[0].Transaction
+	ext := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(tx.ExtParam), &ext); err != nil {
+		return nil, err
+	}
+	delete(ext, ExtractDataChunkParam)
+	delete(ext, ExtractDataChunksParam)
+	delete(ext, ExtractDataChunkMoreParam)
+	tx.ExtParam = ""
+	if len(ext) > 0 {
+		raw, _ := json.Marshal(ext)
+		tx.ExtParam = string(raw)
+	}
+
+	merged := openwallet.NewBlockExtractData()
+	merged.Transaction = &tx
+	for _, chunk := range ordered {
+		merged.TxInputs = append(merged.TxInputs, chunk.TxInputs...)
+		merged.TxOutputs = append(merged.TxOutputs, chunk.TxOutputs...)
+	}
+	return merged, nil
+}
+
+//splitExtractData 输入输出总数超过size或json大小估计超过maxBytes时，按输入在前、输出在后的顺序分段，
+//每段最多size项且不超过maxBytes，单项超过maxBytes时单独成段，第一段的交易单保留完整的From和To，
+//后续段省去以减小通知内容，size和maxBytes不大于0时不检查对应的限制
+func splitExtractData(data *openwallet.TxExtractData, size, maxBytes int) []*openwallet.TxExtractData {
+
+	whole := []*openwallet.TxExtractData{data}
+	if data == nil || data.Transaction == nil || (size <= 0 && maxBytes <= 0) {
+		return whole
+	}
+
+	var (
+		inputs  = data.TxInputs
+		outputs = data.TxOutputs
+		count   = len(inputs) + len(outputs)
+		items   = make([]int, count) //每项的json大小
+		sum     = 0
+	)
+	if maxBytes > 0 {
+		for i, in := range inputs {
+			raw, _ := json.Marshal(in)
+			items[i] = len(raw)
+			sum += len(raw)
+		}
+		for i, out := range outputs {
+			raw, _ := json.Marshal(out)
+			items[len(inputs)+i] = len(raw)
+			sum += len(raw)
+		}
+	}
+
+	head, rest := 0, 0 //第一段和后续段交易单的json大小
+	if maxBytes > 0 {
+		raw, _ := json.Marshal(data.Transaction)
+		head = len(raw) + chunkParamsBytes
+		trimmed := *data.Transaction
+		trimmed.From, trimmed.To = nil, nil
+		raw, _ = json.Marshal(&trimmed)
+		rest = len(raw) + chunkParamsBytes
+	}
+
+	if (size <= 0 || count <= size) && (maxBytes <= 0 || head+sum <= maxBytes) {
+		return whole
+	}
+
+	//按数量和大小确定每段的结束位置
+	ends := make([]int, 0)
+	n, bytes, base := 0, 0, head
+	for k := 0; k < count; k++ {
+		if n > 0 && ((size > 0 && n >= size) || (maxBytes > 0 && base+bytes+items[k] > maxBytes)) {
+			ends = append(ends, k)
+			n, bytes, base = 0, 0, rest
+		}
+		n++
+		bytes += items[k]
+	}
+	ends = append(ends, count)
+	if len(ends) == 1 {
+		return whole
+	}
+
+	chunks := make([]*openwallet.TxExtractData, 0, len(ends))
+	begin := 0
+	for i, end := range ends {
+		tx := *data.Transaction
+		if i > 0 {
+			tx.From = nil
+			tx.To = nil
+		}
+		tx.SetExtParam(ExtractDataChunkParam, i+1)
+		tx.SetExtParam(ExtractDataChunksParam, len(ends))
+		tx.SetExtParam(ExtractDataChunkMoreParam, i+1 < len(ends))
+
+		//[begin, end)中前面的是输入，超出输入数量的是输出
+		inBegin, inEnd := begin, end
+		if inBegin > len(inputs) {
+			inBegin = len(inputs)
+		}
+		if inEnd > len(inputs) {
+			inEnd = len(inputs)
+		}
+		chunk := &openwallet.TxExtractData{Transaction: &tx}
+		chunk.TxInputs = inputs[inBegin:inEnd]
+		chunk.TxOutputs = outputs[begin-inBegin : end-inEnd]
+		chunks = append(chunks, chunk)
+		begin = end
+	}
+	return chunks
+}
+
+//notifyObserverChunks 按NotifyChunkSize和NotifyChunkBytes分段通知观察者，任一段失败即返回错误，重新投递时所有段都重新通知
+func (bs *NEOBlockScanner) notifyObserverChunks(o openwallet.BlockScanNotificationObject, sourceKey string, data *openwallet.TxExtractData) error {
+	cfg := bs.wm.config()
+	for _, chunk := range splitExtractData(data, cfg.NotifyChunkSize, cfg.NotifyChunkBytes) {
+		start := time.Now()
+		err := o.BlockExtractDataNotify(sourceKey, chunk)
+		bs.recordObserverLatency(notifyObserverName(o), time.Since(start), err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_ExtractLargeTransaction(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.NotifyChunkSize = 1000
+
+	bs := wm.Blockscanner
+
+	//归集交易：3000个输入来自账户地址，2个输出
+	trx := &Transaction{TxID: testHash("consolidate"), BlockHash: testHash("block10"), BlockHeight: 10}
+	for i := 0; i < 3000; i++ {
+		trx.Vins = append(trx.Vins, &Vin{TxID: testHash(fmt.Sprintf("fund%d", i)), Addr: fmt.Sprintf("A%d", i), Value: "1"})
+	}
+	trx.Vouts = []*Vout{{N: 0, Addr: "hot", Value: "2999"}, {N: 1, Addr: "other", Value: "0.5"}}
+
+	scanAddressFunc := func(address string) (string, bool) {
+		if address == "hot" {
+			return "hot", true
+		}
+		return "account", address != "other"
+	}
+	result := &ExtractResult{
+		TxID:            trx.TxID,
+		extractData:     make(map[string]*openwallet.TxExtractData),
+		extractOmniData: make(map[string]*openwallet.TxExtractData),
+	}
+	bs.extractTransaction(trx, result, scanAddressFunc)
+	if !result.Success || len(result.extractData) != 2 {
+		t.Fatalf("extractTransaction failed, extract data: %d", len(result.extractData))
+	}
+
+	account := result.extractData["account"]
+	if len(account.TxInputs) != 3000 || cap(account.TxInputs) != 3000 || len(account.TxOutputs) != 0 {
+		t.Fatalf("account inputs should be preallocated, len: %d, cap: %d", len(account.TxInputs), cap(account.TxInputs))
+	}
+	for i, input := range account.TxInputs {
+		if input.SourceTxID != testHash(fmt.Sprintf("fund%d", i)) || input.Address != fmt.Sprintf("A%d", i) {
+			t.Fatalf("input: %d extracted in wrong order, got: %s", i, input.Address)
+		}
+	}
+	hot := result.extractData["hot"]
+	if len(hot.TxOutputs) != 1 || hot.TxOutputs[0].Amount != "2999" || len(hot.Transaction.From) != 3000 {
+		t.Fatalf("hot output should be extracted, got: %+v", hot.TxOutputs)
+	}
+	if fees := hot.Transaction.Fees; fees != "0.50000000" {
+		t.Errorf("fees should be counted from all inputs and outputs, got: %s", fees)
+	}
+
+	//超过分段数量的通知按序分段，后续段不带From和To
+	observer := &testReplayObserver{}
+	if err := bs.notifyObserver(observer, "account", account); err != nil {
+		t.Fatalf("notifyObserver failed, unexpected error: %v", err)
+	}
+	if len(observer.data) != 3 {
+		t.Fatalf("notification should be split into 3 chunks, got: %d", len(observer.data))
+	}
+	total := 0
+	for i, chunk := range observer.data {
+		index, chunks := ExtractDataChunk(chunk)
+		if index != i+1 || chunks != 3 || chunk.Transaction.TxID != trx.TxID {
+			t.Errorf("chunk: %d has wrong chunk params: %d/%d", i, index, chunks)
+		}
+		if (i == 0) != (len(chunk.Transaction.From) == 3000) {
+			t.Errorf("chunk: %d from list, got: %d", i, len(chunk.Transaction.From))
+		}
+		total += len(chunk.TxInputs) + len(chunk.TxOutputs)
+	}
+	if total != 3000 || observer.data[2].TxInputs[999].Address != "A2999" {
+		t.Errorf("chunks should carry all inputs in order, got: %d", total)
+	}
+	if index, _ := ExtractDataChunk(account); index != 0 {
+		t.Errorf("original extract data should not be modified")
+	}
+
+	//未超过分段数量或不分段时整体通知
+	observer = &testReplayObserver{}
+	bs.notifyObserver(observer, "hot", hot)
+	wm.Config.NotifyChunkSize = 0
+	wm.Config.NotifyChunkBytes = 0
+	bs.notifyObserver(observer, "account", account)
+	if len(observer.data) != 2 || observer.data[1] != account {
+		t.Errorf("notification should not be split, got: %d", len(observer.data))
+	}
+
+	//输入输出混合分段
+	mixed := &openwallet.TxExtractData{Transaction: &openwallet.Transaction{TxID: "mixed"}}
+	for i := 0; i < 3; i++ {
+		mixed.TxInputs = append(mixed.TxInputs, &openwallet.TxInput{})
+		mixed.TxOutputs = append(mixed.TxOutputs, &openwallet.TxOutPut{})
+	}
+	chunks := splitExtractData(mixed, 4, 0)
+	if len(chunks) != 2 || len(chunks[0].TxInputs) != 3 || len(chunks[0].TxOutputs) != 1 || len(chunks[1].TxInputs) != 0 || len(chunks[1].TxOutputs) != 2 {
+		t.Errorf("mixed chunks split wrong, got: %d", len(chunks))
+	}
+}
+
+func TestNEOBlockScanner_NotifyChunkBytes(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.NotifyChunkSize = 0
+	wm.Config.NotifyChunkBytes = 4096
+	bs := wm.Blockscanner
+
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: testHash("payout"), BlockHeight: 10}
+	data.Transaction.SetExtParam(ExtractDataSequenceParam, 7)
+	for i := 0; i < 100; i++ {
+		out := &openwallet.TxOutPut{}
+		out.TxID = data.Transaction.TxID
+		out.Address = fmt.Sprintf("A%d", i)
+		out.Amount = "1"
+		out.Index = uint64(i)
+		data.TxOutputs = append(data.TxOutputs, out)
+	}
+
+	observer := &testReplayObserver{}
+	if err := bs.notifyObserver(observer, "account", data); err != nil {
+		t.Fatalf("notifyObserver failed, unexpected error: %v", err)
+	}
+	if len(observer.data) < 2 {
+		t.Fatalf("notification over size limit should be split, got: %d", len(observer.data))
+	}
+
+	//每段不超过大小限制，除最后一段外都有后续段标记
+	for i, chunk := range observer.data {
+		raw, _ := json.Marshal(chunk)
+		if len(raw) > wm.Config.NotifyChunkBytes {
+			t.Errorf("chunk: %d size: %d exceeds limit", i, len(raw))
+		}
+		if more := ExtractDataHasMoreChunks(chunk); more != (i < len(observer.data)-1) {
+			t.Errorf("chunk: %d continuation marker: %v", i, more)
+		}
+		if ExtractDataSequence(chunk) != 7 {
+			t.Errorf("chunk: %d should keep extract data sequence", i)
+		}
+	}
+
+	//倒序合并还原完整的提取结果
+	reversed := make([]*openwallet.TxExtractData, 0, len(observer.data))
+	for i := len(observer.data) - 1; i >= 0; i-- {
+		reversed = append(reversed, observer.data[i])
+	}
+	merged, err := MergeExtractDataChunks(reversed)
+	if err != nil {
+		t.Fatalf("MergeExtractDataChunks failed, unexpected error: %v", err)
+	}
+	if len(merged.TxOutputs) != 100 || merged.TxOutputs[99].Address != "A99" || ExtractDataSequence(merged) != 7 {
+		t.Errorf("merged extract data should contain all outputs in order, got: %d", len(merged.TxOutputs))
+	}
+	if index, _ := ExtractDataChunk(merged); index != 0 || ExtractDataHasMoreChunks(merged) {
+		t.Errorf("merged extract data should not carry chunk params")
+	}
+	if _, err := MergeExtractDataChunks(observer.data[1:]); err == nil {
+		t.Errorf("incomplete chunks should not be merged")
+	}
+	if _, err := MergeExtractDataChunks([]*openwallet.TxExtractData{data}); err == nil {
+		t.Errorf("unchunked extract data should not be merged")
+	}
+
+	//单项超过大小限制时单独成段
+	if chunks := splitExtractData(data, 0, 1); len(chunks) != 100 {
+		t.Errorf("oversized items should be delivered one per chunk, got: %d", len(chunks))
+	}
+}
@@ -0,0 +1,314 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//ExtractDataSequenceParam 提取结果交易单ExtParam中的序号字段
+	ExtractDataSequenceParam = "sequence"
+)
+
+//ExtractDataRecord 已通知的提取结果，用于观察者离线后补发
+type ExtractDataRecord struct {
+	ID            string `storm:"id"`
+	BlockHeight   uint64 `storm:"index"`
+	TxID          string
+	SourceKey     string
+	Sequence      uint64 //sourceKey内单调递增的序号，从1开始
+	Data          *openwallet.TxExtractData
+	SchemaVersion int //数据结构版本
+}
+
+//sourceKeySequence sourceKey最近分配的提取结果序号
+type sourceKeySequence struct {
+	SourceKey string `storm:"id"`
+	Sequence  uint64
+}
+
+func NewExtractDataRecord(height uint64, sourceKey string, data *openwallet.TxExtractData) *ExtractDataRecord {
+	obj := ExtractDataRecord{}
+	obj.BlockHeight = height
+	obj.SourceKey = sourceKey
+	obj.Data = data
+	obj.SchemaVersion = SchemaVersion
+	if data != nil && data.Transaction != nil {
+		obj.TxID = data.Transaction.TxID
+	}
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%d_%s_%s", height, obj.TxID, sourceKey))))
+	return &obj
+}
+
+//SaveExtractData 保存提取结果到本地
+func (wm *WalletManager) SaveExtractData(height uint64, extractData map[string]*openwallet.TxExtractData) error {
+	return wm.saveBlockExtractData(height, []map[string]*openwallet.TxExtractData{extractData})
+}
+
+//saveBlockExtractData 在一个事务中保存区块内全部交易的提取结果
+func (wm *WalletManager) saveBlockExtractData(height uint64, batch []map[string]*openwallet.TxExtractData) error {
+
+	//未确认的交易不保存
+	if height == 0 {
+		return nil
+	}
+
+	empty := true
+	for _, extractData := range batch {
+		if len(extractData) > 0 {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return nil
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, extractData := range batch {
+		for key, data := range extractData {
+			record := NewExtractDataRecord(height, key, data)
+
+			//重扫同一交易时沿用已分配的序号，消费者可按序号去重
+			var exist ExtractDataRecord
+			err = tx.One("ID", record.ID, &exist)
+			if err == nil && exist.Sequence > 0 {
+				record.Sequence = exist.Sequence
+			} else if err != nil && err != storm.ErrNotFound {
+				return err
+			} else {
+				record.Sequence, err = nextSourceKeySequence(tx, key)
+				if err != nil {
+					return err
+				}
+			}
+
+			if data != nil && data.Transaction != nil {
+				data.Transaction.SetExtParam(ExtractDataSequenceParam, record.Sequence)
+			}
+
+			err = tx.Save(record)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+//nextSourceKeySequence 分配sourceKey的下一个提取结果序号
+func nextSourceKeySequence(tx storm.Node, sourceKey string) (uint64, error) {
+	var s sourceKeySequence
+	err := tx.One("SourceKey", sourceKey, &s)
+	if err == storm.ErrNotFound {
+		s.SourceKey = sourceKey
+	} else if err != nil {
+		return 0, err
+	}
+
+	s.Sequence++
+	err = tx.Save(&s)
+	if err != nil {
+		return 0, err
+	}
+	return s.Sequence, nil
+}
+
+//ExtractDataSequence 读取通知的提取结果在sourceKey内的序号，未分配序号（如未确认交易）时返回0，
+//消费者发现序号不连续时可用ReplayExtractDataBySequence补发缺失的部分
+func ExtractDataSequence(data *openwallet.TxExtractData) uint64 {
+	if data == nil || data.Transaction == nil || len(data.Transaction.ExtParam) == 0 {
+		return 0
+	}
+	return data.Transaction.GetExtParam().Get(ExtractDataSequenceParam).Uint()
+}
+
+//GetLastExtractDataSequence 获取sourceKey最近分配的提取结果序号，没有记录时为0
+func (wm *WalletManager) GetLastExtractDataSequence(sourceKey string) (uint64, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var s sourceKeySequence
+	err = db.One("SourceKey", sourceKey, &s)
+	if err == storm.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return s.Sequence, nil
+}
+
+//GetExtractDataBySequence 获取sourceKey序号范围内已保存的提取结果，按序号升序
+func (wm *WalletManager) GetExtractDataBySequence(sourceKey string, fromSeq, toSeq uint64) ([]*ExtractDataRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ExtractDataRecord
+	err = db.Select(q.Eq("SourceKey", sourceKey), q.Gte("Sequence", fromSeq), q.Lte("Sequence", toSeq)).OrderBy("Sequence").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//GetExtractData 获取区块高度范围内已保存的提取结果
+func (wm *WalletManager) GetExtractData(fromHeight, toHeight uint64) ([]*ExtractDataRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ExtractDataRecord
+	err = db.Select(q.Gte("BlockHeight", fromHeight), q.Lte("BlockHeight", toHeight)).OrderBy("BlockHeight").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//GetExtractDataByTxID 获取交易单已保存的提取结果
+func (wm *WalletManager) GetExtractDataByTxID(txid string) ([]*ExtractDataRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ExtractDataRecord
+	err = db.Select(q.Eq("TxID", txid)).Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//PruneExtractData 删除早于本地高度减去retentionBlocks的提取结果，返回删除的数量。
+//sourceKey的序号不受影响，被删除范围内的提取结果不能再补发
+func (wm *WalletManager) PruneExtractData(retentionBlocks uint64) (int, error) {
+
+	localHeight, _ := wm.GetLocalNewBlock()
+	if retentionBlocks == 0 || localHeight <= retentionBlocks {
+		return 0, nil
+	}
+	before := localHeight - retentionBlocks
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	count, err := db.Select(q.Lt("BlockHeight", before)).Count(&ExtractDataRecord{})
+	if err != nil || count == 0 {
+		return 0, err
+	}
+
+	err = db.Select(q.Lt("BlockHeight", before)).Delete(&ExtractDataRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+//ReplayExtractData 把已保存的提取结果重新推送给指定观察者，不重新提取，不影响其他观察者
+func (bs *NEOBlockScanner) ReplayExtractData(fromHeight, toHeight uint64, observer openwallet.BlockScanNotificationObject) error {
+
+	if observer == nil {
+		return fmt.Errorf("observer is nil")
+	}
+
+	if fromHeight > toHeight {
+		return bs.wm.errorf(ErrBlockHeightInvalid, "from height: %d is greater than to height: %d", fromHeight, toHeight)
+	}
+
+	list, err := bs.wm.GetExtractData(fromHeight, toHeight)
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "get extract data failed, unexpected error: %v", err)
+	}
+
+	for _, r := range list {
+		err = bs.notifyObserver(observer, r.SourceKey, r.Data)
+		if err != nil {
+			return fmt.Errorf("replay extract data on height: %d, txid: %s failed, unexpected error: %v", r.BlockHeight, r.TxID, err)
+		}
+	}
+
+	bs.wm.Log.Std.Info("block scanner replay %d extract data from height: %d to height: %d", len(list), fromHeight, toHeight)
+
+	return nil
+}
+
+//ReplayExtractDataBySequence 把sourceKey序号范围内已保存的提取结果重新推送给指定观察者，用于补发消费者发现的序号缺口
+func (bs *NEOBlockScanner) ReplayExtractDataBySequence(sourceKey string, fromSeq, toSeq uint64, observer openwallet.BlockScanNotificationObject) error {
+
+	if observer == nil {
+		return fmt.Errorf("observer is nil")
+	}
+
+	if fromSeq > toSeq {
+		return fmt.Errorf("from sequence: %d is greater than to sequence: %d", fromSeq, toSeq)
+	}
+
+	list, err := bs.wm.GetExtractDataBySequence(sourceKey, fromSeq, toSeq)
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "get extract data failed, unexpected error: %v", err)
+	}
+
+	for _, r := range list {
+		err = bs.notifyObserver(observer, r.SourceKey, r.Data)
+		if err != nil {
+			return fmt.Errorf("replay extract data of source key: %s, sequence: %d failed, unexpected error: %v", r.SourceKey, r.Sequence, err)
+		}
+	}
+
+	bs.wm.Log.Std.Info("block scanner replay %d extract data of source key: %s from sequence: %d to sequence: %d", len(list), sourceKey, fromSeq, toSeq)
+
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sort"
+)
+
+//Feature 可按部署逐步开启、运行时可立即关闭的高风险子系统
+type Feature string
+
+const (
+	FeatureRawBlockParse       Feature = "rawBlockParse"       //使用getblock返回的交易详情提取，不再逐笔查询交易单，核心节点模式有效
+	FeatureUTXOIndex           Feature = "utxoIndex"           //记录未花输出历史，还需要开启UTXOHistory
+	FeatureWebsocketSubscriber Feature = "websocketSubscriber" //浏览器模式通过socketIO订阅内存池交易
+)
+
+const (
+	FeatureSourceDefault = "default" //未配置，使用默认状态
+	FeatureSourceConfig  = "config"  //配置文件featureFlags指定
+	FeatureSourceRuntime = "runtime" //运行时SetFeatureFlag指定
+)
+
+//featureDefaults 未配置时的状态，已有的子系统默认开启，保持原有行为，新的子系统默认关闭
+var featureDefaults = map[Feature]bool{
+	FeatureRawBlockParse:       false,
+	FeatureUTXOIndex:           true,
+	FeatureWebsocketSubscriber: true,
+}
+
+//FeatureFlag 功能开关的当前状态
+type FeatureFlag struct {
+	Feature Feature `json:"feature"`
+	Enabled bool    `json:"enabled"`
+	Source  string  `json:"source"`
+}
+
+//isKnownFeature 是否为支持开关的功能
+func isKnownFeature(name string) bool {
+	_, ok := featureDefaults[Feature(name)]
+	return ok
+}
+
+//featureFlag 功能开关状态，运行时设置优先于配置，配置优先于默认状态
+func (wm *WalletManager) featureFlag(feature Feature) *FeatureFlag {
+
+	flag := &FeatureFlag{Feature: feature, Enabled: featureDefaults[feature], Source: FeatureSourceDefault}
+
+	if enabled, ok := wm.config().FeatureFlags[string(feature)]; ok {
+		flag.Enabled, flag.Source = enabled, FeatureSourceConfig
+	}
+
+	wm.featureMu.RLock()
+	defer wm.featureMu.RUnlock()
+	if enabled, ok := wm.featureOverrides[feature]; ok {
+		flag.Enabled, flag.Source = enabled, FeatureSourceRuntime
+	}
+
+	return flag
+}
+
+//FeatureEnabled 功能是否开启
+func (wm *WalletManager) FeatureEnabled(feature Feature) bool {
+	return wm.featureFlag(feature).Enabled
+}
+
+//FeatureFlags 全部功能开关的当前状态，按名称排序
+func (wm *WalletManager) FeatureFlags() []*FeatureFlag {
+	flags := make([]*FeatureFlag, 0, len(featureDefaults))
+	for feature := range featureDefaults {
+		flags = append(flags, wm.featureFlag(feature))
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Feature < flags[j].Feature
+	})
+	return flags
+}
+
+//SetFeatureFlag 运行时开启或关闭功能，不需要重新部署，立即生效，重启后恢复为配置的状态。
+//关闭utxoIndex期间的未花输出历史不记录，重新开启后需要用RebuildLocalData补齐
+func (wm *WalletManager) SetFeatureFlag(feature Feature, enabled bool) error {
+
+	if !isKnownFeature(string(feature)) {
+		return wm.errorf(ErrFeatureUnknown, "feature: %s is unknown", feature)
+	}
+
+	wm.featureMu.Lock()
+	if wm.featureOverrides == nil {
+		wm.featureOverrides = make(map[Feature]bool)
+	}
+	wm.featureOverrides[feature] = enabled
+	wm.featureMu.Unlock()
+
+	wm.Log.Std.Notice("feature: %s is set to enabled: %v at runtime", feature, enabled)
+	wm.applyFeatureFlag(feature)
+
+	return nil
+}
+
+//ResetFeatureFlag 清除运行时设置，恢复为配置或默认的状态
+func (wm *WalletManager) ResetFeatureFlag(feature Feature) error {
+
+	if !isKnownFeature(string(feature)) {
+		return wm.errorf(ErrFeatureUnknown, "feature: %s is unknown", feature)
+	}
+
+	wm.featureMu.Lock()
+	delete(wm.featureOverrides, feature)
+	wm.featureMu.Unlock()
+
+	wm.Log.Std.Notice("feature: %s runtime setting is reset, enabled: %v", feature, wm.FeatureEnabled(feature))
+	wm.applyFeatureFlag(feature)
+
+	return nil
+}
+
+//applyFeatureFlag 开关变化后立即作用于运行中的子系统，其他功能在下次使用时读取开关
+func (wm *WalletManager) applyFeatureFlag(feature Feature) {
+	if feature == FeatureWebsocketSubscriber && wm.Blockscanner != nil {
+		wm.Blockscanner.syncSocketIO()
+	}
+}
+
+//utxoHistoryEnabled 配置开启且功能开关未关闭时记录未花输出历史
+func (wm *WalletManager) utxoHistoryEnabled() bool {
+	return wm.config().UTXOHistory && wm.FeatureEnabled(FeatureUTXOIndex)
+}
+
+//blockTxDetails 开启rawBlockParse时，返回getblock结果中已解析的交易详情，按txid索引，
+//详情与交易列表不一致时返回nil，逐笔查询交易单
+func (bs *NEOBlockScanner) blockTxDetails(block *Block) map[string]*Transaction {
+
+	if bs.wm.config().RPCServerType != RPCServerCore || !bs.wm.FeatureEnabled(FeatureRawBlockParse) {
+		return nil
+	}
+
+	if !block.isVerbose || len(block.TxDetails) != len(block.Tx) {
+		return nil
+	}
+
+	details := make(map[string]*Transaction, len(block.TxDetails))
+	for i, trx := range block.TxDetails {
+		if trx == nil || trx.TxID != block.Tx[i] {
+			return nil
+		}
+		//区块中的交易详情没有所在区块信息
+		if trx.BlockHeight == 0 {
+			trx.BlockHeight = block.Height
+			trx.BlockHash = block.Hash
+		}
+		if trx.Blocktime == 0 {
+			trx.Blocktime = int64(block.Time)
+		}
+		details[trx.TxID] = trx
+	}
+
+	return details
+}
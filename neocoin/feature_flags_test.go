@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_FeatureFlags(t *testing.T) {
+	wm := NewWalletManager()
+
+	if wm.FeatureEnabled(FeatureRawBlockParse) || !wm.FeatureEnabled(FeatureUTXOIndex) || !wm.FeatureEnabled(FeatureWebsocketSubscriber) {
+		t.Fatalf("new features should be off and existing subsystems on by default")
+	}
+
+	//配置优先于默认状态，运行时设置优先于配置
+	wm.Config.FeatureFlags[string(FeatureUTXOIndex)] = false
+	wm.Config.UTXOHistory = true
+	if wm.FeatureEnabled(FeatureUTXOIndex) || wm.utxoHistoryEnabled() {
+		t.Errorf("utxo index should be disabled by config")
+	}
+	if err := wm.SetFeatureFlag(FeatureUTXOIndex, true); err != nil || !wm.utxoHistoryEnabled() {
+		t.Errorf("utxo index should be enabled at runtime, err: %v", err)
+	}
+	flags := wm.FeatureFlags()
+	if len(flags) != 3 || flags[2].Feature != FeatureWebsocketSubscriber || flags[1].Source != FeatureSourceRuntime || flags[0].Source != FeatureSourceDefault {
+		t.Errorf("unexpected feature flags: %+v %+v %+v", flags[0], flags[1], flags[2])
+	}
+	if err := wm.ResetFeatureFlag(FeatureUTXOIndex); err != nil || wm.FeatureEnabled(FeatureUTXOIndex) {
+		t.Errorf("reset should restore config state, err: %v", err)
+	}
+
+	if err := wm.SetFeatureFlag("unknown", true); err == nil {
+		t.Errorf("unknown feature should be rejected")
+	} else if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrFeatureUnknown {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	//关闭socketIO订阅后不再报告该能力
+	wm.Config.RPCServerType = RPCServerExplorer
+	wm.SetFeatureFlag(FeatureWebsocketSubscriber, false)
+	if wm.Capabilities().WebsocketSubscribe {
+		t.Errorf("websocket subscribe should be reported off")
+	}
+
+	wm.Config.FeatureFlags["rawBlock"] = true
+	wm.Config.FeatureFlags[string(FeatureRawBlockParse)] = true
+	problems := wm.Config.Validate()
+	found := 0
+	for _, p := range problems {
+		if p == `featureFlags: "rawBlock" is unknown` || p == "featureFlags: rawBlockParse requires rpcServerType = 0, it has no effect with explorer" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("feature flag problems should be reported, got: %v", problems)
+	}
+}
+
+func TestNEOBlockScanner_RawBlockParse(t *testing.T) {
+	var (
+		txid     = testHash("tx")
+		receiver = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+		txCalls  int32
+	)
+	txObj := map[string]interface{}{"txid": txid, "type": "ContractTransaction", "vin": []interface{}{},
+		"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": receiver}}}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockhash":
+			return testHash("block10")
+		case "getblock":
+			return map[string]interface{}{"index": 10, "hash": params[0], "previousblockhash": testHash("block9"), "time": 1000, "tx": []interface{}{txObj}}
+		case "getrawtransaction":
+			atomic.AddInt32(&txCalls, 1)
+			return txObj
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	bs := wm.Blockscanner
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "account", address == receiver
+	}
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	//默认逐笔查询交易单
+	if err := bs.ScanBlock(10); err != nil || atomic.LoadInt32(&txCalls) != 1 || len(observer.data) != 1 {
+		t.Fatalf("ScanBlock = %v, transaction calls: %d, notified: %d", err, txCalls, len(observer.data))
+	}
+
+	//开启后使用区块中的交易详情
+	wm.SetFeatureFlag(FeatureRawBlockParse, true)
+	//同一区块已通知过，清除通知记录后重新扫描
+	wm.DeleteExtractData(10)
+	bs.heightGuard.forget(10)
+	if err := bs.ScanBlock(10); err != nil || atomic.LoadInt32(&txCalls) != 1 || len(observer.data) != 2 {
+		t.Fatalf("ScanBlock = %v, transaction calls: %d, notified: %d", err, txCalls, len(observer.data))
+	}
+	tx := observer.data[1].Transaction
+	if tx.TxID != txid || tx.BlockHeight != 10 || tx.BlockHash != testHash("block10") || tx.ConfirmTime != 1000 {
+		t.Errorf("raw block parsed transaction: %+v", tx)
+	}
+
+	//立即回退到逐笔查询
+	wm.SetFeatureFlag(FeatureRawBlockParse, false)
+	wm.DeleteExtractData(10)
+	bs.heightGuard.forget(10)
+	bs.ScanBlock(10)
+	if atomic.LoadInt32(&txCalls) != 2 {
+		t.Errorf("disabled raw block parse should fetch transactions again, calls: %d", txCalls)
+	}
+}
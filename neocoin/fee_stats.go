@@ -0,0 +1,247 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//freeTxMaxSize 不支付网络费的交易大小上限，超过时需要支付网络费才能被打包
+	freeTxMaxSize = 1024
+)
+
+//FeeSample 一笔交易的网络费样本
+type FeeSample struct {
+	TxID        string
+	Size        uint64
+	NetFee      decimal.Decimal
+	BlockHeight uint64 //0表示在内存池中
+	SeenAt      int64
+}
+
+//FeeRate 每字节网络费
+func (s *FeeSample) FeeRate() decimal.Decimal {
+	if s.Size == 0 {
+		return decimal.Zero
+	}
+	return s.NetFee.Div(decimal.New(int64(s.Size), 0))
+}
+
+//IsFree 是否免费交易
+func (s *FeeSample) IsFree() bool {
+	return !s.NetFee.IsPositive()
+}
+
+//FeeDistribution 网络费分布，按最近排名法取分位数
+type FeeDistribution struct {
+	Count  int
+	Min    decimal.Decimal
+	Median decimal.Decimal
+	P75    decimal.Decimal
+	P90    decimal.Decimal
+	Max    decimal.Decimal
+}
+
+//FeeStats 最近区块和内存池的网络费统计
+type FeeStats struct {
+	FromHeight uint64 //统计的起始区块高度
+	ToHeight   uint64 //统计的结束区块高度
+	TxCount    int    //区块中支付网络费的交易和免费交易总数
+	FreeCount  int    //区块中的免费交易数
+	//免费交易接受率，区块中的免费交易数 / (区块中的免费交易数 + 内存池中等待的免费交易数)
+	FreeAcceptRate float64
+	Fees           FeeDistribution //区块中付费交易的网络费
+	FeeRates       FeeDistribution //区块中付费交易的每字节网络费
+	LargeFees      FeeDistribution //区块中超过免费大小上限的交易的网络费
+	MempoolCount   int             //内存池中的交易数
+	MempoolFree    int             //内存池中的免费交易数
+	MempoolFees    FeeDistribution //内存池中付费交易的网络费
+	UpdateAt       int64
+}
+
+//feeTracker 记录最近区块和内存池的交易网络费
+type feeTracker struct {
+	mu      sync.Mutex
+	blocks  map[uint64]map[string]*FeeSample //区块高度 -> 交易样本
+	mempool map[string]*FeeSample
+	tip     uint64
+}
+
+func newFeeTracker() *feeTracker {
+	return &feeTracker{
+		blocks:  make(map[uint64]map[string]*FeeSample),
+		mempool: make(map[string]*FeeSample),
+	}
+}
+
+//observe 记录交易的网络费，window为保留的区块数量
+func (f *feeTracker) observe(trx *Transaction, window uint64) {
+
+	//区块奖励交易不计入
+	if trx == nil || trx.Type == "MinerTransaction" {
+		return
+	}
+
+	netFee, err := decimal.NewFromString(trx.NetFee)
+	if err != nil {
+		netFee = decimal.Zero
+	}
+
+	sample := &FeeSample{
+		TxID:        trx.TxID,
+		Size:        trx.Size,
+		NetFee:      netFee,
+		BlockHeight: trx.BlockHeight,
+		SeenAt:      time.Now().Unix(),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if sample.BlockHeight == 0 {
+		f.mempool[sample.TxID] = sample
+		return
+	}
+
+	//已打包的交易不再计入内存池
+	delete(f.mempool, sample.TxID)
+
+	if window > 0 && f.tip >= window && sample.BlockHeight <= f.tip-window {
+		return
+	}
+
+	samples, ok := f.blocks[sample.BlockHeight]
+	if !ok {
+		samples = make(map[string]*FeeSample)
+		f.blocks[sample.BlockHeight] = samples
+	}
+	samples[sample.TxID] = sample
+
+	if sample.BlockHeight > f.tip {
+		f.tip = sample.BlockHeight
+		for height := range f.blocks {
+			if window > 0 && f.tip >= window && height <= f.tip-window {
+				delete(f.blocks, height)
+			}
+		}
+	}
+}
+
+//retainMempool 只保留仍在内存池中的交易样本
+func (f *feeTracker) retainMempool(txids []string) {
+	inMemPool := make(map[string]bool, len(txids))
+	for _, txid := range txids {
+		inMemPool[txid] = true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for txid := range f.mempool {
+		if !inMemPool[txid] {
+			delete(f.mempool, txid)
+		}
+	}
+}
+
+//stats 计算统计结果
+func (f *feeTracker) stats() *FeeStats {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := &FeeStats{UpdateAt: time.Now().Unix()}
+
+	var fees, rates, largeFees, mempoolFees []decimal.Decimal
+	for height, samples := range f.blocks {
+		if stats.FromHeight == 0 || height < stats.FromHeight {
+			stats.FromHeight = height
+		}
+		if height > stats.ToHeight {
+			stats.ToHeight = height
+		}
+		for _, s := range samples {
+			stats.TxCount++
+			if s.IsFree() {
+				stats.FreeCount++
+				continue
+			}
+			fees = append(fees, s.NetFee)
+			rates = append(rates, s.FeeRate())
+			if s.Size > freeTxMaxSize {
+				largeFees = append(largeFees, s.NetFee)
+			}
+		}
+	}
+
+	for _, s := range f.mempool {
+		stats.MempoolCount++
+		if s.IsFree() {
+			stats.MempoolFree++
+			continue
+		}
+		mempoolFees = append(mempoolFees, s.NetFee)
+	}
+
+	if total := stats.FreeCount + stats.MempoolFree; total > 0 {
+		stats.FreeAcceptRate = float64(stats.FreeCount) / float64(total)
+	}
+
+	stats.Fees = newFeeDistribution(fees)
+	stats.FeeRates = newFeeDistribution(rates)
+	stats.LargeFees = newFeeDistribution(largeFees)
+	stats.MempoolFees = newFeeDistribution(mempoolFees)
+
+	return stats
+}
+
+//newFeeDistribution 计算分布
+func newFeeDistribution(values []decimal.Decimal) FeeDistribution {
+	dist := FeeDistribution{Count: len(values)}
+	if len(values) == 0 {
+		return dist
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].LessThan(values[j])
+	})
+
+	dist.Min = values[0]
+	dist.Median = feePercentile(values, 50)
+	dist.P75 = feePercentile(values, 75)
+	dist.P90 = feePercentile(values, 90)
+	dist.Max = values[len(values)-1]
+	return dist
+}
+
+//feePercentile 最近排名法取已排序数据的分位数
+func feePercentile(sorted []decimal.Decimal, p int) decimal.Decimal {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+//GetFeeStats 最近扫描的区块和内存池交易的网络费统计，用于判断大额交易是否需要支付优先网络费以便及时确认
+func (wm *WalletManager) GetFeeStats() *FeeStats {
+	return wm.feeStats.stats()
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWalletManager_GetFeeStats(t *testing.T) {
+	wm := NewWalletManager()
+
+	//10个区块，每个区块1笔免费交易和1笔付费交易
+	for i := uint64(1); i <= 10; i++ {
+		wm.feeStats.observe(&Transaction{TxID: fmt.Sprintf("free%d", i), Type: "ContractTransaction", Size: 200, NetFee: "0", BlockHeight: i}, 5)
+		wm.feeStats.observe(&Transaction{TxID: fmt.Sprintf("paid%d", i), Type: "ContractTransaction", Size: 2000, NetFee: fmt.Sprintf("0.%02d", i), BlockHeight: i}, 5)
+		wm.feeStats.observe(&Transaction{TxID: fmt.Sprintf("miner%d", i), Type: "MinerTransaction", Size: 10, NetFee: "0", BlockHeight: i}, 5)
+	}
+
+	//内存池中3笔免费交易，其中1笔已离开内存池
+	for i := 1; i <= 3; i++ {
+		wm.feeStats.observe(&Transaction{TxID: fmt.Sprintf("pending%d", i), Type: "ContractTransaction", Size: 200, NetFee: "0"}, 5)
+	}
+	wm.feeStats.observe(&Transaction{TxID: "pending4", Type: "InvocationTransaction", Size: 300, NetFee: "0.5"}, 5)
+	wm.feeStats.retainMempool([]string{"pending1", "pending2", "pending4"})
+
+	stats := wm.GetFeeStats()
+	if stats.FromHeight != 6 || stats.ToHeight != 10 || stats.TxCount != 10 || stats.FreeCount != 5 {
+		t.Errorf("unexpected block stats: %+v", stats)
+	}
+	if stats.Fees.Count != 5 || stats.Fees.Min.String() != "0.06" || stats.Fees.Median.String() != "0.08" || stats.Fees.P90.String() != "0.1" || stats.LargeFees.Count != 5 {
+		t.Errorf("unexpected fee distribution: %+v", stats.Fees)
+	}
+	if stats.FeeRates.Median.String() != "0.00004" {
+		t.Errorf("unexpected fee rate median: %s", stats.FeeRates.Median.String())
+	}
+	if stats.MempoolCount != 3 || stats.MempoolFree != 2 || stats.MempoolFees.Median.String() != "0.5" {
+		t.Errorf("unexpected mempool stats: %+v", stats)
+	}
+	if rate := stats.FreeAcceptRate; rate < 0.71 || rate > 0.72 {
+		t.Errorf("unexpected free accept rate: %v", rate)
+	}
+
+	//内存池交易打包后移出内存池统计
+	wm.feeStats.observe(&Transaction{TxID: "pending4", Type: "InvocationTransaction", Size: 300, NetFee: "0.5", BlockHeight: 11}, 5)
+	stats = wm.GetFeeStats()
+	if stats.MempoolCount != 2 || stats.FromHeight != 7 || stats.Fees.Max.String() != "0.5" {
+		t.Errorf("unexpected stats after confirm: %+v", stats)
+	}
+}
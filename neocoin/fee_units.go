@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//RoundingMode 金额换算的舍入方式
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota //四舍五入，与StringFixed一致
+	RoundHalfEven                     //银行家舍入，四舍六入五成双，汇总大量金额时没有系统性偏差
+	RoundDown                         //向零截断
+	RoundUp                           //远离零进位，手续费取整时不少付
+)
+
+//String 舍入方式名称
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundHalfUp:
+		return "half-up"
+	case RoundHalfEven:
+		return "half-even"
+	case RoundDown:
+		return "down"
+	case RoundUp:
+		return "up"
+	}
+	return fmt.Sprintf("unknown(%d)", int(m))
+}
+
+//RoundAmount 按舍入方式保留places位小数
+func RoundAmount(d decimal.Decimal, places int32, mode RoundingMode) decimal.Decimal {
+	switch mode {
+	case RoundHalfEven:
+		return d.RoundBank(places)
+	case RoundDown:
+		return d.Truncate(places)
+	case RoundUp:
+		truncated := d.Truncate(places)
+		if truncated.Equal(d) {
+			return truncated
+		}
+		step := decimal.New(1, -places)
+		if d.Sign() < 0 {
+			return truncated.Sub(step)
+		}
+		return truncated.Add(step)
+	}
+	return d.Round(places)
+}
+
+//RoundGAS 按配置的舍入方式把GAS金额规范到最小单位精度，手续费预估和报表统一使用
+func (wm *WalletManager) RoundGAS(gas decimal.Decimal) decimal.Decimal {
+	return RoundAmount(gas, wm.Decimal(), wm.config().FeeRoundingMode)
+}
+
+//FormatGAS 按配置的舍入方式输出固定精度的GAS金额
+func (wm *WalletManager) FormatGAS(gas decimal.Decimal) string {
+	return wm.RoundGAS(gas).StringFixed(wm.Decimal())
+}
+
+//GASToBaseUnits 十进制GAS换算为最小单位（1 GAS = 10^精度），超出精度的部分按配置的舍入方式处理
+func (wm *WalletManager) GASToBaseUnits(gas decimal.Decimal) int64 {
+	return wm.RoundGAS(gas).Shift(wm.Decimal()).IntPart()
+}
+
+//BaseUnitsToGAS 最小单位换算为十进制GAS
+func (wm *WalletManager) BaseUnitsToGAS(units int64) decimal.Decimal {
+	return decimal.New(units, -wm.Decimal())
+}
+
+//SetGASFiatRate 设置1 GAS对应的法币价格，用于报表显示，rate不大于0表示不换算
+func (wm *WalletManager) SetGASFiatRate(currency string, rate decimal.Decimal) {
+	wm.updateConfig(func(cfg *WalletConfig) {
+		cfg.FiatCurrency = currency
+		cfg.GASFiatRate = rate
+	})
+}
+
+//GASFiatRate 1 GAS对应的法币价格和法币名称
+func (wm *WalletManager) GASFiatRate() (decimal.Decimal, string) {
+	cfg := wm.config()
+	return cfg.GASFiatRate, cfg.FiatCurrency
+}
+
+//GASToFiat GAS金额换算为法币显示金额，按配置的舍入方式保留法币精度，未设置价格时返回错误
+func (wm *WalletManager) GASToFiat(gas decimal.Decimal) (decimal.Decimal, string, error) {
+	rate, currency := wm.GASFiatRate()
+	if !rate.IsPositive() {
+		return decimal.Zero, currency, fmt.Errorf("gas fiat rate is not set")
+	}
+	return RoundAmount(gas.Mul(rate), wm.config().FiatDecimals, wm.config().FeeRoundingMode), currency, nil
+}
+
+//setFeesFiat 设置了法币价格时，把手续费的法币金额记录到交易单的ExtParam
+func (decoder *TransactionDecoder) setFeesFiat(rawTx *openwallet.RawTransaction, fees decimal.Decimal) {
+	fiat, currency, err := decoder.wm.GASToFiat(fees)
+	if err != nil {
+		return
+	}
+	rawTx.SetExtParam("feesFiat", map[string]string{
+		"amount":   fiat.StringFixed(decoder.wm.config().FiatDecimals),
+		"currency": currency,
+	})
+}
+
+//FiatToGAS 法币金额换算为GAS，按配置的舍入方式保留GAS精度，未设置价格时返回错误
+func (wm *WalletManager) FiatToGAS(fiat decimal.Decimal) (decimal.Decimal, error) {
+	rate, _ := wm.GASFiatRate()
+	if !rate.IsPositive() {
+		return decimal.Zero, fmt.Errorf("gas fiat rate is not set")
+	}
+	return wm.RoundGAS(fiat.DivRound(rate, wm.Decimal()+8)), nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		mode     RoundingMode
+		expected string
+	}{
+		{"0.000000125", RoundHalfUp, "0.00000013"},
+		{"0.000000125", RoundHalfEven, "0.00000012"},
+		{"0.000000135", RoundHalfEven, "0.00000014"},
+		{"0.000000129", RoundDown, "0.00000012"},
+		{"0.000000121", RoundUp, "0.00000013"},
+		{"0.00000012", RoundUp, "0.00000012"},
+		{"-0.000000121", RoundUp, "-0.00000013"},
+	}
+
+	for _, test := range tests {
+		value, _ := decimal.NewFromString(test.value)
+		rounded := RoundAmount(value, 8, test.mode)
+		if rounded.StringFixed(8) != test.expected {
+			t.Errorf("RoundAmount %s %s: %s, expected: %s", test.value, test.mode, rounded.StringFixed(8), test.expected)
+		}
+	}
+}
+
+func TestWalletManager_GASConversion(t *testing.T) {
+	wm := NewWalletManager()
+
+	gas, _ := decimal.NewFromString("1.234567895")
+	if units := wm.GASToBaseUnits(gas); units != 123456790 {
+		t.Errorf("GASToBaseUnits: %d", units)
+	}
+	wm.Config.FeeRoundingMode = RoundHalfEven
+	if units := wm.GASToBaseUnits(gas); units != 123456790 {
+		t.Errorf("GASToBaseUnits half even: %d", units)
+	}
+	wm.Config.FeeRoundingMode = RoundDown
+	if units := wm.GASToBaseUnits(gas); units != 123456789 {
+		t.Errorf("GASToBaseUnits down: %d", units)
+	}
+	if s := wm.BaseUnitsToGAS(123456789).String(); s != "1.23456789" {
+		t.Errorf("BaseUnitsToGAS: %s", s)
+	}
+
+	//未设置法币价格时不换算
+	if _, _, err := wm.GASToFiat(gas); err == nil {
+		t.Errorf("GASToFiat should fail without rate")
+	}
+
+	wm.Config.FeeRoundingMode = RoundHalfEven
+	wm.SetGASFiatRate("EUR", decimal.New(25, -1))
+	fees, _ := decimal.NewFromString("0.001")
+	fiat, currency, err := wm.GASToFiat(fees)
+	if err != nil || currency != "EUR" || fiat.StringFixed(2) != "0.00" {
+		t.Errorf("GASToFiat: %s %s, unexpected error: %v", fiat, currency, err)
+	}
+	fiat, _, _ = wm.GASToFiat(decimal.New(3, 0))
+	if fiat.StringFixed(2) != "7.50" {
+		t.Errorf("GASToFiat: %s", fiat)
+	}
+	back, err := wm.FiatToGAS(decimal.New(1, 0))
+	if err != nil || back.String() != "0.4" {
+		t.Errorf("FiatToGAS: %s, unexpected error: %v", back, err)
+	}
+}
+
+func TestWalletManager_EstimateFeeRounding(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.MinFees = decimal.Zero
+	wm.Config.MaxTxInputs = 10
+
+	//1 * 148 + 1 * 34 + 10 = 192字节
+	feeRate, _ := decimal.NewFromString("0.0000001")
+	fee, _ := wm.EstimateFee(1, 1, feeRate)
+	if wm.FormatGAS(fee) != "0.00000002" {
+		t.Errorf("half up fee: %s", wm.FormatGAS(fee))
+	}
+
+	wm.Config.FeeRoundingMode = RoundUp
+	feeRate, _ = decimal.NewFromString("0.00000001")
+	fee, _ = wm.EstimateFee(1, 1, feeRate)
+	if wm.FormatGAS(fee) != "0.00000001" {
+		t.Errorf("round up fee: %s", wm.FormatGAS(fee))
+	}
+}
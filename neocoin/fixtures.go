@@ -0,0 +1,309 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+//RPCFixture 一次json-rpc调用的原始请求和结果
+type RPCFixture struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+}
+
+//FixtureSet 从节点抓取的测试数据，可由FixtureClient或模拟的json-rpc服务回放
+type FixtureSet struct {
+	Symbol   string        `json:"symbol"`
+	Heights  []uint64      `json:"heights"`
+	TxIDs    []string      `json:"txids"`
+	CreateAt int64         `json:"createAt"`
+	Calls    []*RPCFixture `json:"calls"`
+
+	mu    sync.RWMutex
+	index map[string]*RPCFixture
+}
+
+//NewFixtureSet 创建空的测试数据集
+func NewFixtureSet(symbol string) *FixtureSet {
+	return &FixtureSet{
+		Symbol:   symbol,
+		Heights:  make([]uint64, 0),
+		TxIDs:    make([]string, 0),
+		CreateAt: time.Now().Unix(),
+		Calls:    make([]*RPCFixture, 0),
+		index:    make(map[string]*RPCFixture),
+	}
+}
+
+//LoadFixtureFile 读取json格式的测试数据文件
+func LoadFixtureFile(path string) (*FixtureSet, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &FixtureSet{}
+	err = json.Unmarshal(raw, set)
+	if err != nil {
+		return nil, err
+	}
+
+	set.index = make(map[string]*RPCFixture)
+	for _, call := range set.Calls {
+		key, err := fixtureKey(call.Method, call.Params)
+		if err != nil {
+			return nil, fmt.Errorf("fixture method: %s params is invalid, unexpected error: %v", call.Method, err)
+		}
+		set.index[key] = call
+	}
+
+	return set, nil
+}
+
+//SaveFile 以json格式保存测试数据文件
+func (set *FixtureSet) SaveFile(path string) error {
+	set.mu.RLock()
+	raw, err := json.MarshalIndent(set, "", "  ")
+	set.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
+
+//Add 记录一次调用结果，相同方法和参数只保留第一次的结果
+func (set *FixtureSet) Add(method string, params []interface{}, result *gjson.Result) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	key, err := fixtureKey(method, rawParams)
+	if err != nil {
+		return err
+	}
+
+	rawResult := json.RawMessage("null")
+	if result != nil && len(result.Raw) > 0 {
+		rawResult = json.RawMessage(result.Raw)
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if _, exist := set.index[key]; exist {
+		return nil
+	}
+
+	call := &RPCFixture{
+		Method: method,
+		Params: rawParams,
+		Result: rawResult,
+	}
+	set.index[key] = call
+	set.Calls = append(set.Calls, call)
+
+	return nil
+}
+
+//Lookup 按方法和参数查找记录的结果
+func (set *FixtureSet) Lookup(method string, params json.RawMessage) (json.RawMessage, bool) {
+	key, err := fixtureKey(method, params)
+	if err != nil {
+		return nil, false
+	}
+
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	call, ok := set.index[key]
+	if !ok {
+		return nil, false
+	}
+	return call.Result, true
+}
+
+//ServeHTTP 作为模拟节点的json-rpc服务回放测试数据，未记录的调用返回json-rpc错误
+func (set *FixtureSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+
+	resp := map[string]interface{}{
+		"jsonrpc": "2.0",
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		resp["error"] = map[string]interface{}{"code": -32700, "message": err.Error()}
+	} else if result, ok := set.Lookup(body.Method, body.Params); ok {
+		resp["id"] = body.ID
+		resp["result"] = result
+	} else {
+		resp["id"] = body.ID
+		resp["error"] = map[string]interface{}{
+			"code":    -32601,
+			"message": fmt.Sprintf("fixture not found, method: %s params: %s", body.Method, body.Params),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+//fixtureKey 方法和规范化后的参数组成查找键，参数中的数字按字面值比较
+func fixtureKey(method string, params json.RawMessage) (string, error) {
+	var value interface{}
+	if len(params) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(params))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return "", err
+		}
+	}
+
+	//空参数与[]一致
+	if value == nil {
+		value = []interface{}{}
+	}
+
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return method + " " + string(canonical), nil
+}
+
+//FixtureClient 回放测试数据的节点客户端
+type FixtureClient struct {
+	set *FixtureSet
+}
+
+//NewFixtureClient 创建回放测试数据的节点客户端
+func NewFixtureClient(set *FixtureSet) *FixtureClient {
+	return &FixtureClient{set: set}
+}
+
+//Call 返回记录的调用结果，未记录的调用返回错误
+func (c *FixtureClient) Call(path string, request []interface{}) (*gjson.Result, error) {
+	params, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := c.set.Lookup(path, params)
+	if !ok {
+		return nil, fmt.Errorf("fixture not found, method: %s params: %s", path, params)
+	}
+
+	result := gjson.ParseBytes(raw)
+	return &result, nil
+}
+
+//fixtureRecorder 转发调用到节点并记录成功的结果
+type fixtureRecorder struct {
+	client ClientInterface
+	set    *FixtureSet
+}
+
+//Call 转发调用并记录结果
+func (r *fixtureRecorder) Call(path string, request []interface{}) (*gjson.Result, error) {
+	result, err := r.client.Call(path, request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.set.Add(path, request, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+//CaptureFixtures 从节点抓取指定高度的区块和交易单，连同输入引用的交易单一起记录为可回放的测试数据，
+//抓取过程与扫描器提取交易单的调用一致，便于复现生产环境高度上的提取问题
+func (wm *WalletManager) CaptureFixtures(heights []uint64, txids []string) (*FixtureSet, error) {
+
+	if wm.nodeClient() == nil {
+		return nil, fmt.Errorf("node client is not setup, fixtures capture needs json-rpc")
+	}
+
+	if wm.config().RPCServerType == RPCServerExplorer {
+		return nil, fmt.Errorf("fixtures capture is unavailable in explorer mode")
+	}
+
+	set := NewFixtureSet(wm.Symbol())
+
+	//使用独立的钱包管理者记录调用，不影响正在运行的扫描器
+	capture := NewWalletManager()
+	capture.Config = wm.config().clone()
+	capture.Log = wm.Log
+	capture.SetNodeClient(&fixtureRecorder{client: wm.nodeClient(), set: set})
+
+	_, err := capture.GetBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	//只记录调用，不提取任何地址
+	skipAddress := func(address string) (string, bool) {
+		return "", false
+	}
+
+	for _, height := range heights {
+		hash, err := capture.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := capture.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, txid := range block.Tx {
+			result := capture.Blockscanner.ExtractTransaction(height, hash, txid, skipAddress)
+			if !result.Success {
+				wm.Log.Std.Info("fixtures capture extract transaction: %s failed at height: %d", txid, height)
+			}
+		}
+
+		set.Heights = append(set.Heights, height)
+	}
+
+	for _, txid := range txids {
+		result := capture.Blockscanner.ExtractTransaction(0, "", txid, skipAddress)
+		if !result.Success {
+			return nil, fmt.Errorf("fixtures capture transaction: %s failed", txid)
+		}
+		set.TxIDs = append(set.TxIDs, txid)
+	}
+
+	return set, nil
+}
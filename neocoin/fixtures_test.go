@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalletManager_CaptureFixtures(t *testing.T) {
+	const (
+		neo   = "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+		alice = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		bob   = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	)
+	var (
+		blockHash = testHash("block100")
+		preTx     = testHash("pre")
+		tx        = testHash("tx")
+	)
+	txs := map[string]interface{}{
+		preTx: map[string]interface{}{"txid": preTx, "vout": []interface{}{
+			map[string]interface{}{"n": 0, "asset": neo, "value": "10", "address": alice},
+		}},
+		tx: map[string]interface{}{"txid": tx, "blockhash": blockHash, "vin": []interface{}{
+			map[string]interface{}{"txid": preTx, "vout": 0},
+		}, "vout": []interface{}{
+			map[string]interface{}{"n": 0, "asset": neo, "value": "10", "address": bob},
+		}},
+	}
+	live := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 201
+		case "getblockhash":
+			return blockHash
+		case "getblock":
+			return map[string]interface{}{
+				"index":             100,
+				"hash":              blockHash,
+				"previousblockhash": testHash("block99"),
+				"time":              1000,
+				"tx":                []interface{}{tx},
+			}
+		case "getrawtransaction":
+			return txs[params[0].(string)]
+		}
+		return nil
+	})
+	defer live.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(live.URL, "", false)
+
+	set, err := wm.CaptureFixtures([]uint64{100}, nil)
+	if err != nil {
+		t.Errorf("CaptureFixtures failed unexpected error: %v\n", err)
+		return
+	}
+	//getblockcount, getblockhash, getblock, 交易单及其输入引用的交易单
+	if len(set.Calls) != 5 || len(set.Heights) != 1 {
+		t.Errorf("unexpected captured calls: %d", len(set.Calls))
+	}
+
+	dir, _ := ioutil.TempDir("", "neo-fixtures")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "block100.json")
+	if err := set.SaveFile(path); err != nil {
+		t.Errorf("SaveFile failed unexpected error: %v\n", err)
+		return
+	}
+
+	loaded, err := LoadFixtureFile(path)
+	if err != nil {
+		t.Errorf("LoadFixtureFile failed unexpected error: %v\n", err)
+		return
+	}
+
+	//关闭节点后通过模拟的json-rpc服务回放
+	live.Close()
+	mock := httptest.NewServer(loaded)
+	defer mock.Close()
+
+	replay := NewWalletManager()
+	replay.WalletClient = NewClient(mock.URL, "", false)
+
+	hash, err := replay.GetBlockHash(100)
+	if err != nil || hash != blockHash {
+		t.Errorf("replay GetBlockHash: %s, unexpected error: %v", hash, err)
+	}
+
+	result := replay.Blockscanner.ExtractTransaction(100, blockHash, tx, func(address string) (string, bool) {
+		return address, address == alice || address == bob
+	})
+	if !result.Success || len(result.extractData) != 2 {
+		t.Errorf("replay extract transaction failed, extract data: %d", len(result.extractData))
+	}
+
+	if _, err := replay.GetBlockHash(101); err == nil {
+		t.Errorf("uncaptured call should fail")
+	}
+
+	//不经过http直接回放
+	replay.SetNodeClient(NewFixtureClient(loaded))
+	if _, err := replay.GetTransaction(preTx); err != nil {
+		t.Errorf("fixture client GetTransaction failed unexpected error: %v\n", err)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//TxActionRollback 分叉回滚的交易记录标识
+	TxActionRollback = "rollback"
+)
+
+//rollbackID 由原记录ID和孤块hash生成回滚记录的ID，与原记录不同，同一交易多次回滚也不重复
+func rollbackID(id, blockHash string) string {
+	return base64.StdEncoding.EncodeToString(crypto.SHA256([]byte(fmt.Sprintf("%s_%s_%s", TxActionRollback, id, blockHash))))
+}
+
+//NewRollbackExtractData 生成提取结果的回滚数据，金额取反，输入输出标记为删除，
+//交易单WxID和输入输出Sid重新生成，避免消费者按ID去重时丢弃回滚数据
+func NewRollbackExtractData(data *openwallet.TxExtractData) *openwallet.TxExtractData {
+
+	if data == nil {
+		return nil
+	}
+
+	rollback := &openwallet.TxExtractData{}
+
+	for _, input := range data.TxInputs {
+		in := *input
+		in.Amount = negateAmount(in.Amount)
+		in.Delete = true
+		in.Sid = rollbackID(in.Sid, in.BlockHash)
+		rollback.TxInputs = append(rollback.TxInputs, &in)
+	}
+
+	for _, output := range data.TxOutputs {
+		out := *output
+		out.Amount = negateAmount(out.Amount)
+		out.Delete = true
+		out.Sid = rollbackID(out.Sid, out.BlockHash)
+		rollback.TxOutputs = append(rollback.TxOutputs, &out)
+	}
+
+	if data.Transaction != nil {
+		tx := *data.Transaction
+		tx.Amount = negateAmount(tx.Amount)
+		tx.TxAction = TxActionRollback
+		tx.WxID = rollbackID(tx.WxID, tx.BlockHash)
+		rollback.Transaction = &tx
+	}
+
+	return rollback
+}
+
+//negateAmount 金额取反，无法解析时原样返回
+func negateAmount(amount string) string {
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return amount
+	}
+	return d.Neg().String()
+}
+
+//DeleteExtractData 删除指定高度已保存的提取结果，以及不低于该高度的检查点
+func (wm *WalletManager) DeleteExtractData(height uint64) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Select(q.Eq("BlockHeight", height)).Delete(&ExtractDataRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	err = db.Select(q.Gte("Height", height)).Delete(&ChainCheckpoint{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+//rollbackExtractData 分叉时把孤块上已通知的提取结果生成回滚数据通知给观察者
+func (bs *NEOBlockScanner) rollbackExtractData(height uint64) {
+
+	//回滚后重新扫描该高度时须重新通知
+	bs.heightGuard.forget(height)
+
+	if !bs.wm.config().ForkRollbackNotify {
+		return
+	}
+
+	list, err := bs.wm.GetExtractData(height, height)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, get extract data failed. unexpected error: %v", height, err)
+		return
+	}
+
+	//持久化投递状态，失败的回滚通知由补发任务重新投递
+	if bs.wm.config().NotifyOutbox {
+		for _, r := range list {
+			bs.deliverExtractData(height, map[string]*openwallet.TxExtractData{r.SourceKey: NewRollbackExtractData(r.Data)})
+		}
+	} else {
+		for o, _ := range bs.Observers {
+			for _, r := range list {
+				if !bs.acceptNotify(o, r.SourceKey, r.Data) {
+					continue
+				}
+				err = bs.notifyObserver(o, r.SourceKey, NewRollbackExtractData(r.Data))
+				if err != nil {
+					bs.wm.Log.Std.Error("block height: %d, txid: %s rollback notify failed. unexpected error: %v", height, r.TxID, err)
+				}
+			}
+		}
+	}
+
+	err = bs.wm.DeleteExtractData(height)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, delete extract data failed. unexpected error: %v", height, err)
+	}
+
+	bs.wm.Log.Std.Info("block scanner rollback %d extract data on fork height: %d", len(list), height)
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/tidwall/gjson"
+)
+
+//SimulatedFork 模拟的分叉，区块范围内的区块替换为另一条链上hash不同的区块，用于测试观察者的回滚处理
+type SimulatedFork struct {
+	From    uint64 //分叉的起始高度
+	To      uint64 //分叉的结束高度，0表示直到最新高度
+	Label   string //另一条链的标识，不同标识生成不同的区块hash，可连续模拟多次重组
+	DropTxs bool   //另一条链上的区块不包含交易，原链上的交易回到未确认状态
+}
+
+//contains 高度是否在分叉范围内
+func (f *SimulatedFork) contains(height uint64) bool {
+	return height >= f.From && (f.To == 0 || height <= f.To)
+}
+
+//altHash 另一条链上对应原区块的hash
+func (f *SimulatedFork) altHash(realHash string) string {
+	return "0x" + hex.EncodeToString(crypto.SHA256([]byte(fmt.Sprintf("fork:%s:%s", f.Label, normalizeTxID(realHash)))))
+}
+
+//forkSimClient 改写节点返回的区块hash模拟分叉的客户端，其他请求原样转发
+type forkSimClient struct {
+	client   ClientInterface
+	previous ClientInterface //模拟前注入的客户端，结束模拟时恢复
+
+	mu        sync.RWMutex
+	fork      SimulatedFork
+	altToReal map[string]string //另一条链的区块hash -> 原区块hash
+	realToAlt map[string]string
+}
+
+func newForkSimClient(client, previous ClientInterface, fork SimulatedFork) *forkSimClient {
+	c := &forkSimClient{client: client, previous: previous}
+	c.setFork(fork)
+	return c
+}
+
+//setFork 替换模拟的分叉
+func (c *forkSimClient) setFork(fork SimulatedFork) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fork = fork
+	c.altToReal = make(map[string]string)
+	c.realToAlt = make(map[string]string)
+}
+
+//alt 记录并返回原区块hash在另一条链上的hash
+func (c *forkSimClient) alt(realHash string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	altHash := c.fork.altHash(realHash)
+	c.altToReal[altHash] = realHash
+	c.realToAlt[realHash] = altHash
+	return altHash
+}
+
+func (c *forkSimClient) Call(path string, request []interface{}) (*gjson.Result, error) {
+
+	c.mu.RLock()
+	fork := c.fork
+	c.mu.RUnlock()
+
+	switch path {
+	case "getblockhash":
+		result, err := c.client.Call(path, request)
+		if err != nil || len(request) == 0 {
+			return result, err
+		}
+		height, ok := forkSimHeight(request[0])
+		if !ok || !fork.contains(height) {
+			return result, nil
+		}
+		return forkSimString(c.alt(result.String())), nil
+
+	case "getblock", "getblockheader":
+		if len(request) == 0 {
+			return c.client.Call(path, request)
+		}
+		hash := fmt.Sprint(request[0])
+		c.mu.RLock()
+		realHash, isAlt := c.altToReal[hash]
+		c.mu.RUnlock()
+		if isAlt {
+			request = append([]interface{}{realHash}, request[1:]...)
+		}
+		result, err := c.client.Call(path, request)
+		if err != nil || !result.IsObject() {
+			return result, err
+		}
+		return c.rewriteBlock(fork, result, isAlt)
+
+	case "getrawtransaction":
+		result, err := c.client.Call(path, request)
+		if err != nil || !result.IsObject() {
+			return result, err
+		}
+		return c.rewriteTransaction(fork, result)
+	}
+
+	return c.client.Call(path, request)
+}
+
+//rewriteBlock 分叉范围内的区块改为另一条链的hash，范围内和紧接范围的区块改写上一区块hash，
+//只有按另一条链的hash查询时才丢弃交易
+func (c *forkSimClient) rewriteBlock(fork SimulatedFork, result *gjson.Result, isAlt bool) (*gjson.Result, error) {
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Raw), &obj); err != nil {
+		return nil, err
+	}
+
+	height := uint64(result.Get("index").Uint())
+	if fork.contains(height) {
+		obj["hash"] = c.alt(result.Get("hash").String())
+		if isAlt && fork.DropTxs {
+			if _, ok := obj["tx"]; ok {
+				obj["tx"] = []interface{}{}
+			}
+		}
+	}
+	if height > 0 && fork.contains(height-1) {
+		if prev := result.Get("previousblockhash").String(); len(prev) > 0 {
+			obj["previousblockhash"] = c.alt(prev)
+		}
+	}
+	if next := result.Get("nextblockhash").String(); len(next) > 0 && fork.contains(height+1) {
+		obj["nextblockhash"] = c.alt(next)
+	}
+
+	return forkSimObject(obj)
+}
+
+//rewriteTransaction 分叉范围内区块的交易改为另一条链的区块hash，丢弃交易时改为未确认
+func (c *forkSimClient) rewriteTransaction(fork SimulatedFork, result *gjson.Result) (*gjson.Result, error) {
+
+	blockHash := result.Get("blockhash").String()
+	c.mu.RLock()
+	altHash, ok := c.realToAlt[blockHash]
+	c.mu.RUnlock()
+	if len(blockHash) == 0 || !ok {
+		return result, nil
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Raw), &obj); err != nil {
+		return nil, err
+	}
+	if fork.DropTxs {
+		delete(obj, "blockhash")
+		delete(obj, "blocktime")
+		delete(obj, "confirmations")
+	} else {
+		obj["blockhash"] = altHash
+	}
+
+	return forkSimObject(obj)
+}
+
+func forkSimHeight(v interface{}) (uint64, bool) {
+	switch h := v.(type) {
+	case uint64:
+		return h, true
+	case int:
+		return uint64(h), h >= 0
+	case int64:
+		return uint64(h), h >= 0
+	case float64:
+		return uint64(h), h >= 0
+	}
+	return 0, false
+}
+
+func forkSimString(s string) *gjson.Result {
+	raw, _ := json.Marshal(s)
+	result := gjson.ParseBytes(raw)
+	return &result
+}
+
+func forkSimObject(obj map[string]interface{}) (*gjson.Result, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	result := gjson.ParseBytes(raw)
+	return &result, nil
+}
+
+//SimulateFork 让扫描器看到模拟的分叉，之后扫描到分叉范围时按正常的分叉处理回滚并通知观察者，
+//再次调用替换为新的分叉，只能在配置了forkSimulation的测试环境中使用
+func (wm *WalletManager) SimulateFork(fork SimulatedFork) error {
+
+	if !wm.config().ForkSimulation {
+		return wm.errorf(ErrOperationNotPermitted, "fork simulation is disabled, set forkSimulation = true on test deployments")
+	}
+	if wm.config().RPCServerType == RPCServerExplorer || wm.nodeClient() == nil {
+		return wm.errorf(ErrOperationNotPermitted, "fork simulation requires json-rpc node")
+	}
+	if fork.From == 0 || (fork.To > 0 && fork.To < fork.From) {
+		return wm.errorf(ErrBlockHeightInvalid, "fork range from: %d to: %d is invalid", fork.From, fork.To)
+	}
+
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+
+	if sim, ok := wm.injected.client.(*forkSimClient); ok {
+		sim.setFork(fork)
+	} else {
+		client := wm.injected.client
+		if client == nil {
+			client = wm.WalletClient
+		}
+		wm.injected.client = newForkSimClient(client, wm.injected.client, fork)
+	}
+
+	wm.Log.Std.Warning("simulating fork: %s from height: %d to: %d, drop transactions: %v", fork.Label, fork.From, fork.To, fork.DropTxs)
+
+	return nil
+}
+
+//ResolveSimulatedFork 结束模拟，节点恢复返回原链，扫描器在模拟链上扫描过的区块再次按分叉回滚
+func (wm *WalletManager) ResolveSimulatedFork() {
+
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+
+	if sim, ok := wm.injected.client.(*forkSimClient); ok {
+		wm.injected.client = sim.previous
+		wm.Log.Std.Warning("simulated fork: %s resolved", sim.fork.Label)
+	}
+}
+
+//ActiveSimulatedFork 当前模拟的分叉，没有时返回nil
+func (wm *WalletManager) ActiveSimulatedFork() *SimulatedFork {
+
+	wm.injectMu.RLock()
+	defer wm.injectMu.RUnlock()
+
+	sim, ok := wm.injected.client.(*forkSimClient)
+	if !ok {
+		return nil
+	}
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+	fork := sim.fork
+	return &fork
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWalletManager_SimulateFork(t *testing.T) {
+	var tip uint64 = 10
+	heights := make(map[string]uint64)
+	for h := uint64(0); h <= 20; h++ {
+		heights[testHash(fmt.Sprintf("block%d", h))] = h
+	}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return atomic.LoadUint64(&tip) + 1
+		case "getblockhash":
+			return testHash(fmt.Sprintf("block%v", params[0]))
+		case "getblock", "getblockheader":
+			h, ok := heights[fmt.Sprint(params[0])]
+			if !ok {
+				return nil
+			}
+			return map[string]interface{}{"index": h, "hash": params[0], "previousblockhash": testHash(fmt.Sprintf("block%d", h-1)),
+				"nextblockhash": testHash(fmt.Sprintf("block%d", h+1)), "time": 1000 + h, "tx": []interface{}{testHash(fmt.Sprintf("tx%d", h))}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	fork := SimulatedFork{From: 8, Label: "a", DropTxs: true}
+	if err := wm.SimulateFork(fork); err == nil {
+		t.Fatalf("fork simulation should be disabled by default")
+	}
+	wm.Config.ForkSimulation = true
+	if err := wm.SimulateFork(SimulatedFork{From: 9, To: 8}); err == nil {
+		t.Errorf("invalid fork range should be rejected")
+	}
+
+	bs := wm.Blockscanner
+	bs.Scanning = true
+	observer := &testHeaderObserver{}
+	bs.AddObserver(observer)
+	wm.SaveLocalNewBlock(5, testHash("block5"))
+	bs.ScanBlockTask()
+	if n := observer.waitHeaders(5); n != 5 {
+		t.Fatalf("real chain should be notified, notified: %d", n)
+	}
+
+	//分叉范围内的区块在另一条链上，扫描到新区块时回滚
+	if err := wm.SimulateFork(fork); err != nil {
+		t.Fatalf("SimulateFork failed, unexpected error: %v", err)
+	}
+	if active := wm.ActiveSimulatedFork(); active == nil || active.Label != "a" {
+		t.Fatalf("ActiveSimulatedFork = %+v", active)
+	}
+	hash, _ := wm.GetBlockHash(9)
+	block, err := wm.GetBlock(hash, BlockDetailTxIDs)
+	if err != nil || block.Hash == testHash("block9") || block.Previousblockhash != fork.altHash(testHash("block8")) || len(block.Tx) != 0 {
+		t.Fatalf("simulated block = %+v, %v", block, err)
+	}
+	if block, _ := wm.GetBlock(testHash("block9"), BlockDetailTxIDs); len(block.Tx) != 1 {
+		t.Errorf("block queried by real hash should keep transactions")
+	}
+	if hash, _ := wm.GetBlockHash(7); hash != testHash("block7") {
+		t.Errorf("block below fork range should not be changed")
+	}
+
+	atomic.StoreUint64(&tip, 11)
+	bs.ScanBlockTask()
+	observer.waitHeaders(100)
+	forked := make(map[uint64]bool)
+	for _, header := range observer.headers[5:] {
+		if header.Fork {
+			forked[header.Height] = true
+		}
+	}
+	if !forked[8] || !forked[9] || !forked[10] || forked[7] {
+		t.Fatalf("blocks in fork range should be rolled back, forked: %v", forked)
+	}
+	if local, localHash := wm.GetLocalNewBlock(); local != 11 || localHash != fork.altHash(testHash("block11")) {
+		t.Fatalf("scanner should follow simulated chain, local height: %d hash: %s", local, localHash)
+	}
+
+	//结束模拟后回到原链，模拟链上的区块再次回滚
+	wm.ResolveSimulatedFork()
+	if wm.ActiveSimulatedFork() != nil {
+		t.Errorf("simulated fork should be resolved")
+	}
+	notified := len(observer.headers)
+	atomic.StoreUint64(&tip, 12)
+	bs.ScanBlockTask()
+	observer.waitHeaders(100)
+	rolledBack := false
+	for _, header := range observer.headers[notified:] {
+		rolledBack = rolledBack || (header.Fork && header.Height == 11)
+	}
+	if !rolledBack {
+		t.Errorf("simulated blocks should be rolled back after resolve")
+	}
+	if _, localHash := wm.GetLocalNewBlock(); localHash != testHash("block12") {
+		t.Errorf("scanner should be back on real chain, local hash: %s", localHash)
+	}
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	TraceForward  = "forward"  //追踪资金去向
+	TraceBackward = "backward" //追踪资金来源
+
+	TraceOutputUnspent = "unspent" //未花费
+	TraceOutputSpent   = "spent"   //已花费
+	TraceOutputUnknown = "unknown" //已花费但本地索引没有花费交易单，或未继续追踪
+
+	traceMaxNodes = 1000 //资金图最大节点数，超过后截断
+)
+
+//TraceNode 资金图中的一个交易输出
+type TraceNode struct {
+	ID      string `json:"id"` //txid:n
+	TxID    string `json:"txid"`
+	N       uint64 `json:"n"`
+	Address string `json:"address"`
+	Value   string `json:"value"`
+	Asset   string `json:"asset"`
+	Depth   int    `json:"depth"`
+	Status  string `json:"status"`
+}
+
+//TraceEdge 资金图中的一次花费，From输出被TxID花费后产生To输出
+type TraceEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	TxID string `json:"txid"`
+}
+
+//FundsGraph 资金追踪结果
+type FundsGraph struct {
+	Root      string                `json:"root"`
+	Direction string                `json:"direction"`
+	Depth     int                   `json:"depth"`
+	Nodes     map[string]*TraceNode `json:"nodes"`
+	Edges     []*TraceEdge          `json:"edges"`
+	Truncated bool                  `json:"truncated"` //节点数超过上限，未追踪完整
+}
+
+//fundsTracer 单次追踪的上下文，缓存已查询的交易单
+type fundsTracer struct {
+	wm     *WalletManager
+	graph  *FundsGraph
+	txs    map[string]*Transaction
+	spends map[string]string //本地索引：txid:n -> 花费交易单
+}
+
+func traceOutputID(txid string, n uint64) string {
+	return fmt.Sprintf("%s:%d", normalizeTxID(txid), n)
+}
+
+func normalizeTxID(txid string) string {
+	return strings.TrimPrefix(strings.ToLower(txid), "0x")
+}
+
+//TraceFunds 从交易输出出发，沿花费链向后追踪最多depth跳的资金去向，
+//花费关系优先使用本地已保存的提取结果，交易单和未花状态通过RPC查询
+func (wm *WalletManager) TraceFunds(txid string, vout uint64, depth int) (*FundsGraph, error) {
+	return wm.traceFunds(txid, vout, depth, TraceForward)
+}
+
+//TraceFundsBackward 从交易输出出发，沿交易输入向前追踪最多depth跳的资金来源
+func (wm *WalletManager) TraceFundsBackward(txid string, vout uint64, depth int) (*FundsGraph, error) {
+	return wm.traceFunds(txid, vout, depth, TraceBackward)
+}
+
+func (wm *WalletManager) traceFunds(txid string, vout uint64, depth int, direction string) (*FundsGraph, error) {
+
+	if depth < 0 {
+		return nil, fmt.Errorf("trace depth: %d is invalid", depth)
+	}
+
+	t := &fundsTracer{
+		wm: wm,
+		graph: &FundsGraph{
+			Root:      traceOutputID(txid, vout),
+			Direction: direction,
+			Depth:     depth,
+			Nodes:     make(map[string]*TraceNode),
+			Edges:     make([]*TraceEdge, 0),
+		},
+		txs: make(map[string]*Transaction),
+	}
+
+	root, err := t.outputNode(txid, vout, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if direction == TraceForward {
+		if err := t.loadSpendIndex(); err != nil {
+			return nil, wm.errorf(ErrLocalDBOperateFailed, "get extract data failed, unexpected error: %v", err)
+		}
+	}
+
+	queue := []*TraceNode{root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		var next []*TraceNode
+		if direction == TraceForward {
+			next, err = t.forward(node)
+		} else {
+			next, err = t.backward(node)
+		}
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, next...)
+	}
+
+	return t.graph, nil
+}
+
+//getTransaction 查询交易单，同一次追踪内只查询一次
+func (t *fundsTracer) getTransaction(txid string) (*Transaction, error) {
+	key := normalizeTxID(txid)
+	if trx, ok := t.txs[key]; ok {
+		return trx, nil
+	}
+	trx, err := t.wm.GetTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+	t.txs[key] = trx
+	return trx, nil
+}
+
+//outputNode 查询交易输出并加入资金图，已存在时返回nil
+func (t *fundsTracer) outputNode(txid string, n uint64, depth int) (*TraceNode, error) {
+
+	id := traceOutputID(txid, n)
+	if _, exist := t.graph.Nodes[id]; exist {
+		return nil, nil
+	}
+
+	if len(t.graph.Nodes) >= traceMaxNodes {
+		t.graph.Truncated = true
+		return nil, nil
+	}
+
+	trx, err := t.getTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &TraceNode{
+		ID:     id,
+		TxID:   trx.TxID,
+		N:      n,
+		Depth:  depth,
+		Status: TraceOutputUnknown,
+	}
+	for _, out := range trx.Vouts {
+		if out.N == n {
+			node.Address = out.Addr
+			node.Value = out.Value
+			node.Asset = out.Asset
+			break
+		}
+	}
+
+	t.graph.Nodes[id] = node
+	return node, nil
+}
+
+//addEdge 两端输出都在资金图中才记录花费关系，截断的节点不记录
+func (t *fundsTracer) addEdge(from, to, txid string) {
+	if t.graph.Nodes[from] == nil || t.graph.Nodes[to] == nil {
+		return
+	}
+	t.graph.Edges = append(t.graph.Edges, &TraceEdge{From: from, To: to, TxID: txid})
+}
+
+//loadSpendIndex 从已保存的提取结果建立输出到花费交易单的索引
+func (t *fundsTracer) loadSpendIndex() error {
+
+	db, err := t.wm.openLocalDB(t.wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var list []*ExtractDataRecord
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	t.spends = make(map[string]string)
+	for _, r := range list {
+		if r.Data == nil {
+			continue
+		}
+		for _, input := range r.Data.TxInputs {
+			t.spends[traceOutputID(input.SourceTxID, input.SourceIndex)] = r.TxID
+		}
+	}
+
+	return nil
+}
+
+//forward 追踪输出被哪笔交易单花费，以及花费后产生的输出
+func (t *fundsTracer) forward(node *TraceNode) ([]*TraceNode, error) {
+
+	spendTxID, ok := t.spends[node.ID]
+	if !ok {
+		out, err := t.wm.GetTxOut(node.TxID, node.N)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil && len(out.Addr) > 0 {
+			node.Status = TraceOutputUnspent
+		}
+		return nil, nil
+	}
+
+	node.Status = TraceOutputSpent
+	if node.Depth >= t.graph.Depth {
+		return nil, nil
+	}
+
+	trx, err := t.getTransaction(spendTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := make([]*TraceNode, 0, len(trx.Vouts))
+	for _, out := range trx.Vouts {
+		child, err := t.outputNode(trx.TxID, out.N, node.Depth+1)
+		if err != nil {
+			return nil, err
+		}
+		t.addEdge(node.ID, traceOutputID(trx.TxID, out.N), trx.TxID)
+		if child != nil {
+			next = append(next, child)
+		}
+	}
+
+	return next, nil
+}
+
+//backward 追踪产生该输出的交易单花费了哪些输出
+func (t *fundsTracer) backward(node *TraceNode) ([]*TraceNode, error) {
+
+	if node.Depth >= t.graph.Depth {
+		return nil, nil
+	}
+
+	trx, err := t.getTransaction(node.TxID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := make([]*TraceNode, 0, len(trx.Vins))
+	for _, vin := range trx.Vins {
+		parent, err := t.outputNode(vin.TxID, vin.Vout, node.Depth+1)
+		if err != nil {
+			return nil, err
+		}
+		t.addEdge(traceOutputID(vin.TxID, vin.Vout), node.ID, trx.TxID)
+		if parent != nil {
+			parent.Status = TraceOutputSpent
+			next = append(next, parent)
+		}
+	}
+
+	return next, nil
+}
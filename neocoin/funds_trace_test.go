@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_TraceFunds(t *testing.T) {
+	const neo = "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+	var (
+		tx0 = testHash("tx0")
+		tx1 = testHash("tx1")
+		tx2 = testHash("tx2")
+	)
+	output := func(n int, address, value string) map[string]interface{} {
+		return map[string]interface{}{"n": n, "asset": neo, "value": value, "address": address}
+	}
+	input := func(txid string, n int) map[string]interface{} {
+		return map[string]interface{}{"txid": txid, "vout": n}
+	}
+	//tx0:0 -> tx1 -> (tx1:0, tx1:1)，tx1:0 -> tx2 -> tx2:0，tx1:1未花费
+	txs := map[string]interface{}{
+		tx0: map[string]interface{}{"txid": tx0, "vout": []interface{}{output(0, "AHot", "10")}},
+		tx1: map[string]interface{}{"txid": tx1, "vin": []interface{}{input(tx0, 0)}, "vout": []interface{}{output(0, "AMule", "7"), output(1, "AHot", "3")}},
+		tx2: map[string]interface{}{"txid": tx2, "vin": []interface{}{input(tx1, 0)}, "vout": []interface{}{output(0, "AExchange", "7")}},
+	}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getrawtransaction":
+			return txs[params[0].(string)]
+		case "gettxout":
+			if params[0] == tx1 && params[1].(float64) == 1 {
+				return output(1, "AHot", "3")
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	spend := func(txid, sourceTxID string) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid}
+		data.TxInputs = []*openwallet.TxInput{{SourceTxID: sourceTxID, SourceIndex: 0, Recharge: openwallet.Recharge{TxID: txid, Address: "AHot", Amount: "10"}}}
+		return data
+	}
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"hot": spend(tx1, tx0)})
+	wm.SaveExtractData(11, map[string]*openwallet.TxExtractData{"hot": spend(tx2, tx1)})
+
+	graph, err := wm.TraceFunds(tx0, 0, 2)
+	if err != nil {
+		t.Errorf("TraceFunds failed unexpected error: %v\n", err)
+		return
+	}
+	if len(graph.Nodes) != 4 || len(graph.Edges) != 3 || graph.Truncated {
+		t.Errorf("unexpected forward graph, nodes: %d, edges: %d", len(graph.Nodes), len(graph.Edges))
+	}
+	if n := graph.Nodes[traceOutputID(tx1, 1)]; n == nil || n.Status != TraceOutputUnspent || n.Address != "AHot" {
+		t.Errorf("unexpected change output node: %+v", n)
+	}
+	if n := graph.Nodes[traceOutputID(tx2, 0)]; n == nil || n.Depth != 2 || n.Address != "AExchange" {
+		t.Errorf("unexpected depth 2 node: %+v", n)
+	}
+
+	graph, _ = wm.TraceFunds(tx0, 0, 1)
+	if len(graph.Nodes) != 3 || graph.Nodes[traceOutputID(tx1, 0)].Status != TraceOutputSpent {
+		t.Errorf("depth 1 forward graph should stop at tx1 outputs, nodes: %d", len(graph.Nodes))
+	}
+
+	graph, err = wm.TraceFundsBackward(tx2, 0, 5)
+	if err != nil {
+		t.Errorf("TraceFundsBackward failed unexpected error: %v\n", err)
+		return
+	}
+	if len(graph.Nodes) != 3 || len(graph.Edges) != 2 || graph.Nodes[traceOutputID(tx0, 0)] == nil {
+		t.Errorf("unexpected backward graph, nodes: %d, edges: %d", len(graph.Nodes), len(graph.Edges))
+	}
+}
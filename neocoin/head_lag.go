@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	AlertTypeHeadLagDegraded  = "head_lag_degraded"  //节点高度落后，暂停通知提取结果
+	AlertTypeHeadLagRecovered = "head_lag_recovered" //节点高度恢复，继续通知提取结果
+
+	//headLagUnscanReason 节点落后时暂停通知的区块，记录为未扫区块待恢复后重扫
+	headLagUnscanReason = "head lag degraded"
+)
+
+//HeadLagStatus 当前节点与参考高度的差距
+type HeadLagStatus struct {
+	Height     uint64            //当前节点高度
+	Median     uint64            //参考高度中位数
+	Lag        uint64            //落后的区块数
+	References map[string]uint64 //备用节点和浏览器的高度
+	Degraded   bool              //是否超过阈值
+}
+
+//CheckHeadLag 比较当前节点高度与备用节点和浏览器高度的中位数，落后超过阈值时为降级状态，
+//没有可用的参考高度时不降级
+func (wm *WalletManager) CheckHeadLag() (*HeadLagStatus, error) {
+
+	if wm.nodeClient() == nil {
+		return nil, fmt.Errorf("node client is not setup")
+	}
+
+	height, err := getBlockHeightByClient(wm.nodeClient(), wm.HeightOffset())
+	if err != nil {
+		return nil, fmt.Errorf("node can not get block height, unexpected error: %v", err)
+	}
+
+	status := &HeadLagStatus{
+		Height:     height,
+		References: make(map[string]uint64),
+	}
+
+	//单个参考源不可用时忽略，避免备用节点故障导致降级
+	for i, c := range wm.backupClients() {
+		h, err := getBlockHeightByClient(c, wm.HeightOffset())
+		if err != nil {
+			wm.Log.Std.Info("backup node: %s can not get block height, unexpected error: %v", clientName(c, i+1), err)
+			continue
+		}
+		status.References[clientName(c, i+1)] = h
+	}
+
+	if wm.explorerClient() != nil && wm.config().RPCServerType != RPCServerExplorer {
+		h, err := wm.getBlockHeightByExplorer()
+		if err != nil {
+			wm.Log.Std.Info("explorer can not get block height, unexpected error: %v", err)
+			wm.Blockscanner.recordScanError(ScanErrorSourceExplorer, 0, err)
+			wm.Blockscanner.setDegraded(DegradedExplorerDown, true, err)
+		} else {
+			status.References["explorer"] = h
+			wm.Blockscanner.setDegraded(DegradedExplorerDown, false, nil)
+		}
+	}
+
+	if len(status.References) == 0 {
+		return status, nil
+	}
+
+	heights := make([]uint64, 0, len(status.References))
+	for _, h := range status.References {
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	//偶数个参考高度取较低的一个，避免单个超前的参考源导致降级
+	status.Median = heights[(len(heights)-1)/2]
+	if status.Median > height {
+		status.Lag = status.Median - height
+	}
+	status.Degraded = wm.config().HeadLagThreshold > 0 && status.Lag > wm.config().HeadLagThreshold
+
+	return status, nil
+}
+
+//checkHeadLag 每个扫描周期检查节点高度落后情况，降级和恢复时通知告警观测者
+func (bs *NEOBlockScanner) checkHeadLag() {
+
+	if bs.wm.config().HeadLagThreshold == 0 {
+		bs.setHeadLagDegraded(false)
+		return
+	}
+
+	status, err := bs.wm.CheckHeadLag()
+	if err != nil {
+		//无法判断时保持原状态
+		bs.wm.Log.Std.Info("block scanner can not check head lag; unexpected error: %v", err)
+		return
+	}
+
+	changed := bs.setHeadLagDegraded(status.Degraded)
+	bs.setDegraded(DegradedHeadLag, status.Degraded, fmt.Errorf("node height: %d lags median: %d by %d blocks", status.Height, status.Median, status.Lag))
+	if !changed {
+		return
+	}
+
+	var alert *Alert
+	if status.Degraded {
+		alert = NewAlert(bs.wm.Symbol(), AlertTypeHeadLagDegraded, status.Height,
+			fmt.Sprintf("node height: %d lags median: %d by %d blocks, extract data notifications are withheld", status.Height, status.Median, status.Lag))
+	} else {
+		alert = NewAlert(bs.wm.Symbol(), AlertTypeHeadLagRecovered, status.Height,
+			fmt.Sprintf("node height: %d caught up with median: %d, extract data notifications are resumed", status.Height, status.Median))
+	}
+	for name, h := range status.References {
+		alert.Details[name] = fmt.Sprintf("%d", h)
+	}
+
+	bs.wm.Log.Std.Warning("%s", alert.Message)
+	bs.newAlertNotify(alert)
+}
+
+//setHeadLagDegraded 设置降级状态，返回状态是否变化
+func (bs *NEOBlockScanner) setHeadLagDegraded(degraded bool) bool {
+	bs.headLagMu.Lock()
+	defer bs.headLagMu.Unlock()
+
+	changed := bs.headLagDegraded != degraded
+	bs.headLagDegraded = degraded
+	return changed
+}
+
+//isHeadLagDegraded 是否处于节点落后的降级状态
+func (bs *NEOBlockScanner) isHeadLagDegraded() bool {
+	bs.headLagMu.RLock()
+	defer bs.headLagMu.RUnlock()
+	return bs.headLagDegraded
+}
+
+//withholdExtractData 降级状态下不通知提取结果，已确认区块记录为未扫区块，恢复后重扫再通知。
+//返回错误让提取按失败处理，重扫未扫区块时不会在降级期间删除记录
+func (bs *NEOBlockScanner) withholdExtractData(height uint64) error {
+
+	if !bs.isHeadLagDegraded() {
+		return nil
+	}
+
+	if height > 0 {
+		err := bs.SaveUnscanRecord(NewUnscanRecord(height, "", headLagUnscanReason))
+		if err != nil {
+			return fmt.Errorf("block height: %d extract data withheld, save unscan record failed, unexpected error: %v", height, err)
+		}
+	}
+
+	return fmt.Errorf("block height: %d extract data withheld, node head lag degraded", height)
+}
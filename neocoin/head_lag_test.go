@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_CheckHeadLag(t *testing.T) {
+	primary := newTestRPCServer(testChainHandler(100, "0x"))
+	defer primary.Close()
+	backup1 := newTestRPCServer(testChainHandler(120, "0x"))
+	defer backup1.Close()
+	backup2 := newTestRPCServer(testChainHandler(125, "0x"))
+	defer backup2.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.HeadLagThreshold = 10
+	wm.WalletClient = NewClient(primary.URL, "", false)
+	wm.BackupClients = []*Client{NewClient(backup1.URL, "", false), NewClient(backup2.URL, "", false)}
+
+	status, err := wm.CheckHeadLag()
+	if err != nil {
+		t.Errorf("CheckHeadLag failed unexpected error: %v\n", err)
+		return
+	}
+	//偶数个参考高度取较低的中位数
+	if status.Height != 100 || status.Median != 120 || status.Lag != 20 || !status.Degraded {
+		t.Errorf("unexpected head lag status: %+v", status)
+	}
+
+	bs := wm.Blockscanner
+	alerts := &testAlertObserver{}
+	bs.AddAlertObserver(alerts)
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	bs.checkHeadLag()
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeHeadLagDegraded {
+		t.Errorf("degraded alert should be sent, alerts: %d", len(alerts.alerts))
+	}
+
+	//降级期间不通知，区块记录为未扫区块
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "0x01"}
+	if err := bs.newExtractDataNotify(99, map[string]*openwallet.TxExtractData{"acc": data}); err == nil {
+		t.Errorf("withheld extract data should fail the block, so rescan keeps the record")
+	}
+	if len(observer.notified) != 0 {
+		t.Errorf("extract data should be withheld, notified: %v", observer.notified)
+	}
+	records, _ := wm.GetUnscanRecords()
+	if len(records) != 1 || records[0].BlockHeight != 99 || records[0].Reason != headLagUnscanReason {
+		t.Errorf("withheld block should be recorded for rescan, records: %+v", records)
+	}
+
+	//状态不变时不重复告警
+	bs.checkHeadLag()
+	if len(alerts.alerts) != 1 {
+		t.Errorf("alert should not repeat, alerts: %d", len(alerts.alerts))
+	}
+
+	wm.Config.HeadLagThreshold = 30
+	bs.checkHeadLag()
+	if len(alerts.alerts) != 2 || alerts.alerts[1].Type != AlertTypeHeadLagRecovered {
+		t.Errorf("recovered alert should be sent, alerts: %d", len(alerts.alerts))
+	}
+	if err := bs.newExtractDataNotify(99, map[string]*openwallet.TxExtractData{"acc": data}); err != nil {
+		t.Errorf("newExtractDataNotify after recovery failed unexpected error: %v", err)
+	}
+	if len(observer.notified) != 1 {
+		t.Errorf("extract data should be notified after recovery, notified: %v", observer.notified)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	LocaleEN = "en" //英文，消息原文
+	LocaleZH = "zh" //简体中文
+)
+
+//messageCatalog 消息目录，以英文消息格式为键，格式化参数的顺序和类型须与原文一致
+var messageCatalog = map[string]map[string]string{
+	LocaleZH: {
+		//区块扫描
+		"block height to rescan must greater than 0":                       "重扫区块高度必须大于0",
+		"block height must greater than 0":                                 "区块高度必须大于0",
+		"can not get block hash on height: %d, unexpected error: %v":       "无法获取高度: %d 的区块hash，错误: %v",
+		"block hash: %s is not equal to node hash: %s on height: %d":       "区块hash: %s 与节点hash: %s 在高度: %d 不一致",
+		"purge local data above height: %d failed, unexpected error: %v":   "清除高度: %d 以上的本地数据失败，错误: %v",
+		"timestamp: %d is earlier than genesis block time: %d":             "时间戳: %d 早于创世区块时间: %d",
+		"from height: %d is greater than to height: %d":                    "起始高度: %d 大于结束高度: %d",
+		"local block schema version: %d is newer than adapter: %d":         "本地区块数据版本: %d 高于适配器版本: %d",
+		"local db schema version: %d is newer than adapter: %d":            "本地数据库版本: %d 高于适配器版本: %d",
+
+		//本地数据库
+		"local db: %s is locked by another process, retry after %v or stop the other process": "本地数据库: %s 被其他进程占用，请在 %v 后重试或停止其他进程",
+		"scan lease is held by instance: %s, last heartbeat: %s":           "扫描租约被实例: %s 持有，最近续约时间: %s",
+		"open local db failed, unexpected error: %v":                       "打开本地数据库失败，错误: %v",
+		"local db: %s belongs to %s network: %d, current is %s network: %d": "本地数据库: %s 属于 %s 网络: %d，当前为 %s 网络: %d",
+		"read local network of: %s failed, unexpected error: %v":           "读取 %s 的本地数据网络失败，错误: %v",
+		"save local network of: %s failed, unexpected error: %v":           "保存 %s 的本地数据网络失败，错误: %v",
+		"get schema version failed, unexpected error: %v":                  "获取数据库版本失败，错误: %v",
+		"begin migration failed, unexpected error: %v":                     "开始数据库升级失败，错误: %v",
+		"migrate local db to version: %d failed, unexpected error: %v":     "本地数据库升级到版本: %d 失败，错误: %v",
+		"commit migration failed, unexpected error: %v":                    "提交数据库升级失败，错误: %v",
+		"get extract data failed, unexpected error: %v":                    "获取提取结果失败，错误: %v",
+		"open audit log db failed, unexpected error: %v":                   "打开审计日志数据库失败，错误: %v",
+		"begin audit log failed, unexpected error: %v":                     "开始写入审计日志失败，错误: %v",
+		"get last audit log failed, unexpected error: %v":                  "获取最后一条审计日志失败，错误: %v",
+		"save audit log failed, unexpected error: %v":                      "保存审计日志失败，错误: %v",
+		"commit audit log failed, unexpected error: %v":                    "提交审计日志失败，错误: %v",
+		"get transaction approval failed, unexpected error: %v":            "获取交易审批记录失败，错误: %v",
+		"save transaction approval failed, unexpected error: %v":           "保存交易审批记录失败，错误: %v",
+		"get withdraw records failed, unexpected error: %v":                "获取提币记录失败，错误: %v",
+		"save withdraw record failed, unexpected error: %v":                "保存提币记录失败，错误: %v",
+		"get idempotency record failed, unexpected error: %v":              "获取幂等键记录失败，错误: %v",
+		"open legacy db: %s failed, unexpected error: %v":                  "打开旧版数据库: %s 失败，错误: %v",
+		"legacy db has no scan position, unexpected error: %v":             "旧版数据库没有扫描位置，错误: %v",
+		"scan position: %d already exists, legacy scan position: %d is not imported": "扫描位置: %d 已存在，未导入旧版扫描位置: %d",
+		"read legacy blocks failed, unexpected error: %v":                  "读取旧版区块记录失败，错误: %v",
+		"read legacy unscan records failed, unexpected error: %v":          "读取旧版未扫记录失败，错误: %v",
+		"get notify deliveries failed, unexpected error: %v":               "获取通知投递记录失败，错误: %v",
+		"save notify deliveries failed, unexpected error: %v":              "保存通知投递记录失败，错误: %v",
+		"invalid config: %s":                                               "配置不正确: %s",
+		"feature: %s is unknown":                                           "功能: %s 不存在",
+		"rpc method: %s is unavailable, %s is disabled until %s":           "RPC方法: %s 不可用，%s 已停用至 %s",
+		"get indexed transaction failed, unexpected error: %v":             "获取交易索引失败，错误: %v",
+		"save indexed transaction failed, unexpected error: %v":            "保存交易索引失败，错误: %v",
+		"get utxo history failed, unexpected error: %v":                    "获取未花输出历史失败，错误: %v",
+		"block height: %d is above scanned height: %d":                     "区块高度: %d 高于已扫描高度: %d",
+		"get rebuild checkpoint failed, unexpected error: %v":              "获取重建进度失败，错误: %v",
+		"save rebuild checkpoint failed, unexpected error: %v":             "保存重建进度失败，错误: %v",
+		"clear local data from height: %d failed, unexpected error: %v":    "清除高度: %d 起的本地数据失败，错误: %v",
+		"rebuild local data on height: %d failed, unexpected error: %v":    "重建高度: %d 的本地数据失败，错误: %v",
+		"save archived watch addresses failed, unexpected error: %v":       "保存归档观测地址失败，错误: %v",
+		"get archived watch addresses failed, unexpected error: %v":        "获取归档观测地址失败，错误: %v",
+		"delete archived watch address failed, unexpected error: %v":       "删除归档观测地址失败，错误: %v",
+		"watch address: %s is not archived":                                "观测地址: %s 未归档",
+		"page snapshot block: %d hash: %s is replaced by: %s, restart from the first page": "分页快照区块: %d hash: %s 已被: %s 替换，请从第一页重新查询",
+		"block height: %d hash: %s is replaced by: %s, re-extract again":                   "区块高度: %d 的hash: %s 已被: %s 替换，请重新提取",
+		"local head: %d has no common ancestor with node in stored blocks":  "本地区块头: %d 在已保存区块中找不到与节点的共同祖先",
+		"read backup failed, unexpected error: %v":                         "读取备份失败，错误: %v",
+		"backup entry: %s is unexpected":                                   "备份条目: %s 不正确",
+		"backup is incomplete":                                             "备份不完整",
+		"backup file: %s checksum mismatch":                                "备份文件: %s 校验和不一致",
+		"backup format: %d is not supported":                               "不支持备份格式: %d",
+		"backup belongs to %s network: %d, current is %s network: %d":      "备份属于 %s 网络: %d，当前为 %s 网络: %d",
+		"backup is encrypted, local db key is required":                    "备份已加密，需要配置本地数据库密钥",
+		"backup schema version: %d is not equal to manifest: %d":           "备份数据库版本: %d 与清单: %d 不一致",
+		"restore local db: %s failed, unexpected error: %v":                "恢复本地数据库: %s 失败，错误: %v",
+
+		//交易
+		"[%s] have not addresses":                                          "[%s] 没有地址",
+		"[%s] balance is not enough":                                       "[%s] 余额不足",
+		"The balance: %s is not enough! ":                                  "余额: %s 不足！",
+		"account have not available utxo":                                  "账户没有可用的utxo",
+		"address: %s risk score: %v category: %s is blocked":               "地址: %s 风险分数: %v 类别: %s 已被拒绝",
+		"account: %s withdraw amount: %s exceeds per tx limit: %s":         "账户: %s 提币金额: %s 超出单笔限额: %s",
+		"account: %s withdraw amount: %s exceeds per %s limit: %s, used: %s": "账户: %s 提币金额: %s 超出每%s限额: %s，已使用: %s",
+		"transaction: %s is rejected":                                      "交易单: %s 审批已拒绝",
+		"transaction: %s is pending approval":                              "交易单: %s 等待审批",
+		"local txid mismatch, GetHash: %s, CalcTxID: %s":                   "本地交易ID计算不一致，GetHash: %s，CalcTxID: %s",
+		"node can not find broadcast transaction: %s, unexpected error: %v": "节点找不到已广播的交易单: %s，错误: %v",
+		"node txid: %s is not equal to local txid: %s":                     "节点交易ID: %s 与本地交易ID: %s 不一致",
+		"transaction: %s has been cancelled":                               "交易单: %s 已取消",
+		"transaction: %s has been submitted, can not cancel":               "交易单: %s 已广播，不能取消",
+		"save abandoned transaction failed, unexpected error: %v":          "保存已取消交易单失败，错误: %v",
+		"transaction: %s is scheduled to broadcast at %s":                  "交易单: %s 将于 %s 广播",
+		"get scheduled broadcast failed, unexpected error: %v":             "获取定时交易单失败，错误: %v",
+		"save scheduled broadcast failed, unexpected error: %v":            "保存定时交易单失败，错误: %v",
+		"get settlement records failed, unexpected error: %v":              "获取结算明细失败，错误: %v",
+
+		//跨链证明
+		"transaction: %s is not confirmed in block":                        "交易单: %s 尚未打包进区块",
+		"transaction: %s is not found in block: %s":                        "交易单: %s 不在区块: %s 中",
+		"block: %s merkle root: %s can not be proved":                      "区块: %s 的默克尔根: %s 无法验证",
+		"transaction: %s has no output: %d":                                "交易单: %s 没有输出: %d",
+
+		//通知隔离
+		"quarantined notification: %s is not found":                        "隔离的通知: %s 不存在",
+		"delete quarantined notification failed, unexpected error: %v":     "删除隔离的通知失败，错误: %v",
+
+		//入账核对
+		"%s can not find txid: %s":                                         "%s 找不到交易单: %s",
+		"%s txid: %s block hash: %s, expected: %s":                         "%s 交易单: %s 的区块hash: %s，应为: %s",
+		"%s txid: %s has no output: %d":                                    "%s 交易单: %s 没有输出: %d",
+		"%s txid: %s output: %d is %s %s, expected: %s %s":                 "%s 交易单: %s 的输出: %d 为 %s %s，应为: %s %s",
+
+		//地址派生校验
+		"address audit requires json-rpc node":                              "地址校验需要使用json-rpc节点",
+		"%d of %d audited addresses failed node validation: %s":            "%d 个地址未通过节点校验，共校验 %d 个: %s",
+
+		//浏览器一致性读取
+		"explorer request: %s value: %s agreed by %d of %d responses, quorum: %d": "浏览器请求: %s 数额: %s 只有 %d 个结果一致，共 %d 个结果，要求: %d 个",
+
+		//模拟分叉
+		"fork simulation is disabled, set forkSimulation = true on test deployments": "未允许模拟分叉，仅在测试环境中设置 forkSimulation = true",
+		"fork simulation requires json-rpc node":                                     "模拟分叉需要使用json-rpc节点",
+		"fork range from: %d to: %d is invalid":                                      "分叉范围 %d 至 %d 不正确",
+
+		//权限
+		"operation token is invalid":        "操作令牌无效",
+		"operation [%s] is not permitted":   "操作 [%s] 未授权",
+	},
+}
+
+//T 按配置的语言翻译消息格式，目录中没有时返回原文
+func (wm *WalletManager) T(format string) string {
+	if catalog, ok := messageCatalog[wm.config().Locale]; ok {
+		if msg, ok := catalog[format]; ok {
+			return msg
+		}
+	}
+	return format
+}
+
+//errorf 按配置的语言生成带错误码的错误
+func (wm *WalletManager) errorf(code uint64, format string, a ...interface{}) *openwallet.Error {
+	return openwallet.Errorf(code, wm.T(format), a...)
+}
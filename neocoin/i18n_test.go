@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestMessageCatalog_Verbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for locale, catalog := range messageCatalog {
+		for format, msg := range catalog {
+			if !reflect.DeepEqual(verbs.FindAllString(format, -1), verbs.FindAllString(msg, -1)) {
+				t.Errorf("[%s] message: %s verbs are not equal to: %s", locale, msg, format)
+			}
+		}
+	}
+}
+
+func TestWalletManager_Errorf(t *testing.T) {
+	wm := NewWalletManager()
+
+	err := wm.errorf(ErrTransactionRejected, "transaction: %s is rejected", "0x01")
+	if err.Error() != "[5402]transaction: 0x01 is rejected" {
+		t.Errorf("default locale should be english, got: %s", err.Error())
+	}
+
+	wm.Config.Locale = LocaleZH
+	err = wm.errorf(ErrTransactionRejected, "transaction: %s is rejected", "0x01")
+	if err.Code() != ErrTransactionRejected || err.Error() != "[5402]交易单: 0x01 审批已拒绝" {
+		t.Errorf("unexpected localized error: %v", err)
+	}
+
+	//目录中没有的消息返回原文
+	if msg := wm.T("unknown message"); msg != "unknown message" {
+		t.Errorf("unknown message should fall back, got: %s", msg)
+	}
+}
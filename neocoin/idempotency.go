@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//IdempotencyKeyParam 交易单ExtParam中的幂等键字段
+	IdempotencyKeyParam = "idempotencyKey"
+)
+
+//IdempotencyRecord 幂等键对应的已广播交易单
+type IdempotencyRecord struct {
+	Key       string `storm:"id"`
+	TxID      string
+	Sid       string
+	AccountID string
+	CreateAt  int64
+	Void      bool //交易单已取消，幂等键作废
+}
+
+//SetIdempotencyKey 设置交易单的幂等键，相同幂等键重复提交时直接返回首次广播的txid
+func SetIdempotencyKey(rawTx *openwallet.RawTransaction, key string) error {
+	return rawTx.SetExtParam(IdempotencyKeyParam, key)
+}
+
+//idempotencyKey 读取交易单的幂等键
+func idempotencyKey(rawTx *openwallet.RawTransaction) string {
+	if len(rawTx.ExtParam) == 0 {
+		return ""
+	}
+	return rawTx.GetExtParam().Get(IdempotencyKeyParam).String()
+}
+
+//GetIdempotencyRecord 获取幂等键对应的已广播交易单，不存在返回nil
+func (wm *WalletManager) GetIdempotencyRecord(key string) (*IdempotencyRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var record IdempotencyRecord
+	err = db.One("Key", key, &record)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+//saveIdempotencyRecord 广播成功后保存幂等键与txid的对应关系
+func (wm *WalletManager) saveIdempotencyRecord(key string, rawTx *openwallet.RawTransaction) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	record := &IdempotencyRecord{
+		Key:      key,
+		TxID:     rawTx.TxID,
+		Sid:      rawTx.Sid,
+		CreateAt: time.Now().Unix(),
+	}
+	if rawTx.Account != nil {
+		record.AccountID = rawTx.Account.AccountID
+	}
+
+	return db.Save(record)
+}
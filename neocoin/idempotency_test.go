@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestTransactionDecoder_SubmitRawTransactionIdempotency(t *testing.T) {
+	broadcasts := 0
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "sendrawtransaction" {
+			broadcasts++
+			return true
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.TxIDCheck = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	rawHex := "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac"
+	newRawTx := func(hex string) *openwallet.RawTransaction {
+		rawTx := &openwallet.RawTransaction{
+			Sid:         "sid",
+			Account:     &openwallet.AssetsAccount{AccountID: "account"},
+			RawHex:      hex,
+			IsCompleted: true,
+		}
+		SetIdempotencyKey(rawTx, "withdraw-1")
+		return rawTx
+	}
+
+	tx, err := wm.TxDecoder.SubmitRawTransaction(nil, newRawTx(rawHex))
+	if err != nil {
+		t.Errorf("SubmitRawTransaction failed unexpected error: %v\n", err)
+		return
+	}
+
+	//上游重试时即使交易单重新构建，也返回首次广播的txid
+	retryTx := newRawTx("80000000")
+	retried, err := wm.TxDecoder.SubmitRawTransaction(nil, retryTx)
+	if err != nil || retried.TxID != tx.TxID || !retryTx.IsSubmit || broadcasts != 1 {
+		t.Errorf("retry should return original txid: %s, got: %v, broadcasts: %d, unexpected error: %v", tx.TxID, retried, broadcasts, err)
+	}
+
+	record, _ := wm.GetIdempotencyRecord("withdraw-1")
+	if record == nil || record.TxID != tx.TxID || record.AccountID != "account" {
+		t.Errorf("unexpected idempotency record: %+v", record)
+	}
+
+	//不带幂等键时正常广播
+	if _, err := wm.TxDecoder.SubmitRawTransaction(nil, &openwallet.RawTransaction{Account: &openwallet.AssetsAccount{AccountID: "account"}, RawHex: rawHex, IsCompleted: true}); err != nil || broadcasts != 2 {
+		t.Errorf("submit without idempotency key should broadcast, broadcasts: %d, unexpected error: %v", broadcasts, err)
+	}
+}
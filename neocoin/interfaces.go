@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/hdkeystore"
+	"github.com/tidwall/gjson"
+)
+
+//BatchClientInterface 支持批量请求的节点客户端
+type BatchClientInterface interface {
+	ClientInterface
+	BatchCall(path string, requests [][]interface{}) ([]*gjson.Result, error)
+}
+
+//ExplorerInterface 浏览器API客户端
+type ExplorerInterface interface {
+	Call(path string, request interface{}, method string) (*gjson.Result, error)
+}
+
+//KeystoreInterface 秘钥存取
+type KeystoreInterface interface {
+	GetKey(rootId, filename, auth string) (*hdkeystore.HDKey, error)
+	StoreKey(filename string, key *hdkeystore.HDKey, auth string) error
+	JoinPath(filename string) string
+}
+
+var (
+	_ BatchClientInterface = (*Client)(nil)
+	_ ExplorerInterface    = (*Explorer)(nil)
+	_ KeystoreInterface    = (*hdkeystore.HDKeystore)(nil)
+)
+
+//injectedDeps 注入的依赖实现
+type injectedDeps struct {
+	client          ClientInterface
+	backups         []ClientInterface
+	explorer        ExplorerInterface
+	backupExplorers []ExplorerInterface
+	keystore        KeystoreInterface
+}
+
+//SetNodeClient 注入节点客户端实现，设置后替代WalletClient，nil恢复使用WalletClient
+func (wm *WalletManager) SetNodeClient(client ClientInterface) {
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+	wm.injected.client = client
+}
+
+//SetBackupNodeClients 注入备用节点客户端实现，设置后替代BackupClients，nil恢复使用BackupClients
+func (wm *WalletManager) SetBackupNodeClients(clients []ClientInterface) {
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+	wm.injected.backups = clients
+}
+
+//SetExplorerClient 注入浏览器API客户端实现，设置后替代ExplorerClient，nil恢复使用ExplorerClient
+func (wm *WalletManager) SetExplorerClient(explorer ExplorerInterface) {
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+	wm.injected.explorer = explorer
+}
+
+//SetKeystore 注入秘钥存取实现，设置后替代Storage，nil恢复使用Storage
+func (wm *WalletManager) SetKeystore(keystore KeystoreInterface) {
+	wm.injectMu.Lock()
+	defer wm.injectMu.Unlock()
+	wm.injected.keystore = keystore
+}
+
+//nodeClient 当前使用的节点客户端，都未设置时返回nil
+func (wm *WalletManager) nodeClient() ClientInterface {
+	wm.injectMu.RLock()
+	defer wm.injectMu.RUnlock()
+	if wm.injected.client != nil {
+		return wm.injected.client
+	}
+	if wm.WalletClient != nil {
+		return wm.WalletClient
+	}
+	return nil
+}
+
+//backupClients 当前使用的备用节点客户端
+func (wm *WalletManager) backupClients() []ClientInterface {
+	wm.injectMu.RLock()
+	defer wm.injectMu.RUnlock()
+	if wm.injected.backups != nil {
+		return wm.injected.backups
+	}
+	clients := make([]ClientInterface, 0, len(wm.BackupClients))
+	for _, c := range wm.BackupClients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+//explorerClient 当前使用的浏览器API客户端，都未设置时返回nil
+func (wm *WalletManager) explorerClient() ExplorerInterface {
+	wm.injectMu.RLock()
+	defer wm.injectMu.RUnlock()
+	if wm.injected.explorer != nil {
+		return wm.injected.explorer
+	}
+	if wm.ExplorerClient != nil {
+		return wm.ExplorerClient
+	}
+	return nil
+}
+
+//keystore 当前使用的秘钥存取实现，都未设置时返回nil
+func (wm *WalletManager) keystore() KeystoreInterface {
+	wm.injectMu.RLock()
+	defer wm.injectMu.RUnlock()
+	if wm.injected.keystore != nil {
+		return wm.injected.keystore
+	}
+	if wm.Storage != nil {
+		return wm.Storage
+	}
+	return nil
+}
+
+//clientName 节点客户端名称，用于日志和告警
+func clientName(client ClientInterface, index int) string {
+	if c, ok := client.(*Client); ok {
+		return c.BaseURL
+	}
+	return fmt.Sprintf("node[%d]", index)
+}
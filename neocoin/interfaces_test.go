@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+//mockClient 模拟节点客户端，按方法名返回固定结果
+type mockClient struct {
+	results map[string]string
+}
+
+func (c *mockClient) Call(path string, request []interface{}) (*gjson.Result, error) {
+	raw, ok := c.results[path]
+	if !ok {
+		return nil, fmt.Errorf("method: %s not mocked", path)
+	}
+	result := gjson.Parse(raw)
+	return &result, nil
+}
+
+//mockFuncClient 按请求动态返回结果的模拟节点客户端
+type mockFuncClient func(path string, request []interface{}) (*gjson.Result, error)
+
+func (f mockFuncClient) Call(path string, request []interface{}) (*gjson.Result, error) {
+	return f(path, request)
+}
+
+func TestWalletManager_MockClient(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.RPCServerType = RPCServerCore
+	blockHash := testHash("abc")
+	wm.SetNodeClient(&mockClient{results: map[string]string{
+		"getblockcount": "101",
+		"getblockhash":  fmt.Sprintf(`"%s"`, blockHash),
+	}})
+	wm.SetBackupNodeClients([]ClientInterface{&mockClient{results: map[string]string{
+		"getblockcount": "101",
+		"getblockhash":  fmt.Sprintf(`"%s"`, blockHash),
+	}}})
+
+	height, err := wm.GetBlockHeight()
+	if err != nil {
+		t.Errorf("GetBlockHeight failed unexpected error: %v\n", err)
+		return
+	}
+	if height != 100 {
+		t.Errorf("height should be 100, got %d", height)
+	}
+
+	hash, err := wm.GetBlockHash(height)
+	if err != nil {
+		t.Errorf("GetBlockHash failed unexpected error: %v\n", err)
+		return
+	}
+	if hash != blockHash {
+		t.Errorf("hash should be %s, got %s", blockHash, hash)
+	}
+
+	alert, err := wm.CheckNodeDivergence()
+	if err != nil || alert != nil {
+		t.Errorf("mock nodes should not diverge, alert: %v, err: %v", alert, err)
+	}
+
+	//未实现批量请求的客户端跳过预取
+	wm.Config.PublicNodeMode = true
+	wm.prefetchTransactions([]string{"0x01"})
+}
+
+func TestWalletManager_InjectedClientFallback(t *testing.T) {
+	wm := NewWalletManager()
+	if wm.nodeClient() != nil || wm.explorerClient() != nil {
+		t.Errorf("unset clients should be nil interfaces")
+	}
+
+	client := NewClient("http://127.0.0.1:10332", "", false)
+	wm.WalletClient = client
+	mock := &mockClient{}
+	wm.SetNodeClient(mock)
+	if wm.nodeClient() != mock {
+		t.Errorf("injected client should take precedence")
+	}
+
+	//清除注入后恢复使用公开字段
+	wm.SetNodeClient(nil)
+	if wm.nodeClient() != client {
+		t.Errorf("WalletClient should be used after injected client is cleared")
+	}
+	if wm.keystore() == nil {
+		t.Errorf("Storage should be used when no keystore is injected")
+	}
+}
+
+func TestWalletManager_ListUnspentChunk(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.RPCServerType = RPCServerCore
+	wm.Config.UnspentQueryChunkSize = 7
+	wm.Config.UnspentQueryConcurrency = 3
+	wm.Config.UnspentCache = false
+
+	var mu sync.Mutex
+	calls := 0
+	wm.SetNodeClient(mockFuncClient(func(path string, request []interface{}) (*gjson.Result, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		address := request[0].(string)
+		if address == "bad" {
+			return nil, fmt.Errorf("unknown address")
+		}
+		if address == "empty" {
+			result := gjson.Parse(`{"address":"empty","balance":[]}`)
+			return &result, nil
+		}
+		result := gjson.Parse(fmt.Sprintf(`{"address":"%s","balance":[{"asset_symbol":"NEO","amount":"1"}]}`, address))
+		return &result, nil
+	}))
+
+	addresses := make([]string, 0)
+	for i := 0; i < 30; i++ {
+		addresses = append(addresses, fmt.Sprintf("addr%d", i))
+	}
+	addresses = append(addresses, "empty")
+
+	utxos, err := wm.ListUnspent(0, addresses...)
+	if err != nil {
+		t.Errorf("ListUnspent failed unexpected error: %v\n", err)
+		return
+	}
+
+	if calls != len(addresses) {
+		t.Errorf("each address should be queried once, calls: %d", calls)
+	}
+	if len(utxos) != 30 {
+		t.Errorf("utxos should be 30, got %d", len(utxos))
+		return
+	}
+	for i, u := range utxos {
+		if u.Address != addresses[i] {
+			t.Errorf("utxo %d address: %s, expected: %s", i, u.Address, addresses[i])
+		}
+	}
+
+	//查询失败的地址返回错误，不静默跳过
+	if _, err := wm.ListUnspent(0, append(addresses, "bad")...); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("ListUnspent should report failed addresses, err: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	InvoiceIDParam  = "invoiceID"  //入账输出匹配的账单ID
+	InvoiceIDsParam = "invoiceIDs" //交易单匹配的全部账单ID
+)
+
+//InvoicePayment 观测地址收到的入账，用于匹配待支付账单
+type InvoicePayment struct {
+	SourceKey string
+	Address   string
+	AssetID   string //资产ID，小写不带0x前缀
+	Amount    decimal.Decimal
+	TxID      string
+	Index     uint64
+}
+
+//paymentKey 入账输出的唯一标识，重扫时匹配到同一账单
+func (p *InvoicePayment) paymentKey() string {
+	return fmt.Sprintf("%s_%d", p.TxID, p.Index)
+}
+
+//InvoiceMatcher 按入账金额匹配待支付账单的接口，用于以唯一金额而不是唯一地址识别付款，
+//返回匹配的账单ID，未匹配返回空
+type InvoiceMatcher interface {
+	MatchInvoice(payment *InvoicePayment) (string, error)
+}
+
+//SetInvoiceMatcher 设置待支付账单匹配，传入nil关闭匹配
+func (wm *WalletManager) SetInvoiceMatcher(matcher InvoiceMatcher) {
+	wm.InvoiceMatcher = matcher
+}
+
+//Invoice 待支付账单
+type Invoice struct {
+	ID       string
+	Address  string //收款地址，为空时匹配任意观测地址
+	AssetID  string //资产ID
+	Amount   decimal.Decimal
+	CreateAt int64
+}
+
+//InvoiceRegistry 内存中的待支付账单登记，Tolerance为0时金额须完全相等，
+//否则匹配差额不超过Tolerance且最接近的账单，匹配后账单关闭，重启后须重新登记
+type InvoiceRegistry struct {
+	Tolerance decimal.Decimal
+
+	mu       sync.Mutex
+	invoices map[string]*Invoice
+	paid     map[string]string //入账输出 -> 账单ID
+}
+
+func NewInvoiceRegistry(tolerance decimal.Decimal) *InvoiceRegistry {
+	return &InvoiceRegistry{
+		Tolerance: tolerance,
+		invoices:  make(map[string]*Invoice),
+		paid:      make(map[string]string),
+	}
+}
+
+//OpenInvoice 登记待支付账单
+func (r *InvoiceRegistry) OpenInvoice(invoice *Invoice) error {
+
+	if invoice == nil || len(invoice.ID) == 0 {
+		return fmt.Errorf("invoice id is empty")
+	}
+	if !invoice.Amount.IsPositive() {
+		return fmt.Errorf("invoice: %s amount must be positive", invoice.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exist := r.invoices[invoice.ID]; exist {
+		return fmt.Errorf("invoice: %s is already open", invoice.ID)
+	}
+
+	obj := *invoice
+	obj.AssetID = normalizeAssetID(obj.AssetID)
+	if obj.CreateAt == 0 {
+		obj.CreateAt = time.Now().UnixNano()
+	}
+	r.invoices[obj.ID] = &obj
+
+	return nil
+}
+
+//CloseInvoice 关闭待支付账单，不再参与匹配
+func (r *InvoiceRegistry) CloseInvoice(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.invoices, id)
+}
+
+//OpenInvoices 获取待支付账单，按登记时间排序
+func (r *InvoiceRegistry) OpenInvoices() []*Invoice {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]*Invoice, 0, len(r.invoices))
+	for _, invoice := range r.invoices {
+		obj := *invoice
+		list = append(list, &obj)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreateAt != list[j].CreateAt {
+			return list[i].CreateAt < list[j].CreateAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+//MatchInvoice 实现InvoiceMatcher，差额相同时匹配先登记的账单
+func (r *InvoiceRegistry) MatchInvoice(payment *InvoicePayment) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := payment.paymentKey()
+	if id, ok := r.paid[key]; ok {
+		return id, nil
+	}
+
+	var (
+		matched *Invoice
+		minDiff decimal.Decimal
+	)
+	for _, invoice := range r.invoices {
+		if invoice.AssetID != normalizeAssetID(payment.AssetID) {
+			continue
+		}
+		if len(invoice.Address) > 0 && invoice.Address != payment.Address {
+			continue
+		}
+		diff := payment.Amount.Sub(invoice.Amount).Abs()
+		if diff.GreaterThan(r.Tolerance) {
+			continue
+		}
+		if matched == nil || diff.LessThan(minDiff) ||
+			(diff.Equal(minDiff) && (invoice.CreateAt < matched.CreateAt || (invoice.CreateAt == matched.CreateAt && invoice.ID < matched.ID))) {
+			matched = invoice
+			minDiff = diff
+		}
+	}
+
+	if matched == nil {
+		return "", nil
+	}
+
+	delete(r.invoices, matched.ID)
+	r.paid[key] = matched.ID
+
+	return matched.ID, nil
+}
+
+//normalizeAssetID 资产ID统一为小写不带0x前缀
+func normalizeAssetID(assetID string) string {
+	return strings.TrimPrefix(strings.ToLower(assetID), "0x")
+}
+
+//attachInvoiceMatches 匹配观测地址纯入账的输出与待支付账单，账单ID记录到输出和交易单扩展参数，
+//同一交易有观测地址的输入时是找零，不匹配
+func (bs *NEOBlockScanner) attachInvoiceMatches(sourceKey string, extractData *openwallet.TxExtractData, trx *Transaction) {
+
+	matcher := bs.wm.InvoiceMatcher
+	if matcher == nil || len(extractData.TxInputs) > 0 || extractData.Transaction == nil {
+		return
+	}
+
+	vouts := make(map[uint64]*Vout)
+	for _, output := range trx.Vouts {
+		vouts[output.N] = output
+	}
+
+	invoiceIDs := make([]string, 0)
+	for _, output := range extractData.TxOutputs {
+		vout, ok := vouts[output.Index]
+		if !ok {
+			continue
+		}
+		amount, err := decimal.NewFromString(output.Amount)
+		if err != nil {
+			continue
+		}
+
+		id, err := matcher.MatchInvoice(&InvoicePayment{
+			SourceKey: sourceKey,
+			Address:   output.Address,
+			AssetID:   normalizeAssetID(vout.Asset),
+			Amount:    amount,
+			TxID:      output.TxID,
+			Index:     output.Index,
+		})
+		if err != nil {
+			bs.wm.Log.Std.Warning("tx: %s output: %d match invoice failed; unexpected error: %v", output.TxID, output.Index, err)
+			continue
+		}
+		if len(id) == 0 {
+			continue
+		}
+
+		output.SetExtParam(InvoiceIDParam, id)
+		invoiceIDs = append(invoiceIDs, id)
+	}
+
+	if len(invoiceIDs) > 0 {
+		extractData.Transaction.SetExtParam(InvoiceIDsParam, invoiceIDs)
+	}
+}
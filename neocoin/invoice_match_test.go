@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestInvoiceRegistry_MatchInvoice(t *testing.T) {
+	const gas = "602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7"
+
+	r := NewInvoiceRegistry(decimal.Zero)
+	r.OpenInvoice(&Invoice{ID: "a", AssetID: "0x" + gas, Amount: decimal.RequireFromString("1.0001")})
+	r.OpenInvoice(&Invoice{ID: "b", AssetID: gas, Amount: decimal.RequireFromString("1.0002"), Address: "AShop"})
+	if err := r.OpenInvoice(&Invoice{ID: "a", AssetID: gas, Amount: decimal.New(1, 0)}); err == nil {
+		t.Errorf("duplicate invoice should be rejected")
+	}
+
+	payment := func(txid, address, amount string) *InvoicePayment {
+		return &InvoicePayment{Address: address, AssetID: gas, Amount: decimal.RequireFromString(amount), TxID: txid}
+	}
+
+	//精确匹配
+	if id, _ := r.MatchInvoice(payment("tx1", "AOther", "1.0001")); id != "a" {
+		t.Errorf("exact match: %s", id)
+	}
+	//重扫同一输出匹配到同一账单
+	if id, _ := r.MatchInvoice(payment("tx1", "AOther", "1.0001")); id != "a" {
+		t.Errorf("rescan match: %s", id)
+	}
+	//已匹配的账单关闭
+	if id, _ := r.MatchInvoice(payment("tx2", "AOther", "1.0001")); id != "" {
+		t.Errorf("paid invoice should not match again: %s", id)
+	}
+	//收款地址不一致
+	if id, _ := r.MatchInvoice(payment("tx3", "AOther", "1.0002")); id != "" {
+		t.Errorf("invoice of other address should not match: %s", id)
+	}
+
+	//容差匹配最接近的账单
+	r = NewInvoiceRegistry(decimal.RequireFromString("0.001"))
+	r.OpenInvoice(&Invoice{ID: "c", AssetID: gas, Amount: decimal.RequireFromString("5.001")})
+	r.OpenInvoice(&Invoice{ID: "d", AssetID: gas, Amount: decimal.RequireFromString("5.002")})
+	if id, _ := r.MatchInvoice(payment("tx4", "AShop", "5.0018")); id != "d" {
+		t.Errorf("tolerance match: %s", id)
+	}
+	if id, _ := r.MatchInvoice(payment("tx5", "AShop", "5.003")); id != "" {
+		t.Errorf("out of tolerance should not match: %s", id)
+	}
+	if list := r.OpenInvoices(); len(list) != 1 || list[0].ID != "c" {
+		t.Errorf("open invoices: %v", list)
+	}
+}
+
+func TestNEOBlockScanner_AttachInvoiceMatches(t *testing.T) {
+	const neo = "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+	txid := testHash("tx")
+
+	wm := NewWalletManager()
+	registry := NewInvoiceRegistry(decimal.Zero)
+	registry.OpenInvoice(&Invoice{ID: "order-1", AssetID: neo, Amount: decimal.New(3, 0)})
+	wm.SetInvoiceMatcher(registry)
+
+	trx := &Transaction{TxID: txid, Vouts: []*Vout{{N: 0, Asset: "0x" + neo, Value: "3", Addr: "AShop"}}}
+	newExtractData := func() *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid}
+		data.TxOutputs = []*openwallet.TxOutPut{{Recharge: openwallet.Recharge{TxID: txid, Address: "AShop", Amount: "3", Index: 0}}}
+		return data
+	}
+
+	//找零不匹配
+	change := newExtractData()
+	change.TxInputs = []*openwallet.TxInput{{}}
+	wm.Blockscanner.attachInvoiceMatches("shop", change, trx)
+	if change.Transaction.GetExtParam().Get(InvoiceIDsParam).Exists() {
+		t.Errorf("change output should not match invoice")
+	}
+
+	data := newExtractData()
+	wm.Blockscanner.attachInvoiceMatches("shop", data, trx)
+	if id := data.TxOutputs[0].GetExtParam().Get(InvoiceIDParam).String(); id != "order-1" {
+		t.Errorf("output invoice id: %s", id)
+	}
+	if ids := data.Transaction.GetExtParam().Get(InvoiceIDsParam).Array(); len(ids) != 1 || ids[0].String() != "order-1" {
+		t.Errorf("transaction invoice ids: %v", ids)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	LabelTargetTx      = "tx"      //交易单标签
+	LabelTargetAddress = "address" //地址标签
+)
+
+//Label 运维人员对交易单或地址的标注
+type Label struct {
+	ID         string `storm:"id"`
+	Target     string `storm:"index"` //txid或地址
+	TargetType string
+	Label      string `storm:"index"`
+	Note       string
+	UpdateAt   int64
+}
+
+func NewLabel(targetType, target, label, note string) *Label {
+	obj := Label{}
+	obj.TargetType = targetType
+	obj.Target = target
+	obj.Label = label
+	obj.Note = note
+	obj.UpdateAt = time.Now().Unix()
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%s_%s", targetType, target))))
+	return &obj
+}
+
+//SetTxLabel 标注交易单
+func (wm *WalletManager) SetTxLabel(txid, label, note string) error {
+	return wm.saveLabel(NewLabel(LabelTargetTx, txid, label, note))
+}
+
+//GetTxLabel 获取交易单的标注
+func (wm *WalletManager) GetTxLabel(txid string) (*Label, error) {
+	return wm.getLabel(LabelTargetTx, txid)
+}
+
+//SetAddressLabel 标注地址
+func (wm *WalletManager) SetAddressLabel(address, label, note string) error {
+	return wm.saveLabel(NewLabel(LabelTargetAddress, address, label, note))
+}
+
+//GetAddressLabel 获取地址的标注
+func (wm *WalletManager) GetAddressLabel(address string) (*Label, error) {
+	return wm.getLabel(LabelTargetAddress, address)
+}
+
+//SearchByLabel 查询使用该标签的所有标注
+func (wm *WalletManager) SearchByLabel(label string) ([]*Label, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*Label
+	err = db.Find("Label", label, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (wm *WalletManager) saveLabel(label *Label) error {
+
+	if len(label.Target) == 0 {
+		return fmt.Errorf("label target is empty")
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(label)
+}
+
+func (wm *WalletManager) getLabel(targetType, target string) (*Label, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var label Label
+	err = db.One("ID", NewLabel(targetType, target, "", "").ID, &label)
+	if err != nil {
+		return nil, err
+	}
+
+	return &label, nil
+}
+
+//attachTxLabel 导出交易记录时，附带交易单的标注
+func (wm *WalletManager) attachTxLabel(tx *openwallet.Transaction) {
+
+	if tx == nil {
+		return
+	}
+
+	label, err := wm.GetTxLabel(tx.TxID)
+	if err != nil {
+		return
+	}
+
+	tx.SetExtParam("label", label.Label)
+	tx.SetExtParam("note", label.Note)
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalletManager_TxLabel(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	txid := "0x28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d"
+	err := wm.SetTxLabel(txid, "incident-01", "double deposit")
+	if err != nil {
+		t.Errorf("SetTxLabel failed unexpected error: %v\n", err)
+		return
+	}
+
+	wm.SetAddressLabel("AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT", "incident-01", "")
+
+	label, err := wm.GetTxLabel(txid)
+	if err != nil {
+		t.Errorf("GetTxLabel failed unexpected error: %v\n", err)
+		return
+	}
+	if label.Note != "double deposit" {
+		t.Errorf("label note: %s", label.Note)
+	}
+
+	list, err := wm.SearchByLabel("incident-01")
+	if err != nil {
+		t.Errorf("SearchByLabel failed unexpected error: %v\n", err)
+		return
+	}
+	if len(list) != 2 {
+		t.Errorf("SearchByLabel count: %d, expected: 2", len(list))
+	}
+}
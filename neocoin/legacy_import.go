@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	//legacyImportBlockWindow 导入扫描位置以下的区块头数量，用于分叉检查
+	legacyImportBlockWindow = 1000
+)
+
+//LegacyImportResult 旧版数据导入结果
+type LegacyImportResult struct {
+	Height        uint64 //导入的扫描高度
+	Hash          string //导入的扫描区块hash
+	Blocks        int    //导入的区块头数量
+	UnscanRecords int    //导入的未扫记录数量
+}
+
+//ImportLegacyScanState 读取bitcoin系适配器的本地数据库，包括blockchain集合的blockHeight和blockHash、
+//区块记录和未扫记录，转换后写入区块链数据访问接口，保留原扫描位置。
+//旧数据库以只读方式打开，导入期间应停止扫描器；已有扫描位置时，需overwrite为true才覆盖
+func (bs *NEOBlockScanner) ImportLegacyScanState(path string, overwrite bool) (*LegacyImportResult, error) {
+
+	if bs.BlockchainDAI == nil {
+		return nil, fmt.Errorf("Blockchain DAI is not setup ")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "open legacy db: %s failed, unexpected error: %v", path, err)
+	}
+
+	db, err := storm.Open(path, storm.BoltOptions(0600, &bolt.Options{ReadOnly: true, Timeout: bs.wm.config().DBLockTimeout}))
+	if err == bolt.ErrTimeout {
+		return nil, bs.wm.errorf(ErrStorageBusy, "local db: %s is locked by another process, retry after %v or stop the other process", path, bs.wm.config().DBLockTimeout)
+	}
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "open legacy db: %s failed, unexpected error: %v", path, err)
+	}
+	defer db.Close()
+
+	result := &LegacyImportResult{}
+
+	err = db.Get(blockchainBucket, "blockHeight", &result.Height)
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "legacy db has no scan position, unexpected error: %v", err)
+	}
+	db.Get(blockchainBucket, "blockHash", &result.Hash)
+
+	if !overwrite {
+		height, _, err := bs.GetLocalNewBlock()
+		if err == nil && height > 0 && height != result.Height {
+			return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "scan position: %d already exists, legacy scan position: %d is not imported", height, result.Height)
+		}
+	}
+
+	//扫描位置以下的区块头
+	var (
+		blocks []*Block
+		from   uint64
+	)
+	if result.Height > legacyImportBlockWindow {
+		from = result.Height - legacyImportBlockWindow
+	}
+	err = db.Range("Height", from, result.Height, &blocks)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "read legacy blocks failed, unexpected error: %v", err)
+	}
+
+	for _, block := range blocks {
+		if err = bs.SaveLocalBlock(block); err != nil {
+			return nil, err
+		}
+		result.Blocks++
+	}
+
+	//旧版未扫记录没有symbol，按当前symbol重新生成ID
+	var records []*UnscanRecord
+	err = db.All(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "read legacy unscan records failed, unexpected error: %v", err)
+	}
+
+	for _, r := range records {
+		//已软删除的记录不再需要重扫
+		if r.Deleted {
+			continue
+		}
+		record := openwallet.NewUnscanRecord(r.BlockHeight, r.TxID, r.Reason, bs.wm.Symbol())
+		if err = bs.BlockchainDAI.SaveUnscanRecord(record); err != nil {
+			return nil, err
+		}
+		result.UnscanRecords++
+	}
+
+	//最后写入扫描位置，中途失败不会跳过未导入的数据
+	err = bs.SaveLocalNewBlock(result.Height, result.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	bs.wm.Log.Std.Info("legacy scan state imported, height: %d, blocks: %d, unscan records: %d", result.Height, result.Blocks, result.UnscanRecords)
+
+	return result, nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//testBlockchainDAI 内存中的区块链数据访问接口
+type testBlockchainDAI struct {
+	openwallet.BlockchainDAIBase
+	current *openwallet.BlockHeader
+	headers map[uint64]*openwallet.BlockHeader
+	unscan  map[string]*openwallet.UnscanRecord
+}
+
+func newTestBlockchainDAI() *testBlockchainDAI {
+	return &testBlockchainDAI{
+		headers: make(map[uint64]*openwallet.BlockHeader),
+		unscan:  make(map[string]*openwallet.UnscanRecord),
+	}
+}
+
+func (dai *testBlockchainDAI) SaveCurrentBlockHead(header *openwallet.BlockHeader) error {
+	dai.current = header
+	return nil
+}
+
+func (dai *testBlockchainDAI) GetCurrentBlockHead(symbol string) (*openwallet.BlockHeader, error) {
+	if dai.current == nil {
+		return nil, storm.ErrNotFound
+	}
+	return dai.current, nil
+}
+
+func (dai *testBlockchainDAI) SaveLocalBlockHead(header *openwallet.BlockHeader) error {
+	dai.headers[header.Height] = header
+	return nil
+}
+
+func (dai *testBlockchainDAI) SaveUnscanRecord(record *openwallet.UnscanRecord) error {
+	dai.unscan[record.ID] = record
+	return nil
+}
+
+func TestNEOBlockScanner_ImportLegacyScanState(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "neo-legacy")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blockchain.db")
+
+	//bitcoin系适配器的旧版数据
+	db, err := storm.Open(path)
+	if err != nil {
+		t.Errorf("open legacy db failed unexpected error: %v\n", err)
+		return
+	}
+	db.Set(blockchainBucket, "blockHeight", uint64(1500))
+	db.Set(blockchainBucket, "blockHash", "0x1500")
+	db.Save(&Block{Height: 100, Hash: "0x100"})
+	db.Save(&Block{Height: 1499, Hash: "0x1499", Previousblockhash: "0x1498"})
+	db.Save(&Block{Height: 1500, Hash: "0x1500", Previousblockhash: "0x1499"})
+	db.Save(NewUnscanRecord(1200, "0xabc", "rpc timeout"))
+	db.Close()
+
+	wm := NewWalletManager()
+	dai := newTestBlockchainDAI()
+	wm.Blockscanner.SetBlockchainDAI(dai)
+
+	result, err := wm.Blockscanner.ImportLegacyScanState(path, false)
+	if err != nil {
+		t.Errorf("ImportLegacyScanState failed unexpected error: %v\n", err)
+		return
+	}
+
+	//窗口外的区块头不导入
+	if result.Height != 1500 || result.Blocks != 2 || result.UnscanRecords != 1 {
+		t.Errorf("unexpected import result: %+v", result)
+	}
+
+	height, hash, _ := wm.Blockscanner.GetLocalNewBlock()
+	if height != 1500 || hash != "0x1500" {
+		t.Errorf("scan position should be kept, height: %d, hash: %s", height, hash)
+	}
+	if h := dai.headers[1499]; h == nil || h.Previousblockhash != "0x1498" || h.Symbol != wm.Symbol() {
+		t.Errorf("unexpected imported header: %+v", h)
+	}
+	record := openwallet.NewUnscanRecord(1200, "0xabc", "rpc timeout", wm.Symbol())
+	if r := dai.unscan[record.ID]; r == nil || r.Reason != "rpc timeout" {
+		t.Errorf("unexpected imported unscan record: %+v", r)
+	}
+
+	//已有其他扫描位置时不覆盖
+	wm.Blockscanner.SaveLocalNewBlock(1600, "0x1600")
+	if _, err = wm.Blockscanner.ImportLegacyScanState(path, false); err == nil {
+		t.Errorf("existing scan position should not be overwritten")
+	}
+	if _, err = wm.Blockscanner.ImportLegacyScanState(path, true); err != nil {
+		t.Errorf("ImportLegacyScanState overwrite failed unexpected error: %v\n", err)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+)
+
+const (
+	ShedWorkMempool     = "mempool"     //扫描交易内存池
+	ShedWorkReconcile   = "reconcile"   //多节点分歧检查
+	ShedWorkStats       = "stats"       //网络费样本和区块资产汇总
+	ShedWorkMaintenance = "maintenance" //定时维护任务
+)
+
+//LoadShedStatus 追赶区块期间推迟低优先级工作的状态
+type LoadShedStatus struct {
+	Shedding bool              //是否正在推迟低优先级工作
+	Since    int64             //开始推迟的时间
+	Lag      uint64            //最近一次记录的落后区块数量
+	Deferred map[string]uint64 //本轮追赶各类工作推迟的次数
+}
+
+//updateLoadShedding 按落后区块数量切换追赶状态，落后超过loadShedBehind进入，
+//回到loadShedResume以内退出，两个阈值之间保持原状态避免来回切换
+func (bs *NEOBlockScanner) updateLoadShedding(currentHeight, maxHeight uint64) {
+
+	var (
+		behind = bs.wm.config().LoadShedBehind
+		resume = bs.wm.config().LoadShedResume
+		lag    uint64
+	)
+	if maxHeight > currentHeight {
+		lag = maxHeight - currentHeight
+	}
+
+	bs.shedMu.Lock()
+	defer bs.shedMu.Unlock()
+
+	bs.shedStatus.Lag = lag
+
+	switch {
+	case !bs.shedStatus.Shedding && behind > 0 && lag > behind:
+		bs.shedStatus.Shedding = true
+		bs.shedStatus.Since = time.Now().Unix()
+		bs.shedStatus.Deferred = make(map[string]uint64)
+		bs.wm.Log.Std.Info("block scanner is %d blocks behind tip, defer low priority work until within %d blocks", lag, resume)
+	case bs.shedStatus.Shedding && (behind == 0 || lag <= resume):
+		bs.shedStatus.Shedding = false
+		bs.wm.Log.Std.Info("block scanner caught up to %d blocks behind tip in %v, resume low priority work, deferred: %v",
+			lag, time.Since(time.Unix(bs.shedStatus.Since, 0)), bs.shedStatus.Deferred)
+	}
+}
+
+//shedLoad 追赶区块期间推迟低优先级工作，返回true时调用者跳过该工作
+func (bs *NEOBlockScanner) shedLoad(work string) bool {
+
+	bs.shedMu.Lock()
+	defer bs.shedMu.Unlock()
+
+	if !bs.shedStatus.Shedding {
+		return false
+	}
+
+	bs.shedStatus.Deferred[work]++
+
+	return true
+}
+
+//IsSheddingLoad 是否正在追赶区块并推迟低优先级工作
+func (bs *NEOBlockScanner) IsSheddingLoad() bool {
+	bs.shedMu.Lock()
+	defer bs.shedMu.Unlock()
+	return bs.shedStatus.Shedding
+}
+
+//GetLoadShedStatus 获取推迟低优先级工作的状态
+func (bs *NEOBlockScanner) GetLoadShedStatus() *LoadShedStatus {
+
+	bs.shedMu.Lock()
+	defer bs.shedMu.Unlock()
+
+	status := bs.shedStatus
+	status.Deferred = make(map[string]uint64, len(bs.shedStatus.Deferred))
+	for work, count := range bs.shedStatus.Deferred {
+		status.Deferred[work] = count
+	}
+
+	return &status
+}
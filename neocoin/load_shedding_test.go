@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestNEOBlockScanner_LoadShedding(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.LoadShedBehind = 100
+	wm.Config.LoadShedResume = 10
+	wm.Config.StartupMempoolSync = true
+	bs := wm.Blockscanner
+	bs.IsScanMemPool = true
+
+	//落后未超过阈值时不推迟
+	bs.updateLoadShedding(1000, 1100)
+	if bs.IsSheddingLoad() || bs.shedLoad(ShedWorkStats) {
+		t.Fatalf("should not shed load when 100 blocks behind")
+	}
+
+	//落后超过阈值后推迟低优先级工作
+	bs.updateLoadShedding(1000, 1101)
+	if !bs.IsSheddingLoad() {
+		t.Fatalf("should shed load when 101 blocks behind")
+	}
+	if bs.syncMempoolOnStartup() || bs.mempoolSynced {
+		t.Errorf("startup mempool sync should be deferred")
+	}
+	bs.shedLoad(ShedWorkStats)
+	bs.shedLoad(ShedWorkStats)
+
+	//两个阈值之间保持推迟
+	bs.updateLoadShedding(1080, 1101)
+	status := bs.GetLoadShedStatus()
+	if !status.Shedding || status.Lag != 21 || status.Deferred[ShedWorkMempool] != 1 || status.Deferred[ShedWorkStats] != 2 {
+		t.Fatalf("unexpected load shed status: %+v", status)
+	}
+
+	//返回的是副本
+	status.Deferred[ShedWorkStats] = 0
+	if bs.GetLoadShedStatus().Deferred[ShedWorkStats] != 2 {
+		t.Errorf("load shed status should be copied")
+	}
+
+	//回到恢复阈值以内恢复
+	bs.updateLoadShedding(1091, 1101)
+	if bs.IsSheddingLoad() || bs.shedLoad(ShedWorkMempool) {
+		t.Errorf("should resume low priority work when 10 blocks behind")
+	}
+
+	//未启用时不推迟，已推迟的恢复
+	bs.updateLoadShedding(0, 1000)
+	wm.Config.LoadShedBehind = 0
+	bs.updateLoadShedding(0, 1000)
+	if bs.IsSheddingLoad() {
+		t.Errorf("disabled load shedding should resume")
+	}
+}
@@ -0,0 +1,365 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asdine/storm"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	//BackupFormatVersion 备份文件格式版本
+	BackupFormatVersion = 1
+
+	backupManifestEntry = "manifest.json"  //备份清单，备份流的第一个条目
+	backupChecksumEntry = "checksums.json" //数据库文件的sha256，备份流的最后一个条目
+	backupRestoreSuffix = ".restore"       //恢复时的临时文件后缀
+)
+
+//BackupManifest 备份清单，记录备份的数据结构版本和所属网络，恢复时校验
+type BackupManifest struct {
+	Format        int      `json:"format"`
+	SchemaVersion int      `json:"schemaVersion"`
+	Symbol        string   `json:"symbol"`
+	Magic         uint32   `json:"magic"`
+	Encrypted     bool     `json:"encrypted"` //数据库记录已加密，恢复时需使用相同的密钥
+	Files         []string `json:"files"`
+	CreateAt      int64    `json:"createAt"`
+}
+
+//localDBFiles 适配器的本地数据库文件，包括扫描状态、未花索引、提取结果、标签的区块链数据库，交易索引和审计日志
+func (wm *WalletManager) localDBFiles() []string {
+	cfg := wm.config()
+	return []string{cfg.BlockchainFile, cfg.TxIndexFile, cfg.AuditLogFile}
+}
+
+//Backup 把本地数据库的一致快照写入w，格式为tar，依次为备份清单、各数据库文件和校验和，
+//备份期间与扫描周期互斥，各文件处于同一扫描高度
+func (wm *WalletManager) Backup(w io.Writer) (*BackupManifest, error) {
+
+	if err := wm.checkLocalNetwork(); err != nil {
+		return nil, err
+	}
+
+	wm.scanCycleMu.Lock()
+	defer wm.scanCycleMu.Unlock()
+
+	version, err := wm.GetLocalSchemaVersion()
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get schema version failed, unexpected error: %v", err)
+	}
+
+	key, err := wm.localDBKey()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := wm.config()
+	manifest := &BackupManifest{
+		Format:        BackupFormatVersion,
+		SchemaVersion: version,
+		Symbol:        cfg.Symbol,
+		Magic:         cfg.NetworkMagicValue(),
+		Encrypted:     key != nil,
+		Files:         make([]string, 0),
+		CreateAt:      time.Now().Unix(),
+	}
+	for _, file := range wm.localDBFiles() {
+		if _, err := os.Stat(filepath.Join(cfg.DBPath, file)); err == nil {
+			manifest.Files = append(manifest.Files, file)
+		}
+	}
+
+	tw := tar.NewWriter(w)
+
+	raw, _ := json.Marshal(manifest)
+	if err = writeTarEntry(tw, backupManifestEntry, raw); err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(manifest.Files))
+	for _, file := range manifest.Files {
+		sum, err := wm.backupLocalDB(tw, file)
+		if err != nil {
+			return nil, err
+		}
+		checksums[file] = sum
+	}
+
+	raw, _ = json.Marshal(checksums)
+	if err = writeTarEntry(tw, backupChecksumEntry, raw); err != nil {
+		return nil, err
+	}
+
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+
+	wm.Log.Std.Notice("local db backup finished, schema version: %d, files: %v", manifest.SchemaVersion, manifest.Files)
+
+	return manifest, nil
+}
+
+//backupLocalDB 在只读事务中把数据库文件写入备份，返回sha256
+func (wm *WalletManager) backupLocalDB(tw *tar.Writer, file string) (string, error) {
+
+	db, err := bolt.Open(filepath.Join(wm.config().DBPath, file), 0600, &bolt.Options{Timeout: wm.config().DBLockTimeout, ReadOnly: true})
+	if err == bolt.ErrTimeout {
+		return "", wm.errorf(ErrStorageBusy, "local db: %s is locked by another process, retry after %v or stop the other process", file, wm.config().DBLockTimeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	hash := sha256.New()
+	err = db.View(func(tx *bolt.Tx) error {
+		err := tw.WriteHeader(&tar.Header{Name: file, Mode: 0600, Size: tx.Size(), ModTime: time.Now()})
+		if err != nil {
+			return err
+		}
+		_, err = tx.WriteTo(io.MultiWriter(tw, hash))
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("backup local db: %s failed, unexpected error: %v", file, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+//Restore 从Backup生成的备份恢复本地数据库，校验清单、网络和校验和后整体替换，
+//备份中没有的数据库文件删除，恢复期间与扫描周期互斥。
+//备份的数据结构版本比适配器更新时拒绝恢复，较旧时由MigrateLocalDB升级
+func (wm *WalletManager) Restore(r io.Reader) (*BackupManifest, error) {
+
+	if err := wm.requireCapability(CapabilityDeleteLocal); err != nil {
+		return nil, err
+	}
+
+	if err := wm.checkLocalNetwork(); err != nil {
+		return nil, err
+	}
+
+	wm.scanCycleMu.Lock()
+	defer wm.scanCycleMu.Unlock()
+
+	var (
+		dir       = wm.config().DBPath
+		tr        = tar.NewReader(r)
+		manifest  *BackupManifest
+		checksums map[string]string
+		restored  = make(map[string]string)
+	)
+
+	//未完成时清除临时文件
+	defer func() {
+		for _, file := range wm.localDBFiles() {
+			os.Remove(filepath.Join(dir, file+backupRestoreSuffix))
+		}
+	}()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+		}
+
+		switch {
+		case manifest == nil:
+			if header.Name != backupManifestEntry {
+				return nil, wm.errorf(ErrLocalDBBackupInvalid, "backup entry: %s is unexpected", header.Name)
+			}
+			if manifest, err = wm.readBackupManifest(tr); err != nil {
+				return nil, err
+			}
+		case header.Name == backupChecksumEntry:
+			raw, err := ioutil.ReadAll(tr)
+			if err == nil {
+				err = json.Unmarshal(raw, &checksums)
+			}
+			if err != nil {
+				return nil, wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+			}
+		case checksums == nil && containsString(manifest.Files, header.Name) && restored[header.Name] == "":
+			tmp, err := os.OpenFile(filepath.Join(dir, header.Name+backupRestoreSuffix), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				return nil, err
+			}
+			hash := sha256.New()
+			_, err = io.Copy(io.MultiWriter(tmp, hash), tr)
+			if err == nil {
+				err = tmp.Sync()
+			}
+			tmp.Close()
+			if err != nil {
+				return nil, wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+			}
+			restored[header.Name] = hex.EncodeToString(hash.Sum(nil))
+		default:
+			return nil, wm.errorf(ErrLocalDBBackupInvalid, "backup entry: %s is unexpected", header.Name)
+		}
+	}
+
+	if manifest == nil || checksums == nil {
+		return nil, wm.errorf(ErrLocalDBBackupInvalid, "backup is incomplete")
+	}
+	for _, file := range manifest.Files {
+		if sum := restored[file]; sum == "" || sum != checksums[file] {
+			return nil, wm.errorf(ErrLocalDBBackupInvalid, "backup file: %s checksum mismatch", file)
+		}
+	}
+
+	if err := wm.verifyRestoredDB(manifest); err != nil {
+		return nil, err
+	}
+
+	//校验通过后整体替换，备份中没有的数据库文件删除
+	for _, file := range wm.localDBFiles() {
+		path := filepath.Join(dir, file)
+		if _, ok := restored[file]; ok {
+			if err := os.Rename(path+backupRestoreSuffix, path); err != nil {
+				return nil, wm.errorf(ErrLocalDBOperateFailed, "restore local db: %s failed, unexpected error: %v", file, err)
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, wm.errorf(ErrLocalDBOperateFailed, "restore local db: %s failed, unexpected error: %v", file, err)
+		}
+	}
+
+	//恢复的数据库重新检查编码器
+	wm.dbKeyMu.Lock()
+	wm.dbCodecReady = nil
+	wm.dbKeyMu.Unlock()
+
+	wm.Log.Std.Notice("local db restored, schema version: %d, files: %v, backup at: %s",
+		manifest.SchemaVersion, manifest.Files, time.Unix(manifest.CreateAt, 0).Format(time.RFC3339))
+
+	return manifest, nil
+}
+
+//readBackupManifest 读取并校验备份清单
+func (wm *WalletManager) readBackupManifest(r io.Reader) (*BackupManifest, error) {
+
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+	}
+
+	var manifest BackupManifest
+	if err = json.Unmarshal(raw, &manifest); err != nil {
+		return nil, wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+	}
+
+	if manifest.Format != BackupFormatVersion {
+		return nil, wm.errorf(ErrLocalDBBackupInvalid, "backup format: %d is not supported", manifest.Format)
+	}
+
+	if manifest.SchemaVersion > SchemaVersion {
+		return nil, wm.errorf(ErrLocalDBSchemaUnsupported, "local db schema version: %d is newer than adapter: %d", manifest.SchemaVersion, SchemaVersion)
+	}
+
+	cfg := wm.config()
+	if !(&LocalNetwork{Symbol: manifest.Symbol, Magic: manifest.Magic}).matches(cfg.Symbol, cfg.NetworkMagicValue()) {
+		return nil, wm.errorf(ErrLocalDBNetworkMismatch, "backup belongs to %s network: %d, current is %s network: %d", manifest.Symbol, manifest.Magic, cfg.Symbol, cfg.NetworkMagicValue())
+	}
+
+	for _, file := range manifest.Files {
+		if !containsString(wm.localDBFiles(), file) {
+			return nil, wm.errorf(ErrLocalDBBackupInvalid, "backup entry: %s is unexpected", file)
+		}
+	}
+
+	return &manifest, nil
+}
+
+//verifyRestoredDB 用当前密钥读取恢复的区块链数据库的数据结构版本，确认可以解密且与清单一致
+func (wm *WalletManager) verifyRestoredDB(manifest *BackupManifest) error {
+
+	file := wm.config().BlockchainFile
+	if !containsString(manifest.Files, file) {
+		return nil
+	}
+
+	key, err := wm.localDBKey()
+	if err != nil {
+		return err
+	}
+	if manifest.Encrypted && key == nil {
+		return wm.errorf(ErrLocalDBBackupInvalid, "backup is encrypted, local db key is required")
+	}
+
+	options := []func(*storm.Options) error{
+		storm.BoltOptions(0600, &bolt.Options{Timeout: wm.config().DBLockTimeout}),
+	}
+	if manifest.Encrypted {
+		c, err := newAESGCMCodec(key)
+		if err != nil {
+			return err
+		}
+		options = append(options, storm.Codec(c))
+	}
+
+	db, err := storm.Open(filepath.Join(wm.config().DBPath, file+backupRestoreSuffix), options...)
+	if err != nil {
+		return wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	version := 0
+	err = db.Get(schemaBucket, schemaVersionKey, &version)
+	if err != nil && err != storm.ErrNotFound {
+		return wm.errorf(ErrLocalDBBackupInvalid, "read backup failed, unexpected error: %v", err)
+	}
+	if version != manifest.SchemaVersion {
+		return wm.errorf(ErrLocalDBBackupInvalid, "backup schema version: %d is not equal to manifest: %d", version, manifest.SchemaVersion)
+	}
+
+	return nil
+}
+
+//writeTarEntry 写入一个tar条目
+func writeTarEntry(tw *tar.Writer, name string, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(content)
+	return err
+}
+
+//containsString 列表中是否有s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
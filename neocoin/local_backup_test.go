@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_BackupRestore(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	if err := wm.MigrateLocalDB(); err != nil {
+		t.Fatalf("MigrateLocalDB failed, unexpected error: %v", err)
+	}
+	wm.SaveLocalNewBlock(100, testHash("block100"))
+	wm.SetAddressLabel("A", "hot", "")
+
+	var backup bytes.Buffer
+	manifest, err := wm.Backup(&backup)
+	if err != nil {
+		t.Fatalf("Backup failed, unexpected error: %v", err)
+	}
+	if manifest.SchemaVersion != SchemaVersion || len(manifest.Files) != 1 || manifest.Files[0] != wm.Config.BlockchainFile {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	//升级后扫描继续，数据变化
+	wm.SaveLocalNewBlock(200, testHash("block200"))
+	wm.SetAddressLabel("A", "cold", "")
+	wm.TxIndex().SaveBlockTxs(200, []*IndexedTx{})
+
+	//校验和不一致时不替换
+	corrupted := append([]byte{}, backup.Bytes()...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	if _, err := wm.Restore(bytes.NewReader(corrupted)); err == nil {
+		t.Fatalf("corrupted backup should be rejected")
+	}
+	if height, _ := wm.GetLocalNewBlock(); height != 200 {
+		t.Fatalf("failed restore should keep local data, height: %d", height)
+	}
+
+	//其他网络的备份不恢复
+	other := NewWalletManager()
+	other.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	other.Config.IsTestNet = !wm.Config.IsTestNet
+	defer os.RemoveAll(other.Config.DBPath)
+	if _, err := other.Restore(bytes.NewReader(backup.Bytes())); err == nil {
+		t.Errorf("backup of other network should be rejected")
+	} else if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrLocalDBNetworkMismatch {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	//恢复到备份时的状态，备份中没有的数据库删除
+	if _, err := wm.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatalf("Restore failed, unexpected error: %v", err)
+	}
+	if height, hash := wm.GetLocalNewBlock(); height != 100 || hash != testHash("block100") {
+		t.Errorf("scan position should be restored, got: %d %s", height, hash)
+	}
+	if label, err := wm.GetAddressLabel("A"); err != nil || label.Label != "hot" {
+		t.Errorf("label should be restored, got: %+v, %v", label, err)
+	}
+	if _, err := os.Stat(wm.Config.DBPath + "/" + wm.Config.TxIndexFile); !os.IsNotExist(err) {
+		t.Errorf("db file not in backup should be removed")
+	}
+	if files, _ := ioutil.ReadDir(wm.Config.DBPath); len(files) != 2 {
+		t.Errorf("restore temporary files should be removed, got %d files", len(files))
+	}
+}
+
+func TestWalletManager_RestoreEncrypted(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.DBEncryptKey = "000102030405060708090a0b0c0d0e0f"
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	wm.MigrateLocalDB()
+	wm.SaveLocalNewBlock(100, testHash("block100"))
+
+	var backup bytes.Buffer
+	if manifest, err := wm.Backup(&backup); err != nil || !manifest.Encrypted {
+		t.Fatalf("Backup = %+v, %v", manifest, err)
+	}
+
+	//密钥不同时无法读取，拒绝恢复
+	wm.Config.DBEncryptKey = "0f0e0d0c0b0a09080706050403020100"
+	if _, err := wm.Restore(bytes.NewReader(backup.Bytes())); err == nil {
+		t.Errorf("encrypted backup should be rejected with other key")
+	}
+
+	wm.Config.DBEncryptKey = "000102030405060708090a0b0c0d0e0f"
+	if _, err := wm.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatalf("Restore failed, unexpected error: %v", err)
+	}
+	if height, _ := wm.GetLocalNewBlock(); height != 100 {
+		t.Errorf("scan position should be restored, got: %d", height)
+	}
+}
@@ -0,0 +1,433 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/codec"
+	bolt "go.etcd.io/bbolt"
+)
+
+//DBKeyProvider 本地数据库加密密钥提供者，可接入KMS
+type DBKeyProvider func() ([]byte, error)
+
+//SetDBKeyProvider 设置本地数据库加密密钥提供者，优先于配置的密钥
+func (wm *WalletManager) SetDBKeyProvider(provider DBKeyProvider) {
+	wm.dbKeyMu.Lock()
+	defer wm.dbKeyMu.Unlock()
+	wm.dbKeyProvider = provider
+	wm.dbKey = nil
+	wm.dbCodecReady = nil
+}
+
+//localDBKey 获取本地数据库加密密钥，未开启加密返回nil
+func (wm *WalletManager) localDBKey() ([]byte, error) {
+	wm.dbKeyMu.Lock()
+	defer wm.dbKeyMu.Unlock()
+
+	var (
+		key []byte
+		err error
+	)
+
+	if wm.dbKeyProvider != nil {
+		if wm.dbKey != nil {
+			return wm.dbKey, nil
+		}
+		key, err = wm.dbKeyProvider()
+		if err != nil {
+			return nil, fmt.Errorf("get local db key from provider failed, unexpected error: %v", err)
+		}
+	} else if len(wm.config().DBEncryptKey) > 0 {
+		key, err = hex.DecodeString(strings.TrimPrefix(wm.config().DBEncryptKey, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("local db key is not hex, unexpected error: %v", err)
+		}
+	} else {
+		return nil, nil
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("local db key length: %d is invalid, must be 16, 24 or 32 bytes", len(key))
+	}
+
+	//只缓存密钥提供者的结果，避免每次打开数据库都请求KMS
+	if wm.dbKeyProvider != nil {
+		wm.dbKey = key
+	}
+	return key, nil
+}
+
+//openLocalDB 打开本地数据库，开启加密时使用AES-GCM编码记录
+//数据库文件被其他进程占用时，超过等待时间返回ErrStorageBusy
+func (wm *WalletManager) openLocalDB(file string) (*storm.DB, error) {
+
+	key, err := wm.localDBKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wm.checkLocalNetwork(); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(wm.config().DBPath, file)
+	options := []func(*storm.Options) error{
+		storm.BoltOptions(0600, &bolt.Options{Timeout: wm.config().DBLockTimeout}),
+	}
+
+	if key != nil {
+		c, err := newAESGCMCodec(key)
+		if err != nil {
+			return nil, err
+		}
+		if err = wm.prepareLocalDBCodec(path, c); err != nil {
+			return nil, err
+		}
+		options = append(options, storm.Codec(c))
+	}
+
+	for {
+		before, statErr := os.Stat(path)
+
+		db, err := storm.Open(path, options...)
+		if err == bolt.ErrTimeout {
+			busyErr := wm.errorf(ErrStorageBusy, "local db: %s is locked by another process, retry after %v or stop the other process", file, wm.config().DBLockTimeout)
+			wm.Blockscanner.recordScanError(ScanErrorSourceDB, 0, busyErr)
+			wm.Blockscanner.setDegraded(DegradedDBBusy, true, busyErr)
+			return nil, busyErr
+		}
+		if err == nil {
+			wm.Blockscanner.setDegraded(DegradedDBBusy, false, nil)
+		}
+		if err != nil || statErr != nil {
+			return db, err
+		}
+
+		//等待文件锁期间数据库被压缩替换，打开的是已删除的旧文件，重新打开
+		if after, err := os.Stat(path); err == nil && !os.SameFile(before, after) {
+			db.Close()
+			continue
+		}
+
+		return db, nil
+	}
+}
+
+//CompactLocalDB 压缩本地数据库，回收已删除记录占用的空间，返回压缩前后的文件大小
+//压缩到临时文件后替换原文件，期间持有原文件锁，等待锁的打开者发现文件已替换后重新打开
+func (wm *WalletManager) CompactLocalDB() (int64, int64, error) {
+
+	path := filepath.Join(wm.config().DBPath, wm.config().BlockchainFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	src, err := bolt.Open(path, 0600, &bolt.Options{Timeout: wm.config().DBLockTimeout})
+	if err == bolt.ErrTimeout {
+		return 0, 0, wm.errorf(ErrStorageBusy, "local db: %s is locked by another process, retry after %v or stop the other process", wm.config().BlockchainFile, wm.config().DBLockTimeout)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.Close()
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	//bolt提交时已同步到磁盘
+	err = compactBolt(dst, src)
+	dst.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, err
+	}
+
+	compacted, err := os.Stat(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, err
+	}
+
+	//替换文件，不改写仍被映射的原文件
+	if err = os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, err
+	}
+
+	return info.Size(), compacted.Size(), nil
+}
+
+//compactBolt 复制所有bucket到新数据库，包括嵌套bucket和自增序号
+func compactBolt(dst, src *bolt.DB) error {
+	return src.View(func(stx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				nb, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBoltBucket(nb, b)
+			})
+		})
+	})
+}
+
+//copyBoltBucket 递归复制bucket内容
+func copyBoltBucket(dst, src *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBoltBucket(nb, src.Bucket(k))
+	})
+}
+
+const (
+	//codecMarkerBucket 记录数据库当前编码器的bucket
+	codecMarkerBucket = "__neo_codec"
+	codecMarkerKey    = "name"
+	//stormMetadataBucket storm记录编码器名称的bucket
+	stormMetadataBucket = "__storm_metadata"
+	//aesGCMNonceLabel 由加密密钥派生nonce密钥的标签
+	aesGCMNonceLabel = "neo-adapter local db nonce"
+)
+
+//codecIndexedRecords 索引字段不是字符串或整数的记录，storm用编码器生成其索引键，
+//更换编码器后需要重建索引
+var codecIndexedRecords = []interface{}{
+	&ConfirmPendingRecord{},
+	&TxApproval{},
+}
+
+//prepareLocalDBCodec 每个数据库文件检查一次编码器，明文或旧版加密的数据库迁移到当前编码器
+func (wm *WalletManager) prepareLocalDBCodec(path string, c *aesGCMCodec) error {
+
+	wm.dbKeyMu.Lock()
+	defer wm.dbKeyMu.Unlock()
+
+	if wm.dbCodecReady[path] {
+		return nil
+	}
+
+	migrated, err := migrateLocalDBCodec(path, c, wm.config().DBLockTimeout)
+	if err == bolt.ErrTimeout {
+		return wm.errorf(ErrStorageBusy, "local db: %s is locked by another process, retry after %v or stop the other process", filepath.Base(path), wm.config().DBLockTimeout)
+	}
+	if err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "migrate local db: %s to codec: %s failed, unexpected error: %v", filepath.Base(path), c.Name(), err)
+	}
+
+	if migrated {
+		db, err := storm.Open(path, storm.Codec(c), storm.BoltOptions(0600, &bolt.Options{Timeout: wm.config().DBLockTimeout}))
+		if err != nil {
+			return err
+		}
+		for _, record := range codecIndexedRecords {
+			if !hasStormBucket(db, record) {
+				continue
+			}
+			if err = db.ReIndex(record); err != nil && err != storm.ErrNotFound {
+				db.Close()
+				return wm.errorf(ErrLocalDBOperateFailed, "reindex local db: %s failed, unexpected error: %v", filepath.Base(path), err)
+			}
+		}
+		db.Close()
+		wm.Log.Std.Info("local db: %s migrated to codec: %s", filepath.Base(path), c.Name())
+	}
+
+	if wm.dbCodecReady == nil {
+		wm.dbCodecReady = make(map[string]bool)
+	}
+	wm.dbCodecReady[path] = true
+	return nil
+}
+
+//hasStormBucket 记录类型的bucket是否存在，storm重建不存在的bucket索引会panic
+func hasStormBucket(db *storm.DB, record interface{}) bool {
+	name := reflect.TypeOf(record).Elem().Name()
+	exist := false
+	db.Bolt.View(func(tx *bolt.Tx) error {
+		exist = tx.Bucket([]byte(name)) != nil
+		return nil
+	})
+	return exist
+}
+
+//migrateLocalDBCodec 把数据库的记录重新编码为c，已是当前编码器时返回false。
+//能用同一密钥解密的记录保留，明文json记录加密，storm元数据的编码器名称同步更新
+func migrateLocalDBCodec(path string, c *aesGCMCodec, timeout time.Duration) (bool, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: timeout})
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	migrated := false
+	err = db.Update(func(tx *bolt.Tx) error {
+
+		marker, err := tx.CreateBucketIfNotExists([]byte(codecMarkerBucket))
+		if err != nil {
+			return err
+		}
+		if string(marker.Get([]byte(codecMarkerKey))) == c.Name() {
+			return nil
+		}
+
+		err = tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if string(name) == codecMarkerBucket {
+				return nil
+			}
+			return c.reencodeBucket(b)
+		})
+		if err != nil {
+			return err
+		}
+
+		migrated = true
+		return marker.Put([]byte(codecMarkerKey), []byte(c.Name()))
+	})
+
+	return migrated, err
+}
+
+//reencodeBucket 重新编码bucket内的记录，索引bucket由storm重建
+func (c *aesGCMCodec) reencodeBucket(b *bolt.Bucket) error {
+
+	type kv struct{ k, v []byte }
+	var records []kv
+	err := b.ForEach(func(k, v []byte) error {
+		if v != nil {
+			records = append(records, kv{k: append([]byte{}, k...), v: append([]byte{}, v...)})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		plain, err := c.open(r.v)
+		if err != nil {
+			if !json.Valid(r.v) {
+				return fmt.Errorf("record: %x can not be decrypted or decoded", r.k)
+			}
+			plain = r.v
+		}
+		if err = b.Put(r.k, c.seal(plain)); err != nil {
+			return err
+		}
+	}
+
+	if meta := b.Bucket([]byte(stormMetadataBucket)); meta != nil {
+		return meta.Put([]byte("codec"), []byte(c.Name()))
+	}
+	return nil
+}
+
+//aesGCMCodec 使用AES-GCM加密json编码后的记录，nonce由记录内容的HMAC派生，
+//相同内容的编码结果相同，storm经编码器生成的索引键（如bool、自定义类型字段）可以查找。
+//注意：记录ID和字符串、整数类型的索引字段storm不经过编码器，仍为明文
+type aesGCMCodec struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+func newAESGCMCodec(key []byte) (*aesGCMCodec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(aesGCMNonceLabel))
+	return &aesGCMCodec{aead: aead, nonceKey: mac.Sum(nil)}, nil
+}
+
+//seal 加密，nonce放在密文前
+func (c *aesGCMCodec) seal(plain []byte) []byte {
+	mac := hmac.New(sha256.New, c.nonceKey)
+	mac.Write(plain)
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+	return c.aead.Seal(nonce, nonce, plain, nil)
+}
+
+//open 解密
+func (c *aesGCMCodec) open(b []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(b) < size {
+		return nil, errors.New("local db record is too short to decrypt")
+	}
+	return c.aead.Open(nil, b[:size], b[size:], nil)
+}
+
+//Marshal 编码并加密
+func (c *aesGCMCodec) Marshal(v interface{}) ([]byte, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.seal(plain), nil
+}
+
+//Unmarshal 解密并解码
+func (c *aesGCMCodec) Unmarshal(b []byte, v interface{}) error {
+	plain, err := c.open(b)
+	if err != nil {
+		return fmt.Errorf("decrypt local db record failed, unexpected error: %v", err)
+	}
+	return json.Unmarshal(plain, v)
+}
+
+//Name 编码器名称，storm用于检查数据库与编码器是否匹配。
+//旧版本名称为aesgcm-siv-json，密钥和编码方式相同，名称不一致时由migrateLocalDBCodec更新
+func (c *aesGCMCodec) Name() string {
+	return "aesgcm-hmacnonce-json"
+}
+
+var _ codec.MarshalUnmarshaler = (*aesGCMCodec)(nil)
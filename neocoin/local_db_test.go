@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestWalletManager_EncryptedLocalDB(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.DBEncryptKey = "000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f"
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	wm.SaveLocalBlock(&Block{Hash: "0xsecretblockhash", Height: 10})
+
+	block, err := wm.GetLocalBlock(10)
+	if err != nil || block.Hash != "0xsecretblockhash" {
+		t.Errorf("GetLocalBlock failed, block: %v, unexpected error: %v\n", block, err)
+		return
+	}
+
+	raw, _ := ioutil.ReadFile(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	if bytes.Contains(raw, []byte("secretblockhash")) {
+		t.Errorf("local db record should be encrypted")
+	}
+
+	//密钥提供者返回错误密钥时无法解密
+	wm.SetDBKeyProvider(func() ([]byte, error) {
+		return bytes.Repeat([]byte{1}, 32), nil
+	})
+	if _, err := wm.GetLocalBlock(10); err == nil {
+		t.Errorf("GetLocalBlock should fail with wrong key")
+	}
+}
+
+func TestWalletManager_LocalDBLocked(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.DBLockTimeout = 100 * time.Millisecond
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//模拟其他进程占用数据库文件
+	holder, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	if err != nil {
+		t.Errorf("open local db failed, unexpected error: %v\n", err)
+		return
+	}
+
+	err = wm.SaveLocalNewBlock(10, "0x01")
+	if err == nil || openwallet.ConvertError(err).Code() != ErrStorageBusy {
+		t.Errorf("SaveLocalNewBlock should fail with storage busy, err: %v", err)
+	}
+
+	holder.Close()
+	if err := wm.SaveLocalNewBlock(10, "0x01"); err != nil {
+		t.Errorf("SaveLocalNewBlock failed unexpected error: %v\n", err)
+	}
+}
+
+func TestWalletManager_EncryptedLocalDBIndex(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.DBEncryptKey = "000102030405060708090a0b0c0d0e0f"
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	db, err := wm.openLocalDB(wm.Config.BlockchainFile)
+	if err != nil {
+		t.Errorf("open local db failed, unexpected error: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	db.Save(&ConfirmPendingRecord{ID: "a", Notified: true})
+	db.Save(&ConfirmPendingRecord{ID: "b", Notified: false})
+	db.Save(&TxApproval{TxID: "c", Status: ApprovalApproved})
+	db.Save(&TxApproval{TxID: "d", Status: ApprovalRejected})
+
+	//经编码器生成的索引键需要确定，才能按索引查找
+	var notified []*ConfirmPendingRecord
+	if err := db.Find("Notified", true, &notified); err != nil || len(notified) != 1 || notified[0].ID != "a" {
+		t.Errorf("find by bool index failed, records: %v, unexpected error: %v", notified, err)
+	}
+	var approvals []*TxApproval
+	if err := db.Find("Status", ApprovalApproved, &approvals); err != nil || len(approvals) != 1 || approvals[0].TxID != "c" {
+		t.Errorf("find by custom type index failed, records: %v, unexpected error: %v", approvals, err)
+	}
+}
+
+func TestWalletManager_EncryptLocalDBMigration(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//未加密时写入的数据
+	wm.SaveLocalBlock(&Block{Hash: "0xplainblockhash", Height: 10})
+	wm.SaveLocalNewBlock(10, "0xplainblockhash")
+	db, _ := wm.openLocalDB(wm.Config.BlockchainFile)
+	db.Save(&ConfirmPendingRecord{ID: "a", Notified: true})
+	db.Close()
+
+	//开启加密后迁移已有数据
+	wm.Config.DBEncryptKey = "000102030405060708090a0b0c0d0e0f"
+
+	block, err := wm.GetLocalBlock(10)
+	if err != nil || block.Hash != "0xplainblockhash" {
+		t.Errorf("GetLocalBlock after migration failed, block: %v, unexpected error: %v\n", block, err)
+		return
+	}
+	if height, hash := wm.GetLocalNewBlock(); height != 10 || hash != "0xplainblockhash" {
+		t.Errorf("GetLocalNewBlock after migration: %d, %s", height, hash)
+	}
+
+	db, err = wm.openLocalDB(wm.Config.BlockchainFile)
+	if err != nil {
+		t.Errorf("open local db failed, unexpected error: %v\n", err)
+		return
+	}
+	var notified []*ConfirmPendingRecord
+	if err := db.Find("Notified", true, &notified); err != nil || len(notified) != 1 {
+		t.Errorf("find by bool index after migration failed, records: %v, unexpected error: %v", notified, err)
+	}
+	db.Close()
+
+	//再次打开不重复迁移
+	wm.dbCodecReady = nil
+	migrated, err := migrateLocalDBCodec(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile), mustAESGCMCodec(t, "000102030405060708090a0b0c0d0e0f"), time.Second)
+	if err != nil || migrated {
+		t.Errorf("migrated db should not be migrated again, migrated: %v, err: %v", migrated, err)
+	}
+
+	//旧版本编码器名称的数据库更新为当前名称，记录不变
+	path := filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile)
+	raw, _ := bolt.Open(path, 0600, nil)
+	raw.Update(func(tx *bolt.Tx) error {
+		tx.Bucket([]byte(codecMarkerBucket)).Put([]byte(codecMarkerKey), []byte("aesgcm-siv-json"))
+		return tx.Bucket([]byte("Block")).Bucket([]byte(stormMetadataBucket)).Put([]byte("codec"), []byte("aesgcm-siv-json"))
+	})
+	raw.Close()
+
+	wm.dbCodecReady = nil
+	if block, err := wm.GetLocalBlock(10); err != nil || block.Hash != "0xplainblockhash" {
+		t.Errorf("GetLocalBlock after codec rename failed, block: %v, unexpected error: %v", block, err)
+	}
+	raw, _ = bolt.Open(path, 0600, nil)
+	raw.View(func(tx *bolt.Tx) error {
+		if name := string(tx.Bucket([]byte(codecMarkerBucket)).Get([]byte(codecMarkerKey))); name != "aesgcm-hmacnonce-json" {
+			t.Errorf("codec marker should be renamed, got: %s", name)
+		}
+		return nil
+	})
+	raw.Close()
+}
+
+func mustAESGCMCodec(t *testing.T, key string) *aesGCMCodec {
+	raw, _ := hex.DecodeString(key)
+	c, err := newAESGCMCodec(raw)
+	if err != nil {
+		t.Fatalf("new codec failed, unexpected error: %v", err)
+	}
+	return c
+}
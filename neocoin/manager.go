@@ -21,8 +21,10 @@ import (
 	"math"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
 	"github.com/asdine/storm/q"
 	"github.com/astaxie/beego/config"
 	"github.com/blocktree/go-owcdrivers/owkeychain"
@@ -38,6 +40,8 @@ import (
 	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/codeskyblue/go-sh"
 	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+	bolt "go.etcd.io/bbolt"
 )
 
 const (
@@ -47,23 +51,66 @@ const (
 type WalletManager struct {
 	openwallet.AssetsAdapterBase
 
-	Storage         *hdkeystore.HDKeystore        //秘钥存取
-	WalletClient    *Client                       // 节点客户端
-	OnmiClient      *Client                       // Omni代币节点客户端
-	ExplorerClient  *Explorer                     // 浏览器API客户端
-	Config          *WalletConfig                 //钱包管理配置
-	WalletsInSum    map[string]*openwallet.Wallet //参与汇总的钱包
-	Blockscanner    *NEOBlockScanner              //区块扫描器
-	Decoder         AddressDecoder                //地址编码器
-	TxDecoder       openwallet.TransactionDecoder //交易单编码器
-	Log             *log.OWLogger                 //日志工具
-	ContractDecoder *ContractDecoder              //智能合约解析器
+	Storage               *hdkeystore.HDKeystore        //秘钥存取
+	WalletClient          *Client                       // 节点客户端
+	BackupClients         []*Client                     // 备用节点客户端，用于多节点分歧检查
+	BackupExplorerClients []*Explorer                   // 备用浏览器API客户端，用于余额和交易记录的一致性读取
+	OnmiClient            *Client                       // Omni代币节点客户端
+	ExplorerClient        *Explorer                     // 浏览器API客户端
+	Config                *WalletConfig                 //钱包管理配置
+	WalletsInSum          map[string]*openwallet.Wallet //参与汇总的钱包
+	Blockscanner          *NEOBlockScanner              //区块扫描器
+	Decoder               AddressDecoder                //地址编码器
+	TxDecoder             openwallet.TransactionDecoder //交易单编码器
+	Log                   *log.OWLogger                 //日志工具
+	ContractDecoder       *ContractDecoder              //智能合约解析器
+	RiskProvider          AddressRiskProvider           //地址风险筛查
+	InvoiceMatcher        InvoiceMatcher                //入账金额匹配待支付账单
+	Approver              TransactionApprover           //交易单广播前审批
+	Scheduler             *Scheduler                    //内置维护任务调度器
+
+	configMu         sync.RWMutex                     //配置替换锁
+	auditMu          sync.Mutex                       //审计日志追加锁
+	capabilities     capabilitySet                    //管理者已授予的危险操作权限
+	dbKeyMu          sync.Mutex                       //本地数据库密钥锁
+	dbKeyProvider    DBKeyProvider                    //本地数据库加密密钥提供者
+	dbKey            []byte                           //密钥提供者返回的密钥缓存
+	dbCodecReady     map[string]bool                  //已迁移到当前编码器的数据库文件
+	networkMu        sync.Mutex                       //本地数据网络检查锁
+	networkChecked   string                           //已检查网络的本地数据目录
+	withdrawMu       sync.Mutex                       //提币限额锁
+	withdrawLimits   map[string]*WithdrawLimit        //账户提币限额
+	signerMu         sync.Mutex                       //外部签名者锁
+	signers          map[string]neoTransaction.Signer //地址注册的外部签名者
+	scanCycleMu      sync.RWMutex                     //扫描周期锁，扫描期间持有读锁，替换配置持有写锁
+	leaseMu          sync.Mutex                       //扫描租约锁
+	instanceID       string                           //适配器实例标识
+	leaseRenewedAt   time.Time                        //最近一次续约扫描租约的时间
+	idempotencyMu    sync.Mutex                       //幂等广播锁
+	confirmMu        sync.Mutex                       //确认数要求锁
+	minConfirms      map[string]uint64                //地址或账户的确认数要求
+	feeStats         *feeTracker                      //最近区块和内存池的网络费统计
+	breakers         *rpcBreakers                     //可选功能RPC方法熔断器
+	txIndexMu        sync.RWMutex                     //交易索引存储锁
+	txIndex          TxIndexStore                     //浏览器模式的交易索引存储，nil使用本地数据库
+	unspentCache     *unspentCache                    //按节点最新区块缓存的未花查询结果
+	injectMu         sync.RWMutex                     //注入依赖锁
+	injected         injectedDeps                     //注入的依赖，设置后优先于对应的客户端字段
+	addressBookMu    sync.RWMutex                     //地址簿缓存锁
+	addressBook      map[string]*AddressBookEntry     //地址簿内存缓存，nil时从本地数据库加载
+	featureMu        sync.RWMutex                     //功能开关锁
+	featureOverrides map[Feature]bool                 //运行时设置的功能开关，优先于配置
+	trackedAssetsMu  sync.Mutex                       //跟踪资产修改锁
+	assetsFileStamp  string                           //已加载的跟踪资产文件修改时间和大小
+	readRouter       *nodeRouter                      //多节点读请求路由
+	storageMu        sync.Mutex                       //存储统计锁
+	storageSamples   map[string]*storageSample        //上一次统计的本地数据库文件大小
 }
 
 func NewWalletManager() *WalletManager {
 	wm := WalletManager{}
 	wm.Config = NewConfig(Symbol, CurveType, Decimals)
-	storage := hdkeystore.NewHDKeystore(wm.Config.keyDir, hdkeystore.StandardScryptN, hdkeystore.StandardScryptP)
+	storage := hdkeystore.NewHDKeystore(wm.config().keyDir, hdkeystore.StandardScryptN, hdkeystore.StandardScryptP)
 	wm.Storage = storage
 	//参与汇总的钱包
 	wm.WalletsInSum = make(map[string]*openwallet.Wallet)
@@ -73,6 +120,15 @@ func NewWalletManager() *WalletManager {
 	wm.TxDecoder = NewTransactionDecoder(&wm)
 	wm.Log = log.NewOWLogger(wm.Symbol())
 	wm.ContractDecoder = NewContractDecoder(&wm)
+	wm.Scheduler = NewScheduler(&wm)
+	wm.feeStats = newFeeTracker()
+	wm.breakers = newRPCBreakers()
+	wm.unspentCache = newUnspentCache()
+	wm.readRouter = newNodeRouter()
+	//默认配置有问题时提示，加载外部配置后再次检查并返回错误
+	for _, problem := range wm.Config.Validate() {
+		wm.Log.Std.Warning("config: %s", problem)
+	}
 	return &wm
 }
 
@@ -86,7 +142,7 @@ func (wm *WalletManager) GetAddressesByAccount(walletID string) ([]string, error
 		walletID,
 	}
 
-	result, err := wm.WalletClient.Call("getaddressesbyaccount", request)
+	result, err := wm.nodeClient().Call("getaddressesbyaccount", request)
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +195,7 @@ func (wm *WalletManager) ImportPrivKey(wif, walletID string) error {
 		false,
 	}
 
-	_, err := wm.WalletClient.Call("importprivkey", request)
+	_, err := wm.nodeClient().Call("importprivkey", request)
 
 	if err != nil {
 		return err
@@ -158,7 +214,7 @@ func (wm *WalletManager) ImportAddress(address, account string) error {
 		false,
 	}
 
-	_, err := wm.WalletClient.Call("importaddress", request)
+	_, err := wm.nodeClient().Call("importaddress", request)
 
 	if err != nil {
 		return err
@@ -222,7 +278,7 @@ func (wm *WalletManager) ImportMulti(addresses []*openwallet.Address, keys []str
 		},
 	}
 
-	result, err := wm.WalletClient.Call("importmulti", request)
+	result, err := wm.nodeClient().Call("importmulti", request)
 	if err != nil {
 		return nil, err
 	}
@@ -240,7 +296,7 @@ func (wm *WalletManager) ImportMulti(addresses []*openwallet.Address, keys []str
 //GetCoreWalletinfo 获取核心钱包节点信息
 func (wm *WalletManager) GetCoreWalletinfo() error {
 
-	_, err := wm.WalletClient.Call("getwalletinfo", nil)
+	_, err := wm.nodeClient().Call("getwalletinfo", nil)
 
 	if err != nil {
 		return err
@@ -258,7 +314,7 @@ func (wm *WalletManager) UnlockWallet(passphrase string, seconds int) error {
 		seconds,
 	}
 
-	_, err := wm.WalletClient.Call("walletpassphrase", request)
+	_, err := wm.nodeClient().Call("walletpassphrase", request)
 	if err != nil {
 		return err
 	}
@@ -270,7 +326,7 @@ func (wm *WalletManager) UnlockWallet(passphrase string, seconds int) error {
 //LockWallet 锁钱包
 func (wm *WalletManager) LockWallet() error {
 
-	_, err := wm.WalletClient.Call("walletlock", nil)
+	_, err := wm.nodeClient().Call("walletlock", nil)
 	if err != nil {
 		return err
 	}
@@ -281,7 +337,7 @@ func (wm *WalletManager) LockWallet() error {
 //GetNetworkInfo 获取网络信息
 func (wm *WalletManager) GetNetworkInfo() error {
 
-	_, err := wm.WalletClient.Call("getnetworkinfo", nil)
+	_, err := wm.nodeClient().Call("getnetworkinfo", nil)
 	if err != nil {
 		return err
 	}
@@ -296,7 +352,7 @@ func (wm *WalletManager) KeyPoolRefill(keyPoolSize uint64) error {
 		keyPoolSize,
 	}
 
-	_, err := wm.WalletClient.Call("keypoolrefill", request)
+	_, err := wm.nodeClient().Call("keypoolrefill", request)
 	if err != nil {
 		return err
 	}
@@ -311,7 +367,7 @@ func (wm *WalletManager) CreateReceiverAddress(account string) (string, error) {
 		account,
 	}
 
-	result, err := wm.WalletClient.Call("getnewaddress", request)
+	result, err := wm.nodeClient().Call("getnewaddress", request)
 	if err != nil {
 		return "", err
 	}
@@ -345,7 +401,7 @@ func (wm *WalletManager) CreateBatchAddress(name, password string, count uint64)
 	timestamp := time.Now()
 	//建立文件名，时间格式2006-01-02 15:04:05
 	filename := "address-" + common.TimeFormat("20060102150405", timestamp) + ".txt"
-	filePath := filepath.Join(wm.Config.addressDir, filename)
+	filePath := filepath.Join(wm.config().addressDir, filename)
 
 	//生产通道
 	producer := make(chan []*openwallet.Address)
@@ -509,19 +565,19 @@ func (wm *WalletManager) CreateNewWallet(name, password string) (*openwallet.Wal
 		return nil, "", err
 	}
 
-	key, keyFile, err := hdkeystore.StoreHDKeyWithSeed(wm.Config.keyDir, name, password, extSeed, hdkeystore.StandardScryptN, hdkeystore.StandardScryptP)
+	key, keyFile, err := hdkeystore.StoreHDKeyWithSeed(wm.config().keyDir, name, password, extSeed, hdkeystore.StandardScryptN, hdkeystore.StandardScryptP)
 	if err != nil {
 		return nil, "", err
 	}
 
-	file.MkdirAll(wm.Config.DBPath)
-	file.MkdirAll(wm.Config.keyDir)
+	file.MkdirAll(wm.config().DBPath)
+	file.MkdirAll(wm.config().keyDir)
 
 	w := &openwallet.Wallet{
 		WalletID: key.KeyID,
 		Alias:    key.Alias,
 		KeyFile:  keyFile,
-		DBFile:   filepath.Join(wm.Config.DBPath, key.FileName()+".db"),
+		DBFile:   filepath.Join(wm.config().DBPath, key.FileName()+".db"),
 	}
 
 	w.SaveToDB()
@@ -538,7 +594,7 @@ func (wm *WalletManager) EncryptWallet(password string) error {
 		password,
 	}
 
-	_, err := wm.WalletClient.Call("encryptwallet", request)
+	_, err := wm.nodeClient().Call("encryptwallet", request)
 	if err != nil {
 		return err
 	}
@@ -548,13 +604,13 @@ func (wm *WalletManager) EncryptWallet(password string) error {
 //GetWallets 获取钱包列表
 func (wm *WalletManager) GetWallets() ([]*openwallet.Wallet, error) {
 
-	wallets, err := openwallet.GetWalletsByKeyDir(wm.Config.keyDir)
+	wallets, err := openwallet.GetWalletsByKeyDir(wm.config().keyDir)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, w := range wallets {
-		w.DBFile = filepath.Join(wm.Config.DBPath, w.FileName()+".db")
+		w.DBFile = filepath.Join(wm.config().DBPath, w.FileName()+".db")
 	}
 
 	return wallets, nil
@@ -588,7 +644,7 @@ func (wm *WalletManager) AddMultiSigAddress(required uint64, addresses []string)
 		addresses,
 	}
 
-	result, err := wm.WalletClient.Call("addmultisigaddress", request)
+	result, err := wm.nodeClient().Call("addmultisigaddress", request)
 	if err != nil {
 		return "", "", err
 	}
@@ -628,7 +684,7 @@ func (wm *WalletManager) GetWalletBalance(accountID string) string {
 	//	balance = balance.Add(amount)
 	//}
 
-	//balance, err := wm.WalletClient.Call("getbalance", request)
+	//balance, err := wm.nodeClient().Call("getbalance", request)
 	//if err != nil {
 	//	return "", err
 	//}
@@ -640,7 +696,7 @@ func (wm *WalletManager) GetWalletBalance(accountID string) string {
 //GetAddressBalance 获取地址余额
 func (wm *WalletManager) GetAddressBalance(walletID, address string) string {
 
-	wm.RebuildWalletUnspent(walletID)
+	wm.rebuildWalletUnspent(walletID)
 
 	wallet, err := wm.GetWalletInfo(walletID)
 	if err != nil {
@@ -694,7 +750,7 @@ func (wm *WalletManager) CreateNewPrivateKey(accountID string, key *owkeychain.E
 	//	return "", nil, err
 	//}
 
-	wif, err := wm.Decoder.PrivateKeyToWIF(keyBytes, wm.Config.IsTestNet)
+	wif, err := wm.Decoder.PrivateKeyToWIF(keyBytes, wm.config().IsTestNet)
 
 	//cfg := chaincfg.MainNetParams
 	//if wm.Config.IsTestNet {
@@ -708,7 +764,7 @@ func (wm *WalletManager) CreateNewPrivateKey(accountID string, key *owkeychain.E
 
 	publicKey := childKey.GetPublicKeyBytes()
 
-	address, err := wm.Decoder.PublicKeyToAddress(publicKey, wm.Config.IsTestNet)
+	address, err := wm.Decoder.PublicKeyToAddress(publicKey, wm.config().IsTestNet)
 
 	//pkHash := btcutil.Hash160(publicKey)
 	//address, err :=  btcutil.NewAddressPubKeyHash(pkHash, &cfg)
@@ -722,7 +778,7 @@ func (wm *WalletManager) CreateNewPrivateKey(accountID string, key *owkeychain.E
 		AccountID:   accountID,
 		HDPath:      derivedPath,
 		CreatedTime: time.Now().Unix(),
-		Symbol:      wm.Config.Symbol,
+		Symbol:      wm.config().Symbol,
 		Index:       index,
 		WatchOnly:   false,
 	}
@@ -764,7 +820,7 @@ func (wm *WalletManager) BackupWalletData(dest string) error {
 		dest,
 	}
 
-	_, err := wm.WalletClient.Call("backupwallet", request)
+	_, err := wm.nodeClient().Call("backupwallet", request)
 	if err != nil {
 		return err
 	}
@@ -781,12 +837,12 @@ func (wm *WalletManager) BackupWallet(walletID string) (string, error) {
 	}
 
 	//创建备份文件夹
-	newBackupDir := filepath.Join(wm.Config.backupDir, w.FileName()+"-"+common.TimeFormat("20060102150405"))
+	newBackupDir := filepath.Join(wm.config().backupDir, w.FileName()+"-"+common.TimeFormat("20060102150405"))
 	file.MkdirAll(newBackupDir)
 
 	//创建临时备份文件wallet.dat
 	tmpWalletDat := fmt.Sprintf("tmp-walllet-%d.dat", time.Now().Unix())
-	tmpWalletDat = filepath.Join(wm.Config.WalletDataPath, tmpWalletDat)
+	tmpWalletDat = filepath.Join(wm.config().WalletDataPath, tmpWalletDat)
 
 	//1. 备份核心钱包的wallet.dat
 	err = wm.BackupWalletData(tmpWalletDat)
@@ -833,17 +889,17 @@ func (wm *WalletManager) RestoreWallet(keyFile, dbFile, datFile, password string
 	fmt.Printf("Validating key file... \n")
 
 	//检查密码是否可以解析种子文件，是否可以解锁钱包。
-	key, err = wm.Storage.GetKey("", keyFile, password)
+	key, err = wm.keystore().GetKey("", keyFile, password)
 	if err != nil {
 		return errors.New("Passowrd is incorrect!")
 	}
 
 	//钱包当前的dat文件
-	curretWDFile := filepath.Join(wm.Config.WalletDataPath, "wallet.dat")
+	curretWDFile := filepath.Join(wm.config().WalletDataPath, "wallet.dat")
 
 	//创建临时备份文件wallet.dat，备份
 	tmpWalletDat := fmt.Sprintf("restore-walllet-%d.dat", time.Now().Unix())
-	tmpWalletDat = filepath.Join(wm.Config.WalletDataPath, tmpWalletDat)
+	tmpWalletDat = filepath.Join(wm.config().WalletDataPath, tmpWalletDat)
 
 	fmt.Printf("Backup current wallet.dat file... \n")
 
@@ -867,7 +923,7 @@ func (wm *WalletManager) RestoreWallet(keyFile, dbFile, datFile, password string
 	file.Delete(curretWDFile)
 
 	//恢复备份dat到钱包数据目录
-	err = file.Copy(datFile, wm.Config.WalletDataPath)
+	err = file.Copy(datFile, wm.config().WalletDataPath)
 	if err != nil {
 		return err
 	}
@@ -895,12 +951,12 @@ func (wm *WalletManager) RestoreWallet(keyFile, dbFile, datFile, password string
 		fmt.Printf("Restore wallet key and datebase file... \n")
 
 		//复制种子文件到data/btc/key/
-		file.MkdirAll(wm.Config.keyDir)
-		file.Copy(keyFile, filepath.Join(wm.Config.keyDir, key.FileName()+".key"))
+		file.MkdirAll(wm.config().keyDir)
+		file.Copy(keyFile, filepath.Join(wm.config().keyDir, key.FileName()+".key"))
 
 		//复制钱包数据库文件到data/btc/db/
-		file.MkdirAll(wm.Config.DBPath)
-		file.Copy(dbFile, filepath.Join(wm.Config.DBPath, key.FileName()+".db"))
+		file.MkdirAll(wm.config().DBPath)
+		file.Copy(dbFile, filepath.Join(wm.config().DBPath, key.FileName()+".db"))
 
 		fmt.Printf("Backup wallet has been restored. \n")
 
@@ -946,7 +1002,7 @@ func (wm *WalletManager) DumpWallet(filename string) error {
 		filename,
 	}
 
-	_, err := wm.WalletClient.Call("dumpwallet", request)
+	_, err := wm.nodeClient().Call("dumpwallet", request)
 	if err != nil {
 		return err
 	}
@@ -962,7 +1018,7 @@ func (wm *WalletManager) ImportWallet(filename string) error {
 		filename,
 	}
 
-	_, err := wm.WalletClient.Call("importwallet", request)
+	_, err := wm.nodeClient().Call("importwallet", request)
 	if err != nil {
 		return err
 	}
@@ -974,7 +1030,7 @@ func (wm *WalletManager) ImportWallet(filename string) error {
 //GetBlockChainInfo 获取钱包区块链信息
 func (wm *WalletManager) GetBlockChainInfo() (*BlockchainInfo, error) {
 
-	result, err := wm.WalletClient.Call("getblockchaininfo", nil)
+	result, err := wm.nodeClient().Call("getblockchaininfo", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -985,54 +1041,115 @@ func (wm *WalletManager) GetBlockChainInfo() (*BlockchainInfo, error) {
 
 }
 
-//ListUnspent 获取未花记录
-func (wm *WalletManager) ListUnspent(min uint64, addresses ...string) ([]*UnspentBalance, error) {
+//CallRaw 直接调用节点JSON-RPC方法，复用节点客户端的认证、重试和限速，用于调用适配器未封装的节点插件接口
+func (wm *WalletManager) CallRaw(method string, params []interface{}) (gjson.Result, error) {
 
-	//:分页限制
+	if wm.nodeClient() == nil {
+		return gjson.Result{}, fmt.Errorf("node client is not setup, json-rpc is unavailable in explorer mode")
+	}
+
+	if len(method) == 0 {
+		return gjson.Result{}, fmt.Errorf("json-rpc method is empty")
+	}
+
+	result, err := wm.nodeClient().Call(method, params)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	return *result, nil
+}
+
+//ListUnspent 获取未花记录，地址较多时按批拆分并发查询，按地址顺序合并结果
+func (wm *WalletManager) ListUnspent(min uint64, addresses ...string) ([]*UnspentBalance, error) {
 
 	var (
-		limit       = 100
-		searchAddrs = make([]string, 0)
-		max         = len(addresses)
-		step        = max / limit
+		limit       = wm.config().UnspentQueryChunkSize
+		concurrency = wm.config().UnspentQueryConcurrency
 		utxo        = make([]*UnspentBalance, 0)
-		pice        *UnspentBalance
-		err         error
 	)
 
-	for i := 0; i <= step; i++ {
-		begin := i * limit
-		end := (i + 1) * limit
-		if end > max {
-			end = max
+	if limit <= 0 {
+		limit = len(addresses)
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	//按节点最新区块缓存结果，构建交易时避免重复查询相同地址的未花
+	var tip, cacheKey string
+	if wm.config().UnspentCache && wm.config().RPCServerType != RPCServerExplorer {
+		if hash, err := wm.GetBestBlockHash(); err == nil && len(hash) > 0 {
+			tip, cacheKey = hash, unspentCacheKey(min, addresses)
+			if cached, ok := wm.unspentCache.get(tip, cacheKey); ok {
+				return cached, nil
+			}
 		}
+	}
 
-		searchAddrs = addresses[begin:end]
+	chunks := make([][]string, 0)
+	for begin := 0; begin < len(addresses); begin += limit {
+		end := begin + limit
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		chunks = append(chunks, addresses[begin:end])
+	}
 
-		if len(searchAddrs) == 0 {
-			continue
+	var (
+		wg      sync.WaitGroup
+		results = make([][]*UnspentBalance, len(chunks))
+		errs    = make([]error, len(chunks))
+		sem     = make(chan struct{}, concurrency)
+	)
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, searchAddrs []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = wm.listUnspentChunk(min, searchAddrs)
+		}(i, chunk)
+	}
+
+	wg.Wait()
+
+	for i := range chunks {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
+		utxo = append(utxo, results[i]...)
+	}
 
-		for _, addr := range searchAddrs {
-			/*if wm.Config.RPCServerType == RPCServerExplorer {
-				pice, err = wm.listUnspentByExplorer(min, addr)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				pice, err = wm.getListUnspentByCore(min, addr)
-				if err != nil {
-					return nil, err
-				}
-			}*/
-			pice, err = wm.getListUnspentByCore(min, addr)
-			if err != nil {
-				continue
-			}
-			utxo = append(utxo, pice)
+	if len(tip) > 0 {
+		wm.unspentCache.set(tip, cacheKey, addresses, utxo)
+	}
+
+	return utxo, nil
+}
+
+//listUnspentChunk 查询一批地址的未花记录，没有未花的地址跳过，查询失败的地址汇总后返回错误
+func (wm *WalletManager) listUnspentChunk(min uint64, searchAddrs []string) ([]*UnspentBalance, error) {
+
+	utxo := make([]*UnspentBalance, 0)
+	failed := make([]string, 0)
+	for _, addr := range searchAddrs {
+		pice, err := wm.getListUnspentByCore(min, addr)
+		if err == errUnspentBalanceEmpty {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", addr, err))
+			continue
 		}
+		utxo = append(utxo, pice)
+	}
 
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("list unspent of %d addresses failed, %s", len(failed), strings.Join(failed, "; "))
 	}
+
 	return utxo, nil
 }
 
@@ -1050,7 +1167,7 @@ func (wm *WalletManager) ClaimGAS(address string) (error) {
 // claimgas 获取钱包中的GAS到指定地址
 func (wm *WalletManager) claimGASByCore(address string) error {
 	request := []interface{}{address}
-	_, err := wm.WalletClient.Call("claimgas", request)
+	_, err := wm.nodeClient().Call("claimgas", request)
 	if err != nil {
 		return err
 	}
@@ -1066,12 +1183,12 @@ func (wm *WalletManager) getListUnspentByCore(min uint64, addresse string) (*Uns
 
 	request := []interface{}{addresse}
 
-	result, err := wm.WalletClient.Call("getunspents", request)
+	result, err := wm.nodeClient().Call("getunspents", request)
 	if err != nil {
 		return nil, err
 	}
 
-	balance, err = NewUnspentBalance(result)
+	balance, err = NewUnspentBalance(result, wm.config().GASAssetID)
 	if err != nil {
 		return nil, err
 	}
@@ -1082,6 +1199,16 @@ func (wm *WalletManager) getListUnspentByCore(min uint64, addresse string) (*Uns
 //RebuildWalletUnspent 批量插入未花记录到本地
 func (wm *WalletManager) RebuildWalletUnspent(walletID string) error {
 
+	if err := wm.requireCapability(CapabilityDeleteLocal); err != nil {
+		return err
+	}
+
+	return wm.rebuildWalletUnspent(walletID)
+}
+
+//rebuildWalletUnspent 重建钱包的未花记录，内部刷新余额时调用
+func (wm *WalletManager) rebuildWalletUnspent(walletID string) error {
+
 	var (
 		wallet *openwallet.Wallet
 	)
@@ -1103,20 +1230,37 @@ func (wm *WalletManager) RebuildWalletUnspent(walletID string) error {
 		return errors.New("The wallet that your given name is not exist!")
 	}
 
-	//查找核心钱包确认数大于1的
-	utxos, err := wm.ListUnspent(0)
+	db, err := wallet.OpenDB()
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	db, err := wallet.OpenDB()
+	//查询钱包所有地址的未花
+	var addrs []*openwallet.Address
+	err = db.All(&addrs)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
-	//清空历史的UTXO
-	db.Drop("Unspent")
+	if len(addrs) == 0 {
+		return fmt.Errorf("wallet: %s has no address", walletID)
+	}
+
+	addresses := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		addresses = append(addresses, a.Address)
+	}
+
+	utxos, err := wm.ListUnspent(0, addresses...)
+	if err != nil {
+		return err
+	}
+
+	//没有查到未花时保留本地记录，避免查询异常时清空UTXO
+	if len(utxos) == 0 {
+		return fmt.Errorf("wallet: %s no unspent found, local unspent records are kept", walletID)
+	}
 
 	//开始事务
 	tx, err := db.Begin(true)
@@ -1125,6 +1269,12 @@ func (wm *WalletManager) RebuildWalletUnspent(walletID string) error {
 	}
 	defer tx.Rollback()
 
+	//清空历史的UTXO
+	err = tx.Drop(&UnspentBalance{})
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
 	//批量插入到本地数据库
 	//设置utxo的钱包账户
 	for _, utxo := range utxos {
@@ -1236,7 +1386,7 @@ func (wm *WalletManager) BuildTransaction(utxos []*Unspent, to []string, change
 		outputs,
 	}
 
-	rawTx, err := wm.WalletClient.Call("createrawtransaction", request)
+	rawTx, err := wm.nodeClient().Call("createrawtransaction", request)
 	if err != nil {
 		return "", decimal.New(0, 0), err
 	}
@@ -1254,7 +1404,7 @@ func (wm *WalletManager) SignRawTransactionInCoreWallet(txHex, walletID string,
 	//查找未花签名需要的私钥
 	for _, u := range utxos {
 
-		childKey, err := key.DerivedKeyWithPath(u.HDAddress.HDPath, wm.Config.CurveType)
+		childKey, err := key.DerivedKeyWithPath(u.HDAddress.HDPath, wm.config().CurveType)
 
 		keyBytes, err := childKey.GetPrivateKeyBytes()
 		if err != nil {
@@ -1269,7 +1419,7 @@ func (wm *WalletManager) SignRawTransactionInCoreWallet(txHex, walletID string,
 		//}
 
 		cfg := chaincfg.MainNetParams
-		if wm.Config.IsTestNet {
+		if wm.config().IsTestNet {
 			cfg = chaincfg.TestNet3Params
 		}
 
@@ -1288,7 +1438,7 @@ func (wm *WalletManager) SignRawTransactionInCoreWallet(txHex, walletID string,
 		wifs,
 	}
 
-	result, err := wm.WalletClient.Call("signrawtransaction", request)
+	result, err := wm.nodeClient().Call("signrawtransaction", request)
 	if err != nil {
 		return "", err
 	}
@@ -1300,11 +1450,41 @@ func (wm *WalletManager) SignRawTransactionInCoreWallet(txHex, walletID string,
 //SendRawTransaction 广播交易
 func (wm *WalletManager) SendRawTransaction(txHex string) (string, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
-		return wm.sendRawTransactionByExplorer(txHex)
+	if err := wm.requireCapability(CapabilityBroadcast); err != nil {
+		return "", err
+	}
+
+	return wm.sendRawTransaction(txHex)
+}
+
+//sendRawTransaction 广播交易，调用方负责权限检查
+func (wm *WalletManager) sendRawTransaction(txHex string) (string, error) {
+
+	var (
+		result string
+		err    error
+	)
+
+	if wm.config().RPCServerType == RPCServerExplorer {
+		result, err = wm.sendRawTransactionByExplorer(txHex)
 	} else {
-		return wm.sendRawTransactionByCore(txHex)
+		result, err = wm.sendRawTransactionByCore(txHex)
+	}
+
+	//广播成功后，涉及交易输入输出的未花缓存失效
+	if err == nil {
+		wm.unspentCache.invalidateTx(txHex)
+	}
+
+	//记录审计日志，广播失败也记录
+	txid, _ := GetTxId(txHex)
+	detail := fmt.Sprintf("result: %s", result)
+	if err != nil {
+		detail = fmt.Sprintf("error: %v", err)
 	}
+	wm.auditTransaction(AuditActionBroadcast, "", txid, txHex, detail)
+
+	return result, err
 }
 
 //sendRawTransactionByCore 广播交易
@@ -1314,7 +1494,7 @@ func (wm *WalletManager) sendRawTransactionByCore(txHex string) (string, error)
 		txHex,
 	}
 
-	result, err := wm.WalletClient.Call("sendrawtransaction", request)
+	result, err := wm.nodeClient().Call("sendrawtransaction", request)
 	if err != nil {
 		return "", err
 	}
@@ -1450,8 +1630,8 @@ func (wm *WalletManager) SendTransaction(walletID, to string, amount decimal.Dec
 	fmt.Printf("-----------------------------------------------\n")
 
 	//UTXO如果大于设定限制，则分拆成多笔交易单发送
-	if len(usedUTXO) > wm.Config.MaxTxInputs {
-		sendTime = int(math.Ceil(float64(len(usedUTXO)) / float64(wm.Config.MaxTxInputs)))
+	if len(usedUTXO) > wm.config().MaxTxInputs {
+		sendTime = int(math.Ceil(float64(len(usedUTXO)) / float64(wm.config().MaxTxInputs)))
 	}
 
 	for i := 0; i < sendTime; i++ {
@@ -1459,7 +1639,7 @@ func (wm *WalletManager) SendTransaction(walletID, to string, amount decimal.Dec
 		var sendUxto []*UnspentBalance
 		var pieceOfSend = decimal.New(0, 0)
 
-		s := i * wm.Config.MaxTxInputs
+		s := i * wm.config().MaxTxInputs
 
 		//最后一个，计算余数
 		if i == sendTime-1 {
@@ -1467,7 +1647,7 @@ func (wm *WalletManager) SendTransaction(walletID, to string, amount decimal.Dec
 
 			pieceOfSend = totalSend
 		} else {
-			sendUxto = usedUTXO[s : s+wm.Config.MaxTxInputs]
+			sendUxto = usedUTXO[s : s+wm.config().MaxTxInputs]
 
 			for _, u := range sendUxto {
 				ua, _ := decimal.NewFromString(u.NEOUnspent.Amount)
@@ -1657,8 +1837,8 @@ func (wm *WalletManager) SendBatchTransaction(walletID string, to []string, amou
 	}
 
 	//UTXO如果大于设定限制，则分拆成多笔交易单发送
-	if len(usedUTXO) > wm.Config.MaxTxInputs {
-		errStr := fmt.Sprintf("The transaction is use max inputs over: %d", wm.Config.MaxTxInputs)
+	if len(usedUTXO) > wm.config().MaxTxInputs {
+		errStr := fmt.Sprintf("The transaction is use max inputs over: %d", wm.config().MaxTxInputs)
 		return "", errors.New(errStr)
 	}
 
@@ -1750,19 +1930,19 @@ func (wm *WalletManager) EstimateFee(inputs, outputs int64, feeRate decimal.Deci
 	var piece int64 = 1
 
 	//UTXO如果大于设定限制，则分拆成多笔交易单发送
-	if inputs > int64(wm.Config.MaxTxInputs) {
-		piece = int64(math.Ceil(float64(inputs) / float64(wm.Config.MaxTxInputs)))
+	if inputs > int64(wm.config().MaxTxInputs) {
+		piece = int64(math.Ceil(float64(inputs) / float64(wm.config().MaxTxInputs)))
 	}
 
 	//计算公式如下：148 * 输入数额 + 34 * 输出数额 + 10
 	trx_bytes := decimal.New(inputs*148+outputs*34+piece*10, 0)
 	trx_fee := trx_bytes.Div(decimal.New(1000, 0)).Mul(feeRate)
-	trx_fee = trx_fee.Round(wm.Decimal())
+	trx_fee = wm.RoundGAS(trx_fee)
 	//wm.Log.Debugf("trx_fee: %s", trx_fee.String())
 	//wm.Log.Debugf("MinFees: %s", wm.Config.MinFees.String())
 	//是否低于最小手续费
-	if trx_fee.LessThan(wm.Config.MinFees) {
-		trx_fee = wm.Config.MinFees
+	if trx_fee.LessThan(wm.config().MinFees) {
+		trx_fee = wm.config().MinFees
 	}
 
 	return trx_fee, nil
@@ -1771,7 +1951,7 @@ func (wm *WalletManager) EstimateFee(inputs, outputs int64, feeRate decimal.Deci
 //EstimateFeeRate 预估的没KB手续费率
 func (wm *WalletManager) EstimateFeeRate() (decimal.Decimal, error) {
 
-	if wm.Config.RPCServerType == RPCServerExplorer {
+	if wm.config().RPCServerType == RPCServerExplorer {
 		return wm.estimateFeeRateByExplorer()
 	} else {
 		return wm.estimateFeeRateByCore()
@@ -1788,10 +1968,10 @@ func (wm *WalletManager) estimateFeeRateByCore() (decimal.Decimal, error) {
 		2,
 	}
 
-	estimatesmartfee, err := wm.WalletClient.Call("estimatesmartfee", request)
+	estimatesmartfee, err := wm.nodeClient().Call("estimatesmartfee", request)
 	if err != nil {
 
-		estimatefee, err2 := wm.WalletClient.Call("estimatefee", request)
+		estimatefee, err2 := wm.nodeClient().Call("estimatefee", request)
 		if err2 != nil {
 			return decimal.New(0, 0), err2
 		}
@@ -1812,27 +1992,27 @@ func (wm *WalletManager) SummaryWallets() {
 	for wid, wallet := range wm.WalletsInSum {
 
 		//重新加载utxo
-		wm.RebuildWalletUnspent(wid)
+		wm.rebuildWalletUnspent(wid)
 
 		//统计钱包最新余额
 		wb := wm.GetWalletBalance(wid)
 
 		balance, _ := decimal.NewFromString(wb)
 		//如果余额大于阀值，汇总的地址
-		if balance.GreaterThan(wm.Config.Threshold) {
+		if balance.GreaterThan(wm.config().Threshold) {
 
 			wm.Log.Std.Info("Summary account[%s]balance = %v ", wallet.WalletID, balance)
 			wm.Log.Std.Info("Summary account[%s]Start Send Transaction", wallet.WalletID)
 
-			txID, err := wm.SendTransaction(wallet.WalletID, wm.Config.SumAddress, balance, wallet.Password, false)
+			txID, err := wm.SendTransaction(wallet.WalletID, wm.config().SumAddress, balance, wallet.Password, false)
 			if err != nil {
 				wm.Log.Std.Info("Summary account[%s]unexpected error: %v", wallet.WalletID, err)
 				continue
 			} else {
-				wm.Log.Std.Info("Summary account[%s]successfully，Received Address[%s], TXID：%s", wallet.WalletID, wm.Config.SumAddress, txID)
+				wm.Log.Std.Info("Summary account[%s]successfully，Received Address[%s], TXID：%s", wallet.WalletID, wm.config().SumAddress, txID)
 			}
 		} else {
-			wm.Log.Std.Info("Wallet Account[%s]-[%s]Current UnspentBalance: %v，below threshold: %v", wallet.Alias, wallet.WalletID, balance, wm.Config.Threshold)
+			wm.Log.Std.Info("Wallet Account[%s]-[%s]Current UnspentBalance: %v，below threshold: %v", wallet.Alias, wallet.WalletID, balance, wm.config().Threshold)
 		}
 	}
 
@@ -1873,7 +2053,7 @@ func (wm *WalletManager) createAddressWork(k *hdkeystore.HDKey, producer chan<-
 	runWIFs := make([]string, 0)
 
 	derivedPath := fmt.Sprintf("%s/%d", k.RootPath, index)
-	childKey, err := k.DerivedKeyWithPath(derivedPath, wm.Config.CurveType)
+	childKey, err := k.DerivedKeyWithPath(derivedPath, wm.config().CurveType)
 	if err != nil {
 		producer <- make([]*openwallet.Address, 0)
 		return
@@ -1898,6 +2078,13 @@ func (wm *WalletManager) createAddressWork(k *hdkeystore.HDKey, producer chan<-
 		runWIFs = append(runWIFs, wif)
 	}
 
+	//节点校验派生的地址，未通过的不导入
+	if errRun := wm.auditDerivedAddresses(runAddress); errRun != nil {
+		wm.Log.Std.Error("Audit derived addresses failed unexpected error: %v", errRun)
+		producer <- make([]*openwallet.Address, 0)
+		return
+	}
+
 	//批量导入私钥
 	failed, errRun := wm.ImportMulti(runAddress, runWIFs, true)
 	if errRun != nil {
@@ -1938,7 +2125,7 @@ func (wm *WalletManager) exportAddressToFile(addrs []*openwallet.Address, filePa
 		content = content + a.Address + "\n"
 	}
 
-	file.MkdirAll(wm.Config.addressDir)
+	file.MkdirAll(wm.config().addressDir)
 	file.WriteFile(filePath, []byte(content), true)
 }
 
@@ -1967,27 +2154,6 @@ func (wm *WalletManager) saveAddressToDB(addrs []*openwallet.Address, wallet *op
 
 }
 
-//loadConfig 读取配置
-func (wm *WalletManager) LoadConfig() error {
-
-	var (
-		c   config.Configer
-		err error
-	)
-
-	//读取配置
-	absFile := filepath.Join(wm.Config.configFilePath, wm.Config.configFileName)
-
-	c, err = config.NewConfig("ini", absFile)
-	if err != nil {
-		return errors.New("Config is not setup. Please run 'wmd Config -s <symbol>' ")
-	}
-
-	wm.LoadAssetsConfig(c)
-
-	return nil
-}
-
 //SendToAddress
 func (wm *WalletManager) SendToAddress(address string, amount uint64) (string, error) {
 	request := []interface{}{
@@ -1995,7 +2161,7 @@ func (wm *WalletManager) SendToAddress(address string, amount uint64) (string, e
 		amount,
 	}
 
-	result, err := wm.WalletClient.Call("sendtoaddress", request)
+	result, err := wm.nodeClient().Call("sendtoaddress", request)
 	if err != nil {
 		return "", err
 	}
@@ -2009,7 +2175,7 @@ func (wm *WalletManager) printWalletList(list []*openwallet.Wallet) {
 	tableInfo := make([][]interface{}, 0)
 
 	for i, w := range list {
-		a := w.SingleAssetsAccount(wm.Config.Symbol)
+		a := w.SingleAssetsAccount(wm.config().Symbol)
 		a.Balance = wm.GetWalletBalance(a.AccountID)
 		tableInfo = append(tableInfo, []interface{}{
 			i, a.WalletID, a.Alias, a.Balance,
@@ -2029,7 +2195,7 @@ func (wm *WalletManager) printWalletList(list []*openwallet.Wallet) {
 func (wm *WalletManager) startNode() error {
 
 	//读取配置
-	absFile := filepath.Join(wm.Config.configFilePath, wm.Config.configFileName)
+	absFile := filepath.Join(wm.config().configFilePath, wm.config().configFileName)
 	c, err := config.NewConfig("ini", absFile)
 	if err != nil {
 		return errors.New("Config is not setup! ")
@@ -2042,7 +2208,7 @@ func (wm *WalletManager) startNode() error {
 //stopNode 关闭节点
 func (wm *WalletManager) stopNode() error {
 	//读取配置
-	absFile := filepath.Join(wm.Config.configFilePath, wm.Config.configFileName)
+	absFile := filepath.Join(wm.config().configFilePath, wm.config().configFileName)
 	c, err := config.NewConfig("ini", absFile)
 	if err != nil {
 		return errors.New("Config is not setup! ")
@@ -18,10 +18,15 @@ package neocoin
 import (
 	"fmt"
 	"github.com/astaxie/beego/config"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/hdkeystore"
 	"github.com/blocktree/openwallet/log"
+	"github.com/blocktree/openwallet/openwallet"
 	"github.com/codeskyblue/go-sh"
 	"github.com/shopspring/decimal"
+	"io/ioutil"
 	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -46,6 +51,12 @@ func testNewWalletManager() *WalletManager {
 	if err != nil {
 		panic(err)
 	}
+	//测试数据写到临时目录
+	dataDir, err := ioutil.TempDir("", "neo-data")
+	if err != nil {
+		panic(err)
+	}
+	c.Set("dataDir", dataDir)
 	wm.LoadAssetsConfig(c)
 	//wm.ExplorerClient.Debug = false
 	wm.WalletClient.Debug = true
@@ -522,4 +533,79 @@ func TestWalletManager_ImportAddress(t *testing.T) {
 		return
 	}
 	log.Info("imported success")
-}
\ No newline at end of file
+}
+func TestWalletManager_RebuildWalletUnspentKeepsRecords(t *testing.T) {
+	address := "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+	nodeFailed := true
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getunspents" || nodeFailed {
+			return nil
+		}
+		return map[string]interface{}{
+			"address": params[0],
+			"balance": []interface{}{
+				map[string]interface{}{
+					"unspent":      []interface{}{map[string]interface{}{"txid": testHash("utxo")[2:], "n": 0, "value": 10}},
+					"asset_hash":   "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+					"asset":        "NEO",
+					"asset_symbol": "NEO",
+					"amount":       10,
+				},
+			},
+		}
+	})
+	defer server.Close()
+
+	dataDir, _ := ioutil.TempDir("", "neo-data")
+	defer os.RemoveAll(dataDir)
+
+	wm := NewWalletManager()
+	wm.Config.keyDir = filepath.Join(dataDir, "key")
+	wm.Config.DBPath = filepath.Join(dataDir, "db")
+	os.MkdirAll(wm.Config.DBPath, os.ModePerm)
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	key, _, err := hdkeystore.StoreHDKeyWithSeed(wm.Config.keyDir, "rebuild", "1234qwer", make([]byte, 32), hdkeystore.LightScryptN, hdkeystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("store key failed unexpected error: %v", err)
+	}
+	wallet := &openwallet.Wallet{WalletID: key.KeyID, DBFile: filepath.Join(wm.Config.DBPath, key.FileName()+".db")}
+
+	db, err := wallet.OpenDB()
+	if err != nil {
+		t.Fatalf("open wallet db failed unexpected error: %v", err)
+	}
+	db.Save(&openwallet.Address{Address: address, AccountID: key.KeyID})
+	db.Save(&UnspentBalance{Key: "old", Address: address})
+	db.Close()
+
+	countUnspent := func(id string) int {
+		db, _ := wallet.OpenDB()
+		defer db.Close()
+		var list []*UnspentBalance
+		db.All(&list)
+		n := 0
+		for _, u := range list {
+			if u.Key == id {
+				n++
+			}
+		}
+		return n
+	}
+
+	//节点查询失败时不清空本地未花
+	if err := wm.RebuildWalletUnspent(key.KeyID); err == nil {
+		t.Errorf("rebuild should fail when no unspent found")
+	}
+	if countUnspent("old") != 1 {
+		t.Errorf("local unspent should be kept when rebuild failed")
+	}
+
+	nodeFailed = false
+	if err := wm.RebuildWalletUnspent(key.KeyID); err != nil {
+		t.Fatalf("RebuildWalletUnspent failed unexpected error: %v", err)
+	}
+	if countUnspent("old") != 0 || countUnspent(common.NewString(address).SHA256()) != 1 {
+		t.Errorf("local unspent should be replaced by node unspent")
+	}
+}
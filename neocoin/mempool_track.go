@@ -0,0 +1,214 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//TxActionUnconfirmedDropped 未确认交易从内存池消失且未上链的交易记录标识
+	TxActionUnconfirmedDropped = "unconfirmed_dropped"
+)
+
+//MempoolTxRecord 已通知的内存池交易，用于跟踪交易是否被驱逐或替换
+type MempoolTxRecord struct {
+	ID        string `storm:"id"`
+	TxID      string `storm:"index"`
+	SourceKey string
+	Data      *openwallet.TxExtractData
+	FirstSeen int64 //首次在内存池发现的时间
+	LastSeen  int64 //最后在内存池发现的时间
+	MissCount int   //连续未在内存池且未上链的扫描次数
+}
+
+func NewMempoolTxRecord(sourceKey string, data *openwallet.TxExtractData) *MempoolTxRecord {
+	obj := MempoolTxRecord{}
+	obj.SourceKey = sourceKey
+	obj.Data = data
+	if data != nil && data.Transaction != nil {
+		obj.TxID = data.Transaction.TxID
+	}
+	obj.FirstSeen = time.Now().Unix()
+	obj.LastSeen = obj.FirstSeen
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("mempool_%s_%s", obj.TxID, sourceKey))))
+	return &obj
+}
+
+//NewDroppedExtractData 生成未确认交易被丢弃的通知数据，金额取反，输入输出标记为删除
+func NewDroppedExtractData(data *openwallet.TxExtractData) *openwallet.TxExtractData {
+	dropped := NewRollbackExtractData(data)
+	if dropped != nil && dropped.Transaction != nil {
+		dropped.Transaction.TxAction = TxActionUnconfirmedDropped
+	}
+	return dropped
+}
+
+//SaveMempoolTxs 保存已通知的内存池交易，已跟踪的交易保留首次发现时间
+func (wm *WalletManager) SaveMempoolTxs(extractData map[string]*openwallet.TxExtractData) error {
+
+	if len(extractData) == 0 {
+		return nil
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for key, data := range extractData {
+		record := NewMempoolTxRecord(key, data)
+		var exist MempoolTxRecord
+		if err = tx.One("ID", record.ID, &exist); err == nil {
+			record.FirstSeen = exist.FirstSeen
+		}
+		err = tx.Save(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetMempoolTxs 获取正在跟踪的内存池交易
+func (wm *WalletManager) GetMempoolTxs() ([]*MempoolTxRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*MempoolTxRecord
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//checkDroppedMempoolTxs 检查已跟踪的内存池交易，已上链的停止跟踪，
+//连续多次不在内存池且节点查询不到的，视为被驱逐或替换，通知观察者
+func (bs *NEOBlockScanner) checkDroppedMempoolTxs(txIDsInMemPool []string) {
+
+	list, err := bs.wm.GetMempoolTxs()
+	if err != nil {
+		bs.wm.Log.Std.Error("get mempool txs failed. unexpected error: %v", err)
+		return
+	}
+
+	if len(list) == 0 {
+		return
+	}
+
+	inMemPool := make(map[string]bool, len(txIDsInMemPool))
+	for _, txid := range txIDsInMemPool {
+		inMemPool[txid] = true
+	}
+
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
+	if err != nil {
+		bs.wm.Log.Std.Error("open local db failed. unexpected error: %v", err)
+		return
+	}
+	defer db.Close()
+
+	now := time.Now().Unix()
+	//同一交易多个来源只查询一次
+	confirmed := make(map[string]bool)
+
+	for _, r := range list {
+
+		if inMemPool[r.TxID] {
+			r.LastSeen = now
+			r.MissCount = 0
+			db.Save(r)
+			continue
+		}
+
+		isConfirmed, checked := confirmed[r.TxID]
+		if !checked {
+			trx, err := bs.wm.GetTransaction(r.TxID)
+			isConfirmed = err == nil && trx != nil && len(trx.BlockHash) > 0
+			confirmed[r.TxID] = isConfirmed
+		}
+
+		if isConfirmed {
+			db.DeleteStruct(r)
+			continue
+		}
+
+		r.MissCount++
+		if r.MissCount < bs.wm.config().MempoolDropAfterScans {
+			db.Save(r)
+			continue
+		}
+
+		bs.wm.Log.Std.Warning("txid: %s dropped from mempool without confirmation, first seen: %d, last seen: %d", r.TxID, r.FirstSeen, r.LastSeen)
+
+		for o := range bs.Observers {
+			if !bs.acceptNotify(o, r.SourceKey, r.Data) {
+				continue
+			}
+			err = bs.notifyObserver(o, r.SourceKey, NewDroppedExtractData(r.Data))
+			if err != nil {
+				bs.wm.Log.Std.Error("txid: %s dropped notify failed. unexpected error: %v", r.TxID, err)
+			}
+		}
+
+		db.DeleteStruct(r)
+	}
+}
+
+//syncMempoolOnStartup 扫描器启动后的第一次区块迭代前，按内存池扫描流程提取一次与观测地址相关的交易，
+//停机期间广播的充值无需等待确认即可通知，返回本次是否已同步，失败时下次任务再试
+func (bs *NEOBlockScanner) syncMempoolOnStartup() bool {
+
+	if bs.mempoolSynced || !bs.IsScanMemPool || !bs.wm.config().StartupMempoolSync {
+		return false
+	}
+
+	//落后较多时先追赶区块，追上后在任务结束时扫描内存池
+	if bs.shedLoad(ShedWorkMempool) {
+		return false
+	}
+
+	bs.wm.Log.Std.Info("block scanner syncing mempool on startup ...")
+
+	err := bs.scanTxMemPool()
+	if err != nil {
+		bs.wm.Log.Std.Warning("block scanner can not sync mempool on startup; unexpected error: %v", err)
+		return false
+	}
+
+	bs.mempoolSynced = true
+	return true
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_CheckDroppedMempoolTxs(t *testing.T) {
+	var (
+		pending     = testHash("pending")
+		confirmed   = testHash("confirmed")
+		droppedTxID = testHash("dropped")
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "getrawtransaction" && params[0] == confirmed {
+			return map[string]interface{}{"txid": confirmed, "blockhash": testHash("block")}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.MempoolDropAfterScans = 2
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	bs := wm.Blockscanner
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	newData := func(txid string) *openwallet.TxExtractData {
+		output := &openwallet.TxOutPut{}
+		output.Amount = "1"
+		return &openwallet.TxExtractData{
+			Transaction: &openwallet.Transaction{TxID: txid, Amount: "1"},
+			TxOutputs:   []*openwallet.TxOutPut{output},
+		}
+	}
+
+	//未确认交易通知后加入跟踪
+	bs.newExtractDataNotify(0, map[string]*openwallet.TxExtractData{"acc": newData(pending)})
+	bs.newExtractDataNotify(0, map[string]*openwallet.TxExtractData{"acc": newData(confirmed)})
+	bs.newExtractDataNotify(0, map[string]*openwallet.TxExtractData{"acc": newData(droppedTxID)})
+	observer.notified = nil
+	observer.data = nil
+
+	list, _ := wm.GetMempoolTxs()
+	if len(list) != 3 {
+		t.Errorf("tracked mempool txs: %d, expected: 3", len(list))
+	}
+
+	//第一次消失，未达到判定次数，已上链的停止跟踪
+	bs.checkDroppedMempoolTxs([]string{pending})
+	list, _ = wm.GetMempoolTxs()
+	if len(list) != 2 || len(observer.notified) != 0 {
+		t.Errorf("tracked: %d, notified: %v", len(list), observer.notified)
+	}
+
+	//第二次消失，通知丢弃
+	bs.checkDroppedMempoolTxs([]string{pending})
+	list, _ = wm.GetMempoolTxs()
+	if len(list) != 1 || list[0].TxID != pending {
+		t.Errorf("tracked mempool txs: %v", list)
+	}
+	if len(observer.notified) != 1 || observer.notified[0] != "acc:"+droppedTxID {
+		t.Errorf("notified: %v", observer.notified)
+		return
+	}
+
+	dropped := observer.data[0]
+	if dropped.Transaction.TxAction != TxActionUnconfirmedDropped || dropped.Transaction.Amount != "-1" || !dropped.TxOutputs[0].Delete {
+		t.Errorf("unexpected dropped data: %+v", dropped.Transaction)
+	}
+}
+
+func TestNEOBlockScanner_SyncMempoolOnStartup(t *testing.T) {
+	var (
+		depositTxID = testHash("deposit")
+		otherTxID   = testHash("other")
+		staleTxID   = testHash("stale")
+		receiver    = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		mempoolSync int
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getrawmempool":
+			mempoolSync++
+			return []interface{}{depositTxID, otherTxID}
+		case "getrawtransaction":
+			address := "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+			if params[0] == depositTxID {
+				address = receiver
+			}
+			return map[string]interface{}{"txid": params[0], "vin": []interface{}{},
+				"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": address}}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	bs := wm.Blockscanner
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "acc", address == receiver
+	}
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	//停机前已通知、停机期间被驱逐的交易
+	wm.Config.MempoolDropAfterScans = 1
+	stale := &openwallet.TxExtractData{Transaction: &openwallet.Transaction{TxID: staleTxID, Amount: "1"}}
+	bs.newExtractDataNotify(0, map[string]*openwallet.TxExtractData{"acc": stale})
+	observer.notified = nil
+
+	if !bs.syncMempoolOnStartup() {
+		t.Errorf("mempool should be synced on startup")
+	}
+	if len(observer.notified) != 2 || observer.notified[0] != "acc:"+staleTxID || observer.notified[1] != "acc:"+depositTxID {
+		t.Errorf("dropped and mempool deposit should be notified on startup, notified: %v", observer.notified)
+	}
+
+	//只在启动后同步一次，本次任务结束时不再重复扫描内存池
+	if bs.syncMempoolOnStartup() {
+		t.Errorf("mempool should not be synced again")
+	}
+	if mempoolSync != 1 {
+		t.Errorf("mempool should be synced once after start, synced: %d", mempoolSync)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"strings"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//MerkleProof 交易在区块中的默克尔证明，跨链桥可凭区块头的默克尔根独立校验
+type MerkleProof struct {
+	TxID        string   `json:"txid"`
+	BlockHash   string   `json:"blockHash"`
+	BlockHeight uint64   `json:"blockHeight"`
+	MerkleRoot  string   `json:"merkleRoot"`
+	Index       int      `json:"index"` //交易在区块中的位置
+	Path        []string `json:"path"`  //从叶子到根每一层的兄弟节点hash
+}
+
+//Verify 校验默克尔路径能否推导出默克尔根
+func (proof *MerkleProof) Verify() bool {
+	ok, err := neoTransaction.VerifyMerklePath(proof.TxID, proof.Index, proof.Path, proof.MerkleRoot)
+	return err == nil && ok
+}
+
+//DepositProof 充值证明，跨链桥在托管锁定NEO后，凭此在其他链铸造包装资产
+type DepositProof struct {
+	MerkleProof
+	OutputIndex   uint64 `json:"outputIndex"`
+	Address       string `json:"address"`
+	AssetID       string `json:"assetID"`
+	Amount        string `json:"amount"`
+	Confirmations uint64 `json:"confirmations"`
+	RawTx         string `json:"rawTx"` //交易原始数据，浏览器模式为空
+}
+
+//GetTxMerkleProof 获取已上链交易的默克尔证明
+func (wm *WalletManager) GetTxMerkleProof(txid string) (*MerkleProof, error) {
+
+	tx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return wm.merkleProofOfTx(txid, tx)
+}
+
+//merkleProofOfTx 由交易所在区块的交易列表生成默克尔证明
+func (wm *WalletManager) merkleProofOfTx(txid string, tx *Transaction) (*MerkleProof, error) {
+
+	if len(tx.BlockHash) == 0 {
+		return nil, wm.errorf(ErrProofUnavailable, "transaction: %s is not confirmed in block", txid)
+	}
+
+	block, err := wm.GetBlock(tx.BlockHash, BlockDetailTxIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	for i, id := range block.Tx {
+		if strings.EqualFold(strings.TrimPrefix(id, "0x"), strings.TrimPrefix(txid, "0x")) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, wm.errorf(ErrProofUnavailable, "transaction: %s is not found in block: %s", txid, block.Hash)
+	}
+
+	path, err := neoTransaction.MerklePath(block.Tx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &MerkleProof{
+		TxID:        block.Tx[index],
+		BlockHash:   block.Hash,
+		BlockHeight: block.Height,
+		MerkleRoot:  block.Merkleroot,
+		Index:       index,
+		Path:        path,
+	}
+
+	//节点返回的交易列表须与区块头的默克尔根一致
+	if !proof.Verify() {
+		return nil, wm.errorf(ErrProofUnavailable, "block: %s merkle root: %s can not be proved", block.Hash, block.Merkleroot)
+	}
+
+	return proof, nil
+}
+
+//ExportDepositProof 导出交易指定输出的充值证明
+func (wm *WalletManager) ExportDepositProof(txid string, outputIndex uint64) (*DepositProof, error) {
+
+	tx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := wm.merkleProofOfTx(txid, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var output *Vout
+	for _, out := range tx.Vouts {
+		if out.N == outputIndex {
+			output = out
+			break
+		}
+	}
+	if output == nil {
+		return nil, wm.errorf(ErrProofUnavailable, "transaction: %s has no output: %d", txid, outputIndex)
+	}
+
+	deposit := &DepositProof{
+		MerkleProof: *proof,
+		OutputIndex: outputIndex,
+		Address:     output.Addr,
+		AssetID:     output.Asset,
+		Amount:      output.Value,
+	}
+
+	if tip, err := wm.ScannableTipHeight(); err == nil && tip >= proof.BlockHeight {
+		deposit.Confirmations = tip - proof.BlockHeight + 1
+	}
+
+	if wm.config().RPCServerType != RPCServerExplorer {
+		deposit.RawTx, err = wm.GetRawTxHex(txid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return deposit, nil
+}
+
+//ExportDepositProofs 导出扫描器提取结果中所有充值输出的证明，供跨链桥直接消费扫描数据
+func (wm *WalletManager) ExportDepositProofs(data *openwallet.TxExtractData) ([]*DepositProof, error) {
+
+	proofs := make([]*DepositProof, 0)
+	if data == nil {
+		return proofs, nil
+	}
+
+	for _, output := range data.TxOutputs {
+		proof, err := wm.ExportDepositProof(output.TxID, output.Index)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, proof)
+	}
+
+	return proofs, nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_ExportDepositProof(t *testing.T) {
+	const neo = "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+	var (
+		txids     = []string{testHash("miner"), testHash("deposit"), testHash("other")}
+		blockHash = testHash("block")
+	)
+	merkleRoot, _ := neoTransaction.MerkleRoot(txids)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 106
+		case "getrawtransaction":
+			if verbose, ok := params[1].(float64); ok && verbose == 0 {
+				return "80000001"
+			}
+			return map[string]interface{}{
+				"txid":      params[0],
+				"blockhash": blockHash,
+				"vout": []interface{}{
+					map[string]interface{}{"n": 0, "asset": neo, "value": "10", "address": "ACustody"},
+				},
+			}
+		case "getblock":
+			return map[string]interface{}{"index": 100, "hash": params[0], "previousblockhash": testHash("99"), "time": 1000, "merkleroot": merkleRoot, "tx": txids}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	proofs, err := wm.ExportDepositProofs(&openwallet.TxExtractData{
+		TxOutputs: []*openwallet.TxOutPut{{Recharge: openwallet.Recharge{TxID: txids[1], Index: 0}}},
+	})
+	if err != nil || len(proofs) != 1 {
+		t.Errorf("ExportDepositProofs failed unexpected error: %v\n", err)
+		return
+	}
+
+	proof := proofs[0]
+	if proof.Index != 1 || proof.BlockHeight != 100 || proof.MerkleRoot != merkleRoot || !proof.Verify() {
+		t.Errorf("unexpected merkle proof: %+v", proof.MerkleProof)
+	}
+	if proof.Address != "ACustody" || proof.Amount != "10" || proof.Confirmations != 6 || proof.RawTx != "80000001" {
+		t.Errorf("unexpected deposit proof: %+v", proof)
+	}
+
+	if _, err := wm.ExportDepositProof(txids[1], 1); err == nil {
+		t.Errorf("missing output should fail")
+	}
+
+	//节点返回的交易列表与默克尔根不一致
+	txids[2] = testHash("forged")
+	_, err = wm.GetTxMerkleProof(txids[1])
+	if err == nil || openwallet.ConvertError(err).Code() != ErrProofUnavailable {
+		t.Errorf("forged block should fail with proof unavailable, err: %v", err)
+	}
+}
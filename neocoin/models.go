@@ -17,6 +17,7 @@ package neocoin
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/blocktree/openwallet/crypto"
@@ -135,16 +136,20 @@ type UnspentTx struct {
 	Value string `json:"value"`
 }
 
-func NewUnspentBalance(json *gjson.Result) (*UnspentBalance, error) {
+//NewUnspentBalance 解析地址未花，gasAssetID用于识别私有链自定义的GAS资产
+//errUnspentBalanceEmpty 地址没有未花记录
+var errUnspentBalanceEmpty = errors.New("Balance is nil!")
+
+func NewUnspentBalance(json *gjson.Result, gasAssetID ...string) (*UnspentBalance, error) {
 	obj := &UnspentBalance{}
 	//解析json
 	arr := json.Get("balance").Array()
 	if len(arr) == 0 {
-		return nil, errors.New("Balance is nil!")
+		return nil, errUnspentBalanceEmpty
 	}
 	for _, a := range arr {
 		unspent := NewUnspent(&a)
-		if unspent.AssetSymbol == AssetSymbolGAS {
+		if unspent.AssetSymbol == AssetSymbolGAS || unspent.isAsset(gasAssetID...) {
 			obj.GASUnspent = unspent
 		} else {
 			obj.NEOUnspent = unspent
@@ -165,6 +170,17 @@ func NewUnspent(json *gjson.Result) *Unspent {
 	}
 }
 
+//isAsset 资产hash是否属于给定的资产ID
+func (u *Unspent) isAsset(assetIDs ...string) bool {
+	hash := strings.TrimPrefix(u.AssetHash, "0x")
+	for _, id := range assetIDs {
+		if len(id) > 0 && hash == strings.TrimPrefix(id, "0x") {
+			return true
+		}
+	}
+	return false
+}
+
 func NewUnspentTxs(json []gjson.Result) *[]UnspentTx {
 	unspentTxs := new([]UnspentTx)
 	for _, j := range json {
@@ -232,36 +248,63 @@ type Block struct {
 
 	*/
 
-	Hash              string
-	Confirmations     uint64
-	Merkleroot        string
-	tx                []string
-	Previousblockhash string
-	Height            uint64 `storm:"id"`
-	Version           uint64
-	Time              uint64
-	Fork              bool
-	txDetails         []*Transaction
+	Hash              string         `json:"hash"`
+	Size              uint64         `json:"size"`
+	Confirmations     uint64         `json:"confirmations"`
+	Merkleroot        string         `json:"merkleroot"`
+	Tx                []string       `json:"tx"`
+	Previousblockhash string         `json:"previousblockhash"`
+	Nextblockhash     string         `json:"nextblockhash,omitempty"`
+	Height            uint64         `json:"index" storm:"id"`
+	Version           uint64         `json:"version"`
+	Time              uint64         `json:"time"`
+	Nonce             string         `json:"nonce"`                   //共识数据
+	NextConsensus     string         `json:"nextconsensus"`           //下一轮共识节点地址
+	Invocation        string         `json:"-"`                       //见证调用脚本，序列化在script中
+	Verification      string         `json:"-"`                       //见证验证脚本，序列化在script中
+	SchemaVersion     int            `json:"schemaversion,omitempty"` //数据结构版本
+	Fork              bool           `json:"fork,omitempty"`
+	TxDetails         []*Transaction `json:"-"` //交易详情，verbose区块才有，序列化在tx中
 	isVerbose         bool
 }
 
-func (wm *WalletManager) NewBlock(json *gjson.Result) *Block {
+//Witness 见证人脚本
+type Witness struct {
+	Invocation   string `json:"invocation"`
+	Verification string `json:"verification"`
+}
+
+//NewBlock 解析节点getblock verbose结果
+func NewBlock(json *gjson.Result) *Block {
+	return newBlock(json, true)
+}
+
+//newBlock 解析节点getblock verbose结果，parseTx为false时只取交易ID，不解析交易详情
+func newBlock(json *gjson.Result, parseTx bool) *Block {
 	obj := &Block{}
 	//解析json
 	obj.Height = gjson.Get(json.Raw, "index").Uint()
 	obj.Hash = gjson.Get(json.Raw, "hash").String()
+	obj.Size = gjson.Get(json.Raw, "size").Uint()
 	obj.Confirmations = gjson.Get(json.Raw, "confirmations").Uint()
 	obj.Merkleroot = gjson.Get(json.Raw, "merkleroot").String()
 	obj.Previousblockhash = gjson.Get(json.Raw, "previousblockhash").String()
+	obj.Nextblockhash = gjson.Get(json.Raw, "nextblockhash").String()
 	obj.Version = gjson.Get(json.Raw, "version").Uint()
 	obj.Time = gjson.Get(json.Raw, "time").Uint()
+	obj.Nonce = gjson.Get(json.Raw, "nonce").String()
+	obj.NextConsensus = gjson.Get(json.Raw, "nextconsensus").String()
+	obj.Invocation = gjson.Get(json.Raw, "script.invocation").String()
+	obj.Verification = gjson.Get(json.Raw, "script.verification").String()
 
 	txs := make([]string, 0)
 	txDetails := make([]*Transaction, 0)
 	for _, tx := range gjson.Get(json.Raw, "tx").Array() {
-		if tx.IsObject() {
+		if tx.IsObject() && !parseTx {
+			txs = append(txs, tx.Get("txid").String())
+		} else if tx.IsObject() {
 			obj.isVerbose = true
-			txObj := wm.newTxByCore(&tx)
+			txObj := NewTransaction(&tx)
 			txDetails = append(txDetails, txObj)
 			txs = append(txs, txObj.TxID)
 		} else {
@@ -271,12 +314,72 @@ func (wm *WalletManager) NewBlock(json *gjson.Result) *Block {
 
 	}
 
-	obj.tx = txs
-	obj.txDetails = txDetails
+	obj.Tx = txs
+	obj.TxDetails = txDetails
 
 	return obj
 }
 
+//NewBlock 解析节点getblock verbose结果
+func (wm *WalletManager) NewBlock(json *gjson.Result) *Block {
+	return NewBlock(json)
+}
+
+//MarshalJSON 按节点getblock verbose格式输出，verbose区块的tx为交易详情，否则为交易id
+func (b Block) MarshalJSON() ([]byte, error) {
+	type alias Block
+	var tx interface{} = b.Tx
+	if b.isVerbose || len(b.TxDetails) > 0 {
+		tx = b.TxDetails
+	} else if b.Tx == nil {
+		tx = []string{}
+	}
+	return json.Marshal(&struct {
+		alias
+		Script Witness     `json:"script"`
+		Tx     interface{} `json:"tx"`
+	}{
+		alias:  alias(b),
+		Script: Witness{Invocation: b.Invocation, Verification: b.Verification},
+		Tx:     tx,
+	})
+}
+
+//UnmarshalJSON 解析节点getblock verbose格式，兼容旧版本按字段名保存的本地区块记录
+func (b *Block) UnmarshalJSON(data []byte) error {
+	if !gjson.ValidBytes(data) {
+		return errors.New("Invalid block json!")
+	}
+	result := gjson.ParseBytes(data)
+
+	//旧版本本地记录没有json标签，字段名即键名
+	if !result.Get("index").Exists() && result.Get("Height").Exists() {
+		*b = Block{
+			Hash:              result.Get("Hash").String(),
+			Confirmations:     result.Get("Confirmations").Uint(),
+			Merkleroot:        result.Get("Merkleroot").String(),
+			Previousblockhash: result.Get("Previousblockhash").String(),
+			Height:            result.Get("Height").Uint(),
+			Version:           result.Get("Version").Uint(),
+			Time:              result.Get("Time").Uint(),
+			Nonce:             result.Get("Nonce").String(),
+			NextConsensus:     result.Get("NextConsensus").String(),
+			Invocation:        result.Get("Invocation").String(),
+			Verification:      result.Get("Verification").String(),
+			SchemaVersion:     int(result.Get("SchemaVersion").Int()),
+			Fork:              result.Get("Fork").Bool(),
+			Tx:                make([]string, 0),
+			TxDetails:         make([]*Transaction, 0),
+		}
+		return nil
+	}
+
+	*b = *NewBlock(&result)
+	b.SchemaVersion = int(result.Get("schemaversion").Int())
+	b.Fork = result.Get("fork").Bool()
+	return nil
+}
+
 //BlockHeader 区块链头
 func (b *Block) BlockHeader(symbol string) *openwallet.BlockHeader {
 
@@ -296,10 +399,13 @@ func (b *Block) BlockHeader(symbol string) *openwallet.BlockHeader {
 
 //UnscanRecords 扫描失败的区块及交易
 type UnscanRecord struct {
-	ID          string `storm:"id"` // primary key
-	BlockHeight uint64
-	TxID        string
-	Reason      string
+	ID           string `storm:"id"` // primary key
+	BlockHeight  uint64
+	TxID         string
+	Reason       string
+	Deleted      bool   //已软删除，不再重扫，保留到清理期限
+	DeletedAt    int64  //软删除时间
+	DeleteReason string //软删除原因
 }
 
 func NewUnscanRecord(height uint64, txID, reason string) *UnscanRecord {
@@ -312,19 +418,42 @@ func NewUnscanRecord(height uint64, txID, reason string) *UnscanRecord {
 }
 
 type Transaction struct {
-	TxID          string
-	Size          uint64
-	Type          string
-	Version       uint64
-	Attributes    *[]Attribute
-	Vins          []*Vin
-	Vouts         []*Vout
-	SysFee        string // 系统交易费 每笔交易都有10GAS的免费额度
-	NetFee        string // 网络交易费 交易大小<1024 byte时网络费是可选的，最低为0.001GAS，>1024 byte时需要支付0.001GAS作为基础费用，且额外收取每字节 0.00001 GAS 的网络费
-	BlockHash     string
-	BlockHeight   uint64
-	Confirmations uint64
-	Blocktime     int64
+	TxID          string       `json:"txid"`
+	Size          uint64       `json:"size"`
+	Type          string       `json:"type"`
+	Version       uint64       `json:"version"`
+	Attributes    *[]Attribute `json:"attributes"`
+	Vins          []*Vin       `json:"vin"`
+	Vouts         []*Vout      `json:"vout"`
+	SysFee        string       `json:"sys_fee"` // 系统交易费 每笔交易都有10GAS的免费额度
+	NetFee        string       `json:"net_fee"` // 网络交易费 交易大小<1024 byte时网络费是可选的，最低为0.001GAS，>1024 byte时需要支付0.001GAS作为基础费用，且额外收取每字节 0.00001 GAS 的网络费
+	Scripts       []*Witness   `json:"scripts"`
+	BlockHash     string       `json:"blockhash,omitempty"`
+	BlockHeight   uint64       `json:"blockheight,omitempty"` //节点不输出，扫描时填写
+	Confirmations uint64       `json:"confirmations,omitempty"`
+	Blocktime     int64        `json:"blocktime,omitempty"`
+}
+
+//MarshalJSON 按节点getrawtransaction verbose格式输出，空列表输出为[]
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	type alias Transaction
+	obj := alias(tx)
+	if obj.Attributes == nil {
+		obj.Attributes = new([]Attribute)
+	}
+	if *obj.Attributes == nil {
+		*obj.Attributes = make([]Attribute, 0)
+	}
+	if obj.Vins == nil {
+		obj.Vins = make([]*Vin, 0)
+	}
+	if obj.Vouts == nil {
+		obj.Vouts = make([]*Vout, 0)
+	}
+	if obj.Scripts == nil {
+		obj.Scripts = make([]*Witness, 0)
+	}
+	return json.Marshal(obj)
 }
 
 type Attribute struct {
@@ -354,32 +483,34 @@ type Attribute struct {
 	   }
 	*/
 
-	Usage  uint64 // 使用类型
-	Length uint8  // 数据长度 (如有需要, 可选)
-	Data   string // 使用类型相关的外部数据
+	Usage  uint64 `json:"usage"` // 使用类型
+	Length uint8  `json:"-"`     // 数据长度 (如有需要, 可选)
+	Data   string `json:"data"`  // 使用类型相关的外部数据
 }
 
 // 交易输入
 type Vin struct {
-	Coinbase string
-	TxID     string
-	Vout     uint64
-	N        uint64
-	Addr     string
-	Value    string
+	Coinbase string `json:"coinbase,omitempty"`
+	TxID     string `json:"txid"`
+	Vout     uint64 `json:"vout"`
+	N        uint64 `json:"n,omitempty"`       //节点不输出，扫描时填写
+	Addr     string `json:"address,omitempty"` //节点不输出，扫描时由引用的输出填写
+	Value    string `json:"value,omitempty"`   //节点不输出，扫描时由引用的输出填写
 }
 
 // 交易输出
 type Vout struct {
-	N            uint64
-	Addr         string
-	Value        string
-	Asset        string
-	ScriptPubKey string
-	Type         string
+	N            uint64 `json:"n"`
+	Addr         string `json:"address"`
+	Value        string `json:"value"`
+	Asset        string `json:"asset"`
+	ScriptPubKey string `json:"scriptpubkey,omitempty"` //节点不输出，非标准输出的脚本
+	Type         string `json:"type,omitempty"`         //节点不输出
+	IsContract   bool   `json:"iscontract,omitempty"`   //是否支付到合约地址
 }
 
-func (wm *WalletManager) newTxByCore(json *gjson.Result) *Transaction {
+//NewTransaction 解析节点getrawtransaction verbose结果
+func NewTransaction(json *gjson.Result) *Transaction {
 
 	/*
 		{
@@ -437,28 +568,37 @@ func (wm *WalletManager) newTxByCore(json *gjson.Result) *Transaction {
 	obj.Attributes = new([]Attribute)
 	if attributes := gjson.Get(json.Raw, "attributes"); attributes.IsArray() {
 		for _, attr := range attributes.Array() {
-			*(obj.Attributes) = append(*(obj.Attributes), newAttributeByCore(&attr))
+			*(obj.Attributes) = append(*(obj.Attributes), NewAttribute(&attr))
 		}
 	}
 
 	obj.Vins = make([]*Vin, 0)
 	if vins := gjson.Get(json.Raw, "vin"); vins.IsArray() {
 		for _, vin := range vins.Array() {
-			obj.Vins = append(obj.Vins, newTxVinByCore(&vin))
+			obj.Vins = append(obj.Vins, NewVin(&vin))
 		}
 	}
 
 	obj.Vouts = make([]*Vout, 0)
 	if vouts := gjson.Get(json.Raw, "vout"); vouts.IsArray() {
 		for _, vout := range vouts.Array() {
-			obj.Vouts = append(obj.Vouts, newTxVoutByCore(&vout))
+			obj.Vouts = append(obj.Vouts, NewVout(&vout))
 		}
 	}
 
+	obj.Scripts = make([]*Witness, 0)
+	for _, script := range gjson.Get(json.Raw, "scripts").Array() {
+		obj.Scripts = append(obj.Scripts, &Witness{
+			Invocation:   script.Get("invocation").String(),
+			Verification: script.Get("verification").String(),
+		})
+	}
+
 	return &obj
 }
 
-func newAttributeByCore(json *gjson.Result) Attribute {
+//NewAttribute 解析交易属性
+func NewAttribute(json *gjson.Result) Attribute {
 	/*
 	   {
 	      "usage":144,
@@ -472,7 +612,8 @@ func newAttributeByCore(json *gjson.Result) Attribute {
 	}
 }
 
-func newTxVinByCore(json *gjson.Result) *Vin {
+//NewVin 解析交易输入
+func NewVin(json *gjson.Result) *Vin {
 	/*
 	   {
 	      "txid":"0x3631f66024ca6f5b033d7e0809eb993443374830025af904fb51b0334f127cda",
@@ -486,7 +627,8 @@ func newTxVinByCore(json *gjson.Result) *Vin {
 	}
 }
 
-func newTxVoutByCore(json *gjson.Result) *Vout {
+//NewVout 解析交易输出
+func NewVout(json *gjson.Result) *Vout {
 
 	/*
 	   {
@@ -496,12 +638,17 @@ func newTxVoutByCore(json *gjson.Result) *Vout {
 	      "address":"AWHX6wX5mEJ4Vwg7uBcqESeq3NggtNFhzD"
 	   }
 	*/
-	return &Vout{
+	obj := &Vout{
 		N:     gjson.Get(json.Raw, "n").Uint(),
 		Asset: gjson.Get(json.Raw, "asset").String(),
 		Value: gjson.Get(json.Raw, "value").String(),
 		Addr:  gjson.Get(json.Raw, "address").String(),
 	}
+
+	//支付到合约的输出，由脚本hash生成地址
+	resolveContractDestination(obj, json)
+
+	return obj
 }
 
 func DecodeScript(script string) ([]byte, error) {
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const testVerboseBlock = `{
+	"hash": "0xd87f1b76d89a158ed54a0cb88701e5d5ad86ce6f86399ecb50c589a65d709881",
+	"size": 686,
+	"version": 0,
+	"previousblockhash": "0x9e6b682209f778a1246202524be785633e03129b6877040ad05134cc96336fcb",
+	"merkleroot": "0x28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d",
+	"time": 1573037731,
+	"index": 4512345,
+	"nonce": "5ab8f7e0b1a2c3d4",
+	"nextconsensus": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT",
+	"script": {
+		"invocation": "40f96445",
+		"verification": "21036943ac"
+	},
+	"tx": [
+		{
+			"txid": "0x28975702b73450d0f466e5b931eafbc04c0ea6a732162c548ff3d569fa627d9d",
+			"size": 262,
+			"type": "ContractTransaction",
+			"version": 0,
+			"attributes": [{"usage": 144, "data": "5473"}],
+			"vin": [{"txid": "0x9e6b682209f778a1246202524be785633e03129b6877040ad05134cc96336fcb", "vout": 1}],
+			"vout": [{"n": 0, "asset": "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", "value": "100", "address": "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"}],
+			"sys_fee": "0",
+			"net_fee": "0",
+			"scripts": [{"invocation": "40f9", "verification": "2103ac"}]
+		}
+	],
+	"confirmations": 3,
+	"nextblockhash": "0x3631f66024ca6f5b033d7e0809eb993443374830025af904fb51b0334f127cda"
+}`
+
+func TestBlock_MarshalJSON(t *testing.T) {
+	result := gjson.Parse(testVerboseBlock)
+	block := NewBlock(&result)
+
+	if block.Height != 4512345 || block.Size != 686 || len(block.Tx) != 1 || len(block.TxDetails) != 1 {
+		t.Errorf("NewBlock parse failed: %+v", block)
+		return
+	}
+
+	raw, err := json.Marshal(block)
+	if err != nil {
+		t.Errorf("MarshalJSON failed unexpected error: %v\n", err)
+		return
+	}
+
+	//输出与节点格式一致
+	out := gjson.ParseBytes(raw)
+	for _, key := range []string{"hash", "size", "version", "previousblockhash", "merkleroot", "time", "index",
+		"nonce", "nextconsensus", "script.invocation", "script.verification", "confirmations", "nextblockhash",
+		"tx.0.txid", "tx.0.attributes.0.usage", "tx.0.vin.0.vout", "tx.0.vout.0.address", "tx.0.sys_fee", "tx.0.scripts.0.invocation"} {
+		if out.Get(key).String() != result.Get(key).String() {
+			t.Errorf("key: %s, output: %s, node: %s", key, out.Get(key).String(), result.Get(key).String())
+		}
+	}
+
+	decoded := &Block{}
+	if err := json.Unmarshal(raw, decoded); err != nil {
+		t.Errorf("UnmarshalJSON failed unexpected error: %v\n", err)
+		return
+	}
+	if decoded.Hash != block.Hash || decoded.Invocation != "40f96445" || len(decoded.TxDetails) != 1 ||
+		decoded.TxDetails[0].Vouts[0].Addr != "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt" {
+		t.Errorf("decoded block mismatch: %+v", decoded)
+	}
+
+	//非verbose区块的tx为交易id
+	block.TxDetails = nil
+	block.isVerbose = false
+	raw, _ = json.Marshal(block)
+	if gjson.GetBytes(raw, "tx.0").String() != block.Tx[0] {
+		t.Errorf("tx should be txid list: %s", gjson.GetBytes(raw, "tx").Raw)
+	}
+}
+
+func TestBlock_UnmarshalLegacyJSON(t *testing.T) {
+	legacy := `{"Hash":"0x01","Confirmations":0,"Merkleroot":"0x02","Previousblockhash":"0x03","Height":100,
+		"Version":0,"Time":1573037731,"Nonce":"","NextConsensus":"","Invocation":"40aa","Verification":"21bb",
+		"SchemaVersion":1,"Fork":true}`
+
+	block := &Block{}
+	if err := json.Unmarshal([]byte(legacy), block); err != nil {
+		t.Errorf("UnmarshalJSON failed unexpected error: %v\n", err)
+		return
+	}
+	if block.Height != 100 || block.Hash != "0x01" || block.Invocation != "40aa" || block.SchemaVersion != 1 || !block.Fork {
+		t.Errorf("legacy block mismatch: %+v", block)
+	}
+}
+
+func TestWalletManager_SaveLocalBlockJSON(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	result := gjson.Parse(testVerboseBlock)
+	block := NewBlock(&result)
+	block.Fork = true
+	if err := wm.SaveLocalBlock(block); err != nil {
+		t.Errorf("SaveLocalBlock failed unexpected error: %v\n", err)
+		return
+	}
+
+	local, err := wm.GetLocalBlock(block.Height)
+	if err != nil {
+		t.Errorf("GetLocalBlock failed unexpected error: %v\n", err)
+		return
+	}
+	if local.Hash != block.Hash || local.Verification != block.Verification || !local.Fork ||
+		local.SchemaVersion != SchemaVersion || len(local.Tx) != 1 {
+		t.Errorf("local block mismatch: %+v", local)
+	}
+	//本地不保存交易详情
+	if len(local.TxDetails) != 0 {
+		t.Errorf("local block should not keep tx details")
+	}
+}
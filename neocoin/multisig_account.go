@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//MultiSigAccount 多重签名账户，保存验证脚本用于签名和估算交易大小
+type MultiSigAccount struct {
+	Address    string   `storm:"id"`
+	AccountID  string   `storm:"index"`
+	Required   int      //最少签名数
+	PublicKeys []string //参与者公钥hex，按验证脚本中的顺序
+	Script     string   //验证脚本hex
+	CreateAt   int64
+}
+
+//OpenwalletAddress 转为openwallet地址，公钥字段保存验证脚本，与多签地址估算交易大小的约定一致
+func (a *MultiSigAccount) OpenwalletAddress(symbol string) *openwallet.Address {
+	return &openwallet.Address{
+		AccountID:   a.AccountID,
+		Address:     a.Address,
+		PublicKey:   a.Script,
+		Symbol:      symbol,
+		CreatedTime: a.CreateAt,
+		WatchOnly:   true,
+	}
+}
+
+//CreateMultiSigAccount 由参与者公钥创建m-of-n多重签名账户，保存验证脚本，并加入扫描器观测，
+//accountID为提取时地址归属的账户，为空时使用地址
+func (wm *WalletManager) CreateMultiSigAccount(accountID string, required int, publicKeys []string) (*MultiSigAccount, error) {
+
+	pubs := make([][]byte, 0, len(publicKeys))
+	for _, pk := range publicKeys {
+		pub, err := hex.DecodeString(pk)
+		if err != nil {
+			return nil, wm.errorf(openwallet.ErrAdressEncodeFailed, "public key: %s is invalid", pk)
+		}
+		pubs = append(pubs, pub)
+	}
+
+	script, address, err := neoTransaction.CreateMultiSigRedeemScript(required, pubs)
+	if err != nil {
+		return nil, wm.errorf(openwallet.ErrAdressEncodeFailed, "create multisig script failed, unexpected error: %v", err)
+	}
+
+	//验证脚本中公钥已排序并压缩
+	_, sorted, err := parseVerificationScript(script)
+	if err != nil {
+		return nil, wm.errorf(openwallet.ErrAdressEncodeFailed, "parse multisig script failed, unexpected error: %v", err)
+	}
+
+	if len(accountID) == 0 {
+		accountID = address
+	}
+
+	account := &MultiSigAccount{
+		Address:    address,
+		AccountID:  accountID,
+		Required:   required,
+		PublicKeys: make([]string, 0, len(sorted)),
+		Script:     hex.EncodeToString(script),
+		CreateAt:   time.Now().Unix(),
+	}
+	for _, pub := range sorted {
+		account.PublicKeys = append(account.PublicKeys, hex.EncodeToString(pub))
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "open local db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	//重复创建时保留首次创建的账户
+	var exist MultiSigAccount
+	err = db.One("Address", address, &exist)
+	if err == nil {
+		if exist.AccountID != accountID {
+			return nil, wm.errorf(openwallet.ErrAdressEncodeFailed, "multisig address: %s belongs to account: %s", address, exist.AccountID)
+		}
+		account = &exist
+	} else if err != storm.ErrNotFound {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get multisig account failed, unexpected error: %v", err)
+	} else if err = db.Save(account); err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "save multisig account failed, unexpected error: %v", err)
+	}
+
+	if wm.Blockscanner != nil {
+		wm.Blockscanner.watchMultiSigAddress(account.Address, account.AccountID)
+	}
+
+	return account, nil
+}
+
+//GetMultiSigAccount 获取多重签名地址的账户
+func (wm *WalletManager) GetMultiSigAccount(address string) (*MultiSigAccount, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var account MultiSigAccount
+	err = db.One("Address", address, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+//GetMultiSigAccounts 获取多重签名账户，accountID为空时返回全部
+func (wm *WalletManager) GetMultiSigAccounts(accountID string) ([]*MultiSigAccount, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*MultiSigAccount
+	if len(accountID) > 0 {
+		err = db.Select(q.Eq("AccountID", accountID)).Find(&list)
+	} else {
+		err = db.All(&list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//watchMultiSigAddress 观测多重签名地址，提取时直接归属账户，不开启观测地址预过滤
+func (bs *NEOBlockScanner) watchMultiSigAddress(address, accountID string) {
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if bs.multiSigWatch == nil {
+		bs.multiSigWatch = make(map[string]string)
+	}
+	bs.multiSigWatch[address] = accountID
+}
+
+//multiSigAccount 多重签名地址归属的账户
+func (bs *NEOBlockScanner) multiSigAccount(address string) (string, bool) {
+
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+
+	accountID, ok := bs.multiSigWatch[address]
+	return accountID, ok
+}
+
+//loadMultiSigAccounts 启动时恢复已创建的多重签名账户的观测
+func (bs *NEOBlockScanner) loadMultiSigAccounts() {
+
+	list, err := bs.wm.GetMultiSigAccounts("")
+	if err != nil {
+		bs.wm.Log.Std.Error("get multisig accounts failed, unexpected error: %v", err)
+		return
+	}
+
+	for _, account := range list {
+		bs.watchMultiSigAddress(account.Address, account.AccountID)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalletManager_CreateMultiSigAccount(t *testing.T) {
+	const (
+		pub1 = "031a6c6fbbdf02ca351745fa86b9ba5a9452d785ac4f7fc2b7548ca2a46c4fcf4a"
+		pub2 = "02df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5f"
+	)
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	if _, err := wm.CreateMultiSigAccount("multi", 3, []string{pub1, pub2}); err == nil {
+		t.Errorf("required more than public keys should fail")
+	}
+	if _, err := wm.CreateMultiSigAccount("multi", 1, []string{"zz"}); err == nil {
+		t.Errorf("invalid public key should fail")
+	}
+
+	account, err := wm.CreateMultiSigAccount("multi", 2, []string{pub1, pub2})
+	if err != nil {
+		t.Errorf("CreateMultiSigAccount failed unexpected error: %v", err)
+		return
+	}
+
+	//验证脚本可解析，并能估算多签见证大小
+	script, _ := hex.DecodeString(account.Script)
+	m, pubkeys, err := parseVerificationScript(script)
+	if err != nil || m != 2 || len(pubkeys) != 2 || len(account.PublicKeys) != 2 {
+		t.Errorf("unexpected multisig account: %+v, err: %v", account, err)
+	}
+	if addr := account.OpenwalletAddress(Symbol); addr.Address != account.Address || addr.PublicKey != account.Script {
+		t.Errorf("unexpected openwallet address: %+v", addr)
+	}
+
+	//公钥顺序不影响地址，重复创建返回已保存的账户
+	again, err := wm.CreateMultiSigAccount("multi", 2, []string{pub2, pub1})
+	if err != nil || again.Address != account.Address || again.CreateAt != account.CreateAt {
+		t.Errorf("create again: %+v, unexpected error: %v", again, err)
+	}
+	if _, err := wm.CreateMultiSigAccount("other", 2, []string{pub1, pub2}); err == nil {
+		t.Errorf("multisig address of another account should fail")
+	}
+
+	//未开启观测地址预过滤时，多签地址直接归属，其他地址仍由ScanAddressFunc判断
+	filterFunc := wm.Blockscanner.filterScanAddressFunc(func(address string) (string, bool) {
+		return "scanned", address == "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	})
+	if accountID, ok := filterFunc(account.Address); !ok || accountID != "multi" {
+		t.Errorf("multisig address should belong to account, got: %s", accountID)
+	}
+	if accountID, ok := filterFunc("AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"); !ok || accountID != "scanned" {
+		t.Errorf("other address should be scanned by ScanAddressFunc, got: %s", accountID)
+	}
+
+	//重启后恢复观测
+	restarted := NewWalletManager()
+	restarted.Config.DBPath = wm.Config.DBPath
+	restarted.Blockscanner.loadMultiSigAccounts()
+	if accountID, ok := restarted.Blockscanner.multiSigAccount(account.Address); !ok || accountID != "multi" {
+		t.Errorf("multisig address should be watched after restart")
+	}
+
+	list, _ := wm.GetMultiSigAccounts("multi")
+	if len(list) != 1 {
+		t.Errorf("multisig accounts: %d", len(list))
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	NEOClaimDefault  = 0 //按地址余额从小到大选取，找零到第一个输入地址
+	NEOClaimPreserve = 1 //尽量少花费NEO，减少被结束GAS领取周期的NEO，余额退回原地址
+	NEOClaimReset    = 2 //花费账户全部NEO并把余额重新发送给原地址，结束所有领取周期使GAS可领取
+
+	//NEOClaimPolicyParam 交易单ExtParam中的领取周期策略字段，优先于配置
+	NEOClaimPolicyParam = "neoClaimPolicy"
+)
+
+//SetNEOClaimPolicy 设置交易单发送NEO时的领取周期策略
+func SetNEOClaimPolicy(rawTx *openwallet.RawTransaction, policy int) error {
+	return rawTx.SetExtParam(NEOClaimPolicyParam, policy)
+}
+
+//neoClaimPolicy 读取交易单的领取周期策略，未指定时使用配置
+func (decoder *TransactionDecoder) neoClaimPolicy(rawTx *openwallet.RawTransaction) int {
+	if len(rawTx.ExtParam) > 0 {
+		if policy := rawTx.GetExtParam().Get(NEOClaimPolicyParam); policy.Exists() {
+			return int(policy.Int())
+		}
+	}
+	return decoder.wm.config().NEOClaimPolicy
+}
+
+//selectNEOUnspentsByClaimPolicy 按领取周期策略选取账户的未花记录。
+//NEO的UTXO被花费时结束其GAS领取周期，保留策略优先选取余额足够的最小单个地址，
+//重置策略选取账户全部持有NEO的地址
+func (decoder *TransactionDecoder) selectNEOUnspentsByClaimPolicy(wrapper openwallet.WalletDAI, accountID string, totalSend decimal.Decimal, policy int) ([]*UnspentBalance, decimal.Decimal, error) {
+
+	switch policy {
+	case NEOClaimPreserve, NEOClaimReset:
+	default:
+		return decoder.selectNEOUnspents(wrapper, accountID, totalSend)
+	}
+
+	unspents, err := decoder.listNEOUnspents(wrapper, accountID)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	//未花记录已按余额从小到大排序，第一个足够支付的地址花费的NEO最少
+	if policy == NEOClaimPreserve {
+		for _, u := range unspents {
+			if ua := unspentNEOAmount(u); ua.GreaterThan(decimal.Zero) && ua.GreaterThanOrEqual(totalSend) {
+				return []*UnspentBalance{u}, ua, nil
+			}
+		}
+	}
+
+	var (
+		usedNEOUTXO = make([]*UnspentBalance, 0)
+		neoBalance  = decimal.Zero
+	)
+
+	for _, u := range unspents {
+		ua := unspentNEOAmount(u)
+		if !ua.GreaterThan(decimal.Zero) {
+			continue
+		}
+		neoBalance = neoBalance.Add(ua)
+		usedNEOUTXO = append(usedNEOUTXO, u)
+		if policy == NEOClaimPreserve && neoBalance.GreaterThanOrEqual(totalSend) {
+			break
+		}
+	}
+
+	if neoBalance.LessThan(totalSend) {
+		return nil, neoBalance, decoder.wm.errorf(openwallet.ErrInsufficientBalanceOfAccount, "The balance: %s is not enough! ", neoBalance.StringFixed(decoder.wm.Decimal()))
+	}
+
+	return usedNEOUTXO, neoBalance, nil
+}
+
+//resendNEORemainder 按输入顺序扣除发送数额，每个地址剩余的NEO重新发送给该地址，
+//处理结果记录到交易单的ExtParam
+func (decoder *TransactionDecoder) resendNEORemainder(rawTx *openwallet.RawTransaction, outputAddrs map[string]decimal.Decimal, usedNEOUTXO []*UnspentBalance, totalSend decimal.Decimal, policy int) map[string]decimal.Decimal {
+
+	resend := make(map[string]string)
+	remain := totalSend
+	for _, u := range usedNEOUTXO {
+		amount := unspentNEOAmount(u)
+		if remain.GreaterThan(decimal.Zero) {
+			spent := decimal.Min(amount, remain)
+			remain = remain.Sub(spent)
+			amount = amount.Sub(spent)
+		}
+		if amount.GreaterThan(decimal.Zero) {
+			outputAddrs = appendOutput(outputAddrs, u.Address, amount)
+			resend[u.Address] = amount.String()
+			decoder.wm.Log.Std.Notice("Resend: %v to Address: %s", amount.StringFixed(decoder.wm.Decimal()), u.Address)
+		}
+	}
+
+	rawTx.SetExtParam("neoClaimResend", map[string]interface{}{
+		"policy": policy,
+		"resend": resend,
+	})
+
+	return outputAddrs
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+const testClaimReceiver = "AXXYzk1kn9Bj8PHeqha921gqCpwJNRmuHC"
+
+//testClaimWallet 资产账户持有多个地址
+type testClaimWallet struct {
+	openwallet.WalletDAIBase
+}
+
+func (w *testClaimWallet) GetAddressList(offset, limit int, cols ...interface{}) ([]*openwallet.Address, error) {
+	addrs := []string{testHotAddress, testColdAddress1, testColdAddress2}
+	for i := 0; i+1 < len(cols); i += 2 {
+		if cols[i] == "Address" {
+			addrs = nil
+			for _, addr := range []string{testHotAddress, testColdAddress1, testColdAddress2} {
+				if cols[i+1] == addr {
+					addrs = []string{addr}
+				}
+			}
+		}
+	}
+	list := make([]*openwallet.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		list = append(list, &openwallet.Address{AccountID: "claim", Address: addr})
+	}
+	return list, nil
+}
+
+func (w *testClaimWallet) GetAddress(address string) (*openwallet.Address, error) {
+	return &openwallet.Address{AccountID: "claim", Address: address}, nil
+}
+
+func TestTransactionDecoder_NEOClaimPolicy(t *testing.T) {
+	balances := map[string]int{testHotAddress: 100, testColdAddress1: 500, testColdAddress2: 50}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getunspents" {
+			return nil
+		}
+		address := params[0].(string)
+		return map[string]interface{}{
+			"address": address,
+			"balance": []interface{}{
+				map[string]interface{}{
+					"unspent":      []interface{}{map[string]interface{}{"txid": testHash(address)[2:], "n": 0, "value": balances[address]}},
+					"asset_hash":   "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+					"asset":        "NEO",
+					"asset_symbol": "NEO",
+					"amount":       balances[address],
+				},
+			},
+		}
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.Config.MaxTxInputs = 10
+	decoder := NewTransactionDecoder(wm)
+	wrapper := &testClaimWallet{}
+
+	create := func(policy int, override bool) *openwallet.RawTransaction {
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: Symbol},
+			Account: &openwallet.AssetsAccount{AccountID: "claim", Symbol: Symbol},
+			To:      map[string]string{testClaimReceiver: "120"},
+			FeeRate: "0",
+		}
+		if override {
+			SetNEOClaimPolicy(rawTx, policy)
+		} else {
+			wm.Config.NEOClaimPolicy = policy
+		}
+		if err := decoder.CreateNEORawTransaction(wrapper, rawTx); err != nil {
+			t.Fatalf("CreateNEORawTransaction failed unexpected error: %v", err)
+		}
+		return rawTx
+	}
+
+	//默认按余额从小到大选取，找零到第一个输入地址
+	rawTx := create(NEOClaimDefault, false)
+	sort.Strings(rawTx.TxTo)
+	if len(rawTx.TxFrom) != 2 || !reflect.DeepEqual(rawTx.TxTo, []string{testColdAddress2 + ":30", testClaimReceiver + ":120"}) {
+		t.Errorf("unexpected default transaction, from: %v, to: %v", rawTx.TxFrom, rawTx.TxTo)
+	}
+	if gjson.Get(rawTx.ExtParam, "neoClaimResend").Exists() {
+		t.Errorf("default policy should not resend: %s", rawTx.ExtParam)
+	}
+
+	//保留策略只花费余额足够的最小地址，余额退回原地址
+	rawTx = create(NEOClaimPreserve, false)
+	if len(rawTx.TxFrom) != 1 || rawTx.TxFrom[0] != testColdAddress1+":500" {
+		t.Errorf("unexpected preserve inputs: %v", rawTx.TxFrom)
+	}
+	resend := gjson.Get(rawTx.ExtParam, "neoClaimResend.resend").Map()
+	if len(resend) != 1 || resend[testColdAddress1].String() != "380" {
+		t.Errorf("unexpected preserve resend: %s", rawTx.ExtParam)
+	}
+
+	//交易单指定的重置策略优先于配置，花费全部地址并把余额重新发送给原地址
+	rawTx = create(NEOClaimReset, true)
+	if len(rawTx.TxFrom) != 3 {
+		t.Errorf("unexpected reset inputs: %v", rawTx.TxFrom)
+	}
+	resend = gjson.Get(rawTx.ExtParam, "neoClaimResend.resend").Map()
+	if len(resend) != 2 || resend[testHotAddress].String() != "30" || resend[testColdAddress1].String() != "500" {
+		t.Errorf("unexpected reset resend: %s", rawTx.ExtParam)
+	}
+	if gjson.Get(rawTx.ExtParam, "neoClaimResend.policy").Int() != NEOClaimReset {
+		t.Errorf("unexpected reset policy: %s", rawTx.ExtParam)
+	}
+}
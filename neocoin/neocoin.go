@@ -21,12 +21,15 @@ import (
 	"github.com/astaxie/beego/config"
 	"github.com/blocktree/openwallet/common"
 	"github.com/blocktree/openwallet/console"
+	"github.com/blocktree/openwallet/hdkeystore"
 	"github.com/blocktree/openwallet/log"
 	"github.com/blocktree/openwallet/openwallet"
 	"github.com/blocktree/openwallet/timer"
 	"github.com/shopspring/decimal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //初始化配置流程
@@ -354,7 +357,7 @@ func (wm *WalletManager) TransferFlow() error {
 	}
 
 	//重新加载utxo
-	wm.RebuildWalletUnspent(wallet.WalletID)
+	wm.rebuildWalletUnspent(wallet.WalletID)
 
 	//建立交易单
 	txID, err := wm.SendTransaction(wallet.WalletID,
@@ -488,7 +491,7 @@ func (wm *WalletManager) ShowConfigInfo(subCmd string) error {
 
 //CurveType 曲线类型
 func (wm *WalletManager) CurveType() uint32 {
-	return wm.Config.CurveType
+	return wm.config().CurveType
 }
 
 //FullName 币种全名
@@ -498,12 +501,12 @@ func (wm *WalletManager) FullName() string {
 
 //Symbol 币种标识
 func (wm *WalletManager) Symbol() string {
-	return wm.Config.Symbol
+	return wm.config().Symbol
 }
 
 //小数位精度
 func (wm *WalletManager) Decimal() int32 {
-	return wm.Config.Decimals
+	return wm.config().Decimals
 }
 
 //AddressDecode 地址解析器
@@ -551,8 +554,8 @@ func (wm *WalletManager) ImportWatchOnlyAddress(address ...*openwallet.Address)
 	//	log.Debug("end ImportAddress")
 	//}
 
-	if len(wm.Config.WalletPassword) > 0 {
-		wm.UnlockWallet(wm.Config.WalletPassword, 600)
+	if len(wm.config().WalletPassword) > 0 {
+		wm.UnlockWallet(wm.config().WalletPassword, 600)
 	}
 
 	failedIndex, err := wm.ImportMulti(address, nil, true)
@@ -614,37 +617,474 @@ func (wm *WalletManager) GetAddressWithBalance(address ...*openwallet.Address) e
 
 }
 
-//LoadAssetsConfig 加载外部配置
+//LoadAssetsConfig 加载外部配置，检查通过后整体替换当前配置，检查失败时当前配置不变，所有问题一次返回
 func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 
-	wm.Config.RPCServerType, _ = c.Int("rpcServerType")
-	wm.Config.ServerAPI = c.String("serverAPI")
-	wm.Config.RpcUser = c.String("rpcUser")
-	wm.Config.RpcPassword = c.String("rpcPassword")
-	wm.Config.IsTestNet, _ = c.Bool("isTestNet")
-	wm.Config.SupportSegWit, _ = c.Bool("supportSegWit")
-	wm.Config.OmniTransferCost = c.String("omniTransferCost")
-	wm.Config.OmniCoreAPI = c.String("omniCoreAPI")
-	wm.Config.OmniRPCUser = c.String("omniRPCUser")
-	wm.Config.OmniRPCPassword = c.String("omniRPCPassword")
-	wm.Config.OmniSupport, _ = c.Bool("omniSupport")
-	wm.Config.MinFees, _ = decimal.NewFromString(c.String("minFees"))
-	wm.Config.MinFees = wm.Config.MinFees.Round(wm.Decimal())
-	wm.Config.DataDir = c.String("dataDir")
+	cfg, assetsStamp := wm.parseAssetsConfig(c)
+
+	if problems := cfg.Validate(); len(problems) > 0 {
+		return wm.errorf(ErrConfigInvalid, "invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return wm.applyAssetsConfig(cfg, assetsStamp)
+}
+
+//parseAssetsConfig 在当前配置的副本上读取外部配置，不修改正在使用的配置，
+//返回新配置和跟踪资产文件的修改标记
+func (wm *WalletManager) parseAssetsConfig(c config.Configer) (*WalletConfig, string) {
+
+	cfg := wm.config().clone()
+	assetsStamp := wm.assetsFileStamp
+
+	//私有链自定义币种标识，本地数据按币种隔离
+	if symbol := c.String("symbol"); len(symbol) > 0 && symbol != cfg.Symbol {
+		cfg.setSymbol(symbol)
+	}
+	if assetID := c.String("neoAssetID"); len(assetID) > 0 {
+		cfg.NEOAssetID = strings.TrimPrefix(assetID, "0x")
+	}
+	if assetID := c.String("gasAssetID"); len(assetID) > 0 {
+		cfg.GASAssetID = strings.TrimPrefix(assetID, "0x")
+	}
+
+	cfg.RPCServerType, _ = c.Int("rpcServerType")
+	cfg.ServerAPI = c.String("serverAPI")
+	cfg.RpcUser = c.String("rpcUser")
+	cfg.RpcPassword = c.String("rpcPassword")
+	cfg.IsTestNet, _ = c.Bool("isTestNet")
+	if magic, err := c.Int64("networkMagic"); err == nil && magic >= 0 {
+		cfg.NetworkMagic = uint32(magic)
+	}
+	cfg.SupportSegWit, _ = c.Bool("supportSegWit")
+	cfg.OmniTransferCost = c.String("omniTransferCost")
+	cfg.OmniCoreAPI = c.String("omniCoreAPI")
+	cfg.OmniRPCUser = c.String("omniRPCUser")
+	cfg.OmniRPCPassword = c.String("omniRPCPassword")
+	cfg.OmniSupport, _ = c.Bool("omniSupport")
+	cfg.OmniDeprecatedCompat, _ = c.Bool("omniDeprecatedCompat")
+	cfg.MinFees, _ = decimal.NewFromString(c.String("minFees"))
+	cfg.MinFees = cfg.MinFees.Round(cfg.Decimals)
+	cfg.DataDir = c.String("dataDir")
+	cfg.ExplorerAPI = c.String("explorerAPI")
+	if schema := c.String("explorerSchema"); len(schema) > 0 {
+		cfg.ExplorerSchema = schema
+	}
+	cfg.WarmStartFromExplorer, _ = c.Bool("warmStartFromExplorer")
+	cfg.NonstandardOutputPolicy, _ = c.Int("nonstandardOutputPolicy")
+	cfg.ZeroConfDepositPolicy, _ = c.Int("zeroConfDepositPolicy")
+	cfg.PinnedScan, _ = c.Bool("pinnedScan")
+	cfg.VerifyBlockSignature, _ = c.Bool("verifyBlockSignature")
+	cfg.ForkRescanVerifyWitness, _ = c.Bool("forkRescanVerifyWitness")
+	cfg.AdaptiveRescan, _ = c.Bool("adaptiveRescan")
+	if rescanMin, err := c.Int64("rescanMinBlockCount"); err == nil && rescanMin >= 0 {
+		cfg.RescanMinBlockCount = uint64(rescanMin)
+	}
+	if rescanMax, err := c.Int64("rescanMaxBlockCount"); err == nil && rescanMax >= 0 {
+		cfg.RescanMaxBlockCount = uint64(rescanMax)
+	}
+	if cfg.RescanMaxBlockCount < cfg.RescanMinBlockCount {
+		cfg.RescanMaxBlockCount = cfg.RescanMinBlockCount
+	}
+	if reorgSeconds, err := c.Int("reorgHistorySeconds"); err == nil && reorgSeconds > 0 {
+		cfg.ReorgHistoryPeriod = time.Duration(reorgSeconds) * time.Second
+	}
+	cfg.ChangeDustThreshold, _ = decimal.NewFromString(c.String("changeDustThreshold"))
+	cfg.ChangeDustPolicy, _ = c.Int("changeDustPolicy")
+	cfg.NEOClaimPolicy, _ = c.Int("neoClaimPolicy")
+	cfg.RiskBlockScore, _ = c.Float("riskBlockScore")
+	cfg.WithdrawMaxPerTx, _ = decimal.NewFromString(c.String("withdrawMaxPerTx"))
+	cfg.WithdrawMaxPerHour, _ = decimal.NewFromString(c.String("withdrawMaxPerHour"))
+	cfg.WithdrawMaxPerDay, _ = decimal.NewFromString(c.String("withdrawMaxPerDay"))
+	cfg.WithdrawAssetLimits = make(map[string]WithdrawLimit)
+	for _, value := range strings.Split(c.String("withdrawAssetLimits"), ",") {
+		if value = strings.TrimSpace(value); len(value) == 0 {
+			continue
+		}
+		asset, limit, err := parseWithdrawAssetLimit(value)
+		if err != nil {
+			wm.Log.Std.Error("%v, skipped", err)
+			continue
+		}
+		cfg.WithdrawAssetLimits[asset] = limit
+	}
+	cfg.ProfileLabels, _ = c.Bool("profileLabels")
+	cfg.AuditLog, _ = c.Bool("auditLog")
+	cfg.OperationToken = c.String("operationToken")
+	cfg.DBEncryptKey = c.String("dbEncryptKey")
+	cfg.SignMode, _ = c.Int("signMode")
+	cfg.SignWorkers, _ = c.Int("signWorkers")
+	cfg.AuditLogKey = c.String("auditLogKey")
+	cfg.ActivityHeartbeatURL = c.String("activityHeartbeatURL")
+	cfg.GenesisBlockHash = c.String("genesisBlockHash")
+	if lockTimeout, err := c.Int("dbLockTimeout"); err == nil && lockTimeout > 0 {
+		cfg.DBLockTimeout = time.Duration(lockTimeout) * time.Second
+	}
+	if flavor := c.String("nodeFlavor"); len(flavor) > 0 {
+		cfg.NodeFlavor = flavor
+	}
+	if offset, err := c.Int64("blockCountOffset"); err == nil {
+		cfg.BlockCountOffset = offset
+	}
+	if leaseTTL, err := c.Int("scanLeaseTTL"); err == nil && leaseTTL >= 0 {
+		cfg.ScanLeaseTTL = time.Duration(leaseTTL) * time.Second
+	}
+	if snapshotTTL, err := c.Int("txPageSnapshotTTL"); err == nil && snapshotTTL >= 0 {
+		cfg.TxPageSnapshotTTL = time.Duration(snapshotTTL) * time.Second
+	}
+	cfg.ExplorerListen = c.String("explorerListen")
+	cfg.HydrateMempoolRecords, _ = c.Bool("hydrateMempoolRecords")
+	cfg.TokenContracts = make([]string, 0)
+	cfg.TokenContractActivation = make(map[string]uint64)
+	for _, value := range strings.Split(c.String("tokenContracts"), ",") {
+		if value = strings.TrimSpace(value); len(value) == 0 {
+			continue
+		}
+		contract, height, err := parseTokenContract(value)
+		if err != nil {
+			wm.Log.Std.Error("token contract: %s activation height is invalid, skipped, unexpected error: %v", value, err)
+			continue
+		}
+		cfg.TokenContracts = append(cfg.TokenContracts, contract)
+		if height > 0 {
+			cfg.TokenContractActivation[contract] = height
+		}
+	}
+	cfg.DisabledTokenContracts = make([]string, 0)
+	for _, contract := range strings.Split(c.String("disabledTokenContracts"), ",") {
+		if contract = strings.TrimSpace(contract); len(contract) > 0 {
+			cfg.DisabledTokenContracts = append(cfg.DisabledTokenContracts, normalizeContractHash(contract))
+		}
+	}
+	if refreshSeconds, err := c.Int("tokenMetadataRefreshSeconds"); err == nil && refreshSeconds > 0 {
+		cfg.TokenMetadataRefreshInterval = time.Duration(refreshSeconds) * time.Second
+	}
+	cfg.TrackedAssetsFile = c.String("trackedAssetsFile")
+	if reloadSeconds, err := c.Int("trackedAssetsReloadSeconds"); err == nil && reloadSeconds > 0 {
+		cfg.TrackedAssetsReloadInterval = time.Duration(reloadSeconds) * time.Second
+	}
+	//跟踪资产文件替代tokenContracts，文件有错误时由Validate报告
+	if len(cfg.TrackedAssetsFile) > 0 {
+		if assets, info, err := cfg.readTrackedAssetsFile(); err == nil {
+			cfg.applyTrackedAssets(assets)
+			assetsStamp = trackedAssetsStamp(info)
+		}
+	}
+	cfg.NotifyOutbox, _ = c.Bool("notifyOutbox")
+	if redeliverSeconds, err := c.Int("notifyRedeliverSeconds"); err == nil && redeliverSeconds > 0 {
+		cfg.NotifyRedeliverInterval = time.Duration(redeliverSeconds) * time.Second
+	}
+	if budget, err := c.Int("observerNotifyBudgetMs"); err == nil && budget >= 0 {
+		cfg.ObserverNotifyBudget = time.Duration(budget) * time.Millisecond
+	}
+	if streak, err := c.Int64("observerSlowStreak"); err == nil && streak > 0 {
+		cfg.ObserverSlowStreak = uint64(streak)
+	}
+	if chunkSize, err := c.Int("notifyChunkSize"); err == nil && chunkSize >= 0 {
+		cfg.NotifyChunkSize = chunkSize
+	}
+	if chunkBytes, err := c.Int("notifyChunkBytes"); err == nil && chunkBytes >= 0 {
+		cfg.NotifyChunkBytes = chunkBytes
+	}
+	if behind, err := c.Int64("loadShedBehind"); err == nil && behind >= 0 {
+		cfg.LoadShedBehind = uint64(behind)
+	}
+	if resume, err := c.Int64("loadShedResume"); err == nil && resume >= 0 {
+		cfg.LoadShedResume = uint64(resume)
+	}
+	if lagThreshold, err := c.Int64("headLagThreshold"); err == nil && lagThreshold > 0 {
+		cfg.HeadLagThreshold = uint64(lagThreshold)
+	}
+	if jitterSeconds, err := c.Int("schedulerJitterSeconds"); err == nil && jitterSeconds >= 0 {
+		cfg.SchedulerJitter = time.Duration(jitterSeconds) * time.Second
+	}
+	cfg.ClaimGASJob, _ = c.Bool("claimGASJob")
+	cfg.ClaimGASAddresses = make([]string, 0)
+	for _, address := range strings.Split(c.String("claimGASAddresses"), ",") {
+		if address = strings.TrimSpace(address); len(address) > 0 {
+			cfg.ClaimGASAddresses = append(cfg.ClaimGASAddresses, address)
+		}
+	}
+	if claimSeconds, err := c.Int("claimGASSeconds"); err == nil && claimSeconds > 0 {
+		cfg.ClaimGASInterval = time.Duration(claimSeconds) * time.Second
+	}
+	cfg.CompactDBJob, _ = c.Bool("compactDBJob")
+	if compactSeconds, err := c.Int("compactDBSeconds"); err == nil && compactSeconds > 0 {
+		cfg.CompactDBInterval = time.Duration(compactSeconds) * time.Second
+	}
+	if broadcastJob, err := c.Bool("scheduledBroadcastJob"); err == nil {
+		cfg.ScheduledBroadcastJob = broadcastJob
+	}
+	if broadcastSeconds, err := c.Int("scheduledBroadcastSeconds"); err == nil && broadcastSeconds > 0 {
+		cfg.ScheduledBroadcastInterval = time.Duration(broadcastSeconds) * time.Second
+	}
+	cfg.ConfirmNotify, _ = c.Bool("confirmNotify")
+	cfg.MinConfirmations = make(map[string]uint64)
+	for _, item := range strings.Split(c.String("minConfirmations"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.TrimSpace(kv[1]), 10, 64); err == nil && n > 0 {
+			cfg.MinConfirmations[strings.TrimSpace(kv[0])] = n
+		}
+	}
+	cfg.FeatureFlags = make(map[string]bool)
+	for _, item := range strings.Split(c.String("featureFlags"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[1]) {
+		case "on":
+			cfg.FeatureFlags[strings.TrimSpace(kv[0])] = true
+		case "off":
+			cfg.FeatureFlags[strings.TrimSpace(kv[0])] = false
+		}
+	}
+	cfg.DepositCrossVerify, _ = c.Bool("depositCrossVerify")
+	if threshold, err := decimal.NewFromString(c.String("depositCrossVerifyThreshold")); err == nil && !threshold.IsNegative() {
+		cfg.DepositCrossVerifyThreshold = threshold
+	}
+	cfg.AddressAuditMode, _ = c.Int("addressAuditMode")
+	if batchSize, err := c.Int("addressAuditBatchSize"); err == nil && batchSize > 0 {
+		cfg.AddressAuditBatchSize = batchSize
+	}
+	if sampleRate, err := c.Int("addressAuditSampleRate"); err == nil && sampleRate > 0 {
+		cfg.AddressAuditSampleRate = sampleRate
+	}
+	if feeStatsBlocks, err := c.Int64("feeStatsBlocks"); err == nil && feeStatsBlocks > 0 {
+		cfg.FeeStatsBlocks = uint64(feeStatsBlocks)
+	}
+	if dualWriteSeconds, err := c.Int64("daiDualWriteSeconds"); err == nil && dualWriteSeconds > 0 {
+		cfg.DAIDualWritePeriod = time.Duration(dualWriteSeconds) * time.Second
+	}
+	if breakerThreshold, err := c.Int("rpcBreakerThreshold"); err == nil && breakerThreshold >= 0 {
+		cfg.RPCBreakerThreshold = breakerThreshold
+	}
+	if cooldownSeconds, err := c.Int("rpcBreakerCooldownSeconds"); err == nil && cooldownSeconds > 0 {
+		cfg.RPCBreakerCooldown = time.Duration(cooldownSeconds) * time.Second
+	}
+	if journalSize, err := c.Int("rpcJournalSize"); err == nil && journalSize >= 0 {
+		cfg.RPCJournalSize = journalSize
+	}
+	cfg.ForkSimulation, _ = c.Bool("forkSimulation")
+	if errorHistory, err := c.Int("statusErrorHistory"); err == nil && errorHistory >= 0 {
+		cfg.StatusErrorHistory = errorHistory
+	}
+	cfg.ExplorerMode, _ = c.Bool("explorerMode")
+	cfg.UTXOHistory, _ = c.Bool("utxoHistory")
+	cfg.RawTxArchive, _ = c.Bool("rawTxArchive")
+	if archiveWatch, err := c.Bool("archiveWatchAddressJob"); err == nil {
+		cfg.ArchiveWatchAddressJob = archiveWatch
+	}
+	if archiveSeconds, err := c.Int("archiveWatchAddressSeconds"); err == nil && archiveSeconds > 0 {
+		cfg.ArchiveWatchAddressInterval = time.Duration(archiveSeconds) * time.Second
+	}
+	if purgeUnscan, err := c.Bool("purgeUnscanRecordJob"); err == nil {
+		cfg.PurgeUnscanRecordJob = purgeUnscan
+	}
+	if purgeSeconds, err := c.Int("purgeUnscanRecordSeconds"); err == nil && purgeSeconds > 0 {
+		cfg.PurgeUnscanRecordInterval = time.Duration(purgeSeconds) * time.Second
+	}
+	if retentionSeconds, err := c.Int("unscanRecordRetentionSeconds"); err == nil && retentionSeconds >= 0 {
+		cfg.UnscanRecordRetention = time.Duration(retentionSeconds) * time.Second
+	}
+	if pruneExtract, err := c.Bool("pruneExtractDataJob"); err == nil {
+		cfg.PruneExtractDataJob = pruneExtract
+	}
+	if pruneSeconds, err := c.Int("pruneExtractDataSeconds"); err == nil && pruneSeconds > 0 {
+		cfg.PruneExtractDataInterval = time.Duration(pruneSeconds) * time.Second
+	}
+	if retentionBlocks, err := c.Int64("extractDataRetentionBlocks"); err == nil && retentionBlocks > 0 {
+		cfg.ExtractDataRetentionBlocks = uint64(retentionBlocks)
+	}
+	if storageJob, err := c.Bool("storageMetricsJob"); err == nil {
+		cfg.StorageMetricsJob = storageJob
+	}
+	if storageSeconds, err := c.Int("storageMetricsSeconds"); err == nil && storageSeconds > 0 {
+		cfg.StorageMetricsInterval = time.Duration(storageSeconds) * time.Second
+	}
+	if warnSize, err := c.Int64("storageWarnSizeMB"); err == nil && warnSize >= 0 {
+		cfg.StorageWarnSize = warnSize << 20
+	}
+	if warnGrowth, err := c.Int64("storageWarnGrowthMBPerHour"); err == nil && warnGrowth >= 0 {
+		cfg.StorageWarnGrowth = warnGrowth << 20
+	}
+	if roundingMode, err := c.Int("feeRoundingMode"); err == nil {
+		cfg.FeeRoundingMode = RoundingMode(roundingMode)
+	}
+	if fiatCurrency := c.String("fiatCurrency"); len(fiatCurrency) > 0 {
+		cfg.FiatCurrency = fiatCurrency
+	}
+	if fiatDecimals, err := c.Int("fiatDecimals"); err == nil && fiatDecimals >= 0 {
+		cfg.FiatDecimals = int32(fiatDecimals)
+	}
+	if gasFiatRate, err := decimal.NewFromString(c.String("gasFiatRate")); err == nil {
+		cfg.GASFiatRate = gasFiatRate
+	}
+	if headCheck, err := c.Bool("startupHeadCheck"); err == nil {
+		cfg.StartupHeadCheck = headCheck
+	}
+	if unspentCache, err := c.Bool("unspentCache"); err == nil {
+		cfg.UnspentCache = unspentCache
+	}
+	cfg.PriorityBackfill, _ = c.Bool("priorityBackfill")
+	if minLag, err := c.Int64("priorityBackfillMinLag"); err == nil && minLag > 0 {
+		cfg.PriorityBackfillMinLag = uint64(minLag)
+	}
+	if maxTxSize, err := c.Int("maxTxSize"); err == nil && maxTxSize > 0 {
+		cfg.MaxTxSize = maxTxSize
+	}
+	if checkpointBlocks, err := c.Int64("checkpointBlocks"); err == nil && checkpointBlocks > 0 {
+		cfg.CheckpointBlocks = uint64(checkpointBlocks)
+	}
+	if snapshotBlocks, err := c.Int64("scanSnapshotBlocks"); err == nil && snapshotBlocks >= 0 {
+		cfg.ScanSnapshotBlocks = uint64(snapshotBlocks)
+	}
+	if snapshotRetention, err := c.Int("scanSnapshotRetention"); err == nil && snapshotRetention >= 0 {
+		cfg.ScanSnapshotRetention = snapshotRetention
+	}
+	if mempoolSync, err := c.Bool("startupMempoolSync"); err == nil {
+		cfg.StartupMempoolSync = mempoolSync
+	}
+	if locale := c.String("locale"); len(locale) > 0 {
+		cfg.Locale = locale
+	}
+	cfg.SelfTestBeforeRun, _ = c.Bool("selfTestBeforeRun")
+	if retryInterval, err := c.Int("selfTestRetryInterval"); err == nil && retryInterval > 0 {
+		cfg.SelfTestRetryInterval = time.Duration(retryInterval) * time.Second
+	}
+	if heartbeatBlocks, err := c.Int64("activityHeartbeatBlocks"); err == nil && heartbeatBlocks > 0 {
+		cfg.ActivityHeartbeatBlocks = uint64(heartbeatBlocks)
+	}
+	if chunkSize, err := c.Int("unspentQueryChunkSize"); err == nil && chunkSize > 0 {
+		cfg.UnspentQueryChunkSize = chunkSize
+	}
+	if concurrency, err := c.Int("unspentQueryConcurrency"); err == nil && concurrency > 0 {
+		cfg.UnspentQueryConcurrency = concurrency
+	}
+	if forkRollbackNotify, err := c.Bool("forkRollbackNotify"); err == nil {
+		cfg.ForkRollbackNotify = forkRollbackNotify
+	}
+	if txidCheck, err := c.Bool("txidCheck"); err == nil {
+		cfg.TxIDCheck = txidCheck
+	}
+	if strictNotifyOrder, err := c.Bool("strictNotifyOrder"); err == nil {
+		cfg.StrictNotifyOrder = strictNotifyOrder
+	}
+	if dropAfterScans, err := c.Int("mempoolDropAfterScans"); err == nil && dropAfterScans > 0 {
+		cfg.MempoolDropAfterScans = dropAfterScans
+	}
+	if confirmBlocks, err := c.Int64("confirmBlocks"); err == nil && confirmBlocks > 0 {
+		cfg.ConfirmBlocks = uint64(confirmBlocks)
+	}
+
+	//备用节点，与主节点使用相同的认证
+	cfg.BackupServerAPI = make([]string, 0)
+	for _, api := range strings.Split(c.String("backupServerAPI"), ",") {
+		api = strings.TrimSpace(api)
+		if len(api) == 0 {
+			continue
+		}
+		cfg.BackupServerAPI = append(cfg.BackupServerAPI, api)
+	}
+	cfg.ReadRouting, _ = c.Bool("readRouting")
+	cfg.NodeWeights = make([]int, 0)
+	for _, w := range strings.Split(c.String("nodeWeights"), ",") {
+		w = strings.TrimSpace(w)
+		if len(w) == 0 {
+			continue
+		}
+		//无法解析的权重记为-1，由Validate报告
+		weight, err := strconv.Atoi(w)
+		if err != nil {
+			weight = -1
+		}
+		cfg.NodeWeights = append(cfg.NodeWeights, weight)
+	}
+
+	//备用浏览器，用于余额和交易记录的一致性读取
+	cfg.BackupExplorerAPI = make([]string, 0)
+	for _, api := range strings.Split(c.String("backupExplorerAPI"), ",") {
+		api = strings.TrimSpace(api)
+		if len(api) == 0 {
+			continue
+		}
+		cfg.BackupExplorerAPI = append(cfg.BackupExplorerAPI, api)
+	}
+	cfg.ExplorerQuorum, _ = c.Int("explorerQuorum")
+	if threshold, err := decimal.NewFromString(c.String("explorerQuorumThreshold")); err == nil && !threshold.IsNegative() {
+		cfg.ExplorerQuorumThreshold = threshold
+	}
+	if interval, err := c.Int64("nodeDivergenceCheckSeconds"); err == nil && interval > 0 {
+		cfg.NodeDivergenceCheckInterval = time.Duration(interval) * time.Second
+	}
+
+	//公共节点模式
+	cfg.PublicNodeMode, _ = c.Bool("publicNodeMode")
+
+	return cfg, assetsStamp
+}
+
+//applyAssetsConfig 发布已检查的配置，等待正在扫描的区块结束后生效，然后创建客户端
+func (wm *WalletManager) applyAssetsConfig(cfg *WalletConfig, assetsStamp string) error {
 
 	//数据文件夹
-	wm.Config.makeDataDir()
+	cfg.makeDataDir()
 
-	token := BasicAuth(wm.Config.RpcUser, wm.Config.RpcPassword)
-	omniToken := BasicAuth(wm.Config.OmniRPCUser, wm.Config.OmniRPCPassword)
+	symbol := wm.Symbol()
+	err := wm.SwapConfig(cfg)
+	if err != nil {
+		return err
+	}
+	cfg = wm.config()
+	wm.assetsFileStamp = assetsStamp
+
+	if cfg.Symbol != symbol {
+		wm.Storage = hdkeystore.NewHDKeystore(cfg.keyDir, hdkeystore.StandardScryptN, hdkeystore.StandardScryptP)
+		wm.Log = log.NewOWLogger(cfg.Symbol)
+	}
+
+	//Omni代币功能已废弃
+	wm.logOmniDeprecation()
+
+	//升级本地数据结构
+	err = wm.MigrateLocalDB()
+	if err != nil {
+		wm.Log.Std.Error("migrate local db failed, unexpected error: %v", err)
+	}
+
+	token := BasicAuth(cfg.RpcUser, cfg.RpcPassword)
+	omniToken := BasicAuth(cfg.OmniRPCUser, cfg.OmniRPCPassword)
 
-	if wm.Config.RPCServerType == RPCServerCore {
-		wm.WalletClient = NewClient(wm.Config.ServerAPI, token, false)
+	if cfg.RPCServerType == RPCServerCore {
+		wm.WalletClient = NewClient(cfg.ServerAPI, token, false)
 	} else {
-		wm.ExplorerClient = NewExplorer(wm.Config.ServerAPI, false)
+		wm.ExplorerClient = NewExplorer(cfg.ServerAPI, false)
 	}
 
-	wm.OnmiClient = NewClient(wm.Config.OmniCoreAPI, omniToken, false)
+	//核心节点模式下，可额外配置浏览器API辅助查询
+	if wm.ExplorerClient == nil && len(cfg.ExplorerAPI) > 0 {
+		wm.ExplorerClient = NewExplorer(cfg.ExplorerAPI, false)
+	}
+
+	wm.OnmiClient = NewClient(cfg.OmniCoreAPI, omniToken, false)
+
+	wm.BackupClients = make([]*Client, 0, len(cfg.BackupServerAPI))
+	for _, api := range cfg.BackupServerAPI {
+		wm.BackupClients = append(wm.BackupClients, NewClient(api, token, false))
+	}
+
+	wm.BackupExplorerClients = make([]*Explorer, 0, len(cfg.BackupExplorerAPI))
+	for _, api := range cfg.BackupExplorerAPI {
+		wm.BackupExplorerClients = append(wm.BackupExplorerClients, NewExplorer(api, false))
+	}
+
+	//公共节点模式
+	wm.applyPublicNodePreset()
+
+	//节点RPC调用记录
+	wm.applyRPCJournal()
 
 	return nil
 }
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//NetworkMagicMainNet NEO主网的网络标识
+	NetworkMagicMainNet uint32 = 7630401
+	//NetworkMagicTestNet NEO测试网的网络标识
+	NetworkMagicTestNet uint32 = 1953787457
+
+	networkMarkerFile = "network.json" //记录本地数据所属网络的文件，与数据库文件在同一目录
+)
+
+//LocalNetwork 本地数据所属的网络
+type LocalNetwork struct {
+	Symbol   string `json:"symbol"`
+	Magic    uint32 `json:"magic"`
+	CreateAt int64  `json:"createAt"`
+}
+
+//NetworkMagicValue 当前配置的网络标识，未配置时按是否测试网取值
+func (wc *WalletConfig) NetworkMagicValue() uint32 {
+	if wc.NetworkMagic > 0 {
+		return wc.NetworkMagic
+	}
+	if wc.IsTestNet {
+		return NetworkMagicTestNet
+	}
+	return NetworkMagicMainNet
+}
+
+//networkDBPath 按币种和网络标识划分的本地数据库目录，
+//旧版未划分的目录未记录网络或记录的网络与当前一致时继续使用
+func (wc *WalletConfig) networkDBPath() string {
+
+	symbolDir := filepath.Join(wc.DataDir, strings.ToLower(wc.Symbol))
+	partitioned := filepath.Join(symbolDir, strconv.FormatUint(uint64(wc.NetworkMagicValue()), 10), "db")
+	if _, err := os.Stat(partitioned); err == nil {
+		return partitioned
+	}
+
+	legacy := filepath.Join(symbolDir, "db")
+	if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+		network, err := readLocalNetwork(legacy)
+		if err == nil && (network == nil || network.matches(wc.Symbol, wc.NetworkMagicValue())) {
+			return legacy
+		}
+	}
+
+	return partitioned
+}
+
+func (n *LocalNetwork) matches(symbol string, magic uint32) bool {
+	return strings.EqualFold(n.Symbol, symbol) && n.Magic == magic
+}
+
+//readLocalNetwork 读取目录记录的网络，未记录时返回nil
+func readLocalNetwork(dir string) (*LocalNetwork, error) {
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, networkMarkerFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var network LocalNetwork
+	if err = json.Unmarshal(raw, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+//GetLocalNetwork 获取本地数据所属的网络，未记录时返回nil
+func (wm *WalletManager) GetLocalNetwork() (*LocalNetwork, error) {
+	return readLocalNetwork(wm.config().DBPath)
+}
+
+//checkLocalNetwork 打开本地数据库前检查数据所属网络，未记录时记录为当前网络，
+//与当前币种或网络标识不一致时拒绝打开，避免切换网络后混用扫描状态
+func (wm *WalletManager) checkLocalNetwork() error {
+
+	var (
+		cfg    = wm.config()
+		dir    = cfg.DBPath
+		symbol = cfg.Symbol
+		magic  = cfg.NetworkMagicValue()
+		key    = dir + "|" + symbol + "|" + strconv.FormatUint(uint64(magic), 10)
+	)
+
+	wm.networkMu.Lock()
+	defer wm.networkMu.Unlock()
+
+	if wm.networkChecked == key {
+		return nil
+	}
+
+	network, err := readLocalNetwork(dir)
+	if err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "read local network of: %s failed, unexpected error: %v", dir, err)
+	}
+
+	if network == nil {
+		network = &LocalNetwork{Symbol: symbol, Magic: magic, CreateAt: time.Now().Unix()}
+		raw, _ := json.Marshal(network)
+		if err = os.MkdirAll(dir, os.ModePerm); err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, networkMarkerFile), raw, 0600)
+		}
+		if err != nil {
+			return wm.errorf(ErrLocalDBOperateFailed, "save local network of: %s failed, unexpected error: %v", dir, err)
+		}
+	} else if !network.matches(symbol, magic) {
+		return wm.errorf(ErrLocalDBNetworkMismatch, "local db: %s belongs to %s network: %d, current is %s network: %d", dir, network.Symbol, network.Magic, symbol, magic)
+	}
+
+	wm.networkChecked = key
+
+	return nil
+}
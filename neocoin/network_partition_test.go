@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_LocalNetworkPartition(t *testing.T) {
+	dataDir, _ := ioutil.TempDir("", "neo-data")
+	defer os.RemoveAll(dataDir)
+
+	newManager := func(testnet bool, magic uint32) *WalletManager {
+		wm := NewWalletManager()
+		wm.Config.DataDir = dataDir
+		wm.Config.IsTestNet = testnet
+		wm.Config.NetworkMagic = magic
+		wm.Config.makeDataDir()
+		return wm
+	}
+
+	//主网和测试网的数据在不同目录
+	mainnet := newManager(false, 0)
+	testnet := newManager(true, 0)
+	if mainnet.Config.DBPath != filepath.Join(dataDir, "neo", "7630401", "db") || testnet.Config.DBPath != filepath.Join(dataDir, "neo", "1953787457", "db") {
+		t.Fatalf("unexpected db path, mainnet: %s, testnet: %s", mainnet.Config.DBPath, testnet.Config.DBPath)
+	}
+	if err := mainnet.SaveLocalNewBlock(10, testHash("main")); err != nil {
+		t.Fatalf("SaveLocalNewBlock failed unexpected error: %v", err)
+	}
+	if height, _ := testnet.GetLocalNewBlock(); height != 0 {
+		t.Errorf("testnet should not read mainnet scan state, height: %d", height)
+	}
+	if network, _ := mainnet.GetLocalNetwork(); network == nil || network.Magic != NetworkMagicMainNet || network.Symbol != Symbol {
+		t.Errorf("unexpected local network: %+v", network)
+	}
+
+	//目录属于其他网络时拒绝打开
+	testnet.Config.DBPath = mainnet.Config.DBPath
+	err := testnet.SaveLocalNewBlock(1, testHash("test"))
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrLocalDBNetworkMismatch {
+		t.Errorf("open other network db should fail, err: %v", err)
+	}
+
+	//旧版未划分的目录未记录网络时继续使用，记录为当前网络
+	legacy := filepath.Join(dataDir, "neo", "db")
+	os.MkdirAll(legacy, os.ModePerm)
+	private := newManager(false, 56753)
+	if private.Config.DBPath != legacy {
+		t.Fatalf("unmarked legacy db should be reused, got: %s", private.Config.DBPath)
+	}
+	private.SaveLocalNewBlock(1, testHash("private"))
+	if other := newManager(false, 12345); other.Config.DBPath != filepath.Join(dataDir, "neo", "12345", "db") {
+		t.Errorf("legacy db of other network should not be reused, got: %s", other.Config.DBPath)
+	}
+}
@@ -19,11 +19,16 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
 	"github.com/imroc/req"
 	"github.com/tidwall/gjson"
 	"github.com/blocktree/openwallet/log"
 )
 
+//ClientInterface 节点客户端，可注入mock或其他实现
 type ClientInterface interface {
 	Call(path string, request []interface{}) (*gjson.Result, error)
 }
@@ -36,7 +41,15 @@ type Client struct {
 	AccessToken string
 	Debug       bool
 	client      *req.Req
+	endpoint    string //实际请求地址，unix套接字时为占位地址
 	//Client *req.Req
+
+	mu        sync.Mutex
+	rateLimit time.Duration //请求最小间隔
+	maxRetry  int           //请求失败重试次数
+	lastCall  time.Time     //最近一次请求时间
+	cache     *rpcCache     //不可变结果缓存
+	journal   *rpcJournal   //调用记录，nil不记录
 }
 
 type Response struct {
@@ -53,23 +66,115 @@ func NewClient(url, token string, debug bool) *Client {
 		BaseURL:     url,
 		AccessToken: token,
 		Debug:       debug,
+		endpoint:    url,
 	}
 
 	api := req.New()
 	//trans, _ := api.Client().Transport.(*http.Transport)
 	//trans.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	//本机节点通过unix域套接字访问
+	if socketPath, ok := parseUnixSocketURL(url); ok {
+		api.SetClient(newUnixSocketHTTPClient(socketPath))
+		c.endpoint = unixSocketEndpoint
+	}
 	c.client = api
 
 	return &c
 }
 
+//SetRateLimit 设置请求最小间隔和失败重试次数，重试时使用随机退避
+func (c *Client) SetRateLimit(interval time.Duration, maxRetry int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rateLimit = interval
+	c.maxRetry = maxRetry
+}
+
+//EnableCache 开启不可变请求结果的缓存
+func (c *Client) EnableCache(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = newRPCCache(size)
+}
+
+//setJournal 设置调用记录
+func (c *Client) setJournal(journal *rpcJournal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.journal = journal
+}
+
+//recordJournal 开启调用记录时记录一次请求，缓存命中的结果不是节点返回的，不记录
+func (c *Client) recordJournal(path string, request []interface{}, start time.Time, result *gjson.Result, err error) {
+	c.mu.Lock()
+	journal := c.journal
+	c.mu.Unlock()
+	if journal != nil {
+		journal.record(c.BaseURL, path, request, start, result, err)
+	}
+}
+
+//waitRateLimit 距离上一次请求不足最小间隔时等待
+func (c *Client) waitRateLimit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rateLimit <= 0 {
+		return
+	}
+	if wait := c.rateLimit - time.Since(c.lastCall); wait > 0 {
+		time.Sleep(wait)
+	}
+	c.lastCall = time.Now()
+}
+
 // Call calls a remote procedure on another node, specified by the path.
 func (c *Client) Call(path string, request []interface{}) (*gjson.Result, error) {
 
+	cacheKey, cacheable := rpcCacheKey(path, request)
+	if cacheable && c.cache != nil {
+		if result, ok := c.cache.get(cacheKey); ok {
+			return result, nil
+		}
+	}
+
 	var (
-		body = make(map[string]interface{}, 0)
+		result *gjson.Result
+		err    error
 	)
 
+	for i := 0; i <= c.maxRetry; i++ {
+		if i > 0 {
+			//随机退避
+			backoff := time.Duration(1<<uint(i-1)) * 500 * time.Millisecond
+			time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+		}
+
+		c.waitRateLimit()
+
+		var retryable bool
+		start := time.Now()
+		result, retryable, err = c.call(path, request)
+		c.recordJournal(path, request, start, result, err)
+		if err == nil || !retryable {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable && c.cache != nil && isFinalRPCResult(path, result) {
+		c.cache.set(cacheKey, result)
+	}
+
+	return result, nil
+}
+
+//BatchCall 批量调用同一个方法，按请求顺序返回结果
+func (c *Client) BatchCall(path string, requests [][]interface{}) ([]*gjson.Result, error) {
+
 	if c.client == nil {
 		return nil, errors.New("API url is not setup. ")
 	}
@@ -79,6 +184,74 @@ func (c *Client) Call(path string, request []interface{}) (*gjson.Result, error)
 		"Authorization": "Basic " + c.AccessToken,
 	}
 
+	body := make([]map[string]interface{}, 0)
+	for i, request := range requests {
+		body = append(body, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      i,
+			"method":  path,
+			"params":  request,
+		})
+	}
+
+	c.waitRateLimit()
+
+	start := time.Now()
+	r, err := c.client.Post(c.endpoint, req.BodyJSON(&body), authHeader)
+	if err != nil {
+		for _, request := range requests {
+			c.recordJournal(path, request, start, nil, err)
+		}
+		return nil, err
+	}
+
+	resp := gjson.ParseBytes(r.Bytes())
+	if !resp.IsArray() {
+		err = errors.New("Batch response is not array! ")
+		for _, request := range requests {
+			c.recordJournal(path, request, start, nil, err)
+		}
+		return nil, err
+	}
+
+	results := make([]*gjson.Result, len(requests))
+	for _, item := range resp.Array() {
+		id := item.Get("id").Int()
+		if id < 0 || int(id) >= len(requests) {
+			continue
+		}
+		if err := isError(&item); err != nil {
+			c.recordJournal(path, requests[id], start, nil, err)
+			continue
+		}
+		result := item.Get("result")
+		results[id] = &result
+		c.recordJournal(path, requests[id], start, &result, nil)
+
+		if cacheKey, cacheable := rpcCacheKey(path, requests[id]); cacheable && c.cache != nil && isFinalRPCResult(path, &result) {
+			c.cache.set(cacheKey, &result)
+		}
+	}
+
+	return results, nil
+}
+
+//call 发送一次json-rpc请求，网络错误时可重试，节点返回的错误不重试
+func (c *Client) call(path string, request []interface{}) (*gjson.Result, bool, error) {
+
+	var (
+		body = make(map[string]interface{}, 0)
+	)
+
+	if c.client == nil {
+		return nil, false, errors.New("API url is not setup. ")
+	}
+
+	authHeader := req.Header{
+		"Accept":        "application/json",
+		"Authorization": "Basic " + c.AccessToken,
+	}
+
 	//json-rpc
 	body["jsonrpc"] = "2.0"
 	body["id"] = "1"
@@ -89,7 +262,7 @@ func (c *Client) Call(path string, request []interface{}) (*gjson.Result, error)
 		log.Std.Info("Start Request API...")
 	}
 
-	r, err := c.client.Post(c.BaseURL, req.BodyJSON(&body), authHeader)
+	r, err := c.client.Post(c.endpoint, req.BodyJSON(&body), authHeader)
 
 	if c.Debug {
 		log.Std.Info("Request API Completed")
@@ -100,18 +273,18 @@ func (c *Client) Call(path string, request []interface{}) (*gjson.Result, error)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, true, err
 	}
 
 	resp := gjson.ParseBytes(r.Bytes())
 	err = isError(&resp)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	result := resp.Get("result")
 
-	return &result, nil
+	return &result, false, nil
 }
 
 // See 2 (end of page 4) http://www.ietf.org/rfc/rfc2617.txt
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+)
+
+//getBlockHeightByClient 通过指定节点获取区块链高度，offset为区块数量与最高区块索引的偏移
+func getBlockHeightByClient(client ClientInterface, offset uint64) (uint64, error) {
+
+	result, err := client.Call("getblockcount", []interface{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	if result.Uint() < offset {
+		return 0, nil
+	}
+
+	return result.Uint() - offset, nil
+}
+
+//getBlockHashByClient 通过指定节点获取区块hash
+func getBlockHashByClient(client ClientInterface, height uint64) (string, error) {
+
+	result, err := client.Call("getblockhash", []interface{}{height})
+	if err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
+
+//CheckNodeDivergence 比较所有节点在相同高度的区块hash，超过确认数仍不一致时返回告警
+//检查高度为所有节点最低高度减去确认数，没有分歧返回nil
+func (wm *WalletManager) CheckNodeDivergence() (*Alert, error) {
+
+	clients := make([]ClientInterface, 0)
+	if client := wm.nodeClient(); client != nil {
+		clients = append(clients, client)
+	}
+	clients = append(clients, wm.backupClients()...)
+
+	if len(clients) < 2 {
+		return nil, nil
+	}
+
+	var minHeight uint64 = 0
+	for i, c := range clients {
+		height, err := getBlockHeightByClient(c, wm.HeightOffset())
+		if err != nil {
+			return nil, fmt.Errorf("node: %s can not get block height, unexpected error: %v", clientName(c, i), err)
+		}
+		if i == 0 || height < minHeight {
+			minHeight = height
+		}
+	}
+
+	if minHeight <= wm.config().ConfirmBlocks {
+		return nil, nil
+	}
+
+	checkHeight := minHeight - wm.config().ConfirmBlocks
+	hashes := make(map[string]string)
+	diverged := false
+	firstHash := ""
+	for i, c := range clients {
+		hash, err := getBlockHashByClient(c, checkHeight)
+		if err != nil {
+			return nil, fmt.Errorf("node: %s can not get block hash, unexpected error: %v", clientName(c, i), err)
+		}
+		hashes[clientName(c, i)] = hash
+		if i == 0 {
+			firstHash = hash
+		} else if hash != firstHash {
+			diverged = true
+		}
+	}
+
+	if !diverged {
+		return nil, nil
+	}
+
+	alert := NewAlert(wm.Symbol(), AlertTypeNodeDivergence, checkHeight,
+		fmt.Sprintf("nodes best block hash diverge beyond %d confirmations", wm.config().ConfirmBlocks))
+	for url, hash := range hashes {
+		alert.Details[url] = hash
+	}
+
+	return alert, nil
+}
+
+//checkNodeDivergence 按配置间隔检查多节点分歧，发现分歧时通知告警观测者
+func (bs *NEOBlockScanner) checkNodeDivergence() {
+
+	if len(bs.wm.backupClients()) == 0 {
+		return
+	}
+
+	interval := bs.wm.config().NodeDivergenceCheckInterval
+	if time.Since(bs.lastDivergenceCheck) < interval {
+		return
+	}
+	bs.lastDivergenceCheck = time.Now()
+
+	alert, err := bs.wm.CheckNodeDivergence()
+	if err != nil {
+		bs.wm.Log.Std.Info("block scanner can not check node divergence; unexpected error: %v", err)
+		return
+	}
+
+	if alert != nil {
+		bs.newAlertNotify(alert)
+	}
+}
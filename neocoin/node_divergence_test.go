@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/openwallet/crypto"
+)
+
+//newTestRPCServer 模拟节点的json-rpc服务
+func newTestRPCServer(handler func(method string, params []interface{}) interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      "1",
+			"result":  handler(body.Method, body.Params),
+		})
+	}))
+}
+
+//testHash 由标记生成格式合法的区块hash或交易ID
+func testHash(label string) string {
+	return "0x" + hex.EncodeToString(crypto.SHA256([]byte(label)))
+}
+
+func testChainHandler(tip uint64, prefix string) func(method string, params []interface{}) interface{} {
+	return func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return tip + 1
+		case "getblockhash":
+			return fmt.Sprintf("%s%v", prefix, params[0])
+		}
+		return nil
+	}
+}
+
+func TestWalletManager_CheckNodeDivergence(t *testing.T) {
+	primary := newTestRPCServer(testChainHandler(100, "0x"))
+	defer primary.Close()
+	same := newTestRPCServer(testChainHandler(98, "0x"))
+	defer same.Close()
+	fork := newTestRPCServer(testChainHandler(100, "0xfork"))
+	defer fork.Close()
+
+	wm := NewWalletManager()
+	wm.Config.ConfirmBlocks = 6
+	wm.WalletClient = NewClient(primary.URL, "", false)
+	wm.BackupClients = []*Client{NewClient(same.URL, "", false)}
+
+	alert, err := wm.CheckNodeDivergence()
+	if err != nil {
+		t.Errorf("CheckNodeDivergence failed unexpected error: %v\n", err)
+		return
+	}
+	if alert != nil {
+		t.Errorf("nodes should not diverge")
+	}
+
+	wm.BackupClients = append(wm.BackupClients, NewClient(fork.URL, "", false))
+	alert, err = wm.CheckNodeDivergence()
+	if err != nil {
+		t.Errorf("CheckNodeDivergence failed unexpected error: %v\n", err)
+		return
+	}
+	if alert == nil || alert.Height != 92 {
+		t.Errorf("nodes should diverge on height 92, alert: %v", alert)
+	}
+}
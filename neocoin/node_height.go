@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+const (
+	NodeFlavorNeoCli      = "neo-cli"       //官方neo-cli节点，getblockcount返回包含创世区块的区块数量
+	NodeFlavorNeoPython   = "neo-python"    //neo-python节点，getblockcount返回Height+1
+	NodeFlavorNeoGo       = "neo-go"        //neo-go 0.70及之后的节点，getblockcount返回BlockHeight+1
+	NodeFlavorNeoGoLegacy = "neo-go-legacy" //neo-go 0.70之前的节点，getblockcount直接返回BlockHeight
+)
+
+//nodeFlavorHeightOffsets 各节点实现getblockcount的返回值与最高区块索引之差
+var nodeFlavorHeightOffsets = map[string]uint64{
+	NodeFlavorNeoCli:      1,
+	NodeFlavorNeoPython:   1,
+	NodeFlavorNeoGo:       1,
+	NodeFlavorNeoGoLegacy: 0,
+}
+
+//HeightOffset 节点报告的高度值与可扫描最高区块索引之差
+//配置BlockCountOffset时以配置为准，否则按服务类型和节点实现取值
+func (wm *WalletManager) HeightOffset() uint64 {
+
+	if wm.config().BlockCountOffset >= 0 {
+		return uint64(wm.config().BlockCountOffset)
+	}
+
+	//浏览器接口返回的是最高区块索引
+	if wm.config().RPCServerType == RPCServerExplorer {
+		return 0
+	}
+
+	if offset, ok := nodeFlavorHeightOffsets[wm.config().NodeFlavor]; ok {
+		return offset
+	}
+
+	return 1
+}
+
+//NodeTipHeight 节点报告的原始高度值，neo-cli等节点为包含创世区块的区块数量
+func (wm *WalletManager) NodeTipHeight() (uint64, error) {
+
+	if wm.config().RPCServerType == RPCServerExplorer {
+		return wm.getBlockHeightByExplorer()
+	} else {
+		return wm.getBlockCountByCore()
+	}
+}
+
+//ScannableTipHeight 可通过getblockhash获取的最高区块索引，扫描器以此为最大高度
+func (wm *WalletManager) ScannableTipHeight() (uint64, error) {
+
+	tip, err := wm.NodeTipHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	offset := wm.HeightOffset()
+	if tip < offset {
+		return 0, nil
+	}
+
+	return tip - offset, nil
+}
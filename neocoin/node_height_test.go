@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestWalletManager_ScannableTipHeight(t *testing.T) {
+	server := newTestRPCServer(testChainHandler(100, "0x"))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	tests := []struct {
+		flavor    string
+		offset    int64
+		node      uint64
+		scannable uint64
+	}{
+		{NodeFlavorNeoCli, -1, 101, 100},
+		{NodeFlavorNeoGo, -1, 101, 100},
+		{"unknown", -1, 101, 100},
+		{NodeFlavorNeoCli, 0, 101, 101},
+		{NodeFlavorNeoCli, 2, 101, 99},
+	}
+
+	for i, test := range tests {
+		wm.Config.NodeFlavor = test.flavor
+		wm.Config.BlockCountOffset = test.offset
+
+		node, err := wm.NodeTipHeight()
+		if err != nil || node != test.node {
+			t.Errorf("case %d NodeTipHeight: %d, unexpected error: %v", i, node, err)
+		}
+
+		scannable, err := wm.ScannableTipHeight()
+		if err != nil || scannable != test.scannable {
+			t.Errorf("case %d ScannableTipHeight: %d, expected: %d, unexpected error: %v", i, scannable, test.scannable, err)
+		}
+	}
+
+	height, _ := wm.GetBlockHeight()
+	if height != 99 {
+		t.Errorf("GetBlockHeight should equal ScannableTipHeight, height: %d", height)
+	}
+}
+
+func TestWalletManager_NodeFlavorHeightOffset(t *testing.T) {
+	server := newTestRPCServer(testChainHandler(100, "0x"))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.Config.BlockCountOffset = -1
+
+	tests := []struct {
+		flavor string
+		offset uint64
+		height uint64
+	}{
+		{NodeFlavorNeoCli, 1, 100},
+		{NodeFlavorNeoPython, 1, 100},
+		{NodeFlavorNeoGo, 1, 100},
+		{NodeFlavorNeoGoLegacy, 0, 101},
+	}
+
+	for _, test := range tests {
+		wm.Config.NodeFlavor = test.flavor
+		if offset := wm.HeightOffset(); offset != test.offset {
+			t.Errorf("flavor: %s offset: %d, expected: %d", test.flavor, offset, test.offset)
+		}
+		height, err := wm.getBlockHeightByCore()
+		if err != nil || height != test.height {
+			t.Errorf("flavor: %s height: %d, expected: %d, unexpected error: %v", test.flavor, height, test.height, err)
+		}
+	}
+
+	//浏览器返回的是最高区块索引
+	wm.Config.RPCServerType = RPCServerExplorer
+	if offset := wm.HeightOffset(); offset != 0 {
+		t.Errorf("explorer offset: %d, expected: 0", offset)
+	}
+}
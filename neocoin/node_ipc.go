@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	//UnixSocketScheme 通过unix域套接字访问本机节点，例如：unix:///var/run/neo/rpc.sock
+	//windows 10以上同样支持AF_UNIX套接字，命名管道暂不支持
+	UnixSocketScheme = "unix://"
+
+	//unixSocketEndpoint 套接字请求使用的占位地址，Host不参与连接
+	unixSocketEndpoint = "http://unix/"
+)
+
+//parseUnixSocketURL 解析unix://开头的节点地址，返回套接字文件路径
+func parseUnixSocketURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, UnixSocketScheme) {
+		return "", false
+	}
+	path := strings.TrimPrefix(url, UnixSocketScheme)
+	if len(path) == 0 {
+		return "", false
+	}
+	return path, true
+}
+
+//newUnixSocketHTTPClient 创建通过unix域套接字发送请求的http客户端
+func newUnixSocketHTTPClient(socketPath string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+		MaxIdleConns:    10,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	return &http.Client{Transport: transport}
+}
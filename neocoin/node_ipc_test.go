@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_UnixSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "neo-ipc")
+	defer os.RemoveAll(dir)
+
+	server := newTestRPCServer(testChainHandler(100, "0x"))
+	defer server.Close()
+
+	socketPath := filepath.Join(dir, "rpc.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix domain socket is not supported: %v", err)
+	}
+	defer l.Close()
+	go http.Serve(l, server.Config.Handler)
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(UnixSocketScheme+socketPath, "", false)
+
+	height, err := wm.getBlockCountByCore()
+	if err != nil || height != 101 {
+		t.Errorf("get block count over unix socket failed, height: %d, unexpected error: %v", height, err)
+	}
+
+	if _, ok := parseUnixSocketURL("http://127.0.0.1:10332"); ok {
+		t.Errorf("http url should not be parsed as unix socket")
+	}
+}
@@ -0,0 +1,276 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//readRouteEWMAAlpha 节点延迟指数加权移动平均的平滑系数
+	readRouteEWMAAlpha = 0.2
+	//readRouteErrorPenalty 请求失败时按该延迟计入平均值，降低失败节点分配到的比例
+	readRouteErrorPenalty = 5 * time.Second
+)
+
+//routedReadMethods 可以分配到任意节点的只读RPC方法，其他方法只发送到主节点
+var routedReadMethods = map[string]bool{
+	"getblockcount":     true,
+	"getbestblockhash":  true,
+	"getblockhash":      true,
+	"getblock":          true,
+	"getblockheader":    true,
+	"getrawtransaction": true,
+	"getrawmempool":     true,
+	"gettxout":          true,
+}
+
+//pinnedReadMethods 与扫描高度相关的读请求，固定期间发送到同一节点，保证一次扫描迭代读到同一条链
+var pinnedReadMethods = map[string]bool{
+	"getblockcount":     true,
+	"getbestblockhash":  true,
+	"getblockhash":      true,
+	"getblock":          true,
+	"getblockheader":    true,
+	"getrawtransaction": true,
+}
+
+//NodeRouteStats 节点的读请求路由统计
+type NodeRouteStats struct {
+	Node      string
+	Weight    int
+	Latency   time.Duration //请求延迟的指数加权移动平均
+	Calls     uint64
+	Failures  uint64
+	LastError string
+	Pinned    bool //是否为当前扫描迭代固定的节点
+}
+
+//routeNode 参与路由的节点
+type routeNode struct {
+	name   string
+	client ClientInterface
+	weight int
+}
+
+//nodeRouter 按权重和延迟分配读请求，扫描迭代期间固定高度相关的读请求到同一节点
+type nodeRouter struct {
+	mu     sync.Mutex
+	stats  map[string]*NodeRouteStats
+	pinned string //固定的节点，空表示固定后还未选择
+	pins   int    //固定的引用数量
+	rand   *rand.Rand
+}
+
+func newNodeRouter() *nodeRouter {
+	return &nodeRouter{
+		stats: make(map[string]*NodeRouteStats),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+//choose 选择节点，固定期间高度相关的请求沿用第一次选择的节点，返回请求是否被固定，
+//其他请求按权重除以平均延迟的比例随机分配，未请求过的节点按1毫秒计算
+func (r *nodeRouter) choose(nodes []*routeNode, critical bool) (*routeNode, bool) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pinned := critical && r.pins > 0
+	if pinned && len(r.pinned) > 0 {
+		for _, n := range nodes {
+			if n.name == r.pinned {
+				return n, true
+			}
+		}
+	}
+
+	var (
+		total  float64
+		scores = make([]float64, len(nodes))
+	)
+	for i, n := range nodes {
+		if n.weight <= 0 {
+			continue
+		}
+		latency := time.Millisecond
+		if s, ok := r.stats[n.name]; ok && s.Latency > latency {
+			latency = s.Latency
+		}
+		scores[i] = float64(n.weight) / latency.Seconds()
+		total += scores[i]
+	}
+
+	chosen := nodes[0]
+	if total > 0 {
+		x := r.rand.Float64() * total
+		for i, n := range nodes {
+			if scores[i] <= 0 {
+				continue
+			}
+			chosen = n
+			if x < scores[i] {
+				break
+			}
+			x -= scores[i]
+		}
+	}
+
+	if pinned {
+		r.pinned = chosen.name
+	}
+
+	return chosen, pinned
+}
+
+//record 累计请求延迟，失败时按readRouteErrorPenalty计入
+func (r *nodeRouter) record(node *routeNode, elapsed time.Duration, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.stats[node.name]
+	if !ok {
+		s = &NodeRouteStats{Node: node.name, Latency: elapsed}
+		r.stats[node.name] = s
+	}
+	s.Weight = node.weight
+	s.Calls++
+	if err != nil {
+		s.Failures++
+		s.LastError = err.Error()
+		elapsed = readRouteErrorPenalty
+	}
+	s.Latency = time.Duration(readRouteEWMAAlpha*float64(elapsed) + (1-readRouteEWMAAlpha)*float64(s.Latency))
+}
+
+//pin 开始固定，返回结束固定的函数，嵌套固定时沿用外层选择的节点
+func (r *nodeRouter) pin() func() {
+
+	r.mu.Lock()
+	r.pins++
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			r.pins--
+			if r.pins == 0 {
+				r.pinned = ""
+			}
+			r.mu.Unlock()
+		})
+	}
+}
+
+//routedClient 按路由分配只读请求的节点客户端
+type routedClient struct {
+	wm *WalletManager
+}
+
+func (c *routedClient) Call(path string, request []interface{}) (*gjson.Result, error) {
+
+	nodes := c.wm.routeNodes()
+	if !routedReadMethods[path] || len(nodes) == 0 {
+		return c.wm.nodeClient().Call(path, request)
+	}
+
+	node, pinned := c.wm.readRouter.choose(nodes, pinnedReadMethods[path])
+
+	start := time.Now()
+	result, err := node.client.Call(path, request)
+	c.wm.readRouter.record(node, time.Since(start), err)
+
+	//未固定的请求在备用节点失败时由主节点重试，固定的请求不换节点，由扫描按失败处理
+	if err != nil && !pinned && node != nodes[0] {
+		c.wm.Log.Std.Info("routed read: %s on node: %s failed, retry on primary node, unexpected error: %v", path, node.name, err)
+		start = time.Now()
+		result, err = nodes[0].client.Call(path, request)
+		c.wm.readRouter.record(nodes[0], time.Since(start), err)
+	}
+
+	return result, err
+}
+
+//routeNodes 参与读请求路由的节点，主节点在前，权重按serverAPI、backupServerAPI的顺序取nodeWeights，未配置的为1
+func (wm *WalletManager) routeNodes() []*routeNode {
+
+	wm.injectMu.RLock()
+	primary := wm.WalletClient
+	injected := wm.injected.client != nil
+	wm.injectMu.RUnlock()
+
+	if injected || primary == nil {
+		return nil
+	}
+
+	weights := wm.config().NodeWeights
+	weight := func(i int) int {
+		if i < len(weights) {
+			return weights[i]
+		}
+		return 1
+	}
+
+	nodes := []*routeNode{{name: clientName(primary, 0), client: primary, weight: weight(0)}}
+	for i, c := range wm.backupClients() {
+		nodes = append(nodes, &routeNode{name: clientName(c, i+1), client: c, weight: weight(i + 1)})
+	}
+
+	return nodes
+}
+
+//readClient 只读请求使用的节点客户端，开启readRouting且配置了备用节点时按权重和延迟分配到各节点，
+//注入了节点客户端时只使用注入的客户端
+func (wm *WalletManager) readClient() ClientInterface {
+
+	cfg := wm.config()
+	if !cfg.ReadRouting || cfg.RPCServerType == RPCServerExplorer || len(wm.routeNodes()) < 2 {
+		return wm.nodeClient()
+	}
+
+	return &routedClient{wm: wm}
+}
+
+//pinReadNode 固定高度相关的读请求到同一节点，直到调用返回的函数，用于一次扫描迭代
+func (wm *WalletManager) pinReadNode() func() {
+	return wm.readRouter.pin()
+}
+
+//ReadRouteStatus 各节点的读请求路由统计，按节点名称排序
+func (wm *WalletManager) ReadRouteStatus() []*NodeRouteStats {
+
+	wm.readRouter.mu.Lock()
+	defer wm.readRouter.mu.Unlock()
+
+	list := make([]*NodeRouteStats, 0, len(wm.readRouter.stats))
+	for _, s := range wm.readRouter.stats {
+		copied := *s
+		copied.Pinned = wm.readRouter.pins > 0 && s.Node == wm.readRouter.pinned
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Node < list[j].Node
+	})
+
+	return list
+}
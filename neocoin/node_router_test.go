@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWalletManager_ReadRouting(t *testing.T) {
+	var primaryCalls, backupCalls int64
+	counting := func(calls *int64) func(method string, params []interface{}) interface{} {
+		return func(method string, params []interface{}) interface{} {
+			atomic.AddInt64(calls, 1)
+			if method == "getblockhash" {
+				return testHash(fmt.Sprintf("block%v", params[0]))
+			}
+			return nil
+		}
+	}
+	primary := newTestRPCServer(counting(&primaryCalls))
+	defer primary.Close()
+	backup := newTestRPCServer(counting(&backupCalls))
+	defer backup.Close()
+	down := newTestRPCServer(nil)
+	down.Close()
+
+	reset := func() {
+		atomic.StoreInt64(&primaryCalls, 0)
+		atomic.StoreInt64(&backupCalls, 0)
+	}
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(primary.URL, "", false)
+	wm.BackupClients = []*Client{NewClient(backup.URL, "", false)}
+	wm.Config.BackupServerAPI = []string{backup.URL}
+
+	//未开启时只使用主节点
+	wm.GetBlockHash(1)
+	if primaryCalls != 1 || backupCalls != 0 {
+		t.Errorf("read routing disabled should use primary node, primary: %d, backup: %d", primaryCalls, backupCalls)
+	}
+
+	//按权重分配读请求
+	wm.Config.ReadRouting = true
+	for _, c := range []struct {
+		weights         []int
+		primary, backup bool
+	}{
+		{[]int{1, 1}, true, true},
+		{[]int{1, 0}, true, false},
+		{[]int{0, 1}, false, true},
+	} {
+		wm.Config.NodeWeights = c.weights
+		reset()
+		for i := 0; i < 40; i++ {
+			if _, err := wm.GetBlockHash(uint64(i)); err != nil {
+				t.Fatalf("GetBlockHash failed, unexpected error: %v", err)
+			}
+		}
+		if (primaryCalls > 0) != c.primary || (backupCalls > 0) != c.backup {
+			t.Errorf("weights: %v, primary: %d, backup: %d", c.weights, primaryCalls, backupCalls)
+		}
+	}
+
+	//固定期间高度相关的请求都发送到同一节点
+	wm.Config.NodeWeights = nil
+	reset()
+	unpin := wm.pinReadNode()
+	for i := 0; i < 40; i++ {
+		wm.GetBlockHash(uint64(i))
+	}
+	status := wm.ReadRouteStatus()
+	unpin()
+	if primaryCalls > 0 && backupCalls > 0 {
+		t.Errorf("pinned reads should use one node, primary: %d, backup: %d", primaryCalls, backupCalls)
+	}
+	pinned := 0
+	for _, s := range status {
+		if s.Pinned {
+			pinned++
+		}
+	}
+	if len(status) != 2 || pinned != 1 {
+		t.Errorf("unexpected route status: %+v", status)
+	}
+
+	//非只读方法只发送到主节点
+	reset()
+	for i := 0; i < 10; i++ {
+		wm.readClient().Call("getwalletinfo", nil)
+	}
+	if primaryCalls != 10 || backupCalls != 0 {
+		t.Errorf("non read method should use primary node, primary: %d, backup: %d", primaryCalls, backupCalls)
+	}
+
+	//备用节点不可用时由主节点重试
+	wm.BackupClients = []*Client{NewClient(down.URL, "", false)}
+	wm.Config.NodeWeights = []int{0, 1}
+	reset()
+	if hash, err := wm.GetBlockHash(7); err != nil || hash != testHash("block7") || primaryCalls != 1 {
+		t.Errorf("failed routed read should retry on primary, hash: %s, err: %v", hash, err)
+	}
+	for _, s := range wm.ReadRouteStatus() {
+		if strings.HasPrefix(s.Node, down.URL) && (s.Failures != 1 || s.Latency < readRouteErrorPenalty/10) {
+			t.Errorf("failed node should be penalized, got: %+v", s)
+		}
+	}
+
+	//注入节点客户端时不路由
+	wm.SetNodeClient(NewClient(primary.URL, "", false))
+	if _, ok := wm.readClient().(*routedClient); ok {
+		t.Errorf("injected client should disable read routing")
+	}
+}
+
+func TestWalletConfig_ValidateReadRouting(t *testing.T) {
+	cfg := NewConfig(Symbol, CurveType, Decimals)
+	cfg.ReadRouting = true
+	cfg.NodeWeights = []int{1, -1}
+
+	problems := strings.Join(cfg.Validate(), "\n")
+	for _, key := range []string{"readRouting requires backupServerAPI", "nodeWeights: 2 weights", "should be non-negative"} {
+		if !strings.Contains(problems, key) {
+			t.Errorf("problem: %q should be reported, got: %s", key, problems)
+		}
+	}
+}
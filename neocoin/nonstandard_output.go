@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+)
+
+const (
+	NonstandardOutputSkip   = 0 //无地址的输出直接跳过
+	NonstandardOutputRecord = 1 //无地址的输出单独记录，保留原始脚本
+)
+
+//NonstandardOutput 没有地址的交易输出，如null data、未知合约脚本
+type NonstandardOutput struct {
+	ID           string `storm:"id"`
+	TxID         string `storm:"index"`
+	N            uint64
+	BlockHeight  uint64
+	BlockHash    string
+	Value        string
+	Asset        string
+	Type         string
+	ScriptPubKey string //原始脚本hex
+	CreateAt     int64
+}
+
+func NewNonstandardOutput(trx *Transaction, output *Vout) *NonstandardOutput {
+	obj := NonstandardOutput{}
+	obj.TxID = trx.TxID
+	obj.N = output.N
+	obj.BlockHeight = trx.BlockHeight
+	obj.BlockHash = trx.BlockHash
+	obj.Value = output.Value
+	obj.Asset = output.Asset
+	obj.Type = output.Type
+	obj.ScriptPubKey = output.ScriptPubKey
+	obj.CreateAt = time.Now().Unix()
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%s_%d", obj.TxID, obj.N))))
+	return &obj
+}
+
+//handleNonstandardOutput 按配置策略处理无地址的输出
+func (bs *NEOBlockScanner) handleNonstandardOutput(trx *Transaction, output *Vout) {
+
+	if bs.wm.config().NonstandardOutputPolicy != NonstandardOutputRecord {
+		return
+	}
+
+	err := bs.wm.SaveNonstandardOutput(NewNonstandardOutput(trx, output))
+	if err != nil {
+		bs.wm.Log.Std.Error("txid: %s, n: %d save nonstandard output failed. unexpected error: %v", trx.TxID, output.N, err)
+	}
+}
+
+//SaveNonstandardOutput 保存无地址的输出
+func (wm *WalletManager) SaveNonstandardOutput(output *NonstandardOutput) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(output)
+}
+
+//GetNonstandardOutputs 查询已记录的无地址输出，txid为空时返回全部
+func (wm *WalletManager) GetNonstandardOutputs(txid string) ([]*NonstandardOutput, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*NonstandardOutput
+	if len(txid) > 0 {
+		err = db.Find("TxID", txid, &list)
+	} else {
+		err = db.All(&list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//NotarizeParam 存证交易单记录的数据hex，ExtParam键名
+	NotarizeParam = "notarizeData"
+
+	remarkAttrUsage = 0xf0 //Remark附加属性的使用类型
+)
+
+//NotarizeData 创建存证交易单，把数据写入Remark附加属性上链，
+//交易单花费账户最小的一个未花输出并原额发回同一地址，不转移价值也不支付手续费，签名广播后交易ID即存证凭据
+func (decoder *TransactionDecoder) NotarizeData(wrapper openwallet.WalletDAI, account *openwallet.AssetsAccount, payload []byte) (*openwallet.RawTransaction, error) {
+
+	if account == nil {
+		return nil, fmt.Errorf("account is nil")
+	}
+
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("notarize data is empty")
+	}
+
+	unspents, err := decoder.listNEOUnspents(wrapper, account.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	//选取数额最小的一个未花输出，只需要一个见证人
+	var (
+		used  *UnspentBalance
+		input UnspentTx
+		value decimal.Decimal
+	)
+	for _, u := range unspents {
+		if u.NEOUnspent == nil || u.NEOUnspent.UnspentTxs == nil {
+			continue
+		}
+		for _, tx := range *u.NEOUnspent.UnspentTxs {
+			v, _ := decimal.NewFromString(tx.Value)
+			if !v.GreaterThan(decimal.Zero) {
+				continue
+			}
+			if used == nil || v.LessThan(value) {
+				used, input, value = u, tx, v
+			}
+		}
+	}
+	if used == nil {
+		return nil, decoder.wm.errorf(openwallet.ErrInsufficientBalanceOfAccount, "[%s] has no unspent to notarize data", account.AccountID)
+	}
+
+	//检查加上附加属性后的交易单大小
+	maxSize := decoder.wm.config().MaxTxSize
+	if maxSize <= 0 {
+		maxSize = MaxTransactionSize
+	}
+	size := EstimateTxSize(1, 1, []WitnessSigner{decoder.witnessSigner(wrapper, used.Address)}) + 1 + varIntSize(len(payload)) + len(payload)
+	if size > maxSize {
+		return nil, decoder.wm.errorf(ErrTransactionTooLarge, "notarize data size: %d exceeds transaction size limit: %d", len(payload), maxSize)
+	}
+
+	part := cloneUnspentBalance(used)
+	*part.NEOUnspent.UnspentTxs = append(*part.NEOUnspent.UnspentTxs, input)
+	part.NEOUnspent.Amount = input.Value
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:     openwallet.Coin{Symbol: decoder.wm.Symbol()},
+		Account:  account,
+		To:       map[string]string{used.Address: value.StringFixed(decoder.wm.Decimal())},
+		FeeRate:  decoder.wm.FormatGAS(decimal.Zero),
+		Fees:     decoder.wm.FormatGAS(decimal.Zero),
+		Required: 1,
+	}
+
+	attrs := []neoTransaction.Attribute{
+		{Attr: neoTransaction.AttrRemark, Data: hex.EncodeToString(payload)},
+	}
+	outputAddrs := appendOutput(make(map[string]decimal.Decimal), used.Address, value)
+	if err := decoder.createNEORawTransactionWithAttrs(wrapper, rawTx, []*UnspentBalance{part}, outputAddrs, attrs); err != nil {
+		return nil, err
+	}
+	rawTx.SetExtParam(NotarizeParam, hex.EncodeToString(payload))
+
+	//记录审计日志
+	decoder.wm.auditTransaction(AuditActionCreate, account.AccountID, "", rawTx.RawHex, rawTx.ExtParam)
+
+	return rawTx, nil
+}
+
+//GetNotarizedData 获取交易单Remark附加属性记录的数据，用于核对存证
+func (wm *WalletManager) GetNotarizedData(txid string) ([]byte, error) {
+
+	tx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Attributes != nil {
+		for _, attr := range *tx.Attributes {
+			if attr.Usage == remarkAttrUsage {
+				return hex.DecodeString(attr.Data)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("transaction: %s has no remark data", txid)
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestTransactionDecoder_NotarizeData(t *testing.T) {
+	balances := map[string]int{testHotAddress: 100, testColdAddress1: 500, testColdAddress2: 50}
+	payload := []byte("sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getunspents":
+			address := params[0].(string)
+			return map[string]interface{}{
+				"address": address,
+				"balance": []interface{}{
+					map[string]interface{}{
+						"unspent": []interface{}{
+							map[string]interface{}{"txid": testHash(address)[2:], "n": 0, "value": balances[address]},
+							map[string]interface{}{"txid": testHash(address + "1")[2:], "n": 1, "value": balances[address] * 2},
+						},
+						"asset_hash":   "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+						"asset":        "NEO",
+						"asset_symbol": "NEO",
+						"amount":       balances[address] * 3,
+					},
+				},
+			}
+		case "getrawtransaction":
+			return map[string]interface{}{
+				"txid":       params[0],
+				"attributes": []interface{}{map[string]interface{}{"usage": 240, "data": hex.EncodeToString(payload)}},
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	decoder := NewTransactionDecoder(wm)
+	account := &openwallet.AssetsAccount{AccountID: "claim", Symbol: Symbol}
+
+	//花费最小的一个未花输出，原额发回同一地址
+	rawTx, err := decoder.NotarizeData(&testClaimWallet{}, account, payload)
+	if err != nil {
+		t.Fatalf("NotarizeData failed unexpected error: %v", err)
+	}
+	if len(rawTx.TxFrom) != 1 || rawTx.TxFrom[0] != testColdAddress2+":50" || rawTx.TxTo[0] != testColdAddress2+":50" {
+		t.Errorf("unexpected notarize transaction, from: %v, to: %v", rawTx.TxFrom, rawTx.TxTo)
+	}
+	if rawTx.TxAmount != "0.00000000" || len(rawTx.Signatures["claim"]) != 1 {
+		t.Errorf("unexpected notarize amount: %s, signatures: %d", rawTx.TxAmount, len(rawTx.Signatures["claim"]))
+	}
+
+	txBytes, _ := hex.DecodeString(rawTx.RawHex)
+	tx, err := neoTransaction.DecodeRawTransaction(txBytes)
+	if err != nil || len(tx.Attributes) != 1 || string(tx.Attributes[0].Data()) != string(payload) {
+		t.Errorf("raw transaction should carry remark data, err: %v", err)
+	}
+
+	//核对链上的存证数据
+	data, err := wm.GetNotarizedData(testHash("notarize"))
+	if err != nil || string(data) != string(payload) {
+		t.Errorf("GetNotarizedData = %s, %v", data, err)
+	}
+
+	//空数据和超出大小上限
+	if _, err := decoder.NotarizeData(&testClaimWallet{}, account, nil); err == nil {
+		t.Errorf("empty payload should fail")
+	}
+	wm.Config.MaxTxSize = 300
+	_, err = decoder.NotarizeData(&testClaimWallet{}, account, []byte(strings.Repeat("a", 200)))
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrTransactionTooLarge {
+		t.Errorf("oversized payload should fail, err: %v", err)
+	}
+}
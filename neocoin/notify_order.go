@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sort"
+)
+
+//extractSequencer 缓存已完成的提取结果，按交易在区块中的序号依次释放，保证通知顺序
+type extractSequencer struct {
+	next    int
+	pending map[int]ExtractResult
+}
+
+//newExtractSequencer 创建提取结果排序器
+func newExtractSequencer() *extractSequencer {
+	return &extractSequencer{
+		pending: make(map[int]ExtractResult),
+	}
+}
+
+//push 加入一个完成的提取结果，返回可以按顺序通知的结果
+func (s *extractSequencer) push(result ExtractResult) []ExtractResult {
+
+	s.pending[result.index] = result
+
+	ready := make([]ExtractResult, 0)
+	for {
+		r, ok := s.pending[s.next]
+		if !ok {
+			break
+		}
+		ready = append(ready, r)
+		delete(s.pending, s.next)
+		s.next++
+	}
+
+	return ready
+}
+
+//sortedHeights 区块高度升序排列，严格顺序通知时重扫失败记录使用
+func sortedHeights(blockMap map[uint64][]string) []uint64 {
+	heights := make([]uint64, 0, len(blockMap))
+	for height := range blockMap {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] < heights[j]
+	})
+	return heights
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestExtractSequencer_Push(t *testing.T) {
+	sequencer := newExtractSequencer()
+
+	//后面的交易先完成，需缓存
+	if ready := sequencer.push(ExtractResult{TxID: "c", index: 2}); len(ready) != 0 {
+		t.Errorf("index 2 should be buffered, got: %v", ready)
+	}
+	if ready := sequencer.push(ExtractResult{TxID: "b", index: 1}); len(ready) != 0 {
+		t.Errorf("index 1 should be buffered, got: %v", ready)
+	}
+
+	//第一笔完成后按顺序全部释放
+	ready := sequencer.push(ExtractResult{TxID: "a", index: 0})
+	if len(ready) != 3 || ready[0].TxID != "a" || ready[1].TxID != "b" || ready[2].TxID != "c" {
+		t.Errorf("unexpected release order: %v", ready)
+	}
+
+	if ready := sequencer.push(ExtractResult{TxID: "d", index: 3}); len(ready) != 1 || ready[0].TxID != "d" {
+		t.Errorf("index 3 should be released immediately, got: %v", ready)
+	}
+}
+
+func TestSortedHeights(t *testing.T) {
+	blockMap := map[uint64][]string{
+		30: nil,
+		10: nil,
+		20: nil,
+	}
+	heights := sortedHeights(blockMap)
+	if len(heights) != 3 || heights[0] != 10 || heights[1] != 20 || heights[2] != 30 {
+		t.Errorf("unexpected heights: %v", heights)
+	}
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/blocktree/openwallet/timer"
+)
+
+const (
+	NotifyDeliveryPending     = "pending"     //待投递
+	NotifyDeliveryDelivered   = "delivered"   //已投递
+	NotifyDeliveryQuarantined = "quarantined" //观察者永久失败，已隔离，不再自动投递
+
+	//notifyDeliveredRetention 已投递记录的保留时间
+	notifyDeliveredRetention = 7 * 24 * time.Hour
+)
+
+//NotifyObserverNamer 观察者可选实现，返回进程重启后不变的名称，用于关联投递状态，未实现时使用类型名
+type NotifyObserverNamer interface {
+	ObserverName() string
+}
+
+//notifyObserverName 观察者名称
+func notifyObserverName(o openwallet.BlockScanNotificationObject) string {
+	if namer, ok := o.(NotifyObserverNamer); ok {
+		return namer.ObserverName()
+	}
+	return fmt.Sprintf("%T", o)
+}
+
+//NotifyDelivery 提取结果对某个观察者的投递状态
+type NotifyDelivery struct {
+	ID          string `storm:"id"`
+	Observer    string `storm:"index"`
+	Status      string `storm:"index"`
+	BlockHeight uint64
+	TxID        string
+	SourceKey   string
+	Data        *openwallet.TxExtractData
+	Attempts    int    //投递次数
+	LastError   string //最近一次投递失败原因
+	CreateAt    int64
+	UpdateAt    int64
+}
+
+func NewNotifyDelivery(observer string, height uint64, sourceKey string, data *openwallet.TxExtractData) *NotifyDelivery {
+	obj := NotifyDelivery{}
+	obj.Observer = observer
+	obj.Status = NotifyDeliveryPending
+	obj.BlockHeight = height
+	obj.SourceKey = sourceKey
+	obj.Data = data
+	if data != nil && data.Transaction != nil {
+		obj.TxID = data.Transaction.TxID
+	}
+	obj.CreateAt = time.Now().Unix()
+	obj.UpdateAt = obj.CreateAt
+	plain := fmt.Sprintf("%s_%d_%s_%s", observer, height, obj.TxID, sourceKey)
+	//回滚通知与原通知分别记录投递状态
+	if data != nil && data.Transaction != nil && data.Transaction.TxAction == TxActionRollback {
+		plain = fmt.Sprintf("%s_%s_%s", plain, TxActionRollback, data.Transaction.WxID)
+	}
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(plain)))
+	return &obj
+}
+
+//saveNotifyDeliveries 批量保存投递状态
+func (wm *WalletManager) saveNotifyDeliveries(list []*NotifyDelivery) error {
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range list {
+		err = tx.Save(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetNotifyDeliveries 获取投递状态记录，observer或status为空时不过滤，按区块高度排序
+func (wm *WalletManager) GetNotifyDeliveries(observer, status string) ([]*NotifyDelivery, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	matchers := make([]q.Matcher, 0)
+	if len(observer) > 0 {
+		matchers = append(matchers, q.Eq("Observer", observer))
+	}
+	if len(status) > 0 {
+		matchers = append(matchers, q.Eq("Status", status))
+	}
+
+	var list []*NotifyDelivery
+	err = db.Select(matchers...).OrderBy("BlockHeight", "CreateAt").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//deliver 投递一条通知并更新状态
+func (d *NotifyDelivery) deliver(bs *NEOBlockScanner, o openwallet.BlockScanNotificationObject) error {
+	d.Attempts++
+	d.UpdateAt = time.Now().Unix()
+
+	err := bs.notifyObserver(o, d.SourceKey, d.Data)
+	if err != nil {
+		d.LastError = err.Error()
+		//永久失败的通知隔离，不再补发
+		if bs.isPermanentNotifyError(d.Observer, err) {
+			d.Status = NotifyDeliveryQuarantined
+		}
+		return err
+	}
+
+	d.Status = NotifyDeliveryDelivered
+	d.LastError = ""
+	return nil
+}
+
+//deliverExtractData 先持久化每个观察者的待投递记录再投递，失败的通知保留待投递状态由补发任务重试
+func (bs *NEOBlockScanner) deliverExtractData(height uint64, extractData map[string]*openwallet.TxExtractData) {
+
+	deliveries := make(map[openwallet.BlockScanNotificationObject][]*NotifyDelivery)
+	list := make([]*NotifyDelivery, 0)
+	for o, _ := range bs.Observers {
+		name := notifyObserverName(o)
+		for key, data := range extractData {
+			//不满足订阅条件的不生成投递记录
+			if !bs.acceptNotify(o, key, data) {
+				continue
+			}
+			d := NewNotifyDelivery(name, height, key, data)
+			deliveries[o] = append(deliveries[o], d)
+			list = append(list, d)
+		}
+	}
+
+	//持久化失败时仍然投递，只是不能保证重启后补发
+	err := bs.wm.saveNotifyDeliveries(list)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, save notify deliveries failed. unexpected error: %v", height, err)
+	}
+
+	quarantined := make([]*NotifyDelivery, 0)
+	for o, ds := range deliveries {
+		for _, d := range ds {
+			if err := d.deliver(bs, o); err != nil {
+				if d.Status == NotifyDeliveryQuarantined {
+					quarantined = append(quarantined, d)
+					continue
+				}
+				bs.wm.Log.Std.Error("block height: %d, notify observer: %s failed, will be redelivered. unexpected error: %v", height, d.Observer, err)
+			}
+		}
+	}
+
+	err = bs.wm.saveNotifyDeliveries(list)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, update notify deliveries failed. unexpected error: %v", height, err)
+	}
+
+	for _, d := range quarantined {
+		bs.quarantineAlert(d)
+	}
+}
+
+//RedeliverNotifications 重新投递待投递的通知，只投递给当前已注册的同名观察者，
+//最近interval内更新过的记录可能正在投递，留到下一次补发，返回投递成功的数量
+func (bs *NEOBlockScanner) RedeliverNotifications(interval time.Duration) (int, error) {
+
+	bs.outboxMu.Lock()
+	defer bs.outboxMu.Unlock()
+
+	pending, err := bs.wm.GetNotifyDeliveries("", NotifyDeliveryPending)
+	if err != nil {
+		return 0, bs.wm.errorf(ErrLocalDBOperateFailed, "get notify deliveries failed, unexpected error: %v", err)
+	}
+
+	observers := make(map[string]openwallet.BlockScanNotificationObject)
+	for o, _ := range bs.Observers {
+		observers[notifyObserverName(o)] = o
+	}
+
+	var (
+		delivered   int
+		updated     = make([]*NotifyDelivery, 0)
+		quarantined = make([]*NotifyDelivery, 0)
+		deadline    = time.Now().Add(-interval).Unix()
+	)
+	for _, d := range pending {
+		o, ok := observers[d.Observer]
+		if !ok || d.UpdateAt > deadline {
+			continue
+		}
+
+		err = d.deliver(bs, o)
+		updated = append(updated, d)
+		if err != nil && d.Status == NotifyDeliveryQuarantined {
+			//隔离的通知不影响该观察者后续的通知
+			quarantined = append(quarantined, d)
+			continue
+		}
+		if err != nil {
+			//保持区块顺序，该观察者后续的通知留到下一次补发
+			bs.wm.Log.Std.Info("redeliver notification to observer: %s on height: %d failed, unexpected error: %v", d.Observer, d.BlockHeight, err)
+			delete(observers, d.Observer)
+			continue
+		}
+		delivered++
+	}
+
+	err = bs.wm.saveNotifyDeliveries(updated)
+	if err != nil {
+		return delivered, bs.wm.errorf(ErrLocalDBOperateFailed, "save notify deliveries failed, unexpected error: %v", err)
+	}
+
+	for _, d := range quarantined {
+		bs.quarantineAlert(d)
+	}
+
+	bs.wm.pruneNotifyDeliveries(time.Now().Add(-notifyDeliveredRetention))
+
+	return delivered, nil
+}
+
+//pruneNotifyDeliveries 删除早于指定时间的已投递记录
+func (wm *WalletManager) pruneNotifyDeliveries(before time.Time) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	err = db.Select(q.Eq("Status", NotifyDeliveryDelivered), q.Lt("UpdateAt", before.Unix())).Delete(&NotifyDelivery{})
+	if err != nil && err != storm.ErrNotFound {
+		wm.Log.Std.Error("prune notify deliveries failed, unexpected error: %v", err)
+	}
+}
+
+//startNotifyRedeliver 定时补发未投递的通知
+func (bs *NEOBlockScanner) startNotifyRedeliver(interval time.Duration) *timer.TaskTimer {
+	task := timer.NewTask(interval, func() {
+		if _, err := bs.RedeliverNotifications(interval); err != nil {
+			bs.wm.Log.Std.Error("redeliver notifications failed, unexpected error: %v", err)
+		}
+	})
+	task.Start()
+	return task
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testNamedObserver struct {
+	testReplayObserver
+	name string
+	fail bool
+}
+
+func (o *testNamedObserver) ObserverName() string {
+	return o.name
+}
+
+func (o *testNamedObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	if o.fail {
+		return fmt.Errorf("observer is offline")
+	}
+	return o.testReplayObserver.BlockExtractDataNotify(sourceKey, data)
+}
+
+func TestNEOBlockScanner_NotifyOutbox(t *testing.T) {
+	dbPath, _ := ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(dbPath)
+
+	wm := NewWalletManager()
+	wm.Config.DBPath = dbPath
+	wm.Config.NotifyOutbox = true
+
+	online := &testNamedObserver{name: "online"}
+	offline := &testNamedObserver{name: "offline", fail: true}
+	wm.Blockscanner.AddObserver(online)
+	wm.Blockscanner.AddObserver(offline)
+
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "0x01"}
+	wm.Blockscanner.newExtractDataNotify(10, map[string]*openwallet.TxExtractData{"acc": data})
+
+	if len(online.notified) != 1 {
+		t.Errorf("online observer should be notified once, got: %v", online.notified)
+	}
+	pending, _ := wm.GetNotifyDeliveries("", NotifyDeliveryPending)
+	if len(pending) != 1 || pending[0].Observer != "offline" || pending[0].Attempts != 1 || len(pending[0].LastError) == 0 {
+		t.Errorf("unexpected pending deliveries: %+v", pending)
+		return
+	}
+
+	//进程重启后，同名观察者重新注册即可收到补发
+	restarted := NewWalletManager()
+	restarted.Config.DBPath = dbPath
+	restarted.Config.NotifyOutbox = true
+	recovered := &testNamedObserver{name: "offline"}
+	restarted.Blockscanner.AddObserver(recovered)
+
+	delivered, err := restarted.Blockscanner.RedeliverNotifications(0)
+	if err != nil || delivered != 1 {
+		t.Errorf("RedeliverNotifications delivered: %d, unexpected error: %v", delivered, err)
+	}
+	if len(recovered.notified) != 1 || recovered.notified[0] != "acc:0x01" {
+		t.Errorf("recovered observer notified: %v", recovered.notified)
+	}
+
+	pending, _ = restarted.GetNotifyDeliveries("", NotifyDeliveryPending)
+	if len(pending) != 0 {
+		t.Errorf("pending deliveries should be empty, got: %d", len(pending))
+	}
+	done, _ := restarted.GetNotifyDeliveries("offline", NotifyDeliveryDelivered)
+	if len(done) != 1 || done[0].Attempts != 2 {
+		t.Errorf("unexpected delivered records: %+v", done)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	AlertTypeNotifyQuarantined = "notify_quarantined" //观察者永久无法处理的通知已隔离
+)
+
+//PermanentNotifyError 观察者处理通知永久失败，重试也不会成功，例如数据不符合业务规则，
+//通知被隔离而不是记录未扫区块触发整个区块重扫
+type PermanentNotifyError struct {
+	Err error
+}
+
+//NewPermanentNotifyError 观察者在BlockExtractDataNotify中返回，标记通知永久失败
+func NewPermanentNotifyError(err error) error {
+	return &PermanentNotifyError{Err: err}
+}
+
+func (e *PermanentNotifyError) Error() string {
+	if e.Err == nil {
+		return "permanent notify error"
+	}
+	return e.Err.Error()
+}
+
+//Permanent 实现了该方法且返回true的错误都视为永久失败
+func (e *PermanentNotifyError) Permanent() bool {
+	return true
+}
+
+//NotifyErrorClassifier 判断观察者返回的错误是否永久失败，用于无法修改返回错误类型的观察者，
+//observer为观察者名称，见NotifyObserverNamer
+type NotifyErrorClassifier func(observer string, err error) bool
+
+//SetNotifyErrorClassifier 设置观察者错误的分类回调，nil时只按错误类型判断
+func (bs *NEOBlockScanner) SetNotifyErrorClassifier(classifier NotifyErrorClassifier) {
+	bs.classifierMu.Lock()
+	defer bs.classifierMu.Unlock()
+	bs.notifyClassifier = classifier
+}
+
+//isPermanentNotifyError 错误实现了Permanent() bool或分类回调判断为永久失败，其他错误视为暂时失败
+func (bs *NEOBlockScanner) isPermanentNotifyError(observer string, err error) bool {
+
+	if err == nil {
+		return false
+	}
+
+	if p, ok := err.(interface{ Permanent() bool }); ok && p.Permanent() {
+		return true
+	}
+
+	bs.classifierMu.RLock()
+	classifier := bs.notifyClassifier
+	bs.classifierMu.RUnlock()
+
+	return classifier != nil && classifier(observer, err)
+}
+
+//quarantineNotify 保存永久失败的通知为隔离记录并告警
+func (bs *NEOBlockScanner) quarantineNotify(observer string, height uint64, sourceKey string, data *openwallet.TxExtractData, notifyErr error) {
+
+	d := NewNotifyDelivery(observer, height, sourceKey, data)
+	d.Status = NotifyDeliveryQuarantined
+	d.Attempts = 1
+	d.LastError = notifyErr.Error()
+
+	err := bs.wm.saveNotifyDeliveries([]*NotifyDelivery{d})
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d, save quarantined notification failed. unexpected error: %v", height, err)
+	}
+
+	bs.quarantineAlert(d)
+}
+
+//quarantineAlert 通知被隔离的告警
+func (bs *NEOBlockScanner) quarantineAlert(d *NotifyDelivery) {
+
+	bs.wm.Log.Std.Warning("block height: %d, notification of txid: %s to observer: %s is quarantined, error: %s", d.BlockHeight, d.TxID, d.Observer, d.LastError)
+
+	alert := NewAlert(bs.wm.Symbol(), AlertTypeNotifyQuarantined, d.BlockHeight,
+		fmt.Sprintf("notification of txid: %s to observer: %s permanently failed and is quarantined", d.TxID, d.Observer))
+	alert.Details["id"] = d.ID
+	alert.Details["observer"] = d.Observer
+	alert.Details["txid"] = d.TxID
+	alert.Details["sourceKey"] = d.SourceKey
+	alert.Details["error"] = d.LastError
+	bs.newAlertNotify(alert)
+}
+
+//GetQuarantinedNotifications 获取已隔离的通知，observer为空时返回全部，按区块高度排序
+func (wm *WalletManager) GetQuarantinedNotifications(observer string) ([]*NotifyDelivery, error) {
+	return wm.GetNotifyDeliveries(observer, NotifyDeliveryQuarantined)
+}
+
+//getQuarantinedNotification 按ID获取已隔离的通知
+func (wm *WalletManager) getQuarantinedNotification(id string) (*NotifyDelivery, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var d NotifyDelivery
+	err = db.One("ID", id, &d)
+	if err == storm.ErrNotFound || (err == nil && d.Status != NotifyDeliveryQuarantined) {
+		return nil, wm.errorf(ErrNotifyDeliveryNotFound, "quarantined notification: %s is not found", id)
+	}
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get notify deliveries failed, unexpected error: %v", err)
+	}
+
+	return &d, nil
+}
+
+//ReleaseQuarantinedNotification 观察者修复后重新投递已隔离的通知，只投递给当前已注册的同名观察者，
+//再次永久失败时保持隔离；暂时失败时启用了notifyOutbox则转为待投递由补发任务重试，否则保持隔离
+func (bs *NEOBlockScanner) ReleaseQuarantinedNotification(id string) error {
+
+	bs.outboxMu.Lock()
+	defer bs.outboxMu.Unlock()
+
+	d, err := bs.wm.getQuarantinedNotification(id)
+	if err != nil {
+		return err
+	}
+
+	var observer openwallet.BlockScanNotificationObject
+	for o, _ := range bs.Observers {
+		if notifyObserverName(o) == d.Observer {
+			observer = o
+			break
+		}
+	}
+	if observer == nil {
+		return fmt.Errorf("observer: %s is not registered", d.Observer)
+	}
+
+	d.Status = NotifyDeliveryPending
+	notifyErr := d.deliver(bs, observer)
+	if notifyErr != nil && !bs.wm.config().NotifyOutbox {
+		d.Status = NotifyDeliveryQuarantined
+	}
+
+	err = bs.wm.saveNotifyDeliveries([]*NotifyDelivery{d})
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "save notify deliveries failed, unexpected error: %v", err)
+	}
+
+	if notifyErr != nil {
+		return notifyErr
+	}
+
+	bs.wm.Log.Std.Notice("quarantined notification of txid: %s to observer: %s is released", d.TxID, d.Observer)
+
+	return nil
+}
+
+//DiscardQuarantinedNotification 放弃已隔离的通知，例如观察者确认该交易无需入账，删除后不能恢复
+func (bs *NEOBlockScanner) DiscardQuarantinedNotification(id string) error {
+
+	if err := bs.wm.requireCapability(CapabilityDeleteLocal); err != nil {
+		return err
+	}
+
+	bs.outboxMu.Lock()
+	defer bs.outboxMu.Unlock()
+
+	d, err := bs.wm.getQuarantinedNotification(id)
+	if err != nil {
+		return err
+	}
+
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(d)
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "delete quarantined notification failed, unexpected error: %v", err)
+	}
+
+	bs.wm.Log.Std.Notice("quarantined notification of txid: %s to observer: %s is discarded", d.TxID, d.Observer)
+
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//testRejectObserver 返回指定错误的观察者，err为nil时正常处理
+type testRejectObserver struct {
+	testReplayObserver
+	name string
+	err  error
+}
+
+func (o *testRejectObserver) ObserverName() string {
+	return o.name
+}
+
+func (o *testRejectObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	if o.err != nil {
+		return o.err
+	}
+	return o.testReplayObserver.BlockExtractDataNotify(sourceKey, data)
+}
+
+func testQuarantineData(txid string) map[string]*openwallet.TxExtractData {
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: txid}
+	return map[string]*openwallet.TxExtractData{"acc": data}
+}
+
+func TestNEOBlockScanner_QuarantineNotify(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	alerts := &testAlertObserver{}
+	bs.AddAlertObserver(alerts)
+	rejecter := &testRejectObserver{name: "rejecter", err: NewPermanentNotifyError(fmt.Errorf("account is closed"))}
+	bs.AddObserver(rejecter)
+
+	//永久失败隔离，不记录未扫区块
+	bs.newExtractDataNotify(10, testQuarantineData("0x01"))
+	if records, _ := wm.GetUnscanRecords(); len(records) != 0 {
+		t.Errorf("permanent failure should not save unscan record, got: %d", len(records))
+	}
+	quarantined, _ := wm.GetQuarantinedNotifications("rejecter")
+	if len(quarantined) != 1 || quarantined[0].TxID != "0x01" || quarantined[0].LastError != "account is closed" {
+		t.Fatalf("unexpected quarantined notifications: %+v", quarantined)
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeNotifyQuarantined || alerts.alerts[0].Details["id"] != quarantined[0].ID {
+		t.Errorf("quarantine should raise an alert, got: %+v", alerts.alerts)
+	}
+
+	//暂时失败仍记录未扫区块
+	rejecter.err = fmt.Errorf("database is down")
+	bs.newExtractDataNotify(11, testQuarantineData("0x02"))
+	if records, _ := wm.GetUnscanRecords(); len(records) != 1 {
+		t.Errorf("transient failure should save unscan record, got: %d", len(records))
+	}
+
+	//分类回调判断为永久失败
+	bs.SetNotifyErrorClassifier(func(observer string, err error) bool {
+		return observer == "rejecter" && err.Error() == "invalid memo"
+	})
+	rejecter.err = fmt.Errorf("invalid memo")
+	bs.newExtractDataNotify(12, testQuarantineData("0x03"))
+	if quarantined, _ = wm.GetQuarantinedNotifications(""); len(quarantined) != 2 {
+		t.Fatalf("classified failure should be quarantined, got: %d", len(quarantined))
+	}
+
+	//观察者修复后重新投递
+	if err := bs.ReleaseQuarantinedNotification(quarantined[0].ID); err == nil {
+		t.Errorf("release should fail while observer still rejects")
+	}
+	rejecter.err = nil
+	if err := bs.ReleaseQuarantinedNotification(quarantined[0].ID); err != nil {
+		t.Fatalf("ReleaseQuarantinedNotification failed, unexpected error: %v", err)
+	}
+	if len(rejecter.notified) != 1 || rejecter.notified[0] != "acc:0x01" {
+		t.Errorf("released notification should be delivered, notified: %v", rejecter.notified)
+	}
+	err := bs.ReleaseQuarantinedNotification(quarantined[0].ID)
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrNotifyDeliveryNotFound {
+		t.Errorf("released notification should not be quarantined, err: %v", err)
+	}
+
+	if err := bs.DiscardQuarantinedNotification(quarantined[1].ID); err != nil {
+		t.Fatalf("DiscardQuarantinedNotification failed, unexpected error: %v", err)
+	}
+	if quarantined, _ = wm.GetQuarantinedNotifications(""); len(quarantined) != 0 {
+		t.Errorf("discarded notification should be removed, got: %d", len(quarantined))
+	}
+}
+
+func TestNEOBlockScanner_QuarantineNotifyOutbox(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.NotifyOutbox = true
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	rejecter := &testRejectObserver{name: "rejecter", err: NewPermanentNotifyError(fmt.Errorf("account is closed"))}
+	bs.AddObserver(rejecter)
+
+	bs.newExtractDataNotify(10, testQuarantineData("0x01"))
+	rejecter.err = fmt.Errorf("database is down")
+	bs.newExtractDataNotify(11, testQuarantineData("0x02"))
+
+	//隔离的通知不补发，也不阻塞该观察者后续的通知
+	rejecter.err = nil
+	delivered, err := bs.RedeliverNotifications(0)
+	if err != nil || delivered != 1 || len(rejecter.notified) != 1 || rejecter.notified[0] != "acc:0x02" {
+		t.Fatalf("RedeliverNotifications = %d, %v, notified: %v", delivered, err, rejecter.notified)
+	}
+	quarantined, _ := wm.GetQuarantinedNotifications("rejecter")
+	if len(quarantined) != 1 || quarantined[0].TxID != "0x01" {
+		t.Fatalf("unexpected quarantined notifications: %+v", quarantined)
+	}
+
+	//暂时失败时转为待投递
+	rejecter.err = fmt.Errorf("database is down")
+	if err := bs.ReleaseQuarantinedNotification(quarantined[0].ID); err == nil {
+		t.Errorf("release should report observer error")
+	}
+	if pending, _ := wm.GetNotifyDeliveries("rejecter", NotifyDeliveryPending); len(pending) != 1 || pending[0].Attempts != 2 {
+		t.Errorf("transient failure on release should be pending, got: %+v", pending)
+	}
+}
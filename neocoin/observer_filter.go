@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//ObserverFilter 观察者订阅过滤条件，投递前判断，不满足的提取结果不通知该观察者，
+//各条件同时满足才通知，零值条件不限制
+type ObserverFilter struct {
+	SourceKeys   []string        //只通知指定账户的提取结果
+	ContractOnly bool            //只通知合约代币交易
+	MinAmount    decimal.Decimal //只通知有输入或输出金额不小于该值的交易
+}
+
+//Match 提取结果是否满足过滤条件
+func (f *ObserverFilter) Match(sourceKey string, data *openwallet.TxExtractData) bool {
+
+	if f == nil {
+		return true
+	}
+
+	if len(f.SourceKeys) > 0 {
+		matched := false
+		for _, key := range f.SourceKeys {
+			if key == sourceKey {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if data == nil {
+		return true
+	}
+
+	if f.ContractOnly && (data.Transaction == nil || !data.Transaction.Coin.IsContract) {
+		return false
+	}
+
+	if f.MinAmount.IsPositive() {
+		matched := false
+		for _, input := range data.TxInputs {
+			if amount, err := decimal.NewFromString(input.Amount); err == nil && amount.Abs().GreaterThanOrEqual(f.MinAmount) {
+				matched = true
+				break
+			}
+		}
+		for _, output := range data.TxOutputs {
+			if matched {
+				break
+			}
+			if amount, err := decimal.NewFromString(output.Amount); err == nil && amount.Abs().GreaterThanOrEqual(f.MinAmount) {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+//AddObserverWithFilter 添加带过滤条件的观测者，已添加的观测者更新过滤条件，filter为nil不过滤
+func (bs *NEOBlockScanner) AddObserverWithFilter(obj openwallet.BlockScanNotificationObject, filter *ObserverFilter) error {
+
+	if obj == nil {
+		return nil
+	}
+
+	err := bs.AddObserver(obj)
+	if err != nil {
+		return err
+	}
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if filter == nil {
+		delete(bs.observerFilters, obj)
+	} else {
+		bs.observerFilters[obj] = filter
+	}
+
+	return nil
+}
+
+//RemoveObserver 移除观测者和它的过滤条件
+func (bs *NEOBlockScanner) RemoveObserver(obj openwallet.BlockScanNotificationObject) error {
+
+	err := bs.BlockScannerBase.RemoveObserver(obj)
+	if err != nil {
+		return err
+	}
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	delete(bs.observerFilters, obj)
+
+	return nil
+}
+
+//ObserverFilter 观测者的过滤条件，未设置返回nil
+func (bs *NEOBlockScanner) ObserverFilter(obj openwallet.BlockScanNotificationObject) *ObserverFilter {
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+	return bs.observerFilters[obj]
+}
+
+//acceptNotify 投递前判断观测者是否订阅该提取结果
+func (bs *NEOBlockScanner) acceptNotify(obj openwallet.BlockScanNotificationObject, sourceKey string, data *openwallet.TxExtractData) bool {
+	return bs.ObserverFilter(obj).Match(sourceKey, data)
+}
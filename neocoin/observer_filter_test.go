@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestNEOBlockScanner_ObserverFilter(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	newData := func(txid, amount string, isContract bool) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid, BlockHeight: 10, Coin: openwallet.Coin{IsContract: isContract}}
+		data.TxOutputs = []*openwallet.TxOutPut{{Recharge: openwallet.Recharge{TxID: txid, Amount: amount}}}
+		return data
+	}
+
+	all := &testReplayObserver{}
+	large := &testReplayObserver{}
+	token := &testReplayObserver{}
+	bs := wm.Blockscanner
+	bs.AddObserver(all)
+	bs.AddObserverWithFilter(large, &ObserverFilter{SourceKeys: []string{"exchange"}, MinAmount: decimal.New(100, 0)})
+	bs.AddObserverWithFilter(token, &ObserverFilter{ContractOnly: true})
+
+	bs.newExtractDataNotify(10, map[string]*openwallet.TxExtractData{
+		"exchange": newData("0xbig", "100", false),
+	})
+	bs.newExtractDataNotify(10, map[string]*openwallet.TxExtractData{
+		"exchange": newData("0xsmall", "1", false),
+		"user":     newData("0xuser", "500", true),
+	})
+
+	if len(all.notified) != 3 {
+		t.Errorf("observer without filter should receive all, got: %v", all.notified)
+	}
+	if len(large.notified) != 1 || large.notified[0] != "exchange:0xbig" {
+		t.Errorf("unexpected filtered notifications: %v", large.notified)
+	}
+	if len(token.notified) != 1 || token.notified[0] != "user:0xuser" {
+		t.Errorf("unexpected contract notifications: %v", token.notified)
+	}
+
+	//移除观测者同时移除过滤条件
+	bs.RemoveObserver(large)
+	if bs.ObserverFilter(large) != nil {
+		t.Errorf("observer filter should be removed")
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//ObserverLatency 观察者处理提取结果通知的耗时统计
+type ObserverLatency struct {
+	Observer   string
+	Count      uint64        //通知次数
+	Failed     uint64        //通知返回错误的次数
+	Total      time.Duration //累计耗时
+	Max        time.Duration //最长耗时
+	Last       time.Duration //最近一次耗时
+	SlowCount  uint64        //超过耗时预算的次数
+	SlowStreak uint64        //连续超过耗时预算的次数
+	UpdateAt   int64
+}
+
+//Avg 平均耗时
+func (l *ObserverLatency) Avg() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+//notifyObserver 通知观察者提取结果并记录耗时
+func (bs *NEOBlockScanner) notifyObserver(o openwallet.BlockScanNotificationObject, sourceKey string, data *openwallet.TxExtractData) error {
+	err := bs.notifyObserverChunks(o, sourceKey, data)
+	if err != nil {
+		var height uint64
+		if data != nil && data.Transaction != nil {
+			height = data.Transaction.BlockHeight
+		}
+		bs.recordScanError(ScanErrorSourceObserver, height, fmt.Errorf("observer: %s, %v", notifyObserverName(o), err))
+	}
+	return err
+}
+
+//recordObserverLatency 累计观察者的通知耗时，连续超过预算达到次数时告警，持续变慢时每达到一次次数重复告警
+func (bs *NEOBlockScanner) recordObserverLatency(name string, elapsed time.Duration, notifyErr error) {
+
+	var (
+		budget = bs.wm.config().ObserverNotifyBudget
+		streak = bs.wm.config().ObserverSlowStreak
+		alert  *Alert
+	)
+
+	bs.latencyMu.Lock()
+
+	if bs.observerLatency == nil {
+		bs.observerLatency = make(map[string]*ObserverLatency)
+	}
+
+	l, ok := bs.observerLatency[name]
+	if !ok {
+		l = &ObserverLatency{Observer: name}
+		bs.observerLatency[name] = l
+	}
+
+	l.Count++
+	if notifyErr != nil {
+		l.Failed++
+	}
+	l.Total += elapsed
+	l.Last = elapsed
+	if elapsed > l.Max {
+		l.Max = elapsed
+	}
+	l.UpdateAt = time.Now().Unix()
+
+	if budget > 0 && elapsed > budget {
+		l.SlowCount++
+		l.SlowStreak++
+		if streak > 0 && l.SlowStreak%streak == 0 {
+			alert = NewAlert(bs.wm.Symbol(), AlertTypeSlowObserver, 0,
+				fmt.Sprintf("observer: %s exceeded notify budget: %v for %d consecutive notifications, last: %v, avg: %v",
+					name, budget, l.SlowStreak, elapsed, l.Avg()))
+			alert.Details["observer"] = name
+			alert.Details["budget"] = budget.String()
+			alert.Details["last"] = elapsed.String()
+			alert.Details["avg"] = l.Avg().String()
+			alert.Details["max"] = l.Max.String()
+		}
+	} else {
+		l.SlowStreak = 0
+	}
+
+	bs.latencyMu.Unlock()
+
+	if alert != nil {
+		bs.newAlertNotify(alert)
+	}
+}
+
+//GetObserverMetrics 获取各观察者的通知耗时统计，按平均耗时从慢到快排序
+func (bs *NEOBlockScanner) GetObserverMetrics() []*ObserverLatency {
+
+	bs.latencyMu.Lock()
+	defer bs.latencyMu.Unlock()
+
+	list := make([]*ObserverLatency, 0, len(bs.observerLatency))
+	for _, l := range bs.observerLatency {
+		copied := *l
+		list = append(list, &copied)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Avg() != list[j].Avg() {
+			return list[i].Avg() > list[j].Avg()
+		}
+		return list[i].Observer < list[j].Observer
+	})
+
+	return list
+}
+
+//ResetObserverMetrics 清空通知耗时统计，observer为空时清空全部
+func (bs *NEOBlockScanner) ResetObserverMetrics(observer string) {
+
+	bs.latencyMu.Lock()
+	defer bs.latencyMu.Unlock()
+
+	if len(observer) == 0 {
+		bs.observerLatency = make(map[string]*ObserverLatency)
+		return
+	}
+	delete(bs.observerLatency, observer)
+}
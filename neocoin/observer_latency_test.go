@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_ObserverLatency(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.ObserverNotifyBudget = 100 * time.Millisecond
+	wm.Config.ObserverSlowStreak = 3
+	bs := wm.Blockscanner
+
+	alerts := &testAlertObserver{}
+	bs.AddAlertObserver(alerts)
+
+	//通知经过计时，按观察者名称统计
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "0xtx1"}
+	fast := &testNamedObserver{name: "fast"}
+	if err := bs.notifyObserver(fast, "account", data); err != nil {
+		t.Fatalf("notifyObserver failed, unexpected error: %v", err)
+	}
+	failed := &testNamedObserver{name: "failed", fail: true}
+	if err := bs.notifyObserver(failed, "account", data); err == nil {
+		t.Errorf("notifyObserver should return observer error")
+	}
+
+	//偶尔超过预算不告警，连续超过达到次数后告警
+	bs.recordObserverLatency("slow", 200*time.Millisecond, nil)
+	bs.recordObserverLatency("slow", 200*time.Millisecond, nil)
+	bs.recordObserverLatency("slow", 10*time.Millisecond, nil)
+	bs.recordObserverLatency("slow", 200*time.Millisecond, nil)
+	bs.recordObserverLatency("slow", 200*time.Millisecond, nil)
+	if len(alerts.alerts) != 0 {
+		t.Fatalf("streak was broken, got alerts: %d", len(alerts.alerts))
+	}
+	bs.recordObserverLatency("slow", 300*time.Millisecond, nil)
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeSlowObserver || alerts.alerts[0].Details["observer"] != "slow" {
+		t.Fatalf("slow observer should raise an alert, got: %+v", alerts.alerts)
+	}
+
+	metrics := bs.GetObserverMetrics()
+	if len(metrics) != 3 || metrics[0].Observer != "slow" {
+		t.Fatalf("metrics should be sorted slowest first, got: %+v", metrics)
+	}
+	slow := metrics[0]
+	if slow.Count != 6 || slow.SlowCount != 5 || slow.SlowStreak != 3 || slow.Max != 300*time.Millisecond || slow.Last != 300*time.Millisecond {
+		t.Errorf("unexpected slow observer metrics: %+v", slow)
+	}
+	if slow.Avg() != 185*time.Millisecond {
+		t.Errorf("avg = %v, want 185ms", slow.Avg())
+	}
+	for _, l := range metrics[1:] {
+		if l.Observer == "failed" && (l.Count != 1 || l.Failed != 1) {
+			t.Errorf("unexpected failed observer metrics: %+v", l)
+		}
+	}
+
+	//返回的是副本
+	metrics[0].Count = 0
+	if bs.GetObserverMetrics()[0].Count != 6 {
+		t.Errorf("metrics should be copied")
+	}
+
+	//通过浏览器接口查询
+	server := httptest.NewServer(NewExplorerServer(bs))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/metrics/observers")
+	if err != nil {
+		t.Fatalf("get metrics failed, unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		BudgetMs  float64 `json:"budgetMs"`
+		Observers []struct {
+			Observer  string  `json:"observer"`
+			AvgMs     float64 `json:"avgMs"`
+			SlowCount uint64  `json:"slowCount"`
+		} `json:"observers"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.BudgetMs != 100 || len(result.Observers) != 3 || result.Observers[0].AvgMs != 185 || result.Observers[0].SlowCount != 5 {
+		t.Errorf("unexpected metrics response: %+v", result)
+	}
+
+	//未设置预算时只统计不告警
+	bs.ResetObserverMetrics("")
+	wm.Config.ObserverNotifyBudget = 0
+	for i := 0; i < 5; i++ {
+		bs.recordObserverLatency("slow", time.Second, nil)
+	}
+	if len(alerts.alerts) != 1 || bs.GetObserverMetrics()[0].SlowCount != 0 {
+		t.Errorf("budget disabled should not count slow notifications")
+	}
+}
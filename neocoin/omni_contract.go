@@ -46,7 +46,7 @@ func (wm *WalletManager) GetOmniTransaction(txid string) (*OmniTransaction, erro
 		txid,
 	}
 
-	result, err := wm.OnmiClient.Call("omni_gettransaction", request)
+	result, err := wm.callWithBreaker(wm.OnmiClient, "omni_gettransaction", request)
 	if err != nil {
 		return nil, err
 	}
@@ -145,6 +145,11 @@ func NewContractDecoder(wm *WalletManager) *ContractDecoder {
 
 func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.SmartContract, address ...string) ([]*openwallet.TokenBalance, error) {
 
+	//未开启Omni兼容时查询NEP-5代币合约
+	if !decoder.wm.omniEnabled() {
+		return decoder.wm.getNEP5TokenBalances(contract, address...)
+	}
+
 	var tokenBalanceList []*openwallet.TokenBalance
 
 	for i:=0; i<len(address); i++ {
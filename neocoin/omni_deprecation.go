@@ -0,0 +1,42 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+const (
+	DeprecationOmniSupport = "omni_support" //Omni代币功能已废弃，由NEP-5代币合约替代
+)
+
+//omniEnabled Omni代币功能是否生效，须同时开启兼容开关，否则omniSupport不生效
+func (wm *WalletManager) omniEnabled() bool {
+	cfg := wm.config()
+	return cfg.OmniSupport && cfg.OmniDeprecatedCompat
+}
+
+//logOmniDeprecation 配置了omniSupport时记录废弃事件，未开启兼容开关时代币功能改用NEP-5代币合约
+func (wm *WalletManager) logOmniDeprecation() {
+	cfg := wm.config()
+	if !cfg.OmniSupport {
+		return
+	}
+
+	replacement := "tokenContracts"
+	if cfg.OmniDeprecatedCompat {
+		replacement = "none"
+	}
+
+	wm.Log.Std.Warning("deprecation event=%s symbol=%s key=omniSupport compat=%v tokenContracts=%d replacement=%s",
+		DeprecationOmniSupport, wm.Symbol(), cfg.OmniDeprecatedCompat, len(cfg.TokenContracts), replacement)
+}
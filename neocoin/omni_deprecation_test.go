@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_OmniDeprecatedCompat(t *testing.T) {
+	const (
+		contract = "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+		address  = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		empty    = "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"
+	)
+	hash, _ := neoTransaction.AddressToScriptHash(address)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "invokefunction" || params[0] != contract || params[1] != "balanceOf" {
+			return nil
+		}
+		param := params[2].([]interface{})[0].(map[string]interface{})
+		if param["value"] == hex.EncodeToString(reverseBytes(hash)) {
+			//12.5个精度为8的代币
+			return map[string]interface{}{"state": "HALT, BREAK", "stack": []interface{}{map[string]interface{}{"type": "ByteArray", "value": "807c814a"}}}
+		}
+		return map[string]interface{}{"state": "HALT, BREAK", "stack": []interface{}{map[string]interface{}{"type": "ByteArray", "value": ""}}}
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	//只配置omniSupport时Omni功能不生效
+	wm.Config.OmniSupport = true
+	if wm.omniEnabled() {
+		t.Errorf("omni should be disabled without deprecated compat")
+	}
+
+	balances, err := wm.ContractDecoder.GetTokenBalanceByAddress(openwallet.SmartContract{Address: contract, Symbol: "RPX", Decimals: 8}, address, empty)
+	if err != nil {
+		t.Errorf("GetTokenBalanceByAddress failed unexpected error: %v\n", err)
+		return
+	}
+	if len(balances) != 2 || balances[0].Balance.Balance != "12.5" || balances[1].Balance.Balance != "0" {
+		t.Errorf("nep5 token balances: %+v, %+v", balances[0].Balance, balances[1].Balance)
+	}
+
+	wm.Config.OmniDeprecatedCompat = true
+	if !wm.omniEnabled() {
+		t.Errorf("omni should be enabled with deprecated compat")
+	}
+}
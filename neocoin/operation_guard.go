@@ -0,0 +1,254 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"crypto/hmac"
+	"sync"
+)
+
+//Capability 危险操作权限
+type Capability uint32
+
+const (
+	CapabilityRescan       Capability = 1 << iota //重设扫描高度
+	CapabilityDeleteUnscan                        //删除未扫记录
+	CapabilityBroadcast                           //广播交易单
+	CapabilityTakeOver                            //接管扫描租约
+	CapabilityDeleteLocal                         //删除本地区块、已删除的未扫记录和钱包未花
+
+	CapabilityAll = CapabilityRescan | CapabilityDeleteUnscan | CapabilityBroadcast | CapabilityTakeOver | CapabilityDeleteLocal
+)
+
+//String 权限名称
+func (c Capability) String() string {
+	switch c {
+	case CapabilityRescan:
+		return "rescan"
+	case CapabilityDeleteUnscan:
+		return "deleteUnscan"
+	case CapabilityBroadcast:
+		return "broadcast"
+	case CapabilityTakeOver:
+		return "takeOver"
+	case CapabilityDeleteLocal:
+		return "deleteLocal"
+	case CapabilityAll:
+		return "all"
+	}
+	return "unknown"
+}
+
+//capabilitySet 已授予的危险操作权限，管理者和每个操作句柄各持有一份
+type capabilitySet struct {
+	mu   sync.Mutex
+	caps Capability
+}
+
+//grant 校验操作令牌后授予权限，未配置操作令牌时所有操作默认允许
+func (s *capabilitySet) grant(wm *WalletManager, token string, caps Capability) error {
+	operationToken := wm.config().OperationToken
+	if len(operationToken) == 0 {
+		return nil
+	}
+	if !hmac.Equal([]byte(token), []byte(operationToken)) {
+		return wm.errorf(ErrOperationNotPermitted, "operation token is invalid")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caps |= caps
+	return nil
+}
+
+func (s *capabilitySet) revoke(caps Capability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caps &^= caps
+}
+
+func (s *capabilitySet) require(wm *WalletManager, caps Capability) error {
+	if len(wm.config().OperationToken) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.caps&caps != caps {
+		return wm.errorf(ErrOperationNotPermitted, "operation [%s] is not permitted", caps)
+	}
+	return nil
+}
+
+//GrantCapability 使用操作令牌授予管理者危险操作权限
+//监控类组件应使用ReadOnly句柄，或通过NewOperationHandle获得单独授权的句柄
+func (wm *WalletManager) GrantCapability(token string, caps Capability) error {
+	return wm.capabilities.grant(wm, token, caps)
+}
+
+//RevokeCapability 收回危险操作权限
+func (wm *WalletManager) RevokeCapability(caps Capability) {
+	wm.capabilities.revoke(caps)
+}
+
+//requireCapability 检查管理者是否拥有危险操作权限
+func (wm *WalletManager) requireCapability(caps Capability) error {
+	return wm.capabilities.require(wm, caps)
+}
+
+//ReadOnlyManager 只读管理者句柄，不包含任何危险操作，不受管理者授权影响
+type ReadOnlyManager interface {
+	GetLocalNewBlock() (uint64, string)
+	GetLocalBlock(height uint64) (*Block, error)
+	GetBlockHeight() (uint64, error)
+	GetBlockHash(height uint64) (string, error)
+	GetBlock(hash string) (*Block, error)
+	GetTransaction(txid string) (*Transaction, error)
+	GetTransactionDetail(txid string) (*TransactionDetail, error)
+	FilterKnownTxIDs(txids []string) (*TxIDFilterResult, error)
+	GetTxIDsInMemPool() ([]string, error)
+	GetMempoolTxs() ([]*MempoolTxRecord, error)
+	GetUnscanRecords() ([]*UnscanRecord, error)
+	GetQuarantinedNotifications(observer string) ([]*NotifyDelivery, error)
+	ListUnspent(min uint64, addresses ...string) ([]*UnspentBalance, error)
+	StorageMetrics() (*StorageMetrics, error)
+	ListScanSnapshots() ([]*ScanSnapshot, error)
+	ConfigSnapshot() *WalletConfig
+}
+
+type readOnlyManager struct {
+	wm *WalletManager
+}
+
+//ReadOnly 返回只读句柄，提供给监控类组件
+func (wm *WalletManager) ReadOnly() ReadOnlyManager {
+	return &readOnlyManager{wm: wm}
+}
+
+func (r *readOnlyManager) GetLocalNewBlock() (uint64, string) { return r.wm.GetLocalNewBlock() }
+func (r *readOnlyManager) GetLocalBlock(height uint64) (*Block, error) {
+	return r.wm.GetLocalBlock(height)
+}
+func (r *readOnlyManager) GetBlockHeight() (uint64, error) { return r.wm.GetBlockHeight() }
+func (r *readOnlyManager) GetBlockHash(height uint64) (string, error) {
+	return r.wm.GetBlockHash(height)
+}
+func (r *readOnlyManager) GetBlock(hash string) (*Block, error) { return r.wm.GetBlock(hash) }
+func (r *readOnlyManager) GetTransaction(txid string) (*Transaction, error) {
+	return r.wm.GetTransaction(txid)
+}
+func (r *readOnlyManager) GetTransactionDetail(txid string) (*TransactionDetail, error) {
+	return r.wm.GetTransactionDetail(txid)
+}
+func (r *readOnlyManager) FilterKnownTxIDs(txids []string) (*TxIDFilterResult, error) {
+	return r.wm.FilterKnownTxIDs(txids)
+}
+func (r *readOnlyManager) GetTxIDsInMemPool() ([]string, error) { return r.wm.GetTxIDsInMemPool() }
+func (r *readOnlyManager) GetMempoolTxs() ([]*MempoolTxRecord, error) {
+	return r.wm.GetMempoolTxs()
+}
+func (r *readOnlyManager) GetUnscanRecords() ([]*UnscanRecord, error) {
+	return r.wm.GetUnscanRecords()
+}
+func (r *readOnlyManager) GetQuarantinedNotifications(observer string) ([]*NotifyDelivery, error) {
+	return r.wm.GetQuarantinedNotifications(observer)
+}
+func (r *readOnlyManager) ListUnspent(min uint64, addresses ...string) ([]*UnspentBalance, error) {
+	return r.wm.ListUnspent(min, addresses...)
+}
+func (r *readOnlyManager) StorageMetrics() (*StorageMetrics, error) { return r.wm.StorageMetrics() }
+func (r *readOnlyManager) ListScanSnapshots() ([]*ScanSnapshot, error) {
+	return r.wm.ListScanSnapshots()
+}
+func (r *readOnlyManager) ConfigSnapshot() *WalletConfig { return r.wm.ConfigSnapshot() }
+
+//OperationHandle 单独授权的危险操作句柄，只检查句柄自身的权限，
+//管理者或其他句柄的授权不会扩散到该句柄
+type OperationHandle struct {
+	wm           *WalletManager
+	capabilities capabilitySet
+}
+
+//NewOperationHandle 创建未授权的操作句柄
+func (wm *WalletManager) NewOperationHandle() *OperationHandle {
+	return &OperationHandle{wm: wm}
+}
+
+//Grant 使用操作令牌授予句柄危险操作权限
+func (h *OperationHandle) Grant(token string, caps Capability) error {
+	return h.capabilities.grant(h.wm, token, caps)
+}
+
+//Revoke 收回句柄的危险操作权限
+func (h *OperationHandle) Revoke(caps Capability) {
+	h.capabilities.revoke(caps)
+}
+
+//SetRescanBlockHeight 重置区块链扫描高度，需要CapabilityRescan
+func (h *OperationHandle) SetRescanBlockHeight(height uint64) error {
+	if err := h.capabilities.require(h.wm, CapabilityRescan); err != nil {
+		return err
+	}
+	return h.wm.Blockscanner.setRescanBlockHeight(height)
+}
+
+//SetLocalBlockHead 设置本地已扫区块头，需要CapabilityRescan，purge时还需要CapabilityDeleteLocal
+func (h *OperationHandle) SetLocalBlockHead(height uint64, hash string, purge bool) error {
+	if err := h.capabilities.require(h.wm, localBlockHeadCapability(purge)); err != nil {
+		return err
+	}
+	return h.wm.Blockscanner.setLocalBlockHead(height, hash, purge)
+}
+
+//DeleteUnscanRecord 软删除指定高度的未扫记录，需要CapabilityDeleteUnscan
+func (h *OperationHandle) DeleteUnscanRecord(height uint64) error {
+	if err := h.capabilities.require(h.wm, CapabilityDeleteUnscan); err != nil {
+		return err
+	}
+	return h.wm.deleteUnscanRecord(height)
+}
+
+//DeleteLocalDataAboveHeight 删除本地高于指定高度的区块和未扫记录，需要CapabilityDeleteLocal
+func (h *OperationHandle) DeleteLocalDataAboveHeight(height uint64) error {
+	if err := h.capabilities.require(h.wm, CapabilityDeleteLocal); err != nil {
+		return err
+	}
+	return h.wm.deleteLocalDataAboveHeight(height)
+}
+
+//RestoreToHeight 恢复扫描状态到不高于height的最近快照，需要CapabilityRescan和CapabilityDeleteLocal
+func (h *OperationHandle) RestoreToHeight(height uint64) (*ScanSnapshot, error) {
+	if err := h.capabilities.require(h.wm, CapabilityRescan|CapabilityDeleteLocal); err != nil {
+		return nil, err
+	}
+	return h.wm.Blockscanner.restoreToHeight(height)
+}
+
+//SendRawTransaction 广播交易，需要CapabilityBroadcast
+func (h *OperationHandle) SendRawTransaction(txHex string) (string, error) {
+	if err := h.capabilities.require(h.wm, CapabilityBroadcast); err != nil {
+		return "", err
+	}
+	return h.wm.sendRawTransaction(txHex)
+}
+
+//localBlockHeadCapability 设置本地区块头需要的权限
+func localBlockHeadCapability(purge bool) Capability {
+	if purge {
+		return CapabilityRescan | CapabilityDeleteLocal
+	}
+	return CapabilityRescan
+}
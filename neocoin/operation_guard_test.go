@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_GrantCapability(t *testing.T) {
+	wm := NewWalletManager()
+
+	//未配置令牌时不拦截
+	if err := wm.requireCapability(CapabilityBroadcast); err != nil {
+		t.Errorf("operation should be permitted without token, err: %v", err)
+	}
+
+	wm.Config.OperationToken = "secret"
+
+	if _, err := wm.SendRawTransaction("00"); err == nil {
+		t.Errorf("broadcast should not be permitted without grant")
+	}
+	if err := wm.Blockscanner.SetRescanBlockHeight(100); err == nil {
+		t.Errorf("rescan should not be permitted without grant")
+	}
+
+	if err := wm.GrantCapability("wrong", CapabilityAll); err == nil {
+		t.Errorf("grant with wrong token should fail")
+	}
+
+	if err := wm.GrantCapability("secret", CapabilityRescan|CapabilityDeleteUnscan); err != nil {
+		t.Errorf("GrantCapability failed unexpected error: %v\n", err)
+		return
+	}
+	if err := wm.requireCapability(CapabilityRescan); err != nil {
+		t.Errorf("rescan should be permitted after grant, err: %v", err)
+	}
+	if err := wm.requireCapability(CapabilityBroadcast); err == nil {
+		t.Errorf("broadcast should not be permitted without grant")
+	}
+
+	wm.RevokeCapability(CapabilityRescan)
+	if err := wm.requireCapability(CapabilityRescan); err == nil {
+		t.Errorf("rescan should not be permitted after revoke")
+	}
+}
+
+func TestWalletManager_GuardDestructiveOperations(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.OperationToken = "secret"
+
+	if err := wm.Blockscanner.SetLocalBlockHead(100, "", false); err == nil {
+		t.Errorf("set local block head should not be permitted without grant")
+	}
+	if err := wm.DeleteLocalDataAboveHeight(100); err == nil {
+		t.Errorf("delete local data should not be permitted without grant")
+	}
+	if err := wm.DeleteUnscanRecord(100); err == nil {
+		t.Errorf("delete unscan record should not be permitted without grant")
+	}
+	if _, err := wm.PurgeUnscanRecords(time.Now()); err == nil {
+		t.Errorf("purge unscan records should not be permitted without grant")
+	}
+	if err := wm.RebuildWalletUnspent("W1"); err == nil {
+		t.Errorf("rebuild unspent should not be permitted without grant")
+	}
+
+	//只授予重扫权限时，清除本地数据仍被拦截
+	wm.GrantCapability("secret", CapabilityRescan)
+	err := wm.Blockscanner.SetLocalBlockHead(100, "", true)
+	if openwallet.ConvertError(err).Code() != ErrOperationNotPermitted {
+		t.Errorf("purge local block head should require delete local capability, err: %v", err)
+	}
+}
+
+func TestWalletManager_OperationHandle(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.OperationToken = "secret"
+
+	//管理者的授权不会扩散到其他句柄
+	wm.GrantCapability("secret", CapabilityAll)
+
+	monitor := wm.NewOperationHandle()
+	if _, err := monitor.SendRawTransaction("00"); openwallet.ConvertError(err).Code() != ErrOperationNotPermitted {
+		t.Errorf("broadcast should not be permitted on ungranted handle, err: %v", err)
+	}
+	if err := monitor.DeleteLocalDataAboveHeight(100); openwallet.ConvertError(err).Code() != ErrOperationNotPermitted {
+		t.Errorf("delete local data should not be permitted on ungranted handle, err: %v", err)
+	}
+
+	operator := wm.NewOperationHandle()
+	if err := operator.Grant("wrong", CapabilityDeleteLocal); err == nil {
+		t.Errorf("grant with wrong token should fail")
+	}
+	if err := operator.Grant("secret", CapabilityDeleteLocal); err != nil {
+		t.Fatalf("Grant failed unexpected error: %v", err)
+	}
+	if err := operator.DeleteLocalDataAboveHeight(100); err != nil {
+		t.Errorf("delete local data should be permitted after grant, err: %v", err)
+	}
+	if err := monitor.DeleteLocalDataAboveHeight(100); err == nil {
+		t.Errorf("grant should not affect other handles")
+	}
+
+	operator.Revoke(CapabilityDeleteLocal)
+	if err := operator.DeleteLocalDataAboveHeight(100); err == nil {
+		t.Errorf("delete local data should not be permitted after revoke")
+	}
+
+	//只读句柄不提供危险操作
+	var readOnly interface{} = wm.ReadOnly()
+	if _, ok := readOnly.(interface{ SendRawTransaction(string) (string, error) }); ok {
+		t.Errorf("read only handle should not broadcast")
+	}
+	if _, ok := readOnly.(*WalletManager); ok {
+		t.Errorf("read only handle should not expose the manager")
+	}
+}
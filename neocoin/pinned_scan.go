@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//ScanPin 一次扫描迭代固定的链快照
+type ScanPin struct {
+	Height uint64 //固定的节点最新高度
+	Hash   string //固定高度的区块hash
+}
+
+//pinChainTip 固定本次迭代的节点最新区块，未开启固定读取时返回nil，
+//节点最新高度未变化时沿用上一个区块已校验过的快照，不再重复请求
+func (bs *NEOBlockScanner) pinChainTip(pin *ScanPin, height uint64) (*ScanPin, error) {
+
+	if !bs.wm.config().PinnedScan {
+		return nil, nil
+	}
+
+	if pin != nil && pin.Height == height {
+		return pin, nil
+	}
+
+	hash, err := bs.wm.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScanPin{Height: height, Hash: hash}, nil
+}
+
+//isPinConsistent 检查节点在迭代期间是否发生重组，
+//节点只是继续出块时固定高度的hash不变，视为一致。
+//固定高度的hash不变则其之前的区块也不变，区块hash使用本次迭代已获取的，只请求一次固定高度的hash
+func (bs *NEOBlockScanner) isPinConsistent(pin *ScanPin, height uint64, hash string) bool {
+
+	if pin == nil {
+		return true
+	}
+
+	//区块必须在固定快照的链上
+	if height > pin.Height || (height == pin.Height && hash != pin.Hash) {
+		return false
+	}
+
+	tipHash, err := bs.wm.GetBlockHash(pin.Height)
+	if err != nil || tipHash != pin.Hash {
+		return false
+	}
+
+	return true
+}
+
+//needExtractAfterRestart 节点重组后重新扫描已提取过的高度，
+//区块未变化时已通知过，不再重复提取；区块已变化时先回滚原区块已通知的提取结果
+func (bs *NEOBlockScanner) needExtractAfterRestart(extracted *ScanPin, height uint64, hash string) bool {
+
+	if extracted == nil || extracted.Height != height {
+		return true
+	}
+
+	if extracted.Hash == hash {
+		bs.wm.Log.Std.Info("block height: %d has been extracted before restart, skip extracting", height)
+		return false
+	}
+
+	bs.rollbackExtractData(height)
+	return true
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestNEOBlockScanner_PinnedScan(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.RPCServerType = RPCServerCore
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	hashA, hashB, hashC := testHash("a"), testHash("b"), testHash("c")
+	tipHash, calls := hashA, 0
+	wm.SetNodeClient(mockFuncClient(func(path string, request []interface{}) (*gjson.Result, error) {
+		calls++
+		result := gjson.Parse(fmt.Sprintf(`"%s"`, tipHash))
+		return &result, nil
+	}))
+	bs := wm.Blockscanner
+
+	pin, err := bs.pinChainTip(nil, 100)
+	if err != nil || pin != nil {
+		t.Errorf("pin should be disabled by default")
+	}
+	if !bs.isPinConsistent(nil, 50, hashB) {
+		t.Errorf("nil pin should always be consistent")
+	}
+
+	wm.Config.PinnedScan = true
+	pin, err = bs.pinChainTip(nil, 100)
+	if err != nil {
+		t.Errorf("pinChainTip failed unexpected error: %v\n", err)
+		return
+	}
+	if pin.Height != 100 || pin.Hash != hashA {
+		t.Errorf("unexpected pin: %+v", pin)
+	}
+
+	//最新高度未变化时沿用快照
+	calls = 0
+	if next, _ := bs.pinChainTip(pin, 100); next != pin || calls != 0 {
+		t.Errorf("pin should be reused without requests, calls: %d", calls)
+	}
+
+	//每个区块只请求一次固定高度的hash
+	if !bs.isPinConsistent(pin, 50, hashB) || calls != 1 {
+		t.Errorf("pin should be consistent with one request, calls: %d", calls)
+	}
+	if bs.isPinConsistent(pin, 101, hashA) {
+		t.Errorf("block above pinned height should be inconsistent")
+	}
+	if bs.isPinConsistent(pin, 100, hashB) {
+		t.Errorf("block at pinned height with other hash should be inconsistent")
+	}
+
+	//节点重组
+	tipHash = hashC
+	if bs.isPinConsistent(pin, 100, hashA) {
+		t.Errorf("pin should be inconsistent after reorganization")
+	}
+}
+
+func TestNEOBlockScanner_NeedExtractAfterRestart(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.ForkRollbackNotify = true
+	bs := wm.Blockscanner
+
+	data := openwallet.NewBlockExtractData()
+	data.Transaction = &openwallet.Transaction{TxID: "tx1", Amount: "1"}
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": data})
+
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	extracted := &ScanPin{Height: 10, Hash: testHash("a")}
+	if !bs.needExtractAfterRestart(nil, 10, testHash("a")) || !bs.needExtractAfterRestart(extracted, 11, testHash("a")) {
+		t.Errorf("blocks not extracted before restart should be extracted")
+	}
+
+	//重扫相同区块不重复通知
+	if bs.needExtractAfterRestart(extracted, 10, testHash("a")) || len(observer.data) != 0 {
+		t.Errorf("same block should not be extracted again")
+	}
+
+	//区块已变化，回滚原区块的通知后重新提取
+	if !bs.needExtractAfterRestart(extracted, 10, testHash("b")) {
+		t.Errorf("changed block should be extracted")
+	}
+	if len(observer.data) != 1 || observer.data[0].Transaction.TxAction != TxActionRollback {
+		t.Errorf("orphaned extract data should be rolled back: %v", observer.notified)
+	}
+}
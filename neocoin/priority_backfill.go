@@ -0,0 +1,228 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	priorityBackfillBatch        = 20               //每次扫描任务优先扫描的区块数量上限
+	priorityBackfillAddressChunk = 50               //每次向浏览器查询交易历史的地址数量
+	priorityBackfillReestimate   = 10 * time.Minute //优先队列为空时重新估算的间隔
+)
+
+//BackfillPriority 回填区块与观测地址的相关度，Relevance为区块内观测地址相关的交易单数量
+type BackfillPriority struct {
+	Height    uint64
+	Relevance int
+}
+
+//priorityScannedBlock 回填时已优先提取的区块，顺序扫描到该高度时不再重复提取
+type priorityScannedBlock struct {
+	Height uint64 `storm:"id"`
+	Hash   string
+	ScanAt int64
+}
+
+//backfillQueue 待优先扫描的回填区块
+type backfillQueue struct {
+	items       []*BackfillPriority
+	estimatedAt time.Time
+}
+
+//EstimateBackfillPriority 按浏览器的观测地址交易历史估算(from, to]范围内区块的相关度，
+//按相关度降序、高度升序返回，没有相关交易的区块不返回
+func (bs *NEOBlockScanner) EstimateBackfillPriority(from, to uint64) ([]*BackfillPriority, error) {
+
+	if bs.wm.explorerClient() == nil {
+		return nil, errors.New("explorer API is not setup")
+	}
+
+	bs.Mu.RLock()
+	addresses := make([]string, 0, len(bs.watchAddresses))
+	for a := range bs.watchAddresses {
+		addresses = append(addresses, a)
+	}
+	bs.Mu.RUnlock()
+	sort.Strings(addresses)
+
+	relevance := make(map[uint64]int)
+	for begin := 0; begin < len(addresses); begin += priorityBackfillAddressChunk {
+		end := begin + priorityBackfillAddressChunk
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		//浏览器从最新的交易单开始返回，早于回填范围后停止拉取
+		items, _, err := bs.wm.collectAddrTxsByExplorer(addresses[begin:end], func(items []*explorerTxItem) bool {
+			last := items[len(items)-1].tx.BlockHeight
+			return last > 0 && last <= from
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			if h := item.tx.BlockHeight; h > from && h <= to {
+				relevance[h]++
+			}
+		}
+	}
+
+	list := make([]*BackfillPriority, 0, len(relevance))
+	for h, n := range relevance {
+		list = append(list, &BackfillPriority{Height: h, Relevance: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Relevance != list[j].Relevance {
+			return list[i].Relevance > list[j].Relevance
+		}
+		return list[i].Height < list[j].Height
+	})
+
+	return list, nil
+}
+
+//priorityBackfill 本地高度落后节点较多时，先扫描观测地址相关的区块，新用户的充值不必等待全部回填完成
+func (bs *NEOBlockScanner) priorityBackfill(localHeight uint64) {
+
+	//浏览器模式、未花输出历史和严格顺序通知依赖按高度顺序提取，优先扫描会让高处区块先于低处区块通知
+	cfg := bs.wm.config()
+	if !cfg.PriorityBackfill || cfg.ExplorerMode || cfg.UTXOHistory || cfg.StrictNotifyOrder {
+		return
+	}
+
+	maxHeight, err := bs.wm.GetBlockHeight()
+	if err != nil || maxHeight < localHeight+cfg.PriorityBackfillMinLag {
+		return
+	}
+
+	if bs.backfill == nil {
+		bs.backfill = &backfillQueue{}
+	}
+	queue := bs.backfill
+
+	if len(queue.items) == 0 {
+		if time.Since(queue.estimatedAt) < priorityBackfillReestimate {
+			return
+		}
+		queue.estimatedAt = time.Now()
+		queue.items, err = bs.EstimateBackfillPriority(localHeight, maxHeight)
+		if err != nil {
+			bs.wm.Log.Std.Warning("estimate backfill priority failed, unexpected error: %v", err)
+			return
+		}
+		bs.wm.Log.Std.Info("backfill priority estimated %d blocks relevant to watched addresses", len(queue.items))
+	}
+
+	for scanned := 0; scanned < priorityBackfillBatch && len(queue.items) > 0; {
+
+		if !bs.Scanning {
+			return
+		}
+
+		next := queue.items[0]
+		queue.items = queue.items[1:]
+
+		//顺序扫描已追上
+		if next.Height <= localHeight {
+			continue
+		}
+		if _, ok := bs.wm.getPriorityScannedBlock(next.Height); ok {
+			continue
+		}
+
+		bs.wm.Log.Std.Info("block scanner priority backfill height: %d, relevance: %d", next.Height, next.Relevance)
+
+		block, err := bs.scanBlock(next.Height)
+		if err != nil {
+			continue
+		}
+
+		err = bs.wm.savePriorityScannedBlock(&priorityScannedBlock{Height: block.Height, Hash: block.Hash, ScanAt: time.Now().Unix()})
+		if err != nil {
+			bs.wm.Log.Std.Error("block height: %d save priority scanned block failed; unexpected error: %v", block.Height, err)
+		}
+		scanned++
+	}
+}
+
+//takePriorityScanned 顺序扫描到已优先提取的区块时删除记录，hash一致返回true不再重复提取，
+//不一致说明优先提取后发生了分叉，回滚已通知的提取结果后重新提取
+func (bs *NEOBlockScanner) takePriorityScanned(height uint64, hash string) bool {
+
+	record, ok := bs.wm.getPriorityScannedBlock(height)
+	if !ok {
+		return false
+	}
+
+	if err := bs.wm.deletePriorityScannedBlock(height); err != nil {
+		bs.wm.Log.Std.Error("block height: %d delete priority scanned block failed; unexpected error: %v", height, err)
+	}
+
+	if record.Hash == hash {
+		return true
+	}
+
+	bs.rollbackExtractData(height)
+	return false
+}
+
+func (wm *WalletManager) savePriorityScannedBlock(record *priorityScannedBlock) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(record)
+}
+
+func (wm *WalletManager) getPriorityScannedBlock(height uint64) (*priorityScannedBlock, bool) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, false
+	}
+	defer db.Close()
+
+	var record priorityScannedBlock
+	if err := db.One("Height", height, &record); err != nil {
+		return nil, false
+	}
+	return &record, true
+}
+
+func (wm *WalletManager) deletePriorityScannedBlock(height uint64) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&priorityScannedBlock{Height: height})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	return nil
+}
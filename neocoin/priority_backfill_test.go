@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNEOBlockScanner_EstimateBackfillPriority(t *testing.T) {
+	explorer := &testExplorer{blocks: make(map[string][]string)}
+	explorer.addTx(3, 0)
+	explorer.addTx(7, 0)
+	explorer.addTx(9, 0)
+	explorer.addTx(9, 1)
+	explorer.addTx(12, 0)
+	explorer.addTx(0, 0)
+
+	wm := NewWalletManager()
+	wm.SetExplorerClient(explorer)
+	bs := NewNEOBlockScanner(wm)
+	bs.SetWatchAddressFilter(testHotAddress)
+
+	list, err := bs.EstimateBackfillPriority(5, 10)
+	if err != nil {
+		t.Errorf("EstimateBackfillPriority failed unexpected error: %v", err)
+		return
+	}
+
+	expected := []BackfillPriority{{Height: 9, Relevance: 2}, {Height: 7, Relevance: 1}}
+	if len(list) != len(expected) {
+		t.Errorf("backfill priority: %v, expected: %v", list, expected)
+		return
+	}
+	for i, p := range list {
+		if *p != expected[i] {
+			t.Errorf("backfill priority %d: %v, expected: %v", i, *p, expected[i])
+		}
+	}
+}
+
+func TestNEOBlockScanner_TakePriorityScanned(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	bs := NewNEOBlockScanner(wm)
+
+	wm.savePriorityScannedBlock(&priorityScannedBlock{Height: 10, Hash: testHash("10")})
+	if !bs.takePriorityScanned(10, testHash("10")) {
+		t.Errorf("block 10 should be priority scanned")
+	}
+	if bs.takePriorityScanned(10, testHash("10")) {
+		t.Errorf("priority scanned record should be deleted")
+	}
+
+	//优先提取后分叉，需要重新提取
+	wm.savePriorityScannedBlock(&priorityScannedBlock{Height: 11, Hash: testHash("11")})
+	if bs.takePriorityScanned(11, testHash("fork-11")) {
+		t.Errorf("forked block 11 should be extracted again")
+	}
+}
+
+func TestNEOBlockScanner_PriorityBackfillStrictOrder(t *testing.T) {
+	calls := 0
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		calls++
+		return 5000
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.SetExplorerClient(&testExplorer{blocks: make(map[string][]string)})
+	wm.Config.PriorityBackfill = true
+	wm.Config.StrictNotifyOrder = true
+	bs := NewNEOBlockScanner(wm)
+	bs.Scanning = true
+
+	//严格顺序通知时不优先扫描
+	bs.priorityBackfill(10)
+	if calls != 0 || bs.backfill != nil {
+		t.Errorf("priority backfill should be skipped when strict notify order is on")
+	}
+
+	wm.Config.StrictNotifyOrder = false
+	bs.priorityBackfill(10)
+	if calls == 0 || bs.backfill == nil {
+		t.Errorf("priority backfill should run when strict notify order is off")
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+
+	"github.com/blocktree/openwallet/common/file"
+)
+
+const (
+	ProfilePhaseFetchBlock = "fetch_block" //获取区块
+	ProfilePhaseExtract    = "extract"     //提取交易
+	ProfilePhaseSave       = "save"        //保存区块
+)
+
+//scanProfile 按区块范围采集的性能分析
+type scanProfile struct {
+	dir        string
+	fromHeight uint64
+	toHeight   uint64
+	cpuFile    *os.File
+}
+
+//withPhaseLabel 开启性能标签时，为提取阶段打上pprof标签，阶段内创建的goroutine继承标签
+func (bs *NEOBlockScanner) withPhaseLabel(phase string, height uint64, fn func()) {
+
+	if !bs.wm.config().ProfileLabels {
+		fn()
+		return
+	}
+
+	labels := pprof.Labels("symbol", bs.wm.Symbol(), "phase", phase, "height", strconv.FormatUint(height, 10))
+	pprof.Do(context.Background(), labels, func(ctx context.Context) {
+		fn()
+	})
+}
+
+//StartProfile 设置性能分析的区块范围，扫描到fromHeight时开始采集CPU，
+//扫描完toHeight后停止采集并写入堆内存快照，文件保存在dir目录
+func (bs *NEOBlockScanner) StartProfile(dir string, fromHeight, toHeight uint64) error {
+
+	if fromHeight > toHeight {
+		return fmt.Errorf("from height: %d is greater than to height: %d", fromHeight, toHeight)
+	}
+
+	bs.profileMu.Lock()
+	defer bs.profileMu.Unlock()
+
+	if bs.profile != nil {
+		return fmt.Errorf("profile from height: %d to height: %d is running", bs.profile.fromHeight, bs.profile.toHeight)
+	}
+
+	if !file.MkdirAll(dir) {
+		return fmt.Errorf("can not create profile dir: %s", dir)
+	}
+
+	bs.profile = &scanProfile{
+		dir:        dir,
+		fromHeight: fromHeight,
+		toHeight:   toHeight,
+	}
+
+	return nil
+}
+
+//StopProfile 提前结束性能分析，已开始采集时写入CPU和堆内存快照
+func (bs *NEOBlockScanner) StopProfile() error {
+
+	bs.profileMu.Lock()
+	defer bs.profileMu.Unlock()
+
+	return bs.stopProfile()
+}
+
+//stopProfile 结束性能分析，调用者需持有profileMu
+func (bs *NEOBlockScanner) stopProfile() error {
+
+	p := bs.profile
+	if p == nil {
+		return nil
+	}
+	bs.profile = nil
+
+	if p.cpuFile == nil {
+		return nil
+	}
+
+	pprof.StopCPUProfile()
+	p.cpuFile.Close()
+
+	heapFile, err := os.Create(filepath.Join(p.dir, fmt.Sprintf("heap_%d_%d.pprof", p.fromHeight, p.toHeight)))
+	if err != nil {
+		return err
+	}
+	defer heapFile.Close()
+
+	err = pprof.WriteHeapProfile(heapFile)
+	if err != nil {
+		return err
+	}
+
+	bs.wm.Log.Std.Info("block scanner profile from height: %d to height: %d saved in %s", p.fromHeight, p.toHeight, p.dir)
+
+	return nil
+}
+
+//profileBeforeBlock 扫描区块前，到达范围起点时开始采集CPU
+func (bs *NEOBlockScanner) profileBeforeBlock(height uint64) {
+
+	bs.profileMu.Lock()
+	defer bs.profileMu.Unlock()
+
+	p := bs.profile
+	if p == nil || p.cpuFile != nil || height < p.fromHeight || height > p.toHeight {
+		return
+	}
+
+	cpuFile, err := os.Create(filepath.Join(p.dir, fmt.Sprintf("cpu_%d_%d.pprof", p.fromHeight, p.toHeight)))
+	if err != nil {
+		bs.wm.Log.Std.Error("block scanner create cpu profile failed; unexpected error: %v", err)
+		bs.profile = nil
+		return
+	}
+
+	err = pprof.StartCPUProfile(cpuFile)
+	if err != nil {
+		bs.wm.Log.Std.Error("block scanner start cpu profile failed; unexpected error: %v", err)
+		cpuFile.Close()
+		bs.profile = nil
+		return
+	}
+
+	p.cpuFile = cpuFile
+}
+
+//profileAfterBlock 扫描区块后，到达范围终点时停止采集
+func (bs *NEOBlockScanner) profileAfterBlock(height uint64) {
+
+	bs.profileMu.Lock()
+	defer bs.profileMu.Unlock()
+
+	p := bs.profile
+	if p == nil || p.cpuFile == nil || height < p.toHeight {
+		return
+	}
+
+	err := bs.stopProfile()
+	if err != nil {
+		bs.wm.Log.Std.Error("block scanner stop profile failed; unexpected error: %v", err)
+	}
+}
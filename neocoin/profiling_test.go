@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNEOBlockScanner_Profile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "neo-profile")
+	defer os.RemoveAll(dir)
+
+	wm := NewWalletManager()
+	bs := wm.Blockscanner
+
+	err := bs.StartProfile(dir, 10, 12)
+	if err != nil {
+		t.Errorf("StartProfile failed unexpected error: %v\n", err)
+		return
+	}
+	if err = bs.StartProfile(dir, 10, 12); err == nil {
+		t.Errorf("profile should not start twice")
+	}
+
+	for height := uint64(9); height <= 13; height++ {
+		bs.profileBeforeBlock(height)
+		bs.profileAfterBlock(height)
+	}
+
+	for _, name := range []string{"cpu_10_12.pprof", "heap_10_12.pprof"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("profile file: %s should exist", name)
+		}
+	}
+
+	//未到达范围时提前结束不产生文件
+	bs.StartProfile(dir, 100, 200)
+	if err = bs.StopProfile(); err != nil {
+		t.Errorf("StopProfile failed unexpected error: %v\n", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cpu_100_200.pprof")); err == nil {
+		t.Errorf("profile should not be captured before range")
+	}
+}
+
+func TestNEOBlockScanner_PhaseLabel(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.ProfileLabels = true
+
+	phase := ""
+	wm.Blockscanner.withPhaseLabel(ProfilePhaseExtract, 10, func() {
+		phase = ProfilePhaseExtract
+	})
+	if phase != ProfilePhaseExtract {
+		t.Errorf("phase function should be called")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+//公共节点模式的预设参数
+const (
+	publicNodeMaxExtractingSize = 2                      //并发的扫描线程数
+	publicNodeRequestInterval   = 200 * time.Millisecond //请求最小间隔
+	publicNodeMaxRetry          = 3                      //请求失败重试次数
+	publicNodeBatchSize         = 20                     //批量请求的数量
+	publicNodeCacheSize         = 5000                   //请求结果缓存数量
+)
+
+//rpcCache 不可变请求结果的缓存，超过容量先进先出淘汰
+type rpcCache struct {
+	mu     sync.Mutex
+	size   int
+	keys   []string
+	values map[string]*gjson.Result
+}
+
+func newRPCCache(size int) *rpcCache {
+	return &rpcCache{
+		size:   size,
+		keys:   make([]string, 0, size),
+		values: make(map[string]*gjson.Result),
+	}
+}
+
+func (c *rpcCache) get(key string) (*gjson.Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	return v, ok
+}
+
+func (c *rpcCache) set(key string, value *gjson.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exist := c.values[key]; exist {
+		return
+	}
+	if len(c.keys) >= c.size {
+		delete(c.values, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.keys = append(c.keys, key)
+	c.values[key] = value
+}
+
+//rpcCacheKey 可缓存请求的key，只缓存交易单和按hash查询的区块
+func rpcCacheKey(path string, request []interface{}) (string, bool) {
+	if len(request) == 0 {
+		return "", false
+	}
+	switch path {
+	case "getrawtransaction":
+		//只缓存详细格式的交易单，true与1结果一致
+		if len(request) < 2 {
+			return "", false
+		}
+	case "getblock":
+		if _, ok := request[0].(string); !ok {
+			return "", false
+		}
+		return fmt.Sprintf("%s_%v", path, request), true
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("%s_%v", path, request[0]), true
+}
+
+//isFinalRPCResult 结果是否不再变化，未上链的交易单之后会变化，不缓存
+func isFinalRPCResult(path string, result *gjson.Result) bool {
+	if path == "getrawtransaction" {
+		return len(result.Get("blockhash").String()) > 0
+	}
+	return true
+}
+
+//applyPublicNodePreset 应用公共节点模式预设：低并发、限速、重试退避、缓存
+func (wm *WalletManager) applyPublicNodePreset() {
+
+	if !wm.config().PublicNodeMode {
+		return
+	}
+
+	//注入的其他客户端实现自行控制请求频率
+	if client, ok := wm.nodeClient().(*Client); ok {
+		client.SetRateLimit(publicNodeRequestInterval, publicNodeMaxRetry)
+		client.EnableCache(publicNodeCacheSize)
+	}
+
+	if wm.Blockscanner != nil {
+		wm.Blockscanner.extractingCH = make(chan struct{}, publicNodeMaxExtractingSize)
+	}
+
+	wm.Log.Std.Info("wallet manager is running in public node mode")
+}
+
+//prefetchTransactions 公共节点模式下，批量获取交易单填充缓存，减少单笔请求次数
+func (wm *WalletManager) prefetchTransactions(txids []string) {
+
+	if !wm.config().PublicNodeMode || wm.config().RPCServerType != RPCServerCore {
+		return
+	}
+
+	client, ok := wm.nodeClient().(BatchClientInterface)
+	if !ok {
+		return
+	}
+
+	for start := 0; start < len(txids); start += publicNodeBatchSize {
+		end := start + publicNodeBatchSize
+		if end > len(txids) {
+			end = len(txids)
+		}
+
+		requests := make([][]interface{}, 0)
+		for _, txid := range txids[start:end] {
+			requests = append(requests, []interface{}{txid, 1})
+		}
+
+		_, err := client.BatchCall("getrawtransaction", requests)
+		if err != nil {
+			wm.Log.Std.Info("prefetch transactions failed; unexpected error: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_CacheAndBatchCall(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		raw, _ := ioutil.ReadAll(r.Body)
+		var batch []map[string]interface{}
+		if json.Unmarshal(raw, &batch) == nil {
+			resp := make([]map[string]interface{}, 0)
+			for _, b := range batch {
+				params := b["params"].([]interface{})
+				resp = append(resp, map[string]interface{}{"id": b["id"], "result": map[string]interface{}{"txid": params[0], "blockhash": "0xabc"}})
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "1", "result": map[string]interface{}{"txid": "single"}})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "", false)
+	client.SetRateLimit(time.Millisecond, 1)
+	client.EnableCache(10)
+
+	results, err := client.BatchCall("getrawtransaction", [][]interface{}{{"0x01", 1}, {"0x02", 1}})
+	if err != nil {
+		t.Errorf("BatchCall failed unexpected error: %v\n", err)
+		return
+	}
+	if len(results) != 2 || results[1].Get("txid").String() != "0x02" {
+		t.Errorf("BatchCall results: %v", results)
+	}
+
+	result, err := client.Call("getrawtransaction", []interface{}{"0x02", true})
+	if err != nil {
+		t.Errorf("Call failed unexpected error: %v\n", err)
+		return
+	}
+	if result.Get("txid").String() != "0x02" || requests != 1 {
+		t.Errorf("Call should hit cache, txid: %s, requests: %d", result.Get("txid").String(), requests)
+	}
+}
+
+func TestRPCCache_Evict(t *testing.T) {
+	cache := newRPCCache(2)
+	cache.set("a", nil)
+	cache.set("b", nil)
+	cache.set("c", nil)
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("key a should be evicted")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Errorf("key c should be cached")
+	}
+}
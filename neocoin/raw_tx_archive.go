@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+//RawTxRecord 提取时保存的观测地址相关交易的原始数据，节点裁剪或浏览器下线后仍可取得交易的原始字节，
+//txid即原始数据的hash，分叉后重新打包的交易数据不变，回滚时不删除
+type RawTxRecord struct {
+	TxID        string `storm:"id"`
+	BlockHeight uint64 `storm:"index"`
+	Hex         string
+	CreateAt    int64
+}
+
+//getRawTxHexByCore 从节点获取交易的原始数据
+func (wm *WalletManager) getRawTxHexByCore(txid string) (string, error) {
+	raw, err := wm.readClient().Call("getrawtransaction", []interface{}{txid, 0})
+	if err != nil {
+		return "", err
+	}
+	return raw.String(), nil
+}
+
+//archiveRawTx 保存交易的原始数据，已保存的交易不重复获取
+func (wm *WalletManager) archiveRawTx(height uint64, txid string) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var exist RawTxRecord
+	err = db.One("TxID", txid, &exist)
+	if err == nil {
+		return nil
+	} else if err != storm.ErrNotFound {
+		return err
+	}
+
+	rawHex, err := wm.getRawTxHexByCore(txid)
+	if err != nil {
+		return err
+	}
+
+	return db.Save(&RawTxRecord{
+		TxID:        txid,
+		BlockHeight: height,
+		Hex:         rawHex,
+		CreateAt:    time.Now().Unix(),
+	})
+}
+
+//GetRawTxHex 获取交易的原始数据，启用保存时优先使用提取时保存的数据，未保存时从节点获取
+func (wm *WalletManager) GetRawTxHex(txid string) (string, error) {
+
+	if wm.config().RawTxArchive {
+		db, err := wm.openLocalDB(wm.config().BlockchainFile)
+		if err != nil {
+			return "", err
+		}
+
+		var record RawTxRecord
+		err = db.One("TxID", txid, &record)
+		db.Close()
+		if err == nil {
+			return record.Hex, nil
+		} else if err != storm.ErrNotFound {
+			return "", err
+		}
+	}
+
+	if wm.config().RPCServerType == RPCServerExplorer {
+		return "", fmt.Errorf("raw transaction: %s is not archived", txid)
+	}
+
+	return wm.getRawTxHexByCore(txid)
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalletManager_GetRawTxHex(t *testing.T) {
+	const rawHex = "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000"
+	txid := testHash("tx")
+
+	calls, pruned := 0, false
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getrawtransaction" || params[0] != txid || params[1] != float64(0) {
+			return nil
+		}
+		calls++
+		if pruned {
+			return nil
+		}
+		return rawHex
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.RawTxArchive = true
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	if err := wm.archiveRawTx(100, txid); err != nil {
+		t.Errorf("archiveRawTx failed unexpected error: %v\n", err)
+		return
+	}
+
+	//已保存的交易不重复获取
+	wm.archiveRawTx(100, txid)
+	if calls != 1 {
+		t.Errorf("archived raw transaction should not be fetched again, calls: %d", calls)
+	}
+
+	//节点裁剪后仍可取得原始数据
+	pruned = true
+	hex, err := wm.GetRawTxHex(txid)
+	if err != nil || hex != rawHex {
+		t.Errorf("GetRawTxHex = %s, %v", hex, err)
+	}
+
+	//浏览器模式未保存的交易无法获取
+	wm.Config.RPCServerType = RPCServerExplorer
+	if _, err := wm.GetRawTxHex(testHash("other")); err == nil {
+		t.Errorf("unarchived raw transaction should fail in explorer mode")
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/blocktree/openwallet/timer"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	RebalanceSweep = "sweep" //热钱包超出目标留存，归集到冷钱包
+	RebalanceTopUp = "topUp" //热钱包低于目标留存，由冷钱包补充
+)
+
+//RebalancePolicy 冷热钱包分离的资金调度策略
+type RebalancePolicy struct {
+	AccountID     string          //热钱包资产账户
+	TargetFloat   decimal.Decimal //热钱包目标留存的NEO
+	Tolerance     decimal.Decimal //偏离目标留存不超过该值时不调度
+	ColdAddresses []string        //冷钱包地址
+}
+
+//RebalanceAction 一笔调度交易
+type RebalanceAction struct {
+	Type   string
+	From   []string //归集时为热钱包地址，补充时为冷钱包地址
+	To     string
+	Amount string
+	RawTx  *openwallet.RawTransaction //归集交易单，按正常流程签名和广播
+	RawHex string                     //补充交易单的未签名数据，由冷钱包离线签名
+}
+
+//RebalancePlan 调度计划，试运行时只计算金额，不构建交易单
+type RebalancePlan struct {
+	AccountID   string
+	HotBalance  string
+	ColdBalance string
+	TargetFloat string
+	DryRun      bool
+	Actions     []*RebalanceAction
+	CreateAt    int64
+}
+
+//String 调度计划摘要，供运维人员审批
+func (plan *RebalancePlan) String() string {
+	lines := []string{
+		fmt.Sprintf("rebalance account: %s, hot: %s, cold: %s, target: %s, dry run: %v",
+			plan.AccountID, plan.HotBalance, plan.ColdBalance, plan.TargetFloat, plan.DryRun),
+	}
+	if len(plan.Actions) == 0 {
+		lines = append(lines, "  no action needed")
+	}
+	for _, action := range plan.Actions {
+		lines = append(lines, fmt.Sprintf("  %s %s NEO from [%s] to %s",
+			action.Type, action.Amount, strings.Join(action.From, ", "), action.To))
+	}
+	return strings.Join(lines, "\n")
+}
+
+//RebalanceHandler 定时调度的结果处理
+type RebalanceHandler func(plan *RebalancePlan, err error)
+
+//unspentNEOAmount 地址未花中的NEO数量
+func unspentNEOAmount(u *UnspentBalance) decimal.Decimal {
+	if u == nil || u.NEOUnspent == nil {
+		return decimal.Zero
+	}
+	amount, _ := decimal.NewFromString(u.NEOUnspent.Amount)
+	return amount
+}
+
+//PlanRebalance 按策略计算热钱包与目标留存的差额，给出归集或补充交易
+//NEO不可分割，调度金额向下取整；非试运行时构建交易单
+func (wm *WalletManager) PlanRebalance(wrapper openwallet.WalletDAI, policy *RebalancePolicy, dryRun bool) (*RebalancePlan, error) {
+
+	if len(policy.ColdAddresses) == 0 {
+		return nil, fmt.Errorf("cold addresses is empty")
+	}
+
+	account, err := wrapper.GetAssetsAccountInfo(policy.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := wrapper.GetAddressList(0, -1, "AccountID", policy.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, wm.errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", policy.AccountID)
+	}
+
+	hotAddrs := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		hotAddrs = append(hotAddrs, a.Address)
+	}
+
+	hotUnspents, err := wm.ListUnspent(0, hotAddrs...)
+	if err != nil {
+		return nil, err
+	}
+	coldUnspents, err := wm.ListUnspent(0, policy.ColdAddresses...)
+	if err != nil {
+		return nil, err
+	}
+
+	hotBalance, coldBalance := decimal.Zero, decimal.Zero
+	for _, u := range hotUnspents {
+		hotBalance = hotBalance.Add(unspentNEOAmount(u))
+	}
+	for _, u := range coldUnspents {
+		coldBalance = coldBalance.Add(unspentNEOAmount(u))
+	}
+
+	plan := &RebalancePlan{
+		AccountID:   policy.AccountID,
+		HotBalance:  hotBalance.String(),
+		ColdBalance: coldBalance.String(),
+		TargetFloat: policy.TargetFloat.String(),
+		DryRun:      dryRun,
+		Actions:     make([]*RebalanceAction, 0),
+		CreateAt:    time.Now().Unix(),
+	}
+
+	diff := hotBalance.Sub(policy.TargetFloat)
+	if diff.Abs().LessThanOrEqual(policy.Tolerance) {
+		return plan, nil
+	}
+
+	var action *RebalanceAction
+	if diff.GreaterThan(decimal.Zero) {
+		action, err = wm.planSweep(wrapper, account, hotAddrs, coldUnspents, policy, diff.Truncate(0), dryRun)
+	} else {
+		action, err = wm.planTopUp(hotAddrs[0], coldUnspents, diff.Neg().Truncate(0), dryRun)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if action != nil {
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	return plan, nil
+}
+
+//planSweep 热钱包超出部分归集到余额最少的冷钱包地址
+func (wm *WalletManager) planSweep(wrapper openwallet.WalletDAI, account *openwallet.AssetsAccount, hotAddrs []string, coldUnspents []*UnspentBalance, policy *RebalancePolicy, amount decimal.Decimal, dryRun bool) (*RebalanceAction, error) {
+
+	if amount.LessThanOrEqual(decimal.Zero) {
+		return nil, nil
+	}
+
+	coldBalances := make(map[string]decimal.Decimal)
+	for _, u := range coldUnspents {
+		coldBalances[u.Address] = unspentNEOAmount(u)
+	}
+	to := policy.ColdAddresses[0]
+	for _, addr := range policy.ColdAddresses[1:] {
+		if coldBalances[addr].LessThan(coldBalances[to]) {
+			to = addr
+		}
+	}
+
+	action := &RebalanceAction{
+		Type:   RebalanceSweep,
+		From:   hotAddrs,
+		To:     to,
+		Amount: amount.String(),
+	}
+	if dryRun {
+		return action, nil
+	}
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: account,
+		To:      map[string]string{to: action.Amount},
+		FeeRate: "0",
+	}
+	if err := wm.TxDecoder.CreateRawTransaction(wrapper, rawTx); err != nil {
+		return nil, err
+	}
+	action.RawTx = rawTx
+
+	return action, nil
+}
+
+//planTopUp 由余额最多的冷钱包地址补充热钱包，构建未签名交易单供冷钱包离线签名
+func (wm *WalletManager) planTopUp(hotAddress string, coldUnspents []*UnspentBalance, amount decimal.Decimal, dryRun bool) (*RebalanceAction, error) {
+
+	if amount.LessThanOrEqual(decimal.Zero) {
+		return nil, nil
+	}
+
+	sorted := make([]*UnspentBalance, 0, len(coldUnspents))
+	for _, u := range coldUnspents {
+		if unspentNEOAmount(u).GreaterThan(decimal.Zero) {
+			sorted = append(sorted, u)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return unspentNEOAmount(sorted[i]).GreaterThan(unspentNEOAmount(sorted[j]))
+	})
+
+	var (
+		used    = make([]*UnspentBalance, 0)
+		from    = make([]string, 0)
+		balance = decimal.Zero
+	)
+	for _, u := range sorted {
+		used = append(used, u)
+		from = append(from, u.Address)
+		balance = balance.Add(unspentNEOAmount(u))
+		if balance.GreaterThanOrEqual(amount) {
+			break
+		}
+	}
+	if balance.LessThan(amount) {
+		return nil, wm.errorf(openwallet.ErrInsufficientBalanceOfAccount, "The balance: %s is not enough! ", balance.StringFixed(wm.Decimal()))
+	}
+
+	action := &RebalanceAction{
+		Type:   RebalanceTopUp,
+		From:   from,
+		To:     hotAddress,
+		Amount: amount.String(),
+	}
+	if dryRun {
+		return action, nil
+	}
+
+	vins := make([]neoTransaction.Vin, 0)
+	for _, u := range used {
+		for _, tx := range *u.NEOUnspent.UnspentTxs {
+			vins = append(vins, neoTransaction.Vin{TxID: tx.TxID, Vout: uint16(tx.N)})
+		}
+	}
+
+	vouts := []neoTransaction.Vout{
+		{Asset: wm.config().NEOAssetID, Address: hotAddress, Value: uint64(amount.Shift(wm.Decimal()).IntPart())},
+	}
+	//找零回到第一个冷钱包地址
+	if change := balance.Sub(amount); change.GreaterThan(decimal.Zero) {
+		vouts = append(vouts, neoTransaction.Vout{Asset: wm.config().NEOAssetID, Address: from[0], Value: uint64(change.Shift(wm.Decimal()).IntPart())})
+	}
+
+	rawHex, err := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction, vins, vouts, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create transaction failed, unexpected error: %v", err)
+	}
+	action.RawHex = rawHex
+
+	return action, nil
+}
+
+//StartRebalance 按间隔定时执行调度计划，返回的定时器由调用方停止
+func (wm *WalletManager) StartRebalance(wrapper openwallet.WalletDAI, policy *RebalancePolicy, interval time.Duration, dryRun bool, handler RebalanceHandler) *timer.TaskTimer {
+	task := timer.NewTask(interval, func() {
+		plan, err := wm.PlanRebalance(wrapper, policy, dryRun)
+		if err != nil {
+			wm.Log.Std.Error("rebalance account: %s failed, unexpected error: %v", policy.AccountID, err)
+		} else {
+			wm.Log.Std.Info("%s", plan)
+		}
+		if handler != nil {
+			handler(plan, err)
+		}
+	})
+	task.Start()
+	return task
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	testHotAddress   = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	testColdAddress1 = "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"
+	testColdAddress2 = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+)
+
+//testRebalanceWallet 只有一个热钱包地址的资产账户
+type testRebalanceWallet struct {
+	openwallet.WalletDAIBase
+}
+
+func (w *testRebalanceWallet) GetAssetsAccountInfo(accountID string) (*openwallet.AssetsAccount, error) {
+	return &openwallet.AssetsAccount{AccountID: accountID, Symbol: Symbol}, nil
+}
+
+func (w *testRebalanceWallet) GetAddressList(offset, limit int, cols ...interface{}) ([]*openwallet.Address, error) {
+	for i := 0; i+1 < len(cols); i += 2 {
+		if cols[i] == "Address" && cols[i+1] != testHotAddress {
+			return nil, nil
+		}
+	}
+	return []*openwallet.Address{{AccountID: "hot", Address: testHotAddress}}, nil
+}
+
+func (w *testRebalanceWallet) GetAddress(address string) (*openwallet.Address, error) {
+	return &openwallet.Address{AccountID: "hot", Address: address}, nil
+}
+
+func TestWalletManager_PlanRebalance(t *testing.T) {
+	balances := map[string]int{testHotAddress: 100, testColdAddress1: 500, testColdAddress2: 50}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getunspents" {
+			return nil
+		}
+		address := params[0].(string)
+		return map[string]interface{}{
+			"address": address,
+			"balance": []interface{}{
+				map[string]interface{}{
+					"unspent":      []interface{}{map[string]interface{}{"txid": testHash(address)[2:], "n": 0, "value": balances[address]}},
+					"asset_hash":   "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+					"asset":        "NEO",
+					"asset_symbol": "NEO",
+					"amount":       balances[address],
+				},
+			},
+		}
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wrapper := &testRebalanceWallet{}
+	policy := &RebalancePolicy{
+		AccountID:     "hot",
+		TargetFloat:   decimal.New(60, 0),
+		Tolerance:     decimal.New(5, 0),
+		ColdAddresses: []string{testColdAddress1, testColdAddress2},
+	}
+
+	//热钱包超出目标，归集到余额最少的冷钱包
+	plan, err := wm.PlanRebalance(wrapper, policy, true)
+	if err != nil {
+		t.Errorf("PlanRebalance failed unexpected error: %v\n", err)
+		return
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].Type != RebalanceSweep || plan.Actions[0].Amount != "40" || plan.Actions[0].To != testColdAddress2 {
+		t.Errorf("unexpected sweep plan:\n%s", plan)
+	}
+	if plan.Actions[0].RawTx != nil {
+		t.Errorf("dry run should not build transaction")
+	}
+
+	plan, err = wm.PlanRebalance(wrapper, policy, false)
+	if err != nil || plan.Actions[0].RawTx == nil || !plan.Actions[0].RawTx.IsBuilt {
+		t.Errorf("sweep transaction should be built, unexpected error: %v", err)
+	}
+
+	//偏离不超过容忍值时不调度
+	policy.TargetFloat = decimal.New(97, 0)
+	plan, _ = wm.PlanRebalance(wrapper, policy, true)
+	if len(plan.Actions) != 0 || !strings.Contains(plan.String(), "no action needed") {
+		t.Errorf("unexpected plan within tolerance:\n%s", plan)
+	}
+
+	//热钱包低于目标，由余额最多的冷钱包补充
+	policy.TargetFloat = decimal.New(300, 0)
+	plan, err = wm.PlanRebalance(wrapper, policy, false)
+	if err != nil {
+		t.Errorf("PlanRebalance failed unexpected error: %v\n", err)
+		return
+	}
+	action := plan.Actions[0]
+	if action.Type != RebalanceTopUp || action.Amount != "200" || action.From[0] != testColdAddress1 || action.To != testHotAddress || len(action.RawHex) == 0 {
+		t.Errorf("unexpected top up plan:\n%s", plan)
+	}
+
+	//冷钱包余额不足
+	policy.TargetFloat = decimal.New(1000, 0)
+	if _, err := wm.PlanRebalance(wrapper, policy, true); err == nil {
+		t.Errorf("top up should fail when cold balance is not enough")
+	}
+}
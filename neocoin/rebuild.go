@@ -0,0 +1,261 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	rebuildCheckpointID    = "rebuild"
+	rebuildCheckpointEvery = 10 //每重建多少个区块保存一次进度
+)
+
+//RebuildCheckpoint 重建本地数据的进度，中断后从NextHeight继续
+type RebuildCheckpoint struct {
+	ID         string `storm:"id"`
+	FromHeight uint64
+	ToHeight   uint64 //开始重建时的已扫描高度
+	NextHeight uint64 //下一个待重建的高度
+	TxCount    int    //已重建的交易数
+	Done       bool
+	StartAt    int64
+	UpdateAt   int64
+}
+
+//Progress 重建进度，0到1
+func (c *RebuildCheckpoint) Progress() float64 {
+	if c.Done || c.ToHeight < c.FromHeight {
+		return 1
+	}
+	return float64(c.NextHeight-c.FromHeight) / float64(c.ToHeight-c.FromHeight+1)
+}
+
+//GetRebuildCheckpoint 获取重建本地数据的进度，没有重建记录返回nil
+func (wm *WalletManager) GetRebuildCheckpoint() (*RebuildCheckpoint, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var c RebuildCheckpoint
+	err = db.One("ID", rebuildCheckpointID, &c)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (wm *WalletManager) saveRebuildCheckpoint(c *RebuildCheckpoint) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	c.ID = rebuildCheckpointID
+	c.UpdateAt = time.Now().Unix()
+	return db.Save(c)
+}
+
+//clearLocalDataFromHeight 清除高度不小于fromHeight的未花输出历史、交易索引、提取结果和检查点
+func (wm *WalletManager) clearLocalDataFromHeight(fromHeight uint64) error {
+
+	err := wm.DeleteUTXOHistoryAboveHeight(fromHeight - 1)
+	if err != nil {
+		return err
+	}
+
+	err = wm.deleteChainCheckpointsFromHeight(fromHeight)
+	if err != nil {
+		return err
+	}
+
+	err = wm.TxIndex().DeleteAboveHeight(fromHeight - 1)
+	if err != nil {
+		return err
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Select(q.Gte("BlockHeight", fromHeight)).Delete(&ExtractDataRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+//RebuildLocalData 从指定高度重建未花输出历史、交易索引和地址交易记录（提取结果），用于修复损坏的本地索引，
+//重建到开始时的已扫描高度，不通知观察者。进度定期保存，中断后以相同起始高度再次调用从断点继续
+func (bs *NEOBlockScanner) RebuildLocalData(fromHeight uint64) error {
+
+	if fromHeight == 0 {
+		return bs.wm.errorf(ErrBlockHeightInvalid, "block height must greater than 0")
+	}
+
+	if err := bs.wm.requireCapability(CapabilityRescan); err != nil {
+		return err
+	}
+
+	checkpoint, err := bs.wm.GetRebuildCheckpoint()
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "get rebuild checkpoint failed, unexpected error: %v", err)
+	}
+
+	if checkpoint != nil && !checkpoint.Done && checkpoint.FromHeight == fromHeight {
+		bs.wm.Log.Std.Info("resume rebuilding local data from height: %d, next height: %d, to height: %d", checkpoint.FromHeight, checkpoint.NextHeight, checkpoint.ToHeight)
+	} else {
+		toHeight, _ := bs.wm.GetLocalNewBlock()
+		if fromHeight > toHeight {
+			return bs.wm.errorf(ErrBlockHeightInvalid, "block height: %d is above scanned height: %d", fromHeight, toHeight)
+		}
+
+		err = bs.wm.clearLocalDataFromHeight(fromHeight)
+		if err != nil {
+			return bs.wm.errorf(ErrLocalDBOperateFailed, "clear local data from height: %d failed, unexpected error: %v", fromHeight, err)
+		}
+
+		checkpoint = &RebuildCheckpoint{
+			FromHeight: fromHeight,
+			ToHeight:   toHeight,
+			NextHeight: fromHeight,
+			StartAt:    time.Now().Unix(),
+		}
+		err = bs.wm.saveRebuildCheckpoint(checkpoint)
+		if err != nil {
+			return bs.wm.errorf(ErrLocalDBOperateFailed, "save rebuild checkpoint failed, unexpected error: %v", err)
+		}
+		bs.wm.Log.Std.Info("start rebuilding local data from height: %d to height: %d", fromHeight, toHeight)
+	}
+
+	//未设置扫描地址时只重建浏览器模式的交易索引
+	scanAddressFunc := bs.filterScanAddressFunc(bs.ScanAddressFunc)
+	if scanAddressFunc == nil {
+		scanAddressFunc = func(address string) (string, bool) {
+			return "", false
+		}
+	}
+
+	for checkpoint.NextHeight <= checkpoint.ToHeight {
+
+		height := checkpoint.NextHeight
+		txCount, err := bs.rebuildBlock(height, scanAddressFunc)
+		if err != nil {
+			//保存已完成的进度，下次从失败的高度继续
+			bs.wm.saveRebuildCheckpoint(checkpoint)
+			return bs.wm.errorf(ErrLocalDBOperateFailed, "rebuild local data on height: %d failed, unexpected error: %v", height, err)
+		}
+
+		checkpoint.NextHeight = height + 1
+		checkpoint.TxCount += txCount
+
+		if (height-checkpoint.FromHeight+1)%rebuildCheckpointEvery == 0 {
+			err = bs.wm.saveRebuildCheckpoint(checkpoint)
+			if err != nil {
+				return bs.wm.errorf(ErrLocalDBOperateFailed, "save rebuild checkpoint failed, unexpected error: %v", err)
+			}
+			bs.wm.Log.Std.Info("rebuilding local data, height: %d/%d, progress: %.2f%%", height, checkpoint.ToHeight, checkpoint.Progress()*100)
+		}
+	}
+
+	checkpoint.Done = true
+	err = bs.wm.saveRebuildCheckpoint(checkpoint)
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "save rebuild checkpoint failed, unexpected error: %v", err)
+	}
+
+	bs.wm.Log.Std.Notice("rebuild local data from height: %d to height: %d finished, %d transactions", checkpoint.FromHeight, checkpoint.ToHeight, checkpoint.TxCount)
+
+	return nil
+}
+
+//rebuildBlock 重新提取一个区块的交易，保存提取结果和索引，返回交易数
+//重建的高度可能已被扫描器重扫，先清除该高度的数据，重复重建结果一致
+func (bs *NEOBlockScanner) rebuildBlock(height uint64, scanAddressFunc openwallet.BlockScanAddressFunc) (int, error) {
+
+	//与扫描周期互斥，避免同一高度同时写入
+	bs.wm.scanCycleMu.Lock()
+	defer bs.wm.scanCycleMu.Unlock()
+
+	hash, err := bs.wm.GetBlockHash(height)
+	if err != nil {
+		return 0, err
+	}
+
+	block, err := bs.wm.GetBlock(hash, BlockDetailTxIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	err = bs.wm.DeleteExtractData(height)
+	if err != nil {
+		return 0, err
+	}
+
+	trxs := make([]*Transaction, 0, len(block.Tx))
+	for _, txid := range block.Tx {
+		result := bs.ExtractTransaction(height, block.Hash, txid, scanAddressFunc)
+		if !result.Success {
+			return 0, fmt.Errorf("extract transaction: %s failed", txid)
+		}
+		trxs = append(trxs, result.trx)
+
+		err = bs.wm.SaveExtractData(height, result.extractData)
+		if err != nil {
+			return 0, err
+		}
+		err = bs.wm.SaveExtractData(height, result.extractOmniData)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if bs.wm.config().ExplorerMode {
+		txs := make([]*IndexedTx, 0, len(trxs))
+		for i, trx := range trxs {
+			txs = append(txs, NewIndexedTx(trx, i))
+		}
+		err = bs.wm.TxIndex().SaveBlockTxs(height, txs)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if bs.wm.utxoHistoryEnabled() {
+		err = bs.saveUTXOHistory(trxs, scanAddressFunc)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(trxs), nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+func TestNEOBlockScanner_RebuildLocalData(t *testing.T) {
+	var (
+		fundTxID  = testHash("fund")
+		spendTxID = testHash("spend")
+		sender    = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		receiver  = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+		mu        sync.Mutex
+		requested []float64
+	)
+	blocks := map[string]uint64{testHash("block10"): 10, testHash("block11"): 11}
+	blockTxs := map[uint64][]interface{}{10: {fundTxID}, 11: {spendTxID}}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockhash":
+			mu.Lock()
+			requested = append(requested, params[0].(float64))
+			mu.Unlock()
+			return testHash(fmt.Sprintf("block%v", params[0]))
+		case "getblock":
+			height := blocks[params[0].(string)]
+			return map[string]interface{}{"index": height, "hash": params[0], "previousblockhash": testHash(fmt.Sprintf("block%d", height-1)), "time": 1000, "tx": blockTxs[height]}
+		case "getrawtransaction":
+			switch params[0] {
+			case fundTxID:
+				return map[string]interface{}{"txid": fundTxID, "vin": []interface{}{},
+					"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": sender}}}
+			case spendTxID:
+				return map[string]interface{}{"txid": spendTxID, "vin": []interface{}{map[string]interface{}{"txid": fundTxID, "vout": 0}},
+					"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": receiver}}}
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.ExplorerMode = true
+	wm.Config.UTXOHistory = true
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "account", address == sender
+	}
+	wm.SaveLocalNewBlock(11, testHash("block11"))
+
+	//本地索引损坏
+	if err := bs.RebuildLocalData(12); err == nil {
+		t.Errorf("height above scanned height should be rejected")
+	}
+	if tx, _ := wm.GetIndexedTx(spendTxID); tx != nil {
+		t.Errorf("index should be empty before rebuilding")
+	}
+
+	if err := bs.RebuildLocalData(10); err != nil {
+		t.Errorf("RebuildLocalData failed unexpected error: %v\n", err)
+		return
+	}
+	if tx, _ := wm.GetIndexedTx(spendTxID); tx == nil || tx.BlockHeight != 11 {
+		t.Errorf("transaction index should be rebuilt: %+v", tx)
+	}
+	if snapshot, _ := wm.GetBalanceAtHeight(sender, 11); snapshot.NEO != "0" {
+		t.Errorf("utxo history should be rebuilt: %+v", snapshot)
+	}
+	if snapshot, _ := wm.GetBalanceAtHeight(sender, 10); snapshot.NEO != "10" {
+		t.Errorf("utxo history should be rebuilt: %+v", snapshot)
+	}
+	if history, _ := wm.GetAddressHistory(sender); len(history) != 2 {
+		t.Errorf("address history should be rebuilt: %d", len(history))
+	}
+	checkpoint, _ := wm.GetRebuildCheckpoint()
+	if checkpoint == nil || !checkpoint.Done || checkpoint.TxCount != 2 || checkpoint.Progress() != 1 {
+		t.Errorf("unexpected checkpoint: %+v", checkpoint)
+	}
+
+	//中断后从断点继续，不重复重建已完成的高度
+	checkpoint.Done = false
+	checkpoint.NextHeight = 11
+	wm.saveRebuildCheckpoint(checkpoint)
+	requested = nil
+	if err := bs.RebuildLocalData(10); err != nil {
+		t.Errorf("RebuildLocalData resume failed unexpected error: %v\n", err)
+		return
+	}
+	if len(requested) != 1 || requested[0] != 11 {
+		t.Errorf("resume should continue from checkpoint, requested: %v", requested)
+	}
+	if history, _ := wm.GetAddressHistory(sender); len(history) != 2 {
+		t.Errorf("rebuilding twice should be idempotent: %d", len(history))
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//ExtractDataChange 重新提取的结果与原通知不一致的记录
+type ExtractDataChange struct {
+	Original  *ExtractDataRecord //原通知的提取结果
+	Extracted *ExtractDataRecord //重新提取的结果
+	Fields    []string           //不一致的字段
+}
+
+//ReExtractDiff 重新提取区块的结果，及与原通知的提取结果的差异
+type ReExtractDiff struct {
+	Height    uint64
+	Hash      string
+	Extracted []*ExtractDataRecord //重新提取的全部结果
+	Compared  bool                 //是否与原通知比较，以下字段只在比较时填写
+	Missing   []*ExtractDataRecord //重新提取有、原通知没有，通常是旧版本漏提取的记录
+	Removed   []*ExtractDataRecord //原通知有、重新提取没有
+	Changed   []*ExtractDataChange //两者都有但内容不一致
+	Unchanged int                  //一致的数量
+}
+
+//HasDiff 是否与原通知不一致
+func (d *ReExtractDiff) HasDiff() bool {
+	return len(d.Missing) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+//ReExtractBlock 重新提取已扫描区块的交易，不保存也不通知，compare为true时与已保存的原通知结果比较，
+//用于升级适配器后检查旧版本漏提取或提取不一致的记录，漏提取的记录可用BackfillReExtractedData补发
+func (bs *NEOBlockScanner) ReExtractBlock(height uint64, compare bool) (*ReExtractDiff, error) {
+
+	if height == 0 {
+		return nil, bs.wm.errorf(ErrBlockHeightInvalid, "block height must greater than 0")
+	}
+
+	//与保存和通知互斥，读取到的原通知结果是完整的
+	bs.wm.scanCycleMu.RLock()
+	defer bs.wm.scanCycleMu.RUnlock()
+
+	scanned, _ := bs.wm.GetLocalNewBlock()
+	if height > scanned {
+		return nil, bs.wm.errorf(ErrBlockHeightInvalid, "block height: %d is above scanned height: %d", height, scanned)
+	}
+
+	hash, err := bs.wm.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := bs.wm.GetBlock(hash, BlockDetailTxIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ReExtractDiff{
+		Height:    height,
+		Hash:      block.Hash,
+		Extracted: make([]*ExtractDataRecord, 0),
+		Compared:  compare,
+	}
+
+	scanAddressFunc := bs.filterScanAddressFunc(bs.ScanAddressFunc)
+	if scanAddressFunc == nil {
+		scanAddressFunc = func(address string) (string, bool) {
+			return "", false
+		}
+	}
+
+	for _, txid := range block.Tx {
+		result := bs.ExtractTransaction(height, block.Hash, txid, scanAddressFunc)
+		if !result.Success {
+			return nil, fmt.Errorf("extract transaction: %s failed", txid)
+		}
+		for _, extractData := range []map[string]*openwallet.TxExtractData{result.extractData, result.extractOmniData} {
+			for sourceKey, data := range extractData {
+				diff.Extracted = append(diff.Extracted, NewExtractDataRecord(height, sourceKey, data))
+			}
+		}
+	}
+	sortExtractDataRecords(diff.Extracted)
+
+	if !compare {
+		return diff, nil
+	}
+
+	originals, err := bs.wm.GetExtractData(height, height)
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "get extract data failed, unexpected error: %v", err)
+	}
+
+	delivered := make(map[string]*ExtractDataRecord, len(originals))
+	for _, r := range originals {
+		delivered[r.ID] = r
+	}
+
+	diff.Missing = make([]*ExtractDataRecord, 0)
+	diff.Removed = make([]*ExtractDataRecord, 0)
+	diff.Changed = make([]*ExtractDataChange, 0)
+	for _, r := range diff.Extracted {
+		original, ok := delivered[r.ID]
+		if !ok {
+			diff.Missing = append(diff.Missing, r)
+			continue
+		}
+		delete(delivered, r.ID)
+		if fields := diffExtractData(original.Data, r.Data); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, &ExtractDataChange{Original: original, Extracted: r, Fields: fields})
+		} else {
+			diff.Unchanged++
+		}
+	}
+	for _, r := range delivered {
+		diff.Removed = append(diff.Removed, r)
+	}
+	sortExtractDataRecords(diff.Removed)
+
+	if diff.HasDiff() {
+		bs.wm.Log.Std.Warning("re-extract block height: %d, missing: %d, removed: %d, changed: %d, unchanged: %d",
+			height, len(diff.Missing), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	}
+
+	return diff, nil
+}
+
+//BackfillReExtractedData 保存并通知重新提取时发现的漏提取记录，分配新的序号，
+//不一致和多余的记录只报告，由调用者决定如何处理
+func (bs *NEOBlockScanner) BackfillReExtractedData(diff *ReExtractDiff) (int, error) {
+
+	if diff == nil || !diff.Compared {
+		return 0, fmt.Errorf("re-extract diff is not compared with delivered extract data")
+	}
+
+	if len(diff.Missing) == 0 {
+		return 0, nil
+	}
+
+	if err := bs.wm.requireCapability(CapabilityRescan); err != nil {
+		return 0, err
+	}
+
+	bs.wm.scanCycleMu.RLock()
+	defer bs.wm.scanCycleMu.RUnlock()
+
+	//重新提取后区块被分叉替换时不补发
+	hash, err := bs.wm.GetBlockHash(diff.Height)
+	if err != nil {
+		return 0, err
+	}
+	if normalizeTxID(hash) != normalizeTxID(diff.Hash) {
+		return 0, bs.wm.errorf(ErrBlockHashMismatch, "block height: %d hash: %s is replaced by: %s, re-extract again", diff.Height, diff.Hash, hash)
+	}
+
+	batch := make([]map[string]*openwallet.TxExtractData, 0, len(diff.Missing))
+	for _, r := range diff.Missing {
+		batch = append(batch, map[string]*openwallet.TxExtractData{r.SourceKey: r.Data})
+	}
+
+	if failed := bs.newBlockExtractDataNotify(diff.Height, batch); failed > 0 {
+		return len(batch) - failed, fmt.Errorf("backfill block height: %d, %d of %d extract data notify failed", diff.Height, failed, len(batch))
+	}
+
+	bs.wm.Log.Std.Notice("backfill block height: %d, %d missing extract data", diff.Height, len(batch))
+
+	return len(batch), nil
+}
+
+//sortExtractDataRecords 按txid和sourceKey排序，比较结果稳定
+func sortExtractDataRecords(list []*ExtractDataRecord) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].TxID != list[j].TxID {
+			return list[i].TxID < list[j].TxID
+		}
+		return list[i].SourceKey < list[j].SourceKey
+	})
+}
+
+//diffExtractData 比较提取结果中影响入账的字段，返回不一致的字段名，忽略序号等通知时附加的扩展参数
+func diffExtractData(original, extracted *openwallet.TxExtractData) []string {
+
+	fields := make([]string, 0)
+	if original == nil || extracted == nil {
+		if original != extracted {
+			fields = append(fields, "data")
+		}
+		return fields
+	}
+
+	if a, b := original.Transaction, extracted.Transaction; a == nil || b == nil {
+		if a != b {
+			fields = append(fields, "transaction")
+		}
+	} else {
+		check := func(name, x, y string) {
+			if x != y {
+				fields = append(fields, "transaction."+name)
+			}
+		}
+		check("txid", a.TxID, b.TxID)
+		check("coin", a.Coin.Symbol+a.Coin.Contract.Address, b.Coin.Symbol+b.Coin.Contract.Address)
+		check("from", strings.Join(a.From, ","), strings.Join(b.From, ","))
+		check("to", strings.Join(a.To, ","), strings.Join(b.To, ","))
+		check("amount", a.Amount, b.Amount)
+		check("fees", a.Fees, b.Fees)
+		check("txType", fmt.Sprint(a.TxType), fmt.Sprint(b.TxType))
+		check("txAction", a.TxAction, b.TxAction)
+		check("blockHash", a.BlockHash, b.BlockHash)
+		check("status", a.Status, b.Status)
+	}
+
+	inputs := func(list []*openwallet.TxInput) string {
+		items := make([]string, 0, len(list))
+		for _, in := range list {
+			items = append(items, fmt.Sprintf("%s:%d:%s:%s:%s", in.SourceTxID, in.SourceIndex, in.Address, in.Coin.Symbol+in.Coin.Contract.Address, in.Amount))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	if inputs(original.TxInputs) != inputs(extracted.TxInputs) {
+		fields = append(fields, "inputs")
+	}
+
+	outputs := func(list []*openwallet.TxOutPut) string {
+		items := make([]string, 0, len(list))
+		for _, out := range list {
+			items = append(items, fmt.Sprintf("%d:%s:%s:%s", out.Index, out.Address, out.Coin.Symbol+out.Coin.Contract.Address, out.Amount))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	if outputs(original.TxOutputs) != outputs(extracted.TxOutputs) {
+		fields = append(fields, "outputs")
+	}
+
+	return fields
+}
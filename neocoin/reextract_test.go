@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_ReExtractBlock(t *testing.T) {
+	var (
+		fundTxID  = testHash("fund")
+		payTxID   = testHash("pay")
+		staleTxID = testHash("stale")
+		sender    = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		receiver  = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockhash":
+			return testHash(fmt.Sprintf("block%v", params[0]))
+		case "getblock":
+			return map[string]interface{}{"index": 10, "hash": params[0], "previousblockhash": testHash("block9"), "time": 1000, "tx": []interface{}{fundTxID, payTxID}}
+		case "getrawtransaction":
+			to := sender
+			if params[0] == payTxID {
+				to = receiver
+			}
+			return map[string]interface{}{"txid": params[0], "vin": []interface{}{},
+				"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": to}}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	wm.SaveLocalNewBlock(10, testHash("block10"))
+
+	if _, err := bs.ReExtractBlock(11, true); err == nil {
+		t.Errorf("height above scanned height should be rejected")
+	}
+
+	//旧版本只提取了发送方地址
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "account", address == sender
+	}
+	diff, err := bs.ReExtractBlock(10, false)
+	if err != nil || len(diff.Extracted) != 1 || diff.Compared {
+		t.Fatalf("ReExtractBlock = %+v, %v", diff, err)
+	}
+	for _, r := range diff.Extracted {
+		wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{r.SourceKey: r.Data})
+	}
+
+	//原通知中有重新提取没有的记录
+	stale := openwallet.NewBlockExtractData()
+	stale.Transaction = &openwallet.Transaction{TxID: staleTxID, BlockHeight: 10}
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": stale})
+
+	//新版本提取了接收方地址
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "account", address == sender || address == receiver
+	}
+	diff, err = bs.ReExtractBlock(10, true)
+	if err != nil {
+		t.Fatalf("ReExtractBlock failed, unexpected error: %v", err)
+	}
+	if !diff.HasDiff() || len(diff.Missing) != 1 || diff.Missing[0].TxID != payTxID || len(diff.Removed) != 1 || diff.Removed[0].TxID != staleTxID || diff.Unchanged != 1 {
+		t.Fatalf("unexpected diff, missing: %d, removed: %d, changed: %d, unchanged: %d", len(diff.Missing), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	}
+
+	//原通知的金额不一致
+	changed, _ := wm.GetExtractDataByTxID(fundTxID)
+	changed[0].Data.TxOutputs[0].Amount = "1"
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": changed[0].Data})
+	diff, _ = bs.ReExtractBlock(10, true)
+	if len(diff.Changed) != 1 || len(diff.Changed[0].Fields) != 1 || diff.Changed[0].Fields[0] != "outputs" {
+		t.Fatalf("amount change should be reported, got: %+v", diff.Changed)
+	}
+
+	//补发漏提取的记录，只补发漏提取的
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+	if _, err := bs.BackfillReExtractedData(&ReExtractDiff{Height: 10}); err == nil {
+		t.Errorf("backfill should require compared diff")
+	}
+	count, err := bs.BackfillReExtractedData(diff)
+	if err != nil || count != 1 || len(observer.notified) != 1 || observer.notified[0] != "account:"+payTxID {
+		t.Fatalf("BackfillReExtractedData = %d, %v, notified: %v", count, err, observer.notified)
+	}
+	if ExtractDataSequence(observer.data[0]) == 0 {
+		t.Errorf("backfilled extract data should be assigned a sequence")
+	}
+	diff, _ = bs.ReExtractBlock(10, true)
+	if len(diff.Missing) != 0 || diff.Unchanged != 1 {
+		t.Errorf("backfilled record should be delivered, missing: %d", len(diff.Missing))
+	}
+}
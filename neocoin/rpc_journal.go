@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//rpcJournalSlotSize 每条记录占用的固定字节数，按序号取模写入对应位置，文件大小固定
+	rpcJournalSlotSize = 512
+
+	RPCJournalStatusOK    = "ok"    //节点返回结果
+	RPCJournalStatusError = "error" //请求失败或节点返回错误
+)
+
+//RPCJournalEntry 节点RPC调用记录，用于事后复盘漏入账时还原节点在相关高度返回的内容
+type RPCJournalEntry struct {
+	Seq        uint64 `json:"seq"`
+	Time       int64  `json:"time"` //请求开始时间，毫秒
+	Node       string `json:"node"`
+	Method     string `json:"method"`
+	ParamsHash string `json:"paramsHash"`           //参数的hash，见RPCParamsHash
+	ResultHash string `json:"resultHash,omitempty"` //返回结果的hash，相同参数前后返回不同结果时可比较
+	Duration   int64  `json:"duration"`             //耗时，毫秒
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+//RPCParamsHash 计算RPC参数的hash，与调用记录的ParamsHash比较可找到指定参数的调用，如getblockhash的高度
+func RPCParamsHash(params []interface{}) string {
+	if params == nil {
+		params = []interface{}{}
+	}
+	raw, _ := json.Marshal(params)
+	return rpcJournalHash(raw)
+}
+
+//rpcJournalHash 截取sha256的前16字节，足够区分记录且控制单条记录的长度
+func rpcJournalHash(raw []byte) string {
+	hash := sha256.Sum256(raw)
+	return hex.EncodeToString(hash[:16])
+}
+
+//rpcJournal 固定容量的环形调用记录文件，记录满后覆盖最早的记录
+type rpcJournal struct {
+	mu     sync.Mutex
+	path   string
+	size   int
+	next   uint64 //下一条记录的序号
+	loaded bool   //是否已从文件恢复序号
+}
+
+func newRPCJournal(path string, size int) *rpcJournal {
+	return &rpcJournal{path: path, size: size}
+}
+
+//record 记录一次调用，写入失败只记录错误日志，不影响调用结果
+func (j *rpcJournal) record(node, method string, params []interface{}, start time.Time, result *gjson.Result, callErr error) {
+
+	entry := &RPCJournalEntry{
+		Time:       start.UnixNano() / int64(time.Millisecond),
+		Node:       node,
+		Method:     method,
+		ParamsHash: RPCParamsHash(params),
+		Duration:   int64(time.Since(start) / time.Millisecond),
+		Status:     RPCJournalStatusOK,
+	}
+	if callErr != nil {
+		entry.Status = RPCJournalStatusError
+		entry.Error = callErr.Error()
+	} else if result != nil {
+		entry.ResultHash = rpcJournalHash([]byte(result.Raw))
+	}
+
+	if err := j.append(entry); err != nil {
+		log.Std.Error("rpc journal append method: %s failed, unexpected error: %v", method, err)
+	}
+}
+
+//append 分配序号并写入对应的位置
+func (j *rpcJournal) append(entry *RPCJournalEntry) error {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	//首次写入时从已有记录恢复序号，容量缩小时截掉多余的位置
+	if !j.loaded {
+		entries, err := readRPCJournal(f)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Seq >= j.next {
+				j.next = e.Seq + 1
+			}
+		}
+		if j.next == 0 {
+			j.next = 1
+		}
+		if err = f.Truncate(int64(j.size) * rpcJournalSlotSize); err != nil {
+			return err
+		}
+		j.loaded = true
+	}
+
+	entry.Seq = j.next
+	slot, err := encodeRPCJournalEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteAt(slot, int64(entry.Seq%uint64(j.size))*rpcJournalSlotSize)
+	if err != nil {
+		return err
+	}
+	j.next++
+
+	return nil
+}
+
+//encodeRPCJournalEntry 编码为固定长度的一行json，过长时截断错误信息和节点地址
+func encodeRPCJournalEntry(entry *RPCJournalEntry) ([]byte, error) {
+	for {
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) < rpcJournalSlotSize {
+			slot := bytes.Repeat([]byte(" "), rpcJournalSlotSize)
+			copy(slot, raw)
+			slot[rpcJournalSlotSize-1] = '\n'
+			return slot, nil
+		}
+		over := len(raw) - rpcJournalSlotSize + 1
+		switch {
+		case len(entry.Error) > over:
+			entry.Error = entry.Error[:len(entry.Error)-over]
+		case len(entry.Node) > over:
+			entry.Node = entry.Node[:len(entry.Node)-over]
+		default:
+			entry.Error = ""
+			entry.Node = ""
+			if len(entry.Method) > over {
+				entry.Method = entry.Method[:len(entry.Method)-over]
+			}
+		}
+	}
+}
+
+//readRPCJournal 读取全部记录并按序号排序，跳过未写入或损坏的位置
+func readRPCJournal(r io.Reader) ([]*RPCJournalEntry, error) {
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*RPCJournalEntry, 0)
+	for offset := 0; offset+rpcJournalSlotSize <= len(data); offset += rpcJournalSlotSize {
+		slot := bytes.TrimSpace(data[offset : offset+rpcJournalSlotSize])
+		if len(slot) == 0 {
+			continue
+		}
+		var entry RPCJournalEntry
+		if json.Unmarshal(slot, &entry) != nil || entry.Seq == 0 {
+			continue
+		}
+		list = append(list, &entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Seq < list[j].Seq
+	})
+
+	return list, nil
+}
+
+//rpcJournalPath 调用记录文件路径
+func (wm *WalletManager) rpcJournalPath() string {
+	return filepath.Join(wm.config().DBPath, wm.config().RPCJournalFile)
+}
+
+//applyRPCJournal 配置了记录容量时，为节点客户端开启调用记录，注入的其他客户端实现不记录
+func (wm *WalletManager) applyRPCJournal() {
+
+	size := wm.config().RPCJournalSize
+	if size <= 0 {
+		return
+	}
+
+	journal := newRPCJournal(wm.rpcJournalPath(), size)
+	clients := []*Client{wm.WalletClient}
+	clients = append(clients, wm.BackupClients...)
+	for _, c := range clients {
+		if c != nil {
+			c.setJournal(journal)
+		}
+	}
+
+	wm.Log.Std.Info("rpc journal is enabled, keep last %d calls in: %s", size, wm.rpcJournalPath())
+}
+
+//GetRPCJournal 按序号获取调用记录文件中保留的全部记录，关闭记录后仍可读取之前的记录
+func (wm *WalletManager) GetRPCJournal() ([]*RPCJournalEntry, error) {
+
+	f, err := os.Open(wm.rpcJournalPath())
+	if os.IsNotExist(err) {
+		return []*RPCJournalEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readRPCJournal(f)
+}
+
+//ExportRPCJournal 按序号导出调用记录，每行一条json记录，method不为空时只导出该方法的记录
+func (wm *WalletManager) ExportRPCJournal(w io.Writer, method string) error {
+
+	list, err := wm.GetRPCJournal()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, e := range list {
+		if len(method) > 0 && e.Method != method {
+			continue
+		}
+		err = encoder.Encode(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWalletManager_RPCJournal(t *testing.T) {
+	server := newTestRPCServer(testChainHandler(10, "hash"))
+	defer server.Close()
+	down := newTestRPCServer(testChainHandler(10, "hash"))
+	down.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.BackupClients = []*Client{NewClient(down.URL, "", false)}
+
+	//未开启时不记录
+	wm.applyRPCJournal()
+	wm.WalletClient.Call("getblockcount", nil)
+	if list, err := wm.GetRPCJournal(); err != nil || len(list) != 0 {
+		t.Fatalf("journal disabled, got: %d, %v", len(list), err)
+	}
+
+	wm.Config.RPCJournalSize = 3
+	wm.applyRPCJournal()
+	for h := uint64(1); h <= 4; h++ {
+		wm.WalletClient.Call("getblockhash", []interface{}{h})
+	}
+	wm.BackupClients[0].Call("getblockcount", nil)
+
+	//只保留最近的记录
+	list, err := wm.GetRPCJournal()
+	if err != nil || len(list) != 3 || list[0].Seq != 3 || list[2].Seq != 5 {
+		t.Fatalf("GetRPCJournal = %+v, %v", list, err)
+	}
+	if list[1].Method != "getblockhash" || list[1].ParamsHash != RPCParamsHash([]interface{}{4}) || list[1].Status != RPCJournalStatusOK || list[1].ResultHash == "" {
+		t.Errorf("unexpected entry: %+v", list[1])
+	}
+	if list[2].Node != down.URL || list[2].Status != RPCJournalStatusError || list[2].Error == "" {
+		t.Errorf("failed call should be recorded, got: %+v", list[2])
+	}
+	if info, _ := os.Stat(wm.rpcJournalPath()); info.Size() != 3*rpcJournalSlotSize {
+		t.Errorf("journal file size should be fixed, got: %d", info.Size())
+	}
+
+	//重启后继续编号
+	wm.applyRPCJournal()
+	wm.WalletClient.Call("getblockcount", nil)
+	list, _ = wm.GetRPCJournal()
+	if len(list) != 3 || list[2].Seq != 6 || list[2].Method != "getblockcount" {
+		t.Fatalf("journal should continue after reopen, got: %+v", list)
+	}
+
+	var buf bytes.Buffer
+	if err := wm.ExportRPCJournal(&buf, "getblockhash"); err != nil || strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("ExportRPCJournal = %s, %v", buf.String(), err)
+	}
+
+	//过长的错误信息截断到固定长度
+	slot, err := encodeRPCJournalEntry(&RPCJournalEntry{Seq: 1, Method: "getblock", Error: strings.Repeat("x", 1000)})
+	if err != nil || len(slot) != rpcJournalSlotSize {
+		t.Errorf("encodeRPCJournalEntry = %d, %v", len(slot), err)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+//isHash256 是否为0x开头的32字节hex字符串，区块hash和交易ID都是该格式
+func isHash256(hash string) bool {
+	if !strings.HasPrefix(hash, "0x") || len(hash) != 66 {
+		return false
+	}
+	_, err := hex.DecodeString(hash[2:])
+	return err == nil
+}
+
+//isUintValue 是否为非负整数
+func isUintValue(value gjson.Result) bool {
+	if value.Type != gjson.Number {
+		return false
+	}
+	_, err := strconv.ParseUint(value.Raw, 10, 64)
+	return err == nil
+}
+
+//isAmountValue 是否为合法的金额，节点返回的金额可能是数字或字符串
+func isAmountValue(value gjson.Result) bool {
+	if value.Type != gjson.Number && value.Type != gjson.String {
+		return false
+	}
+	_, err := decimal.NewFromString(value.String())
+	return err == nil
+}
+
+//validateBlockCountResult 校验getblockcount的返回结果
+func validateBlockCountResult(result *gjson.Result) error {
+	if !isUintValue(*result) || result.Uint() == 0 {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "invalid block count: %s", result.Raw)
+	}
+	return nil
+}
+
+//validateBlockHashResult 校验getblockhash的返回结果
+func validateBlockHashResult(result *gjson.Result) error {
+	if !isHash256(result.String()) {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "invalid block hash: %s", result.Raw)
+	}
+	return nil
+}
+
+//validateBlockHeaderResult 校验getblockheader的返回结果，getblock结果的区块头部分相同
+func validateBlockHeaderResult(result *gjson.Result) error {
+
+	if !result.IsObject() {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "block is not an object: %s", result.Raw)
+	}
+
+	if hash := result.Get("hash").String(); !isHash256(hash) {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "invalid block hash: %s", hash)
+	}
+
+	index := result.Get("index")
+	if !isUintValue(index) {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "invalid block index: %s", index.Raw)
+	}
+
+	//创世区块没有上一区块
+	if prev := result.Get("previousblockhash").String(); index.Uint() > 0 && !isHash256(prev) {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "block: %d invalid previous block hash: %s", index.Uint(), prev)
+	}
+
+	if t := result.Get("time"); !isUintValue(t) {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "block: %d invalid time: %s", index.Uint(), t.Raw)
+	}
+
+	return nil
+}
+
+//validateBlockResult 校验getblock的返回结果
+func validateBlockResult(result *gjson.Result) error {
+
+	if err := validateBlockHeaderResult(result); err != nil {
+		return err
+	}
+
+	index := result.Get("index")
+	txs := result.Get("tx")
+	if !txs.IsArray() {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "block: %d transactions is not an array", index.Uint())
+	}
+
+	for _, tx := range txs.Array() {
+		if tx.IsObject() {
+			if err := validateTransactionResult(&tx); err != nil {
+				return err
+			}
+		} else if !isHash256(tx.String()) {
+			return openwallet.Errorf(ErrRPCResponseInvalid, "block: %d invalid txid: %s", index.Uint(), tx.Raw)
+		}
+	}
+
+	return nil
+}
+
+//validateTransactionResult 校验getrawtransaction的返回结果
+func validateTransactionResult(result *gjson.Result) error {
+
+	if !result.IsObject() {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "transaction is not an object: %s", result.Raw)
+	}
+
+	txid := result.Get("txid").String()
+	if !isHash256(txid) {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "invalid txid: %s", txid)
+	}
+
+	for _, vin := range result.Get("vin").Array() {
+		if prev := vin.Get("txid").String(); !isHash256(prev) {
+			return openwallet.Errorf(ErrRPCResponseInvalid, "txid: %s invalid input txid: %s", txid, prev)
+		}
+		if !isUintValue(vin.Get("vout")) {
+			return openwallet.Errorf(ErrRPCResponseInvalid, "txid: %s invalid input vout: %s", txid, vin.Get("vout").Raw)
+		}
+	}
+
+	for _, vout := range result.Get("vout").Array() {
+		if !isUintValue(vout.Get("n")) {
+			return openwallet.Errorf(ErrRPCResponseInvalid, "txid: %s invalid output n: %s", txid, vout.Get("n").Raw)
+		}
+		if !isAmountValue(vout.Get("value")) {
+			return openwallet.Errorf(ErrRPCResponseInvalid, "txid: %s output: %d invalid value: %s", txid, vout.Get("n").Uint(), vout.Get("value").Raw)
+		}
+	}
+
+	for _, fee := range []string{"sys_fee", "net_fee"} {
+		if v := result.Get(fee); v.Exists() && !isAmountValue(v) {
+			return openwallet.Errorf(ErrRPCResponseInvalid, "txid: %s invalid %s: %s", txid, fee, v.Raw)
+		}
+	}
+
+	return nil
+}
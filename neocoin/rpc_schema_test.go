@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestValidateRPCResponse(t *testing.T) {
+	txid := testHash("tx")
+	blockHash := testHash("block")
+
+	validBlock := fmt.Sprintf(`{"hash":"%s","index":10,"previousblockhash":"%s","time":1573037731,"tx":["%s"]}`, blockHash, testHash("prev"), txid)
+	validTx := fmt.Sprintf(`{"txid":"%s","vin":[{"txid":"%s","vout":0}],"vout":[{"n":0,"value":"1.5","address":"AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"}],"sys_fee":"0","net_fee":"0.001"}`, txid, testHash("prev"))
+
+	tests := []struct {
+		name     string
+		validate func(*gjson.Result) error
+		raw      string
+		valid    bool
+	}{
+		{"block count", validateBlockCountResult, `101`, true},
+		{"block count zero", validateBlockCountResult, `0`, false},
+		{"block count string", validateBlockCountResult, `"101"`, false},
+		{"block hash", validateBlockHashResult, fmt.Sprintf(`"%s"`, blockHash), true},
+		{"block hash short", validateBlockHashResult, `"0xabc"`, false},
+		{"block", validateBlockResult, validBlock, true},
+		{"block genesis", validateBlockResult, fmt.Sprintf(`{"hash":"%s","index":0,"time":1468595301,"tx":[]}`, blockHash), true},
+		{"block missing index", validateBlockResult, fmt.Sprintf(`{"hash":"%s","time":1,"tx":[]}`, blockHash), false},
+		{"block negative index", validateBlockResult, fmt.Sprintf(`{"hash":"%s","index":-1,"time":1,"tx":[]}`, blockHash), false},
+		{"block bad txid", validateBlockResult, fmt.Sprintf(`{"hash":"%s","index":0,"time":1,"tx":["0x01"]}`, blockHash), false},
+		{"block missing tx", validateBlockResult, fmt.Sprintf(`{"hash":"%s","index":0,"time":1}`, blockHash), false},
+		{"transaction", validateTransactionResult, validTx, true},
+		{"transaction bad txid", validateTransactionResult, `{"txid":"tx","vin":[],"vout":[]}`, false},
+		{"transaction bad amount", validateTransactionResult, fmt.Sprintf(`{"txid":"%s","vout":[{"n":0,"value":"abc"}]}`, txid), false},
+		{"transaction missing amount", validateTransactionResult, fmt.Sprintf(`{"txid":"%s","vout":[{"n":0}]}`, txid), false},
+		{"transaction bad fee", validateTransactionResult, fmt.Sprintf(`{"txid":"%s","net_fee":"x"}`, txid), false},
+		{"transaction null", validateTransactionResult, `null`, false},
+	}
+
+	for _, test := range tests {
+		result := gjson.Parse(test.raw)
+		err := test.validate(&result)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: invalid response should fail", test.name)
+			} else if owErr, ok := err.(*openwallet.Error); !ok || owErr.Code() != ErrRPCResponseInvalid {
+				t.Errorf("%s: error should be typed, got: %v", test.name, err)
+			}
+		}
+	}
+}
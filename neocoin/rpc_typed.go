@@ -0,0 +1,223 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//RPCSchemaV1 NEO 2.x节点的返回格式
+	RPCSchemaV1 = 1
+)
+
+//RPCRequest 类型化的节点请求，每个方法对应一个请求结构
+type RPCRequest interface {
+	Method() string
+	Params() []interface{}
+}
+
+//GetBlockCountRequest getblockcount请求
+type GetBlockCountRequest struct{}
+
+func (GetBlockCountRequest) Method() string        { return "getblockcount" }
+func (GetBlockCountRequest) Params() []interface{} { return []interface{}{} }
+
+//GetBlockHashRequest getblockhash请求
+type GetBlockHashRequest struct {
+	Height uint64
+}
+
+func (GetBlockHashRequest) Method() string          { return "getblockhash" }
+func (r GetBlockHashRequest) Params() []interface{} { return []interface{}{r.Height} }
+
+//GetBestBlockHashRequest getbestblockhash请求
+type GetBestBlockHashRequest struct{}
+
+func (GetBestBlockHashRequest) Method() string        { return "getbestblockhash" }
+func (GetBestBlockHashRequest) Params() []interface{} { return []interface{}{} }
+
+//GetRawMempoolRequest getrawmempool请求
+type GetRawMempoolRequest struct{}
+
+func (GetRawMempoolRequest) Method() string        { return "getrawmempool" }
+func (GetRawMempoolRequest) Params() []interface{} { return []interface{}{} }
+
+//GetTxOutRequest gettxout请求
+type GetTxOutRequest struct {
+	TxID string
+	N    uint64
+}
+
+func (GetTxOutRequest) Method() string          { return "gettxout" }
+func (r GetTxOutRequest) Params() []interface{} { return []interface{}{r.TxID, r.N} }
+
+//rpcAmount 节点返回的金额，可能是数字或字符串
+type rpcAmount string
+
+func (a *rpcAmount) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(string(data), `"`)
+	if raw == "null" {
+		raw = ""
+	}
+	*a = rpcAmount(raw)
+	return nil
+}
+
+//txOutResult gettxout的返回结构，节点格式变化时新增版本结构实现该接口
+type txOutResult interface {
+	toVout() *Vout
+}
+
+//TxOutResultV1 NEO 2.x节点gettxout的返回结构
+type TxOutResultV1 struct {
+	N             uint64    `json:"n"`
+	Asset         string    `json:"asset"`
+	Value         rpcAmount `json:"value"`
+	Address       string    `json:"address"`
+	ScriptHash    string    `json:"scripthash"`
+	ScriptHashAlt string    `json:"script_hash"`
+}
+
+func (r *TxOutResultV1) toVout() *Vout {
+	output := &Vout{
+		N:     r.N,
+		Asset: r.Asset,
+		Value: string(r.Value),
+		Addr:  r.Address,
+	}
+
+	//支付到合约的输出，由脚本hash生成地址
+	if len(output.Addr) == 0 {
+		for _, scriptHash := range []string{r.ScriptHash, r.ScriptHashAlt} {
+			if addr := scriptHashToAddress(scriptHash); len(addr) > 0 {
+				output.Addr = addr
+				output.IsContract = true
+				break
+			}
+		}
+	}
+
+	return output
+}
+
+//TypedClient 类型化的节点客户端，按请求结构调用并解码到返回结构，替代直接读取gjson字段
+type TypedClient struct {
+	Client        ClientInterface
+	SchemaVersion int //节点返回格式版本
+}
+
+func NewTypedClient(client ClientInterface) *TypedClient {
+	return &TypedClient{
+		Client:        client,
+		SchemaVersion: RPCSchemaV1,
+	}
+}
+
+//Do 执行请求，并把结果解码到result
+func (tc *TypedClient) Do(request RPCRequest, result interface{}) error {
+
+	if tc.Client == nil {
+		return errors.New("API url is not setup. ")
+	}
+
+	raw, err := tc.Client.Call(request.Method(), request.Params())
+	if err != nil {
+		return err
+	}
+
+	if len(raw.Raw) == 0 {
+		return nil
+	}
+
+	if err = json.Unmarshal([]byte(raw.Raw), result); err != nil {
+		return openwallet.Errorf(ErrRPCResponseInvalid, "%s result can not be decoded: %v", request.Method(), err)
+	}
+
+	return nil
+}
+
+//GetBlockCount 获取节点的区块数量
+func (tc *TypedClient) GetBlockCount() (uint64, error) {
+	var count uint64
+	if err := tc.Do(GetBlockCountRequest{}, &count); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, openwallet.Errorf(ErrRPCResponseInvalid, "invalid block count: %d", count)
+	}
+	return count, nil
+}
+
+//GetBlockHash 根据区块高度获得区块hash
+func (tc *TypedClient) GetBlockHash(height uint64) (string, error) {
+	var hash string
+	if err := tc.Do(GetBlockHashRequest{Height: height}, &hash); err != nil {
+		return "", err
+	}
+	if !isHash256(hash) {
+		return "", openwallet.Errorf(ErrRPCResponseInvalid, "invalid block hash: %s", hash)
+	}
+	return hash, nil
+}
+
+//GetBestBlockHash 获取主链中高度最大的区块的hash
+func (tc *TypedClient) GetBestBlockHash() (string, error) {
+	var hash string
+	if err := tc.Do(GetBestBlockHashRequest{}, &hash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+//GetRawMempool 获取交易池中的交易单IDs
+func (tc *TypedClient) GetRawMempool() ([]string, error) {
+	var txids []string
+	if err := tc.Do(GetRawMempoolRequest{}, &txids); err != nil {
+		return nil, err
+	}
+	if txids == nil {
+		return nil, errors.New("no query record")
+	}
+	return txids, nil
+}
+
+//GetTxOut 获取交易单输出信息，按节点返回格式版本解码
+func (tc *TypedClient) GetTxOut(txid string, vout uint64) (*Vout, error) {
+
+	var result txOutResult
+	switch tc.SchemaVersion {
+	case RPCSchemaV1:
+		result = &TxOutResultV1{}
+	default:
+		return nil, openwallet.Errorf(ErrRPCResponseInvalid, "unsupported rpc schema version: %d", tc.SchemaVersion)
+	}
+
+	if err := tc.Do(GetTxOutRequest{TxID: txid, N: vout}, result); err != nil {
+		return nil, err
+	}
+
+	return result.toVout(), nil
+}
+
+//rpc 当前节点客户端的类型化封装
+func (wm *WalletManager) rpc() *TypedClient {
+	return NewTypedClient(wm.readClient())
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestTypedClient(t *testing.T) {
+	blockHash := testHash("block")
+	txid := testHash("tx")
+
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 101
+		case "getblockhash":
+			if params[0] == float64(1) {
+				return "0x01"
+			}
+			return blockHash
+		case "getrawmempool":
+			return []string{txid}
+		case "gettxout":
+			if params[1] == float64(1) {
+				return map[string]interface{}{"n": 1, "asset": "0xc56f", "value": 2.5, "script_hash": "0x" + "11223344556677889900aabbccddeeff00112233"}
+			}
+			return map[string]interface{}{"n": 0, "asset": "0xc56f", "value": "100", "address": "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	tc := NewTypedClient(NewClient(server.URL, "", false))
+
+	if count, err := tc.GetBlockCount(); err != nil || count != 101 {
+		t.Errorf("GetBlockCount = %d, %v", count, err)
+	}
+
+	if hash, err := tc.GetBlockHash(10); err != nil || hash != blockHash {
+		t.Errorf("GetBlockHash = %s, %v", hash, err)
+	}
+
+	if _, err := tc.GetBlockHash(1); openwallet.ConvertError(err).Code() != ErrRPCResponseInvalid {
+		t.Errorf("invalid block hash should be rejected, err: %v", err)
+	}
+
+	if txids, err := tc.GetRawMempool(); err != nil || len(txids) != 1 || txids[0] != txid {
+		t.Errorf("GetRawMempool = %v, %v", txids, err)
+	}
+
+	output, err := tc.GetTxOut(txid, 0)
+	if err != nil || output.Value != "100" || output.Addr != "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT" || output.IsContract {
+		t.Errorf("GetTxOut = %+v, %v", output, err)
+	}
+
+	//数字金额和合约输出
+	output, err = tc.GetTxOut(txid, 1)
+	if err != nil || output.Value != "2.5" || len(output.Addr) == 0 || !output.IsContract {
+		t.Errorf("GetTxOut contract = %+v, %v", output, err)
+	}
+
+	tc.SchemaVersion = 0
+	if _, err := tc.GetTxOut(txid, 0); err == nil {
+		t.Errorf("unsupported schema version should fail")
+	}
+}
+
+func TestTypedClient_InvalidResult(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return "101"
+		}
+		return nil
+	})
+	defer server.Close()
+
+	tc := NewTypedClient(NewClient(server.URL, "", false))
+
+	if _, err := tc.GetBlockCount(); openwallet.ConvertError(err).Code() != ErrRPCResponseInvalid {
+		t.Errorf("string block count should be rejected, err: %v", err)
+	}
+
+	if _, err := tc.GetRawMempool(); err == nil {
+		t.Errorf("null mempool should fail")
+	}
+}
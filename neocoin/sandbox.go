@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//Sandbox 创建使用相同配置、节点和浏览器客户端的管理者，本地数据保存在dbPath，
+//扫描和重设高度不影响当前管理者的本地数据，用于兼容性检查等场景。已授予的权限和数据库密钥提供者一并复制
+func (wm *WalletManager) Sandbox(dbPath string) *WalletManager {
+
+	sandbox := NewWalletManager()
+
+	cfg := wm.config().clone()
+	cfg.DBPath = dbPath
+	sandbox.Config = cfg
+
+	sandbox.WalletClient = wm.WalletClient
+	sandbox.BackupClients = wm.BackupClients
+	sandbox.OnmiClient = wm.OnmiClient
+	sandbox.ExplorerClient = wm.ExplorerClient
+	sandbox.BackupExplorerClients = wm.BackupExplorerClients
+
+	wm.injectMu.RLock()
+	sandbox.injected = wm.injected
+	wm.injectMu.RUnlock()
+
+	wm.dbKeyMu.Lock()
+	sandbox.dbKeyProvider = wm.dbKeyProvider
+	wm.dbKeyMu.Unlock()
+
+	wm.capabilities.mu.Lock()
+	sandbox.capabilities.caps = wm.capabilities.caps
+	wm.capabilities.mu.Unlock()
+
+	return sandbox
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalletManager_Sandbox(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.OperationToken = "token"
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient("http://127.0.0.1:1", "", false)
+	wm.GrantCapability("token", CapabilityRescan)
+
+	dbPath, _ := ioutil.TempDir("", "neo-sandbox")
+	defer os.RemoveAll(dbPath)
+	sandbox := wm.Sandbox(dbPath)
+
+	if sandbox.config().DBPath != dbPath || sandbox.WalletClient != wm.WalletClient {
+		t.Fatalf("sandbox should use the same client with its own db path")
+	}
+	if sandbox.requireCapability(CapabilityRescan) != nil || sandbox.requireCapability(CapabilityDeleteLocal) == nil {
+		t.Errorf("sandbox should copy granted capabilities only")
+	}
+
+	sandbox.SaveLocalNewBlock(10, testHash("block10"))
+	if height, _ := wm.GetLocalNewBlock(); height != 0 {
+		t.Errorf("sandbox local data should not affect wallet manager, height: %d", height)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sync"
+)
+
+//notifiedHeightWindow 保留已通知区块记录的高度范围
+const notifiedHeightWindow = 1000
+
+//heightGuard 所有扫描入口共用的区块高度锁和通知记录
+//实时扫描与手动重扫并发时，同一高度串行提取，同一区块只通知一次
+type heightGuard struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	inflight  map[uint64]bool   //正在提取的高度
+	notified  map[uint64]string //已通知的高度对应区块hash
+	extracted map[uint64]string //提取数据已全部通知的高度对应区块hash
+	maxHeight uint64            //已通知的最高高度
+}
+
+func newHeightGuard() *heightGuard {
+	g := &heightGuard{
+		inflight:  make(map[uint64]bool),
+		notified:  make(map[uint64]string),
+		extracted: make(map[uint64]string),
+	}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+//lock 占用高度，其他入口正在提取该高度时等待其完成
+func (g *heightGuard) lock(height uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.inflight[height] {
+		g.cond.Wait()
+	}
+	g.inflight[height] = true
+}
+
+//unlock 释放高度
+func (g *heightGuard) unlock(height uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.inflight, height)
+	g.cond.Broadcast()
+}
+
+//markNotified 记录区块已通知，同一高度相同hash的区块已通知过时返回false
+//同一高度hash不同时视为分叉后的新区块，允许通知
+func (g *heightGuard) markNotified(height uint64, hash string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if notified, ok := g.notified[height]; ok && notified == hash {
+		return false
+	}
+	g.notified[height] = hash
+	g.prune(height)
+
+	return true
+}
+
+//extractNotified 区块的提取数据是否已全部通知，同一高度hash不同时视为分叉后的新区块
+func (g *heightGuard) extractNotified(height uint64, hash string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	notified, ok := g.extracted[height]
+	return ok && notified == hash
+}
+
+//markExtractNotified 记录区块的提取数据已全部通知，有通知失败时不记录，补扫时重新通知
+func (g *heightGuard) markExtractNotified(height uint64, hash string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.extracted[height] = hash
+	g.prune(height)
+}
+
+//prune 只保留最近范围内的记录，调用方持有锁
+func (g *heightGuard) prune(height uint64) {
+
+	if height > g.maxHeight {
+		g.maxHeight = height
+	}
+
+	if len(g.notified)+len(g.extracted) <= notifiedHeightWindow {
+		return
+	}
+	for h := range g.notified {
+		if h+notifiedHeightWindow <= g.maxHeight {
+			delete(g.notified, h)
+		}
+	}
+	for h := range g.extracted {
+		if h+notifiedHeightWindow <= g.maxHeight {
+			delete(g.extracted, h)
+		}
+	}
+}
+
+//forget 删除高度的通知记录，分叉回滚后该高度的区块和提取数据须重新通知
+func (g *heightGuard) forget(height uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.notified, height)
+	delete(g.extracted, height)
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testHeaderObserver struct {
+	mu      sync.Mutex
+	headers []*openwallet.BlockHeader
+}
+
+func (o *testHeaderObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.headers = append(o.headers, header)
+	return nil
+}
+
+//waitHeaders 区块通知是异步的，等待收到指定数量后再稍等，确认没有多余通知
+func (o *testHeaderObserver) waitHeaders(count int) int {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		o.mu.Lock()
+		n := len(o.headers)
+		o.mu.Unlock()
+		if n >= count {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.headers)
+}
+
+func (o *testHeaderObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	return nil
+}
+
+func TestNEOBlockScanner_DuplicateBlockNotify(t *testing.T) {
+	txid := testHash("tx")
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockhash":
+			return testHash("block100")
+		case "getblock":
+			return map[string]interface{}{"index": 100, "hash": params[0], "previousblockhash": testHash("block99"), "time": 1000, "tx": []interface{}{txid}}
+		case "getrawtransaction":
+			return map[string]interface{}{"txid": txid, "blockhash": testHash("block100")}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	bs := wm.Blockscanner
+	observer := &testHeaderObserver{}
+	bs.AddObserver(observer)
+
+	//手动重扫与实时扫描并发触发同一高度
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bs.ScanBlock(100)
+		}()
+	}
+	wg.Wait()
+
+	if n := observer.waitHeaders(1); n != 1 {
+		t.Errorf("block should be notified once, notified: %d", n)
+	}
+
+	//分叉通知后，该高度的区块可重新通知
+	block := &Block{Height: 100, Hash: testHash("block100")}
+	bs.newBlockNotify(block, true)
+	bs.newBlockNotify(block, false)
+	if n := observer.waitHeaders(3); n != 3 || !observer.headers[1].Fork {
+		t.Errorf("fork and rescanned block should be notified, notified: %d", n)
+	}
+}
+
+func TestNEOBlockScanner_DuplicateExtractDataNotify(t *testing.T) {
+	var (
+		txid    = testHash("tx")
+		address = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockhash":
+			return testHash("block100")
+		case "getblock":
+			return map[string]interface{}{"index": 100, "hash": params[0], "previousblockhash": testHash("block99"), "time": 1000, "tx": []interface{}{txid}}
+		case "getrawtransaction":
+			return map[string]interface{}{"txid": txid, "blockhash": testHash("block100"), "vin": []interface{}{},
+				"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": address}}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	bs.ScanAddressFunc = func(a string) (string, bool) {
+		return "account", a == address
+	}
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	//同一高度扫描两次，提取数据只通知一次
+	bs.ScanBlock(100)
+	bs.ScanBlock(100)
+	if len(observer.notified) != 1 || observer.notified[0] != "account:"+txid {
+		t.Fatalf("extract data should be notified once, notified: %v", observer.notified)
+	}
+
+	//回滚后重新扫描该高度时重新通知
+	bs.rollbackExtractData(100)
+	bs.ScanBlock(100)
+	if len(observer.notified) != 3 || !observer.data[1].TxOutputs[0].Delete || observer.data[2].TxOutputs[0].Delete {
+		t.Errorf("rescanned block should be notified after rollback, notified: %v", observer.notified)
+	}
+}
+
+func TestHeightGuard_Lock(t *testing.T) {
+	g := newHeightGuard()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		running int
+		maxRun  int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.lock(100)
+			mu.Lock()
+			running++
+			if running > maxRun {
+				maxRun = running
+			}
+			mu.Unlock()
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			g.unlock(100)
+		}()
+	}
+	wg.Wait()
+
+	if maxRun != 1 {
+		t.Errorf("same height should be extracted serially, max concurrent: %d", maxRun)
+	}
+
+	//只保留最近范围内的通知记录
+	for h := uint64(1); h <= notifiedHeightWindow+10; h++ {
+		g.markNotified(h, "hash")
+	}
+	if len(g.notified) > notifiedHeightWindow+1 {
+		t.Errorf("notified records should be pruned, count: %d", len(g.notified))
+	}
+	if g.markNotified(notifiedHeightWindow+10, "hash") {
+		t.Errorf("recent notified block should be duplicate")
+	}
+}
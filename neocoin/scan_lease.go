@@ -0,0 +1,182 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	scanLeaseBucket = "scanLease" //扫描租约集合
+	scanLeaseKey    = "lease"
+)
+
+//ScanLease 扫描租约，同一本地数据库同时只允许一个适配器实例扫描写入
+type ScanLease struct {
+	Owner       string //持有者实例标识
+	AcquiredAt  int64  //取得租约的时间
+	HeartbeatAt int64  //最近一次续约的时间
+}
+
+//Expired 租约超过有效期未续约
+func (lease *ScanLease) Expired(ttl time.Duration) bool {
+	return time.Since(time.Unix(lease.HeartbeatAt, 0)) > ttl
+}
+
+//InstanceID 当前适配器实例标识，由主机名、进程号和启动时间组成
+func (wm *WalletManager) InstanceID() string {
+	wm.leaseMu.Lock()
+	defer wm.leaseMu.Unlock()
+	if len(wm.instanceID) == 0 {
+		host, _ := os.Hostname()
+		wm.instanceID = fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	}
+	return wm.instanceID
+}
+
+//GetScanLease 获取当前的扫描租约，没有租约时返回nil
+func (wm *WalletManager) GetScanLease() (*ScanLease, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var lease ScanLease
+	err = db.Get(scanLeaseBucket, scanLeaseKey, &lease)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+//AcquireScanLease 取得或续约扫描租约，其他实例持有未过期的租约时返回ErrScanLeaseHeld
+func (wm *WalletManager) AcquireScanLease() error {
+	return wm.writeScanLease(false)
+}
+
+//TakeOver 计划内故障转移，强制从其他实例接管扫描租约，原实例下次续约时停止扫描
+func (wm *WalletManager) TakeOver() error {
+	if err := wm.requireCapability(CapabilityTakeOver); err != nil {
+		return err
+	}
+
+	return wm.writeScanLease(true)
+}
+
+//ReleaseScanLease 释放当前实例持有的扫描租约
+func (wm *WalletManager) ReleaseScanLease() error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var lease ScanLease
+	err = db.Get(scanLeaseBucket, scanLeaseKey, &lease)
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if lease.Owner != wm.InstanceID() {
+		return nil
+	}
+
+	wm.leaseMu.Lock()
+	wm.leaseRenewedAt = time.Time{}
+	wm.leaseMu.Unlock()
+
+	return db.Delete(scanLeaseBucket, scanLeaseKey)
+}
+
+//writeScanLease 在同一个数据库事务中检查并写入租约
+func (wm *WalletManager) writeScanLease(force bool) error {
+
+	owner := wm.InstanceID()
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	lease := ScanLease{Owner: owner, AcquiredAt: now.Unix()}
+
+	var current ScanLease
+	err = tx.Get(scanLeaseBucket, scanLeaseKey, &current)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	if err == nil {
+		if current.Owner == owner {
+			lease.AcquiredAt = current.AcquiredAt
+		} else if !force && !current.Expired(wm.config().ScanLeaseTTL) {
+			return wm.errorf(ErrScanLeaseHeld, "scan lease is held by instance: %s, last heartbeat: %s", current.Owner, time.Unix(current.HeartbeatAt, 0).Format(time.RFC3339))
+		}
+	}
+	lease.HeartbeatAt = now.Unix()
+
+	if err = tx.Set(scanLeaseBucket, scanLeaseKey, &lease); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	wm.leaseMu.Lock()
+	wm.leaseRenewedAt = now
+	wm.leaseMu.Unlock()
+
+	return nil
+}
+
+//keepScanLease 扫描期间按有效期的三分之一续约，未开启租约时直接返回
+func (wm *WalletManager) keepScanLease() error {
+	if wm.config().ScanLeaseTTL <= 0 {
+		return nil
+	}
+
+	wm.leaseMu.Lock()
+	renewedAt := wm.leaseRenewedAt
+	wm.leaseMu.Unlock()
+
+	if !renewedAt.IsZero() && time.Since(renewedAt) < wm.config().ScanLeaseTTL/3 {
+		return nil
+	}
+
+	return wm.AcquireScanLease()
+}
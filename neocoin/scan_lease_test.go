@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_ScanLease(t *testing.T) {
+	dbPath, _ := ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(dbPath)
+
+	primary := NewWalletManager()
+	primary.Config.DBPath = dbPath
+	standby := NewWalletManager()
+	standby.Config.DBPath = dbPath
+	standby.Config.OperationToken = "token"
+
+	if err := primary.AcquireScanLease(); err != nil {
+		t.Errorf("AcquireScanLease failed unexpected error: %v\n", err)
+		return
+	}
+	if err := primary.AcquireScanLease(); err != nil {
+		t.Errorf("owner should renew scan lease, unexpected error: %v\n", err)
+	}
+
+	//其他实例持有未过期的租约
+	err := standby.AcquireScanLease()
+	if err == nil || openwallet.ConvertError(err).Code() != ErrScanLeaseHeld {
+		t.Errorf("standby should not acquire held scan lease, err: %v", err)
+	}
+
+	//计划内故障转移须授权
+	if err := standby.TakeOver(); err == nil {
+		t.Errorf("TakeOver should require capability")
+	}
+	standby.GrantCapability("token", CapabilityTakeOver)
+	if err := standby.TakeOver(); err != nil {
+		t.Errorf("TakeOver failed unexpected error: %v\n", err)
+	}
+
+	lease, _ := primary.GetScanLease()
+	if lease == nil || lease.Owner != standby.InstanceID() {
+		t.Errorf("scan lease should be owned by standby, lease: %+v", lease)
+	}
+	if err := primary.AcquireScanLease(); err == nil {
+		t.Errorf("primary should lose scan lease after take over")
+	}
+
+	//租约过期后可被其他实例取得
+	primary.Config.ScanLeaseTTL = 1
+	if err := primary.AcquireScanLease(); err != nil {
+		t.Errorf("expired scan lease should be acquired, unexpected error: %v\n", err)
+	}
+
+	primary.ReleaseScanLease()
+	if lease, _ := standby.GetScanLease(); lease != nil {
+		t.Errorf("scan lease should be released, lease: %+v", lease)
+	}
+}
@@ -0,0 +1,274 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+//ScanSnapshot 扫描状态快照，记录区块头和各sourceKey已分配的提取结果序号，用于恢复到该高度
+type ScanSnapshot struct {
+	Height    uint64 `storm:"id"`
+	BlockHash string
+	Sequences map[string]uint64 //sourceKey最近分配的提取结果序号
+	CreateAt  int64
+}
+
+//takeScanSnapshot 区块高度保存后调用，高度为N的整数倍时保存扫描状态快照，只保留最近的快照
+func (bs *NEOBlockScanner) takeScanSnapshot(height uint64, hash string) {
+
+	blocks := bs.wm.config().ScanSnapshotBlocks
+	if blocks == 0 || height == 0 || height%blocks != 0 {
+		return
+	}
+
+	err := bs.wm.saveScanSnapshot(height, hash)
+	if err != nil {
+		bs.wm.Log.Std.Error("block height: %d save scan snapshot failed; unexpected error: %v", height, err)
+		return
+	}
+
+	bs.wm.Log.Std.Info("scan snapshot at height: %d", height)
+}
+
+//saveScanSnapshot 保存高度height的扫描状态快照，并删除超出保留数量的旧快照
+func (wm *WalletManager) saveScanSnapshot(height uint64, hash string) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var sequences []*sourceKeySequence
+	err = tx.All(&sequences)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	snapshot := &ScanSnapshot{
+		Height:    height,
+		BlockHash: hash,
+		Sequences: make(map[string]uint64, len(sequences)),
+		CreateAt:  time.Now().Unix(),
+	}
+	for _, s := range sequences {
+		snapshot.Sequences[s.SourceKey] = s.Sequence
+	}
+
+	err = tx.Save(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if retention := wm.config().ScanSnapshotRetention; retention > 0 {
+		var expired []*ScanSnapshot
+		err = tx.AllByIndex("Height", &expired, storm.Reverse(), storm.Skip(retention))
+		if err != nil && err != storm.ErrNotFound {
+			return err
+		}
+		for _, s := range expired {
+			err = tx.DeleteStruct(s)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+//ListScanSnapshots 获取本地保存的扫描状态快照，按高度降序
+func (wm *WalletManager) ListScanSnapshots() ([]*ScanSnapshot, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var snapshots []*ScanSnapshot
+	err = db.AllByIndex("Height", &snapshots, storm.Reverse())
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	if snapshots == nil {
+		snapshots = make([]*ScanSnapshot, 0)
+	}
+	return snapshots, nil
+}
+
+//RestoreToHeight 把本地扫描状态恢复到不高于height的最近一个快照，需要CapabilityRescan和CapabilityDeleteLocal。
+//快照以上已通知的提取结果先发送回滚通知（开启ForkRollbackNotify时），再删除本地的区块、提取结果、
+//未花输出历史、交易索引、待确认和待投递记录，提取结果序号恢复到快照时的值，
+//之后扫描器从快照高度的下一个区块按顺序重新提取和通知，用于发现错误版本处理了一段区块后撤销。
+//快照区块已被分叉替换时使用更早的快照。等待正在扫描的区块结束后执行，不能在扫描通知的回调中调用
+func (bs *NEOBlockScanner) RestoreToHeight(height uint64) (*ScanSnapshot, error) {
+	if err := bs.wm.requireCapability(CapabilityRescan | CapabilityDeleteLocal); err != nil {
+		return nil, err
+	}
+
+	return bs.restoreToHeight(height)
+}
+
+func (bs *NEOBlockScanner) restoreToHeight(height uint64) (*ScanSnapshot, error) {
+
+	bs.wm.scanCycleMu.Lock()
+	defer bs.wm.scanCycleMu.Unlock()
+
+	scanned, _ := bs.wm.GetLocalNewBlock()
+	if height > scanned {
+		return nil, bs.wm.errorf(ErrBlockHeightInvalid, "block height: %d is above scanned height: %d", height, scanned)
+	}
+
+	snapshot, err := bs.findRestoreSnapshot(height)
+	if err != nil {
+		return nil, err
+	}
+
+	bs.wm.Log.Std.Notice("restore scan state to snapshot height: %d, scanned height: %d", snapshot.Height, scanned)
+
+	//从高到低回滚已通知的提取结果，与分叉回滚的通知顺序一致
+	for h := scanned; h > snapshot.Height; h-- {
+		bs.rollbackExtractData(h)
+	}
+
+	err = bs.wm.clearLocalDataFromHeight(snapshot.Height + 1)
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "clear local data from height: %d failed, unexpected error: %v", snapshot.Height+1, err)
+	}
+
+	err = bs.wm.deleteLocalDataAboveHeight(snapshot.Height)
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "purge local data above height: %d failed, unexpected error: %v", snapshot.Height, err)
+	}
+
+	err = bs.wm.restoreScanSnapshotState(snapshot)
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "restore scan snapshot: %d failed, unexpected error: %v", snapshot.Height, err)
+	}
+
+	err = bs.wm.SaveLocalNewBlock(snapshot.Height, snapshot.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	bs.wm.Log.Std.Notice("scan state restored to height: %d, blocks above will be rescanned and notified again", snapshot.Height)
+
+	return snapshot, nil
+}
+
+//findRestoreSnapshot 查找不高于height且区块hash与节点一致的最近快照
+func (bs *NEOBlockScanner) findRestoreSnapshot(height uint64) (*ScanSnapshot, error) {
+
+	snapshots, err := bs.wm.ListScanSnapshots()
+	if err != nil {
+		return nil, bs.wm.errorf(ErrLocalDBOperateFailed, "list scan snapshots failed, unexpected error: %v", err)
+	}
+
+	for _, s := range snapshots {
+		if s.Height > height {
+			continue
+		}
+		hash, err := bs.wm.GetBlockHash(s.Height)
+		if err != nil {
+			return nil, err
+		}
+		if normalizeTxID(hash) == normalizeTxID(s.BlockHash) {
+			return s, nil
+		}
+		bs.wm.Log.Std.Warning("scan snapshot height: %d hash: %s is replaced by: %s, try earlier snapshot", s.Height, s.BlockHash, hash)
+	}
+
+	return nil, bs.wm.errorf(ErrBlockHeightInvalid, "no scan snapshot at or below height: %d", height)
+}
+
+//restoreScanSnapshotState 删除快照以上的待确认、待投递记录和更新的快照，恢复提取结果序号。
+//快照后重扫的低高度区块可能分配了更大的序号，序号取快照值和剩余提取结果的最大值，避免重复分配
+func (wm *WalletManager) restoreScanSnapshotState(snapshot *ScanSnapshot) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.Select(q.Gt("BlockHeight", snapshot.Height)).Delete(&ConfirmPendingRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	//已投递的记录保留作为历史，未投递的由重新通知重新生成
+	err = tx.Select(q.Gt("BlockHeight", snapshot.Height), q.Not(q.Eq("Status", NotifyDeliveryDelivered))).Delete(&NotifyDelivery{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	err = tx.Select(q.Gt("Height", snapshot.Height)).Delete(&ScanSnapshot{})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	restored := make(map[string]uint64, len(snapshot.Sequences))
+	for key, seq := range snapshot.Sequences {
+		restored[key] = seq
+	}
+	err = tx.Select().Each(new(ExtractDataRecord), func(record interface{}) error {
+		r := record.(*ExtractDataRecord)
+		if r.Sequence > restored[r.SourceKey] {
+			restored[r.SourceKey] = r.Sequence
+		}
+		return nil
+	})
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	var sequences []*sourceKeySequence
+	err = tx.All(&sequences)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	for _, s := range sequences {
+		seq, ok := restored[s.SourceKey]
+		if !ok {
+			err = tx.DeleteStruct(s)
+		} else if seq != s.Sequence {
+			s.Sequence = seq
+			err = tx.Save(s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
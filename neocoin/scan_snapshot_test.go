@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_RestoreToHeight(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "getblockhash" {
+			return testHash(fmt.Sprintf("block%v", params[0]))
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.Config.ScanSnapshotBlocks = 10
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	observer := &testReplayObserver{}
+	bs.AddObserver(observer)
+
+	extractData := func(txid string, height uint64) *openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: testHash(txid), BlockHeight: height}
+		return data
+	}
+
+	wm.SaveExtractData(5, map[string]*openwallet.TxExtractData{"account": extractData("tx5", 5)})
+	wm.SaveLocalNewBlock(10, testHash("block10"))
+	bs.takeScanSnapshot(10, testHash("block10"))
+	//不是N的整数倍时不保存
+	bs.takeScanSnapshot(15, testHash("block15"))
+
+	//错误版本处理的区块
+	wm.SaveExtractData(15, map[string]*openwallet.TxExtractData{"account": extractData("tx15", 15)})
+	wm.SaveExtractData(15, map[string]*openwallet.TxExtractData{"other": extractData("tx15", 15)})
+	wm.SaveLocalNewBlock(20, testHash("block20"))
+	bs.takeScanSnapshot(20, testHash("block20"))
+
+	snapshots, err := wm.ListScanSnapshots()
+	if err != nil || len(snapshots) != 2 || snapshots[0].Height != 20 || snapshots[0].Sequences["account"] != 2 || snapshots[0].Sequences["other"] != 1 {
+		t.Fatalf("ListScanSnapshots = %+v, %v", snapshots, err)
+	}
+
+	wm.Config.OperationToken = "secret"
+	if _, err := bs.RestoreToHeight(18); err == nil {
+		t.Errorf("restore should not be permitted without grant")
+	}
+	wm.GrantCapability("secret", CapabilityRescan|CapabilityDeleteLocal)
+
+	if _, err := bs.RestoreToHeight(25); err == nil {
+		t.Errorf("height above scanned height should be rejected")
+	}
+	if _, err := bs.RestoreToHeight(5); err == nil {
+		t.Errorf("height without snapshot should be rejected")
+	}
+
+	snapshot, err := bs.RestoreToHeight(18)
+	if err != nil || snapshot.Height != 10 {
+		t.Fatalf("RestoreToHeight = %+v, %v", snapshot, err)
+	}
+	if len(observer.notified) != 2 {
+		t.Errorf("extract data above snapshot should be rolled back, notified: %v", observer.notified)
+	}
+	if height, hash := wm.GetLocalNewBlock(); height != 10 || hash != testHash("block10") {
+		t.Errorf("local head should be restored, got: %d %s", height, hash)
+	}
+	if records, _ := wm.GetExtractData(1, 20); len(records) != 1 || records[0].BlockHeight != 5 {
+		t.Errorf("extract data above snapshot should be deleted, got: %d", len(records))
+	}
+	if seq, _ := wm.GetLastExtractDataSequence("account"); seq != 1 {
+		t.Errorf("account sequence should be restored to 1, got: %d", seq)
+	}
+	if seq, _ := wm.GetLastExtractDataSequence("other"); seq != 0 {
+		t.Errorf("other sequence should be reset, got: %d", seq)
+	}
+	if snapshots, _ = wm.ListScanSnapshots(); len(snapshots) != 1 {
+		t.Errorf("snapshots above restored height should be deleted, got: %d", len(snapshots))
+	}
+
+	//重新通知时按顺序分配序号
+	wm.SaveExtractData(15, map[string]*openwallet.TxExtractData{"account": extractData("tx15", 15)})
+	if seq, _ := wm.GetLastExtractDataSequence("account"); seq != 2 {
+		t.Errorf("re-notified extract data should continue sequence, got: %d", seq)
+	}
+
+	//快照区块已被分叉替换时使用更早的快照
+	wm.SaveLocalNewBlock(20, testHash("block20"))
+	wm.saveScanSnapshot(12, testHash("orphan"))
+	if snapshot, err = bs.RestoreToHeight(14); err != nil || snapshot.Height != 10 {
+		t.Errorf("orphan snapshot should be skipped, got: %+v, %v", snapshot, err)
+	}
+
+	//只保留最近的快照
+	wm.Config.ScanSnapshotRetention = 2
+	for _, h := range []uint64{20, 30, 40} {
+		wm.saveScanSnapshot(h, testHash(fmt.Sprintf("block%d", h)))
+	}
+	if snapshots, _ = wm.ListScanSnapshots(); len(snapshots) != 2 || snapshots[1].Height != 30 {
+		t.Errorf("snapshots should be pruned by retention, got: %d", len(snapshots))
+	}
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DegradedNodeDown     = "node_down"     //节点无法获取区块高度
+	DegradedExplorerDown = "explorer_down" //浏览器无法获取区块高度
+	DegradedDBBusy       = "db_busy"       //本地数据库被其他进程占用
+	DegradedHeadLag      = "head_lag"      //节点高度落后，暂停通知提取结果，见HeadLagThreshold
+
+	ScanErrorSourceNode     = "node"     //节点请求失败
+	ScanErrorSourceExplorer = "explorer" //浏览器请求失败
+	ScanErrorSourceDB       = "db"       //本地数据库操作失败
+	ScanErrorSourceLease    = "lease"    //无法持有扫描租约
+	ScanErrorSourceObserver = "observer" //观察者处理通知失败
+)
+
+//ScanError 扫描器最近发生的错误
+type ScanError struct {
+	Source  string //错误来源，见ScanErrorSourceNode等
+	Height  uint64 //相关的区块高度，0表示与区块无关
+	Message string
+	Time    int64
+}
+
+//DegradedState 一项降级状态
+type DegradedState struct {
+	Degraded bool
+	Since    int64  //进入当前状态的时间，0表示启动后未进入过降级状态
+	Reason   string //最近一次降级的原因
+	UpdateAt int64  //最近一次检查的时间
+}
+
+//ScannerStatus 扫描器的运行状态，用于管理界面展示适配器健康状况
+type ScannerStatus struct {
+	Scanning     bool
+	LocalHeight  uint64
+	LocalHash    string
+	LastScanAt   int64                     //最近一次扫描任务结束的时间
+	Degraded     map[string]*DegradedState //各项降级状态，key见DegradedNodeDown等
+	OpenBreakers []string                  //已熔断的可选功能RPC方法
+	LastErrors   []*ScanError              //最近的错误，最新的在前，数量见StatusErrorHistory
+	CreateAt     int64
+}
+
+//Healthy 没有任何降级状态和熔断的RPC方法
+func (s *ScannerStatus) Healthy() bool {
+	for _, d := range s.Degraded {
+		if d.Degraded {
+			return false
+		}
+	}
+	return len(s.OpenBreakers) == 0
+}
+
+//scannerHealth 最近的错误和降级状态
+type scannerHealth struct {
+	mu         sync.Mutex
+	errors     []*ScanError //环形缓冲，next为下一次写入的位置
+	next       int
+	degraded   map[string]*DegradedState
+	lastScanAt int64
+}
+
+func newScannerHealth() *scannerHealth {
+	h := &scannerHealth{degraded: make(map[string]*DegradedState)}
+	for _, name := range []string{DegradedNodeDown, DegradedExplorerDown, DegradedDBBusy, DegradedHeadLag} {
+		h.degraded[name] = &DegradedState{}
+	}
+	return h
+}
+
+//recordScanError 记录最近的错误，超过保留数量时覆盖最早的
+func (bs *NEOBlockScanner) recordScanError(source string, height uint64, err error) {
+
+	if bs == nil || bs.health == nil || err == nil {
+		return
+	}
+
+	size := bs.wm.config().StatusErrorHistory
+	if size <= 0 {
+		return
+	}
+
+	e := &ScanError{Source: source, Height: height, Message: err.Error(), Time: time.Now().Unix()}
+
+	h := bs.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.errors) != size {
+		//保留数量变化时只保留最近的错误
+		recent := h.recentErrors()
+		if len(recent) >= size {
+			recent = recent[:size-1]
+		}
+		h.errors = make([]*ScanError, size)
+		for i := range recent {
+			h.errors[len(recent)-1-i] = recent[i]
+		}
+		h.next = len(recent)
+	}
+	h.errors[h.next] = e
+	h.next = (h.next + 1) % size
+}
+
+//recentErrors 按时间倒序的最近错误，调用者持有锁
+func (h *scannerHealth) recentErrors() []*ScanError {
+	list := make([]*ScanError, 0, len(h.errors))
+	for i := 1; i <= len(h.errors); i++ {
+		e := h.errors[(h.next-i+len(h.errors))%len(h.errors)]
+		if e == nil {
+			break
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+//setDegraded 更新降级状态，状态变化时记录时间和日志
+func (bs *NEOBlockScanner) setDegraded(name string, degraded bool, reason error) {
+
+	if bs == nil || bs.health == nil {
+		return
+	}
+
+	h := bs.health
+	h.mu.Lock()
+	d, ok := h.degraded[name]
+	if !ok {
+		d = &DegradedState{}
+		h.degraded[name] = d
+	}
+	now := time.Now().Unix()
+	changed := d.Degraded != degraded
+	d.Degraded = degraded
+	d.UpdateAt = now
+	if changed {
+		d.Since = now
+	}
+	if degraded && reason != nil {
+		d.Reason = reason.Error()
+	}
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if degraded {
+		bs.wm.Log.Std.Warning("block scanner degraded: %s, reason: %v", name, reason)
+	} else {
+		bs.wm.Log.Std.Notice("block scanner recovered: %s", name)
+	}
+}
+
+//chainUnavailable 获取区块高度失败，按数据源记录节点或浏览器不可用
+func (bs *NEOBlockScanner) chainUnavailable(err error) {
+	source, flag := ScanErrorSourceNode, DegradedNodeDown
+	if bs.wm.config().RPCServerType == RPCServerExplorer {
+		source, flag = ScanErrorSourceExplorer, DegradedExplorerDown
+	}
+	bs.recordScanError(source, 0, err)
+	bs.setDegraded(flag, true, err)
+}
+
+//chainAvailable 获取区块高度成功，清除当前数据源的不可用状态
+func (bs *NEOBlockScanner) chainAvailable() {
+	flag := DegradedNodeDown
+	if bs.wm.config().RPCServerType == RPCServerExplorer {
+		flag = DegradedExplorerDown
+	}
+	bs.setDegraded(flag, false, nil)
+}
+
+//chainErrorSource 区块数据请求失败时的错误来源
+func (bs *NEOBlockScanner) chainErrorSource() string {
+	if bs.wm.config().RPCServerType == RPCServerExplorer {
+		return ScanErrorSourceExplorer
+	}
+	return ScanErrorSourceNode
+}
+
+//scanTaskDone 记录扫描任务结束的时间
+func (bs *NEOBlockScanner) scanTaskDone() {
+	bs.health.mu.Lock()
+	bs.health.lastScanAt = time.Now().Unix()
+	bs.health.mu.Unlock()
+}
+
+//Status 扫描器的运行状态，包括最近的错误、降级状态和时间，不请求节点
+func (bs *NEOBlockScanner) Status() *ScannerStatus {
+
+	status := &ScannerStatus{
+		Scanning:     bs.Scanning,
+		Degraded:     make(map[string]*DegradedState),
+		OpenBreakers: make([]string, 0),
+		CreateAt:     time.Now().Unix(),
+	}
+
+	bs.health.mu.Lock()
+	status.LastScanAt = bs.health.lastScanAt
+	status.LastErrors = bs.health.recentErrors()
+	for name, d := range bs.health.degraded {
+		copied := *d
+		status.Degraded[name] = &copied
+	}
+	bs.health.mu.Unlock()
+
+	for _, b := range bs.wm.RPCBreakerStatus() {
+		if b.Open {
+			status.OpenBreakers = append(status.OpenBreakers, fmt.Sprintf("%s(%s)", b.Method, b.Feature))
+		}
+	}
+
+	//数据库被占用时不等待锁，保留已知的扫描高度为0
+	if !status.Degraded[DegradedDBBusy].Degraded {
+		status.LocalHeight, status.LocalHash = bs.wm.GetLocalNewBlock()
+	}
+
+	return status
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+func TestNEOBlockScanner_Status(t *testing.T) {
+	server := newTestRPCServer(testChainHandler(5, "0x"))
+	down := newTestRPCServer(nil)
+	down.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.DBLockTimeout = 100 * time.Millisecond
+	wm.WalletClient = NewClient(down.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+	defer server.Close()
+
+	bs := wm.Blockscanner
+	bs.Scanning = true
+	wm.SaveLocalNewBlock(5, "0x5")
+	if status := bs.Status(); !status.Healthy() || status.LocalHeight != 5 || len(status.LastErrors) != 0 {
+		t.Fatalf("unexpected initial status: %+v", status)
+	}
+
+	//节点不可用
+	bs.ScanBlockTask()
+	status := bs.Status()
+	nodeDown := status.Degraded[DegradedNodeDown]
+	if status.Healthy() || !nodeDown.Degraded || nodeDown.Since == 0 || len(nodeDown.Reason) == 0 || status.LastScanAt == 0 {
+		t.Fatalf("node should be down, status: %+v, node: %+v", status, nodeDown)
+	}
+	if len(status.LastErrors) != 1 || status.LastErrors[0].Source != ScanErrorSourceNode {
+		t.Errorf("node error should be recorded, got: %+v", status.LastErrors)
+	}
+
+	//节点恢复，保留最近的错误
+	wm.WalletClient = NewClient(server.URL, "", false)
+	bs.ScanBlockTask()
+	status = bs.Status()
+	if !status.Healthy() || status.Degraded[DegradedNodeDown].Degraded || len(status.LastErrors) != 1 {
+		t.Errorf("node should be recovered, status: %+v", status)
+	}
+
+	//数据库被其他进程占用
+	holder, err := storm.Open(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	if err != nil {
+		t.Fatalf("open local db failed, unexpected error: %v", err)
+	}
+	wm.SaveLocalNewBlock(6, "0x6")
+	status = bs.Status()
+	if !status.Degraded[DegradedDBBusy].Degraded || status.LastErrors[0].Source != ScanErrorSourceDB || status.LocalHeight != 0 {
+		t.Errorf("db should be busy, status: %+v", status)
+	}
+	holder.Close()
+	wm.SaveLocalNewBlock(6, "0x6")
+	if status = bs.Status(); status.Degraded[DegradedDBBusy].Degraded || status.LocalHeight != 6 {
+		t.Errorf("db should be released, status: %+v", status)
+	}
+
+	//超过保留数量时覆盖最早的错误
+	wm.Config.StatusErrorHistory = 3
+	for i := 1; i <= 5; i++ {
+		bs.recordScanError(ScanErrorSourceObserver, uint64(i), fmt.Errorf("error %d", i))
+	}
+	errs := bs.Status().LastErrors
+	if len(errs) != 3 || errs[0].Height != 5 || errs[2].Height != 3 {
+		t.Errorf("unexpected recent errors: %+v", errs)
+	}
+	wm.Config.StatusErrorHistory = 0
+	bs.recordScanError(ScanErrorSourceObserver, 6, fmt.Errorf("error 6"))
+	if errs := bs.Status().LastErrors; len(errs) != 3 {
+		t.Errorf("errors should not be recorded when disabled, got: %d", len(errs))
+	}
+}
@@ -0,0 +1,485 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	JobNameClaimGAS  = "claim_gas"  //认领GAS
+	JobNameCompactDB = "compact_db" //压缩本地数据库
+	JobNameRebalance = "rebalance"  //UTXO归集与冷热平衡
+
+	JobNameArchiveWatchAddress = "archive_watch_address" //归档过期观测地址
+	JobNamePurgeUnscanRecord   = "purge_unscan_record"   //清理软删除的未扫记录
+	JobNamePruneExtractData    = "prune_extract_data"    //清理过期的提取结果
+
+	//jobRunHistoryLimit 每个任务保留的执行记录数量
+	jobRunHistoryLimit = 100
+)
+
+//MaintenanceJob 定期执行的维护任务
+type MaintenanceJob struct {
+	Name     string        //任务名称，唯一
+	Interval time.Duration //执行间隔
+	Jitter   time.Duration //每次执行前额外随机等待[0, Jitter)，避免多个实例同时执行
+	Enabled  bool          //是否启用，未启用的任务只能手动执行
+	Urgent   bool          //按时执行的任务，不加随机等待，追赶区块期间不推迟
+	Run      func() error  //任务内容
+}
+
+//JobRun 维护任务的一次执行记录
+type JobRun struct {
+	ID      int64  `storm:"id,increment"`
+	Job     string `storm:"index"`
+	Manual  bool   //是否手动执行
+	StartAt int64
+	EndAt   int64
+	Success bool
+	Error   string
+}
+
+//scheduledJob 调度中的任务
+type scheduledJob struct {
+	job     *MaintenanceJob
+	stop    chan struct{}
+	running bool
+}
+
+//Scheduler 内置维护任务调度器，每个任务独立按间隔加随机抖动执行，同一任务不会重叠执行
+type Scheduler struct {
+	wm      *WalletManager
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	started bool
+}
+
+func NewScheduler(wm *WalletManager) *Scheduler {
+	s := Scheduler{}
+	s.wm = wm
+	s.jobs = make(map[string]*scheduledJob)
+	return &s
+}
+
+//AddJob 添加任务，调度器已启动时立即开始调度启用的任务
+func (s *Scheduler) AddJob(job *MaintenanceJob) error {
+
+	if job == nil || len(job.Name) == 0 || job.Run == nil {
+		return fmt.Errorf("maintenance job name and run func is required")
+	}
+
+	if job.Interval <= 0 {
+		return fmt.Errorf("maintenance job: %s interval should be greater than zero", job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exist := s.jobs[job.Name]; exist {
+		return fmt.Errorf("maintenance job: %s already exists", job.Name)
+	}
+
+	sj := &scheduledJob{job: job}
+	s.jobs[job.Name] = sj
+	if s.started && job.Enabled {
+		s.schedule(sj)
+	}
+
+	return nil
+}
+
+//RemoveJob 停止并移除任务
+func (s *Scheduler) RemoveJob(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sj, exist := s.jobs[name]
+	if !exist {
+		return
+	}
+	s.unschedule(sj)
+	delete(s.jobs, name)
+}
+
+//SetJobEnabled 启用或停用任务
+func (s *Scheduler) SetJobEnabled(name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sj, exist := s.jobs[name]
+	if !exist {
+		return fmt.Errorf("maintenance job: %s not found", name)
+	}
+
+	sj.job.Enabled = enabled
+	if !s.started {
+		return nil
+	}
+	if enabled {
+		s.schedule(sj)
+	} else {
+		s.unschedule(sj)
+	}
+
+	return nil
+}
+
+//Jobs 已添加的任务，按名称排序
+func (s *Scheduler) Jobs() []*MaintenanceJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*MaintenanceJob, 0, len(s.jobs))
+	for _, sj := range s.jobs {
+		jobs = append(jobs, sj.job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs
+}
+
+//Start 开始调度所有启用的任务
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, sj := range s.jobs {
+		if sj.job.Enabled {
+			s.schedule(sj)
+		}
+	}
+}
+
+//Stop 停止调度，正在执行的任务会执行完毕
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return
+	}
+	s.started = false
+
+	for _, sj := range s.jobs {
+		s.unschedule(sj)
+	}
+}
+
+//schedule 启动任务的调度线程，调用者持有锁
+func (s *Scheduler) schedule(sj *scheduledJob) {
+	if sj.stop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	sj.stop = stop
+	go func() {
+		for {
+			wait := sj.job.Interval
+			if sj.job.Jitter > 0 {
+				wait += time.Duration(rand.Int63n(int64(sj.job.Jitter)))
+			}
+
+			select {
+			case <-time.After(wait):
+				//追赶区块期间推迟定时执行，手动执行不受影响
+				if !sj.job.Urgent && s.wm.Blockscanner != nil && s.wm.Blockscanner.shedLoad(ShedWorkMaintenance) {
+					s.wm.Log.Std.Info("block scanner is catching up, maintenance job: %s is deferred", sj.job.Name)
+					continue
+				}
+				if _, err := s.runJob(sj, false); err != nil {
+					s.wm.Log.Std.Error("maintenance job: %s failed, unexpected error: %v", sj.job.Name, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+//unschedule 停止任务的调度线程，调用者持有锁
+func (s *Scheduler) unschedule(sj *scheduledJob) {
+	if sj.stop != nil {
+		close(sj.stop)
+		sj.stop = nil
+	}
+}
+
+//RunJob 立即执行一次任务，不论是否启用，任务正在执行时返回错误
+func (s *Scheduler) RunJob(name string) (*JobRun, error) {
+	s.mu.Lock()
+	sj, exist := s.jobs[name]
+	s.mu.Unlock()
+
+	if !exist {
+		return nil, fmt.Errorf("maintenance job: %s not found", name)
+	}
+
+	return s.runJob(sj, true)
+}
+
+//runJob 执行任务并记录执行历史，返回的错误为任务执行错误
+func (s *Scheduler) runJob(sj *scheduledJob, manual bool) (*JobRun, error) {
+
+	s.mu.Lock()
+	if sj.running {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("maintenance job: %s is running", sj.job.Name)
+	}
+	sj.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		sj.running = false
+		s.mu.Unlock()
+	}()
+
+	run := &JobRun{
+		Job:     sj.job.Name,
+		Manual:  manual,
+		StartAt: time.Now().Unix(),
+	}
+
+	err := sj.job.Run()
+
+	run.EndAt = time.Now().Unix()
+	run.Success = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	//历史记录保存失败不影响任务结果
+	if saveErr := s.wm.saveJobRun(run); saveErr != nil {
+		s.wm.Log.Std.Warning("save maintenance job: %s run history failed, unexpected error: %v", sj.job.Name, saveErr)
+	}
+
+	return run, err
+}
+
+//saveJobRun 保存执行记录，只保留最近的记录
+func (wm *WalletManager) saveJobRun(run *JobRun) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Save(run)
+	if err != nil {
+		return err
+	}
+
+	var expired []*JobRun
+	err = db.Select(q.Eq("Job", run.Job)).OrderBy("ID").Reverse().Skip(jobRunHistoryLimit).Find(&expired)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	for _, r := range expired {
+		if err := db.DeleteStruct(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//GetJobRuns 获取任务的执行记录，job为空时返回所有任务，最近的在前，limit为0时不限制
+func (wm *WalletManager) GetJobRuns(job string, limit int) ([]*JobRun, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	matchers := make([]q.Matcher, 0)
+	if len(job) > 0 {
+		matchers = append(matchers, q.Eq("Job", job))
+	}
+
+	query := db.Select(matchers...).OrderBy("ID").Reverse()
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*JobRun
+	err = query.Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//NewClaimGASJob 认领GAS任务，依次认领每个地址可领取的GAS
+func (wm *WalletManager) NewClaimGASJob(addresses []string, interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNameClaimGAS,
+		Interval: interval,
+		Run: func() error {
+			failed := 0
+			for _, address := range addresses {
+				if err := wm.ClaimGAS(address); err != nil {
+					wm.Log.Std.Error("claim gas of address: %s failed, unexpected error: %v", address, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("claim gas of %d/%d addresses failed", failed, len(addresses))
+			}
+			return nil
+		},
+	}
+}
+
+//NewCompactDBJob 压缩本地数据库任务
+func (wm *WalletManager) NewCompactDBJob(interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNameCompactDB,
+		Interval: interval,
+		Run: func() error {
+			before, after, err := wm.CompactLocalDB()
+			if err != nil {
+				return err
+			}
+			wm.Log.Std.Info("local db compacted from %d bytes to %d bytes", before, after)
+			return nil
+		},
+	}
+}
+
+//NewArchiveWatchAddressJob 归档过期观测地址任务
+func (wm *WalletManager) NewArchiveWatchAddressJob(interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNameArchiveWatchAddress,
+		Interval: interval,
+		Run: func() error {
+			_, err := wm.Blockscanner.ArchiveExpiredWatchAddresses()
+			return err
+		},
+	}
+}
+
+//NewPurgeUnscanRecordJob 清理软删除的未扫记录任务，保留retention时长内删除的记录
+func (wm *WalletManager) NewPurgeUnscanRecordJob(retention, interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNamePurgeUnscanRecord,
+		Interval: interval,
+		Run: func() error {
+			count, err := wm.purgeUnscanRecords(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			wm.Log.Std.Info("purged %d soft deleted unscan records", count)
+			return nil
+		},
+	}
+}
+
+//NewPruneExtractDataJob 清理提取结果任务，保留本地高度往前retentionBlocks个区块的记录
+func (wm *WalletManager) NewPruneExtractDataJob(retentionBlocks uint64, interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNamePruneExtractData,
+		Interval: interval,
+		Run: func() error {
+			count, err := wm.PruneExtractData(retentionBlocks)
+			if err != nil {
+				return err
+			}
+			wm.Log.Std.Info("pruned %d extract data records", count)
+			return nil
+		},
+	}
+}
+
+//NewRebalanceJob UTXO归集与冷热平衡任务，需要调用者提供钱包数据接口
+func (wm *WalletManager) NewRebalanceJob(wrapper openwallet.WalletDAI, policy *RebalancePolicy, interval time.Duration, dryRun bool, handler RebalanceHandler) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNameRebalance,
+		Interval: interval,
+		Run: func() error {
+			plan, err := wm.PlanRebalance(wrapper, policy, dryRun)
+			if err == nil {
+				wm.Log.Std.Info("%s", plan)
+			}
+			if handler != nil {
+				handler(plan, err)
+			}
+			return err
+		},
+	}
+}
+
+//addConfiguredJobs 添加配置文件中的内置任务，按配置启用，已添加的任务不会重复添加
+func (wm *WalletManager) addConfiguredJobs() {
+
+	jobs := make([]*MaintenanceJob, 0)
+	if len(wm.config().ClaimGASAddresses) > 0 {
+		job := wm.NewClaimGASJob(wm.config().ClaimGASAddresses, wm.config().ClaimGASInterval)
+		job.Enabled = wm.config().ClaimGASJob
+		jobs = append(jobs, job)
+	}
+	job := wm.NewCompactDBJob(wm.config().CompactDBInterval)
+	job.Enabled = wm.config().CompactDBJob
+	jobs = append(jobs, job)
+	job = wm.NewArchiveWatchAddressJob(wm.config().ArchiveWatchAddressInterval)
+	job.Enabled = wm.config().ArchiveWatchAddressJob
+	jobs = append(jobs, job)
+	job = wm.NewPurgeUnscanRecordJob(wm.config().UnscanRecordRetention, wm.config().PurgeUnscanRecordInterval)
+	job.Enabled = wm.config().PurgeUnscanRecordJob
+	jobs = append(jobs, job)
+	job = wm.NewPruneExtractDataJob(wm.config().ExtractDataRetentionBlocks, wm.config().PruneExtractDataInterval)
+	job.Enabled = wm.config().PruneExtractDataJob
+	jobs = append(jobs, job)
+	job = wm.NewScheduledBroadcastJob(wm.config().ScheduledBroadcastInterval)
+	job.Enabled = wm.config().ScheduledBroadcastJob
+	jobs = append(jobs, job)
+	job = wm.NewStorageMetricsJob(wm.config().StorageMetricsInterval)
+	job.Enabled = wm.config().StorageMetricsJob
+	jobs = append(jobs, job)
+
+	for _, job := range jobs {
+		if !job.Urgent {
+			job.Jitter = wm.config().SchedulerJitter
+		}
+		wm.Scheduler.mu.Lock()
+		_, exist := wm.Scheduler.jobs[job.Name]
+		wm.Scheduler.mu.Unlock()
+		if exist {
+			continue
+		}
+		if err := wm.Scheduler.AddJob(job); err != nil {
+			wm.Log.Std.Error("add maintenance job: %s failed, unexpected error: %v", job.Name, err)
+		}
+	}
+}
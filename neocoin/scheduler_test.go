@@ -0,0 +1,150 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunJob(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	fail := false
+	err := wm.Scheduler.AddJob(&MaintenanceJob{
+		Name:     "reconcile",
+		Interval: time.Hour,
+		Run: func() error {
+			if fail {
+				return errors.New("balance mismatch")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Errorf("AddJob failed unexpected error: %v\n", err)
+		return
+	}
+
+	if err := wm.Scheduler.AddJob(&MaintenanceJob{Name: "reconcile", Interval: time.Hour, Run: func() error { return nil }}); err == nil {
+		t.Errorf("AddJob should fail with duplicate name")
+	}
+
+	wm.Scheduler.RunJob("reconcile")
+	fail = true
+	if _, err := wm.Scheduler.RunJob("reconcile"); err == nil {
+		t.Errorf("RunJob should return job error")
+	}
+
+	runs, err := wm.GetJobRuns("reconcile", 0)
+	if err != nil || len(runs) != 2 {
+		t.Errorf("GetJobRuns failed, runs: %d, unexpected error: %v", len(runs), err)
+		return
+	}
+	if runs[0].Success || runs[0].Error != "balance mismatch" || !runs[1].Success || !runs[0].Manual {
+		t.Errorf("unexpected job runs: %+v, %+v", runs[0], runs[1])
+	}
+}
+
+func TestScheduler_Schedule(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	var enabled, disabled int32
+	wm.Scheduler.AddJob(&MaintenanceJob{
+		Name:     "enabled",
+		Interval: 20 * time.Millisecond,
+		Jitter:   10 * time.Millisecond,
+		Enabled:  true,
+		Run: func() error {
+			atomic.AddInt32(&enabled, 1)
+			return nil
+		},
+	})
+	wm.Scheduler.AddJob(&MaintenanceJob{
+		Name:     "disabled",
+		Interval: 20 * time.Millisecond,
+		Run: func() error {
+			atomic.AddInt32(&disabled, 1)
+			return nil
+		},
+	})
+
+	wm.Scheduler.Start()
+	time.Sleep(200 * time.Millisecond)
+	wm.Scheduler.Stop()
+
+	if atomic.LoadInt32(&enabled) == 0 || atomic.LoadInt32(&disabled) != 0 {
+		t.Errorf("enabled job runs: %d, disabled job runs: %d", enabled, disabled)
+	}
+
+	stopped := atomic.LoadInt32(&enabled)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&enabled) > stopped+1 {
+		t.Errorf("job should not run after scheduler stopped")
+	}
+}
+
+func TestWalletManager_CompactLocalDB(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	for i := uint64(1); i <= 500; i++ {
+		wm.SaveLocalBlock(&Block{Hash: fmt.Sprintf("0x%064d", i), Height: i})
+	}
+	wm.DeleteLocalDataAboveHeight(1)
+
+	before, after, err := wm.CompactLocalDB()
+	if err != nil {
+		t.Errorf("CompactLocalDB failed unexpected error: %v\n", err)
+		return
+	}
+	if after >= before {
+		t.Errorf("local db size should shrink, before: %d, after: %d", before, after)
+	}
+
+	info, _ := os.Stat(filepath.Join(wm.Config.DBPath, wm.Config.BlockchainFile))
+	if info.Size() != after {
+		t.Errorf("local db file size: %d, expected: %d", info.Size(), after)
+	}
+
+	block, err := wm.GetLocalBlock(1)
+	if err != nil || block.Hash != fmt.Sprintf("0x%064d", 1) {
+		t.Errorf("GetLocalBlock after compaction failed, block: %v, unexpected error: %v", block, err)
+	}
+
+	//自增序号在压缩后保持连续
+	wm.Scheduler.AddJob(&MaintenanceJob{Name: "noop", Interval: time.Hour, Run: func() error { return nil }})
+	wm.Scheduler.RunJob("noop")
+	if _, _, err := wm.CompactLocalDB(); err != nil {
+		t.Errorf("CompactLocalDB failed unexpected error: %v\n", err)
+	}
+	wm.Scheduler.RunJob("noop")
+	runs, _ := wm.GetJobRuns("noop", 0)
+	if len(runs) != 2 || runs[0].ID <= runs[1].ID {
+		t.Errorf("unexpected job runs after compaction: %v", runs)
+	}
+}
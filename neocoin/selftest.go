@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+const (
+	SelfTestPass = "pass" //检查通过
+	SelfTestFail = "fail" //检查失败
+	SelfTestSkip = "skip" //未配置，跳过检查
+)
+
+//自检使用的固定公钥，检查地址推导是否自洽
+const selfTestPubkey = "031a6c6fbbdf02ca351745fa86b9ba5a9452d785ac4f7fc2b7548ca2a46c4fcf4a"
+
+//SelfTestCheck 单项自检结果
+type SelfTestCheck struct {
+	Name     string
+	Status   string
+	Message  string
+	Duration time.Duration
+}
+
+//SelfTestReport 适配器自检报告
+type SelfTestReport struct {
+	Symbol   string
+	Passed   bool
+	Checks   []*SelfTestCheck
+	CreateAt int64
+}
+
+//Failed 未通过的检查项
+func (r *SelfTestReport) Failed() []*SelfTestCheck {
+	failed := make([]*SelfTestCheck, 0)
+	for _, c := range r.Checks {
+		if c.Status == SelfTestFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+//errSelfTestSkip 检查项未配置，跳过
+type errSelfTestSkip string
+
+func (e errSelfTestSkip) Error() string {
+	return string(e)
+}
+
+//SelfTest 启动自检：节点连通、网络匹配、区块获取、地址推导、本地数据库读写、浏览器可用性
+func (wm *WalletManager) SelfTest() *SelfTestReport {
+
+	report := &SelfTestReport{
+		Symbol:   wm.Symbol(),
+		Passed:   true,
+		Checks:   make([]*SelfTestCheck, 0),
+		CreateAt: time.Now().Unix(),
+	}
+
+	var height uint64
+
+	run := func(name string, check func() (string, error)) {
+		start := time.Now()
+		message, err := check()
+		c := &SelfTestCheck{Name: name, Status: SelfTestPass, Message: message}
+		if err != nil {
+			if _, skip := err.(errSelfTestSkip); skip {
+				c.Status = SelfTestSkip
+			} else {
+				c.Status = SelfTestFail
+				report.Passed = false
+			}
+			c.Message = err.Error()
+		}
+		c.Duration = time.Since(start)
+		report.Checks = append(report.Checks, c)
+	}
+
+	run("node", func() (string, error) {
+		var err error
+		height, err = wm.GetBlockHeight()
+		if err != nil {
+			return "", fmt.Errorf("node is unreachable: %v", err)
+		}
+		return fmt.Sprintf("block height: %d", height), nil
+	})
+
+	run("network", func() (string, error) {
+		if len(wm.config().GenesisBlockHash) == 0 {
+			return "", errSelfTestSkip("genesis block hash is not configured")
+		}
+		hash, err := wm.GetBlockHash(0)
+		if err != nil {
+			return "", fmt.Errorf("get genesis block hash failed: %v", err)
+		}
+		if hash != wm.config().GenesisBlockHash {
+			return "", fmt.Errorf("genesis block hash: %s is not equal to configured: %s", hash, wm.config().GenesisBlockHash)
+		}
+		return hash, nil
+	})
+
+	run("block", func() (string, error) {
+		hash, err := wm.GetBlockHash(height)
+		if err != nil {
+			return "", fmt.Errorf("get block hash on height: %d failed: %v", height, err)
+		}
+		block, err := wm.GetBlock(hash)
+		if err != nil {
+			return "", fmt.Errorf("get block: %s failed: %v", hash, err)
+		}
+		if block.Height != height || block.Hash != hash {
+			return "", fmt.Errorf("block height: %d, hash: %s is not equal to requested height: %d, hash: %s", block.Height, block.Hash, height, hash)
+		}
+		return hash, nil
+	})
+
+	run("address", func() (string, error) {
+		pub, _ := hex.DecodeString(selfTestPubkey)
+		address, err := wm.Decoder.PublicKeyToAddress(pub, wm.config().IsTestNet)
+		if err != nil {
+			return "", err
+		}
+		script, expected, err := neoTransaction.CreateSignatureRedeemScript(pub)
+		if err != nil {
+			return "", err
+		}
+		if address != expected {
+			return "", fmt.Errorf("derived address: %s is not equal to verification script address: %s", address, expected)
+		}
+		hash, err := addressToScriptHash(address)
+		if err != nil {
+			return "", err
+		}
+		if neoTransaction.ScriptHashToAddress(hash) != address {
+			return "", fmt.Errorf("address: %s decode round-trip failed", address)
+		}
+		return fmt.Sprintf("%s, script: %s", address, hex.EncodeToString(script)), nil
+	})
+
+	run("database", func() (string, error) {
+		db, err := wm.openLocalDB(wm.config().BlockchainFile)
+		if err != nil {
+			return "", fmt.Errorf("open local db failed: %v", err)
+		}
+		defer db.Close()
+		probe := time.Now().UnixNano()
+		if err = db.Set("selftest", "probe", probe); err != nil {
+			return "", fmt.Errorf("write local db failed: %v", err)
+		}
+		var got int64
+		if err = db.Get("selftest", "probe", &got); err != nil || got != probe {
+			return "", fmt.Errorf("read local db failed: %v", err)
+		}
+		db.Delete("selftest", "probe")
+		return wm.config().BlockchainFile, nil
+	})
+
+	run("explorer", func() (string, error) {
+		if wm.explorerClient() == nil {
+			return "", errSelfTestSkip("explorer api is not configured")
+		}
+		explorerHeight, err := wm.getBlockHeightByExplorer()
+		if err != nil {
+			return "", fmt.Errorf("explorer is unavailable: %v", err)
+		}
+		return fmt.Sprintf("block height: %d", explorerHeight), nil
+	})
+
+	for _, c := range report.Checks {
+		if c.Status == SelfTestFail {
+			wm.Log.Std.Error("self test [%s] failed: %s", c.Name, c.Message)
+		}
+	}
+
+	return report
+}
+
+//waitSelfTest 阻塞直到自检通过，未通过时按间隔重试
+func (bs *NEOBlockScanner) waitSelfTest() {
+	for {
+		report := bs.wm.SelfTest()
+		if report.Passed {
+			bs.wm.Log.Std.Info("self test passed, block scanner starting")
+			return
+		}
+		bs.wm.Log.Std.Warning("self test failed %d checks, retry after %v", len(report.Failed()), bs.wm.config().SelfTestRetryInterval)
+		time.Sleep(bs.wm.config().SelfTestRetryInterval)
+	}
+}
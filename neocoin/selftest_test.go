@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalletManager_SelfTest(t *testing.T) {
+	genesis := testHash("genesis")
+	tip := testHash("tip")
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return 11
+		case "getblockhash":
+			if params[0].(float64) == 0 {
+				return genesis
+			}
+			return tip
+		case "getblock":
+			return map[string]interface{}{"index": 10, "hash": tip, "previousblockhash": testHash("9"), "time": 1000, "tx": []interface{}{}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	report := wm.SelfTest()
+	if !report.Passed {
+		t.Errorf("self test should pass, failed: %+v", report.Failed())
+	}
+
+	status := make(map[string]string)
+	for _, c := range report.Checks {
+		status[c.Name] = c.Status
+	}
+	if status["node"] != SelfTestPass || status["block"] != SelfTestPass || status["address"] != SelfTestPass || status["database"] != SelfTestPass {
+		t.Errorf("unexpected check status: %v", status)
+	}
+	if status["network"] != SelfTestSkip || status["explorer"] != SelfTestSkip {
+		t.Errorf("unconfigured checks should be skipped: %v", status)
+	}
+
+	//网络不匹配
+	wm.Config.GenesisBlockHash = testHash("other")
+	report = wm.SelfTest()
+	if report.Passed || len(report.Failed()) != 1 || report.Failed()[0].Name != "network" {
+		t.Errorf("network mismatch should fail, failed: %+v", report.Failed())
+	}
+
+	wm.Config.GenesisBlockHash = genesis
+	if report = wm.SelfTest(); !report.Passed {
+		t.Errorf("self test should pass with matched genesis, failed: %+v", report.Failed())
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//BatchIDParam 交易单ExtParam中的批量提币批次号，广播后按批次生成结算明细
+	BatchIDParam = "batchID"
+)
+
+//SettlementRecord 批量提币中一个接收方的结算明细
+type SettlementRecord struct {
+	ID        string `storm:"id"` //txid:vout
+	BatchID   string `storm:"index"`
+	TxID      string
+	Vout      int    //接收方的输出序号，合约转账为-1
+	Address   string //接收地址
+	Asset     string //资产ID或合约地址
+	Amount    string
+	FeeShare  string //按金额分摊的手续费
+	AccountID string
+	OrderID   string
+	Sid       string
+	CreateAt  int64
+}
+
+//SettlementReport 批量提币的结算报告
+type SettlementReport struct {
+	BatchID     string
+	TxIDs       []string
+	Recipients  []*SettlementRecord
+	TotalAmount decimal.Decimal
+	TotalFees   decimal.Decimal
+	GeneratedAt int64
+}
+
+//SetBatchID 设置交易单的批量提币批次号，同一批次拆分的多笔交易单使用相同的批次号
+func SetBatchID(rawTx *openwallet.RawTransaction, batchID string) error {
+	return rawTx.SetExtParam(BatchIDParam, batchID)
+}
+
+//batchID 读取交易单的批量提币批次号
+func batchID(rawTx *openwallet.RawTransaction) string {
+	if len(rawTx.ExtParam) == 0 {
+		return ""
+	}
+	return rawTx.GetExtParam().Get(BatchIDParam).String()
+}
+
+//newSettlementRecords 由已广播的交易单生成接收方的结算明细，找零输出不计入，
+//手续费按接收金额比例分摊，舍入误差计入最后一个接收方
+func (wm *WalletManager) newSettlementRecords(rawTx *openwallet.RawTransaction) ([]*SettlementRecord, error) {
+
+	var (
+		records   = make([]*SettlementRecord, 0)
+		decimals  = wm.Decimal()
+		accountID = ""
+		now       = time.Now().Unix()
+	)
+	if rawTx.Account != nil {
+		accountID = rawTx.Account.AccountID
+	}
+
+	newRecord := func(vout int, address, asset string, amount decimal.Decimal) *SettlementRecord {
+		return &SettlementRecord{
+			ID:        fmt.Sprintf("%s:%d", rawTx.TxID, vout),
+			BatchID:   batchID(rawTx),
+			TxID:      rawTx.TxID,
+			Vout:      vout,
+			Address:   address,
+			Asset:     asset,
+			Amount:    amount.StringFixed(decimals),
+			AccountID: accountID,
+			OrderID:   orderID(rawTx),
+			Sid:       rawTx.Sid,
+			CreateAt:  now,
+		}
+	}
+
+	amounts := make([]decimal.Decimal, 0)
+	if rawTx.Coin.IsContract {
+		//合约转账没有UTXO输出，按接收方记录
+		receivers := make([]string, 0, len(rawTx.To))
+		for address := range rawTx.To {
+			receivers = append(receivers, address)
+		}
+		sort.Strings(receivers)
+		for _, address := range receivers {
+			amount, err := decimal.NewFromString(rawTx.To[address])
+			if err != nil {
+				return nil, fmt.Errorf("amount of receiver: %s is invalid", address)
+			}
+			records = append(records, newRecord(-1, address, rawTx.Coin.Contract.Address, amount))
+			amounts = append(amounts, amount)
+		}
+	} else {
+		txBytes, err := hex.DecodeString(rawTx.RawHex)
+		if err != nil {
+			return nil, err
+		}
+		trx, err := neoTransaction.DecodeRawTransaction(txBytes)
+		if err != nil {
+			return nil, err
+		}
+		for i, out := range trx.Vouts {
+			address := out.GetAddress()
+			if _, ok := rawTx.To[address]; !ok {
+				continue
+			}
+			amount := decimal.New(int64(out.GetValue()), -decimals)
+			records = append(records, newRecord(i, address, "0x"+strings.TrimPrefix(out.GetAssetID(), "0x"), amount))
+			amounts = append(amounts, amount)
+		}
+	}
+
+	total := decimal.Zero
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+
+	fees, _ := decimal.NewFromString(rawTx.Fees)
+	allocated := decimal.Zero
+	for i, r := range records {
+		share := decimal.Zero
+		if i == len(records)-1 {
+			share = fees.Sub(allocated)
+		} else if total.GreaterThan(decimal.Zero) {
+			share = fees.Mul(amounts[i]).Div(total).Truncate(decimals)
+		}
+		allocated = allocated.Add(share)
+		r.FeeShare = share.StringFixed(decimals)
+	}
+
+	return records, nil
+}
+
+//saveSettlementRecords 广播批量提币交易单后保存结算明细，未设置批次号时不保存
+func (wm *WalletManager) saveSettlementRecords(rawTx *openwallet.RawTransaction) error {
+
+	if len(batchID(rawTx)) == 0 {
+		return nil
+	}
+
+	records, err := wm.newSettlementRecords(rawTx)
+	if err != nil {
+		return err
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range records {
+		if err = tx.Save(r); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetSettlementReport 按批次号获取批量提币的结算报告，接收方按交易单和输出序号排序，不存在返回nil
+func (wm *WalletManager) GetSettlementReport(batchID string) (*SettlementReport, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*SettlementRecord
+	err = db.Select(q.Eq("BatchID", batchID)).OrderBy("CreateAt", "TxID", "Vout").Find(&list)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get settlement records failed, unexpected error: %v", err)
+	}
+
+	report := &SettlementReport{
+		BatchID:     batchID,
+		TxIDs:       make([]string, 0),
+		Recipients:  list,
+		TotalAmount: decimal.Zero,
+		TotalFees:   decimal.Zero,
+		GeneratedAt: time.Now().Unix(),
+	}
+	for _, r := range list {
+		if len(report.TxIDs) == 0 || report.TxIDs[len(report.TxIDs)-1] != r.TxID {
+			report.TxIDs = append(report.TxIDs, r.TxID)
+		}
+		amount, _ := decimal.NewFromString(r.Amount)
+		fee, _ := decimal.NewFromString(r.FeeShare)
+		report.TotalAmount = report.TotalAmount.Add(amount)
+		report.TotalFees = report.TotalFees.Add(fee)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_SettlementReport(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "sendrawtransaction" {
+			return true
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.TxIDCheck = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	newBatchTx := func(vout uint16, fees string) *openwallet.RawTransaction {
+		txHex, _ := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction,
+			[]neoTransaction.Vin{{TxID: testHash(testHotAddress)[2:], Vout: vout}},
+			[]neoTransaction.Vout{
+				{Asset: neoTransaction.NeoGasAssetId, Address: testColdAddress1, Value: 100000000},
+				{Asset: neoTransaction.NeoGasAssetId, Address: testHotAddress, Value: 50000000},
+				{Asset: neoTransaction.NeoGasAssetId, Address: testColdAddress2, Value: 200000000},
+			},
+			nil)
+		rawTx := &openwallet.RawTransaction{
+			RawHex:      txHex,
+			Account:     &openwallet.AssetsAccount{AccountID: "hot"},
+			To:          map[string]string{testColdAddress1: "1", testColdAddress2: "2"},
+			Fees:        fees,
+			IsCompleted: true,
+		}
+		SetBatchID(rawTx, "payout-1")
+		return rawTx
+	}
+
+	//同一批次拆分为两笔交易单
+	var txid string
+	for i, fees := range []string{"0.001", "0.0001"} {
+		tx, err := wm.TxDecoder.SubmitRawTransaction(nil, newBatchTx(uint16(i), fees))
+		if err != nil {
+			t.Fatalf("SubmitRawTransaction failed, unexpected error: %v", err)
+		}
+		if i == 0 {
+			txid = tx.TxID
+		}
+	}
+
+	report, err := wm.GetSettlementReport("payout-1")
+	if err != nil || report == nil {
+		t.Fatalf("GetSettlementReport = %+v, %v", report, err)
+	}
+	if len(report.TxIDs) != 2 || len(report.Recipients) != 4 || report.TotalAmount.String() != "6" || report.TotalFees.String() != "0.0011" {
+		t.Fatalf("unexpected report, txids: %v, recipients: %d, amount: %s, fees: %s", report.TxIDs, len(report.Recipients), report.TotalAmount, report.TotalFees)
+	}
+
+	//找零输出不计入，手续费按金额分摊
+	records := make(map[string]*SettlementRecord)
+	for _, r := range report.Recipients {
+		if r.Address == testHotAddress {
+			t.Errorf("change output should not be reported")
+		}
+		if r.TxID == txid {
+			records[r.Address] = r
+		}
+	}
+	if r := records[testColdAddress1]; r == nil || r.Vout != 0 || r.Amount != "1.00000000" || r.FeeShare != "0.00033333" || r.Asset != "0x"+neoTransaction.NeoGasAssetId {
+		t.Errorf("unexpected settlement record: %+v", r)
+	}
+	if r := records[testColdAddress2]; r == nil || r.Vout != 2 || r.Amount != "2.00000000" || r.FeeShare != "0.00066667" {
+		t.Errorf("unexpected settlement record: %+v", r)
+	}
+
+	//未设置批次号时不记录
+	if report, err := wm.GetSettlementReport("other"); err != nil || report != nil {
+		t.Errorf("unknown batch should return nil, got: %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/hdkeystore"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//SignBenchmark 签名吞吐量测试结果
+type SignBenchmark struct {
+	Signatures       int           //签名数量
+	Workers          int           //并行签名的协程数
+	Elapsed          time.Duration //派生私钥和签名的总耗时
+	SignaturesPerSec float64       //每秒签名数
+}
+
+//signWorkers 并行签名的协程数，未配置时为CPU核数
+func (wm *WalletManager) signWorkers() int {
+	if workers := wm.config().SignWorkers; workers > 0 {
+		return workers
+	}
+	return runtime.NumCPU()
+}
+
+//signWithHDKey 使用钱包私钥为多个地址签名，派生私钥和签名并行处理，
+//有失败时按输入顺序返回第一个错误
+func (decoder *TransactionDecoder) signWithHDKey(unsignedTx string, key *hdkeystore.HDKey, keySignatures []*openwallet.KeySignature) error {
+	return decoder.signKeySignatures(unsignedTx, key, keySignatures, decoder.wm.signWorkers())
+}
+
+//signKeySignatures 使用workers个协程为keySignatures签名，每个签名只写入自己的keySignature
+func (decoder *TransactionDecoder) signKeySignatures(unsignedTx string, key *hdkeystore.HDKey, keySignatures []*openwallet.KeySignature, workers int) error {
+
+	errs := make([]error, len(keySignatures))
+
+	if workers > len(keySignatures) {
+		workers = len(keySignatures)
+	}
+
+	if workers <= 1 {
+		for i, ks := range keySignatures {
+			errs[i] = decoder.signKeySignature(unsignedTx, key, ks)
+		}
+	} else {
+		var (
+			wg      sync.WaitGroup
+			indexes = make(chan int)
+		)
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for index := range indexes {
+					errs[index] = decoder.signKeySignature(unsignedTx, key, keySignatures[index])
+				}
+			}()
+		}
+
+		for i := range keySignatures {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//signKeySignature 派生地址私钥并签名交易
+func (decoder *TransactionDecoder) signKeySignature(unsignedTx string, key *hdkeystore.HDKey, keySignature *openwallet.KeySignature) error {
+
+	childKey, err := key.DerivedKeyWithPath(keySignature.Address.HDPath, keySignature.EccType)
+	if err != nil {
+		return err
+	}
+	keyBytes, err := childKey.GetPrivateKeyBytes()
+	if err != nil {
+		return err
+	}
+
+	// 交易单哈希签名
+	sigPub, err := neoTransaction.SignRawTransactionWithMode(unsignedTx, keyBytes, neoTransaction.SignMode(decoder.wm.config().SignMode))
+	if err != nil {
+		return fmt.Errorf("transaction hash sign failed, unexpected error: %v", err)
+	}
+
+	if err := decoder.setKeySignature(keySignature, sigPub); err != nil {
+		return err
+	}
+
+	decoder.wm.Log.Debug("address:", keySignature.Address.Address, "signature:", keySignature.Signature)
+
+	return nil
+}
+
+//BenchmarkSigning 使用随机种子派生signatures个地址，为一笔多输入交易签名，测试派生私钥和签名的吞吐量，
+//workers为0时使用配置的并行数
+func (decoder *TransactionDecoder) BenchmarkSigning(signatures, workers int) (*SignBenchmark, error) {
+
+	if signatures <= 0 {
+		return nil, fmt.Errorf("signatures must greater than 0")
+	}
+	if workers <= 0 {
+		workers = decoder.wm.signWorkers()
+	}
+
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	key, err := hdkeystore.NewHDKey(seed, "benchmark", "m/44'/888'")
+	if err != nil {
+		return nil, err
+	}
+
+	curveType := decoder.wm.config().CurveType
+	vins := make([]neoTransaction.Vin, 0, signatures)
+	keySignatures := make([]*openwallet.KeySignature, 0, signatures)
+	for i := 0; i < signatures; i++ {
+		path := fmt.Sprintf("m/44'/888'/0'/0/%d", i)
+		child, err := key.DerivedKeyWithPath(path, curveType)
+		if err != nil {
+			return nil, err
+		}
+		_, address, err := neoTransaction.CreateSignatureRedeemScript(child.GetPublicKeyBytes())
+		if err != nil {
+			return nil, err
+		}
+		txid := make([]byte, 32)
+		rand.Read(txid)
+		vins = append(vins, neoTransaction.Vin{TxID: hex.EncodeToString(txid), Vout: 0})
+		keySignatures = append(keySignatures, &openwallet.KeySignature{
+			EccType: curveType,
+			Address: &openwallet.Address{Address: address, HDPath: path},
+		})
+	}
+
+	vouts := []neoTransaction.Vout{{Asset: neoTransaction.NeoAssetId, Address: keySignatures[0].Address.Address, Value: uint64(signatures)}}
+	emptyTx, err := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction, vins, vouts, nil)
+	if err != nil {
+		return nil, err
+	}
+	unsignedTx, err := neoTransaction.UnsignedRawTransaction(emptyTx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if err := decoder.signKeySignatures(unsignedTx, key, keySignatures, workers); err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	result := &SignBenchmark{
+		Signatures: signatures,
+		Workers:    workers,
+		Elapsed:    elapsed,
+	}
+	if elapsed > 0 {
+		result.SignaturesPerSec = float64(signatures) / elapsed.Seconds()
+	}
+
+	decoder.wm.Log.Std.Info("sign benchmark, signatures: %d, workers: %d, elapsed: %v, %.2f signatures/sec",
+		signatures, workers, elapsed, result.SignaturesPerSec)
+
+	return result, nil
+}
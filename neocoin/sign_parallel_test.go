@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/hdkeystore"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestTransactionDecoder_SignKeySignaturesParallel(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.SignMode = int(neoTransaction.SignModeRFC6979)
+	decoder := NewTransactionDecoder(wm)
+
+	key, _ := hdkeystore.NewHDKey(bytes.Repeat([]byte{7}, 32), "test", "m/44'/888'")
+	newKeySignatures := func() []*openwallet.KeySignature {
+		list := make([]*openwallet.KeySignature, 0)
+		for i := 0; i < 8; i++ {
+			path := fmt.Sprintf("m/44'/888'/0'/0/%d", i)
+			child, _ := key.DerivedKeyWithPath(path, wm.Config.CurveType)
+			_, address, _ := neoTransaction.CreateSignatureRedeemScript(child.GetPublicKeyBytes())
+			list = append(list, &openwallet.KeySignature{EccType: wm.Config.CurveType, Address: &openwallet.Address{Address: address, HDPath: path}})
+		}
+		return list
+	}
+
+	vin := neoTransaction.Vin{TxID: testHash("fund")[2:], Vout: 0}
+	vout := neoTransaction.Vout{Asset: neoTransaction.NeoAssetId, Address: "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt", Value: 1}
+	emptyTx, _ := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction, []neoTransaction.Vin{vin}, []neoTransaction.Vout{vout}, nil)
+	unsignedTx, _ := neoTransaction.UnsignedRawTransaction(emptyTx)
+
+	//确定性k下并行与依次签名的结果一致
+	sequential := newKeySignatures()
+	if err := decoder.signKeySignatures(unsignedTx, key, sequential, 1); err != nil {
+		t.Fatalf("sequential sign failed, unexpected error: %v", err)
+	}
+	parallel := newKeySignatures()
+	if err := decoder.signKeySignatures(unsignedTx, key, parallel, 4); err != nil {
+		t.Fatalf("parallel sign failed, unexpected error: %v", err)
+	}
+	for i := range sequential {
+		if sequential[i].Signature == "" || sequential[i].Signature != parallel[i].Signature || sequential[i].Address.PublicKey != parallel[i].Address.PublicKey {
+			t.Errorf("signature of address: %s mismatch", sequential[i].Address.Address)
+		}
+	}
+
+	//地址与派生路径不符时返回错误
+	invalid := newKeySignatures()
+	invalid[5].Address.Address = invalid[6].Address.Address
+	if err := decoder.signKeySignatures(unsignedTx, key, invalid, 4); err == nil {
+		t.Errorf("mismatched signing key should fail")
+	}
+}
+
+func TestTransactionDecoder_BenchmarkSigning(t *testing.T) {
+	wm := NewWalletManager()
+	decoder := NewTransactionDecoder(wm)
+
+	if _, err := decoder.BenchmarkSigning(0, 0); err == nil {
+		t.Errorf("zero signatures should be rejected")
+	}
+
+	result, err := decoder.BenchmarkSigning(16, 0)
+	if err != nil {
+		t.Fatalf("BenchmarkSigning failed, unexpected error: %v", err)
+	}
+	if result.Signatures != 16 || result.Workers != wm.signWorkers() || result.SignaturesPerSec <= 0 {
+		t.Errorf("unexpected benchmark result: %+v", result)
+	}
+
+	wm.Config.SignWorkers = 1
+	if result, err = decoder.BenchmarkSigning(4, 0); err != nil || result.Workers != 1 {
+		t.Errorf("configured workers should be used, result: %+v, err: %v", result, err)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+//RegisterSigner 为地址注册外部签名者，如PKCS#11硬件设备，签名时优先于钱包种子派生的私钥
+func (wm *WalletManager) RegisterSigner(address string, signer neoTransaction.Signer) {
+	wm.signerMu.Lock()
+	defer wm.signerMu.Unlock()
+	if wm.signers == nil {
+		wm.signers = make(map[string]neoTransaction.Signer)
+	}
+	if signer == nil {
+		delete(wm.signers, address)
+		return
+	}
+	wm.signers[address] = signer
+}
+
+//getSigner 获取地址注册的外部签名者
+func (wm *WalletManager) getSigner(address string) neoTransaction.Signer {
+	wm.signerMu.Lock()
+	defer wm.signerMu.Unlock()
+	return wm.signers[address]
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+//checkLocalHeadConsistency 启动时检查本地已扫描区块hash是否仍在节点对应高度上，
+//节点重新同步到其他链时，按本地保存的区块回溯到共同祖先并回滚，返回回滚后的高度
+func (bs *NEOBlockScanner) checkLocalHeadConsistency() (uint64, error) {
+
+	height, hash := bs.wm.GetLocalNewBlock()
+	if height == 0 || len(hash) == 0 {
+		return height, nil
+	}
+
+	nodeHash, err := bs.wm.GetBlockHash(height)
+	if err != nil {
+		return height, err
+	}
+
+	if nodeHash == hash {
+		return height, nil
+	}
+
+	bs.wm.Log.Std.Warning("local head height: %d hash: %s not found on node, node hash: %s", height, hash, nodeHash)
+
+	//回溯本地保存的区块，查找与节点相同hash的共同祖先
+	var ancestor *Block
+	for h := height - 1; h > 0; h-- {
+		localBlock, err := bs.wm.GetLocalBlock(h)
+		if err != nil {
+			break
+		}
+		nodeHash, err := bs.wm.GetBlockHash(h)
+		if err != nil {
+			return height, err
+		}
+		if localBlock.Hash == nodeHash {
+			ancestor = localBlock
+			break
+		}
+	}
+
+	if ancestor == nil {
+		return height, bs.wm.errorf(ErrBlockHashMismatch, "local head: %d has no common ancestor with node in stored blocks", height)
+	}
+
+	bs.wm.Log.Std.Info("roll back local head from height: %d to common ancestor height: %d, hash: %s", height, ancestor.Height, ancestor.Hash)
+
+	//通知孤块并回滚孤块上已通知的提取结果
+	for h := height; h > ancestor.Height; h-- {
+		if forkBlock, err := bs.wm.GetLocalBlock(h); err == nil {
+			bs.newBlockNotify(forkBlock, true)
+		}
+		bs.rollbackExtractData(h)
+	}
+
+	//删除孤块、未扫记录、未花输出历史、交易索引和提取结果
+	err = bs.wm.deleteLocalDataAboveHeight(ancestor.Height)
+	if err != nil {
+		return height, err
+	}
+
+	err = bs.wm.clearLocalDataFromHeight(ancestor.Height + 1)
+	if err != nil {
+		return height, err
+	}
+
+	//重新记录扫描起点
+	err = bs.wm.SaveLocalNewBlock(ancestor.Height, ancestor.Hash)
+	if err != nil {
+		return height, err
+	}
+
+	return ancestor.Height, nil
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNEOBlockScanner_CheckLocalHeadConsistency(t *testing.T) {
+	//节点在高度8之后重新同步到其他链
+	node := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "getblockhash" {
+			h := uint64(params[0].(float64))
+			if h > 8 {
+				return testHash(fmt.Sprintf("fork-%d", h))
+			}
+			return testHash(fmt.Sprintf("main-%d", h))
+		}
+		return nil
+	})
+	defer node.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient(node.URL, "", false)
+	bs := NewNEOBlockScanner(wm)
+
+	for h := uint64(5); h <= 10; h++ {
+		wm.SaveLocalBlock(&Block{Hash: testHash(fmt.Sprintf("main-%d", h)), Height: h})
+	}
+	wm.SaveLocalNewBlock(10, testHash("main-10"))
+
+	height, err := bs.checkLocalHeadConsistency()
+	if err != nil || height != 8 {
+		t.Errorf("checkLocalHeadConsistency should roll back to 8, height: %d, unexpected error: %v", height, err)
+		return
+	}
+
+	localHeight, localHash := wm.GetLocalNewBlock()
+	if localHeight != 8 || localHash != testHash("main-8") {
+		t.Errorf("local head should be 8, height: %d, hash: %s", localHeight, localHash)
+	}
+	if _, err := wm.GetLocalBlock(9); err == nil {
+		t.Errorf("orphaned block 9 should be deleted")
+	}
+
+	//本地区块头一致时不回滚
+	height, err = bs.checkLocalHeadConsistency()
+	if err != nil || height != 8 {
+		t.Errorf("checkLocalHeadConsistency should keep 8, height: %d, unexpected error: %v", height, err)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	AlertTypeStorageGrowth = "storage_growth" //本地数据库文件大小或增长速度超过阈值
+
+	JobNameStorageMetrics = "storage_metrics" //统计本地数据库大小和增长速度
+)
+
+//StorageFileStats 本地数据库文件的大小、各bucket记录数和增长速度
+type StorageFileStats struct {
+	File          string
+	Size          int64            //文件字节数
+	Buckets       map[string]int64 //各bucket的记录数，不含storm索引
+	GrowthPerHour float64          //与上一次统计相比每小时增长的字节数，首次统计为0
+	SampleAt      int64
+}
+
+//StorageMetrics 本地数据库存储统计
+type StorageMetrics struct {
+	Files         []*StorageFileStats
+	TotalSize     int64
+	GrowthPerHour float64
+	Warnings      []string //超过阈值的文件
+	CreateAt      int64
+}
+
+//storageSample 上一次统计的文件大小，用于计算增长速度
+type storageSample struct {
+	size int64
+	at   time.Time
+	warn bool //是否已告警，回到阈值以内后重新告警
+}
+
+//StorageMetrics 统计本地数据库文件大小、各bucket记录数和增长速度，大小或增长速度超过阈值时告警，
+//增长速度按与上一次统计的差值计算，进程重启后重新开始
+func (wm *WalletManager) StorageMetrics() (*StorageMetrics, error) {
+
+	cfg := wm.config()
+	metrics := &StorageMetrics{
+		Files:    make([]*StorageFileStats, 0),
+		Warnings: make([]string, 0),
+		CreateAt: time.Now().Unix(),
+	}
+
+	for _, file := range wm.localDBFiles() {
+		info, err := os.Stat(filepath.Join(cfg.DBPath, file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		buckets, err := wm.countStorageBuckets(file)
+		if err != nil {
+			return nil, err
+		}
+
+		stats := &StorageFileStats{
+			File:     file,
+			Size:     info.Size(),
+			Buckets:  buckets,
+			SampleAt: time.Now().Unix(),
+		}
+		metrics.Files = append(metrics.Files, stats)
+		metrics.TotalSize += stats.Size
+	}
+
+	wm.storageMu.Lock()
+	if wm.storageSamples == nil {
+		wm.storageSamples = make(map[string]*storageSample)
+	}
+	alerts := make([]*Alert, 0)
+	now := time.Now()
+	for _, stats := range metrics.Files {
+		prev := wm.storageSamples[stats.File]
+		sample := &storageSample{size: stats.Size, at: now}
+		if prev != nil {
+			if elapsed := now.Sub(prev.at).Hours(); elapsed > 0 {
+				stats.GrowthPerHour = float64(stats.Size-prev.size) / elapsed
+			}
+			sample.warn = prev.warn
+		}
+		metrics.GrowthPerHour += stats.GrowthPerHour
+
+		reasons := make([]string, 0)
+		if cfg.StorageWarnSize > 0 && stats.Size >= cfg.StorageWarnSize {
+			reasons = append(reasons, fmt.Sprintf("size: %d bytes reached threshold: %d", stats.Size, cfg.StorageWarnSize))
+		}
+		if cfg.StorageWarnGrowth > 0 && stats.GrowthPerHour >= float64(cfg.StorageWarnGrowth) {
+			reasons = append(reasons, fmt.Sprintf("growth: %.0f bytes/hour reached threshold: %d", stats.GrowthPerHour, cfg.StorageWarnGrowth))
+		}
+		if len(reasons) > 0 {
+			message := fmt.Sprintf("local db: %s %s", stats.File, strings.Join(reasons, ", "))
+			metrics.Warnings = append(metrics.Warnings, message)
+			//持续超过阈值时只告警一次
+			if !sample.warn {
+				alert := NewAlert(wm.Symbol(), AlertTypeStorageGrowth, 0, message)
+				alert.Details["file"] = stats.File
+				alert.Details["size"] = fmt.Sprintf("%d", stats.Size)
+				alert.Details["growthPerHour"] = fmt.Sprintf("%.0f", stats.GrowthPerHour)
+				alerts = append(alerts, alert)
+			}
+		}
+		sample.warn = len(reasons) > 0
+		wm.storageSamples[stats.File] = sample
+	}
+	wm.storageMu.Unlock()
+
+	for _, message := range metrics.Warnings {
+		wm.Log.Std.Warning("%s", message)
+	}
+	for _, alert := range alerts {
+		wm.Blockscanner.newAlertNotify(alert)
+	}
+
+	return metrics, nil
+}
+
+//countStorageBuckets 统计数据库各顶层bucket的记录数，嵌套的bucket（storm索引和元数据）不计入
+func (wm *WalletManager) countStorageBuckets(file string) (map[string]int64, error) {
+
+	db, err := wm.openLocalDB(file)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	buckets := make(map[string]int64)
+	err = db.Bolt.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			var count int64
+			c := b.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if v != nil {
+					count++
+				}
+			}
+			buckets[string(name)] = count
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "count local db: %s buckets failed, unexpected error: %v", file, err)
+	}
+
+	return buckets, nil
+}
+
+//String 按文件输出大小、增长速度和记录数最多的bucket
+func (m *StorageMetrics) String() string {
+	lines := make([]string, 0, len(m.Files)+1)
+	lines = append(lines, fmt.Sprintf("local db total: %d bytes, growth: %.0f bytes/hour", m.TotalSize, m.GrowthPerHour))
+	for _, f := range m.Files {
+		names := make([]string, 0, len(f.Buckets))
+		for name := range f.Buckets {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if f.Buckets[names[i]] != f.Buckets[names[j]] {
+				return f.Buckets[names[i]] > f.Buckets[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		buckets := make([]string, 0, len(names))
+		for _, name := range names {
+			buckets = append(buckets, fmt.Sprintf("%s=%d", name, f.Buckets[name]))
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %d bytes, growth: %.0f bytes/hour, records: %s", f.File, f.Size, f.GrowthPerHour, strings.Join(buckets, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+//NewStorageMetricsJob 统计本地数据库存储任务，超过阈值时告警
+func (wm *WalletManager) NewStorageMetricsJob(interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNameStorageMetrics,
+		Interval: interval,
+		Run: func() error {
+			metrics, err := wm.StorageMetrics()
+			if err != nil {
+				return err
+			}
+			wm.Log.Std.Info("%s", metrics)
+			return nil
+		},
+	}
+}
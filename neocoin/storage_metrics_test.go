@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_StorageMetrics(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.StorageWarnSize = 0
+	wm.Config.StorageWarnGrowth = 0
+	alerts := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(alerts)
+
+	//没有数据库文件时为空
+	metrics, err := wm.StorageMetrics()
+	if err != nil || len(metrics.Files) != 0 || metrics.TotalSize != 0 {
+		t.Fatalf("StorageMetrics = %+v, %v", metrics, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: testHash(string(rune('a' + i))), BlockHeight: 10}
+		wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": data})
+	}
+	metrics, err = wm.StorageMetrics()
+	if err != nil || len(metrics.Files) != 1 || metrics.Files[0].File != wm.Config.BlockchainFile {
+		t.Fatalf("StorageMetrics = %+v, %v", metrics, err)
+	}
+	stats := metrics.Files[0]
+	if stats.Size == 0 || stats.Size != metrics.TotalSize || stats.Buckets["ExtractDataRecord"] != 3 {
+		t.Errorf("unexpected blockchain file stats: %+v", stats)
+	}
+	if stats.GrowthPerHour != 0 || len(metrics.Warnings) != 0 || len(alerts.alerts) != 0 {
+		t.Errorf("first sample should not report growth or warnings, got: %+v", metrics)
+	}
+
+	//超过大小阈值时告警一次，回到阈值以内后再次超过时重新告警
+	wm.Config.StorageWarnSize = 1
+	for i := 0; i < 2; i++ {
+		if metrics, _ = wm.StorageMetrics(); len(metrics.Warnings) != 1 {
+			t.Errorf("size over threshold should be warned, got: %v", metrics.Warnings)
+		}
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeStorageGrowth || alerts.alerts[0].Details["file"] != wm.Config.BlockchainFile {
+		t.Fatalf("size over threshold should raise one alert, got: %+v", alerts.alerts)
+	}
+	wm.Config.StorageWarnSize = 0
+	wm.StorageMetrics()
+	wm.Config.StorageWarnSize = 1
+	wm.StorageMetrics()
+	if len(alerts.alerts) != 2 {
+		t.Errorf("alert should be raised again after recovery, got: %d", len(alerts.alerts))
+	}
+
+	//增长速度按与上一次统计的差值计算
+	wm.Config.StorageWarnSize = 0
+	wm.StorageMetrics()
+	wm.Config.StorageWarnGrowth = 1
+	wm.storageMu.Lock()
+	sample := wm.storageSamples[wm.Config.BlockchainFile]
+	sample.size -= 1 << 20
+	sample.at = sample.at.Add(-time.Hour)
+	wm.storageMu.Unlock()
+	metrics, _ = wm.StorageMetrics()
+	if growth := metrics.Files[0].GrowthPerHour; growth < 1<<20-1024 || growth > 1<<20 {
+		t.Errorf("growth should be about 1MB per hour, got: %.0f", growth)
+	}
+	if len(metrics.Warnings) != 1 || len(alerts.alerts) != 3 {
+		t.Errorf("growth over threshold should be warned, got: %v", metrics.Warnings)
+	}
+
+	if job := wm.NewStorageMetricsJob(time.Hour); job.Run() != nil || job.Name != JobNameStorageMetrics {
+		t.Errorf("storage metrics job failed")
+	}
+	if _, err := wm.ReadOnly().StorageMetrics(); err != nil {
+		t.Errorf("read only StorageMetrics failed, unexpected error: %v", err)
+	}
+}
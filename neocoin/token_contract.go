@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/timer"
+)
+
+//TokenContractState 运行期间设置的代币合约开关，优先于配置
+type TokenContractState struct {
+	Contract string `storm:"id"`
+	Enabled  bool
+	UpdateAt int64
+}
+
+//TokenContract 跟踪的代币合约及其生效设置
+type TokenContract struct {
+	Contract         string
+	ActivationHeight uint64 //从该高度开始跟踪，之前的区块不回溯
+	Enabled          bool
+}
+
+//parseTokenContract 解析配置的代币合约，格式为合约脚本hash[@开始跟踪的区块高度]
+func parseTokenContract(value string) (string, uint64, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "@", 2)
+	contract := normalizeContractHash(strings.TrimSpace(parts[0]))
+	if len(parts) == 1 {
+		return contract, 0, nil
+	}
+	height, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return contract, height, nil
+}
+
+//SetTokenContractEnabled 启用或停用跟踪的代币合约，保存到本地数据库，重启后仍然有效
+func (wm *WalletManager) SetTokenContractEnabled(contract string, enabled bool) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(&TokenContractState{
+		Contract: normalizeContractHash(contract),
+		Enabled:  enabled,
+		UpdateAt: time.Now().Unix(),
+	})
+}
+
+//GetTokenContracts 获取跟踪的代币合约及其生效设置
+func (wm *WalletManager) GetTokenContracts() ([]*TokenContract, error) {
+
+	cfg := wm.config()
+
+	disabled := make(map[string]bool)
+	for _, contract := range cfg.DisabledTokenContracts {
+		disabled[normalizeContractHash(contract)] = true
+	}
+
+	db, err := wm.openLocalDB(cfg.BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	list := make([]*TokenContract, 0, len(cfg.TokenContracts))
+	for _, contract := range cfg.TokenContracts {
+		contract = normalizeContractHash(contract)
+		item := &TokenContract{
+			Contract:         contract,
+			ActivationHeight: cfg.TokenContractActivation[contract],
+			Enabled:          !disabled[contract],
+		}
+
+		var state TokenContractState
+		err = db.One("Contract", contract, &state)
+		if err == nil {
+			item.Enabled = state.Enabled
+		} else if err != storm.ErrNotFound {
+			return nil, err
+		}
+
+		list = append(list, item)
+	}
+
+	return list, nil
+}
+
+//ActiveTokenContracts 获取在指定高度生效的代币合约，停用或未到开始高度的合约不返回
+func (wm *WalletManager) ActiveTokenContracts(height uint64) ([]string, error) {
+
+	list, err := wm.GetTokenContracts()
+	if err != nil {
+		return nil, err
+	}
+
+	contracts := make([]string, 0, len(list))
+	for _, item := range list {
+		if item.Enabled && item.ActivationHeight <= height {
+			contracts = append(contracts, item.Contract)
+		}
+	}
+	return contracts, nil
+}
+
+//startTrackedTokenRefresh 定时刷新当前扫描高度下生效的代币合约元数据，
+//新上架的合约扫描到开始高度后才刷新，停用的合约不再产生告警
+func (wm *WalletManager) startTrackedTokenRefresh(interval time.Duration) *timer.TaskTimer {
+	task := timer.NewTask(interval, func() {
+		height, _ := wm.GetLocalNewBlock()
+		contracts, err := wm.ActiveTokenContracts(height)
+		if err != nil {
+			wm.Log.Std.Error("get active token contracts failed, unexpected error: %v", err)
+			return
+		}
+		for _, contract := range contracts {
+			metadata, err := wm.RefreshTokenMetadata(contract)
+			if err != nil {
+				wm.Log.Std.Error("refresh token contract: %s metadata failed, unexpected error: %v", contract, err)
+				continue
+			}
+			wm.checkTrackedAssetMetadata(metadata)
+		}
+	})
+	task.Start()
+	return task
+}
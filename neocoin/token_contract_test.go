@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/astaxie/beego/config"
+)
+
+func TestWalletManager_ActiveTokenContracts(t *testing.T) {
+	const (
+		rpx = "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+		nex = "0x3a4acd3647086e7c44398aac0349802e6a171129"
+		ont = "0xceab719b8baa2310f232ee0d277c061704541cfb"
+	)
+
+	wm := NewWalletManager()
+	dataDir, _ := ioutil.TempDir("", "neo-data")
+	defer os.RemoveAll(dataDir)
+
+	c, err := config.NewConfigData("ini", []byte(`
+dataDir = "`+dataDir+`"
+tokenContracts = "`+rpx+`, 0x3A4ACD3647086E7C44398AAC0349802E6A171129@2000, `+ont+`, 0x0000000000000000000000000000000000000001@x"
+disabledTokenContracts = "`+ont+`"
+`))
+	if err != nil {
+		t.Fatalf("new config failed, unexpected error: %v", err)
+	}
+	if err = wm.LoadAssetsConfig(c); err != nil {
+		t.Fatalf("LoadAssetsConfig failed, unexpected error: %v", err)
+	}
+
+	//开始高度格式错误的合约不跟踪
+	if !reflect.DeepEqual(wm.Config.TokenContracts, []string{rpx, nex, ont}) {
+		t.Errorf("token contracts: %v", wm.Config.TokenContracts)
+	}
+
+	//未到开始高度的合约不生效
+	contracts, err := wm.ActiveTokenContracts(1999)
+	if err != nil || !reflect.DeepEqual(contracts, []string{rpx}) {
+		t.Errorf("active token contracts at 1999: %v, err: %v", contracts, err)
+	}
+	contracts, _ = wm.ActiveTokenContracts(2000)
+	if !reflect.DeepEqual(contracts, []string{rpx, nex}) {
+		t.Errorf("active token contracts at 2000: %v", contracts)
+	}
+
+	//运行期间停用，不修改配置
+	wm.SetTokenContractEnabled(rpx, false)
+	contracts, _ = wm.ActiveTokenContracts(2000)
+	if !reflect.DeepEqual(contracts, []string{nex}) {
+		t.Errorf("active token contracts after disable: %v", contracts)
+	}
+
+	//配置停用的合约可在运行期间重新启用
+	wm.SetTokenContractEnabled(ont, true)
+	contracts, _ = wm.ActiveTokenContracts(2000)
+	if !reflect.DeepEqual(contracts, []string{nex, ont}) {
+		t.Errorf("active token contracts after enable: %v", contracts)
+	}
+}
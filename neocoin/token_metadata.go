@@ -0,0 +1,283 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/blocktree/openwallet/timer"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	AlertTypeTokenMetadataChanged = "token_metadata_changed" //代币合约symbol或精度变化
+)
+
+//TokenMetadata 代币合约元数据
+type TokenMetadata struct {
+	ID          string `storm:"id"`
+	Contract    string `storm:"index"` //合约脚本hash
+	Symbol      string
+	Decimals    uint64
+	TotalSupply string
+	UpdateAt    int64 `storm:"index"`
+}
+
+func newTokenMetadata(contract, symbol string, decimals uint64, totalSupply string) *TokenMetadata {
+	obj := &TokenMetadata{
+		Contract:    normalizeContractHash(contract),
+		Symbol:      symbol,
+		Decimals:    decimals,
+		TotalSupply: totalSupply,
+		UpdateAt:    time.Now().UnixNano(),
+	}
+	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%s_%d", obj.Contract, obj.UpdateAt))))
+	return obj
+}
+
+//normalizeContractHash 合约脚本hash统一为小写并带0x前缀
+func normalizeContractHash(contract string) string {
+	return "0x" + strings.TrimPrefix(strings.ToLower(contract), "0x")
+}
+
+//QueryTokenMetadata 调用合约的symbol、decimals和totalSupply方法查询代币元数据
+func (wm *WalletManager) QueryTokenMetadata(contract string) (*TokenMetadata, error) {
+
+	results := make(map[string]*gjson.Result)
+	for _, operation := range []string{"symbol", "decimals", "totalSupply"} {
+		result, err := wm.callWithBreaker(wm.nodeClient(), "invokefunction", []interface{}{normalizeContractHash(contract), operation, []interface{}{}})
+		if err != nil {
+			return nil, err
+		}
+		if state := result.Get("state").String(); !strings.HasPrefix(state, "HALT") {
+			return nil, fmt.Errorf("contract: %s invoke %s failed, vm state: %s", contract, operation, state)
+		}
+		item := result.Get("stack.0")
+		if !item.Exists() {
+			return nil, fmt.Errorf("contract: %s invoke %s returns empty stack", contract, operation)
+		}
+		results[operation] = &item
+	}
+
+	symbol, err := stackItemString(results["symbol"])
+	if err != nil {
+		return nil, err
+	}
+	decimals, err := stackItemInteger(results["decimals"])
+	if err != nil {
+		return nil, err
+	}
+	totalSupply, err := stackItemInteger(results["totalSupply"])
+	if err != nil {
+		return nil, err
+	}
+
+	return newTokenMetadata(contract, symbol, decimals.Uint64(), totalSupply.String()), nil
+}
+
+//stackItemString 解析虚拟机返回的字符串
+func stackItemString(item *gjson.Result) (string, error) {
+	value := item.Get("value").String()
+	switch item.Get("type").String() {
+	case "String":
+		return value, nil
+	case "ByteArray":
+		raw, err := hex.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		return string(raw), nil
+	}
+	return "", fmt.Errorf("unexpected stack item type: %s", item.Get("type").String())
+}
+
+//stackItemInteger 解析虚拟机返回的整数，字节数组为小端补码
+func stackItemInteger(item *gjson.Result) (*big.Int, error) {
+	value := item.Get("value").String()
+	switch item.Get("type").String() {
+	case "Integer":
+		number, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer: %s", value)
+		}
+		return number, nil
+	case "ByteArray":
+		raw, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		//小端转大端
+		be := make([]byte, len(raw))
+		for i, b := range raw {
+			be[len(raw)-1-i] = b
+		}
+		number := new(big.Int).SetBytes(be)
+		if len(be) > 0 && be[0]&0x80 != 0 {
+			number.Sub(number, new(big.Int).Lsh(big.NewInt(1), uint(len(be)*8)))
+		}
+		return number, nil
+	}
+	return nil, fmt.Errorf("unexpected stack item type: %s", item.Get("type").String())
+}
+
+//GetTokenMetadataHistory 获取合约元数据历史，按时间先后排序
+func (wm *WalletManager) GetTokenMetadataHistory(contract string) ([]*TokenMetadata, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*TokenMetadata
+	err = db.Select(q.Eq("Contract", normalizeContractHash(contract))).OrderBy("UpdateAt").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//RefreshTokenMetadata 重新查询合约元数据，与最近一次记录不同时保存历史，
+//symbol或精度变化时发送告警，避免下游按旧精度记账
+func (wm *WalletManager) RefreshTokenMetadata(contract string) (*TokenMetadata, error) {
+
+	current, err := wm.QueryTokenMetadata(contract)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := wm.GetTokenMetadataHistory(contract)
+	if err != nil {
+		return nil, err
+	}
+
+	var last *TokenMetadata
+	if len(history) > 0 {
+		last = history[len(history)-1]
+	}
+
+	if last != nil && last.Symbol == current.Symbol && last.Decimals == current.Decimals && last.TotalSupply == current.TotalSupply {
+		return last, nil
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Save(current)
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if last != nil && (last.Symbol != current.Symbol || last.Decimals != current.Decimals) {
+		alert := NewAlert(wm.Symbol(), AlertTypeTokenMetadataChanged, 0,
+			fmt.Sprintf("token contract: %s metadata changed, symbol: %s -> %s, decimals: %d -> %d",
+				current.Contract, last.Symbol, current.Symbol, last.Decimals, current.Decimals))
+		alert.Details["contract"] = current.Contract
+		alert.Details["symbol"] = current.Symbol
+		alert.Details["decimals"] = fmt.Sprintf("%d", current.Decimals)
+		alert.Details["previousSymbol"] = last.Symbol
+		alert.Details["previousDecimals"] = fmt.Sprintf("%d", last.Decimals)
+		wm.Blockscanner.newAlertNotify(alert)
+	}
+
+	return current, nil
+}
+
+//StartTokenMetadataRefresh 定时刷新代币合约元数据
+func (wm *WalletManager) StartTokenMetadataRefresh(interval time.Duration, contracts ...string) *timer.TaskTimer {
+	task := timer.NewTask(interval, func() {
+		for _, contract := range contracts {
+			if _, err := wm.RefreshTokenMetadata(contract); err != nil {
+				wm.Log.Std.Error("refresh token contract: %s metadata failed, unexpected error: %v", contract, err)
+			}
+		}
+	})
+	task.Start()
+	return task
+}
+
+//GetNEP5Balance 调用合约的balanceOf方法查询地址的代币余额，返回最小单位的整数
+func (wm *WalletManager) GetNEP5Balance(contract, address string) (*big.Int, error) {
+
+	hash, err := neoTransaction.AddressToScriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+
+	param := map[string]interface{}{
+		"type":  "Hash160",
+		"value": hex.EncodeToString(reverseBytes(hash)),
+	}
+	result, err := wm.callWithBreaker(wm.nodeClient(), "invokefunction", []interface{}{normalizeContractHash(contract), "balanceOf", []interface{}{param}})
+	if err != nil {
+		return nil, err
+	}
+	if state := result.Get("state").String(); !strings.HasPrefix(state, "HALT") {
+		return nil, fmt.Errorf("contract: %s invoke balanceOf failed, vm state: %s", contract, state)
+	}
+	item := result.Get("stack.0")
+	if !item.Exists() {
+		return nil, fmt.Errorf("contract: %s invoke balanceOf returns empty stack", contract)
+	}
+	//余额为0时返回空字节数组
+	if item.Get("type").String() == "ByteArray" && len(item.Get("value").String()) == 0 {
+		return big.NewInt(0), nil
+	}
+
+	return stackItemInteger(&item)
+}
+
+//getNEP5TokenBalances 查询地址的NEP-5代币余额，按合约精度换算
+func (wm *WalletManager) getNEP5TokenBalances(contract openwallet.SmartContract, address ...string) ([]*openwallet.TokenBalance, error) {
+
+	tokenBalanceList := make([]*openwallet.TokenBalance, 0, len(address))
+	for _, addr := range address {
+		balance := decimal.Zero
+		amount, err := wm.GetNEP5Balance(contract.Address, addr)
+		if err != nil {
+			wm.Log.Errorf("get address[%v] nep5 token balance failed, err: %v", addr, err)
+		} else {
+			balance = decimal.NewFromBigInt(amount, -int32(contract.Decimals))
+		}
+
+		tokenBalanceList = append(tokenBalanceList, &openwallet.TokenBalance{
+			Contract: &contract,
+			Balance: &openwallet.Balance{
+				Address:          addr,
+				Symbol:           contract.Symbol,
+				Balance:          balance.String(),
+				ConfirmBalance:   balance.String(),
+				UnconfirmBalance: "0",
+			},
+		})
+	}
+
+	return tokenBalanceList, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type testAlertObserver struct {
+	alerts []*Alert
+}
+
+func (o *testAlertObserver) NEOAlertNotify(alert *Alert) error {
+	o.alerts = append(o.alerts, alert)
+	return nil
+}
+
+func TestWalletManager_RefreshTokenMetadata(t *testing.T) {
+	const contract = "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+	symbol, decimals, totalSupply := "RPX", "8", "00e1f505"
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "invokefunction" || params[0] != contract {
+			return nil
+		}
+		var item map[string]interface{}
+		switch params[1] {
+		case "symbol":
+			item = map[string]interface{}{"type": "ByteArray", "value": hex.EncodeToString([]byte(symbol))}
+		case "decimals":
+			item = map[string]interface{}{"type": "Integer", "value": decimals}
+		case "totalSupply":
+			item = map[string]interface{}{"type": "ByteArray", "value": totalSupply}
+		}
+		return map[string]interface{}{"state": "HALT, BREAK", "stack": []interface{}{item}}
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	observer := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(observer)
+
+	meta, err := wm.RefreshTokenMetadata(contract)
+	if err != nil {
+		t.Errorf("RefreshTokenMetadata failed unexpected error: %v\n", err)
+		return
+	}
+	if meta.Symbol != "RPX" || meta.Decimals != 8 || meta.TotalSupply != "100000000" {
+		t.Errorf("unexpected token metadata: %+v", meta)
+	}
+
+	//元数据未变化不保存历史
+	wm.RefreshTokenMetadata(contract)
+	//发行量变化只保存历史，不告警
+	totalSupply = "00c2eb0b"
+	wm.RefreshTokenMetadata(contract)
+	history, _ := wm.GetTokenMetadataHistory(contract)
+	if len(history) != 2 || len(observer.alerts) != 0 {
+		t.Errorf("unexpected history: %d, alerts: %d", len(history), len(observer.alerts))
+	}
+
+	//合约迁移后精度变化，发送告警
+	decimals = "6"
+	wm.RefreshTokenMetadata(contract)
+	history, _ = wm.GetTokenMetadataHistory(contract)
+	if len(history) != 3 || history[2].Decimals != 6 {
+		t.Errorf("unexpected history after decimals change: %+v", history)
+	}
+	if len(observer.alerts) != 1 || observer.alerts[0].Type != AlertTypeTokenMetadataChanged || observer.alerts[0].Details["previousDecimals"] != "8" {
+		t.Errorf("decimals change should raise alert: %+v", observer.alerts)
+	}
+}
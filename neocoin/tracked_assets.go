@@ -0,0 +1,463 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/timer"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AlertTypeTrackedAssetMismatch = "tracked_asset_mismatch" //跟踪资产配置的symbol或精度与合约不一致
+
+	//trackedAssetMaxDecimals 代币精度上限
+	trackedAssetMaxDecimals = 18
+)
+
+//TrackedAsset 跟踪的代币资产，由trackedAssetsFile配置，上架新代币只需修改文件
+type TrackedAsset struct {
+	Contract         string          `json:"contract"`         //合约脚本hash
+	Symbol           string          `json:"symbol"`           //代币符号，为空时不与合约核对
+	Decimals         uint64          `json:"decimals"`         //代币精度
+	ActivationHeight uint64          `json:"activationHeight"` //从该高度开始跟踪，之前的区块不回溯
+	MinDeposit       decimal.Decimal `json:"minDeposit"`       //最小入账金额，0表示不限制
+	Disabled         bool            `json:"disabled"`         //停用，不再刷新元数据和告警
+}
+
+//trackedAssetsDocument json格式的跟踪资产文件
+type trackedAssetsDocument struct {
+	Tokens []*TrackedAsset `json:"tokens"`
+}
+
+//validate 检查单个资产配置
+func (a *TrackedAsset) validate() []string {
+	problems := make([]string, 0)
+	if !isContractHash(a.Contract) {
+		problems = append(problems, fmt.Sprintf("tokens: %q should be 20 bytes hex script hash", a.Contract))
+	}
+	if a.Decimals > trackedAssetMaxDecimals {
+		problems = append(problems, fmt.Sprintf("tokens: %s decimals: %d should not be greater than %d", a.Contract, a.Decimals, trackedAssetMaxDecimals))
+	}
+	if a.MinDeposit.IsNegative() {
+		problems = append(problems, fmt.Sprintf("tokens: %s minDeposit: %s should not be negative", a.Contract, a.MinDeposit.String()))
+	}
+	if a.Decimals <= trackedAssetMaxDecimals && !a.MinDeposit.Equal(a.MinDeposit.Truncate(int32(a.Decimals))) {
+		problems = append(problems, fmt.Sprintf("tokens: %s minDeposit: %s has more digits than decimals: %d", a.Contract, a.MinDeposit.String(), a.Decimals))
+	}
+	return problems
+}
+
+//validateTrackedAssets 检查资产列表，合约不能重复
+func validateTrackedAssets(assets []*TrackedAsset) []string {
+	problems := make([]string, 0)
+	seen := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		problems = append(problems, a.validate()...)
+		if seen[a.Contract] {
+			problems = append(problems, fmt.Sprintf("tokens: %q is duplicated", a.Contract))
+		}
+		seen[a.Contract] = true
+	}
+	return problems
+}
+
+//ParseTrackedAssets 按扩展名解析跟踪资产文件，支持yaml和json，合约hash统一格式，不做检查
+func ParseTrackedAssets(path string, data []byte) ([]*TrackedAsset, error) {
+
+	var (
+		assets []*TrackedAsset
+		err    error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var doc trackedAssetsDocument
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid json: %v", err)
+		}
+		assets = doc.Tokens
+	default:
+		assets, err = parseTrackedAssetsYAML(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range assets {
+		a.Contract = normalizeContractHash(strings.TrimSpace(a.Contract))
+	}
+	return assets, nil
+}
+
+//parseTrackedAssetsYAML 解析yaml格式的代币列表，只支持tokens下的列表项和标量值，不支持锚点和多行字符串，例如：
+//
+//	tokens:
+//	  - contract: "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+//	    symbol: RPX
+//	    decimals: 8
+//	    activationHeight: 2000000
+//	    minDeposit: "0.1"
+func parseTrackedAssetsYAML(data []byte) ([]*TrackedAsset, error) {
+
+	assets := make([]*TrackedAsset, 0)
+	var current *TrackedAsset
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if len(text) == 0 || text == "---" {
+			continue
+		}
+		if text == "tokens:" {
+			continue
+		}
+
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			current = &TrackedAsset{}
+			assets = append(assets, current)
+			text = strings.TrimSpace(strings.TrimPrefix(text, "-"))
+			if len(text) == 0 {
+				continue
+			}
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: expected a token list item starting with '-'", line)
+		}
+
+		kv := strings.SplitN(text, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected key: value", line)
+		}
+		key := strings.TrimSpace(kv[0])
+		val, err := yamlScalarValue(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: key: %s, %v", line, key, err)
+		}
+		if err = current.set(key, val); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return assets, scanner.Err()
+}
+
+//yamlScalarValue 解析标量值，去掉引号
+func yamlScalarValue(s string) (string, error) {
+	switch {
+	case s == "|" || s == ">" || strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*"):
+		return "", fmt.Errorf("multi-line strings, anchors and aliases are not supported")
+	case strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{"):
+		return "", fmt.Errorf("nested values are not supported")
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated quoted string")
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return s, nil
+}
+
+//set 按yaml键设置字段，未知的键报错，避免拼写错误的配置被忽略
+func (a *TrackedAsset) set(key, val string) error {
+	var err error
+	switch key {
+	case "contract":
+		a.Contract = val
+	case "symbol":
+		a.Symbol = val
+	case "decimals":
+		a.Decimals, err = strconv.ParseUint(val, 10, 64)
+	case "activationHeight":
+		a.ActivationHeight, err = strconv.ParseUint(val, 10, 64)
+	case "minDeposit":
+		a.MinDeposit, err = decimal.NewFromString(val)
+	case "disabled":
+		a.Disabled, err = strconv.ParseBool(val)
+	default:
+		return fmt.Errorf("unknown key: %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("key: %s invalid value: %s", key, val)
+	}
+	return nil
+}
+
+//encodeTrackedAssetsYAML 编码为parseTrackedAssetsYAML可读取的yaml
+func encodeTrackedAssetsYAML(assets []*TrackedAsset) []byte {
+	var b bytes.Buffer
+	b.WriteString("# tracked NEP-5 assets, reloaded automatically when changed\n")
+	b.WriteString("tokens:\n")
+	for _, a := range assets {
+		fmt.Fprintf(&b, "  - contract: %q\n", a.Contract)
+		fmt.Fprintf(&b, "    symbol: %q\n", a.Symbol)
+		fmt.Fprintf(&b, "    decimals: %d\n", a.Decimals)
+		fmt.Fprintf(&b, "    activationHeight: %d\n", a.ActivationHeight)
+		fmt.Fprintf(&b, "    minDeposit: %q\n", a.MinDeposit.String())
+		if a.Disabled {
+			b.WriteString("    disabled: true\n")
+		}
+	}
+	return b.Bytes()
+}
+
+//copyTrackedAssets 复制资产列表，调用者修改不影响配置
+func copyTrackedAssets(assets []*TrackedAsset) []*TrackedAsset {
+	list := make([]*TrackedAsset, 0, len(assets))
+	for _, a := range assets {
+		item := *a
+		list = append(list, &item)
+	}
+	return list
+}
+
+//applyTrackedAssets 设置跟踪资产，并替换代币合约、开始高度和停用合约的配置
+func (c *WalletConfig) applyTrackedAssets(assets []*TrackedAsset) {
+	c.TrackedAssets = copyTrackedAssets(assets)
+	c.TokenContracts = make([]string, 0, len(assets))
+	c.TokenContractActivation = make(map[string]uint64)
+	c.DisabledTokenContracts = make([]string, 0)
+	for _, a := range assets {
+		c.TokenContracts = append(c.TokenContracts, a.Contract)
+		if a.ActivationHeight > 0 {
+			c.TokenContractActivation[a.Contract] = a.ActivationHeight
+		}
+		if a.Disabled {
+			c.DisabledTokenContracts = append(c.DisabledTokenContracts, a.Contract)
+		}
+	}
+}
+
+//trackedAssetsPath 跟踪资产文件路径，相对路径以配置文件目录为准
+func (c *WalletConfig) trackedAssetsPath() string {
+	if len(c.TrackedAssetsFile) == 0 || filepath.IsAbs(c.TrackedAssetsFile) {
+		return c.TrackedAssetsFile
+	}
+	return filepath.Join(c.configFilePath, c.TrackedAssetsFile)
+}
+
+//readTrackedAssetsFile 读取并检查跟踪资产文件
+func (c *WalletConfig) readTrackedAssetsFile() ([]*TrackedAsset, os.FileInfo, error) {
+	path := c.trackedAssetsPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	assets, err := ParseTrackedAssets(path, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tracked assets file: %s, %v", path, err)
+	}
+	if problems := validateTrackedAssets(assets); len(problems) > 0 {
+		return nil, nil, fmt.Errorf("tracked assets file: %s, %s", path, strings.Join(problems, "; "))
+	}
+	return assets, info, nil
+}
+
+//trackedAssetsStamp 跟踪资产文件的修改时间和大小，变化时重新加载
+func trackedAssetsStamp(info os.FileInfo) string {
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+}
+
+//ReloadTrackedAssets 跟踪资产文件变化时重新加载，文件有错误时保持当前配置，返回是否重新加载
+func (wm *WalletManager) ReloadTrackedAssets() (bool, error) {
+
+	if len(wm.config().TrackedAssetsFile) == 0 {
+		return false, nil
+	}
+
+	wm.trackedAssetsMu.Lock()
+	defer wm.trackedAssetsMu.Unlock()
+
+	assets, info, err := wm.config().readTrackedAssetsFile()
+	if err != nil {
+		return false, wm.errorf(ErrConfigInvalid, "invalid config: %s", err.Error())
+	}
+	stamp := trackedAssetsStamp(info)
+	if stamp == wm.assetsFileStamp {
+		return false, nil
+	}
+
+	wm.updateConfig(func(cfg *WalletConfig) {
+		cfg.applyTrackedAssets(assets)
+	})
+	wm.assetsFileStamp = stamp
+
+	wm.Log.Std.Info("tracked assets reloaded from: %s, %d tokens", wm.config().trackedAssetsPath(), len(assets))
+
+	return true, nil
+}
+
+//TrackedAssets 获取跟踪的代币资产，未配置跟踪资产文件时由tokenContracts生成，只包含合约和开始高度
+func (wm *WalletManager) TrackedAssets() []*TrackedAsset {
+
+	cfg := wm.config()
+	if cfg.TrackedAssets != nil {
+		return copyTrackedAssets(cfg.TrackedAssets)
+	}
+
+	disabled := make(map[string]bool)
+	for _, contract := range cfg.DisabledTokenContracts {
+		disabled[normalizeContractHash(contract)] = true
+	}
+	list := make([]*TrackedAsset, 0, len(cfg.TokenContracts))
+	for _, contract := range cfg.TokenContracts {
+		contract = normalizeContractHash(contract)
+		list = append(list, &TrackedAsset{
+			Contract:         contract,
+			ActivationHeight: cfg.TokenContractActivation[contract],
+			Disabled:         disabled[contract],
+		})
+	}
+	return list
+}
+
+//GetTrackedAsset 获取合约的跟踪资产配置
+func (wm *WalletManager) GetTrackedAsset(contract string) (*TrackedAsset, bool) {
+	contract = normalizeContractHash(contract)
+	for _, a := range wm.TrackedAssets() {
+		if a.Contract == contract {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+//SetTrackedAsset 新增或修改跟踪的代币资产，配置了跟踪资产文件时写回文件，否则只在运行期间有效
+func (wm *WalletManager) SetTrackedAsset(asset *TrackedAsset) error {
+
+	if asset == nil {
+		return fmt.Errorf("tracked asset is nil")
+	}
+
+	item := *asset
+	item.Contract = normalizeContractHash(strings.TrimSpace(item.Contract))
+
+	return wm.modifyTrackedAssets(func(assets []*TrackedAsset) []*TrackedAsset {
+		for i, a := range assets {
+			if a.Contract == item.Contract {
+				assets[i] = &item
+				return assets
+			}
+		}
+		return append(assets, &item)
+	})
+}
+
+//RemoveTrackedAsset 移除跟踪的代币资产，配置了跟踪资产文件时写回文件
+func (wm *WalletManager) RemoveTrackedAsset(contract string) error {
+
+	contract = normalizeContractHash(contract)
+	if _, ok := wm.GetTrackedAsset(contract); !ok {
+		return fmt.Errorf("tracked asset: %s is not found", contract)
+	}
+
+	return wm.modifyTrackedAssets(func(assets []*TrackedAsset) []*TrackedAsset {
+		list := make([]*TrackedAsset, 0, len(assets))
+		for _, a := range assets {
+			if a.Contract != contract {
+				list = append(list, a)
+			}
+		}
+		return list
+	})
+}
+
+//modifyTrackedAssets 修改资产列表，检查通过后先写回文件再替换配置
+func (wm *WalletManager) modifyTrackedAssets(modify func(assets []*TrackedAsset) []*TrackedAsset) error {
+
+	wm.trackedAssetsMu.Lock()
+	defer wm.trackedAssetsMu.Unlock()
+
+	assets := modify(wm.TrackedAssets())
+	if problems := validateTrackedAssets(assets); len(problems) > 0 {
+		return wm.errorf(ErrConfigInvalid, "invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	if path := wm.config().trackedAssetsPath(); len(path) > 0 {
+		data := encodeTrackedAssetsYAML(assets)
+		if strings.ToLower(filepath.Ext(path)) == ".json" {
+			data, _ = json.MarshalIndent(&trackedAssetsDocument{Tokens: assets}, "", "  ")
+		}
+		tmp := path + ".tmp"
+		if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		if info, err := os.Stat(path); err == nil {
+			wm.assetsFileStamp = trackedAssetsStamp(info)
+		}
+	}
+
+	wm.updateConfig(func(cfg *WalletConfig) {
+		cfg.applyTrackedAssets(assets)
+	})
+
+	return nil
+}
+
+//checkTrackedAssetMetadata 配置的symbol或精度与合约元数据不一致时告警，避免按错误的精度记账
+func (wm *WalletManager) checkTrackedAssetMetadata(metadata *TokenMetadata) {
+
+	asset, ok := wm.GetTrackedAsset(metadata.Contract)
+	if !ok || wm.config().TrackedAssets == nil {
+		return
+	}
+
+	if (len(asset.Symbol) == 0 || asset.Symbol == metadata.Symbol) && asset.Decimals == metadata.Decimals {
+		return
+	}
+
+	alert := NewAlert(wm.Symbol(), AlertTypeTrackedAssetMismatch, 0,
+		fmt.Sprintf("tracked asset: %s is configured as %s decimals: %d, contract returns %s decimals: %d",
+			asset.Contract, asset.Symbol, asset.Decimals, metadata.Symbol, metadata.Decimals))
+	alert.Details["contract"] = asset.Contract
+	alert.Details["symbol"] = metadata.Symbol
+	alert.Details["decimals"] = fmt.Sprintf("%d", metadata.Decimals)
+	alert.Details["configuredSymbol"] = asset.Symbol
+	alert.Details["configuredDecimals"] = fmt.Sprintf("%d", asset.Decimals)
+	wm.Blockscanner.newAlertNotify(alert)
+}
+
+//startTrackedAssetsReload 定时检查跟踪资产文件，变化时重新加载
+func (wm *WalletManager) startTrackedAssetsReload(interval time.Duration) *timer.TaskTimer {
+	task := timer.NewTask(interval, func() {
+		if _, err := wm.ReloadTrackedAssets(); err != nil {
+			wm.Log.Std.Error("reload tracked assets failed, keep current tokens, unexpected error: %v", err)
+		}
+	})
+	task.Start()
+	return task
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTokensYAML = `# tracked tokens
+tokens:
+  - contract: "0xECC6B20D3CCAC1EE9EF109AF5A7CDB85706B1DF9"
+    symbol: RPX   # red pulse
+    decimals: 8
+    activationHeight: 2000000
+    minDeposit: "0.1"
+  - contract: ceab719b8baa2310f232ee0d277c061704541cfb
+    symbol: 'ONT'
+    decimals: 0
+    disabled: true
+`
+
+func TestParseTrackedAssets(t *testing.T) {
+	assets, err := ParseTrackedAssets("tokens.yaml", []byte(testTokensYAML))
+	if err != nil || len(assets) != 2 {
+		t.Fatalf("ParseTrackedAssets = %d, %v", len(assets), err)
+	}
+	rpx, ont := assets[0], assets[1]
+	if rpx.Contract != "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9" || rpx.Symbol != "RPX" || rpx.Decimals != 8 || rpx.ActivationHeight != 2000000 || rpx.MinDeposit.String() != "0.1" || rpx.Disabled {
+		t.Errorf("unexpected asset: %+v", rpx)
+	}
+	if ont.Contract != "0xceab719b8baa2310f232ee0d277c061704541cfb" || ont.Symbol != "ONT" || !ont.Disabled {
+		t.Errorf("unexpected asset: %+v", ont)
+	}
+
+	//编码后可以读回
+	encoded := encodeTrackedAssetsYAML(assets)
+	again, err := ParseTrackedAssets("tokens.yml", encoded)
+	if err != nil || string(encodeTrackedAssetsYAML(again)) != string(encoded) || !again[1].Disabled {
+		t.Errorf("encoded yaml should round trip, got: %s, %v", encodeTrackedAssetsYAML(again), err)
+	}
+
+	json := `{"tokens":[{"contract":"0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9","symbol":"RPX","decimals":8,"minDeposit":"1"}]}`
+	if assets, err := ParseTrackedAssets("tokens.json", []byte(json)); err != nil || len(assets) != 1 || assets[0].MinDeposit.String() != "1" {
+		t.Errorf("json tracked assets = %+v, %v", assets, err)
+	}
+
+	for _, bad := range []string{
+		"tokens:\n  - contract: 0x01\n    decimal: 8\n",
+		"contract: 0x01\n",
+		"tokens:\n  - contract: 0x01\n    decimals: eight\n",
+		"tokens:\n  - contract: |\n",
+	} {
+		if _, err := ParseTrackedAssets("tokens.yaml", []byte(bad)); err == nil {
+			t.Errorf("%q should fail to parse", bad)
+		}
+	}
+
+	invalid, _ := ParseTrackedAssets("tokens.yaml", []byte("tokens:\n  - contract: 0x01\n  - contract: 0x01\n    decimals: 2\n    minDeposit: 0.001\n"))
+	if problems := validateTrackedAssets(invalid); len(problems) != 4 {
+		t.Errorf("invalid hash, duplicated contract and minDeposit digits should be reported, got: %v", problems)
+	}
+}
+
+func TestWalletManager_TrackedAssets(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "neo-tokens")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.yaml")
+	ioutil.WriteFile(path, []byte(testTokensYAML), 0600)
+
+	wm := NewWalletManager()
+	wm.Config.DBPath = dir
+	wm.Config.TrackedAssetsFile = path
+	alerts := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(alerts)
+
+	if reloaded, err := wm.ReloadTrackedAssets(); !reloaded || err != nil {
+		t.Fatalf("ReloadTrackedAssets = %v, %v", reloaded, err)
+	}
+	if reloaded, _ := wm.ReloadTrackedAssets(); reloaded {
+		t.Errorf("unchanged file should not be reloaded")
+	}
+	contracts, err := wm.ActiveTokenContracts(2000000)
+	if err != nil || len(contracts) != 1 || contracts[0] != "0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9" {
+		t.Fatalf("ActiveTokenContracts = %v, %v", contracts, err)
+	}
+	if problems := wm.Config.Validate(); len(problems) != 0 {
+		t.Errorf("tracked assets config should be valid, got: %v", problems)
+	}
+
+	//文件有错误时保持当前配置
+	ioutil.WriteFile(path, []byte("tokens:\n  - contract: 0x01\n"), 0600)
+	if _, err := wm.ReloadTrackedAssets(); err == nil || len(wm.TrackedAssets()) != 2 {
+		t.Errorf("invalid file should keep current assets, err: %v", err)
+	}
+	if problems := wm.Config.Validate(); len(problems) != 1 || !strings.HasPrefix(problems[0], "trackedAssetsFile") {
+		t.Errorf("invalid file should be reported, got: %v", problems)
+	}
+
+	//运行期间修改写回文件
+	asset, _ := wm.GetTrackedAsset("0xecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9")
+	asset.ActivationHeight = 100
+	if err := wm.SetTrackedAsset(asset); err != nil {
+		t.Fatalf("SetTrackedAsset failed, unexpected error: %v", err)
+	}
+	if err := wm.RemoveTrackedAsset("0xceab719b8baa2310f232ee0d277c061704541cfb"); err != nil {
+		t.Fatalf("RemoveTrackedAsset failed, unexpected error: %v", err)
+	}
+	if err := wm.SetTrackedAsset(&TrackedAsset{Contract: "0x01"}); err == nil {
+		t.Errorf("invalid asset should be rejected")
+	}
+	data, _ := ioutil.ReadFile(path)
+	saved, err := ParseTrackedAssets(path, data)
+	if err != nil || len(saved) != 1 || saved[0].ActivationHeight != 100 {
+		t.Fatalf("modified assets should be written back, got: %+v, %v", saved, err)
+	}
+	if reloaded, _ := wm.ReloadTrackedAssets(); reloaded {
+		t.Errorf("written back file should not be reloaded again")
+	}
+	if len(wm.ConfigSnapshot().TokenContracts) != 1 || wm.ConfigSnapshot().TokenContractActivation[saved[0].Contract] != 100 {
+		t.Errorf("token contracts should follow tracked assets")
+	}
+
+	//配置的精度与合约不一致时告警
+	wm.checkTrackedAssetMetadata(newTokenMetadata(saved[0].Contract, "RPX", 8, "100"))
+	wm.checkTrackedAssetMetadata(newTokenMetadata(saved[0].Contract, "RPX", 6, "100"))
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeTrackedAssetMismatch || alerts.alerts[0].Details["configuredDecimals"] != "8" {
+		t.Errorf("decimals mismatch should raise an alert, got: %+v", alerts.alerts)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//ApprovalStatus 交易单审批状态
+type ApprovalStatus int
+
+const (
+	ApprovalPending  ApprovalStatus = iota //待审批
+	ApprovalApproved                       //已批准
+	ApprovalRejected                       //已拒绝
+)
+
+//TransactionApprover 交易单广播前的审批接口，用于接入人工审核服务
+//审核未完成时返回ApprovalPending，交易单记录为待审批，之后重新提交时再次审批
+type TransactionApprover interface {
+	Approve(rawTx *openwallet.RawTransaction) (ApprovalStatus, error)
+}
+
+//TxApproval 交易单审批记录
+type TxApproval struct {
+	TxID      string `storm:"id"`
+	Sid       string
+	AccountID string
+	RawHex    string
+	Status    ApprovalStatus `storm:"index"`
+	CreateAt  int64
+	UpdateAt  int64
+}
+
+//SetTransactionApprover 设置交易单审批服务，传入nil关闭审批
+func (wm *WalletManager) SetTransactionApprover(approver TransactionApprover) {
+	wm.Approver = approver
+}
+
+//approveTransaction 广播前审批交易单，并保存审批状态，未批准时返回错误。
+//已有审批结果的交易单不再请求审批服务，已拒绝的不能重新提交，只有待审批的重新请求
+func (wm *WalletManager) approveTransaction(rawTx *openwallet.RawTransaction) error {
+
+	if wm.Approver == nil {
+		return nil
+	}
+
+	txid, err := GetTxId(rawTx.RawHex)
+	if err != nil {
+		return err
+	}
+
+	approval, err := wm.getTxApproval(txid)
+	if err != nil {
+		return err
+	}
+	if approval != nil {
+		switch approval.Status {
+		case ApprovalApproved:
+			return nil
+		case ApprovalRejected:
+			return wm.errorf(ErrTransactionRejected, "transaction: %s is rejected", txid)
+		}
+	}
+
+	status, err := wm.Approver.Approve(rawTx)
+	if err != nil {
+		status = ApprovalPending
+		wm.Log.Std.Warning("approve transaction: %s failed, keep pending; unexpected error: %v", txid, err)
+	}
+
+	err = wm.saveTxApproval(txid, rawTx, status)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case ApprovalApproved:
+		return nil
+	case ApprovalRejected:
+		return wm.errorf(ErrTransactionRejected, "transaction: %s is rejected", txid)
+	default:
+		return wm.errorf(ErrTransactionPendingApproval, "transaction: %s is pending approval", txid)
+	}
+}
+
+//getTxApproval 获取交易单的审批记录，没有记录时返回nil
+func (wm *WalletManager) getTxApproval(txid string) (*TxApproval, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "open local db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	var approval TxApproval
+	err = db.One("TxID", txid, &approval)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get transaction approval failed, unexpected error: %v", err)
+	}
+	return &approval, nil
+}
+
+//saveTxApproval 保存交易单审批状态
+func (wm *WalletManager) saveTxApproval(txid string, rawTx *openwallet.RawTransaction, status ApprovalStatus) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "open local db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().Unix()
+	var approval TxApproval
+	err = db.One("TxID", txid, &approval)
+	if err != nil {
+		if err != storm.ErrNotFound {
+			return wm.errorf(ErrLocalDBOperateFailed, "get transaction approval failed, unexpected error: %v", err)
+		}
+		approval = TxApproval{
+			TxID:     txid,
+			Sid:      rawTx.Sid,
+			RawHex:   rawTx.RawHex,
+			CreateAt: now,
+		}
+		if rawTx.Account != nil {
+			approval.AccountID = rawTx.Account.AccountID
+		}
+	}
+	approval.Status = status
+	approval.UpdateAt = now
+
+	err = db.Save(&approval)
+	if err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "save transaction approval failed, unexpected error: %v", err)
+	}
+	return nil
+}
+
+//GetTxApprovals 获取某个状态的交易单审批记录
+func (wm *WalletManager) GetTxApprovals(status ApprovalStatus) ([]*TxApproval, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*TxApproval
+	err = db.Select(q.Eq("Status", status)).Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+type testApprover struct {
+	status ApprovalStatus
+	calls  int
+}
+
+func (a *testApprover) Approve(rawTx *openwallet.RawTransaction) (ApprovalStatus, error) {
+	a.calls++
+	return a.status, nil
+}
+
+func TestTransactionDecoder_SubmitRawTransactionApproval(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	approver := &testApprover{status: ApprovalPending}
+	wm.SetTransactionApprover(approver)
+
+	rawTx := &openwallet.RawTransaction{
+		Sid:         "sid",
+		Account:     &openwallet.AssetsAccount{AccountID: "account"},
+		RawHex:      "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac",
+		IsCompleted: true,
+	}
+
+	_, err := wm.TxDecoder.SubmitRawTransaction(nil, rawTx)
+	if owErr, ok := err.(*openwallet.Error); !ok || owErr.Code() != ErrTransactionPendingApproval {
+		t.Errorf("transaction should be pending approval, err: %v", err)
+		return
+	}
+
+	pending, _ := wm.GetTxApprovals(ApprovalPending)
+	if len(pending) != 1 || pending[0].Sid != "sid" || pending[0].AccountID != "account" {
+		t.Errorf("pending approval is not persisted: %v", pending)
+		return
+	}
+
+	approver.status = ApprovalRejected
+	_, err = wm.TxDecoder.SubmitRawTransaction(nil, rawTx)
+	if owErr, ok := err.(*openwallet.Error); !ok || owErr.Code() != ErrTransactionRejected {
+		t.Errorf("transaction should be rejected, err: %v", err)
+	}
+
+	pending, _ = wm.GetTxApprovals(ApprovalPending)
+	rejected, _ := wm.GetTxApprovals(ApprovalRejected)
+	if len(pending) != 0 || len(rejected) != 1 || approver.calls != 2 {
+		t.Errorf("approval status is not updated, pending: %d, rejected: %d", len(pending), len(rejected))
+	}
+}
+
+func TestWalletManager_ApproveTransactionStored(t *testing.T) {
+	rawTx := &openwallet.RawTransaction{
+		Sid:    "sid",
+		RawHex: "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac",
+	}
+
+	approve := func(wm *WalletManager) int64 {
+		err := wm.approveTransaction(rawTx)
+		if err == nil {
+			return 0
+		}
+		if owErr, ok := err.(*openwallet.Error); ok {
+			return int64(owErr.Code())
+		}
+		t.Fatalf("unexpected error: %v", err)
+		return -1
+	}
+
+	newWalletManager := func(approver *testApprover) *WalletManager {
+		wm := NewWalletManager()
+		wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+		wm.SetTransactionApprover(approver)
+		return wm
+	}
+
+	//待审批的重新请求审批服务
+	approver := &testApprover{status: ApprovalPending}
+	wm := newWalletManager(approver)
+	defer os.RemoveAll(wm.Config.DBPath)
+	if code := approve(wm); code != ErrTransactionPendingApproval {
+		t.Errorf("transaction should be pending approval, code: %d", code)
+	}
+	approver.status = ApprovalApproved
+	if code := approve(wm); code != 0 || approver.calls != 2 {
+		t.Errorf("pending transaction should be approved by re-query, code: %d, calls: %d", code, approver.calls)
+	}
+
+	//已批准的不再请求审批服务
+	approver.status = ApprovalRejected
+	if code := approve(wm); code != 0 || approver.calls != 2 {
+		t.Errorf("approved transaction should not be approved again, code: %d, calls: %d", code, approver.calls)
+	}
+
+	//已拒绝的不能重新提交
+	approver = &testApprover{status: ApprovalRejected}
+	wm = newWalletManager(approver)
+	defer os.RemoveAll(wm.Config.DBPath)
+	if code := approve(wm); code != ErrTransactionRejected {
+		t.Errorf("transaction should be rejected, code: %d", code)
+	}
+	approver.status = ApprovalApproved
+	if code := approve(wm); code != ErrTransactionRejected || approver.calls != 1 {
+		t.Errorf("rejected transaction should stay rejected, code: %d, calls: %d", code, approver.calls)
+	}
+	if approved, _ := wm.GetTxApprovals(ApprovalApproved); len(approved) != 0 {
+		t.Errorf("rejected transaction should not be overwritten")
+	}
+}
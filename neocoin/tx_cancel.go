@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//WithdrawReservationParam 交易单ExtParam中占用的提币额度记录id
+	WithdrawReservationParam = "withdrawReservation"
+)
+
+//AbandonedTransaction 已取消的交易单，不能再广播
+type AbandonedTransaction struct {
+	TxID           string `storm:"id"`
+	Sid            string
+	AccountID      string
+	IdempotencyKey string
+	CancelAt       int64
+}
+
+//CancelRawTransaction 取消已创建未广播的交易单，释放占用的提币额度，作废幂等键并记录取消，
+//上游订单取消后交易单不再占用额度，之后提交该交易单会返回ErrTransactionCancelled
+func (decoder *TransactionDecoder) CancelRawTransaction(rawTx *openwallet.RawTransaction) error {
+
+	if len(rawTx.RawHex) == 0 {
+		return fmt.Errorf("transaction hex is empty")
+	}
+
+	txid, err := GetTxId(rawTx.RawHex)
+	if err != nil {
+		return err
+	}
+
+	if rawTx.IsSubmit {
+		return decoder.wm.errorf(ErrTransactionAlreadySubmitted, "transaction: %s has been submitted, can not cancel", txid)
+	}
+
+	accountID := ""
+	if rawTx.Account != nil {
+		accountID = rawTx.Account.AccountID
+	}
+
+	//作废幂等键，与广播互斥，已广播的交易单不能取消
+	key := idempotencyKey(rawTx)
+	if len(key) > 0 {
+		decoder.wm.idempotencyMu.Lock()
+		defer decoder.wm.idempotencyMu.Unlock()
+
+		record, err := decoder.wm.GetIdempotencyRecord(key)
+		if err != nil {
+			return decoder.wm.errorf(ErrLocalDBOperateFailed, "get idempotency record failed, unexpected error: %v", err)
+		}
+		if record != nil && !record.Void {
+			return decoder.wm.errorf(ErrTransactionAlreadySubmitted, "transaction: %s has been submitted, can not cancel", record.TxID)
+		}
+	}
+
+	abandoned := &AbandonedTransaction{
+		TxID:           txid,
+		Sid:            rawTx.Sid,
+		AccountID:      accountID,
+		IdempotencyKey: key,
+		CancelAt:       time.Now().Unix(),
+	}
+	if err := decoder.wm.saveAbandonedTransaction(abandoned); err != nil {
+		return decoder.wm.errorf(ErrLocalDBOperateFailed, "save abandoned transaction failed, unexpected error: %v", err)
+	}
+
+	//订单记录为已取消，保存失败只告警
+	if err := decoder.wm.saveTxOrder(txid, TxOrderStatusCancelled, rawTx); err != nil {
+		decoder.wm.Log.Warningf("[Sid: %s] save tx order: %s failed: %v", rawTx.Sid, txid, err)
+	}
+
+	//释放占用的提币额度
+	if len(rawTx.ExtParam) > 0 {
+		decoder.wm.releaseWithdraw(rawTx.GetExtParam().Get(WithdrawReservationParam).String())
+	}
+
+	//记录审计日志
+	decoder.wm.auditTransaction(AuditActionCancel, accountID, txid, rawTx.RawHex, "")
+
+	decoder.wm.Log.Std.Info("[Sid: %s] transaction: %s cancelled", rawTx.Sid, txid)
+
+	return nil
+}
+
+//saveAbandonedTransaction 保存已取消的交易单，有幂等键时同时保存作废的幂等键记录
+func (wm *WalletManager) saveAbandonedTransaction(abandoned *AbandonedTransaction) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.Save(abandoned)
+	if err != nil {
+		return err
+	}
+
+	if len(abandoned.IdempotencyKey) > 0 {
+		err = tx.Save(&IdempotencyRecord{
+			Key:       abandoned.IdempotencyKey,
+			TxID:      abandoned.TxID,
+			Sid:       abandoned.Sid,
+			AccountID: abandoned.AccountID,
+			CreateAt:  abandoned.CancelAt,
+			Void:      true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetAbandonedTransaction 获取已取消的交易单，不存在返回nil
+func (wm *WalletManager) GetAbandonedTransaction(txid string) (*AbandonedTransaction, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var abandoned AbandonedTransaction
+	err = db.One("TxID", txid, &abandoned)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &abandoned, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func testCancelRawTransaction(t *testing.T, vout uint16, key string) *openwallet.RawTransaction {
+	txHex, err := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction,
+		[]neoTransaction.Vin{{TxID: testHash(testHotAddress)[2:], Vout: vout}},
+		[]neoTransaction.Vout{{Asset: "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", Address: testColdAddress1, Value: 10}},
+		nil)
+	if err != nil {
+		t.Fatalf("CreateEmptyRawTransaction failed unexpected error: %v", err)
+	}
+	rawTx := &openwallet.RawTransaction{
+		RawHex:      txHex,
+		Sid:         key,
+		Account:     &openwallet.AssetsAccount{AccountID: "hot"},
+		IsCompleted: true,
+	}
+	SetIdempotencyKey(rawTx, key)
+	return rawTx
+}
+
+func TestTransactionDecoder_CancelRawTransaction(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	decoder := NewTransactionDecoder(wm)
+
+	db, _ := wm.openLocalDB(wm.Config.BlockchainFile)
+	db.Save(&WithdrawRecord{ID: "reservation-1", AccountID: "hot", Asset: "NEO", Amount: "10"})
+	db.Close()
+
+	rawTx := testCancelRawTransaction(t, 0, "order-1")
+	rawTx.SetExtParam(WithdrawReservationParam, "reservation-1")
+	if err := decoder.CancelRawTransaction(rawTx); err != nil {
+		t.Errorf("CancelRawTransaction failed unexpected error: %v", err)
+		return
+	}
+
+	txid, _ := GetTxId(rawTx.RawHex)
+	if abandoned, _ := wm.GetAbandonedTransaction(txid); abandoned == nil || abandoned.IdempotencyKey != "order-1" {
+		t.Errorf("abandoned transaction should be recorded, got: %v", abandoned)
+	}
+	if record, _ := wm.GetIdempotencyRecord("order-1"); record == nil || !record.Void {
+		t.Errorf("idempotency key should be void, got: %v", record)
+	}
+
+	db, _ = wm.openLocalDB(wm.Config.BlockchainFile)
+	var reservation WithdrawRecord
+	if err := db.One("ID", "reservation-1", &reservation); err == nil {
+		t.Errorf("withdraw reservation should be released")
+	}
+	db.Close()
+
+	//已取消的交易单不能广播
+	_, err := decoder.SubmitRawTransaction(nil, rawTx)
+	if err == nil || openwallet.ConvertError(err).Code() != ErrTransactionCancelled {
+		t.Errorf("SubmitRawTransaction should fail with cancelled, err: %v", err)
+	}
+
+	//已广播的交易单不能取消
+	submitted := testCancelRawTransaction(t, 1, "order-2")
+	wm.saveIdempotencyRecord("order-2", submitted)
+	err = decoder.CancelRawTransaction(submitted)
+	if err == nil || openwallet.ConvertError(err).Code() != ErrTransactionAlreadySubmitted {
+		t.Errorf("CancelRawTransaction should fail with already submitted, err: %v", err)
+	}
+}
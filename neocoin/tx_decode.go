@@ -17,6 +17,7 @@ package neocoin
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Assetsadapter/neo-adapter/neoTransaction"
@@ -46,20 +47,54 @@ func NewTransactionDecoder(wm *WalletManager) *TransactionDecoder {
 
 //CreateRawTransaction 创建交易单
 func (decoder *TransactionDecoder) CreateRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	//检查最早广播时间
+	if err := decoder.wm.checkBroadcastAt(rawTx); err != nil {
+		return err
+	}
+	//筛查接收地址风险
+	if err := decoder.checkWithdrawRisk(rawTx); err != nil {
+		return err
+	}
+	//检查提币限额并占用额度
+	withdrawID, err := decoder.wm.reserveWithdraw(rawTx)
+	if err != nil {
+		return err
+	}
 	if rawTx.Coin.IsContract {
-		return decoder.CreateOmniRawTransaction(wrapper, rawTx)
+		err = decoder.CreateOmniRawTransaction(wrapper, rawTx)
 	} else {
-		return decoder.CreateNEORawTransaction(wrapper, rawTx)
+		err = decoder.CreateNEORawTransaction(wrapper, rawTx)
 	}
+	if err != nil {
+		decoder.wm.releaseWithdraw(withdrawID)
+		return err
+	}
+	//记录占用的提币额度，取消交易单时释放
+	if len(withdrawID) > 0 {
+		rawTx.SetExtParam(WithdrawReservationParam, withdrawID)
+	}
+
+	//记录审计日志
+	to, _ := json.Marshal(rawTx.To)
+	decoder.wm.auditTransaction(AuditActionCreate, rawTx.Account.AccountID, "", rawTx.RawHex, string(to))
+	return nil
 }
 
 //SignRawTransaction 签名交易单
 func (decoder *TransactionDecoder) SignRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	var err error
 	if rawTx.Coin.IsContract {
-		return decoder.SignOmniRawTransaction(wrapper, rawTx)
+		err = decoder.SignOmniRawTransaction(wrapper, rawTx)
 	} else {
-		return decoder.SignNEORawTransaction(wrapper, rawTx)
+		err = decoder.SignNEORawTransaction(wrapper, rawTx)
 	}
+	if err != nil {
+		return err
+	}
+
+	//记录审计日志
+	decoder.wm.auditTransaction(AuditActionSign, rawTx.Account.AccountID, "", rawTx.RawHex, "")
+	return nil
 }
 
 //VerifyRawTransaction 验证交易单，验证交易单并返回加入签名后的交易单
@@ -112,6 +147,12 @@ func GetTxId(rawTx string) (string,error){
 }
 //SendRawTransaction 广播交易单
 func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) (*openwallet.Transaction, error) {
+	return decoder.submitRawTransaction(rawTx, true)
+}
+
+//submitRawTransaction 广播交易单，approve为false时跳过审批和定时广播，
+//用于定时广播任务广播提交时已审批并保存的交易单
+func (decoder *TransactionDecoder) submitRawTransaction(rawTx *openwallet.RawTransaction, approve bool) (*openwallet.Transaction, error) {
 
 	if len(rawTx.RawHex) == 0 {
 		return nil, fmt.Errorf("transaction hex is empty")
@@ -121,6 +162,46 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 		return nil, fmt.Errorf("transaction is not completed validation")
 	}
 
+	//幂等键已广播过，直接返回首次广播的txid，不再重复广播
+	key := idempotencyKey(rawTx)
+	if len(key) > 0 {
+		decoder.wm.idempotencyMu.Lock()
+		defer decoder.wm.idempotencyMu.Unlock()
+
+		record, err := decoder.wm.GetIdempotencyRecord(key)
+		if err != nil {
+			return nil, decoder.wm.errorf(ErrLocalDBOperateFailed, "get idempotency record failed, unexpected error: %v", err)
+		}
+		if record != nil && record.Void {
+			return nil, decoder.wm.errorf(ErrTransactionCancelled, "transaction: %s has been cancelled", record.TxID)
+		}
+		if record != nil {
+			decoder.wm.Log.Std.Notice("[Sid: %s] idempotency key: %s already submitted, txid: %s", rawTx.Sid, key, record.TxID)
+			rawTx.TxID = record.TxID
+			rawTx.IsSubmit = true
+			return decoder.submittedTransaction(rawTx), nil
+		}
+	}
+
+	//已取消的交易单不再广播
+	if txid, err := GetTxId(rawTx.RawHex); err == nil {
+		if abandoned, _ := decoder.wm.GetAbandonedTransaction(txid); abandoned != nil {
+			return nil, decoder.wm.errorf(ErrTransactionCancelled, "transaction: %s has been cancelled", txid)
+		}
+	}
+
+	if approve {
+		//广播前审批
+		if err := decoder.wm.approveTransaction(rawTx); err != nil {
+			return nil, err
+		}
+
+		//未到最早广播时间时保存，由定时广播任务广播
+		if err := decoder.wm.scheduleBroadcast(rawTx); err != nil {
+			return nil, err
+		}
+	}
+
 	result, err := decoder.wm.SendRawTransaction(rawTx.RawHex)
 	if err != nil {
 		decoder.wm.Log.Warningf("[Sid: %s] submit raw hex: %s", rawTx.Sid, rawTx.RawHex)
@@ -140,6 +221,34 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 	rawTx.TxID = txId
 	rawTx.IsSubmit = true
 
+	//向节点核对交易ID，交易已广播，核对失败只告警
+	if err := decoder.wm.checkBroadcastTxID(rawTx.RawHex, txId); err != nil {
+		decoder.wm.Log.Warningf("[Sid: %s] txid cross-check failed: %v", rawTx.Sid, err)
+	}
+
+	//记录幂等键，交易已广播，保存失败只告警
+	if len(key) > 0 {
+		if err := decoder.wm.saveIdempotencyRecord(key, rawTx); err != nil {
+			decoder.wm.Log.Warningf("[Sid: %s] save idempotency key: %s failed: %v", rawTx.Sid, key, err)
+		}
+	}
+
+	//记录交易单的发起账户和订单号，交易已广播，保存失败只告警
+	if err := decoder.wm.saveTxOrder(txId, TxOrderStatusSubmitted, rawTx); err != nil {
+		decoder.wm.Log.Warningf("[Sid: %s] save tx order: %s failed: %v", rawTx.Sid, txId, err)
+	}
+
+	//记录批量提币的结算明细，交易已广播，保存失败只告警
+	if err := decoder.wm.saveSettlementRecords(rawTx); err != nil {
+		decoder.wm.Log.Warningf("[Sid: %s] save settlement records of batch: %s failed: %v", rawTx.Sid, batchID(rawTx), err)
+	}
+
+	return decoder.submittedTransaction(rawTx), nil
+}
+
+//submittedTransaction 由已广播的交易单生成交易记录
+func (decoder *TransactionDecoder) submittedTransaction(rawTx *openwallet.RawTransaction) *openwallet.Transaction {
+
 	decimals := int32(0)
 	fees := "0"
 	if rawTx.Coin.IsContract {
@@ -165,7 +274,7 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 
 	tx.WxID = openwallet.GenTransactionWxID(tx)
 
-	return tx, nil
+	return tx
 }
 
 ////////////////////////// NEO implement //////////////////////////
@@ -185,34 +294,9 @@ func (decoder *TransactionDecoder) CreateNEORawTransaction(wrapper openwallet.Wa
 		accountID    = rawTx.Account.AccountID
 		destinations = make([]string, 0)
 		//accountTotalSent = decimal.Zero
-		limit = 2000
+		err error
 	)
 
-	address, err := wrapper.GetAddressList(0, limit, "AccountID", rawTx.Account.AccountID)
-	if err != nil {
-		return err
-	}
-
-	if len(address) == 0 {
-		return openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", accountID)
-		//return fmt.Errorf("[%s] have not addresses", accountID)
-	}
-
-	searchAddrs := make([]string, 0)
-	for _, address := range address {
-		searchAddrs = append(searchAddrs, address.Address)
-	}
-	//decoder.wm.Log.Debug(searchAddrs)
-	//查找账户的utxo
-	unspents, err := decoder.wm.ListUnspent(0, searchAddrs...)
-	if err != nil {
-		return err
-	}
-
-	if len(unspents) == 0 {
-		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "[%s] balance is not enough", accountID)
-	}
-
 	if len(rawTx.To) == 0 {
 		return errors.New("Receiver addresses is empty!")
 	}
@@ -222,36 +306,8 @@ func (decoder *TransactionDecoder) CreateNEORawTransaction(wrapper openwallet.Wa
 		deamount, _ := decimal.NewFromString(amount)
 		totalSend = totalSend.Add(deamount)
 		destinations = append(destinations, addr)
-		//计算账户的实际转账amount
-		//addresses, findErr := wrapper.GetAddressList(0, -1, "AccountID", rawTx.Account.AccountID, "Address", addr)
-		//if findErr != nil || len(addresses) == 0 {
-		//	amountDec, _ := decimal.NewFromString(amount)
-		//	accountTotalSent = accountTotalSent.Add(amountDec)
-		//}
 	}
 
-	// 从小到大排序排序UTXO NEO
-	sort.Sort(UnspentSort{unspents, func(a, b *UnspentBalance) int {
-		a_amount, _ := decimal.NewFromString(a.NEOUnspent.Amount)
-		b_amount, _ := decimal.NewFromString(b.NEOUnspent.Amount)
-		if a_amount.GreaterThan(b_amount) {
-			return 1
-		} else {
-			return -1
-		}
-	}})
-
-	//gasUnspents = unspents
-	//sort.Sort(UnspentSort{gasUnspents, func(a, b *UnspentBalance) int {
-	//	a_amount, _ := decimal.NewFromString(a.GASUnspent.Amount)
-	//	b_amount, _ := decimal.NewFromString(b.GASUnspent.Amount)
-	//	if a_amount.GreaterThan(b_amount) {
-	//		return 1
-	//	} else {
-	//		return -1
-	//	}
-	//}})
-
 	// 获取交易费
 	if len(rawTx.FeeRate) == 0 {
 		feesRate, err = decoder.wm.EstimateFeeRate()
@@ -264,65 +320,34 @@ func (decoder *TransactionDecoder) CreateNEORawTransaction(wrapper openwallet.Wa
 
 	decoder.wm.Log.Info("Calculating wallet unspent record to build transaction...")
 	computeTotalSend := totalSend
-	//循环的计算余额是否足够支付发送数额+手续费
-	for {
-
-		usedNEOUTXO = make([]*UnspentBalance, 0)
-		//usedGASUTXO = make([]*UnspentBalance, 0)
-		neoBalance = decimal.New(0, 0)
-
-		//计算一个可用于支付的余额
-		for _, u := range unspents {
-			ua, _ := decimal.NewFromString(u.NEOUnspent.Amount)
-			if ua.GreaterThan(decimal.Zero) {
-				neoBalance = neoBalance.Add(ua)
-				usedNEOUTXO = append(usedNEOUTXO, u)
-				if neoBalance.GreaterThanOrEqual(computeTotalSend) {
-					break
-				}
-			}
-		}
-
-		if neoBalance.LessThan(computeTotalSend) {
-			return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "The balance: %s is not enough! ", neoBalance.StringFixed(decoder.wm.Decimal()))
-		}
-
-		// 计算可用于交易的GAS地址
-		//for _, u := range gasUnspents {
-		//	ua, _ := decimal.NewFromString(u.GASUnspent.Amount)
-		//	if ua.GreaterThan(decimal.Zero) {
-		//		gasBalance = gasBalance.Add(ua)
-		//		usedGASUTXO = append(usedGASUTXO, u)
-		//		if gasBalance.GreaterThanOrEqual(feesRate) {
-		//			break
-		//		}
-		//	}
-		//}
-
-		//计算手续费，找零地址有2个，一个是发送，一个是新创建的
-		//fees, err := decoder.wm.EstimateFee(int64(len(usedNEOUTXO)), int64(len(destinations)+1), feesRate)
-		//if err != nil {
-		//	return err
-		//}
-
-		//actualFees = fees
-
-		break
-
+	claimPolicy := decoder.neoClaimPolicy(rawTx)
+	usedNEOUTXO, neoBalance, err = decoder.selectNEOUnspentsByClaimPolicy(wrapper, accountID, computeTotalSend, claimPolicy)
+	if err != nil {
+		return err
 	}
 
 	//UTXO如果大于设定限制，则分拆成多笔交易单发送
-	if len(usedNEOUTXO) > decoder.wm.Config.MaxTxInputs {
-		errStr := fmt.Sprintf("The transaction is use max inputs over: %d", decoder.wm.Config.MaxTxInputs)
+	if len(usedNEOUTXO) > decoder.wm.config().MaxTxInputs {
+		errStr := fmt.Sprintf("The transaction is use max inputs over: %d", decoder.wm.config().MaxTxInputs)
 		return errors.New(errStr)
 	}
 
+	//按见证人大小估算签名后的交易单大小，超出上限需要拆分成多笔交易单
+	groups, err := decoder.splitUnspentsBySize(wrapper, usedNEOUTXO, len(destinations)+1)
+	if err != nil {
+		return err
+	}
+	if len(groups) > 1 {
+		return decoder.wm.errorf(ErrTransactionTooLarge, "transaction exceeds size limit: %d, split into %d transactions with CreateSplitRawTransaction", decoder.wm.config().MaxTxSize, len(groups))
+	}
+
 	//取账户最后一个地址
 	changeAddress := usedNEOUTXO[0].Address
 
 	changeAmount := neoBalance.Sub(computeTotalSend).Sub(actualFees)
-	rawTx.FeeRate = feesRate.StringFixed(decoder.wm.Decimal())
-	rawTx.Fees = actualFees.StringFixed(decoder.wm.Decimal())
+	rawTx.FeeRate = decoder.wm.FormatGAS(feesRate)
+	rawTx.Fees = decoder.wm.FormatGAS(actualFees)
+	decoder.setFeesFiat(rawTx, actualFees)
 
 	decoder.wm.Log.Std.Notice("-----------------------------------------------")
 	decoder.wm.Log.Std.Notice("From Account: %s", accountID)
@@ -341,10 +366,18 @@ func (decoder *TransactionDecoder) CreateNEORawTransaction(wrapper openwallet.Wa
 		//outputAddrs[to] = amount
 	}
 
-	//changeAmount := balance.Sub(totalSend).Sub(actualFees)
-	if changeAmount.GreaterThan(decimal.New(0, 0)) {
-		outputAddrs = appendOutput(outputAddrs, changeAddress, changeAmount)
-		//outputAddrs[changeAddress] = changeAmount.StringFixed(decoder.wm.Decimal())
+	if claimPolicy == NEOClaimPreserve || claimPolicy == NEOClaimReset {
+		//每个输入地址剩余的NEO重新发送给自己
+		outputAddrs = decoder.resendNEORemainder(rawTx, outputAddrs, usedNEOUTXO, computeTotalSend.Add(actualFees), claimPolicy)
+	} else {
+		//找零低于粉尘阈值时按策略处理
+		changeAmount, actualFees = decoder.handleChangeDust(rawTx, outputAddrs, changeAmount, actualFees)
+
+		//changeAmount := balance.Sub(totalSend).Sub(actualFees)
+		if changeAmount.GreaterThan(decimal.New(0, 0)) {
+			outputAddrs = appendOutput(outputAddrs, changeAddress, changeAmount)
+			//outputAddrs[changeAddress] = changeAmount.StringFixed(decoder.wm.Decimal())
+		}
 	}
 
 	err = decoder.createNEORawTransaction(wrapper, rawTx, usedNEOUTXO, outputAddrs)
@@ -355,6 +388,80 @@ func (decoder *TransactionDecoder) CreateNEORawTransaction(wrapper openwallet.Wa
 	return nil
 }
 
+//selectNEOUnspents 查找账户的未花记录，按地址余额从小到大选取，直到足够支付发送数额
+func (decoder *TransactionDecoder) selectNEOUnspents(wrapper openwallet.WalletDAI, accountID string, totalSend decimal.Decimal) ([]*UnspentBalance, decimal.Decimal, error) {
+
+	var (
+		usedNEOUTXO = make([]*UnspentBalance, 0)
+		neoBalance  = decimal.New(0, 0)
+	)
+
+	unspents, err := decoder.listNEOUnspents(wrapper, accountID)
+	if err != nil {
+		return nil, neoBalance, err
+	}
+
+	//计算一个可用于支付的余额
+	for _, u := range unspents {
+		ua, _ := decimal.NewFromString(u.NEOUnspent.Amount)
+		if ua.GreaterThan(decimal.Zero) {
+			neoBalance = neoBalance.Add(ua)
+			usedNEOUTXO = append(usedNEOUTXO, u)
+			if neoBalance.GreaterThanOrEqual(totalSend) {
+				break
+			}
+		}
+	}
+
+	if neoBalance.LessThan(totalSend) {
+		return nil, neoBalance, decoder.wm.errorf(openwallet.ErrInsufficientBalanceOfAccount, "The balance: %s is not enough! ", neoBalance.StringFixed(decoder.wm.Decimal()))
+	}
+
+	return usedNEOUTXO, neoBalance, nil
+}
+
+//listNEOUnspents 查找账户的未花记录，按地址NEO余额从小到大排序
+func (decoder *TransactionDecoder) listNEOUnspents(wrapper openwallet.WalletDAI, accountID string) ([]*UnspentBalance, error) {
+
+	limit := 2000
+
+	address, err := wrapper.GetAddressList(0, limit, "AccountID", accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(address) == 0 {
+		return nil, decoder.wm.errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", accountID)
+	}
+
+	searchAddrs := make([]string, 0)
+	for _, address := range address {
+		searchAddrs = append(searchAddrs, address.Address)
+	}
+	//查找账户的utxo
+	unspents, err := decoder.wm.ListUnspent(0, searchAddrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(unspents) == 0 {
+		return nil, decoder.wm.errorf(openwallet.ErrInsufficientBalanceOfAccount, "[%s] balance is not enough", accountID)
+	}
+
+	// 从小到大排序排序UTXO NEO
+	sort.Sort(UnspentSort{unspents, func(a, b *UnspentBalance) int {
+		a_amount, _ := decimal.NewFromString(a.NEOUnspent.Amount)
+		b_amount, _ := decimal.NewFromString(b.NEOUnspent.Amount)
+		if a_amount.GreaterThan(b_amount) {
+			return 1
+		} else {
+			return -1
+		}
+	}})
+
+	return unspents, nil
+}
+
 //SignRawTransaction 签名交易单
 func (decoder *TransactionDecoder) SignNEORawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
 
@@ -362,37 +469,56 @@ func (decoder *TransactionDecoder) SignNEORawTransaction(wrapper openwallet.Wall
 		return fmt.Errorf("transaction signature is empty")
 	}
 
-	key, err := wrapper.HDKey()
+	//所有签名者签名去掉见证人的交易，已加入的见证人不影响签名
+	unsignedTx, err := neoTransaction.UnsignedRawTransaction(rawTx.RawHex)
 	if err != nil {
-		return err
+		return fmt.Errorf("transaction decode failed, unexpected error: %v", err)
 	}
+	digest, err := neoTransaction.HashForSigning(unsignedTx)
+	if err != nil {
+		return fmt.Errorf("transaction hash for sig failed, unexpected error: %v", err)
+	}
+	txHash := hex.EncodeToString(digest)
 
 	keySignatures := rawTx.Signatures[rawTx.Account.AccountID]
 	if keySignatures != nil {
+		//钱包私钥签名的地址，派生和签名并行处理
+		local := make([]*openwallet.KeySignature, 0, len(keySignatures))
 		for _, keySignature := range keySignatures {
 
-			childKey, err := key.DerivedKeyWithPath(keySignature.Address.HDPath, keySignature.EccType)
-			if err != nil {
-				return err
-			}
-			keyBytes, err := childKey.GetPrivateKeyBytes()
-			if err != nil {
-				return err
+			if keySignature.Address == nil {
+				return fmt.Errorf("transaction signature address is empty")
 			}
-			decoder.wm.Log.Debug("privateKey:", hex.EncodeToString(keyBytes))
 
-			// 签名交易
-			// 交易单哈希签名
-			sigPub, err := neoTransaction.SignRawTransaction(rawTx.RawHex, keyBytes)
-			if err != nil {
-				return fmt.Errorf("transaction hash sign failed, unexpected error: %v", err)
+			//创建交易单时按地址生成的哈希必须与交易单一致
+			if len(keySignature.Message) > 0 && keySignature.Message != txHash {
+				return fmt.Errorf("transaction hash of address: %s is not equal to raw transaction hash", keySignature.Address.Address)
 			}
+			keySignature.Message = txHash
 
-			decoder.wm.Log.Info("Signature raw transaction : ", rawTx.RawHex)
+			//地址注册了外部签名者时，使用外部签名者签名，外部签名者不要求并发安全，依次调用
+			if signer := decoder.wm.getSigner(keySignature.Address.Address); signer != nil {
+				sigPub, err := neoTransaction.SignRawTransactionWithSigner(unsignedTx, signer)
+				if err != nil {
+					return fmt.Errorf("transaction hash sign by signer failed, unexpected error: %v", err)
+				}
+				if err := decoder.setKeySignature(keySignature, sigPub); err != nil {
+					return err
+				}
+				continue
+			}
 
-			keySignature.Signature = hex.EncodeToString(sigPub.Signature)
+			local = append(local, keySignature)
+		}
 
-			fmt.Println(fmt.Sprintf("Signture : %s, Public Key : %s", hex.EncodeToString(sigPub.Signature), hex.EncodeToString(sigPub.Pubkey)))
+		if len(local) > 0 {
+			key, err := wrapper.HDKey()
+			if err != nil {
+				return err
+			}
+			if err := decoder.signWithHDKey(unsignedTx, key, local); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -400,6 +526,22 @@ func (decoder *TransactionDecoder) SignNEORawTransaction(wrapper openwallet.Wall
 	return nil
 }
 
+//setKeySignature 检查签名的公钥属于签名地址，防止派生路径或外部签名者与地址不对应
+func (decoder *TransactionDecoder) setKeySignature(keySignature *openwallet.KeySignature, sigPub *neoTransaction.SignaturePubkey) error {
+	_, address, err := neoTransaction.CreateSignatureRedeemScript(sigPub.Pubkey)
+	if err != nil {
+		return err
+	}
+	if address != keySignature.Address.Address {
+		return fmt.Errorf("signing key of address: %s belongs to address: %s", keySignature.Address.Address, address)
+	}
+	keySignature.Signature = hex.EncodeToString(sigPub.Signature)
+	if len(keySignature.Address.PublicKey) == 0 {
+		keySignature.Address.PublicKey = hex.EncodeToString(sigPub.Pubkey)
+	}
+	return nil
+}
+
 //VerifyRawTransaction 验证交易单，验证交易单并返回加入签名后的交易单
 func (decoder *TransactionDecoder) VerifyNEORawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
 
@@ -421,6 +563,11 @@ func (decoder *TransactionDecoder) VerifyNEORawTransaction(wrapper openwallet.Wa
 			signature, _ := hex.DecodeString(keySignature.Signature)
 			pubkey, _ := hex.DecodeString(keySignature.Address.PublicKey)
 
+			//见证人按验证脚本hash排序，公钥必须属于签名地址
+			if _, address, err := neoTransaction.CreateSignatureRedeemScript(pubkey); err != nil || address != keySignature.Address.Address {
+				return fmt.Errorf("public key: %s does not belong to address: %s", keySignature.Address.PublicKey, keySignature.Address.Address)
+			}
+
 			signaturePubkey := neoTransaction.SignaturePubkey{
 				Signature: signature,
 				Pubkey:    pubkey,
@@ -469,7 +616,7 @@ func (decoder *TransactionDecoder) GetRawTransactionFeeRate() (feeRate string, u
 		return "", "", err
 	}
 
-	return rate.StringFixed(decoder.wm.Decimal()), "K", nil
+	return decoder.wm.FormatGAS(rate), "K", nil
 }
 
 ////////////////////////// omnicore implement //////////////////////////
@@ -908,7 +1055,7 @@ func (decoder *TransactionDecoder) CreateNEOSummaryRawTransaction(wrapper openwa
 		}
 
 		//尽可能筹够最大input数
-		if len(unspents)+len(sumUnspents) <= decoder.wm.Config.MaxTxInputs {
+		if len(unspents)+len(sumUnspents) <= decoder.wm.config().MaxTxInputs {
 			sumUnspents = append(sumUnspents, unspents...)
 			//if retainedBalance.GreaterThan(decimal.Zero) {
 			//	outputAddrs = appendOutput(outputAddrs, addr, retainedBalance)
@@ -918,7 +1065,7 @@ func (decoder *TransactionDecoder) CreateNEOSummaryRawTransaction(wrapper openwa
 		}
 
 		//如果utxo已经超过最大输入，或遍历地址完结，就可以进行构建交易单
-		if i == len(sumAddresses)-1 || len(sumUnspents) >= decoder.wm.Config.MaxTxInputs {
+		if i == len(sumAddresses)-1 || len(sumUnspents) >= decoder.wm.config().MaxTxInputs {
 			//执行构建交易单工作
 			//decoder.wm.Log.Debugf("sumUnspents: %+v", sumUnspents)
 			//计算手续费，构建交易单inputs，地址保留余额>0，地址需要加入输出，最后+1是汇总地址
@@ -927,33 +1074,34 @@ func (decoder *TransactionDecoder) CreateNEOSummaryRawTransaction(wrapper openwa
 			//	return nil, createErr
 			//}
 
-			//计算这笔交易单的汇总数量
-			for _, u := range sumUnspents {
-				ua, _ := decimal.NewFromString(u.NEOUnspent.Amount)
-				totalInputAmount = totalInputAmount.Add(ua)
+			//按见证人大小拆分，每笔交易单不超出大小上限
+			groups, splitErr := decoder.splitUnspentsBySize(wrapper, sumUnspents, 1)
+			if splitErr != nil {
+				rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+					RawTx: &openwallet.RawTransaction{Coin: sumRawTx.Coin, Account: sumRawTx.Account},
+					Error: openwallet.ConvertError(splitErr),
+				})
+				groups = nil
 			}
 
-			/*
+			for _, group := range groups {
 
-					汇总数量计算：
-
-					1. 输入总数量 = 合计账户地址的所有utxo
-					2. 账户地址输出总数量 = 账户地址保留余额 * 地址数
-				    3. 汇总数量 = 输入总数量 - 账户地址输出总数量 - 手续费
-			*/
-			//retainedBalanceTotal := retainedBalance.Mul(decimal.New(int64(len(outputAddrs)), 0))
-			//sumAmount := totalInputAmount.Sub(fees)
+				//计算这笔交易单的汇总数量
+				totalInputAmount = decimal.Zero
+				for _, u := range group {
+					ua, _ := decimal.NewFromString(u.NEOUnspent.Amount)
+					totalInputAmount = totalInputAmount.Add(ua)
+				}
 
-			decoder.wm.Log.Debugf("totalInputAmount: %v", totalInputAmount)
-			//decoder.wm.Log.Debugf("retainedBalanceTotal: %v", retainedBalanceTotal)
-			//decoder.wm.Log.Debugf("fees: %v", fees)
-			decoder.wm.Log.Debugf("sumAmount: %v", totalInputAmount)
+				decoder.wm.Log.Debugf("totalInputAmount: %v", totalInputAmount)
+				decoder.wm.Log.Debugf("sumAmount: %v", totalInputAmount)
 
-			if totalInputAmount.GreaterThan(decimal.Zero) {
+				if !totalInputAmount.GreaterThan(decimal.Zero) {
+					continue
+				}
 
 				//最后填充汇总地址及汇总数量
-				outputAddrs = appendOutput(outputAddrs, sumRawTx.SummaryAddress, totalInputAmount)
-				//outputAddrs[sumRawTx.SummaryAddress] = sumAmount.StringFixed(decoder.wm.Decimal())
+				outputAddrs = appendOutput(make(map[string]decimal.Decimal), sumRawTx.SummaryAddress, totalInputAmount)
 
 				raxTxTo := make(map[string]string, 0)
 				for a, m := range outputAddrs {
@@ -962,15 +1110,14 @@ func (decoder *TransactionDecoder) CreateNEOSummaryRawTransaction(wrapper openwa
 
 				//创建一笔交易单
 				rawTx := &openwallet.RawTransaction{
-					Coin:    sumRawTx.Coin,
-					Account: sumRawTx.Account,
-					FeeRate: sumRawTx.FeeRate,
-					To:      raxTxTo,
-					//Fees:     fees.StringFixed(decoder.wm.Decimal()),
+					Coin:     sumRawTx.Coin,
+					Account:  sumRawTx.Account,
+					FeeRate:  sumRawTx.FeeRate,
+					To:       raxTxTo,
 					Required: 1,
 				}
 
-				createErr := decoder.createNEORawTransaction(wrapper, rawTx, sumUnspents, outputAddrs)
+				createErr := decoder.createNEORawTransaction(wrapper, rawTx, group, outputAddrs)
 				rawTxWithErr := &openwallet.RawTransactionWithError{
 					RawTx: rawTx,
 					Error: openwallet.ConvertError(createErr),
@@ -978,7 +1125,6 @@ func (decoder *TransactionDecoder) CreateNEOSummaryRawTransaction(wrapper openwa
 
 				//创建成功，添加到队列
 				rawTxArray = append(rawTxArray, rawTxWithErr)
-
 			}
 
 			//清空临时变量
@@ -996,6 +1142,11 @@ func (decoder *TransactionDecoder) CreateNEOSummaryRawTransaction(wrapper openwa
 // usedUTXO : 可以使用的UTXO
 // to : key : 交易接收地址 value : 输出的金额
 func (decoder *TransactionDecoder) createNEORawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction, usedUtxos []*UnspentBalance, to map[string]decimal.Decimal) error {
+	return decoder.createNEORawTransactionWithAttrs(wrapper, rawTx, usedUtxos, to, nil)
+}
+
+//createNEORawTransactionWithAttrs 创建带附加属性的NEO原始交易单
+func (decoder *TransactionDecoder) createNEORawTransactionWithAttrs(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction, usedUtxos []*UnspentBalance, to map[string]decimal.Decimal, attrs []neoTransaction.Attribute) error {
 
 	var (
 		err              error
@@ -1031,8 +1182,8 @@ func (decoder *TransactionDecoder) createNEORawTransaction(wrapper openwallet.Wa
 	}
 
 	//UTXO如果大于设定限制，则分拆成多笔交易单发送
-	if len(usedUtxos) > decoder.wm.Config.MaxTxInputs {
-		errStr := fmt.Sprintf("The transaction is use max inputs over: %d", decoder.wm.Config.MaxTxInputs)
+	if len(usedUtxos) > decoder.wm.config().MaxTxInputs {
+		errStr := fmt.Sprintf("The transaction is use max inputs over: %d", decoder.wm.config().MaxTxInputs)
 		return errors.New(errStr)
 	}
 
@@ -1051,12 +1202,12 @@ func (decoder *TransactionDecoder) createNEORawTransaction(wrapper openwallet.Wa
 	for to, amount := range to {
 		txTo = append(txTo, fmt.Sprintf("%s:%s", to, amount.String()))
 		amount = amount.Shift(decoder.wm.Decimal())
-		out := neoTransaction.Vout{neoTransaction.NeoAssetId, to, uint64(amount.IntPart())}
+		out := neoTransaction.Vout{decoder.wm.config().NEOAssetID, to, uint64(amount.IntPart())}
 		vouts = append(vouts, out)
 	}
 
 	/////////构建空交易单
-	emptyTrans, err := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction, vins, vouts, nil)
+	emptyTrans, err := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction, vins, vouts, attrs)
 
 	if err != nil {
 		return fmt.Errorf("create transaction failed, unexpected error: %v", err)
@@ -1067,20 +1218,29 @@ func (decoder *TransactionDecoder) createNEORawTransaction(wrapper openwallet.Wa
 		rawTx.Signatures = make(map[string][]*openwallet.KeySignature)
 	}
 
-	//装配签名
-	keySigs := make([]*openwallet.KeySignature, 0)
-
+	//装配签名，输入属于不同地址时每个地址签名一次，按见证人顺序排列
+	signers := make([]string, 0, len(usedUtxos))
 	for _, usedUtxo := range usedUtxos {
-		addr, err := wrapper.GetAddress(usedUtxo.Address)
+		signers = append(signers, usedUtxo.Address)
+	}
+	txHashes, err := neoTransaction.CreateRawTransactionHashForSigByAddresses(emptyTrans, signers)
+	if err != nil {
+		return fmt.Errorf("create transaction hash for sig failed, unexpected error: %v", err)
+	}
+
+	keySigs := make([]*openwallet.KeySignature, 0, len(txHashes))
+
+	for _, txHash := range txHashes {
+		addr, err := wrapper.GetAddress(txHash.GetNormalTxAddress())
 		if err != nil {
 			return err
 		}
 
 		signature := openwallet.KeySignature{
-			EccType: decoder.wm.Config.CurveType,
+			EccType: decoder.wm.config().CurveType,
 			Nonce:   "",
 			Address: addr,
-			Message: "",
+			Message: txHash.GetTxHashHex(),
 		}
 
 		keySigs = append(keySigs, &signature)
@@ -1151,8 +1311,8 @@ func (decoder *TransactionDecoder) createOmniRawTransaction(wrapper openwallet.W
 		}
 
 		//UTXO如果大于设定限制，则分拆成多笔交易单发送
-		if len(usedUTXO) > decoder.wm.Config.MaxTxInputs {
-			errStr := fmt.Sprintf("The transaction is use max inputs over: %d", decoder.wm.Config.MaxTxInputs)
+		if len(usedUTXO) > decoder.wm.config().MaxTxInputs {
+			errStr := fmt.Sprintf("The transaction is use max inputs over: %d", decoder.wm.config().MaxTxInputs)
 			return errors.New(errStr)
 		}
 
@@ -1187,17 +1347,17 @@ func (decoder *TransactionDecoder) createOmniRawTransaction(wrapper openwallet.W
 			//txTo = append(txTo, fmt.Sprintf("%s:%s", to, amount))
 		}
 
-		if decoder.wm.Config.IsTestNet {
+		if decoder.wm.config().IsTestNet {
 			addressPrefix = omniTransaction.AddressPrefix{
-				P2PKHPrefix:  decoder.wm.Config.TestNetAddressPrefix.P2PKHPrefix,
-				P2WPKHPrefix: decoder.wm.Config.TestNetAddressPrefix.P2WPKHPrefix,
-				Bech32Prefix: decoder.wm.Config.TestNetAddressPrefix.Bech32Prefix,
+				P2PKHPrefix:  decoder.wm.config().TestNetAddressPrefix.P2PKHPrefix,
+				P2WPKHPrefix: decoder.wm.config().TestNetAddressPrefix.P2WPKHPrefix,
+				Bech32Prefix: decoder.wm.config().TestNetAddressPrefix.Bech32Prefix,
 			}
 		} else {
 			addressPrefix = omniTransaction.AddressPrefix{
-				P2PKHPrefix:  decoder.wm.Config.MainNetAddressPrefix.P2PKHPrefix,
-				P2WPKHPrefix: decoder.wm.Config.MainNetAddressPrefix.P2WPKHPrefix,
-				Bech32Prefix: decoder.wm.Config.MainNetAddressPrefix.Bech32Prefix,
+				P2PKHPrefix:  decoder.wm.config().MainNetAddressPrefix.P2PKHPrefix,
+				P2WPKHPrefix: decoder.wm.config().MainNetAddressPrefix.P2WPKHPrefix,
+				Bech32Prefix: decoder.wm.config().MainNetAddressPrefix.Bech32Prefix,
 			}
 		}
 
@@ -1266,7 +1426,7 @@ func (decoder *TransactionDecoder) createOmniRawTransaction(wrapper openwallet.W
 			}
 
 			signature := &openwallet.KeySignature{
-				EccType: decoder.wm.Config.CurveType,
+				EccType: decoder.wm.config().CurveType,
 				Nonce:   "",
 				Address: addr,
 				Message: beSignHex,
@@ -1540,7 +1700,7 @@ func (decoder *TransactionDecoder) getAssetsAccountUnspents(wrapper openwallet.W
 	}
 
 	if len(address) == 0 {
-		return nil, openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", account.AccountID)
+		return nil, decoder.wm.errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", account.AccountID)
 	}
 
 	searchAddrs := make([]string, 0)
@@ -1612,7 +1772,7 @@ func (decoder *TransactionDecoder) getUTXOSatisfyAmount(unspents []*Unspent, amo
 		}
 
 		if utxo == nil {
-			return nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "account have not available utxo")
+			return nil, decoder.wm.errorf(openwallet.ErrInsufficientBalanceOfAccount, "account have not available utxo")
 		}
 
 		return utxo, nil*/
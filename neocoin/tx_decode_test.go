@@ -16,8 +16,14 @@
 package neocoin
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/hdkeystore"
+	"github.com/blocktree/openwallet/openwallet"
 	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
 	"testing"
 )
 
@@ -74,4 +80,131 @@ func TestGetTxId(t *testing.T) {
 		t.Error(err)
 	}
 	fmt.Println(txId)
-}
\ No newline at end of file
+}
+func TestTransactionDecoder_HandleChangeDust(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.ChangeDustThreshold = decimal.RequireFromString("0.001")
+	decoder := NewTransactionDecoder(wm)
+
+	outputs := map[string]decimal.Decimal{
+		"A": decimal.RequireFromString("1"),
+		"B": decimal.RequireFromString("2"),
+	}
+
+	//高于阈值的找零不处理
+	rawTx := &openwallet.RawTransaction{}
+	change, fees := decoder.handleChangeDust(rawTx, outputs, decimal.RequireFromString("0.01"), decimal.Zero)
+	if !change.Equal(decimal.RequireFromString("0.01")) || !fees.IsZero() || len(rawTx.ExtParam) > 0 {
+		t.Errorf("change above threshold should be kept")
+	}
+
+	//并入手续费
+	change, fees = decoder.handleChangeDust(rawTx, outputs, decimal.RequireFromString("0.0005"), decimal.Zero)
+	if !change.IsZero() || !fees.Equal(decimal.RequireFromString("0.0005")) {
+		t.Errorf("change dust should be folded into fees, change: %s, fees: %s", change, fees)
+	}
+	if gjson.Get(rawTx.ExtParam, "changeDust.decision").String() != "fees" {
+		t.Errorf("unexpected ext param: %s", rawTx.ExtParam)
+	}
+
+	//并入最大输出
+	wm.Config.ChangeDustPolicy = ChangeDustToLargestOutput
+	rawTx = &openwallet.RawTransaction{}
+	change, fees = decoder.handleChangeDust(rawTx, outputs, decimal.RequireFromString("0.0005"), decimal.Zero)
+	if !change.IsZero() || !fees.IsZero() || !outputs["B"].Equal(decimal.RequireFromString("2.0005")) {
+		t.Errorf("change dust should be folded into largest output, outputs: %v", outputs)
+	}
+	if gjson.Get(rawTx.ExtParam, "changeDust.address").String() != "B" {
+		t.Errorf("unexpected ext param: %s", rawTx.ExtParam)
+	}
+}
+
+//testSignWallet 资产账户的地址由同一个种子的不同路径派生
+type testSignWallet struct {
+	openwallet.WalletDAIBase
+	key       *hdkeystore.HDKey
+	addresses map[string]*openwallet.Address
+}
+
+func (w *testSignWallet) HDKey(password ...string) (*hdkeystore.HDKey, error) {
+	return w.key, nil
+}
+
+func (w *testSignWallet) GetAddress(address string) (*openwallet.Address, error) {
+	if addr, ok := w.addresses[address]; ok {
+		return addr, nil
+	}
+	return nil, fmt.Errorf("address: %s not found", address)
+}
+
+func TestTransactionDecoder_SignMultiKeyInputs(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.MaxTxInputs = 10
+	decoder := NewTransactionDecoder(wm)
+
+	key, _ := hdkeystore.NewHDKey(bytes.Repeat([]byte{7}, 32), "test", "m/44'/888'")
+	wallet := &testSignWallet{key: key, addresses: make(map[string]*openwallet.Address)}
+	usedUtxos := make([]*UnspentBalance, 0)
+	for i := 0; i < 3; i++ {
+		path := fmt.Sprintf("m/44'/888'/0'/0/%d", i)
+		child, _ := key.DerivedKeyWithPath(path, wm.Config.CurveType)
+		_, address, _ := neoTransaction.CreateSignatureRedeemScript(child.GetPublicKeyBytes())
+		wallet.addresses[address] = &openwallet.Address{AccountID: "account", Address: address, HDPath: path}
+		usedUtxos = append(usedUtxos, &UnspentBalance{
+			Address:    address,
+			NEOUnspent: &Unspent{UnspentTxs: &[]UnspentTx{{TxID: testHash(address)[2:], N: uint64(i), Value: "1"}}, Amount: "1"},
+		})
+	}
+	//同一地址的多个未花记录只签名一次
+	usedUtxos = append(usedUtxos, &UnspentBalance{
+		Address:    usedUtxos[0].Address,
+		NEOUnspent: &Unspent{UnspentTxs: &[]UnspentTx{{TxID: testHash("other")[2:], N: 0, Value: "1"}}, Amount: "1"},
+	})
+
+	rawTx := &openwallet.RawTransaction{Account: &openwallet.AssetsAccount{AccountID: "account"}, Fees: "0"}
+	to := map[string]decimal.Decimal{"AGofsxAUDwt52KjaB664GYsqVAkULYvKNt": decimal.New(4, 0)}
+	if err := decoder.createNEORawTransaction(wallet, rawTx, usedUtxos, to); err != nil {
+		t.Errorf("createNEORawTransaction failed unexpected error: %v\n", err)
+		return
+	}
+
+	keySigs := rawTx.Signatures["account"]
+	if len(keySigs) != 3 {
+		t.Errorf("each signer address should sign once, got: %d", len(keySigs))
+		return
+	}
+	digest, _ := neoTransaction.HashForSigning(rawTx.RawHex)
+	signers := make([]string, 0)
+	for _, ks := range keySigs {
+		if ks.Message != hex.EncodeToString(digest) {
+			t.Errorf("unexpected hash of address: %s", ks.Address.Address)
+		}
+		signers = append(signers, ks.Address.Address)
+	}
+	sorted, _ := neoTransaction.SortAddressesByScriptHash(signers)
+	if fmt.Sprint(sorted) != fmt.Sprint(signers) {
+		t.Errorf("signatures should be in witness order: %v", signers)
+	}
+
+	if err := decoder.SignNEORawTransaction(wallet, rawTx); err != nil {
+		t.Errorf("SignNEORawTransaction failed unexpected error: %v\n", err)
+		return
+	}
+	if err := decoder.VerifyNEORawTransaction(wallet, rawTx); err != nil || !rawTx.IsCompleted {
+		t.Errorf("VerifyNEORawTransaction failed, completed: %v, unexpected error: %v", rawTx.IsCompleted, err)
+		return
+	}
+
+	signed, _ := hex.DecodeString(rawTx.RawHex)
+	tx, _ := neoTransaction.DecodeRawTransaction(signed)
+	if len(tx.Scripts) != 3 || neoTransaction.CheckWitnessOrder(tx.Scripts) != nil {
+		t.Errorf("unexpected witnesses: %d", len(tx.Scripts))
+	}
+
+	//派生路径与地址不对应时拒绝签名
+	wallet.addresses[signers[0]].HDPath = "m/44'/888'/0'/0/9"
+	keySigs[0].Address.PublicKey = ""
+	if err := decoder.SignNEORawTransaction(wallet, rawTx); err == nil {
+		t.Errorf("signing key of another address should be rejected")
+	}
+}
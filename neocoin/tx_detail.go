@@ -0,0 +1,182 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+//TxDetailInput 交易单输入，来源地址和金额从上一笔交易单的输出补全
+type TxDetailInput struct {
+	SourceTxID  string
+	SourceIndex uint64
+	Address     string
+	Asset       string
+	Amount      string
+	Watched     bool   //是否关注的地址
+	AccountID   string //关注地址所属账户
+}
+
+//TxDetailOutput 交易单输出
+type TxDetailOutput struct {
+	N         uint64
+	Address   string
+	Asset     string
+	Amount    string
+	Watched   bool   //是否关注的地址
+	AccountID string //关注地址所属账户
+}
+
+//TxAssetTotal 交易单中某个资产的输入输出合计
+type TxAssetTotal struct {
+	Input  decimal.Decimal
+	Output decimal.Decimal
+}
+
+//TransactionDetail 交易单完整视图，用于客服排查
+type TransactionDetail struct {
+	TxID           string
+	Type           string
+	RawHex         string
+	BlockHash      string
+	BlockHeight    uint64
+	Blocktime      int64
+	Confirmations  uint64
+	SysFee         string
+	NetFee         string
+	Fees           string //交易费，系统费+网络费
+	Inputs         []*TxDetailInput
+	Outputs        []*TxDetailOutput
+	AssetTotals    map[string]*TxAssetTotal //资产id -> 输入输出合计
+	InvolveWatched bool                     //是否涉及关注的地址
+}
+
+//GetTransactionDetail 获取交易单完整视图，补全输入来源、资产合计、交易费、确认数、原始hex和关注地址标记
+func (wm *WalletManager) GetTransactionDetail(txid string) (*TransactionDetail, error) {
+
+	trx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &TransactionDetail{
+		TxID:          trx.TxID,
+		Type:          trx.Type,
+		BlockHash:     trx.BlockHash,
+		BlockHeight:   trx.BlockHeight,
+		Blocktime:     trx.Blocktime,
+		Confirmations: trx.Confirmations,
+		SysFee:        trx.SysFee,
+		NetFee:        trx.NetFee,
+		Inputs:        make([]*TxDetailInput, 0),
+		Outputs:       make([]*TxDetailOutput, 0),
+		AssetTotals:   make(map[string]*TxAssetTotal),
+	}
+
+	sysFee, _ := decimal.NewFromString(trx.SysFee)
+	netFee, _ := decimal.NewFromString(trx.NetFee)
+	detail.Fees = sysFee.Add(netFee).String()
+
+	//节点返回的交易单只有区块hash时，查区块补全高度
+	if detail.BlockHeight == 0 && len(detail.BlockHash) > 0 {
+		block, err := wm.GetBlock(detail.BlockHash, BlockDetailHeader)
+		if err != nil {
+			return nil, err
+		}
+		detail.BlockHeight = block.Height
+	}
+
+	if wm.config().RPCServerType != RPCServerExplorer {
+		detail.RawHex, err = wm.GetRawTxHex(txid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scanAddressFunc := wm.Blockscanner.filterScanAddressFunc(wm.Blockscanner.ScanAddressFunc)
+	watched := func(address string) (string, bool) {
+		if scanAddressFunc == nil || len(address) == 0 {
+			return "", false
+		}
+		return scanAddressFunc(address)
+	}
+
+	assetTotal := func(asset string) *TxAssetTotal {
+		total, ok := detail.AssetTotals[asset]
+		if !ok {
+			total = &TxAssetTotal{Input: decimal.Zero, Output: decimal.Zero}
+			detail.AssetTotals[asset] = total
+		}
+		return total
+	}
+
+	preTxs := make(map[string]*Transaction)
+	for _, vin := range trx.Vins {
+		input := &TxDetailInput{
+			SourceTxID:  vin.TxID,
+			SourceIndex: vin.Vout,
+			Address:     vin.Addr,
+			Amount:      vin.Value,
+		}
+
+		preTx, ok := preTxs[vin.TxID]
+		if !ok {
+			preTx, err = wm.GetTransaction(vin.TxID)
+			if err != nil {
+				return nil, err
+			}
+			preTxs[vin.TxID] = preTx
+		}
+		if int(vin.Vout) >= len(preTx.Vouts) {
+			return nil, fmt.Errorf("input source tx: %s has no vout: %d", vin.TxID, vin.Vout)
+		}
+		source := preTx.Vouts[vin.Vout]
+		input.Address = source.Addr
+		input.Asset = source.Asset
+		input.Amount = source.Value
+
+		input.AccountID, input.Watched = watched(input.Address)
+		detail.InvolveWatched = detail.InvolveWatched || input.Watched
+
+		amount, _ := decimal.NewFromString(input.Amount)
+		total := assetTotal(input.Asset)
+		total.Input = total.Input.Add(amount)
+
+		detail.Inputs = append(detail.Inputs, input)
+	}
+
+	for _, vout := range trx.Vouts {
+		output := &TxDetailOutput{
+			N:       vout.N,
+			Address: vout.Addr,
+			Asset:   vout.Asset,
+			Amount:  vout.Value,
+		}
+
+		output.AccountID, output.Watched = watched(output.Address)
+		detail.InvolveWatched = detail.InvolveWatched || output.Watched
+
+		amount, _ := decimal.NewFromString(output.Amount)
+		total := assetTotal(output.Asset)
+		total.Output = total.Output.Add(amount)
+
+		detail.Outputs = append(detail.Outputs, output)
+	}
+
+	return detail, nil
+}
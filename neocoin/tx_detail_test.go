@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestWalletManager_GetTransactionDetail(t *testing.T) {
+	const (
+		neo = "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b"
+		gas = "0x602c79718b16e442de58778e148d0b1084e3b2dffd5de6b7b16cee7969282de7"
+	)
+	var (
+		prevTxID  = testHash("prev")
+		txid      = testHash("tx")
+		blockHash = testHash("block")
+	)
+	txs := map[string]interface{}{
+		prevTxID: map[string]interface{}{
+			"txid": prevTxID,
+			"vout": []interface{}{
+				map[string]interface{}{"n": 0, "asset": neo, "value": "10", "address": "AFrom"},
+				map[string]interface{}{"n": 1, "asset": gas, "value": "1", "address": "AFrom"},
+			},
+		},
+		txid: map[string]interface{}{
+			"txid":          txid,
+			"type":          "ContractTransaction",
+			"blockhash":     blockHash,
+			"confirmations": 3,
+			"sys_fee":       "0",
+			"net_fee":       "0.001",
+			"vin": []interface{}{
+				map[string]interface{}{"txid": prevTxID, "vout": 0},
+				map[string]interface{}{"txid": prevTxID, "vout": 1},
+			},
+			"vout": []interface{}{
+				map[string]interface{}{"n": 0, "asset": neo, "value": "10", "address": "ATo"},
+				map[string]interface{}{"n": 1, "asset": gas, "value": "0.999", "address": "AFrom"},
+			},
+		},
+	}
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getrawtransaction":
+			if verbose, ok := params[1].(float64); ok && verbose == 0 {
+				return "80000001"
+			}
+			return txs[params[0].(string)]
+		case "getblock":
+			return map[string]interface{}{"index": 100, "hash": params[0], "previousblockhash": testHash("99"), "time": 1000, "tx": []interface{}{txid}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.Blockscanner.SetBlockScanAddressFunc(func(address string) (string, bool) {
+		return "account", address == "ATo"
+	})
+
+	detail, err := wm.GetTransactionDetail(txid)
+	if err != nil {
+		t.Errorf("GetTransactionDetail failed unexpected error: %v\n", err)
+		return
+	}
+
+	if detail.BlockHeight != 100 || detail.RawHex != "80000001" || detail.Fees != "0.001" {
+		t.Errorf("detail is not resolved, height: %d, raw: %s, fees: %s", detail.BlockHeight, detail.RawHex, detail.Fees)
+	}
+	if len(detail.Inputs) != 2 || detail.Inputs[1].Address != "AFrom" || detail.Inputs[1].Amount != "1" || detail.Inputs[1].Asset != gas {
+		t.Errorf("inputs are not resolved from previous transaction")
+	}
+	if total := detail.AssetTotals[gas]; total.Input.Sub(total.Output).String() != "0.001" {
+		t.Errorf("gas total input: %s, output: %s", total.Input, total.Output)
+	}
+	if !detail.InvolveWatched || !detail.Outputs[0].Watched || detail.Outputs[0].AccountID != "account" || detail.Inputs[0].Watched {
+		t.Errorf("watched address flags are wrong")
+	}
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+)
+
+//IndexedTxIO 索引的交易输入输出
+type IndexedTxIO struct {
+	N     uint64 `json:"n"` //输入为引用交易单的输出序号
+	Addr  string `json:"address"`
+	Value string `json:"value"`
+	Asset string `json:"asset,omitempty"`
+	TxID  string `json:"txid,omitempty"` //输入引用的交易单
+}
+
+//IndexedTx 浏览器模式下索引的交易单，不限于观测地址
+type IndexedTx struct {
+	TxID        string         `storm:"id" json:"txid"`
+	BlockHeight uint64         `storm:"index" json:"blockHeight"`
+	BlockHash   string         `json:"blockHash"`
+	Index       int            `json:"index"` //交易在区块中的序号
+	Type        string         `json:"type"`
+	Size        uint64         `json:"size"`
+	SysFee      string         `json:"sysFee"`
+	NetFee      string         `json:"netFee"`
+	Blocktime   int64          `json:"blocktime"`
+	Inputs      []*IndexedTxIO `json:"inputs"`
+	Outputs     []*IndexedTxIO `json:"outputs"`
+}
+
+//Addresses 交易单输入输出涉及的地址，去重
+func (tx *IndexedTx) Addresses() []string {
+	seen := make(map[string]bool)
+	addresses := make([]string, 0)
+	for _, list := range [][]*IndexedTxIO{tx.Inputs, tx.Outputs} {
+		for _, io := range list {
+			if len(io.Addr) == 0 || seen[io.Addr] {
+				continue
+			}
+			seen[io.Addr] = true
+			addresses = append(addresses, io.Addr)
+		}
+	}
+	return addresses
+}
+
+//NewIndexedTx 从节点交易单创建索引记录
+func NewIndexedTx(trx *Transaction, index int) *IndexedTx {
+	tx := &IndexedTx{
+		TxID:        trx.TxID,
+		BlockHeight: trx.BlockHeight,
+		BlockHash:   trx.BlockHash,
+		Index:       index,
+		Type:        trx.Type,
+		Size:        trx.Size,
+		SysFee:      trx.SysFee,
+		NetFee:      trx.NetFee,
+		Blocktime:   trx.Blocktime,
+		Inputs:      make([]*IndexedTxIO, 0, len(trx.Vins)),
+		Outputs:     make([]*IndexedTxIO, 0, len(trx.Vouts)),
+	}
+	for _, vin := range trx.Vins {
+		if len(vin.Coinbase) > 0 {
+			continue
+		}
+		tx.Inputs = append(tx.Inputs, &IndexedTxIO{N: vin.Vout, Addr: vin.Addr, Value: vin.Value, TxID: vin.TxID})
+	}
+	for _, vout := range trx.Vouts {
+		tx.Outputs = append(tx.Outputs, &IndexedTxIO{N: vout.N, Addr: vout.Addr, Value: vout.Value, Asset: vout.Asset})
+	}
+	return tx
+}
+
+//TxIndexStore 浏览器模式的交易索引存储，可替换为外部数据库实现
+type TxIndexStore interface {
+	//SaveBlockTxs 保存一个区块的交易索引，重复保存覆盖
+	SaveBlockTxs(height uint64, txs []*IndexedTx) error
+	//GetTx 按交易单号查询，不存在返回nil
+	GetTx(txid string) (*IndexedTx, error)
+	//GetTxsByHeight 按区块高度查询，按交易在区块中的序号排序
+	GetTxsByHeight(height uint64) ([]*IndexedTx, error)
+	//GetTxsByAddress 按地址分页查询，按区块高度倒序，返回分页结果和总数
+	GetTxsByAddress(address string, offset, limit int) ([]*IndexedTx, int, error)
+	//DeleteAboveHeight 删除高于指定高度的索引，用于分叉回滚
+	DeleteAboveHeight(height uint64) error
+}
+
+//indexedAddressTx 地址与交易单的关联
+type indexedAddressTx struct {
+	ID          string `storm:"id"`
+	Address     string `storm:"index"`
+	TxID        string
+	BlockHeight uint64 `storm:"index"`
+	Index       int
+}
+
+func newIndexedAddressTx(address string, tx *IndexedTx) *indexedAddressTx {
+	return &indexedAddressTx{
+		ID:          common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%s_%s", address, tx.TxID)))),
+		Address:     address,
+		TxID:        tx.TxID,
+		BlockHeight: tx.BlockHeight,
+		Index:       tx.Index,
+	}
+}
+
+//localTxIndexStore 默认的交易索引存储，保存在本地数据库独立文件
+type localTxIndexStore struct {
+	wm *WalletManager
+}
+
+func (s *localTxIndexStore) SaveBlockTxs(height uint64, txs []*IndexedTx) error {
+
+	if len(txs) == 0 {
+		return nil
+	}
+
+	db, err := s.wm.openLocalDB(s.wm.config().TxIndexFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, itx := range txs {
+		err = tx.Save(itx)
+		if err != nil {
+			return err
+		}
+		for _, address := range itx.Addresses() {
+			err = tx.Save(newIndexedAddressTx(address, itx))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *localTxIndexStore) GetTx(txid string) (*IndexedTx, error) {
+
+	db, err := s.wm.openLocalDB(s.wm.config().TxIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var tx IndexedTx
+	err = db.One("TxID", txid, &tx)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+func (s *localTxIndexStore) GetTxsByHeight(height uint64) ([]*IndexedTx, error) {
+
+	db, err := s.wm.openLocalDB(s.wm.config().TxIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*IndexedTx
+	err = db.Find("BlockHeight", height, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Index < list[j].Index
+	})
+
+	return list, nil
+}
+
+func (s *localTxIndexStore) GetTxsByAddress(address string, offset, limit int) ([]*IndexedTx, int, error) {
+
+	db, err := s.wm.openLocalDB(s.wm.config().TxIndexFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer db.Close()
+
+	var links []*indexedAddressTx
+	err = db.Find("Address", address, &links)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, 0, err
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].BlockHeight != links[j].BlockHeight {
+			return links[i].BlockHeight > links[j].BlockHeight
+		}
+		return links[i].Index > links[j].Index
+	})
+
+	total := len(links)
+	list := make([]*IndexedTx, 0)
+	if offset >= total {
+		return list, total, nil
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	for _, link := range links[offset:end] {
+		var tx IndexedTx
+		err = db.One("TxID", link.TxID, &tx)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, &tx)
+	}
+
+	return list, total, nil
+}
+
+func (s *localTxIndexStore) DeleteAboveHeight(height uint64) error {
+
+	db, err := s.wm.openLocalDB(s.wm.config().TxIndexFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var txs []*IndexedTx
+	err = db.Select(q.Gt("BlockHeight", height)).Find(&txs)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	var links []*indexedAddressTx
+	err = db.Select(q.Gt("BlockHeight", height)).Find(&links)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range txs {
+		err = tx.DeleteStruct(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, l := range links {
+		err = tx.DeleteStruct(l)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//SetTxIndexStore 设置浏览器模式的交易索引存储，nil恢复默认的本地数据库存储
+func (wm *WalletManager) SetTxIndexStore(store TxIndexStore) {
+	wm.txIndexMu.Lock()
+	defer wm.txIndexMu.Unlock()
+	wm.txIndex = store
+}
+
+//TxIndex 浏览器模式的交易索引存储
+func (wm *WalletManager) TxIndex() TxIndexStore {
+	wm.txIndexMu.RLock()
+	defer wm.txIndexMu.RUnlock()
+	if wm.txIndex == nil {
+		return &localTxIndexStore{wm: wm}
+	}
+	return wm.txIndex
+}
+
+//GetIndexedTx 按交易单号查询浏览器模式的交易索引
+func (wm *WalletManager) GetIndexedTx(txid string) (*IndexedTx, error) {
+	tx, err := wm.TxIndex().GetTx(txid)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get indexed transaction failed, unexpected error: %v", err)
+	}
+	return tx, nil
+}
+
+//GetIndexedTxsByHeight 按区块高度查询浏览器模式的交易索引
+func (wm *WalletManager) GetIndexedTxsByHeight(height uint64) ([]*IndexedTx, error) {
+	list, err := wm.TxIndex().GetTxsByHeight(height)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get indexed transaction failed, unexpected error: %v", err)
+	}
+	return list, nil
+}
+
+//GetIndexedTxsByAddress 按地址分页查询浏览器模式的交易索引，返回分页结果和总数
+func (wm *WalletManager) GetIndexedTxsByAddress(address string, offset, limit int) ([]*IndexedTx, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	list, total, err := wm.TxIndex().GetTxsByAddress(address, offset, limit)
+	if err != nil {
+		return nil, 0, wm.errorf(ErrLocalDBOperateFailed, "get indexed transaction failed, unexpected error: %v", err)
+	}
+	return list, total, nil
+}
+
+//indexBlockTransactions 浏览器模式下保存区块全部交易的索引，并通知区块观察者
+func (bs *NEOBlockScanner) indexBlockTransactions(height uint64, hash string, trxs []*Transaction, complete bool) error {
+
+	if height == 0 || len(trxs) == 0 {
+		return nil
+	}
+
+	txs := make([]*IndexedTx, 0, len(trxs))
+	for i, trx := range trxs {
+		if trx == nil {
+			continue
+		}
+		txs = append(txs, NewIndexedTx(trx, i))
+	}
+
+	err := bs.wm.TxIndex().SaveBlockTxs(height, txs)
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "save indexed transaction failed, unexpected error: %v", err)
+	}
+
+	//区块有提取失败的交易时，除非设置跳过失败区块，等重扫完整后再通知
+	if !complete && !bs.IsSkipFailedBlock {
+		return nil
+	}
+
+	details := make([]*Transaction, 0, len(trxs))
+	for _, trx := range trxs {
+		if trx != nil {
+			details = append(details, trx)
+		}
+	}
+
+	bs.Mu.RLock()
+	observers := make([]NEOBlockScanNotificationObject, 0, len(bs.NEOBlockObservers))
+	for o := range bs.NEOBlockObservers {
+		observers = append(observers, o)
+	}
+	bs.Mu.RUnlock()
+
+	block := &Block{Height: height, Hash: hash, TxDetails: details}
+	for _, o := range observers {
+		if err := o.NEOBlockScanNotify(block, details); err != nil {
+			bs.wm.Log.Std.Warning("block observer notify on height: %d failed, unexpected error: %v", height, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type testIndexBlockObserver struct {
+	blocks []*Block
+	txs    [][]*Transaction
+}
+
+func (o *testIndexBlockObserver) NEOBlockScanNotify(block *Block, txs []*Transaction) error {
+	o.blocks = append(o.blocks, block)
+	o.txs = append(o.txs, txs)
+	return nil
+}
+
+func TestNEOBlockScanner_ExplorerMode(t *testing.T) {
+	var (
+		fundTxID  = testHash("fund")
+		spendTxID = testHash("spend")
+		blockHash = testHash("block10")
+		sender    = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		receiver  = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getrawtransaction" {
+			return nil
+		}
+		switch params[0] {
+		case fundTxID:
+			return map[string]interface{}{"txid": fundTxID, "type": "ContractTransaction", "blockhash": blockHash,
+				"vin":  []interface{}{},
+				"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0xneo", "value": "10", "address": sender}}}
+		case spendTxID:
+			return map[string]interface{}{"txid": spendTxID, "type": "ContractTransaction", "blockhash": blockHash,
+				"vin":  []interface{}{map[string]interface{}{"txid": fundTxID, "vout": 0}},
+				"vout": []interface{}{map[string]interface{}{"n": 0, "asset": "0xneo", "value": "10", "address": receiver}}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.ExplorerMode = true
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	//不观测任何地址，浏览器模式仍索引全部交易
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "", false
+	}
+	observer := &testIndexBlockObserver{}
+	bs.AddBTCBlockObserver(observer)
+
+	if err := bs.BatchExtractTransaction(10, blockHash, []string{fundTxID, spendTxID}); err != nil {
+		t.Errorf("BatchExtractTransaction failed unexpected error: %v\n", err)
+		return
+	}
+
+	txs, err := wm.GetIndexedTxsByHeight(10)
+	if err != nil || len(txs) != 2 || txs[0].TxID != fundTxID || txs[1].TxID != spendTxID {
+		t.Errorf("unexpected indexed transactions of height: %v, err: %v", txs, err)
+		return
+	}
+
+	spend, err := wm.GetIndexedTx(spendTxID)
+	if err != nil || spend == nil || spend.BlockHash != blockHash || len(spend.Inputs) != 1 || spend.Inputs[0].Addr != sender || spend.Inputs[0].Value != "10" {
+		t.Errorf("unexpected indexed transaction: %+v, err: %v", spend, err)
+		return
+	}
+
+	list, total, err := wm.GetIndexedTxsByAddress(sender, 0, 1)
+	if err != nil || total != 2 || len(list) != 1 || list[0].TxID != spendTxID {
+		t.Errorf("unexpected address transactions: %v, total: %d, err: %v", list, total, err)
+	}
+	list, total, _ = wm.GetIndexedTxsByAddress(receiver, 0, 10)
+	if total != 1 || list[0].TxID != spendTxID {
+		t.Errorf("unexpected address transactions: %v, total: %d", list, total)
+	}
+
+	if len(observer.blocks) != 1 || observer.blocks[0].Height != 10 || len(observer.txs[0]) != 2 {
+		t.Errorf("block observer should be notified with all transactions")
+	}
+
+	//浏览器接口查询索引
+	httpServer := httptest.NewServer(NewExplorerServer(bs))
+	defer httpServer.Close()
+	resp, err := http.Get(httpServer.URL + "/index/address/" + receiver)
+	if err != nil {
+		t.Fatalf("get index failed, unexpected error: %v", err)
+	}
+	var page struct {
+		Total int          `json:"total"`
+		Txs   []*IndexedTx `json:"txs"`
+	}
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || page.Total != 1 || page.Txs[0].TxID != spendTxID {
+		t.Errorf("unexpected index api response: %d, %+v", resp.StatusCode, page)
+	}
+
+	//分叉回滚删除孤块的索引
+	if err := wm.TxIndex().DeleteAboveHeight(9); err != nil {
+		t.Errorf("DeleteAboveHeight failed unexpected error: %v\n", err)
+	}
+	if tx, _ := wm.GetIndexedTx(spendTxID); tx != nil {
+		t.Errorf("indexed transaction should be deleted")
+	}
+	if _, total, _ := wm.GetIndexedTxsByAddress(sender, 0, 10); total != 0 {
+		t.Errorf("address index should be deleted, total: %d", total)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//OrderIDParam 交易单ExtParam中的外部订单号字段
+	OrderIDParam = "orderID"
+
+	TxOrderStatusSubmitted = "submitted" //已广播，未达到确认数
+	TxOrderStatusConfirmed = "confirmed" //已达到确认数
+	TxOrderStatusCancelled = "cancelled" //已取消，未广播
+	TxOrderStatusScheduled = "scheduled" //已签名，等待定时广播
+)
+
+//TxOrderRecord 交易单与发起账户、外部订单号的对应关系
+type TxOrderRecord struct {
+	TxID          string `storm:"id"`
+	OrderID       string `storm:"index"`
+	AccountID     string `storm:"index"`
+	Sid           string
+	Status        string
+	BlockHash     string
+	Confirmations uint64
+	CreateAt      int64
+	UpdateAt      int64
+}
+
+//SetOrderID 设置交易单的外部订单号，广播或取消后可按订单号查询交易单和状态
+func SetOrderID(rawTx *openwallet.RawTransaction, orderID string) error {
+	return rawTx.SetExtParam(OrderIDParam, orderID)
+}
+
+//orderID 读取交易单的外部订单号
+func orderID(rawTx *openwallet.RawTransaction) string {
+	if len(rawTx.ExtParam) == 0 {
+		return ""
+	}
+	return rawTx.GetExtParam().Get(OrderIDParam).String()
+}
+
+//saveTxOrder 记录交易单的发起账户和外部订单号
+func (wm *WalletManager) saveTxOrder(txid, status string, rawTx *openwallet.RawTransaction) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now().Unix()
+	record := &TxOrderRecord{
+		TxID:     txid,
+		OrderID:  orderID(rawTx),
+		Sid:      rawTx.Sid,
+		Status:   status,
+		CreateAt: now,
+		UpdateAt: now,
+	}
+	if rawTx.Account != nil {
+		record.AccountID = rawTx.Account.AccountID
+	}
+
+	return db.Save(record)
+}
+
+//GetTxOrderByTxID 按txid查询发起账户、外部订单号和状态，不存在返回nil
+func (wm *WalletManager) GetTxOrderByTxID(txid string) (*TxOrderRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var record TxOrderRecord
+	err = db.One("TxID", txid, &record)
+	db.Close()
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	wm.refreshTxOrderStatus(&record)
+
+	return &record, nil
+}
+
+//GetTxOrdersByOrderID 按外部订单号查询交易单和状态，订单取消后重新发起时有多笔，按创建时间升序
+func (wm *WalletManager) GetTxOrdersByOrderID(orderID string) ([]*TxOrderRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*TxOrderRecord
+	err = db.Select(q.Eq("OrderID", orderID)).OrderBy("CreateAt").Find(&list)
+	db.Close()
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, record := range list {
+		wm.refreshTxOrderStatus(record)
+	}
+
+	return list, nil
+}
+
+//refreshTxOrderStatus 向节点查询未达到确认数的交易单，更新确认数和状态，查询失败保留原状态
+func (wm *WalletManager) refreshTxOrderStatus(record *TxOrderRecord) {
+
+	if record.Status != TxOrderStatusSubmitted {
+		return
+	}
+
+	trx, err := wm.GetTransaction(record.TxID)
+	if err != nil {
+		return
+	}
+
+	if trx.Confirmations == record.Confirmations && trx.BlockHash == record.BlockHash {
+		return
+	}
+
+	record.BlockHash = trx.BlockHash
+	record.Confirmations = trx.Confirmations
+	if len(record.BlockHash) > 0 && record.Confirmations >= wm.config().ConfirmBlocks {
+		record.Status = TxOrderStatusConfirmed
+	}
+	record.UpdateAt = time.Now().Unix()
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	if err := db.Save(record); err != nil {
+		wm.Log.Std.Error("update tx order: %s status failed; unexpected error: %v", record.TxID, err)
+	}
+}
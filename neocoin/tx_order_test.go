@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWalletManager_TxOrder(t *testing.T) {
+	var confirmations int32
+	rawTx := testCancelRawTransaction(t, 0, "")
+	SetOrderID(rawTx, "order-9")
+	txid, _ := GetTxId(rawTx.RawHex)
+
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "sendrawtransaction":
+			return true
+		case "getrawtransaction":
+			trx := map[string]interface{}{"txid": txid, "vin": []interface{}{}, "vout": []interface{}{}}
+			if n := atomic.LoadInt32(&confirmations); n > 0 {
+				trx["blockhash"] = testHash("block")
+				trx["confirmations"] = n
+			}
+			return trx
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.WalletClient = NewClient(server.URL, "", false)
+	decoder := NewTransactionDecoder(wm)
+
+	if _, err := decoder.SubmitRawTransaction(nil, rawTx); err != nil {
+		t.Errorf("SubmitRawTransaction failed unexpected error: %v", err)
+		return
+	}
+
+	record, err := wm.GetTxOrderByTxID(txid)
+	if err != nil || record == nil {
+		t.Errorf("GetTxOrderByTxID failed, record: %v, unexpected error: %v", record, err)
+		return
+	}
+	if record.OrderID != "order-9" || record.AccountID != "hot" || record.Status != TxOrderStatusSubmitted {
+		t.Errorf("unexpected tx order record: %+v", record)
+	}
+
+	//订单取消后重新发起的交易单
+	cancelled := testCancelRawTransaction(t, 1, "")
+	SetOrderID(cancelled, "order-9")
+	if err := decoder.CancelRawTransaction(cancelled); err != nil {
+		t.Errorf("CancelRawTransaction failed unexpected error: %v", err)
+		return
+	}
+
+	atomic.StoreInt32(&confirmations, 1)
+	list, err := wm.GetTxOrdersByOrderID("order-9")
+	if err != nil || len(list) != 2 {
+		t.Errorf("GetTxOrdersByOrderID should return 2 records, list: %v, unexpected error: %v", list, err)
+		return
+	}
+	statuses := map[string]string{}
+	for _, r := range list {
+		statuses[r.TxID] = r.Status
+	}
+	cancelledID, _ := GetTxId(cancelled.RawHex)
+	if statuses[txid] != TxOrderStatusConfirmed || statuses[cancelledID] != TxOrderStatusCancelled {
+		t.Errorf("unexpected tx order statuses: %v", statuses)
+	}
+}
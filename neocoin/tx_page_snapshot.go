@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//TxPageSnapshot 分页查询锚定的区块，只返回该区块及之前已确认的交易单，
+//分页期间新进入的区块不改变偏移，导出长历史时不重复不遗漏
+type TxPageSnapshot struct {
+	Height   uint64    //锚定的区块高度
+	Hash     string    //锚定的区块hash，分叉后快照失效
+	CreateAt time.Time //创建时间
+	UsedAt   time.Time //最近一次查询时间，超过有效期未使用的快照被清理
+}
+
+//NewTxPageSnapshot 以当前最新区块创建分页快照
+func (wm *WalletManager) NewTxPageSnapshot() (*TxPageSnapshot, error) {
+
+	height, err := wm.GetBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := wm.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &TxPageSnapshot{
+		Height:   height,
+		Hash:     hash,
+		CreateAt: now,
+		UsedAt:   now,
+	}, nil
+}
+
+//verifyTxPageSnapshot 检查锚定的区块是否仍在主链上
+func (wm *WalletManager) verifyTxPageSnapshot(snapshot *TxPageSnapshot) error {
+
+	hash, err := wm.GetBlockHash(snapshot.Height)
+	if err != nil {
+		return err
+	}
+
+	if normalizeTxID(hash) != normalizeTxID(snapshot.Hash) {
+		return wm.errorf(ErrTxPageSnapshotStale, "page snapshot block: %d hash: %s is replaced by: %s, restart from the first page", snapshot.Height, snapshot.Hash, hash)
+	}
+
+	return nil
+}
+
+//txPageSnapshotKey 按资产和排序后的地址区分查询
+func txPageSnapshotKey(coin openwallet.Coin, address []string) string {
+	addrs := make([]string, len(address))
+	copy(addrs, address)
+	sort.Strings(addrs)
+	return coin.Symbol + "|" + coin.Contract.Address + "|" + strings.Join(addrs, ",")
+}
+
+//txPageSnapshot 获取查询使用的分页快照，offset为0时重新创建，未启用时返回nil
+func (bs *NEOBlockScanner) txPageSnapshot(offset int, coin openwallet.Coin, address []string) (*TxPageSnapshot, error) {
+
+	ttl := bs.wm.config().TxPageSnapshotTTL
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	key := txPageSnapshotKey(coin, address)
+	now := time.Now()
+
+	bs.txPageMu.Lock()
+	defer bs.txPageMu.Unlock()
+
+	if bs.txPageSnapshots == nil {
+		bs.txPageSnapshots = make(map[string]*TxPageSnapshot)
+	}
+
+	//清理过期的快照
+	for k, s := range bs.txPageSnapshots {
+		if now.Sub(s.UsedAt) > ttl {
+			delete(bs.txPageSnapshots, k)
+		}
+	}
+
+	if snapshot, ok := bs.txPageSnapshots[key]; ok && offset > 0 {
+		snapshot.UsedAt = now
+		return snapshot, nil
+	}
+
+	snapshot, err := bs.wm.NewTxPageSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	bs.txPageSnapshots[key] = snapshot
+
+	return snapshot, nil
+}
+
+//dropTxPageSnapshot 删除查询的分页快照，下一次查询重新创建
+func (bs *NEOBlockScanner) dropTxPageSnapshot(coin openwallet.Coin, address []string) {
+	bs.txPageMu.Lock()
+	defer bs.txPageMu.Unlock()
+	delete(bs.txPageSnapshots, txPageSnapshotKey(coin, address))
+}
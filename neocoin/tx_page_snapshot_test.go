@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_TxPageSnapshot(t *testing.T) {
+	var (
+		tip    uint64 = 60
+		prefix        = "main"
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getblockcount":
+			return tip + 1
+		case "getblockhash":
+			return testHash(fmt.Sprintf("%s%v", prefix, params[0]))
+		}
+		return nil
+	})
+	defer server.Close()
+
+	explorer := newTestExplorer(60)
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.SetExplorerClient(explorer)
+	bs := wm.Blockscanner
+	coin := openwallet.Coin{Symbol: Symbol}
+
+	//第一页记录当前区块，未确认的交易单不在快照内
+	snapshot, err := bs.txPageSnapshot(0, coin, []string{"A", "B"})
+	if err != nil || snapshot == nil || snapshot.Height != 60 {
+		t.Fatalf("txPageSnapshot = %+v, %v", snapshot, err)
+	}
+	trxs, err := wm.getMultiAddrTransactionsByExplorer(0, 10, snapshot.Height, "A", "B")
+	if err != nil || len(trxs) != 10 || trxs[0].TxID != "tx60_1" {
+		t.Fatalf("unexpected first page, count: %d, err: %v", len(trxs), err)
+	}
+
+	//翻页期间有新区块和未确认交易单进入，后续页锚定到第一页的区块
+	explorer.addTx(61, 0)
+	explorer.addTx(62, 0)
+	explorer.addTx(0, 1)
+	tip = 62
+	next, err := bs.txPageSnapshot(10, coin, []string{"B", "A"})
+	if err != nil || next != snapshot {
+		t.Fatalf("later page should reuse snapshot, got: %+v, %v", next, err)
+	}
+	trxs, _ = wm.getMultiAddrTransactionsByExplorer(10, 10, next.Height, "A", "B")
+	if len(trxs) != 10 || trxs[0].TxID != "tx55_1" || trxs[9].TxID != "tx51_0" {
+		t.Errorf("unexpected second page, count: %d", len(trxs))
+	}
+
+	//重新从第一页查询时使用最新区块
+	if fresh, _ := bs.txPageSnapshot(0, coin, []string{"A", "B"}); fresh == nil || fresh.Height != 62 {
+		t.Errorf("first page should create new snapshot, got: %+v", fresh)
+	}
+
+	//锚定的区块被分叉替换后返回错误并丢弃快照
+	prefix = "fork"
+	_, err = bs.GetTransactionsByAddress(10, 10, coin, "A", "B")
+	openErr, ok := err.(*openwallet.Error)
+	if !ok || openErr.Code() != ErrTxPageSnapshotStale {
+		t.Errorf("stale snapshot should fail, err: %v", err)
+	}
+	if _, ok := bs.txPageSnapshots[txPageSnapshotKey(coin, []string{"A", "B"})]; ok {
+		t.Errorf("stale snapshot should be dropped")
+	}
+
+	//未启用时不锚定
+	wm.Config.TxPageSnapshotTTL = 0
+	if s, err := bs.txPageSnapshot(0, coin, []string{"A"}); s != nil || err != nil {
+		t.Errorf("disabled snapshot = %+v, %v", s, err)
+	}
+}
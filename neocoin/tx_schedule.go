@@ -0,0 +1,347 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//BroadcastAtParam 交易单ExtParam中的最早广播时间，unix秒
+	BroadcastAtParam = "broadcastAt"
+
+	JobNameScheduledBroadcast = "scheduled_broadcast" //广播到期的定时交易单
+
+	AlertTypeScheduledBroadcastFailed = "scheduled_broadcast_failed" //定时交易单的输入已失效或多次广播失败
+
+	//scheduledBroadcastMaxAttempts 定时交易单广播失败的最大次数，超过后不再重试
+	scheduledBroadcastMaxAttempts = 10
+)
+
+//ScheduledBroadcastStatus 定时交易单状态
+type ScheduledBroadcastStatus int
+
+const (
+	ScheduledBroadcastPending   ScheduledBroadcastStatus = iota //等待广播
+	ScheduledBroadcastSubmitted                                 //已广播
+	ScheduledBroadcastFailed                                    //输入已失效或多次广播失败，不再广播
+	ScheduledBroadcastCancelled                                 //交易单已取消
+)
+
+//ScheduledBroadcast 已签名待定时广播的交易单
+type ScheduledBroadcast struct {
+	TxID        string `storm:"id"`
+	Sid         string
+	AccountID   string
+	RawTx       string                   //交易单json
+	BroadcastAt int64                    //最早广播时间
+	Status      ScheduledBroadcastStatus `storm:"index"`
+	Attempts    int                      //广播失败次数
+	LastError   string
+	CreateAt    int64
+	UpdateAt    int64
+}
+
+//SetBroadcastAt 设置交易单的最早广播时间，到时间前提交的交易单由定时广播任务广播
+func SetBroadcastAt(rawTx *openwallet.RawTransaction, at time.Time) error {
+	return rawTx.SetExtParam(BroadcastAtParam, at.Unix())
+}
+
+//broadcastAt 读取交易单的最早广播时间，未设置时为0
+func broadcastAt(rawTx *openwallet.RawTransaction) int64 {
+	if len(rawTx.ExtParam) == 0 {
+		return 0
+	}
+	return rawTx.GetExtParam().Get(BroadcastAtParam).Int()
+}
+
+//checkBroadcastAt 创建交易单时检查最早广播时间
+func (wm *WalletManager) checkBroadcastAt(rawTx *openwallet.RawTransaction) error {
+	if len(rawTx.ExtParam) == 0 || !rawTx.GetExtParam().Get(BroadcastAtParam).Exists() {
+		return nil
+	}
+	if broadcastAt(rawTx) <= 0 {
+		return fmt.Errorf("broadcastAt: %s is invalid", rawTx.GetExtParam().Get(BroadcastAtParam).Raw)
+	}
+	if !wm.config().ScheduledBroadcastJob {
+		return fmt.Errorf("scheduled broadcast job is disabled, broadcastAt is not supported")
+	}
+	return nil
+}
+
+//scheduleBroadcast 提交时未到最早广播时间的交易单保存为定时交易单，返回ErrTransactionScheduled错误，
+//到时间后由定时广播任务重新验证输入后广播
+func (wm *WalletManager) scheduleBroadcast(rawTx *openwallet.RawTransaction) error {
+
+	at := broadcastAt(rawTx)
+	if at <= time.Now().Unix() {
+		return nil
+	}
+
+	txid, err := GetTxId(rawTx.RawHex)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(rawTx)
+	if err != nil {
+		return err
+	}
+
+	record, err := wm.GetScheduledBroadcast(txid)
+	if err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "get scheduled broadcast failed, unexpected error: %v", err)
+	}
+	if record == nil {
+		record = &ScheduledBroadcast{
+			TxID:     txid,
+			Sid:      rawTx.Sid,
+			CreateAt: time.Now().Unix(),
+		}
+		if rawTx.Account != nil {
+			record.AccountID = rawTx.Account.AccountID
+		}
+	}
+	record.RawTx = string(raw)
+	record.BroadcastAt = at
+	record.Status = ScheduledBroadcastPending
+
+	if err = wm.saveScheduledBroadcast(record); err != nil {
+		return wm.errorf(ErrLocalDBOperateFailed, "save scheduled broadcast failed, unexpected error: %v", err)
+	}
+
+	//订单记录为定时广播，保存失败只告警
+	if err := wm.saveTxOrder(txid, TxOrderStatusScheduled, rawTx); err != nil {
+		wm.Log.Warningf("[Sid: %s] save tx order: %s failed: %v", rawTx.Sid, txid, err)
+	}
+
+	wm.auditTransaction(AuditActionSchedule, record.AccountID, txid, rawTx.RawHex, "")
+
+	return wm.errorf(ErrTransactionScheduled, "transaction: %s is scheduled to broadcast at %s", txid, time.Unix(at, 0).Format(time.RFC3339))
+}
+
+//GetScheduledBroadcast 获取定时交易单，不存在返回nil
+func (wm *WalletManager) GetScheduledBroadcast(txid string) (*ScheduledBroadcast, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var record ScheduledBroadcast
+	err = db.One("TxID", txid, &record)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+//GetScheduledBroadcasts 获取某个状态的定时交易单，按最早广播时间排序
+func (wm *WalletManager) GetScheduledBroadcasts(status ScheduledBroadcastStatus) ([]*ScheduledBroadcast, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ScheduledBroadcast
+	err = db.Select(q.Eq("Status", status)).OrderBy("BroadcastAt").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//saveScheduledBroadcast 更新定时交易单
+func (wm *WalletManager) saveScheduledBroadcast(record *ScheduledBroadcast) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	record.UpdateAt = time.Now().Unix()
+	return db.Save(record)
+}
+
+//RunScheduledBroadcasts 广播已到时间的定时交易单，返回广播成功的数量。
+//广播前重新验证输入未被花费，输入已失效时不再广播，释放占用的提币额度并告警。
+//交易单在提交时已审批，广播时不再审批
+func (wm *WalletManager) RunScheduledBroadcasts() (int, error) {
+
+	pending, err := wm.GetScheduledBroadcasts(ScheduledBroadcastPending)
+	if err != nil {
+		return 0, wm.errorf(ErrLocalDBOperateFailed, "get scheduled broadcast failed, unexpected error: %v", err)
+	}
+
+	var (
+		now       = time.Now().Unix()
+		submitted = 0
+		failed    = 0
+		decoder   = NewTransactionDecoder(wm)
+	)
+	for _, record := range pending {
+		if record.BroadcastAt > now {
+			break
+		}
+
+		var rawTx openwallet.RawTransaction
+		if err := json.Unmarshal([]byte(record.RawTx), &rawTx); err != nil {
+			wm.failScheduledBroadcast(record, nil, fmt.Sprintf("decode raw transaction failed: %v", err))
+			failed++
+			continue
+		}
+
+		//输入已被花费时，可能是该交易单已被其他途径广播
+		if spent, err := wm.spentTxInputs(rawTx.RawHex); err != nil {
+			wm.retryScheduledBroadcast(record, &rawTx, err)
+			failed++
+			continue
+		} else if len(spent) > 0 {
+			if _, err := wm.GetTransaction(record.TxID); err == nil {
+				record.Status = ScheduledBroadcastSubmitted
+				wm.saveScheduledBroadcast(record)
+				continue
+			}
+			wm.failScheduledBroadcast(record, &rawTx, fmt.Sprintf("inputs: %s are spent", strings.Join(spent, ", ")))
+			failed++
+			continue
+		}
+
+		//提交时已审批，不再重复审批
+		_, err := decoder.submitRawTransaction(&rawTx, false)
+		if err == nil {
+			record.Status = ScheduledBroadcastSubmitted
+			record.LastError = ""
+			if err := wm.saveScheduledBroadcast(record); err != nil {
+				wm.Log.Std.Warning("save scheduled broadcast: %s failed, unexpected error: %v", record.TxID, err)
+			}
+			wm.Log.Std.Notice("[Sid: %s] scheduled transaction: %s broadcast", record.Sid, record.TxID)
+			submitted++
+			continue
+		}
+
+		//已取消的交易单不再广播，不算失败
+		if openErr, ok := err.(*openwallet.Error); ok && openErr.Code() == ErrTransactionCancelled {
+			record.Status = ScheduledBroadcastCancelled
+			record.LastError = err.Error()
+			wm.saveScheduledBroadcast(record)
+			continue
+		}
+
+		failed++
+		wm.retryScheduledBroadcast(record, &rawTx, err)
+	}
+
+	if failed > 0 {
+		return submitted, fmt.Errorf("%d of %d due scheduled transactions are not broadcast", failed, submitted+failed)
+	}
+
+	return submitted, nil
+}
+
+//spentTxInputs 查询交易单已被花费的输入
+func (wm *WalletManager) spentTxInputs(rawHex string) ([]string, error) {
+
+	txBytes, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, err
+	}
+	trx, err := neoTransaction.DecodeRawTransaction(txBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make([]string, 0)
+	for _, in := range trx.Vins {
+		txid := strings.TrimPrefix(in.GetTxID(), "0x")
+		out, err := wm.GetTxOut(txid, uint64(in.GetVout()))
+		if err != nil {
+			return nil, err
+		}
+		//节点返回空结果表示输出已被花费
+		if out == nil || len(out.Addr) == 0 {
+			spent = append(spent, fmt.Sprintf("%s:%d", txid, in.GetVout()))
+		}
+	}
+
+	return spent, nil
+}
+
+//retryScheduledBroadcast 广播失败，下次执行任务时重试，超过最大次数后不再广播
+func (wm *WalletManager) retryScheduledBroadcast(record *ScheduledBroadcast, rawTx *openwallet.RawTransaction, err error) {
+	record.Attempts++
+	if record.Attempts >= scheduledBroadcastMaxAttempts {
+		wm.failScheduledBroadcast(record, rawTx, fmt.Sprintf("broadcast failed %d times, last error: %v", record.Attempts, err))
+		return
+	}
+	record.LastError = err.Error()
+	if saveErr := wm.saveScheduledBroadcast(record); saveErr != nil {
+		wm.Log.Std.Warning("save scheduled broadcast: %s failed, unexpected error: %v", record.TxID, saveErr)
+	}
+	wm.Log.Std.Warning("[Sid: %s] scheduled transaction: %s broadcast failed, attempts: %d, unexpected error: %v", record.Sid, record.TxID, record.Attempts, err)
+}
+
+//failScheduledBroadcast 定时交易单不再广播，释放占用的提币额度并告警
+func (wm *WalletManager) failScheduledBroadcast(record *ScheduledBroadcast, rawTx *openwallet.RawTransaction, reason string) {
+
+	record.Status = ScheduledBroadcastFailed
+	record.LastError = reason
+	if err := wm.saveScheduledBroadcast(record); err != nil {
+		wm.Log.Std.Warning("save scheduled broadcast: %s failed, unexpected error: %v", record.TxID, err)
+	}
+
+	if rawTx != nil && len(rawTx.ExtParam) > 0 {
+		wm.releaseWithdraw(rawTx.GetExtParam().Get(WithdrawReservationParam).String())
+	}
+
+	alert := NewAlert(wm.Symbol(), AlertTypeScheduledBroadcastFailed, 0,
+		fmt.Sprintf("scheduled transaction: %s is not broadcast, %s", record.TxID, reason))
+	alert.Details["txid"] = record.TxID
+	alert.Details["sid"] = record.Sid
+	alert.Details["accountID"] = record.AccountID
+	alert.Details["broadcastAt"] = time.Unix(record.BroadcastAt, 0).Format(time.RFC3339)
+	wm.Blockscanner.newAlertNotify(alert)
+}
+
+//NewScheduledBroadcastJob 广播到期的定时交易单任务
+func (wm *WalletManager) NewScheduledBroadcastJob(interval time.Duration) *MaintenanceJob {
+	return &MaintenanceJob{
+		Name:     JobNameScheduledBroadcast,
+		Interval: interval,
+		Urgent:   true,
+		Run: func() error {
+			_, err := wm.RunScheduledBroadcasts()
+			return err
+		},
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_ScheduledBroadcast(t *testing.T) {
+	broadcasts := 0
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "sendrawtransaction":
+			broadcasts++
+			return true
+		case "gettxout":
+			//输出2已被花费
+			if params[1].(float64) == 2 {
+				return nil
+			}
+			return map[string]interface{}{"n": params[1], "asset": "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", "value": "10", "address": testHotAddress}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.TxIDCheck = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+	alerts := &testAlertObserver{}
+	wm.Blockscanner.AddAlertObserver(alerts)
+
+	//未到时间时保存，不广播
+	due := testCancelRawTransaction(t, 0, "due")
+	SetBroadcastAt(due, time.Now().Add(time.Hour))
+	_, err := wm.TxDecoder.SubmitRawTransaction(nil, due)
+	if openErr, ok := err.(*openwallet.Error); !ok || openErr.Code() != ErrTransactionScheduled || broadcasts != 0 {
+		t.Fatalf("submit before broadcastAt should be scheduled, broadcasts: %d, err: %v", broadcasts, err)
+	}
+	txid, _ := GetTxId(due.RawHex)
+	if order, _ := wm.GetTxOrderByTxID(txid); order == nil || order.Status != TxOrderStatusScheduled {
+		t.Errorf("tx order should be scheduled, got: %+v", order)
+	}
+
+	spent := testCancelRawTransaction(t, 2, "spent")
+	SetBroadcastAt(spent, time.Now().Add(time.Hour))
+	wm.TxDecoder.SubmitRawTransaction(nil, spent)
+
+	cancelled := testCancelRawTransaction(t, 1, "cancelled")
+	SetBroadcastAt(cancelled, time.Now().Add(time.Hour))
+	wm.TxDecoder.SubmitRawTransaction(nil, cancelled)
+	if err := wm.TxDecoder.(*TransactionDecoder).CancelRawTransaction(cancelled); err != nil {
+		t.Fatalf("CancelRawTransaction failed, unexpected error: %v", err)
+	}
+
+	//到时间前任务不广播
+	if count, err := wm.RunScheduledBroadcasts(); err != nil || count != 0 || broadcasts != 0 {
+		t.Fatalf("RunScheduledBroadcasts = %d, %v, broadcasts: %d", count, err, broadcasts)
+	}
+
+	//到时间后重新验证输入再广播
+	pending, _ := wm.GetScheduledBroadcasts(ScheduledBroadcastPending)
+	if len(pending) != 3 {
+		t.Fatalf("scheduled broadcasts should be pending, got: %d", len(pending))
+	}
+	for _, record := range pending {
+		record.BroadcastAt = time.Now().Add(-time.Second).Unix()
+		wm.saveScheduledBroadcast(record)
+	}
+	count, err := wm.RunScheduledBroadcasts()
+	if err == nil || count != 1 || broadcasts != 1 {
+		t.Fatalf("RunScheduledBroadcasts = %d, %v, broadcasts: %d", count, err, broadcasts)
+	}
+	if record, _ := wm.GetScheduledBroadcast(txid); record.Status != ScheduledBroadcastSubmitted {
+		t.Errorf("due transaction should be submitted, got: %+v", record)
+	}
+	spentTxID, _ := GetTxId(spent.RawHex)
+	if record, _ := wm.GetScheduledBroadcast(spentTxID); record.Status != ScheduledBroadcastFailed {
+		t.Errorf("transaction with spent inputs should fail, got: %+v", record)
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Type != AlertTypeScheduledBroadcastFailed || alerts.alerts[0].Details["txid"] != spentTxID {
+		t.Errorf("spent inputs should raise an alert, got: %+v", alerts.alerts)
+	}
+	cancelledTxID, _ := GetTxId(cancelled.RawHex)
+	if record, _ := wm.GetScheduledBroadcast(cancelledTxID); record.Status != ScheduledBroadcastCancelled {
+		t.Errorf("cancelled transaction should not be broadcast, got: %+v", record)
+	}
+
+	//已处理的不再广播
+	if count, err := wm.RunScheduledBroadcasts(); err != nil || count != 0 || broadcasts != 1 {
+		t.Errorf("RunScheduledBroadcasts = %d, %v, broadcasts: %d", count, err, broadcasts)
+	}
+
+	//未启用定时广播任务时不接受最早广播时间
+	wm.Config.ScheduledBroadcastJob = false
+	rawTx := &openwallet.RawTransaction{}
+	SetBroadcastAt(rawTx, time.Now())
+	if err := wm.checkBroadcastAt(rawTx); err == nil {
+		t.Errorf("broadcastAt should be rejected when job is disabled")
+	}
+}
+
+func TestWalletManager_ScheduledBroadcastApproved(t *testing.T) {
+	broadcasts := 0
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "sendrawtransaction":
+			broadcasts++
+			return true
+		case "gettxout":
+			return map[string]interface{}{"n": params[1], "asset": "0xc56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", "value": "10", "address": testHotAddress}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.TxIDCheck = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	//提交时审批通过后保存为定时交易单
+	approver := &testApprover{status: ApprovalApproved}
+	wm.SetTransactionApprover(approver)
+	due := testCancelRawTransaction(t, 0, "due")
+	SetBroadcastAt(due, time.Now().Add(time.Hour))
+	if _, err := wm.TxDecoder.SubmitRawTransaction(nil, due); err == nil || approver.calls != 1 {
+		t.Fatalf("submit before broadcastAt should be scheduled after approval, calls: %d, err: %v", approver.calls, err)
+	}
+
+	//审批服务结果变化不影响已审批的定时交易单，广播时不再请求审批
+	approver.status = ApprovalPending
+	txid, _ := GetTxId(due.RawHex)
+	record, _ := wm.GetScheduledBroadcast(txid)
+	record.BroadcastAt = time.Now().Add(-time.Second).Unix()
+	wm.saveScheduledBroadcast(record)
+	count, err := wm.RunScheduledBroadcasts()
+	if err != nil || count != 1 || broadcasts != 1 || approver.calls != 1 {
+		t.Fatalf("RunScheduledBroadcasts = %d, %v, broadcasts: %d, approve calls: %d", count, err, broadcasts, approver.calls)
+	}
+	if record, _ := wm.GetScheduledBroadcast(txid); record.Status != ScheduledBroadcastSubmitted {
+		t.Errorf("scheduled transaction should be submitted, got: %+v", record)
+	}
+}
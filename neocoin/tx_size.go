@@ -0,0 +1,306 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//MaxTransactionSize NEO节点接受的交易单大小上限
+	MaxTransactionSize = 102400
+
+	txInputSize  = 34 //prevHash(32) + prevIndex(2)
+	txOutputSize = 60 //assetID(32) + value(8) + scriptHash(20)
+	signatureLen = 64
+	pubkeyLen    = 33
+)
+
+//WitnessSigner 输入地址的签名方式，单签Required和Total为1
+type WitnessSigner struct {
+	Required int
+	Total    int
+	MultiSig bool
+}
+
+//varIntSize 变长整数编码的字节数
+func varIntSize(n int) int {
+	switch {
+	case n < 0xfd:
+		return 1
+	case n <= 0xffff:
+		return 3
+	default:
+		return 5
+	}
+}
+
+//pushIntSize 脚本压入小整数的字节数
+func pushIntSize(n int) int {
+	if n <= 16 {
+		return 1
+	}
+	return 2
+}
+
+//Size 见证人的字节数，调用脚本为每个签名一个PUSHBYTES64，
+//验证脚本单签为PUSHBYTES33 <pubkey> CHECKSIG，多签为 m <pubkey>... n CHECKMULTISIG
+func (s WitnessSigner) Size() int {
+	invocation := s.Required * (1 + signatureLen)
+	verification := 1 + pubkeyLen + 1
+	if s.MultiSig {
+		verification = pushIntSize(s.Required) + s.Total*(1+pubkeyLen) + pushIntSize(s.Total) + 1
+	}
+	return varIntSize(invocation) + invocation + varIntSize(verification) + verification
+}
+
+//EstimateTxSize 估算签名后的合约交易单字节数，signers为每个输入地址的签名方式
+func EstimateTxSize(inputs, outputs int, signers []WitnessSigner) int {
+	//type + version + 空属性
+	size := 1 + 1 + varIntSize(0)
+	size += varIntSize(inputs) + inputs*txInputSize
+	size += varIntSize(outputs) + outputs*txOutputSize
+	size += varIntSize(len(signers))
+	for _, s := range signers {
+		size += s.Size()
+	}
+	return size
+}
+
+//witnessSigner 按地址保存的公钥或赎回脚本判断签名方式，无法判断时按单签计算
+func (decoder *TransactionDecoder) witnessSigner(wrapper openwallet.WalletDAI, address string) WitnessSigner {
+
+	single := WitnessSigner{Required: 1, Total: 1}
+
+	addr, err := wrapper.GetAddress(address)
+	if err != nil || addr == nil {
+		return single
+	}
+
+	script, err := hex.DecodeString(addr.PublicKey)
+	if err != nil || len(script) == 0 || script[len(script)-1] != opCheckMultiSig {
+		return single
+	}
+
+	m, pubkeys, err := parseVerificationScript(script)
+	if err != nil {
+		return single
+	}
+
+	return WitnessSigner{Required: m, Total: len(pubkeys), MultiSig: true}
+}
+
+//splitUnspentsBySize 按交易单大小上限和最大输入地址数拆分未花记录，同一地址的未花可拆到多笔交易单，
+//outputs为每笔交易单的输出数量
+func (decoder *TransactionDecoder) splitUnspentsBySize(wrapper openwallet.WalletDAI, usedUtxos []*UnspentBalance, outputs int) ([][]*UnspentBalance, error) {
+
+	var (
+		maxSize   = decoder.wm.config().MaxTxSize
+		maxAddrs  = decoder.wm.config().MaxTxInputs
+		groups    = make([][]*UnspentBalance, 0)
+		current   = make([]*UnspentBalance, 0)
+		signers   = make([]WitnessSigner, 0)
+		curInputs = 0
+	)
+
+	if maxSize <= 0 {
+		maxSize = MaxTransactionSize
+	}
+
+	flush := func() {
+		if len(current) > 0 {
+			groups = append(groups, current)
+		}
+		current = make([]*UnspentBalance, 0)
+		signers = make([]WitnessSigner, 0)
+		curInputs = 0
+	}
+
+	for _, u := range usedUtxos {
+
+		if u.NEOUnspent == nil || u.NEOUnspent.UnspentTxs == nil {
+			continue
+		}
+
+		signer := decoder.witnessSigner(wrapper, u.Address)
+		var part *UnspentBalance
+
+		for _, tx := range *u.NEOUnspent.UnspentTxs {
+
+			newSigners := signers
+			if part == nil {
+				newSigners = append(newSigners, signer)
+			}
+
+			//超出大小上限或输入地址数上限时，开始新的交易单
+			overSize := EstimateTxSize(curInputs+1, outputs, newSigners) > maxSize
+			overAddrs := part == nil && maxAddrs > 0 && len(current) >= maxAddrs
+			if overSize || overAddrs {
+				if curInputs == 0 {
+					return nil, decoder.wm.errorf(ErrTransactionTooLarge, "address: %s input can not fit in transaction size limit: %d", u.Address, maxSize)
+				}
+				flush()
+				part = nil
+			}
+
+			if part == nil {
+				part = cloneUnspentBalance(u)
+				current = append(current, part)
+				signers = append(signers, signer)
+			}
+
+			*part.NEOUnspent.UnspentTxs = append(*part.NEOUnspent.UnspentTxs, tx)
+			amount, _ := decimal.NewFromString(part.NEOUnspent.Amount)
+			value, _ := decimal.NewFromString(tx.Value)
+			part.NEOUnspent.Amount = amount.Add(value).String()
+			curInputs++
+		}
+	}
+
+	flush()
+
+	return groups, nil
+}
+
+//cloneUnspentBalance 复制地址未花记录，NEO未花列表和数额为空
+func cloneUnspentBalance(u *UnspentBalance) *UnspentBalance {
+	clone := *u
+	neo := *u.NEOUnspent
+	utxos := make([]UnspentTx, 0)
+	neo.UnspentTxs = &utxos
+	neo.Amount = "0"
+	clone.NEOUnspent = &neo
+	return &clone
+}
+
+//CreateSplitRawTransaction 创建转账交易单，签名后超出大小上限或最大输入地址数时拆分成多笔交易单，
+//每笔交易单向接收地址发送部分数额，合计为发送数额，只支持一个接收地址的NEO转账
+func (decoder *TransactionDecoder) CreateSplitRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) ([]*openwallet.RawTransaction, error) {
+
+	if rawTx.Coin.IsContract {
+		return nil, fmt.Errorf("split transaction does not support contract transfer")
+	}
+
+	if len(rawTx.To) != 1 {
+		return nil, fmt.Errorf("split transaction supports only one receiver")
+	}
+
+	//筛查接收地址风险
+	if err := decoder.checkWithdrawRisk(rawTx); err != nil {
+		return nil, err
+	}
+
+	var (
+		to        string
+		totalSend decimal.Decimal
+		accountID = rawTx.Account.AccountID
+	)
+	for addr, amount := range rawTx.To {
+		to = addr
+		totalSend, _ = decimal.NewFromString(amount)
+	}
+
+	usedUtxos, _, err := decoder.selectNEOUnspents(wrapper, accountID, totalSend)
+	if err != nil {
+		return nil, err
+	}
+
+	//接收地址和找零地址
+	groups, err := decoder.splitUnspentsBySize(wrapper, usedUtxos, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rawTxs      = make([]*openwallet.RawTransaction, 0, len(groups))
+		withdrawIDs = make([]string, 0, len(groups))
+		remain      = totalSend
+		key         = idempotencyKey(rawTx)
+		releaseAll  = func() {
+			for _, id := range withdrawIDs {
+				decoder.wm.releaseWithdraw(id)
+			}
+		}
+	)
+
+	for i, group := range groups {
+
+		if !remain.GreaterThan(decimal.Zero) {
+			break
+		}
+
+		balance := decimal.Zero
+		for _, u := range group {
+			amount, _ := decimal.NewFromString(u.NEOUnspent.Amount)
+			balance = balance.Add(amount)
+		}
+
+		send := decimal.Min(balance, remain)
+		remain = remain.Sub(send)
+
+		splitTx := &openwallet.RawTransaction{
+			Coin:     rawTx.Coin,
+			Account:  rawTx.Account,
+			Sid:      rawTx.Sid,
+			ExtParam: rawTx.ExtParam,
+			To:       map[string]string{to: send.StringFixed(decoder.wm.Decimal())},
+			FeeRate:  decoder.wm.FormatGAS(decimal.Zero),
+			Fees:     decoder.wm.FormatGAS(decimal.Zero),
+			Required: 1,
+		}
+		//每笔交易单使用各自的幂等键，避免广播时被视为同一笔
+		if len(key) > 0 {
+			SetIdempotencyKey(splitTx, fmt.Sprintf("%s-%d", key, i))
+		}
+
+		//检查提币限额并占用额度
+		withdrawID, err := decoder.wm.reserveWithdraw(splitTx)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		withdrawIDs = append(withdrawIDs, withdrawID)
+
+		outputAddrs := appendOutput(make(map[string]decimal.Decimal), to, send)
+		if change := balance.Sub(send); change.GreaterThan(decimal.Zero) {
+			outputAddrs = appendOutput(outputAddrs, group[0].Address, change)
+		}
+
+		err = decoder.createNEORawTransaction(wrapper, splitTx, group, outputAddrs)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		if len(withdrawID) > 0 {
+			splitTx.SetExtParam(WithdrawReservationParam, withdrawID)
+		}
+
+		//记录审计日志
+		toJSON, _ := json.Marshal(splitTx.To)
+		decoder.wm.auditTransaction(AuditActionCreate, accountID, "", splitTx.RawHex, string(toJSON))
+
+		rawTxs = append(rawTxs, splitTx)
+	}
+
+	decoder.wm.Log.Std.Notice("[Sid: %s] transfer: %s to %s split into %d transactions", rawTx.Sid, totalSend.String(), to, len(rawTxs))
+
+	return rawTxs, nil
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestEstimateTxSize(t *testing.T) {
+	single := WitnessSigner{Required: 1, Total: 1}
+	if size := single.Size(); size != 102 {
+		t.Errorf("single signature witness size: %d, expected: 102", size)
+	}
+	multi := WitnessSigner{Required: 2, Total: 3, MultiSig: true}
+	if size := multi.Size(); size != 237 {
+		t.Errorf("2-of-3 multisig witness size: %d, expected: 237", size)
+	}
+
+	//未签名交易单加上见证人
+	txHex, _ := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction,
+		[]neoTransaction.Vin{{TxID: testHash("0")[2:], Vout: 0}},
+		[]neoTransaction.Vout{{Asset: "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", Address: testColdAddress1, Value: 10}},
+		nil)
+	if size, expected := EstimateTxSize(1, 1, []WitnessSigner{single}), len(txHex)/2+1+single.Size(); size != expected {
+		t.Errorf("estimate tx size: %d, expected: %d", size, expected)
+	}
+}
+
+func TestTransactionDecoder_CreateSplitRawTransaction(t *testing.T) {
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getunspents" {
+			return nil
+		}
+		unspent := make([]interface{}, 0)
+		for i := 0; i < 5; i++ {
+			unspent = append(unspent, map[string]interface{}{"txid": testHash(fmt.Sprintf("utxo-%d", i))[2:], "n": 0, "value": 10})
+		}
+		return map[string]interface{}{
+			"address": params[0],
+			"balance": []interface{}{
+				map[string]interface{}{
+					"unspent":      unspent,
+					"asset_hash":   "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+					"asset":        "NEO",
+					"asset_symbol": "NEO",
+					"amount":       50,
+				},
+			},
+		}
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.Config.UnspentCache = false
+	//单签、两个输出时最多3个输入
+	wm.Config.MaxTxSize = EstimateTxSize(3, 2, []WitnessSigner{{Required: 1, Total: 1}})
+	decoder := NewTransactionDecoder(wm)
+	wrapper := &testRebalanceWallet{}
+
+	newRawTx := func() *openwallet.RawTransaction {
+		return &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: Symbol},
+			Account: &openwallet.AssetsAccount{AccountID: "hot"},
+			To:      map[string]string{testColdAddress1: "45"},
+			FeeRate: "0",
+		}
+	}
+
+	err := decoder.CreateRawTransaction(wrapper, newRawTx())
+	if err == nil || openwallet.ConvertError(err).Code() != ErrTransactionTooLarge {
+		t.Errorf("CreateRawTransaction should fail with transaction too large, err: %v", err)
+	}
+
+	rawTxs, err := decoder.CreateSplitRawTransaction(wrapper, newRawTx())
+	if err != nil || len(rawTxs) != 2 {
+		t.Errorf("CreateSplitRawTransaction should split into 2 transactions, rawTxs: %v, unexpected error: %v", rawTxs, err)
+		return
+	}
+
+	single := WitnessSigner{Required: 1, Total: 1}
+	total := decimal.Zero
+	for _, rawTx := range rawTxs {
+		if size := len(rawTx.RawHex)/2 + 1 + single.Size(); size > wm.Config.MaxTxSize {
+			t.Errorf("split transaction size: %d exceeds limit: %d", size, wm.Config.MaxTxSize)
+		}
+		amount, _ := decimal.NewFromString(rawTx.To[testColdAddress1])
+		total = total.Add(amount)
+	}
+	if !total.Equal(decimal.New(45, 0)) {
+		t.Errorf("split transactions send: %s, expected: 45", total)
+	}
+}
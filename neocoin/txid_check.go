@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+//checkBroadcastTxID 核对广播交易的交易ID
+//先用两种独立实现计算本地交易ID，再向节点查询该交易，节点返回的交易ID及其序列化数据须与本地一致
+func (wm *WalletManager) checkBroadcastTxID(txHex, txid string) error {
+
+	if !wm.config().TxIDCheck {
+		return nil
+	}
+
+	localTxID, err := neoTransaction.CalcTxID(txHex)
+	if err != nil {
+		return err
+	}
+
+	if localTxID != txid {
+		return wm.errorf(ErrTxIDMismatch, "local txid mismatch, GetHash: %s, CalcTxID: %s", txid, localTxID)
+	}
+
+	tx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return wm.errorf(ErrTxIDMismatch, "node can not find broadcast transaction: %s, unexpected error: %v", txid, err)
+	}
+
+	if tx.TxID != txid {
+		return wm.errorf(ErrTxIDMismatch, "node txid: %s is not equal to local txid: %s", tx.TxID, txid)
+	}
+
+	return nil
+}
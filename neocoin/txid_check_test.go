@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"testing"
+)
+
+func TestWalletManager_CheckBroadcastTxID(t *testing.T) {
+	rawHex := "80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc500e1f50500000000205f46e5be17823bc84f060f545d55a56455f8790141407d27db1a9bbc6d7d156ad6d34b2499cdeba3515dcec7c38ad967bf164b0fe8e4948a828c140a7799317f0f1101022ea1ad9e4ccf2d731470be2413da72d6e05e232103df22a1f7263a5300ac68849696ab52ee79466de5c414e44fcc8ea43abd8dcb5fac"
+	txid, _ := GetTxId(rawHex)
+
+	nodeTxID := txid
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method == "getrawtransaction" {
+			return map[string]interface{}{"txid": nodeTxID, "vin": []interface{}{}, "vout": []interface{}{}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	if err := wm.checkBroadcastTxID(rawHex, txid); err != nil {
+		t.Errorf("checkBroadcastTxID failed unexpected error: %v\n", err)
+	}
+
+	//本地交易ID计算错误
+	if err := wm.checkBroadcastTxID(rawHex, "0x00"); err == nil {
+		t.Errorf("local txid mismatch should fail")
+	}
+
+	//节点交易ID不一致
+	nodeTxID = "0x00"
+	if err := wm.checkBroadcastTxID(rawHex, txid); err == nil {
+		t.Errorf("node txid mismatch should fail")
+	}
+
+	//关闭核对
+	wm.Config.TxIDCheck = false
+	if err := wm.checkBroadcastTxID(rawHex, txid); err != nil {
+		t.Errorf("disabled check should pass, got: %v", err)
+	}
+}
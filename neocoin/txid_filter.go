@@ -0,0 +1,232 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+const (
+	TxIDStatusConfirmed = "confirmed" //已打包进区块
+	TxIDStatusMempool   = "mempool"   //在节点内存池中，未打包
+	TxIDStatusUnknown   = "unknown"   //本地和节点都没有记录
+
+	TxIDSourceLocal = "local" //本地已保存的提取结果或交易索引
+	TxIDSourceNode  = "node"  //节点或浏览器
+
+	//txidFilterBatchSize 批量查询节点时每批交易单数量
+	txidFilterBatchSize = 100
+)
+
+//KnownTxID 交易单号的查询结果
+type KnownTxID struct {
+	TxID        string //调用者传入的交易单号
+	Status      string //见TxIDStatusConfirmed等
+	BlockHeight uint64 //已确认时所在区块高度，节点无法获取区块时为0
+	BlockHash   string
+	Source      string //结果来源，见TxIDSourceLocal等
+}
+
+//TxIDFilterResult 批量查询交易单号的分类结果，各列表按传入顺序排列
+type TxIDFilterResult struct {
+	Confirmed []*KnownTxID
+	Mempool   []*KnownTxID
+	Unknown   []string
+}
+
+//FilterKnownTxIDs 批量检查交易单是否已确认、在内存池或不存在，先查本地已保存的提取结果和交易索引，
+//其余的批量查询节点，重复的交易单号只返回一次，用于对账任务替代逐笔调用GetTransaction
+func (wm *WalletManager) FilterKnownTxIDs(txids []string) (*TxIDFilterResult, error) {
+
+	result := &TxIDFilterResult{
+		Confirmed: make([]*KnownTxID, 0),
+		Mempool:   make([]*KnownTxID, 0),
+		Unknown:   make([]string, 0),
+	}
+
+	ordered := make([]string, 0, len(txids))
+	known := make(map[string]*KnownTxID, len(txids))
+	for _, txid := range txids {
+		key := normalizeTxID(txid)
+		if len(key) == 0 {
+			continue
+		}
+		if _, ok := known[key]; ok {
+			continue
+		}
+		known[key] = nil
+		ordered = append(ordered, txid)
+	}
+	if len(ordered) == 0 {
+		return result, nil
+	}
+
+	if err := wm.filterLocalTxIDs(ordered, known); err != nil {
+		return nil, err
+	}
+
+	pending := make([]string, 0)
+	for _, txid := range ordered {
+		if known[normalizeTxID(txid)] == nil {
+			pending = append(pending, txid)
+		}
+	}
+	if err := wm.filterNodeTxIDs(pending, known); err != nil {
+		return nil, err
+	}
+
+	for _, txid := range ordered {
+		k := known[normalizeTxID(txid)]
+		switch {
+		case k == nil:
+			result.Unknown = append(result.Unknown, txid)
+		case k.Status == TxIDStatusConfirmed:
+			k.TxID = txid
+			result.Confirmed = append(result.Confirmed, k)
+		default:
+			k.TxID = txid
+			result.Mempool = append(result.Mempool, k)
+		}
+	}
+
+	return result, nil
+}
+
+//filterLocalTxIDs 查询本地已保存的区块交易提取结果，浏览器模式下再查询交易索引
+func (wm *WalletManager) filterLocalTxIDs(txids []string, known map[string]*KnownTxID) error {
+
+	//提取结果保存的交易单号格式以节点返回为准，同时按有无0x前缀查询
+	variants := make([]string, 0, len(txids)*2)
+	for _, txid := range txids {
+		key := normalizeTxID(txid)
+		variants = append(variants, key, "0x"+key)
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	var records []*ExtractDataRecord
+	err = db.Select(q.In("TxID", variants)).Find(&records)
+	db.Close()
+	if err != nil && err != storm.ErrNotFound {
+		return wm.errorf(ErrLocalDBOperateFailed, "get extract data failed, unexpected error: %v", err)
+	}
+
+	for _, r := range records {
+		//内存池交易的提取结果高度为0，由节点确认当前状态
+		if r.BlockHeight == 0 {
+			continue
+		}
+		k := &KnownTxID{Status: TxIDStatusConfirmed, BlockHeight: r.BlockHeight, Source: TxIDSourceLocal}
+		if r.Data != nil && r.Data.Transaction != nil {
+			k.BlockHash = r.Data.Transaction.BlockHash
+		}
+		known[normalizeTxID(r.TxID)] = k
+	}
+
+	if !wm.config().ExplorerMode {
+		return nil
+	}
+
+	for _, txid := range txids {
+		key := normalizeTxID(txid)
+		if known[key] != nil {
+			continue
+		}
+		tx, err := wm.TxIndex().GetTx(txid)
+		if err == nil && tx == nil {
+			tx, err = wm.TxIndex().GetTx("0x" + key)
+		}
+		if err != nil {
+			return wm.errorf(ErrLocalDBOperateFailed, "get indexed transaction failed, unexpected error: %v", err)
+		}
+		if tx != nil {
+			known[key] = &KnownTxID{Status: TxIDStatusConfirmed, BlockHeight: tx.BlockHeight, BlockHash: tx.BlockHash, Source: TxIDSourceLocal}
+		}
+	}
+
+	return nil
+}
+
+//filterNodeTxIDs 查询节点，支持批量调用时按txidFilterBatchSize分批，节点返回错误的交易单视为不存在
+func (wm *WalletManager) filterNodeTxIDs(txids []string, known map[string]*KnownTxID) error {
+
+	if len(txids) == 0 {
+		return nil
+	}
+
+	txs := make(map[string]*Transaction, len(txids))
+
+	client, ok := wm.nodeClient().(BatchClientInterface)
+	if ok && wm.config().RPCServerType == RPCServerCore {
+		for start := 0; start < len(txids); start += txidFilterBatchSize {
+			end := start + txidFilterBatchSize
+			if end > len(txids) {
+				end = len(txids)
+			}
+
+			requests := make([][]interface{}, 0, end-start)
+			for _, txid := range txids[start:end] {
+				requests = append(requests, []interface{}{txid, 1})
+			}
+
+			results, err := client.BatchCall("getrawtransaction", requests)
+			if err != nil {
+				return err
+			}
+			for i, r := range results {
+				if r != nil && r.IsObject() {
+					txs[txids[start+i]] = NewTransaction(r)
+				}
+			}
+		}
+	} else {
+		for _, txid := range txids {
+			tx, err := wm.GetTransaction(txid)
+			if err != nil {
+				wm.Log.Std.Debug("filter txid: %s, get transaction failed; unexpected error: %v", txid, err)
+				continue
+			}
+			txs[txid] = tx
+		}
+	}
+
+	//同一区块的交易只查询一次区块高度
+	heights := make(map[string]uint64)
+	for txid, tx := range txs {
+		k := &KnownTxID{Status: TxIDStatusMempool, Source: TxIDSourceNode}
+		if len(tx.BlockHash) > 0 {
+			k.Status = TxIDStatusConfirmed
+			k.BlockHash = tx.BlockHash
+			k.BlockHeight = tx.BlockHeight
+			if k.BlockHeight == 0 {
+				height, ok := heights[tx.BlockHash]
+				if !ok {
+					if block, err := wm.GetBlock(tx.BlockHash, BlockDetailHeader); err == nil {
+						height = block.Height
+					}
+					heights[tx.BlockHash] = height
+				}
+				k.BlockHeight = height
+			}
+		}
+		known[normalizeTxID(txid)] = k
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_FilterKnownTxIDs(t *testing.T) {
+	var (
+		localTxID   = testHash("local")
+		minedTxID   = testHash("mined")
+		poolTxID    = testHash("pool")
+		missingTxID = testHash("missing")
+		blockHash   = testHash("block20")
+		batches     = 0
+		singles     = 0
+	)
+	handle := func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getrawtransaction":
+			switch params[0] {
+			case minedTxID:
+				return map[string]interface{}{"txid": minedTxID, "blockhash": blockHash}
+			case poolTxID:
+				return map[string]interface{}{"txid": poolTxID}
+			}
+		case "getblockheader":
+			return map[string]interface{}{"index": 20, "hash": blockHash, "previousblockhash": testHash("block19"), "time": 1000}
+		}
+		return nil
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		var batch []map[string]interface{}
+		if json.Unmarshal(raw, &batch) == nil {
+			batches++
+			resp := make([]map[string]interface{}, 0)
+			for _, b := range batch {
+				result := handle(b["method"].(string), b["params"].([]interface{}))
+				if result == nil {
+					resp = append(resp, map[string]interface{}{"id": b["id"], "error": map[string]interface{}{"code": -100, "message": "Unknown transaction"}})
+					continue
+				}
+				resp = append(resp, map[string]interface{}{"id": b["id"], "result": result})
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		singles++
+		var body struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.Unmarshal(raw, &body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": "1", "result": handle(body.Method, body.Params)})
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	local := openwallet.NewBlockExtractData()
+	local.Transaction = &openwallet.Transaction{TxID: localTxID, BlockHash: testHash("block10"), BlockHeight: 10}
+	wm.SaveExtractData(10, map[string]*openwallet.TxExtractData{"account": local})
+
+	//本地记录不区分0x前缀和大小写，重复的只返回一次
+	txids := []string{missingTxID, strings.ToUpper(strings.TrimPrefix(localTxID, "0x")), poolTxID, minedTxID, localTxID, ""}
+	result, err := wm.FilterKnownTxIDs(txids)
+	if err != nil {
+		t.Fatalf("FilterKnownTxIDs failed, unexpected error: %v", err)
+	}
+	if len(result.Confirmed) != 2 || len(result.Mempool) != 1 || len(result.Unknown) != 1 {
+		t.Fatalf("unexpected result, confirmed: %d, mempool: %d, unknown: %v", len(result.Confirmed), len(result.Mempool), result.Unknown)
+	}
+	if c := result.Confirmed[0]; c.TxID != txids[1] || c.Source != TxIDSourceLocal || c.BlockHeight != 10 || c.BlockHash != testHash("block10") {
+		t.Errorf("local record should be confirmed from local store, got: %+v", c)
+	}
+	if c := result.Confirmed[1]; c.TxID != minedTxID || c.Source != TxIDSourceNode || c.BlockHeight != 20 || c.BlockHash != blockHash {
+		t.Errorf("mined transaction should be confirmed by node, got: %+v", c)
+	}
+	if m := result.Mempool[0]; m.TxID != poolTxID || m.Status != TxIDStatusMempool {
+		t.Errorf("unmined transaction should be in mempool, got: %+v", m)
+	}
+	if result.Unknown[0] != missingTxID {
+		t.Errorf("missing transaction should be unknown, got: %v", result.Unknown)
+	}
+
+	//节点交易单一次批量查询，区块高度单独查询一次
+	if batches != 1 || singles != 1 {
+		t.Errorf("node should be queried in one batch, batches: %d, singles: %d", batches, singles)
+	}
+
+	if result, err = wm.FilterKnownTxIDs(nil); err != nil || len(result.Unknown) != 0 {
+		t.Errorf("empty txids should return empty result, err: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+const (
+	UnscanDeleteRescanned  = "rescanned"    //重扫成功
+	UnscanDeleteTxNotFound = "tx_not_found" //节点找不到交易单
+	UnscanDeleteRollback   = "rollback"     //回滚到更低高度
+)
+
+//softDeleteUnscanRecords 标记未扫记录为已删除，已删除的记录保持原删除时间和原因
+func softDeleteUnscanRecords(node storm.Node, list []*UnscanRecord, reason string) error {
+	now := time.Now().Unix()
+	for _, r := range list {
+		if r.Deleted {
+			continue
+		}
+		r.Deleted = true
+		r.DeletedAt = now
+		r.DeleteReason = reason
+		err := node.Save(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//GetDeletedUnscanRecords 获取已软删除、尚未清理的未扫记录，用于排查提取事故
+func (wm *WalletManager) GetDeletedUnscanRecords() ([]*UnscanRecord, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*UnscanRecord
+	err = db.Select(q.Eq("Deleted", true)).OrderBy("BlockHeight").Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//RestoreUnscanRecord 恢复已软删除的未扫记录，恢复后重新参与重扫
+func (wm *WalletManager) RestoreUnscanRecord(id string) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var record UnscanRecord
+	err = db.One("ID", id, &record)
+	if err != nil {
+		return err
+	}
+
+	if !record.Deleted {
+		return nil
+	}
+
+	record.Deleted = false
+	record.DeletedAt = 0
+	record.DeleteReason = ""
+
+	return db.Save(&record)
+}
+
+//PurgeUnscanRecords 彻底删除软删除时间早于before的未扫记录，返回删除数量
+func (wm *WalletManager) PurgeUnscanRecords(before time.Time) (int, error) {
+	if err := wm.requireCapability(CapabilityDeleteLocal); err != nil {
+		return 0, err
+	}
+
+	return wm.purgeUnscanRecords(before)
+}
+
+func (wm *WalletManager) purgeUnscanRecords(before time.Time) (int, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var list []*UnscanRecord
+	err = db.Select(q.Eq("Deleted", true), q.Lt("DeletedAt", before.Unix())).Find(&list)
+	if err == storm.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, r := range list {
+		err = tx.DeleteStruct(r)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(list), tx.Commit()
+}
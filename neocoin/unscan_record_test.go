@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWalletManager_SoftDeleteUnscanRecord(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	record := NewUnscanRecord(100, "", "rpc timeout")
+	wm.Blockscanner.SaveUnscanRecord(record)
+	wm.Blockscanner.SaveUnscanRecord(NewUnscanRecord(200, "", "rpc timeout"))
+
+	err := wm.DeleteUnscanRecord(100)
+	if err != nil {
+		t.Errorf("DeleteUnscanRecord failed unexpected error: %v\n", err)
+		return
+	}
+
+	list, _ := wm.GetUnscanRecords()
+	if len(list) != 1 || list[0].BlockHeight != 200 {
+		t.Errorf("soft deleted unscan record should not be rescanned, got: %d", len(list))
+	}
+
+	deleted, _ := wm.GetDeletedUnscanRecords()
+	if len(deleted) != 1 || deleted[0].ID != record.ID || deleted[0].DeleteReason != UnscanDeleteRescanned || deleted[0].Reason != "rpc timeout" {
+		t.Errorf("soft deleted unscan record should keep its trail, got: %+v", deleted)
+		return
+	}
+
+	//回滚同样为软删除
+	err = wm.DeleteLocalDataAboveHeight(150)
+	if err != nil {
+		t.Errorf("DeleteLocalDataAboveHeight failed unexpected error: %v\n", err)
+		return
+	}
+	deleted, _ = wm.GetDeletedUnscanRecords()
+	if len(deleted) != 2 || deleted[1].DeleteReason != UnscanDeleteRollback {
+		t.Errorf("rolled back unscan record should be soft deleted, got: %d", len(deleted))
+	}
+
+	err = wm.RestoreUnscanRecord(record.ID)
+	if err != nil {
+		t.Errorf("RestoreUnscanRecord failed unexpected error: %v\n", err)
+		return
+	}
+	list, _ = wm.GetUnscanRecords()
+	if len(list) != 1 || list[0].ID != record.ID {
+		t.Errorf("restored unscan record should be rescanned again, got: %d", len(list))
+	}
+
+	//保留期内的记录不清理
+	count, err := wm.PurgeUnscanRecords(time.Now().Add(-time.Hour))
+	if err != nil || count != 0 {
+		t.Errorf("PurgeUnscanRecords should keep records within retention, count: %d, err: %v", count, err)
+	}
+	count, err = wm.PurgeUnscanRecords(time.Now().Add(time.Hour))
+	if err != nil || count != 1 {
+		t.Errorf("PurgeUnscanRecords should purge expired records, count: %d, err: %v", count, err)
+	}
+	deleted, _ = wm.GetDeletedUnscanRecords()
+	if len(deleted) != 0 {
+		t.Errorf("purged unscan records should be removed, got: %d", len(deleted))
+	}
+}
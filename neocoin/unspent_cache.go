@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+//unspentCacheEntry 一组地址的未花查询结果
+type unspentCacheEntry struct {
+	addresses map[string]bool
+	utxo      []*UnspentBalance
+}
+
+//unspentCache 按节点最新区块hash缓存未花查询结果，最新区块变化时全部失效
+type unspentCache struct {
+	mu      sync.Mutex
+	tip     string
+	entries map[string]*unspentCacheEntry
+}
+
+func newUnspentCache() *unspentCache {
+	return &unspentCache{
+		entries: make(map[string]*unspentCacheEntry),
+	}
+}
+
+//unspentCacheKey 最小确认数和排序后的地址集合作为缓存key
+func unspentCacheKey(min uint64, addresses []string) string {
+	sorted := make([]string, len(addresses))
+	copy(sorted, addresses)
+	sort.Strings(sorted)
+	return fmt.Sprintf("%d:%s", min, strings.Join(sorted, ","))
+}
+
+//get 查询缓存，tip与缓存的最新区块不同时清空缓存
+func (c *unspentCache) get(tip, key string) ([]*UnspentBalance, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tip != tip {
+		c.tip = tip
+		c.entries = make(map[string]*unspentCacheEntry)
+		return nil, false
+	}
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return copyUnspentBalances(entry.utxo), true
+}
+
+//set 保存查询结果，查询期间最新区块已变化则不保存
+func (c *unspentCache) set(tip, key string, addresses []string, utxo []*UnspentBalance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tip != tip {
+		return
+	}
+
+	entry := &unspentCacheEntry{
+		addresses: make(map[string]bool),
+	}
+	for _, a := range addresses {
+		entry.addresses[a] = true
+	}
+	entry.utxo = copyUnspentBalances(utxo)
+	c.entries[key] = entry
+}
+
+//copyUnspentBalances 复制未花查询结果，调用方修改返回结果不影响缓存
+func copyUnspentBalances(list []*UnspentBalance) []*UnspentBalance {
+	utxo := make([]*UnspentBalance, 0, len(list))
+	for _, balance := range list {
+		if balance == nil {
+			utxo = append(utxo, nil)
+			continue
+		}
+		b := *balance
+		b.NEOUnspent = copyUnspent(balance.NEOUnspent)
+		b.GASUnspent = copyUnspent(balance.GASUnspent)
+		utxo = append(utxo, &b)
+	}
+	return utxo
+}
+
+//copyUnspent 复制未花记录及其交易列表
+func copyUnspent(u *Unspent) *Unspent {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	if u.UnspentTxs != nil {
+		txs := make([]UnspentTx, len(*u.UnspentTxs))
+		copy(txs, *u.UnspentTxs)
+		c.UnspentTxs = &txs
+	}
+	return &c
+}
+
+//reset 清空缓存
+func (c *unspentCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tip = ""
+	c.entries = make(map[string]*unspentCacheEntry)
+}
+
+//invalidateTx 删除包含广播交易输出地址或花费了其输入的缓存结果
+func (c *unspentCache) invalidateTx(txHex string) {
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		c.reset()
+		return
+	}
+	trx, err := neoTransaction.DecodeRawTransaction(txBytes)
+	if err != nil {
+		c.reset()
+		return
+	}
+
+	spent := make(map[string]bool)
+	for _, in := range trx.Vins {
+		spent[fmt.Sprintf("%s:%d", strings.TrimPrefix(in.GetTxID(), "0x"), in.GetVout())] = true
+	}
+	receivers := make(map[string]bool)
+	for _, out := range trx.Vouts {
+		receivers[out.GetAddress()] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.touched(spent, receivers) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+//touched 缓存结果是否包含接收地址或已花费的输出
+func (e *unspentCacheEntry) touched(spent, receivers map[string]bool) bool {
+	for a := range receivers {
+		if e.addresses[a] {
+			return true
+		}
+	}
+	for _, balance := range e.utxo {
+		for _, u := range []*Unspent{balance.NEOUnspent, balance.GASUnspent} {
+			if u == nil || u.UnspentTxs == nil {
+				continue
+			}
+			for _, tx := range *u.UnspentTxs {
+				if spent[fmt.Sprintf("%s:%d", strings.TrimPrefix(tx.TxID, "0x"), tx.N)] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+func TestWalletManager_ListUnspentCache(t *testing.T) {
+	var (
+		queries int32
+		tip     atomic.Value
+	)
+	tip.Store(testHash("tip-1"))
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		switch method {
+		case "getbestblockhash":
+			return tip.Load()
+		case "sendrawtransaction":
+			return true
+		case "getunspents":
+			atomic.AddInt32(&queries, 1)
+			address := params[0].(string)
+			return map[string]interface{}{
+				"address": address,
+				"balance": []interface{}{
+					map[string]interface{}{
+						"unspent":      []interface{}{map[string]interface{}{"txid": testHash(address)[2:], "n": 0, "value": 10}},
+						"asset_hash":   "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b",
+						"asset":        "NEO",
+						"asset_symbol": "NEO",
+						"amount":       10,
+					},
+				},
+			}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.Config.UnspentCache = true
+
+	listUnspent := func(address string, wantQueries int32) {
+		utxo, err := wm.ListUnspent(0, address)
+		if err != nil || len(utxo) != 1 {
+			t.Errorf("ListUnspent failed, utxo: %v, unexpected error: %v", utxo, err)
+		}
+		if n := atomic.LoadInt32(&queries); n != wantQueries {
+			t.Errorf("getunspents queries: %d, want: %d", n, wantQueries)
+		}
+	}
+
+	listUnspent(testHotAddress, 1)
+	listUnspent(testHotAddress, 1)
+	listUnspent(testColdAddress1, 2)
+
+	//修改返回结果不影响缓存
+	utxo, _ := wm.ListUnspent(0, testHotAddress)
+	utxo[0].Address = "modified"
+	(*utxo[0].NEOUnspent.UnspentTxs)[0].Value = "0"
+	utxo, _ = wm.ListUnspent(0, testHotAddress)
+	if utxo[0].Address == "modified" || (*utxo[0].NEOUnspent.UnspentTxs)[0].Value == "0" {
+		t.Errorf("cached unspent should not be shared with callers: %+v", utxo[0])
+	}
+
+	//新区块后缓存失效
+	tip.Store(testHash("tip-2"))
+	listUnspent(testHotAddress, 3)
+	listUnspent(testColdAddress1, 4)
+
+	//广播花费热钱包未花的交易后，只有热钱包的缓存失效
+	txHex, err := neoTransaction.CreateEmptyRawTransaction(neoTransaction.ContractTransaction,
+		[]neoTransaction.Vin{{TxID: testHash(testHotAddress)[2:], Vout: 0}},
+		[]neoTransaction.Vout{{Asset: "c56f33fc6ecfcd0c225c4ab356fee59390af8560be0e930faebe74a6daff7c9b", Address: testColdAddress2, Value: 10}},
+		nil)
+	if err != nil {
+		t.Errorf("CreateEmptyRawTransaction failed unexpected error: %v", err)
+		return
+	}
+	if _, err := wm.SendRawTransaction(txHex); err != nil {
+		t.Errorf("SendRawTransaction failed unexpected error: %v", err)
+		return
+	}
+	listUnspent(testColdAddress1, 4)
+	listUnspent(testHotAddress, 5)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//UTXORecord 扫描记录的未花输出历史，包括创建和花费的区块高度，用于计算历史余额
+type UTXORecord struct {
+	ID           string `storm:"id"` //txid:n
+	Address      string `storm:"index"`
+	TxID         string
+	N            uint64
+	Asset        string //资产ID，不含0x前缀
+	Value        string
+	CreateHeight uint64 `storm:"index"`
+	SpentTxID    string
+	SpentHeight  uint64 `storm:"index"` //0表示未花费
+}
+
+func utxoRecordID(txid string, n uint64) string {
+	return fmt.Sprintf("%s:%d", strings.TrimPrefix(txid, "0x"), n)
+}
+
+//BalanceSnapshot 地址在指定区块高度的余额
+type BalanceSnapshot struct {
+	Address   string            `json:"address"`
+	Height    uint64            `json:"height"`
+	NEO       string            `json:"neo"`
+	GAS       string            `json:"gas"`
+	Assets    map[string]string `json:"assets"` //资产ID对应的余额
+	UTXOCount int               `json:"utxoCount"`
+}
+
+//saveUTXOHistory 记录区块交易创建和花费的未花输出，只记录扫描命中的地址，浏览器模式记录全部地址
+func (bs *NEOBlockScanner) saveUTXOHistory(trxs []*Transaction, scanAddressFunc openwallet.BlockScanAddressFunc) error {
+
+	if len(trxs) == 0 {
+		return nil
+	}
+
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, trx := range trxs {
+		if trx == nil || trx.BlockHeight == 0 {
+			continue
+		}
+
+		//先花费引用的输出，再创建新的输出
+		for _, vin := range trx.Vins {
+			if len(vin.Coinbase) > 0 || len(vin.TxID) == 0 {
+				continue
+			}
+			var r UTXORecord
+			err = tx.One("ID", utxoRecordID(vin.TxID, vin.Vout), &r)
+			if err == storm.ErrNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			r.SpentTxID = trx.TxID
+			r.SpentHeight = trx.BlockHeight
+			err = tx.Save(&r)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, vout := range trx.Vouts {
+			if len(vout.Addr) == 0 {
+				continue
+			}
+			if !bs.wm.config().ExplorerMode {
+				if _, ok := scanAddressFunc(vout.Addr); !ok {
+					continue
+				}
+			}
+			err = tx.Save(&UTXORecord{
+				ID:           utxoRecordID(trx.TxID, vout.N),
+				Address:      vout.Addr,
+				TxID:         trx.TxID,
+				N:            vout.N,
+				Asset:        strings.TrimPrefix(vout.Asset, "0x"),
+				Value:        vout.Value,
+				CreateHeight: trx.BlockHeight,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+//DeleteUTXOHistoryAboveHeight 回滚高于指定高度的未花输出历史，用于分叉
+func (wm *WalletManager) DeleteUTXOHistoryAboveHeight(height uint64) error {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var created []*UTXORecord
+	err = db.Select(q.Gt("CreateHeight", height)).Find(&created)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	var spent []*UTXORecord
+	err = db.Select(q.Gt("SpentHeight", height), q.Lte("CreateHeight", height)).Find(&spent)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range created {
+		err = tx.DeleteStruct(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range spent {
+		r.SpentTxID = ""
+		r.SpentHeight = 0
+		err = tx.Save(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetBalanceAtHeight 按本地未花输出历史计算地址在指定区块高度的余额，用于日终余额报表和审计快照，
+//只包含开启utxoHistory后扫描到的交易，高度不能超过已扫描高度
+func (wm *WalletManager) GetBalanceAtHeight(address string, height uint64) (*BalanceSnapshot, error) {
+
+	scanned, _ := wm.GetLocalNewBlock()
+	if height > scanned {
+		return nil, wm.errorf(ErrBlockHeightInvalid, "block height: %d is above scanned height: %d", height, scanned)
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "open local db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	var list []*UTXORecord
+	err = db.Select(q.Eq("Address", address), q.Lte("CreateHeight", height)).Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get utxo history failed, unexpected error: %v", err)
+	}
+
+	balances := make(map[string]decimal.Decimal)
+	snapshot := &BalanceSnapshot{
+		Address: address,
+		Height:  height,
+		Assets:  make(map[string]string),
+	}
+	for _, r := range list {
+		if r.SpentHeight > 0 && r.SpentHeight <= height {
+			continue
+		}
+		value, _ := decimal.NewFromString(r.Value)
+		balances[r.Asset] = balances[r.Asset].Add(value)
+		snapshot.UTXOCount++
+	}
+
+	for asset, balance := range balances {
+		snapshot.Assets[asset] = balance.String()
+	}
+	snapshot.NEO = balances[wm.config().NEOAssetID].String()
+	snapshot.GAS = balances[wm.config().GASAssetID].String()
+
+	return snapshot, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+func TestWalletManager_GetBalanceAtHeight(t *testing.T) {
+	var (
+		fundTxID  = testHash("fund")
+		spendTxID = testHash("spend")
+		sender    = "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+		receiver  = "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	)
+	server := newTestRPCServer(func(method string, params []interface{}) interface{} {
+		if method != "getrawtransaction" {
+			return nil
+		}
+		switch params[0] {
+		case fundTxID:
+			return map[string]interface{}{"txid": fundTxID, "vin": []interface{}{},
+				"vout": []interface{}{
+					map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "10", "address": sender},
+					map[string]interface{}{"n": 1, "asset": "0x" + neoTransaction.NeoGasAssetId, "value": "1.5", "address": sender},
+				}}
+		case spendTxID:
+			return map[string]interface{}{"txid": spendTxID, "vin": []interface{}{map[string]interface{}{"txid": fundTxID, "vout": 0}},
+				"vout": []interface{}{
+					map[string]interface{}{"n": 0, "asset": "0x" + neoTransaction.NeoAssetId, "value": "4", "address": receiver},
+					map[string]interface{}{"n": 1, "asset": "0x" + neoTransaction.NeoAssetId, "value": "6", "address": sender},
+				}}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	wm.Config.UTXOHistory = true
+	wm.WalletClient = NewClient(server.URL, "", false)
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	bs := wm.Blockscanner
+	bs.ScanAddressFunc = func(address string) (string, bool) {
+		return "account", address == sender
+	}
+
+	if err := bs.BatchExtractTransaction(10, testHash("block10"), []string{fundTxID}); err != nil {
+		t.Errorf("BatchExtractTransaction failed unexpected error: %v\n", err)
+		return
+	}
+	if err := bs.BatchExtractTransaction(11, testHash("block11"), []string{spendTxID}); err != nil {
+		t.Errorf("BatchExtractTransaction failed unexpected error: %v\n", err)
+		return
+	}
+	wm.SaveLocalNewBlock(11, testHash("block11"))
+
+	snapshot, err := wm.GetBalanceAtHeight(sender, 10)
+	if err != nil || snapshot.NEO != "10" || snapshot.GAS != "1.5" || snapshot.UTXOCount != 2 {
+		t.Errorf("unexpected balance at height 10: %+v, err: %v", snapshot, err)
+	}
+	snapshot, _ = wm.GetBalanceAtHeight(sender, 11)
+	if snapshot.NEO != "6" || snapshot.GAS != "1.5" {
+		t.Errorf("unexpected balance at height 11: %+v", snapshot)
+	}
+	snapshot, _ = wm.GetBalanceAtHeight(receiver, 11)
+	if snapshot.NEO != "0" || snapshot.UTXOCount != 0 {
+		t.Errorf("unwatched address should not be recorded: %+v", snapshot)
+	}
+	if _, err := wm.GetBalanceAtHeight(sender, 12); err == nil {
+		t.Errorf("height above scanned height should be rejected")
+	}
+
+	//分叉回滚后花费的输出恢复未花
+	if err := wm.DeleteUTXOHistoryAboveHeight(10); err != nil {
+		t.Errorf("DeleteUTXOHistoryAboveHeight failed unexpected error: %v\n", err)
+	}
+	snapshot, _ = wm.GetBalanceAtHeight(sender, 11)
+	if snapshot.NEO != "10" || snapshot.UTXOCount != 2 {
+		t.Errorf("unexpected balance after rollback: %+v", snapshot)
+	}
+}
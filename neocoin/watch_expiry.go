@@ -0,0 +1,262 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+//watchAddressTTL 有有效期的观测地址，记录期间的匹配统计
+type watchAddressTTL struct {
+	AddedAt     int64  //加入观测时间
+	ExpireAt    int64  //过期时间
+	Matches     uint64 //提取时匹配次数
+	LastMatchAt int64  //最近一次匹配时间
+}
+
+//expired 是否已过期
+func (t *watchAddressTTL) expired(now int64) bool {
+	return t.ExpireAt > 0 && now >= t.ExpireAt
+}
+
+//ArchivedWatchAddress 过期后归档的观测地址及其观测期间的统计
+type ArchivedWatchAddress struct {
+	Address     string `storm:"id"`
+	Account     string `storm:"index"`
+	AddedAt     int64  //加入观测时间
+	ExpireAt    int64  //过期时间
+	ArchivedAt  int64  //归档时间
+	Matches     uint64 //提取时匹配次数
+	LastMatchAt int64  //最近一次匹配时间
+}
+
+//AddWatchAddressWithTTL 添加有有效期的观测地址，过期后提取时不再匹配，并由归档任务移出观测地址集合，
+//适用于一次性收款地址。与ImportWatchAddresses相同会开启观测地址预过滤，
+//account不为空时地址直接归属该账户，ttl不大于0表示不过期
+func (bs *NEOBlockScanner) AddWatchAddressWithTTL(address, account string, ttl time.Duration) {
+	bs.addWatchAddressWithTTL(address, account, ttl, 0)
+}
+
+func (bs *NEOBlockScanner) addWatchAddressWithTTL(address, account string, ttl time.Duration, matches uint64) {
+
+	now := time.Now()
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	if bs.watchAddresses == nil {
+		bs.watchAddresses = make(map[string]string)
+	}
+	bs.watchAddresses[address] = account
+
+	if ttl > 0 {
+		if bs.watchTTL == nil {
+			bs.watchTTL = make(map[string]*watchAddressTTL)
+		}
+		bs.watchTTL[address] = &watchAddressTTL{
+			AddedAt:  now.Unix(),
+			ExpireAt: now.Add(ttl).Unix(),
+			Matches:  matches,
+		}
+	} else {
+		delete(bs.watchTTL, address)
+	}
+
+	if bs.addressFilter == nil {
+		bs.rebuildAddressFilter()
+	} else {
+		bs.addressFilter.Add([]byte(address))
+	}
+}
+
+//WatchAddressExpireAt 观测地址的过期时间，没有有效期时返回false
+func (bs *NEOBlockScanner) WatchAddressExpireAt(address string) (time.Time, bool) {
+
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+
+	ttl, ok := bs.watchTTL[address]
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(ttl.ExpireAt, 0), true
+}
+
+//matchWatchAddressTTL 提取时检查观测地址有效期并记录匹配，已过期返回false
+func (bs *NEOBlockScanner) matchWatchAddressTTL(address string) bool {
+
+	bs.Mu.RLock()
+	_, limited := bs.watchTTL[address]
+	bs.Mu.RUnlock()
+	if !limited {
+		return true
+	}
+
+	now := time.Now().Unix()
+
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+
+	ttl, ok := bs.watchTTL[address]
+	if !ok {
+		return true
+	}
+	if ttl.expired(now) {
+		return false
+	}
+	ttl.Matches++
+	ttl.LastMatchAt = now
+	return true
+}
+
+//ArchiveExpiredWatchAddresses 把已过期的观测地址连同统计保存到归档记录，并移出观测地址集合和布隆过滤器，
+//返回归档的地址数量
+func (bs *NEOBlockScanner) ArchiveExpiredWatchAddresses() (int, error) {
+
+	now := time.Now().Unix()
+
+	bs.Mu.RLock()
+	archived := make([]*ArchivedWatchAddress, 0)
+	for address, ttl := range bs.watchTTL {
+		if !ttl.expired(now) {
+			continue
+		}
+		archived = append(archived, &ArchivedWatchAddress{
+			Address:     address,
+			Account:     bs.watchAddresses[address],
+			AddedAt:     ttl.AddedAt,
+			ExpireAt:    ttl.ExpireAt,
+			ArchivedAt:  now,
+			Matches:     ttl.Matches,
+			LastMatchAt: ttl.LastMatchAt,
+		})
+	}
+	bs.Mu.RUnlock()
+
+	if len(archived) == 0 {
+		return 0, nil
+	}
+
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, bs.wm.errorf(ErrLocalDBOperateFailed, "save archived watch addresses failed, unexpected error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, a := range archived {
+		if err = tx.Save(a); err != nil {
+			return 0, bs.wm.errorf(ErrLocalDBOperateFailed, "save archived watch addresses failed, unexpected error: %v", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, bs.wm.errorf(ErrLocalDBOperateFailed, "save archived watch addresses failed, unexpected error: %v", err)
+	}
+
+	bs.Mu.Lock()
+	for _, a := range archived {
+		//归档期间重新激活的地址保留在观测地址集合中
+		if ttl, ok := bs.watchTTL[a.Address]; !ok || ttl.ExpireAt != a.ExpireAt {
+			continue
+		}
+		delete(bs.watchTTL, a.Address)
+		delete(bs.watchAddresses, a.Address)
+	}
+	bs.rebuildAddressFilter()
+	bs.Mu.Unlock()
+
+	bs.wm.Log.Std.Info("block scanner archived %d expired watch addresses", len(archived))
+
+	return len(archived), nil
+}
+
+//GetArchivedWatchAddress 获取归档的观测地址，未归档时返回nil
+func (wm *WalletManager) GetArchivedWatchAddress(address string) (*ArchivedWatchAddress, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var a ArchivedWatchAddress
+	err = db.One("Address", address, &a)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get archived watch addresses failed, unexpected error: %v", err)
+	}
+	return &a, nil
+}
+
+//GetArchivedWatchAddresses 获取账户归档的观测地址，account为空时返回全部
+func (wm *WalletManager) GetArchivedWatchAddresses(account string) ([]*ArchivedWatchAddress, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ArchivedWatchAddress
+	if len(account) > 0 {
+		err = db.Find("Account", account, &list)
+	} else {
+		err = db.All(&list)
+	}
+	if err == storm.ErrNotFound {
+		return []*ArchivedWatchAddress{}, nil
+	} else if err != nil {
+		return nil, wm.errorf(ErrLocalDBOperateFailed, "get archived watch addresses failed, unexpected error: %v", err)
+	}
+	return list, nil
+}
+
+//ReactivateWatchAddress 重新激活归档的观测地址并删除归档记录，恢复账户标记，
+//ttl大于0时继续累计匹配统计，不大于0表示不过期
+func (bs *NEOBlockScanner) ReactivateWatchAddress(address string, ttl time.Duration) error {
+
+	archived, err := bs.wm.GetArchivedWatchAddress(address)
+	if err != nil {
+		return err
+	}
+	if archived == nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "watch address: %s is not archived", address)
+	}
+
+	db, err := bs.wm.openLocalDB(bs.wm.config().BlockchainFile)
+	if err != nil {
+		return err
+	}
+	err = db.DeleteStruct(archived)
+	db.Close()
+	if err != nil {
+		return bs.wm.errorf(ErrLocalDBOperateFailed, "delete archived watch address failed, unexpected error: %v", err)
+	}
+
+	bs.addWatchAddressWithTTL(address, archived.Account, ttl, archived.Matches)
+
+	bs.wm.Log.Std.Info("block scanner reactivated watch address: %s", address)
+
+	return nil
+}
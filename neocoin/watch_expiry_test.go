@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNEOBlockScanner_WatchAddressExpiry(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	bs := wm.Blockscanner
+
+	oneTime := "AGVziqTEhJJTQckrUuTQcyHNGV4ksKPPUT"
+	permanent := "AGofsxAUDwt52KjaB664GYsqVAkULYvKNt"
+	bs.AddWatchAddressWithTTL(oneTime, "acc1", time.Hour)
+	bs.AddWatchAddressWithTTL(permanent, "acc2", 0)
+
+	if _, ok := bs.WatchAddressExpireAt(permanent); ok {
+		t.Errorf("permanent watch address should not expire")
+	}
+
+	scanAddressFunc := bs.filterScanAddressFunc(nil)
+	if account, ok := scanAddressFunc(oneTime); !ok || account != "acc1" {
+		t.Errorf("watch address should match before expiry")
+	}
+
+	//过期后不再匹配
+	bs.watchTTL[oneTime].ExpireAt = time.Now().Unix() - 1
+	if _, ok := scanAddressFunc(oneTime); ok {
+		t.Errorf("expired watch address should not match")
+	}
+
+	count, err := bs.ArchiveExpiredWatchAddresses()
+	if err != nil || count != 1 {
+		t.Errorf("ArchiveExpiredWatchAddresses count: %d, unexpected error: %v", count, err)
+		return
+	}
+	if _, ok := bs.watchAddresses[oneTime]; ok {
+		t.Errorf("archived address should be removed from watch addresses")
+	}
+	if account, ok := bs.filterScanAddressFunc(nil)(permanent); !ok || account != "acc2" {
+		t.Errorf("permanent watch address should still match")
+	}
+
+	archived, err := wm.GetArchivedWatchAddress(oneTime)
+	if err != nil || archived == nil || archived.Account != "acc1" || archived.Matches != 1 || archived.LastMatchAt == 0 {
+		t.Errorf("archived watch address: %+v, unexpected error: %v", archived, err)
+		return
+	}
+	list, _ := wm.GetArchivedWatchAddresses("acc1")
+	if len(list) != 1 {
+		t.Errorf("archived watch addresses of account: %d", len(list))
+	}
+
+	//重新激活后恢复匹配和统计
+	if err := bs.ReactivateWatchAddress(oneTime, time.Hour); err != nil {
+		t.Errorf("ReactivateWatchAddress failed unexpected error: %v\n", err)
+		return
+	}
+	if account, ok := bs.filterScanAddressFunc(nil)(oneTime); !ok || account != "acc1" {
+		t.Errorf("reactivated watch address should match")
+	}
+	if bs.watchTTL[oneTime].Matches != 2 {
+		t.Errorf("matches should continue from archive: %d", bs.watchTTL[oneTime].Matches)
+	}
+	if archived, _ := wm.GetArchivedWatchAddress(oneTime); archived != nil {
+		t.Errorf("archive record should be deleted after reactivation")
+	}
+
+	if err := bs.ReactivateWatchAddress(permanent, 0); err == nil {
+		t.Errorf("reactivating not archived address should fail")
+	}
+}
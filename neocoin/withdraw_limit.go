@@ -0,0 +1,257 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//WithdrawLimit 提币限额，为0表示不限制
+type WithdrawLimit struct {
+	MaxPerTx   decimal.Decimal //单笔最大金额
+	MaxPerHour decimal.Decimal //每小时最大金额
+	MaxPerDay  decimal.Decimal //每天最大金额
+}
+
+//WithdrawRecord 提币限额计数记录
+type WithdrawRecord struct {
+	ID        string `storm:"id"`
+	AccountID string `storm:"index"`
+	Asset     string
+	Amount    string
+	CreateAt  int64 `storm:"index"`
+}
+
+//withdrawRetention 提币计数记录保留时长，超过最大统计窗口的记录不再参与限额计算
+const withdrawRetention = 24 * time.Hour
+
+//parseWithdrawAssetLimit 解析配置的资产提币限额，格式为资产:单笔:每小时:每天
+func parseWithdrawAssetLimit(value string) (string, WithdrawLimit, error) {
+	var limit WithdrawLimit
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 4 || len(strings.TrimSpace(parts[0])) == 0 {
+		return "", limit, fmt.Errorf("withdraw asset limit: %s format is invalid", value)
+	}
+	amounts := make([]decimal.Decimal, 0, 3)
+	for _, part := range parts[1:] {
+		d, err := decimal.NewFromString(strings.TrimSpace(part))
+		if err != nil || d.IsNegative() {
+			return "", limit, fmt.Errorf("withdraw asset limit: %s amount is invalid", value)
+		}
+		amounts = append(amounts, d)
+	}
+	limit.MaxPerTx, limit.MaxPerHour, limit.MaxPerDay = amounts[0], amounts[1], amounts[2]
+	return withdrawLimitAsset(strings.TrimSpace(parts[0])), limit, nil
+}
+
+//withdrawLimitAsset 资产标识，合约地址统一格式
+func withdrawLimitAsset(asset string) string {
+	if strings.HasPrefix(strings.ToLower(asset), "0x") {
+		return normalizeContractHash(asset)
+	}
+	return asset
+}
+
+//withdrawLimitKey 账户限额的key，asset为空表示账户的所有资产
+func withdrawLimitKey(accountID, asset string) string {
+	if len(asset) == 0 {
+		return accountID
+	}
+	return accountID + "/" + withdrawLimitAsset(asset)
+}
+
+//SetAccountWithdrawLimit 设置账户的提币限额，覆盖配置的默认限额，不区分资产
+func (wm *WalletManager) SetAccountWithdrawLimit(accountID string, limit *WithdrawLimit) {
+	wm.SetAccountAssetWithdrawLimit(accountID, "", limit)
+}
+
+//SetAccountAssetWithdrawLimit 设置账户某资产的提币限额，asset为币种符号或代币合约地址
+func (wm *WalletManager) SetAccountAssetWithdrawLimit(accountID, asset string, limit *WithdrawLimit) {
+	wm.withdrawMu.Lock()
+	defer wm.withdrawMu.Unlock()
+	if wm.withdrawLimits == nil {
+		wm.withdrawLimits = make(map[string]*WithdrawLimit)
+	}
+	key := withdrawLimitKey(accountID, asset)
+	if limit == nil {
+		delete(wm.withdrawLimits, key)
+		return
+	}
+	wm.withdrawLimits[key] = limit
+}
+
+//getWithdrawLimit 获取账户某资产的提币限额，
+//优先顺序：账户资产限额、账户限额、配置的资产限额、配置的默认限额
+func (wm *WalletManager) getWithdrawLimit(cfg *WalletConfig, accountID, asset string) *WithdrawLimit {
+	if limit, ok := wm.withdrawLimits[withdrawLimitKey(accountID, asset)]; ok {
+		return limit
+	}
+	if limit, ok := wm.withdrawLimits[accountID]; ok {
+		return limit
+	}
+	if limit, ok := cfg.WithdrawAssetLimits[withdrawLimitAsset(asset)]; ok {
+		return &limit
+	}
+	return &WithdrawLimit{
+		MaxPerTx:   cfg.WithdrawMaxPerTx,
+		MaxPerHour: cfg.WithdrawMaxPerHour,
+		MaxPerDay:  cfg.WithdrawMaxPerDay,
+	}
+}
+
+//withdrawAsset 交易单的资产标识，代币使用合约地址
+func withdrawAsset(rawTx *openwallet.RawTransaction) string {
+	if rawTx.Coin.IsContract {
+		return withdrawLimitAsset(rawTx.Coin.Contract.Address)
+	}
+	return rawTx.Coin.Symbol
+}
+
+//GetWithdrawAmount 统计账户某资产从since开始已创建的提币金额
+func (wm *WalletManager) GetWithdrawAmount(accountID, asset string, since int64) (decimal.Decimal, error) {
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer db.Close()
+
+	return sumWithdrawAmount(db, accountID, asset, since)
+}
+
+func sumWithdrawAmount(db storm.Node, accountID, asset string, since int64) (decimal.Decimal, error) {
+
+	var list []*WithdrawRecord
+	err := db.Select(q.Eq("AccountID", accountID), q.Eq("Asset", withdrawLimitAsset(asset)), q.Gte("CreateAt", since)).Find(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return decimal.Zero, err
+	}
+
+	total := decimal.Zero
+	for _, r := range list {
+		amount, _ := decimal.NewFromString(r.Amount)
+		total = total.Add(amount)
+	}
+	return total, nil
+}
+
+//reserveWithdraw 检查提币限额并占用额度，返回计数记录id，创建交易单失败时需释放
+func (wm *WalletManager) reserveWithdraw(rawTx *openwallet.RawTransaction) (string, error) {
+
+	cfg := wm.config()
+	accountID := rawTx.Account.AccountID
+	asset := withdrawAsset(rawTx)
+
+	//负数金额会抵减统计总额，绕过限额
+	amount := decimal.Zero
+	for _, v := range rawTx.To {
+		d, err := decimal.NewFromString(v)
+		if err != nil || !d.IsPositive() {
+			return "", fmt.Errorf("amount: %s is invalid", v)
+		}
+		amount = amount.Add(d)
+	}
+
+	wm.withdrawMu.Lock()
+	defer wm.withdrawMu.Unlock()
+
+	limit := wm.getWithdrawLimit(cfg, accountID, asset)
+	if limit.MaxPerTx.IsZero() && limit.MaxPerHour.IsZero() && limit.MaxPerDay.IsZero() {
+		return "", nil
+	}
+
+	if limit.MaxPerTx.IsPositive() && amount.GreaterThan(limit.MaxPerTx) {
+		return "", wm.errorf(ErrWithdrawLimitExceeded, "account: %s withdraw amount: %s exceeds per tx limit: %s", accountID, amount, limit.MaxPerTx)
+	}
+
+	db, err := wm.openLocalDB(cfg.BlockchainFile)
+	if err != nil {
+		return "", wm.errorf(ErrLocalDBOperateFailed, "open local db failed, unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+
+	//清理超过统计窗口的记录，清理失败不影响限额检查
+	err = db.Select(q.Lt("CreateAt", now.Add(-withdrawRetention).Unix())).Delete(&WithdrawRecord{})
+	if err != nil && err != storm.ErrNotFound {
+		wm.Log.Std.Warning("prune withdraw records failed, unexpected error: %v", err)
+	}
+	windows := []struct {
+		name  string
+		limit decimal.Decimal
+		since time.Time
+	}{
+		{"hour", limit.MaxPerHour, now.Add(-time.Hour)},
+		{"day", limit.MaxPerDay, now.Add(-withdrawRetention)},
+	}
+	for _, w := range windows {
+		if !w.limit.IsPositive() {
+			continue
+		}
+		used, err := sumWithdrawAmount(db, accountID, asset, w.since.Unix())
+		if err != nil {
+			return "", wm.errorf(ErrLocalDBOperateFailed, "get withdraw records failed, unexpected error: %v", err)
+		}
+		if used.Add(amount).GreaterThan(w.limit) {
+			return "", wm.errorf(ErrWithdrawLimitExceeded, "account: %s withdraw amount: %s exceeds per %s limit: %s, used: %s", accountID, amount, w.name, w.limit, used)
+		}
+	}
+
+	record := &WithdrawRecord{
+		AccountID: accountID,
+		Asset:     asset,
+		Amount:    amount.String(),
+		CreateAt:  now.Unix(),
+	}
+	record.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%s_%s_%s_%d", accountID, asset, record.Amount, now.UnixNano()))))
+
+	err = db.Save(record)
+	if err != nil {
+		return "", wm.errorf(ErrLocalDBOperateFailed, "save withdraw record failed, unexpected error: %v", err)
+	}
+
+	return record.ID, nil
+}
+
+//releaseWithdraw 释放占用的提币额度
+func (wm *WalletManager) releaseWithdraw(id string) {
+
+	if len(id) == 0 {
+		return
+	}
+
+	db, err := wm.openLocalDB(wm.config().BlockchainFile)
+	if err != nil {
+		wm.Log.Std.Error("release withdraw record: %s failed; unexpected error: %v", id, err)
+		return
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&WithdrawRecord{ID: id})
+	if err != nil {
+		wm.Log.Std.Error("release withdraw record: %s failed; unexpected error: %v", id, err)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestWalletManager_ReserveWithdraw(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	wm.Config.WithdrawMaxPerTx = decimal.New(10, 0)
+	wm.Config.WithdrawMaxPerHour = decimal.New(15, 0)
+
+	newRawTx := func(amount string) *openwallet.RawTransaction {
+		return &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "NEO"},
+			Account: &openwallet.AssetsAccount{AccountID: "account"},
+			To:      map[string]string{"ATo": amount},
+		}
+	}
+
+	if _, err := wm.reserveWithdraw(newRawTx("11")); err == nil {
+		t.Errorf("withdraw should exceed per tx limit")
+	}
+
+	id, err := wm.reserveWithdraw(newRawTx("10"))
+	if err != nil {
+		t.Errorf("reserveWithdraw failed unexpected error: %v\n", err)
+		return
+	}
+
+	if _, err := wm.reserveWithdraw(newRawTx("6")); err == nil {
+		t.Errorf("withdraw should exceed per hour limit")
+	}
+
+	//释放后额度恢复
+	wm.releaseWithdraw(id)
+	if _, err := wm.reserveWithdraw(newRawTx("6")); err != nil {
+		t.Errorf("withdraw should be permitted after release, err: %v", err)
+	}
+
+	used, _ := wm.GetWithdrawAmount("account", "NEO", time.Now().Add(-time.Hour).Unix())
+	if !used.Equal(decimal.New(6, 0)) {
+		t.Errorf("used amount: %s, expected: 6", used)
+	}
+
+	//账户单独设置的限额优先
+	wm.SetAccountWithdrawLimit("account", &WithdrawLimit{MaxPerTx: decimal.New(100, 0)})
+	if _, err := wm.reserveWithdraw(newRawTx("50")); err != nil {
+		t.Errorf("withdraw should be permitted by account limit, err: %v", err)
+	}
+}
+
+func TestWalletManager_ReserveWithdrawAssetLimit(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	wm.Config.WithdrawMaxPerTx = decimal.New(10, 0)
+	asset, limit, err := parseWithdrawAssetLimit("GAS:1000:0:0")
+	if err != nil || asset != "GAS" || !limit.MaxPerTx.Equal(decimal.New(1000, 0)) {
+		t.Errorf("parseWithdrawAssetLimit: %s, %+v, unexpected error: %v", asset, limit, err)
+	}
+	wm.Config.WithdrawAssetLimits[asset] = limit
+	if _, _, err := parseWithdrawAssetLimit("GAS:-1:0:0"); err == nil {
+		t.Errorf("negative limit should be rejected")
+	}
+
+	newRawTx := func(symbol string, to map[string]string) *openwallet.RawTransaction {
+		return &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: symbol},
+			Account: &openwallet.AssetsAccount{AccountID: "account"},
+			To:      to,
+		}
+	}
+
+	//负数或零金额不能抵减统计总额
+	if _, err := wm.reserveWithdraw(newRawTx("NEO", map[string]string{"ATo1": "20", "ATo2": "-15"})); err == nil {
+		t.Errorf("negative amount should be rejected")
+	}
+	if _, err := wm.reserveWithdraw(newRawTx("NEO", map[string]string{"ATo1": "0"})); err == nil {
+		t.Errorf("zero amount should be rejected")
+	}
+
+	//资产限额优先于默认限额
+	if _, err := wm.reserveWithdraw(newRawTx("GAS", map[string]string{"ATo": "500"})); err != nil {
+		t.Errorf("GAS withdraw should be permitted by asset limit, err: %v", err)
+	}
+	if _, err := wm.reserveWithdraw(newRawTx("NEO", map[string]string{"ATo": "500"})); err == nil {
+		t.Errorf("NEO withdraw should exceed default per tx limit")
+	}
+	wm.SetAccountAssetWithdrawLimit("account", "NEO", &WithdrawLimit{MaxPerTx: decimal.New(500, 0)})
+	if _, err := wm.reserveWithdraw(newRawTx("NEO", map[string]string{"ATo": "500"})); err != nil {
+		t.Errorf("NEO withdraw should be permitted by account asset limit, err: %v", err)
+	}
+}
+
+func TestWalletManager_ReserveWithdrawPrune(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+	wm.Config.WithdrawMaxPerDay = decimal.New(100, 0)
+
+	db, err := wm.openLocalDB(wm.Config.BlockchainFile)
+	if err != nil {
+		t.Fatalf("open local db failed, unexpected error: %v", err)
+	}
+	db.Save(&WithdrawRecord{ID: "old", AccountID: "account", Asset: "NEO", Amount: "50", CreateAt: time.Now().Add(-48 * time.Hour).Unix()})
+	db.Close()
+
+	_, err = wm.reserveWithdraw(&openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "NEO"},
+		Account: &openwallet.AssetsAccount{AccountID: "account"},
+		To:      map[string]string{"ATo": "1"},
+	})
+	if err != nil {
+		t.Errorf("reserveWithdraw failed unexpected error: %v", err)
+	}
+
+	//超过统计窗口的记录已清理
+	used, _ := wm.GetWithdrawAmount("account", "NEO", 0)
+	if !used.Equal(decimal.New(1, 0)) {
+		t.Errorf("used amount: %s, expected: 1", used)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/Assetsadapter/neo-adapter/neoTransaction"
+)
+
+const (
+	AlertTypeInvalidWitness = "invalid_witness" //分叉后重扫的区块中有交易见证签名验证失败
+)
+
+//encodeWitnesses 按交易序列化格式编码见证脚本
+func encodeWitnesses(scripts []*Witness) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Write(encodeVarInt(len(scripts)))
+	for _, w := range scripts {
+		for _, s := range []string{w.Invocation, w.Verification} {
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid witness script: %v", err)
+			}
+			buf.Write(encodeVarInt(len(b)))
+			buf.Write(b)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+//encodeVarInt 变长整数编码
+func encodeVarInt(n int) []byte {
+	switch varIntSize(n) {
+	case 1:
+		return []byte{byte(n)}
+	case 3:
+		return []byte{0xfd, byte(n), byte(n >> 8)}
+	default:
+		return []byte{0xfe, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}
+	}
+}
+
+//VerifyTransactionWitnesses 验证交易的单签和多签见证签名，
+//见证位于原始交易末尾，去掉后即为签名数据，与交易类型的专有数据无关。
+//合约验证脚本无法在本地执行，不验证
+func VerifyTransactionWitnesses(rawHex string, scripts []*Witness) error {
+
+	raw, err := hex.DecodeString(strings.TrimPrefix(rawHex, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid raw transaction: %v", err)
+	}
+
+	witnesses, err := encodeWitnesses(scripts)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) <= len(witnesses) || !bytes.HasSuffix(raw, witnesses) {
+		return fmt.Errorf("witnesses mismatch raw transaction")
+	}
+
+	message := neoTransaction.GetCryptoProvider().SHA256(raw[:len(raw)-len(witnesses)])
+
+	for i, w := range scripts {
+		verification, _ := hex.DecodeString(w.Verification)
+		m, pubkeys, err := parseVerificationScript(verification)
+		if err != nil {
+			continue
+		}
+		invocation, _ := hex.DecodeString(w.Invocation)
+		signatures, err := parseInvocationScript(invocation)
+		if err != nil {
+			return fmt.Errorf("witness %d: %v", i, err)
+		}
+		if !verifyMultiSigSignatures(message, m, pubkeys, signatures) {
+			return fmt.Errorf("witness %d signatures verify failed", i)
+		}
+	}
+
+	return nil
+}
+
+//verifyTxWitness 从节点获取交易并验证见证签名
+func (wm *WalletManager) verifyTxWitness(txid string) error {
+
+	trx, err := wm.GetTransaction(txid)
+	if err != nil {
+		return err
+	}
+
+	rawHex, err := wm.getRawTxHexByCore(txid)
+	if err != nil {
+		return err
+	}
+
+	return VerifyTransactionWitnesses(rawHex, trx.Scripts)
+}
+
+//verifyBlockTxWitnesses 分叉后重扫区块时并行验证全部交易的见证签名，返回验证失败的交易及原因，
+//浏览器模式无法获取原始交易，不验证
+func (bs *NEOBlockScanner) verifyBlockTxWitnesses(block *Block) map[string]string {
+
+	failed := make(map[string]string)
+
+	if bs.wm.config().RPCServerType == RPCServerExplorer || bs.wm.nodeClient() == nil {
+		return failed
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		txids  = make(chan string)
+		worker = runtime.NumCPU()
+	)
+
+	for i := 0; i < worker; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for txid := range txids {
+				if err := bs.wm.verifyTxWitness(txid); err != nil {
+					mu.Lock()
+					failed[txid] = err.Error()
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, txid := range block.Tx {
+		txids <- txid
+	}
+	close(txids)
+	wg.Wait()
+
+	return failed
+}
+
+//checkForkRescanWitnesses 分叉后重扫的区块验证交易见证签名，有交易验证失败时发出告警，
+//返回错误时不提取该区块，避免接受无效分支上的交易
+func (bs *NEOBlockScanner) checkForkRescanWitnesses(block *Block) error {
+
+	failed := bs.verifyBlockTxWitnesses(block)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	alert := NewAlert(bs.wm.Symbol(), AlertTypeInvalidWitness, block.Height,
+		fmt.Sprintf("block: %s has %d transactions with invalid witness after fork", block.Hash, len(failed)))
+	for txid, reason := range failed {
+		alert.Details[txid] = reason
+	}
+	bs.newAlertNotify(alert)
+
+	return fmt.Errorf("block height: %d has %d transactions with invalid witness", block.Height, len(failed))
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+//testSignedTransaction 构造单签交易，返回原始交易和见证
+func testSignedTransaction(t *testing.T, unsigned []byte) (string, []*Witness) {
+
+	prikey := owcrypt.Hash([]byte("witness"), 0, owcrypt.HASH_ALG_SHA256)
+	pubkey, _ := owcrypt.GenPubkey(prikey, owcrypt.ECC_CURVE_SECP256R1)
+	verification := append([]byte{opPushBytes33}, owcrypt.PointCompress(pubkey, owcrypt.ECC_CURVE_SECP256R1)...)
+	verification = append(verification, opCheckSig)
+
+	message := owcrypt.Hash(unsigned, 0, owcrypt.HASH_ALG_SHA256)
+	sig, _ := owcrypt.Signature(prikey, nil, 0, message, 32, owcrypt.ECC_CURVE_SECP256R1)
+	invocation := append([]byte{opPushBytes64}, sig...)
+
+	scripts := []*Witness{{Invocation: hex.EncodeToString(invocation), Verification: hex.EncodeToString(verification)}}
+	witnesses, err := encodeWitnesses(scripts)
+	if err != nil {
+		t.Fatalf("encodeWitnesses failed unexpected error: %v\n", err)
+	}
+
+	return hex.EncodeToString(append(unsigned, witnesses...)), scripts
+}
+
+func TestVerifyTransactionWitnesses(t *testing.T) {
+	unsigned, _ := hex.DecodeString("80000001c249bb0e8c4e02ed738eeafd5b61c180d9cb1d7633fcdb062aa3a01372ebf4050000")
+
+	rawHex, scripts := testSignedTransaction(t, unsigned)
+	if err := VerifyTransactionWitnesses(rawHex, scripts); err != nil {
+		t.Errorf("VerifyTransactionWitnesses failed unexpected error: %v\n", err)
+	}
+
+	//篡改交易数据
+	tampered, _ := hex.DecodeString(rawHex)
+	tampered[1] ^= 0xff
+	if err := VerifyTransactionWitnesses(hex.EncodeToString(tampered), scripts); err == nil {
+		t.Errorf("tampered transaction should be rejected")
+	}
+
+	//见证与原始交易不一致
+	other := []*Witness{{Invocation: scripts[0].Invocation, Verification: "51"}}
+	if err := VerifyTransactionWitnesses(rawHex, other); err == nil {
+		t.Errorf("witnesses mismatch raw transaction should be rejected")
+	}
+
+	//合约验证脚本不验证
+	contract := []*Witness{{Invocation: "00", Verification: "51"}}
+	witnesses, _ := encodeWitnesses(contract)
+	if err := VerifyTransactionWitnesses(hex.EncodeToString(append(unsigned, witnesses...)), contract); err != nil {
+		t.Errorf("contract witness should be skipped, err: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	ZeroConfNotify      = 0 //内存池交易与区块交易一样通知
+	ZeroConfUnconfirmed = 1 //内存池交易标记为未确认后通知
+	ZeroConfSuppress    = 2 //内存池交易不通知，上链后随区块通知
+)
+
+const (
+	//TxActionUnconfirmed 从内存池提取的未确认交易记录标识
+	TxActionUnconfirmed = "unconfirmed"
+)
+
+//applyZeroConfPolicy 按零确认充值策略处理内存池交易的提取结果，返回需要保存和通知的结果
+func (bs *NEOBlockScanner) applyZeroConfPolicy(extractData map[string]*openwallet.TxExtractData) map[string]*openwallet.TxExtractData {
+
+	switch bs.wm.config().ZeroConfDepositPolicy {
+	case ZeroConfSuppress:
+		//不通知也不跟踪，避免之后对未通知的交易发送丢弃通知
+		return nil
+	case ZeroConfUnconfirmed:
+		for _, data := range extractData {
+			if data != nil && data.Transaction != nil {
+				data.Transaction.TxAction = TxActionUnconfirmed
+			}
+		}
+	}
+
+	return extractData
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package neocoin
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestNEOBlockScanner_ZeroConfDepositPolicy(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DBPath, _ = ioutil.TempDir("", "neo-db")
+	defer os.RemoveAll(wm.Config.DBPath)
+
+	observer := &testReplayObserver{}
+	wm.Blockscanner.AddObserver(observer)
+
+	newData := func(txid string) map[string]*openwallet.TxExtractData {
+		data := openwallet.NewBlockExtractData()
+		data.Transaction = &openwallet.Transaction{TxID: txid}
+		return map[string]*openwallet.TxExtractData{"account": data}
+	}
+
+	//默认与区块交易一样通知
+	wm.Blockscanner.newExtractDataNotify(0, newData("tx1"))
+	if len(observer.data) != 1 || observer.data[0].Transaction.TxAction != "" {
+		t.Errorf("mempool deposit should be notified as is: %v", observer.notified)
+	}
+
+	wm.Config.ZeroConfDepositPolicy = ZeroConfUnconfirmed
+	wm.Blockscanner.newExtractDataNotify(0, newData("tx2"))
+	if len(observer.data) != 2 || observer.data[1].Transaction.TxAction != TxActionUnconfirmed {
+		t.Errorf("mempool deposit should be notified as unconfirmed: %v", observer.notified)
+	}
+
+	//区块交易不受策略影响
+	wm.Config.ZeroConfDepositPolicy = ZeroConfSuppress
+	wm.Blockscanner.newExtractDataNotify(0, newData("tx3"))
+	wm.Blockscanner.newExtractDataNotify(10, newData("tx3"))
+	if len(observer.data) != 3 || observer.data[2].Transaction.TxAction != "" {
+		t.Errorf("suppressed mempool deposit should only be notified in block: %v", observer.notified)
+	}
+
+	//不通知的交易不加入内存池跟踪
+	list, _ := wm.GetMempoolTxs()
+	for _, r := range list {
+		if r.TxID == "tx3" {
+			t.Errorf("suppressed mempool deposit should not be tracked")
+		}
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+//Package privnet 启动docker私有链节点，通过适配器公开接口跑通
+//创建地址 → 充值扫描 → 提现 → 确认 的完整流程，可作为下游项目的冒烟测试
+package privnet
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+)
+
+const (
+	//DefaultImage 默认的私有链镜像，4个共识节点，创世资产已转入GenesisAddress
+	DefaultImage = "cityofzion/neo-privatenet"
+
+	//GenesisWIF 私有链镜像中持有全部NEO和GAS的账户私钥
+	GenesisWIF = "KxDgvEKzgSBPPfuVfw67oPQBSjidEiqTHURKSDL1R7yGaGYAeYnr"
+	//GenesisAddress GenesisWIF对应的地址
+	GenesisAddress = "AK2nJJpJr6o664CWJKi1QRXjqeic2zRp8y"
+)
+
+//Funder 向指定地址转入资产，返回交易ID
+type Funder func(h *Harness, address, amount string) (string, error)
+
+//Config 测试环境配置
+type Config struct {
+	//私有链镜像
+	Image string
+	//节点RPC端口
+	RPCPort int
+	//已运行的私有链节点地址，设置后不启动docker容器
+	ServerAPI string
+	//工作目录，保存配置、钱包和数据，为空时使用临时目录并在Stop时删除
+	WorkDir string
+	//节点启动等待时间
+	StartTimeout time.Duration
+	//充值扫描和提现确认的等待时间
+	ConfirmTimeout time.Duration
+	//确认数
+	ConfirmBlocks uint64
+	//充值金额
+	DepositAmount string
+	//提现金额
+	WithdrawAmount string
+	//提现地址，为空时提现回GenesisAddress
+	WithdrawTo string
+	//充值方式，为空时使用节点钱包的sendtoaddress
+	Fund Funder
+}
+
+//NewConfig 默认测试环境配置
+func NewConfig() *Config {
+	return &Config{
+		Image:          DefaultImage,
+		RPCPort:        30333,
+		StartTimeout:   2 * time.Minute,
+		ConfirmTimeout: 3 * time.Minute,
+		ConfirmBlocks:  1,
+		DepositAmount:  "10",
+		WithdrawAmount: "1",
+		WithdrawTo:     GenesisAddress,
+		Fund:           SendToAddress,
+	}
+}
+
+//Harness 私有链测试环境
+type Harness struct {
+	Config    *Config
+	ServerAPI string
+	//直接访问节点的适配器实例
+	Adapter *neocoin.WalletManager
+
+	containerID string
+	tempDir     bool
+}
+
+//Start 启动私有链节点并等待出块
+func Start(cfg *Config) (*Harness, error) {
+
+	if cfg == nil {
+		cfg = NewConfig()
+	}
+
+	h := &Harness{Config: cfg, ServerAPI: cfg.ServerAPI}
+
+	if len(cfg.WorkDir) == 0 {
+		dir, err := ioutil.TempDir("", "neo-privnet")
+		if err != nil {
+			return nil, err
+		}
+		cfg.WorkDir = dir
+		h.tempDir = true
+	}
+
+	if len(h.ServerAPI) == 0 {
+		id, err := docker("run", "-d", "--rm",
+			"-p", fmt.Sprintf("%d:%d", cfg.RPCPort, cfg.RPCPort),
+			cfg.Image)
+		if err != nil {
+			h.Stop()
+			return nil, fmt.Errorf("start privnet container failed, unexpected error: %v", err)
+		}
+		h.containerID = id
+		h.ServerAPI = fmt.Sprintf("http://127.0.0.1:%d", cfg.RPCPort)
+	}
+
+	h.Adapter = neocoin.NewWalletManager()
+	h.Adapter.Config.IsTestNet = true
+	h.Adapter.Config.ConfirmBlocks = cfg.ConfirmBlocks
+	h.Adapter.WalletClient = neocoin.NewClient(h.ServerAPI, "", false)
+
+	if err := h.waitForBlocks(cfg.StartTimeout); err != nil {
+		h.Stop()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+//Stop 停止容器并清理临时目录
+func (h *Harness) Stop() {
+	if len(h.containerID) > 0 {
+		docker("rm", "-f", h.containerID)
+		h.containerID = ""
+	}
+	if h.tempDir {
+		os.RemoveAll(h.Config.WorkDir)
+	}
+}
+
+//AssetsConfig 指向私有链节点的NEO.ini内容
+func (h *Harness) AssetsConfig() string {
+	return fmt.Sprintf(`isScan = true
+rpcServerType = 0
+serverAPI = "%s"
+isTestNet = true
+confirmBlocks = %d
+dataDir = "%s"
+`, h.ServerAPI, h.Config.ConfirmBlocks, filepath.Join(h.Config.WorkDir, "data"))
+}
+
+//writeAssetsConfig 写入适配器配置文件，返回配置目录
+func (h *Harness) writeAssetsConfig() (string, error) {
+	dir := filepath.Join(h.Config.WorkDir, "conf")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	err := ioutil.WriteFile(filepath.Join(dir, neocoin.Symbol+".ini"), []byte(h.AssetsConfig()), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+//waitForBlocks 等待节点RPC可用并产生创世区块之后的区块
+func (h *Harness) waitForBlocks(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		height, err := h.Adapter.ScannableTipHeight()
+		if err == nil && height > 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("privnet node: %s is not ready in %v, last error: %v", h.ServerAPI, timeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+//SendToAddress 通过节点已打开钱包的sendtoaddress转入NEO，私有链镜像的节点钱包持有创世资产
+func SendToAddress(h *Harness, address, amount string) (string, error) {
+	result, err := h.Adapter.CallRaw("sendtoaddress", []interface{}{
+		h.Adapter.Config.NEOAssetID, address, amount,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	txid := result.Get("txid").String()
+	if len(txid) == 0 {
+		return "", fmt.Errorf("sendtoaddress returns no txid: %s", result.Raw)
+	}
+
+	return txid, nil
+}
+
+//docker 执行docker命令，返回去掉首尾空白的输出
+func docker(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %v, %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package privnet
+
+import (
+	"os"
+	"testing"
+)
+
+//需要docker，设置NEO_PRIVNET=1时执行；设置NEO_PRIVNET_API时使用已运行的私有链节点
+func TestRunSmokeTest(t *testing.T) {
+	if len(os.Getenv("NEO_PRIVNET")) == 0 && len(os.Getenv("NEO_PRIVNET_API")) == 0 {
+		t.Skip("set NEO_PRIVNET=1 to run privnet smoke test with docker")
+	}
+
+	cfg := NewConfig()
+	cfg.ServerAPI = os.Getenv("NEO_PRIVNET_API")
+
+	result := RunSmokeTest(t, cfg)
+	t.Logf("privnet smoke result: %+v", result)
+}
+
+func TestHarness_AssetsConfig(t *testing.T) {
+	h := &Harness{Config: NewConfig(), ServerAPI: "http://127.0.0.1:30333"}
+	h.Config.WorkDir = "/tmp/privnet"
+
+	want := `isScan = true
+rpcServerType = 0
+serverAPI = "http://127.0.0.1:30333"
+isTestNet = true
+confirmBlocks = 1
+dataDir = "/tmp/privnet/data"
+`
+	if got := h.AssetsConfig(); got != want {
+		t.Errorf("unexpected assets config:\n%s", got)
+	}
+}
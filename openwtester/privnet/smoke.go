@@ -0,0 +1,219 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package privnet
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Assetsadapter/neo-adapter/neocoin"
+	"github.com/blocktree/openwallet/openw"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	smokeAppID    = "neo-privnet-smoke"
+	smokePassword = "12345678"
+)
+
+//SmokeResult 冒烟测试各步骤的结果
+type SmokeResult struct {
+	WalletID     string
+	AccountID    string
+	Address      string
+	DepositTxID  string
+	WithdrawTxID string
+}
+
+//depositWatcher 等待指定交易的充值提取通知
+type depositWatcher struct {
+	mu    sync.Mutex
+	txid  string
+	found chan struct{}
+}
+
+//BlockScanNotify 新区块扫描完成通知
+func (w *depositWatcher) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+//BlockTxExtractDataNotify 区块提取结果通知
+func (w *depositWatcher) BlockTxExtractDataNotify(account *openwallet.AssetsAccount, data *openwallet.TxExtractData) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if data.Transaction == nil || w.found == nil {
+		return nil
+	}
+	if sameTxID(data.Transaction.TxID, w.txid) {
+		close(w.found)
+		w.found = nil
+	}
+	return nil
+}
+
+//wait 等待充值通知
+func (w *depositWatcher) wait(txid string, timeout time.Duration) error {
+	found := make(chan struct{})
+	w.mu.Lock()
+	w.txid = txid
+	w.found = found
+	w.mu.Unlock()
+
+	select {
+	case <-found:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("deposit tx: %s is not extracted in %v", txid, timeout)
+	}
+}
+
+//sameTxID 比较交易ID，忽略0x前缀和大小写
+func sameTxID(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "0x"), strings.TrimPrefix(b, "0x"))
+}
+
+//NewWalletManager 创建连接私有链并开启区块扫描的openw钱包管理器
+func (h *Harness) NewWalletManager() (*openw.WalletManager, error) {
+
+	confDir, err := h.writeAssetsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	openw.RegAssets(neocoin.Symbol, neocoin.NewWalletManager())
+
+	tc := openw.NewConfig()
+	tc.ConfigDir = confDir
+	tc.DBPath = filepath.Join(h.Config.WorkDir, "openw", "db")
+	tc.KeyDir = filepath.Join(h.Config.WorkDir, "openw", "key")
+	tc.BackupDir = filepath.Join(h.Config.WorkDir, "openw", "backup")
+	tc.EnableBlockScan = true
+	tc.SupportAssets = []string{neocoin.Symbol}
+
+	return openw.NewWalletManager(tc), nil
+}
+
+//RunSmoke 通过适配器公开接口执行 创建地址 → 充值扫描 → 提现 → 确认 流程
+func (h *Harness) RunSmoke() (*SmokeResult, error) {
+
+	tm, err := h.NewWalletManager()
+	if err != nil {
+		return nil, fmt.Errorf("setup wallet manager: %v", err)
+	}
+	defer tm.CloseDB(smokeAppID)
+
+	watcher := &depositWatcher{}
+	tm.AddObserver(watcher)
+	defer tm.RemoveObserver(watcher)
+
+	result := &SmokeResult{}
+
+	//创建地址
+	w, _, err := tm.CreateWallet(smokeAppID, &openwallet.Wallet{Alias: "privnet smoke", IsTrust: true, Password: smokePassword})
+	if err != nil {
+		return result, fmt.Errorf("create wallet: %v", err)
+	}
+	result.WalletID = w.WalletID
+
+	account := &openwallet.AssetsAccount{Alias: "privnet smoke", WalletID: w.WalletID, Required: 1, Symbol: neocoin.Symbol, IsTrust: true}
+	account, address, err := tm.CreateAssetsAccount(smokeAppID, w.WalletID, smokePassword, account, nil)
+	if err != nil {
+		return result, fmt.Errorf("create assets account: %v", err)
+	}
+	result.AccountID = account.AccountID
+	result.Address = address.Address
+
+	//充值并等待扫描
+	result.DepositTxID, err = h.Config.Fund(h, address.Address, h.Config.DepositAmount)
+	if err != nil {
+		return result, fmt.Errorf("fund address: %v", err)
+	}
+
+	if err = watcher.wait(result.DepositTxID, h.Config.ConfirmTimeout); err != nil {
+		return result, fmt.Errorf("deposit scan: %v", err)
+	}
+
+	//充值交易满足确认数后才可花费
+	if err = h.WaitConfirmed(result.DepositTxID, h.Config.ConfirmTimeout); err != nil {
+		return result, fmt.Errorf("deposit confirm: %v", err)
+	}
+
+	//提现
+	rawTx, err := tm.CreateTransaction(smokeAppID, w.WalletID, account.AccountID, h.Config.WithdrawAmount, h.Config.WithdrawTo, "", "", nil)
+	if err != nil {
+		return result, fmt.Errorf("create withdraw: %v", err)
+	}
+
+	if _, err = tm.SignTransaction(smokeAppID, w.WalletID, account.AccountID, smokePassword, rawTx); err != nil {
+		return result, fmt.Errorf("sign withdraw: %v", err)
+	}
+
+	if _, err = tm.VerifyTransaction(smokeAppID, w.WalletID, account.AccountID, rawTx); err != nil {
+		return result, fmt.Errorf("verify withdraw: %v", err)
+	}
+
+	tx, err := tm.SubmitTransaction(smokeAppID, w.WalletID, account.AccountID, rawTx)
+	if err != nil {
+		return result, fmt.Errorf("submit withdraw: %v", err)
+	}
+	result.WithdrawTxID = tx.TxID
+
+	//等待提现确认
+	if err = h.WaitConfirmed(result.WithdrawTxID, h.Config.ConfirmTimeout); err != nil {
+		return result, fmt.Errorf("withdraw confirm: %v", err)
+	}
+
+	return result, nil
+}
+
+//WaitConfirmed 等待交易上链并达到确认数
+func (h *Harness) WaitConfirmed(txid string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		tx, err := h.Adapter.GetTransaction(txid)
+		if err == nil && tx.BlockHeight > 0 {
+			tip, tipErr := h.Adapter.ScannableTipHeight()
+			if tipErr == nil && tip+1 >= tx.BlockHeight+h.Config.ConfirmBlocks {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("tx: %s is not confirmed in %v", txid, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+//RunSmokeTest 启动私有链并执行冒烟测试，供下游项目在测试中直接调用
+func RunSmokeTest(t testing.TB, cfg *Config) *SmokeResult {
+
+	h, err := Start(cfg)
+	if err != nil {
+		t.Fatalf("start privnet failed, unexpected error: %v", err)
+	}
+	defer h.Stop()
+
+	result, err := h.RunSmoke()
+	if err != nil {
+		t.Fatalf("privnet smoke failed, result: %+v, unexpected error: %v", result, err)
+	}
+
+	return result
+}